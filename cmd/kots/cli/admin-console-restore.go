@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func AdminConsoleRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "restore [snapshot name]",
+		Short:         "Restore the admin console from a snapshot",
+		Long:          "Install the admin console, and restore its database and object store from a snapshot. This can be used to rebuild an install in a new cluster.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			restoreOptions := kotsadm.RestoreSnapshotOptions{
+				DeployOptions: kotsadm.DeployOptions{
+					Namespace:         v.GetString("namespace"),
+					Kubeconfig:        v.GetString("kubeconfig"),
+					SharedPassword:    v.GetString("shared-password"),
+					ServiceType:       "ClusterIP",
+					Hostname:          v.GetString("hostname"),
+					BackupSchedule:    v.GetString("backup-schedule"),
+					BackupDestination: backupDestinationFromFlags(v),
+				},
+				SnapshotName: args[0],
+			}
+
+			log := logger.NewLogger()
+			log.ActionWithoutSpinner("Restoring Admin Console")
+			if err := kotsadm.RestoreSnapshot(restoreOptions); err != nil {
+				return errors.Wrap(err, "failed to restore snapshot")
+			}
+
+			log.ActionWithoutSpinner("")
+			log.ActionWithoutSpinner("The Admin Console was restored from snapshot %s", args[0])
+			log.ActionWithoutSpinner("To access the Admin Console, run kubectl kots admin-console --namespace %s", v.GetString("namespace"))
+			log.ActionWithoutSpinner("")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("kubeconfig", defaultKubeConfig(), "the kubeconfig to use")
+	cmd.Flags().StringP("namespace", "n", "default", "the namespace to restore the admin console to")
+	cmd.Flags().String("shared-password", "", "shared password to log in to the restored admin console with")
+	cmd.Flags().String("hostname", "localhost:8800", "the hostname to that the admin console will be exposed on")
+	cmd.Flags().String("backup-schedule", "", "cron schedule to keep taking snapshots on after the restore, scheduled backups are disabled when not set")
+	addBackupDestinationFlags(cmd)
+
+	return cmd
+}
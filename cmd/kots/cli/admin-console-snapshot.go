@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func AdminConsoleSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "snapshot",
+		Short:         "Take a snapshot of the admin console",
+		Long:          "Take a snapshot of the admin console database and object store now, and write it to the destination that scheduled backups were configured with",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			snapshotOptions := kotsadm.SnapshotOptions{
+				Namespace:  v.GetString("namespace"),
+				Kubeconfig: v.GetString("kubeconfig"),
+			}
+
+			snapshotName, err := kotsadm.Snapshot(snapshotOptions)
+			if err != nil {
+				return errors.Wrap(err, "failed to take snapshot")
+			}
+
+			log := logger.NewLogger()
+			log.ActionWithoutSpinner("")
+			log.ActionWithoutSpinner("Snapshot %s was taken", snapshotName)
+			log.ActionWithoutSpinner("To restore it, run kubectl kots admin-console restore %s", snapshotName)
+			log.ActionWithoutSpinner("")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("kubeconfig", defaultKubeConfig(), "the kubeconfig to use")
+	cmd.Flags().StringP("namespace", "n", "default", "the namespace where the admin console is running")
+
+	return cmd
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/auth"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+func AdminConsoleTokenCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage the api tokens of the admin console",
+		Long:  "Create, rotate, list and revoke the scoped, expiring api tokens that CI systems upload to the admin console with, instead of a kubeconfig. Tokens are sent by kots upload from KOTS_API_TOKEN.",
+	}
+
+	cmd.PersistentFlags().String("kubeconfig", defaultKubeConfig(), "the kubeconfig to use")
+	cmd.PersistentFlags().StringP("namespace", "n", "default", "the namespace where the admin console is running")
+
+	cmd.AddCommand(adminConsoleTokenCreateCmd())
+	cmd.AddCommand(adminConsoleTokenRotateCmd())
+	cmd.AddCommand(adminConsoleTokenListCmd())
+	cmd.AddCommand(adminConsoleTokenRemoveCmd())
+
+	return cmd
+}
+
+func adminConsoleTokenCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "create",
+		Short:         "Create an api token",
+		Long:          "Create an api token with a scope that expires. The token is printed once and can't be read again.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			clientset, err := tokenClientset()
+			if err != nil {
+				return err
+			}
+
+			token, apiToken, err := auth.CreateAPIToken(clientset, v.GetString("namespace"), v.GetString("scope"), v.GetDuration("ttl"))
+			if err != nil {
+				return errors.Wrap(err, "failed to create api token")
+			}
+
+			printAPIToken(token, apiToken)
+			return nil
+		},
+	}
+
+	cmd.Flags().String("scope", auth.TokenScopeUpload, "the scope of the token, upload or read-only")
+	cmd.Flags().Duration("ttl", 30*24*time.Hour, "how long the token can be used for")
+
+	return cmd
+}
+
+func adminConsoleTokenRotateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "rotate [name]",
+		Short:         "Replace an api token with a new one",
+		Long:          "Create a new api token with the scope of an existing one, and revoke the existing one.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		Args:          cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			clientset, err := tokenClientset()
+			if err != nil {
+				return err
+			}
+
+			token, apiToken, err := auth.RotateAPIToken(clientset, v.GetString("namespace"), args[0], v.GetDuration("ttl"))
+			if err != nil {
+				return errors.Wrap(err, "failed to rotate api token")
+			}
+
+			printAPIToken(token, apiToken)
+			return nil
+		},
+	}
+
+	cmd.Flags().Duration("ttl", 30*24*time.Hour, "how long the new token can be used for")
+
+	return cmd
+}
+
+func adminConsoleTokenListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "ls",
+		Short:         "List the api tokens",
+		Long:          "List the api tokens of the admin console, with their scope and expiry. The tokens themselves can't be listed.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			clientset, err := tokenClientset()
+			if err != nil {
+				return err
+			}
+
+			apiTokens, err := auth.ListAPITokens(clientset, v.GetString("namespace"))
+			if err != nil {
+				return errors.Wrap(err, "failed to list api tokens")
+			}
+
+			now := time.Now()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSCOPE\tEXPIRES")
+			for _, apiToken := range apiTokens {
+				expires := apiToken.ExpiresAt.Format(time.RFC3339)
+				if apiToken.Expired(now) {
+					expires += " (expired)"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", apiToken.Name, apiToken.Scope, expires)
+			}
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+func adminConsoleTokenRemoveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "rm [name]",
+		Short:         "Revoke an api token",
+		Long:          "Revoke an api token, it can't be used anymore.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		Args:          cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			clientset, err := tokenClientset()
+			if err != nil {
+				return err
+			}
+
+			if err := auth.DeleteAPIToken(clientset, v.GetString("namespace"), args[0]); err != nil {
+				return errors.Wrap(err, "failed to revoke api token")
+			}
+
+			log := logger.NewLogger()
+			log.ActionWithoutSpinner("API token %s was revoked", args[0])
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func printAPIToken(token string, apiToken *auth.APIToken) {
+	log := logger.NewLogger()
+	log.ActionWithoutSpinner("API token %s with scope %s expires at %s", apiToken.Name, apiToken.Scope, apiToken.ExpiresAt.Format(time.RFC3339))
+	log.ActionWithoutSpinner("Set KOTS_API_TOKEN to it to upload with it, it isn't shown again:")
+	fmt.Println(token)
+}
+
+func tokenClientset() (*kubernetes.Clientset, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	return clientset, nil
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/audit"
 	"github.com/replicatedhq/kots/pkg/kotsadm"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/spf13/cobra"
@@ -32,6 +33,8 @@ func AdminConsoleUpgradeCmd() *cobra.Command {
 				return errors.Wrap(err, "failed to upgrade")
 			}
 
+			recordAuditEvent(upgradeOptions.Namespace, audit.ActionAdminConsoleUpgrade, nil)
+
 			log.ActionWithoutSpinner("")
 			log.ActionWithoutSpinner("The Admin Console is running the latest version")
 			log.ActionWithoutSpinner("To access the Admin Console, run kubectl kots admin-console --namespace %s", v.GetString("namespace"))
@@ -69,6 +69,10 @@ func AdminConsoleCmd() *cobra.Command {
 	cmd.Flags().StringP("namespace", "n", "default", "the namespace where the admin console is running")
 
 	cmd.AddCommand(AdminConsoleUpgradeCmd())
+	cmd.AddCommand(AdminConsoleGenerateManifestsCmd())
+	cmd.AddCommand(AdminConsoleSnapshotCmd())
+	cmd.AddCommand(AdminConsoleRestoreCmd())
+	cmd.AddCommand(AdminConsoleTokenCmd())
 
 	return cmd
 }
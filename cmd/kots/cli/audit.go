@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/audit"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/version"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+func AuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "audit",
+		Short:         "List the operations done with the kots cli on an admin console",
+		Long:          `List the operations, such as install, upload, deploy and upgrade, that were done with the kots cli on the admin console in a namespace, and the user, host and kots version that did them. The user is the identity that the cluster authenticated the cli as. The log is kept in a config map that anyone who can edit config maps in the namespace can change, and only the last 1000 operations are kept.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			listOptions := audit.ListOptions{
+				Action: v.GetString("action"),
+				User:   v.GetString("user"),
+			}
+			if since := v.GetDuration("since"); since > 0 {
+				listOptions.Since = time.Now().Add(-since)
+			}
+
+			clientset, err := auditClientset()
+			if err != nil {
+				return err
+			}
+
+			events, err := audit.List(clientset, v.GetString("namespace"), listOptions)
+			if err != nil {
+				return errors.Wrap(err, "failed to list audit events")
+			}
+
+			if v.GetString("output") == "json" {
+				b, err := json.MarshalIndent(events, "", "  ")
+				if err != nil {
+					return errors.Wrap(err, "failed to marshal audit events")
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tACTION\tUSER\tHOST\tVERSION\tPARAMETERS")
+			for _, event := range events {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", event.Time.Format(time.RFC3339), event.Action, event.User, event.Host, event.KotsVersion, formatAuditParameters(event.Parameters))
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().String("kubeconfig", defaultKubeConfig(), "the kubeconfig to use")
+	cmd.Flags().StringP("namespace", "n", "default", "the namespace where the admin console is running")
+	cmd.Flags().String("action", "", "only list operations of this type (install, upload, deploy, registry-change, admin-console-upgrade, upstream-upgrade or reset-password)")
+	cmd.Flags().String("user", "", "only list operations of this user")
+	cmd.Flags().Duration("since", 0, "only list operations newer than this duration (e.g. 24h)")
+	cmd.Flags().StringP("output", "o", "", "output format, json or empty for a table")
+
+	return cmd
+}
+
+// recordAuditEvent adds the operation to the audit log of the admin console. A failure to
+// record is reported but does not fail the operation, which has already completed.
+func recordAuditEvent(namespace string, action string, parameters map[string]string) {
+	if err := writeAuditEvent(namespace, action, parameters); err != nil {
+		log := logger.NewLogger()
+		log.Info("Unable to record %s in the audit log: %s", action, err.Error())
+	}
+}
+
+func writeAuditEvent(namespace string, action string, parameters map[string]string) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	event := audit.Event{
+		Action:      action,
+		User:        audit.CurrentUser(clientset, cfg),
+		Host:        audit.CurrentHost(),
+		KotsVersion: version.Version(),
+		Parameters:  parameters,
+	}
+	return audit.Record(clientset, namespace, event)
+}
+
+func auditClientset() (*kubernetes.Clientset, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	return clientset, nil
+}
+
+func formatAuditParameters(parameters map[string]string) string {
+	keys := []string{}
+	for k := range parameters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	formatted := []string{}
+	for _, k := range keys {
+		formatted = append(formatted, fmt.Sprintf("%s=%s", k, parameters[k]))
+	}
+	return strings.Join(formatted, " ")
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/autodeploy"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/deploy"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/pull"
+	"github.com/replicatedhq/kots/pkg/rendermanifest"
+	"github.com/replicatedhq/kots/pkg/updatecheck"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func DownstreamAutoDeployCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "auto-deploy [app dir] [name]",
+		Short:         "Keep a downstream up to date with the upstream releases that a policy allows",
+		Long:          "Check the upstream for releases every interval, and deploy the latest release within the upgrade level to the downstream, during the maintenance window if there is one. The app directory is pulled again at the release before it's deployed. Required releases are deployed before the releases after them.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 2 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			appDir := ExpandDir(args[0])
+			if _, err := existingDownstreamDir(filepath.Join(appDir, "overlays"), args[1]); err != nil {
+				return err
+			}
+			cipher, err := kubeconfigCipher(v, false)
+			if err != nil {
+				return err
+			}
+
+			policy := autodeploy.Policy{
+				Level: autodeploy.UpgradeLevel(v.GetString("level")),
+			}
+			if v.GetString("window") != "" {
+				location, err := time.LoadLocation(v.GetString("timezone"))
+				if err != nil {
+					return errors.Wrap(err, "failed to load time zone")
+				}
+				policy.Window, err = autodeploy.ParseMaintenanceWindow(v.GetString("window"), location)
+				if err != nil {
+					return err
+				}
+			}
+
+			options, err := appDirAutoDeployOptions(appDir, policy)
+			if err != nil {
+				return err
+			}
+			options.Deployer = &appDirDeployer{
+				appDir:         appDir,
+				name:           args[1],
+				cipher:         cipher,
+				auditNamespace: v.GetString("audit-namespace"),
+				deployOptions: deploy.DeployOptions{
+					AppSlug: filepath.Base(appDir),
+					Kubectl: v.GetString("kubectl"),
+					Prune:   v.GetBool("prune"),
+					Wait:    true,
+					Timeout: v.GetDuration("timeout"),
+				},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			signalChan := make(chan os.Signal, 1)
+			signal.Notify(signalChan, os.Interrupt)
+			go func() {
+				<-signalChan
+				cancel()
+			}()
+
+			log := logger.NewLogger()
+			if v.GetBool("once") {
+				update, err := autodeploy.Reconcile(ctx, options)
+				if err != nil {
+					return err
+				}
+				if update == nil {
+					log.Info("There is no update to deploy")
+					return nil
+				}
+				log.Info("Deployed %s", update.Version)
+				return nil
+			}
+
+			log.Info("Checking for updates to deploy every %s", v.GetDuration("interval"))
+			return autodeploy.Run(ctx, options, v.GetDuration("interval"), log)
+		},
+	}
+
+	cmd.Flags().String("level", string(autodeploy.UpgradeLevelPatch), "the largest version change that's deployed, patch, minor or major")
+	cmd.Flags().String("window", "", "the maintenance window that updates are deployed in, e.g. \"Sat,Sun 02:00-04:00\", any time when empty")
+	cmd.Flags().String("timezone", "UTC", "the time zone of the maintenance window")
+	cmd.Flags().Duration("interval", time.Hour, "how often to check for updates")
+	cmd.Flags().Bool("once", false, "check for updates once instead of running until interrupted, e.g. to run from cron")
+	cmd.Flags().String("kubectl", "kubectl", "the kubectl executable")
+	cmd.Flags().Bool("prune", true, "delete the objects that were removed from the downstream by a release")
+	cmd.Flags().Duration("timeout", 5*time.Minute, "how long to wait for the objects of a release to be ready")
+	cmd.Flags().String("audit-namespace", "", "namespace of the admin console to record the deploys in the audit log of, deploys aren't recorded when not set")
+	addKubeconfigKeyFileFlag(cmd)
+
+	return cmd
+}
+
+// appDirAutoDeployOptions returns the options with the license and the release of the app directory
+func appDirAutoDeployOptions(appDir string, policy autodeploy.Policy) (autodeploy.Options, error) {
+	manifest, err := rendermanifest.Load(appDir)
+	if err != nil {
+		return autodeploy.Options{}, errors.Wrap(err, "failed to load render manifest")
+	}
+	if manifest == nil {
+		return autodeploy.Options{}, errors.Errorf("%s was not created by kots pull, it has no %s", appDir, rendermanifest.Filename)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(appDir, "upstream", "userdata", "license.yaml"))
+	if err != nil {
+		return autodeploy.Options{}, errors.Wrap(err, "failed to read license")
+	}
+	license, err := kotslicense.ParseLicense(b)
+	if err != nil {
+		return autodeploy.Options{}, errors.Wrap(err, "failed to parse license")
+	}
+	license, err = kotslicense.VerifySignature(license)
+	if err != nil {
+		return autodeploy.Options{}, errors.Wrap(err, "failed to verify license signature")
+	}
+
+	options := autodeploy.Options{
+		License:        license,
+		Policy:         policy,
+		CurrentCursor:  manifest.UpdateCursor,
+		CurrentVersion: manifest.VersionLabel,
+	}
+	return options, nil
+}
+
+// appDirDeployer pulls the app directory again at the release and deploys the downstream
+type appDirDeployer struct {
+	appDir         string
+	name           string
+	cipher         *crypto.AESCipher
+	deployOptions  deploy.DeployOptions
+	auditNamespace string
+}
+
+func (d *appDirDeployer) Deploy(ctx context.Context, update updatecheck.Update) error {
+	pullOptions := pull.PullOptions{
+		Downstreams: []string{d.name},
+		Silent:      true,
+	}
+	if _, err := pull.PullReleaseWithContext(ctx, d.appDir, update.Cursor, pullOptions); err != nil {
+		return err
+	}
+
+	if err := deployDownstream(d.appDir, d.name, d.cipher, d.deployOptions); err != nil {
+		return err
+	}
+
+	recordDeployAuditEvent(d.auditNamespace, d.appDir, d.name, update.Version)
+	return nil
+}
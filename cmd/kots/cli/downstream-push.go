@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/gitops"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func DownstreamPushCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "push [app dir] [name]",
+		Short:         "Commit a downstream to a git repository and open a pull request",
+		Long:          "Commit the downstream, built with kubectl kustomize or as the base and overlays of the app directory, to a branch of a git repository, and open a pull request of the branch on github, gitlab or bitbucket. The release is deployed from the repository once the pull request is merged.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 2 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			if v.GetString("repo") == "" {
+				return errors.New("--repo is required")
+			}
+
+			log := logger.NewLogger()
+			log.ActionWithSpinner("Pushing %s to %s", args[1], v.GetString("repo"))
+			result, err := gitops.Push(ExpandDir(args[0]), args[1], gitops.GitOpsOptions{
+				RepoURI:     v.GetString("repo"),
+				Provider:    v.GetString("provider"),
+				APIEndpoint: v.GetString("api-endpoint"),
+				Token:       v.GetString("git-token"),
+				Branch:      v.GetString("branch"),
+				Path:        v.GetString("path"),
+				Content:     v.GetString("content"),
+				Kubectl:     v.GetString("kubectl"),
+			})
+			if err != nil {
+				log.FinishSpinnerWithError()
+				return err
+			}
+			log.FinishSpinner()
+
+			if result.Branch == "" {
+				log.ActionWithoutSpinner("The repository already has this release")
+				return nil
+			}
+			log.ActionWithoutSpinner("Pushed %s to branch %s", result.Commit, result.Branch)
+			if result.PullRequestURL != "" {
+				log.ActionWithoutSpinner("Opened pull request %s", result.PullRequestURL)
+			} else {
+				log.ActionWithoutSpinner("A pull request of branch %s is already open", result.Branch)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("repo", "", "the url of the git repository, e.g. https://github.com/org/repo")
+	cmd.Flags().String("provider", "", "github, gitlab or bitbucket, found from the host of the repository when empty")
+	cmd.Flags().String("api-endpoint", "", "the api of the provider, e.g. for github enterprise")
+	cmd.Flags().String("git-token", "", "the token to push and open the pull request with, can be set with KOTS_GIT_TOKEN")
+	cmd.Flags().String("branch", "main", "the branch to open the pull request against")
+	cmd.Flags().String("path", "", "the directory in the repository to write the downstream to, the app slug when empty")
+	cmd.Flags().String("content", gitops.ContentRendered, "rendered to commit the built downstream, or tree to commit the base and overlays")
+	cmd.Flags().String("kubectl", "kubectl", "the kubectl executable")
+
+	return cmd
+}
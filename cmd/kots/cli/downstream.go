@@ -0,0 +1,387 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/audit"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/deploy"
+	"github.com/replicatedhq/kots/pkg/diff"
+	"github.com/replicatedhq/kots/pkg/downstream"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func DownstreamCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "downstream",
+		Short:         "Add, remove and list the downstreams of an application directory",
+		Long:          `Manage the downstreams in the overlays of an application directory created by kots pull. Each downstream (e.g. staging, prod-us) is a kustomization of the midstream with its own patches.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(DownstreamAddCmd())
+	cmd.AddCommand(DownstreamRemoveCmd())
+	cmd.AddCommand(DownstreamListCmd())
+	cmd.AddCommand(DownstreamDeployCmd())
+	cmd.AddCommand(DownstreamDiffCmd())
+	cmd.AddCommand(DownstreamAutoDeployCmd())
+	cmd.AddCommand(DownstreamPushCmd())
+
+	return cmd
+}
+
+func DownstreamAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "add [app dir] [name]",
+		Short:         "Add a downstream, or add patches to an existing downstream",
+		Long:          "",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 2 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			patches := map[string][]byte{}
+			for _, patchFile := range v.GetStringSlice("patch") {
+				b, err := ioutil.ReadFile(ExpandDir(patchFile))
+				if err != nil {
+					return errors.Wrapf(err, "failed to read patch %s", patchFile)
+				}
+				patches[filepath.Base(patchFile)] = b
+			}
+
+			appDir := ExpandDir(args[0])
+			overlaysDir := filepath.Join(appDir, "overlays")
+			if err := downstream.Add(overlaysDir, args[1], downstream.AddOptions{Patches: patches}); err != nil {
+				return err
+			}
+
+			log := logger.NewLogger()
+
+			if v.GetBool("use-current-context") {
+				if err := downstream.RemoveKubeconfig(overlaysDir, args[1]); err != nil {
+					return err
+				}
+			} else if v.GetString("kubeconfig") != "" {
+				kubeconfig, err := ioutil.ReadFile(ExpandDir(v.GetString("kubeconfig")))
+				if err != nil {
+					return errors.Wrap(err, "failed to read kubeconfig")
+				}
+				cipher, err := kubeconfigCipher(v, true)
+				if err != nil {
+					return err
+				}
+				host, err := downstream.SetKubeconfig(overlaysDir, args[1], kubeconfig, cipher)
+				if err != nil {
+					return err
+				}
+				log.ActionWithoutSpinner("Downstream %s is deployed to %s", args[1], host)
+			}
+
+			log.ActionWithoutSpinner("To deploy, run kots downstream deploy %s %s", args[0], args[1])
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSlice("patch", []string{}, "path to a yaml file of strategic merge patches of the midstream objects to add to the downstream")
+	cmd.Flags().String("kubeconfig", "", "the kubeconfig of the cluster to deploy the downstream to, it's stored encrypted in the downstream")
+	cmd.Flags().Bool("use-current-context", false, "remove the stored kubeconfig, the downstream is deployed to the cluster of the current context")
+	addKubeconfigKeyFileFlag(cmd)
+
+	return cmd
+}
+
+func DownstreamRemoveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "remove [app dir] [name]",
+		Short:         "Remove a downstream and its patches",
+		Long:          "",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			return downstream.Remove(filepath.Join(ExpandDir(args[0]), "overlays"), args[1])
+		},
+	}
+
+	return cmd
+}
+
+func DownstreamListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "list [app dir]",
+		Short:         "List the downstreams of an application directory",
+		Long:          "",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			names, err := downstream.List(filepath.Join(ExpandDir(args[0]), "overlays"))
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				fmt.Println(name)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func DownstreamDeployCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "deploy [app dir] [name]",
+		Short:         "Apply a downstream to its cluster",
+		Long:          "Apply a downstream with kubectl, to the cluster of the kubeconfig that was added with the downstream or to the cluster of the current context.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 2 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			appDir := ExpandDir(args[0])
+			cipher, err := kubeconfigCipher(v, false)
+			if err != nil {
+				return err
+			}
+
+			if v.GetBool("prune") || v.GetBool("wait") {
+				err = deployDownstream(appDir, args[1], cipher, deploy.DeployOptions{
+					AppSlug: filepath.Base(appDir),
+					Kubectl: v.GetString("kubectl"),
+					Prune:   v.GetBool("prune"),
+					Wait:    v.GetBool("wait"),
+					Timeout: v.GetDuration("timeout"),
+				})
+			} else {
+				deployOptions := downstream.DeployOptions{
+					Kubectl: v.GetString("kubectl"),
+					Stdout:  os.Stdout,
+					Stderr:  os.Stderr,
+				}
+				err = downstream.Deploy(filepath.Join(appDir, "overlays"), args[1], cipher, deployOptions)
+			}
+			if err != nil {
+				return err
+			}
+
+			recordDeployAuditEvent(v.GetString("audit-namespace"), appDir, args[1], "")
+			return nil
+		},
+	}
+
+	cmd.Flags().String("kubectl", "kubectl", "the kubectl executable")
+	cmd.Flags().Bool("prune", false, "delete the objects that were deployed before and were removed from the downstream, objects are applied by kots instead of kubectl apply")
+	cmd.Flags().Bool("wait", false, "wait for deployments and statefulsets to roll out and for jobs to complete, objects are applied by kots instead of kubectl apply")
+	cmd.Flags().Duration("timeout", 5*time.Minute, "how long to wait for the objects to be ready")
+	cmd.Flags().String("audit-namespace", "", "namespace of the admin console to record the deploy in the audit log of, the deploy isn't recorded when not set")
+	addKubeconfigKeyFileFlag(cmd)
+
+	return cmd
+}
+
+// recordDeployAuditEvent records the deploy of a downstream in the audit log of the admin console in
+// the namespace, when there is one
+func recordDeployAuditEvent(namespace string, appDir string, name string, versionLabel string) {
+	if namespace == "" {
+		return
+	}
+
+	parameters := map[string]string{
+		"app":        filepath.Base(appDir),
+		"downstream": name,
+	}
+	if versionLabel != "" {
+		parameters["version"] = versionLabel
+	}
+	// the user of the current context, the downstream can be deployed with another kubeconfig
+	recordAuditEvent(namespace, audit.ActionDeploy, parameters)
+}
+
+// deployDownstream applies the downstream without kubectl apply, so that removed objects can be
+// pruned and the rollout can be waited for
+func deployDownstream(appDir string, name string, cipher *crypto.AESCipher, deployOptions deploy.DeployOptions) error {
+	overlaysDir := filepath.Join(appDir, "overlays")
+	downstreamDir, err := existingDownstreamDir(overlaysDir, name)
+	if err != nil {
+		return err
+	}
+	kubeconfig, err := downstream.GetKubeconfig(overlaysDir, name, cipher)
+	if err != nil {
+		return errors.Wrap(err, "failed to get kubeconfig")
+	}
+
+	result, err := deploy.Deploy(downstreamDir, kubeconfig, deployOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range result.Applied {
+		fmt.Printf("%s %s\n", r, r.Action)
+	}
+	for _, r := range result.Pruned {
+		fmt.Printf("%s %s\n", r, r.Action)
+	}
+	for _, r := range result.NotReady {
+		fmt.Printf("%s is not ready: %s\n", r, r.Message)
+	}
+
+	if !result.Ready() {
+		return errors.Errorf("%d objects of downstream %s are not ready", len(result.NotReady), name)
+	}
+	return nil
+}
+
+// existingDownstreamDir returns the directory of the downstream, or an error if it doesn't exist
+func existingDownstreamDir(overlaysDir string, name string) (string, error) {
+	downstreamDir := downstream.Dir(overlaysDir, name)
+	if _, err := os.Stat(filepath.Join(downstreamDir, "kustomization.yaml")); err != nil {
+		return "", errors.Errorf("downstream %s does not exist", name)
+	}
+	return downstreamDir, nil
+}
+
+func DownstreamDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "diff [app dir] [name]",
+		Short:         "Show the changes that deploying a downstream would make to its cluster",
+		Long:          "Build a downstream with kubectl kustomize and compare its objects to the live objects in its cluster with a server side dry run, like kubectl diff. Nothing in the cluster is changed.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 2 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			appDir := ExpandDir(args[0])
+			overlaysDir := filepath.Join(appDir, "overlays")
+			downstreamDir, err := existingDownstreamDir(overlaysDir, args[1])
+			if err != nil {
+				return err
+			}
+
+			cipher, err := kubeconfigCipher(v, false)
+			if err != nil {
+				return err
+			}
+			kubeconfig, err := downstream.GetKubeconfig(overlaysDir, args[1], cipher)
+			if err != nil {
+				return errors.Wrap(err, "failed to get kubeconfig")
+			}
+
+			diffOptions := diff.DiffOptions{
+				Kubectl: v.GetString("kubectl"),
+				Kinds:   v.GetStringSlice("kind"),
+			}
+			diffs, err := diff.Diff(downstreamDir, kubeconfig, diffOptions)
+			if err != nil {
+				return err
+			}
+
+			if v.GetString("output") == "json" {
+				b, err := json.MarshalIndent(diffs, "", "  ")
+				if err != nil {
+					return errors.Wrap(err, "failed to marshal diff")
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			for _, d := range diffs {
+				if d.Action == diff.ActionUnchanged {
+					continue
+				}
+				fmt.Printf("%s %s\n", d.Action, d)
+				for _, change := range d.Changes {
+					fmt.Printf("  %s: %s -> %s\n", change.Path, diffValue(change.Live), diffValue(change.Desired))
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("kubectl", "kubectl", "the kubectl executable")
+	cmd.Flags().StringSlice("kind", []string{}, "only diff objects of these kinds")
+	cmd.Flags().StringP("output", "o", "", "output format, json or empty for a summary")
+	addKubeconfigKeyFileFlag(cmd)
+
+	return cmd
+}
+
+func diffValue(value interface{}) string {
+	if value == nil {
+		return "<none>"
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
+
+// defaultKubeconfigKeyFile is where the key that the kubeconfigs of downstreams are encrypted with
+// is kept, outside of the app dir
+const defaultKubeconfigKeyFile = "~/.kots/kubeconfig.key"
+
+func addKubeconfigKeyFileFlag(cmd *cobra.Command) {
+	cmd.Flags().String("kubeconfig-key-file", defaultKubeconfigKeyFile, "the file with the key that the kubeconfigs of downstreams are encrypted with, it's created when a kubeconfig is first added and must not be committed with the app dir")
+}
+
+// kubeconfigCipher returns the cipher of the kubeconfig key file of the flags, see
+// downstream.KubeconfigCipher
+func kubeconfigCipher(v *viper.Viper, create bool) (*crypto.AESCipher, error) {
+	return downstream.KubeconfigCipher(ExpandDir(v.GetString("kubeconfig-key-file")), create)
+}
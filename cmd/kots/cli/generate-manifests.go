@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/release"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func GenerateManifestsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "manifests [app dir]",
+		Short:         "Package the manifests of an application directory as a release",
+		Long:          `Create a release archive from the upstream of an application directory created by kots pull, with the manifests in deploy order, the images they use, the lint results and the update cursor, ready to be promoted to a channel. The command fails when there are lint results, so that releases can be gated in CI.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			log := logger.NewLogger()
+
+			r, err := release.Generate(ExpandDir(args[0]), release.GenerateOptions{
+				OutputPath:   ExpandDir(v.GetString("output")),
+				UpdateCursor: v.GetString("cursor"),
+				VersionLabel: v.GetString("version-label"),
+			})
+			if err != nil {
+				return err
+			}
+
+			log.ActionWithoutSpinner("Release %s with %d manifests and %d images has been created at %s", r.Manifest.UpdateCursor, len(r.Manifest.Files), len(r.Manifest.Images), r.Path)
+			for _, lintResult := range r.Manifest.Lint {
+				log.ChildActionWithoutSpinner("%s:%d: %s", lintResult.Path, lintResult.Line, lintResult.Message)
+			}
+
+			if len(r.Manifest.Lint) > 0 && !v.GetBool("ignore-lint") {
+				return errors.Errorf("the manifests have %d lint results", len(r.Manifest.Lint))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("output", "release.tar.gz", "the file to write the release archive to")
+	cmd.Flags().String("cursor", "", "the update cursor of the release, instead of the one in the application directory")
+	cmd.Flags().String("version-label", "", "the version label of the release, instead of the one in the application directory")
+	cmd.Flags().Bool("ignore-lint", false, "don't fail when the manifests have lint results")
+
+	return cmd
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/archive"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func InspectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "inspect [archive or app dir]",
+		Short:         "Show the application, version and images in an application archive or directory",
+		Long:          `Summarize an application archive or a directory created by kots pull without a cluster, e.g. for sanity checks in CI.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			inspection, err := archive.Inspect(ExpandDir(args[0]))
+			if err != nil {
+				return err
+			}
+
+			return printInspection(inspection, v.GetString("output"))
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "", "output format, one of: table, json")
+
+	return cmd
+}
+
+func printInspection(inspection *archive.Inspection, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(inspection, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal inspection")
+		}
+		fmt.Println(string(b))
+		return nil
+	case "", "table":
+	default:
+		return errors.Errorf("unknown output format %q", format)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", inspection.Name)
+	fmt.Fprintf(w, "Title:\t%s\n", inspection.Title)
+	fmt.Fprintf(w, "Version:\t%s\n", inspection.VersionLabel)
+	fmt.Fprintf(w, "Cursor:\t%s\n", inspection.UpdateCursor)
+	fmt.Fprintf(w, "Kots kinds:\t%s\n", strings.Join(inspection.KotsKinds, ", "))
+	fmt.Fprintf(w, "Config items:\t%d\n", inspection.ConfigItemCount)
+	fmt.Fprintf(w, "Images:\t%d\n", len(inspection.Images))
+	for _, image := range inspection.Images {
+		fmt.Fprintf(w, "\t%s\n", image)
+	}
+	return w.Flush()
+}
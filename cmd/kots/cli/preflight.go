@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/preflight"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func PreflightCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "preflight [app dir]",
+		Short:         "Run the preflight checks of an application directory against a cluster",
+		Long:          `Run the preflight checks of an application directory created by kots pull, without the Admin Console. The command fails when a check fails, so that installs can be gated in automation.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			format := v.GetString("output")
+			if format != "" && format != "table" && format != "json" {
+				return errors.Errorf("unknown output format %q", format)
+			}
+
+			preflights, err := preflight.FindPreflights(ExpandDir(args[0]))
+			if err != nil {
+				return err
+			}
+			if len(preflights) == 0 {
+				return errors.Errorf("%s has no preflight checks", args[0])
+			}
+
+			log := logger.NewLogger()
+			if format == "json" {
+				log.Silence()
+			}
+
+			log.ActionWithSpinner("Running preflight checks")
+			results, err := preflight.Run(preflights, preflight.RunOptions{
+				Kubeconfig: v.GetString("kubeconfig"),
+			})
+			if err != nil {
+				log.FinishSpinnerWithError()
+				return err
+			}
+			log.FinishSpinner()
+
+			if err := printPreflightResults(results, format); err != nil {
+				return err
+			}
+
+			if results.IsFail() {
+				return errors.New("preflight checks failed")
+			}
+			if results.IsWarn() && v.GetBool("fail-on-warn") {
+				return errors.New("preflight checks have warnings")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("kubeconfig", defaultKubeConfig(), "the kubeconfig to use")
+	cmd.Flags().StringP("output", "o", "", "output format, one of: table, json")
+	cmd.Flags().Bool("fail-on-warn", false, "fail when a check has a warning")
+
+	return cmd
+}
+
+func printPreflightResults(results *preflight.Results, format string) error {
+	if format == "json" {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal preflight results")
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OUTCOME\tCHECK\tMESSAGE")
+	for _, result := range results.Results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Outcome, result.Title, result.Message)
+	}
+	for _, e := range results.Errors {
+		fmt.Fprintf(w, "error\t\t%s\n", e)
+	}
+	return w.Flush()
+}
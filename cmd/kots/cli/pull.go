@@ -1,13 +1,22 @@
 package cli
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/pull"
+	"github.com/replicatedhq/kots/pkg/template"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
 
 func PullCmd() *cobra.Command {
@@ -23,34 +32,109 @@ func PullCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			v := viper.GetViper()
 
-			if len(args) == 0 {
+			if len(args) == 0 && v.GetString("update") == "" {
 				cmd.Help()
 				os.Exit(1)
 			}
 
+			if err := base.ValidateDuplicateResources(v.GetString("duplicate-resources")); err != nil {
+				return err
+			}
+
 			// registry host should not have the scheme (https).  need to
 			// strip it if included or else the rewrite images will fail
 
+			registryOptions, err := registryOptionsFromFlags(v)
+			if err != nil {
+				return err
+			}
+
+			progressReporter, err := progressReporterFromFlags(v)
+			if err != nil {
+				return err
+			}
+
+			fileModes, err := fileModesFromFlags(v)
+			if err != nil {
+				return err
+			}
+
 			pullOptions := pull.PullOptions{
-				HelmRepoURI:         v.GetString("repo"),
-				RootDir:             ExpandDir(v.GetString("rootdir")),
-				Namespace:           v.GetString("namespace"),
-				Downstreams:         v.GetStringSlice("downstream"),
-				LocalPath:           ExpandDir(v.GetString("local-path")),
-				LicenseFile:         ExpandDir(v.GetString("license-file")),
-				ExcludeKotsKinds:    v.GetBool("exclude-kots-kinds"),
-				ExcludeAdminConsole: v.GetBool("exclude-admin-console"),
-				SharedPassword:      v.GetString("shared-password"),
-				CreateAppDir:        true,
-				HelmOptions:         v.GetStringSlice("set"),
-				RewriteImages:       v.GetBool("rewrite-images"),
+				HelmRepoURI:          v.GetString("repo"),
+				RootDir:              ExpandDir(v.GetString("rootdir")),
+				Namespace:            v.GetString("namespace"),
+				Downstreams:          v.GetStringSlice("downstream"),
+				LocalPath:            ExpandDir(v.GetString("local-path")),
+				LicenseFile:          ExpandDir(v.GetString("license-file")),
+				ConfigFile:           ExpandDir(v.GetString("config-values")),
+				ExcludeKotsKinds:     v.GetBool("exclude-kots-kinds"),
+				ExcludeAdminConsole:  v.GetBool("exclude-admin-console"),
+				SplitMultiDocYAML:    v.GetBool("split-multi-doc-yaml"),
+				SharedPassword:       v.GetString("shared-password"),
+				CreateAppDir:         true,
+				HelmOptions:          v.GetStringSlice("set"),
+				HelmValuesFiles:      helmValuesFilesFromFlags(v),
+				HelmRenderer:         v.GetString("helm-renderer"),
+				Helm:                 v.GetString("helm"),
+				KubeVersion:          v.GetString("kube-version"),
+				HelmAPIVersions:      v.GetStringSlice("helm-api-versions"),
+				AdditionalNamespaces: v.GetStringSlice("additional-namespaces"),
+				SupportArchive:       ExpandDir(v.GetString("support-archive")),
+				TemplateEnvPrefixes:  v.GetStringSlice("template-env-prefix"),
+				DuplicateResources:   v.GetString("duplicate-resources"),
+				ValidateBase:         v.GetBool("validate") || v.GetBool("validate-against-cluster"),
+				Transformers:         transformersFromFlags(v),
+				NamePrefix:           v.GetString("name-prefix"),
+				NameSuffix:           v.GetString("name-suffix"),
+				InstanceName:         v.GetString("instance-name"),
+				EncryptConfigValues:  v.GetBool("encrypt-config-values"),
+				FileModes:            fileModes,
+				ProgressReporter:     progressReporter,
+				RewriteImages:        v.GetBool("rewrite-images"),
 				RewriteImageOptions: pull.RewriteImageOptions{
-					Host:      v.GetString("registry-endpoint"),
-					Namespace: v.GetString("image-namespace"),
+					Host:      registryOptions.Endpoint,
+					Namespace: registryOptions.Namespace,
+					Username:  registryOptions.Username,
+					Password:  registryOptions.Password,
+					OCIOnly:   registryOptions.OCIOnly,
+					Platforms: v.GetStringSlice("image-platform"),
 				},
 			}
 
+			if v.GetBool("validate-against-cluster") {
+				cfg, err := config.GetConfig()
+				if err != nil {
+					return errors.Wrap(err, "failed to get cluster config")
+				}
+				discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+				if err != nil {
+					return errors.Wrap(err, "failed to create discovery client")
+				}
+				pullOptions.ValidationDiscovery = discoveryClient
+			}
+
+			if v.GetString("update") != "" {
+				upstream := ""
+				if len(args) > 0 {
+					upstream = pull.RewriteUpstream(args[0])
+				}
+				result, err := pull.PullUpdate(ExpandDir(v.GetString("update")), upstream, pullOptions)
+				if err != nil {
+					return err
+				}
+				return printUpdateResult(result, v.GetString("output"))
+			}
+
 			upstream := pull.RewriteUpstream(args[0])
+
+			if v.GetBool("template-usage") {
+				usage, err := pull.TemplateUsage(upstream, pullOptions)
+				if err != nil {
+					return err
+				}
+				return printTemplateUsage(usage, v.GetString("output"))
+			}
+
 			renderDir, err := pull.Pull(upstream, pullOptions)
 			if err != nil {
 				return err
@@ -59,6 +143,9 @@ func PullCmd() *cobra.Command {
 			log := logger.NewLogger()
 			log.Initialize()
 			log.Info("Kubernetes application files created in %s", renderDir)
+			if pullOptions.SupportArchive != "" {
+				log.Info("Support archive with sensitive values removed created at %s", pullOptions.SupportArchive)
+			}
 			if len(v.GetStringSlice("downstream")) == 0 {
 				log.Info("To deploy, run kubectl apply -k %s", path.Join(renderDir, "overlays", "midstream"))
 			} else if len(v.GetStringSlice("downstream")) == 1 {
@@ -72,18 +159,116 @@ func PullCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringSlice("set", []string{}, "values to pass to helm when running helm template")
+	cmd.Flags().StringSlice("values", []string{}, "values files to pass to helm when running helm template, merged in order before --set. the values are kept in upstream/userdata and used again when neither flag is set")
+	cmd.Flags().String("helm-renderer", "", "how helm charts are rendered, helm2 with the built in helm v2 libraries (the default), or helm3 with helm template of a helm v3 executable for charts that need helm v3 (not supported by the admin console, which renders with helm2). it's kept in the app directory and used again when not set")
+	cmd.Flags().String("helm", "helm", "the helm v3 executable for --helm-renderer=helm3")
+	cmd.Flags().String("kube-version", base.DefaultHelmKubeVersion, "the kubernetes version that helm charts are rendered for")
+	cmd.Flags().StringSlice("helm-api-versions", []string{}, "api versions that helm charts can check for in .Capabilities.APIVersions, for --helm-renderer=helm3")
 	cmd.Flags().String("repo", "", "repo uri to use when downloading a helm chart")
 	cmd.Flags().String("rootdir", homeDir(), "root directory that will be used to write the yaml to")
 	cmd.Flags().StringP("namespace", "n", "default", "namespace to render the upstream to in the base")
 	cmd.Flags().StringSlice("downstream", []string{}, "the list of any downstreams to create/update")
 	cmd.Flags().String("local-path", "", "specify a local-path to pull a locally available replicated app (only supported on replicated app types currently)")
 	cmd.Flags().String("license-file", "", "path to a license file to use when download a replicated app")
+	cmd.Flags().String("config-values", "", "path to a manifest with the config values of the app (apiVersion: kots.io/v1beta1, kind: ConfigValues), which are checked against the validation rules of the config items")
 	cmd.Flags().Bool("exclude-kots-kinds", true, "set to true to exclude rendering kots custom objects to the base directory")
 	cmd.Flags().Bool("exclude-admin-console", false, "set to true to exclude the admin console (replicated apps only)")
+	cmd.Flags().Bool("split-multi-doc-yaml", false, "set to true to write each object of a multi document upstream file to its own file in the base")
 	cmd.Flags().String("shared-password", "", "shared password to use when deploying the admin console")
+	cmd.Flags().StringSlice("additional-namespaces", []string{}, "namespaces, in addition to the ones found in the application, that need a copy of the image pull secret")
+	cmd.Flags().String("support-archive", "", "render password and file config values as placeholders and write a shareable archive of the application to this path")
+	cmd.Flags().StringSlice("template-env-prefix", []string{}, "prefixes of environment variables that can be read with the GetEnv template function (e.g. KOTS_APP_)")
+	cmd.Flags().String("duplicate-resources", base.DuplicateResourcesDedupe, "how to handle objects that are rendered more than once, e.g. by several charts: dedupe (keep one copy when they are identical and fail otherwise), error, or prefer-first")
+	cmd.Flags().String("name-prefix", "", "prefix to add to the names of all objects, so that the application can be installed more than once in a cluster")
+	cmd.Flags().String("name-suffix", "", "suffix to add to the names of all objects, so that the application can be installed more than once in a cluster")
+	cmd.Flags().String("instance-name", "", "name of the app instance, e.g. the app slug, that the pull secret and other objects that kots creates for the app are prefixed with, so that more than one app can be installed in a namespace")
+	cmd.Flags().StringSlice("transformer", []string{}, "command to run on the rendered objects before they are written, it reads them as a yaml stream on stdin and writes the objects to keep, change or add to stdout")
+	cmd.Flags().Bool("encrypt-config-values", false, "encrypt the config values in upstream/userdata with a key that is kept in a secret in the namespace, so that they can be committed to a repo (the current cluster is used)")
+	addFileModesFlags(cmd)
+	cmd.Flags().Bool("validate", false, "set to true to validate the rendered base manifests before they are written, unknown fields are reported as warnings")
+	cmd.Flags().Bool("validate-against-cluster", false, "set to true to also check that all kinds in the rendered base are available in the current cluster")
 	cmd.Flags().Bool("rewrite-images", false, "set to true to force all container images to be rewritten and pushed to a local registry")
 	cmd.Flags().String("image-namespace", "", "the namespace/org in the docker registry to push images to (required when --rewrite-images is set)")
 	cmd.Flags().String("registry-endpoint", "", "the endpoint of the local docker registry to use when pushing images (required when --rewrite-images is set)")
+	cmd.Flags().Bool("skip-registry-check", false, "set to true to push images without first checking that the registry credentials have push access")
+	cmd.Flags().Bool("registry-oci-only", false, "set to true to convert images to oci media types when pushing to a registry that does not accept docker manifests")
+	cmd.Flags().StringSlice("image-platform", []string{}, "platforms of multi-architecture images to copy to the registry (e.g. linux/arm64), all platforms are copied when not set")
+	cmd.Flags().Bool("template-usage", false, "set to true to report the template functions, config items and contexts that the app uses, instead of pulling it")
+	cmd.Flags().String("update", "", "path to an application directory created by kots pull, to update it to the releases after its update cursor and report the files that changed")
+	cmd.Flags().StringP("output", "o", "", "format of the template usage report or the update report, table (default) or json")
+	cmd.Flags().String("progress", "", "set to json to write the progress of image copies to stderr as json lines")
 
 	return cmd
 }
+
+// printTemplateUsage writes the usage report to stdout, with a warning for each deprecated function
+func printTemplateUsage(usage *template.Usage, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(usage, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal template usage")
+		}
+		fmt.Println(string(b))
+		return nil
+	case "", "table":
+	default:
+		return errors.Errorf("unknown output format %q", format)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FUNCTION\tCONTEXT\tUSES\tFILES")
+	for _, function := range usage.Functions {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", function.Name, function.Context, function.Count, strings.Join(function.Files, ","))
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "CONFIG ITEM\tUSES\tFILES")
+	for _, item := range usage.ConfigItems {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", item.Name, item.Count, strings.Join(item.Files, ","))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, function := range usage.DeprecatedFunctions() {
+		fmt.Printf("\nWarning: %s is deprecated, %s\n", function.Name, function.Deprecated)
+	}
+
+	return nil
+}
+
+// printUpdateResult writes the releases that an app directory was updated to, and the files that
+// changed, to stdout
+func printUpdateResult(result *pull.UpdateResult, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal update result")
+		}
+		fmt.Println(string(b))
+		return nil
+	case "", "table":
+	default:
+		return errors.Errorf("unknown output format %q", format)
+	}
+
+	if len(result.Updates) == 0 {
+		fmt.Printf("There are no updates after %s\n", result.FromCursor)
+		return nil
+	}
+	fmt.Printf("Updated from %s to %s (%s)\n\n", result.FromCursor, result.ToCursor, result.VersionLabel)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tFILE")
+	for _, file := range result.Changes.Added {
+		fmt.Fprintf(w, "added\t%s\n", file)
+	}
+	for _, file := range result.Changes.Modified {
+		fmt.Fprintf(w, "modified\t%s\n", file)
+	}
+	for _, file := range result.Changes.Removed {
+		fmt.Fprintf(w, "removed\t%s\n", file)
+	}
+	return w.Flush()
+}
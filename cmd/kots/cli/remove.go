@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func RemoveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "remove",
+		Short:         "Remove the admin console from a namespace",
+		Long:          `Delete the objects that the admin console was installed with. The namespace and the applications deployed by the admin console are kept.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			removeOptions := kotsadm.RemoveOptions{
+				Namespace:       v.GetString("namespace"),
+				PreserveVolumes: v.GetBool("preserve-volumes"),
+				DryRun:          v.GetBool("dry-run"),
+			}
+
+			log := logger.NewLogger()
+			if removeOptions.DryRun {
+				log.ActionWithoutSpinner("Objects that would be removed from %s", removeOptions.Namespace)
+			} else {
+				log.ActionWithoutSpinner("Removing the admin console from %s", removeOptions.Namespace)
+			}
+
+			removed, err := kotsadm.Remove(removeOptions)
+			if err != nil {
+				return errors.Wrap(err, "failed to remove admin console")
+			}
+
+			for _, obj := range removed {
+				log.ChildActionWithoutSpinner("%s", obj)
+			}
+			if len(removed) == 0 {
+				log.ChildActionWithoutSpinner("No admin console objects were found")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("namespace", "n", "default", "the namespace where the admin console is running")
+	cmd.Flags().Bool("preserve-volumes", false, "keep the persistent volume claims of the database, the object store and the postgres backups")
+	cmd.Flags().Bool("dry-run", false, "list the objects that would be removed without deleting them")
+
+	return cmd
+}
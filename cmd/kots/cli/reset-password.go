@@ -3,9 +3,10 @@ package cli
 import (
 	"os"
 
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/audit"
 	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/prompt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/bcrypt"
@@ -89,6 +90,8 @@ func ResetPasswordCmd() *cobra.Command {
 				}
 			}
 
+			recordAuditEvent(args[0], audit.ActionResetPassword, nil)
+
 			log.ActionWithoutSpinner("The admin console password has been reset")
 			return nil
 		},
@@ -100,17 +103,9 @@ func ResetPasswordCmd() *cobra.Command {
 }
 
 func promptForNewPassword() (string, error) {
-	templates := &promptui.PromptTemplates{
-		Prompt:  "{{ . | bold }} ",
-		Valid:   "{{ . | green }} ",
-		Invalid: "{{ . | red }} ",
-		Success: "{{ . | bold }} ",
-	}
-
-	prompt := promptui.Prompt{
-		Label:     "Enter a new password to be used for the Admin Console:",
-		Templates: templates,
-		Mask:      rune('•'),
+	return prompt.Terminal{}.Input(prompt.Input{
+		Label: "Enter a new password to be used for the Admin Console:",
+		Mask:  true,
 		Validate: func(input string) error {
 			if len(input) < 6 {
 				return errors.New("please enter a longer password")
@@ -118,17 +113,5 @@ func promptForNewPassword() (string, error) {
 
 			return nil
 		},
-	}
-
-	for {
-		result, err := prompt.Run()
-		if err != nil {
-			if err == promptui.ErrInterrupt {
-				os.Exit(-1)
-			}
-			continue
-		}
-
-		return result, nil
-	}
+	})
 }
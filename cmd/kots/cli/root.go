@@ -5,6 +5,10 @@ import (
 	"os"
 	"strings"
 
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/prompt"
+	"github.com/replicatedhq/kots/pkg/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -17,17 +21,50 @@ func RootCmd() *cobra.Command {
 		PreRun: func(cmd *cobra.Command, args []string) {
 			viper.BindPFlags(cmd.Flags())
 		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+			v.BindPFlags(cmd.Flags())
+
+			// loggers are created with the format from the environment
+			switch v.GetString("log-format") {
+			case "", "text":
+			case logger.LogFormatJSON:
+				os.Setenv(logger.LogFormatEnv, logger.LogFormatJSON)
+			default:
+				return errors.Errorf("unknown log format %q", v.GetString("log-format"))
+			}
+
+			proxyOptions, err := proxyOptionsFromFlags(v)
+			if err != nil {
+				return err
+			}
+			return util.ConfigureDefaultTransport(proxyOptions)
+		},
 	}
 
 	cobra.OnInitialize(initConfig)
 
+	cmd.PersistentFlags().String("http-proxy", "", "proxy to make http requests with, the HTTP_PROXY environment variable is used when not set")
+	cmd.PersistentFlags().String("https-proxy", "", "proxy to make https requests with, the HTTPS_PROXY environment variable is used when not set")
+	cmd.PersistentFlags().String("no-proxy", "", "comma separated hosts, domains and cidrs to connect to without the proxy, the NO_PROXY environment variable is used when not set")
+	cmd.PersistentFlags().String("log-format", "text", "format of the output, text or json (one event per line, for CI systems and wrappers)")
+	cmd.PersistentFlags().String("additional-ca-cert", "", "path to a PEM encoded bundle of CA certificates to trust in addition to the system roots (e.g. the CA of an intercepting proxy)")
+
 	cmd.AddCommand(PullCmd())
 	cmd.AddCommand(InstallCmd())
 	cmd.AddCommand(UploadCmd())
 	cmd.AddCommand(DownloadCmd())
 	cmd.AddCommand(UpstreamCmd())
+	cmd.AddCommand(DownstreamCmd())
+	cmd.AddCommand(InspectCmd())
+	cmd.AddCommand(VerifyCmd())
+	cmd.AddCommand(PreflightCmd())
+	cmd.AddCommand(SupportBundleCmd())
+	cmd.AddCommand(GenerateCmd())
 	cmd.AddCommand(AdminConsoleCmd())
 	cmd.AddCommand(ResetPasswordCmd())
+	cmd.AddCommand(RemoveCmd())
+	cmd.AddCommand(AuditCmd())
 	cmd.AddCommand(VersionCmd())
 
 	viper.BindPFlags(cmd.Flags())
@@ -38,7 +75,17 @@ func RootCmd() *cobra.Command {
 
 func InitAndExecute() {
 	if err := RootCmd().Execute(); err != nil {
-		fmt.Println(err)
+		if errors.Cause(err) == prompt.ErrCanceled {
+			os.Exit(-1)
+		}
+		if os.Getenv(logger.LogFormatEnv) == logger.LogFormatJSON {
+			logger.NewLogger().Error(err)
+		} else {
+			fmt.Println(err)
+			if remediation := util.Remediation(err); remediation != "" {
+				fmt.Println(remediation)
+			}
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/supportbundle"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func SupportBundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "support-bundle [app dir]",
+		Short:         "Collect a support bundle with the support bundle spec of an application directory",
+		Long:          `Collect a support bundle from the cluster with the collectors in the support bundle spec of an application directory created by kots pull. Secrets are redacted. The bundle is uploaded when the spec or --upload-to has an endpoint to send it to.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			collectors, err := supportbundle.FindCollectors(ExpandDir(args[0]))
+			if err != nil {
+				return err
+			}
+
+			log := logger.NewLogger()
+			log.Initialize()
+			if len(collectors) == 0 {
+				log.ActionWithoutSpinner("%s has no support bundle spec, only the cluster info and resources are collected", args[0])
+			}
+
+			log.ActionWithoutSpinner("Collecting support bundle")
+			bundle, err := supportbundle.Generate(collectors, supportbundle.GenerateOptions{
+				Kubeconfig:     v.GetString("kubeconfig"),
+				Namespace:      v.GetString("namespace"),
+				OutputPath:     ExpandDir(v.GetString("output")),
+				RedactPatterns: v.GetStringSlice("redact-pattern"),
+			})
+			if err != nil {
+				return err
+			}
+			for _, collectorError := range bundle.CollectorErrors {
+				log.ChildActionWithoutSpinner("Failed to collect %s", collectorError)
+			}
+			log.ActionWithoutSpinner("A support bundle has been created at %s", bundle.Path)
+
+			uploadOptions := supportbundle.UploadOptionsFromSpecs(collectors)
+			if v.GetString("upload-to") != "" {
+				uploadOptions = &supportbundle.UploadOptions{
+					URI:    v.GetString("upload-to"),
+					Method: v.GetString("upload-method"),
+				}
+			}
+			if uploadOptions == nil {
+				log.Finish()
+				return nil
+			}
+
+			log.ActionWithSpinner("Uploading support bundle")
+			if err := supportbundle.Upload(bundle.Path, *uploadOptions); err != nil {
+				log.FinishSpinnerWithError()
+				return err
+			}
+			log.FinishSpinner()
+			log.Finish()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("kubeconfig", defaultKubeConfig(), "the kubeconfig to use")
+	cmd.Flags().StringP("namespace", "n", "default", "the namespace where the admin console is running, its redaction key masks the secrets in the bundle")
+	cmd.Flags().String("output", "support-bundle.tar.gz", "the file to write the support bundle to")
+	cmd.Flags().String("upload-to", "", "url to upload the support bundle to, instead of the url in the support bundle spec")
+	cmd.Flags().StringSlice("redact-pattern", []string{}, "regular expression of more values to redact, only the \"value\" group is redacted when the expression has one")
+	cmd.Flags().String("upload-method", "PUT", "the http method to upload the support bundle with")
+
+	return cmd
+}
@@ -1,10 +1,21 @@
 package cli
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/audit"
+	"github.com/replicatedhq/kots/pkg/deploy"
+	"github.com/replicatedhq/kots/pkg/devloop"
 	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/replicatedhq/kots/pkg/upload"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -28,6 +39,11 @@ func UploadCmd() *cobra.Command {
 				os.Exit(1)
 			}
 
+			// the app has to exist so that every upload is a new version of it
+			if v.GetBool("watch") && v.GetString("slug") == "" {
+				return errors.New("--slug is required with --watch, upload the application once without --watch to create it")
+			}
+
 			log := logger.NewLogger()
 
 			sourceDir := homeDir()
@@ -35,39 +51,84 @@ func UploadCmd() *cobra.Command {
 				sourceDir = ExpandDir(args[0])
 			}
 
-			uploadOptions := upload.UploadOptions{
-				Namespace:       v.GetString("namespace"),
-				Kubeconfig:      v.GetString("kubeconfig"),
-				ExistingAppSlug: v.GetString("slug"),
-				NewAppName:      v.GetString("name"),
-				UpstreamURI:     v.GetString("upstream-uri"),
-				Endpoint:        "http://localhost:3000",
+			progressReporter, err := progressReporterFromFlags(v)
+			if err != nil {
+				return err
 			}
 
-			stopCh := make(chan struct{})
-			defer close(stopCh)
+			uploadOptions := upload.UploadOptions{
+				Namespace:           v.GetString("namespace"),
+				Kubeconfig:          v.GetString("kubeconfig"),
+				ExistingAppSlug:     v.GetString("slug"),
+				NewAppName:          v.GetString("name"),
+				UpstreamURI:         v.GetString("upstream-uri"),
+				LicenseChannel:      v.GetString("license-channel"),
+				UpgradeAdminConsole: v.GetBool("upgrade-admin-console"),
+				Endpoint:            "http://localhost:3000",
+				APIToken:            v.GetString("api-token"),
+				ProgressReporter:    progressReporter,
+				ArchiveOptions: upload.ArchiveOptions{
+					CompressionLevel: v.GetInt("compression-level"),
+					Excludes:         v.GetStringSlice("exclude"),
+					Includes:         v.GetStringSlice("include"),
+					Downstreams:      v.GetStringSlice("downstream"),
+				},
+			}
 
-			errChan, err := upload.StartPortForward(uploadOptions.Namespace, uploadOptions.Kubeconfig, stopCh)
-			if err != nil {
-				return errors.Wrap(err, "failed to port forward")
+			if contexts := v.GetStringSlice("context"); len(contexts) > 0 {
+				if v.GetBool("watch") {
+					return errors.New("--watch can't be used with --context")
+				}
+				return uploadToClusters(sourceDir, contexts, uploadOptions, v, log)
 			}
 
-			go func() {
-				select {
-				case err := <-errChan:
-					if err != nil {
-						log.Error(err)
-						os.Exit(-1)
+			// with an endpoint, e.g. in ci with an api token, the admin console is reached without
+			// the kubeconfig
+			if endpoint := v.GetString("endpoint"); endpoint != "" {
+				uploadOptions.Endpoint = strings.TrimSuffix(endpoint, "/")
+			} else {
+				if err := upload.CheckVersionSkew(context.Background(), uploadOptions); err != nil {
+					return errors.Cause(err)
+				}
+
+				stopCh := make(chan struct{})
+				defer close(stopCh)
+
+				errChan, err := upload.StartPortForward(uploadOptions.Namespace, uploadOptions.Kubeconfig, stopCh)
+				if err != nil {
+					return errors.Wrap(err, "failed to port forward")
+				}
+
+				go func() {
+					select {
+					case err := <-errChan:
+						if err != nil {
+							log.Error(err)
+							os.Exit(-1)
+						}
+					case <-stopCh:
 					}
-				case <-stopCh:
+				}()
+			}
+
+			uploadAndRecord := func() error {
+				if err := upload.Upload(sourceDir, uploadOptions); err != nil {
+					return err
 				}
-			}()
 
-			if err := upload.Upload(sourceDir, uploadOptions); err != nil {
-				return errors.Cause(err)
+				recordAuditEvent(uploadOptions.Namespace, audit.ActionUpload, map[string]string{
+					"slug":        uploadOptions.ExistingAppSlug,
+					"name":        uploadOptions.NewAppName,
+					"upstreamURI": uploadOptions.UpstreamURI,
+				})
+				return nil
+			}
+
+			if !v.GetBool("watch") {
+				return errors.Cause(uploadAndRecord())
 			}
 
-			return nil
+			return watchAndUpload(sourceDir, ExpandDir(v.GetString("local-path")), uploadAndRecord, log)
 		},
 	}
 
@@ -76,6 +137,130 @@ func UploadCmd() *cobra.Command {
 	cmd.Flags().String("slug", "", "the application slug to use. if not present, a new one will be created")
 	cmd.Flags().String("name", "", "the name of the kotsadm application to create")
 	cmd.Flags().String("upstream-uri", "", "the upstream uri that can be used to check for updates")
+	cmd.Flags().String("license-channel", "", "fail if the license of the application isn't for this channel")
+	cmd.Flags().Bool("upgrade-admin-console", false, "upgrade the admin console before uploading when it's too old for this version of kots")
+	cmd.Flags().String("progress", "", "set to json to write the progress of creating and uploading the archive to stderr as json lines")
+	cmd.Flags().StringSlice("exclude", []string{}, "pattern of files not to upload, in addition to the patterns in the .kotsignore file of the source")
+	cmd.Flags().StringSlice("include", []string{}, "pattern of files to upload even when they're excluded")
+	cmd.Flags().StringSlice("downstream", []string{}, "the downstreams to upload the rendered overlays of, all of them are uploaded when it's not set")
+	cmd.Flags().Int("compression-level", 0, "the gzip level of the archive, from 1 (fastest) to 9 (smallest)")
+	cmd.Flags().Bool("watch", false, "upload again every time the source changes, until interrupted")
+	cmd.Flags().StringSlice("context", []string{}, "upload to the admin console in the namespace of each of these kubeconfig contexts at the same time, instead of the current context")
+	cmd.Flags().Int("parallelism", upload.DefaultFanOutParallelism, "with --context, the number of clusters that are uploaded to at the same time")
+	cmd.Flags().String("deploy-downstream", "", "with --context, deploy the overlay of this downstream to each cluster after uploading to it")
+	cmd.Flags().Duration("deploy-timeout", 5*time.Minute, "with --deploy-downstream, how long to wait for the objects to be ready")
+	cmd.Flags().String("api-token", "", "the api token to authenticate the upload with, see kots admin-console token. it's read from KOTS_API_TOKEN when not set")
+	cmd.Flags().String("endpoint", "", "the url of the admin console to upload to, instead of port forwarding to it with the kubeconfig")
+	cmd.Flags().String("local-path", "", "with --watch, the release manifests the source was pulled from with kots pull --local-path. they're watched instead of the source, which is rendered again before it's uploaded")
 
 	return cmd
 }
+
+// uploadToClusters uploads the source dir to the admin console of each context, and prints the
+// status of each cluster
+func uploadToClusters(sourceDir string, contexts []string, uploadOptions upload.UploadOptions, v *viper.Viper, log *logger.Logger) error {
+	fanOutOptions := upload.FanOutOptions{
+		UploadOptions:    uploadOptions,
+		Parallelism:      v.GetInt("parallelism"),
+		DeployDownstream: v.GetString("deploy-downstream"),
+		DeployOptions: deploy.DeployOptions{
+			AppSlug: filepath.Base(sourceDir),
+			Kubectl: "kubectl",
+			Prune:   true,
+			Wait:    true,
+			Timeout: v.GetDuration("deploy-timeout"),
+		},
+		OnStatus: func(status upload.ClusterStatus) {
+			if status.Phase != upload.ClusterPhasePending {
+				log.Info("%s: %s", status.Target, status.Phase)
+			}
+		},
+	}
+	for _, kubeContext := range contexts {
+		fanOutOptions.Targets = append(fanOutOptions.Targets, upload.ClusterTarget{
+			Kubeconfig: uploadOptions.Kubeconfig,
+			Context:    kubeContext,
+			Namespace:  uploadOptions.Namespace,
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	go func() {
+		<-signalChan
+		cancel()
+	}()
+
+	result, err := upload.UploadToClusters(ctx, sourceDir, fanOutOptions)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tSTATUS\tERROR")
+	for _, cluster := range result.Clusters {
+		message := ""
+		if cluster.Err != nil {
+			message = cluster.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", cluster.Target, cluster.Phase, message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed := result.Failed(); len(failed) > 0 {
+		return errors.Errorf("the upload failed for %d of %d clusters", len(failed), len(result.Clusters))
+	}
+	return nil
+}
+
+// watchAndUpload uploads the source dir every time it changes. When there's a local path, it's
+// watched instead and the source dir is rendered from it before uploading.
+func watchAndUpload(sourceDir string, localPath string, uploadFn func() error, log *logger.Logger) error {
+	watchOptions := devloop.WatchOptions{
+		Dirs:   []string{sourceDir},
+		Upload: uploadFn,
+	}
+	if localPath != "" {
+		watchOptions.Dirs = []string{localPath}
+		watchOptions.Render = func() error {
+			_, err := pull.PullLocal(sourceDir, localPath, pull.PullOptions{
+				ExcludeAdminConsole: true,
+				Silent:              true,
+			})
+			return err
+		}
+	}
+
+	events := make(chan devloop.Event)
+	watchOptions.Events = events
+	go func() {
+		for event := range events {
+			switch event.Phase {
+			case devloop.PhaseRendering:
+				if len(event.Changed) > 0 {
+					log.ActionWithoutSpinner("%d files changed", len(event.Changed))
+				}
+			case devloop.PhaseFailed:
+				log.Error(event.Err)
+			case devloop.PhaseUploaded:
+				log.ActionWithoutSpinner("Uploaded, watching %s for changes", watchOptions.Dirs[0])
+			}
+		}
+	}()
+
+	stopCh := make(chan struct{})
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	go func() {
+		<-signalChan
+		close(stopCh)
+	}()
+
+	err := devloop.Watch(watchOptions, stopCh)
+	close(events)
+	return err
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
+	"github.com/replicatedhq/kots/pkg/updatecheck"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func UpstreamCheckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "check",
+		Short:         "List the releases of the upstream application that are newer than the current cursor, in json",
+		Long:          "",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if v.GetString("license-file") == "" {
+				return errors.New("--license-file is required")
+			}
+
+			b, err := ioutil.ReadFile(ExpandDir(v.GetString("license-file")))
+			if err != nil {
+				return errors.Wrap(err, "failed to read license file")
+			}
+			license, err := kotslicense.ParseLicense(b)
+			if err != nil {
+				return errors.Wrap(err, "failed to parse license")
+			}
+			license, err = kotslicense.VerifySignature(license)
+			if err != nil {
+				return errors.Wrap(err, "failed to verify license signature")
+			}
+
+			updates, err := updatecheck.CheckForUpdates(updatecheck.Options{
+				License:           license,
+				CurrentCursor:     v.GetString("current-cursor"),
+				Channel:           v.GetString("channel"),
+				VersionConstraint: v.GetString("version-constraint"),
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to check for updates")
+			}
+
+			output, err := json.MarshalIndent(updates, "", "  ")
+			if err != nil {
+				return errors.Wrap(err, "failed to marshal updates")
+			}
+			fmt.Println(string(output))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("license-file", "", "path to the license file of the application")
+	cmd.Flags().String("current-cursor", "", "the cursor of the installed release, only newer releases are listed")
+	cmd.Flags().String("channel", "", "the channel that the license must be for")
+	cmd.Flags().String("version-constraint", "", "a semver constraint that the versions of the releases must match, e.g. ~1.4")
+
+	return cmd
+}
@@ -6,8 +6,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/audit"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/spf13/cobra"
@@ -94,6 +96,11 @@ func UpstreamUpgradeCmd() *cobra.Command {
 				return errors.Wrap(err, "failed to parse response")
 			}
 
+			recordAuditEvent(v.GetString("namespace"), audit.ActionUpstreamUpgrade, map[string]string{
+				"slug":             appSlug,
+				"updatesAvailable": strconv.Itoa(ucr.UpdatesAvailable),
+			})
+
 			if ucr.UpdatesAvailable == 0 {
 				log.ActionWithoutSpinner("")
 				log.ActionWithoutSpinner("There are no application updates available")
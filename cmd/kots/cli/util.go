@@ -1,9 +1,20 @@
 package cli
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/midstream"
+	"github.com/replicatedhq/kots/pkg/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func ExpandDir(input string) string {
@@ -27,3 +38,230 @@ func defaultKubeConfig() string {
 	}
 	return filepath.Join(homeDir(), ".kube", "config")
 }
+
+func addBackupDestinationFlags(cmd *cobra.Command) {
+	cmd.Flags().String("backup-s3-endpoint", "", "endpoint of the S3 compatible storage that snapshots are written to (e.g. https://s3.amazonaws.com)")
+	cmd.Flags().String("backup-s3-region", "", "region of the snapshot bucket (default us-east-1)")
+	cmd.Flags().String("backup-s3-bucket", "", "bucket that snapshots are written to")
+	cmd.Flags().String("backup-s3-prefix", "", "path in the bucket that snapshots are written under")
+	cmd.Flags().String("backup-s3-access-key-id", "", "access key id to write snapshots with")
+	cmd.Flags().String("backup-s3-secret-access-key", "", "secret access key to write snapshots with")
+}
+
+// addExternalObjectStoreFlags adds the flags of a bucket to keep the archives in instead of
+// deploying minio, the keys can be read from a secret when deploying to a cluster
+func addExternalObjectStoreFlags(cmd *cobra.Command, withSecret bool) {
+	cmd.Flags().String("external-object-store-endpoint", "", "endpoint of an existing S3 compatible object store (e.g. https://s3.us-east-1.amazonaws.com) to keep the archives in instead of deploying minio")
+	cmd.Flags().String("external-object-store-region", "", "region of the external object store bucket")
+	cmd.Flags().String("external-object-store-bucket", "", "bucket of the external object store that the archives are kept in")
+	cmd.Flags().String("external-object-store-access-key-id", "", "access key id of the external object store")
+	cmd.Flags().String("external-object-store-secret-access-key", "", "secret access key of the external object store")
+	if withSecret {
+		cmd.Flags().String("external-object-store-secret", "", "name of an existing secret in the namespace with the accesskey and secretkey of the external object store")
+	}
+	cmd.Flags().Bool("external-object-store-path-style", false, "address the bucket in the path of the endpoint instead of in the host, which most S3 compatible object stores other than S3 need")
+}
+
+// externalObjectStoreFromFlags sets the external object store and its keys on the deploy options
+func externalObjectStoreFromFlags(v *viper.Viper, deployOptions *kotsadm.DeployOptions) {
+	deployOptions.ExternalObjectStore = kotsadm.ExternalObjectStoreOptions{
+		Endpoint:   v.GetString("external-object-store-endpoint"),
+		Region:     v.GetString("external-object-store-region"),
+		Bucket:     v.GetString("external-object-store-bucket"),
+		SecretName: v.GetString("external-object-store-secret"),
+		PathStyle:  v.GetBool("external-object-store-path-style"),
+	}
+	deployOptions.S3AccessKey = v.GetString("external-object-store-access-key-id")
+	deployOptions.S3SecretKey = v.GetString("external-object-store-secret-access-key")
+}
+
+func addIngressFlags(cmd *cobra.Command) {
+	cmd.Flags().String("ingress-hostname", "", "hostname to expose the admin console at with an ingress, instead of port-forwarding")
+	cmd.Flags().String("ingress-tls-secret", "", "name of an existing tls secret in the namespace with the certificate of the ingress hostname")
+	cmd.Flags().Bool("ingress-auto-cert", false, "sign a certificate for the ingress hostname with the --tls-ca-cert, or with a generated CA")
+	cmd.Flags().String("ingress-class", "", "ingress class of the controller that serves the ingress")
+	cmd.Flags().Bool("openshift-route", false, "expose the admin console at the ingress hostname with an openshift route instead of an ingress")
+}
+
+func ingressOptionsFromFlags(v *viper.Viper) kotsadm.IngressOptions {
+	return kotsadm.IngressOptions{
+		Hostname:       v.GetString("ingress-hostname"),
+		TLSSecretName:  v.GetString("ingress-tls-secret"),
+		AutoCert:       v.GetBool("ingress-auto-cert"),
+		IngressClass:   v.GetString("ingress-class"),
+		OpenShiftRoute: v.GetBool("openshift-route"),
+	}
+}
+
+func addIdentityFlags(cmd *cobra.Command) {
+	cmd.Flags().String("identity-issuer-url", "", "issuer url of an OIDC provider to log in to the admin console with, instead of the shared password")
+	cmd.Flags().String("identity-client-secret", "", "name of an existing secret in the namespace with the client-id and client-secret that the admin console is registered with at the OIDC provider")
+	cmd.Flags().StringSlice("identity-group-role", []string{}, "role of the users in a group of the OIDC provider, as group=role where role is admin or read-only")
+	cmd.Flags().String("identity-service-address", "", "url that the identity service (dex) deployed with the admin console is exposed at")
+	cmd.Flags().String("admin-console-address", "", "url that the admin console is exposed at, that the OIDC login redirects back to (default the ingress address, or http://<hostname>)")
+}
+
+func identityOptionsFromFlags(v *viper.Viper) (kotsadm.IdentityOptions, error) {
+	groupRoles, err := kotsadm.ParseGroupRoles(v.GetStringSlice("identity-group-role"))
+	if err != nil {
+		return kotsadm.IdentityOptions{}, errors.Wrap(err, "failed to parse group roles")
+	}
+
+	identityOptions := kotsadm.IdentityOptions{
+		IssuerURL:              v.GetString("identity-issuer-url"),
+		ClientSecretName:       v.GetString("identity-client-secret"),
+		GroupRoles:             groupRoles,
+		IdentityServiceAddress: v.GetString("identity-service-address"),
+		AdminConsoleAddress:    v.GetString("admin-console-address"),
+	}
+
+	return identityOptions, nil
+}
+
+func backupDestinationFromFlags(v *viper.Viper) kotsadm.BackupDestination {
+	return kotsadm.BackupDestination{
+		Endpoint:        v.GetString("backup-s3-endpoint"),
+		Region:          v.GetString("backup-s3-region"),
+		Bucket:          v.GetString("backup-s3-bucket"),
+		Prefix:          v.GetString("backup-s3-prefix"),
+		AccessKeyID:     v.GetString("backup-s3-access-key-id"),
+		SecretAccessKey: v.GetString("backup-s3-secret-access-key"),
+	}
+}
+
+func addFileModesFlags(cmd *cobra.Command) {
+	cmd.Flags().String("dir-mode", "", "octal mode of the directories that the base and midstream are written to, e.g. 0750, regardless of the umask (default 0755 with the umask applied)")
+	cmd.Flags().String("file-mode", "", "octal mode of the files of the base and midstream, e.g. 0640, regardless of the umask (default 0644 with the umask applied)")
+	cmd.Flags().Bool("atomic-writes", false, "write each file of the base and midstream to a temp file that is synced and renamed, so that an interrupted pull never leaves a partially written file")
+}
+
+func fileModesFromFlags(v *viper.Viper) (util.FileModes, error) {
+	dirMode, err := parseFileMode(v.GetString("dir-mode"))
+	if err != nil {
+		return util.FileModes{}, errors.Wrap(err, "invalid --dir-mode")
+	}
+	fileMode, err := parseFileMode(v.GetString("file-mode"))
+	if err != nil {
+		return util.FileModes{}, errors.Wrap(err, "invalid --file-mode")
+	}
+
+	return util.FileModes{
+		DirMode:  dirMode,
+		FileMode: fileMode,
+		Atomic:   v.GetBool("atomic-writes"),
+	}, nil
+}
+
+// parseFileMode parses an octal permission mode, e.g. 0640, an empty mode is 0
+func parseFileMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, errors.Errorf("%q is not an octal mode", mode)
+	}
+	if m == 0 || m > 0777 {
+		return 0, errors.Errorf("%q is not a permission mode between 0001 and 0777", mode)
+	}
+	return os.FileMode(m), nil
+}
+
+// helmValuesFilesFromFlags returns the expanded paths of the --values flags
+func helmValuesFilesFromFlags(v *viper.Viper) []string {
+	filenames := []string{}
+	for _, filename := range v.GetStringSlice("values") {
+		filenames = append(filenames, ExpandDir(filename))
+	}
+	return filenames
+}
+
+func loadPatches(v *viper.Viper) ([]kotsadm.ObjectPatch, error) {
+	filenames := []string{}
+	for _, filename := range v.GetStringSlice("patch") {
+		filenames = append(filenames, ExpandDir(filename))
+	}
+	patches, err := kotsadm.LoadStrategicMergePatches(filenames)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load strategic merge patches")
+	}
+
+	json6902Patches, err := kotsadm.LoadJSON6902Patches(v.GetStringSlice("patch-json6902"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load json patches")
+	}
+
+	return append(patches, json6902Patches...), nil
+}
+
+func transformersFromFlags(v *viper.Viper) []midstream.Transformer {
+	transformers := []midstream.Transformer{}
+	for _, command := range v.GetStringSlice("transformer") {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			continue
+		}
+		transformers = append(transformers, midstream.ExecTransformer{
+			Command: ExpandDir(fields[0]),
+			Args:    fields[1:],
+		})
+	}
+	return transformers
+}
+
+func proxyOptionsFromFlags(v *viper.Viper) (util.ProxyOptions, error) {
+	proxyOptions := util.ProxyOptions{
+		HTTPProxy:  v.GetString("http-proxy"),
+		HTTPSProxy: v.GetString("https-proxy"),
+		NoProxy:    v.GetString("no-proxy"),
+	}
+
+	if filename := v.GetString("additional-ca-cert"); filename != "" {
+		caCert, err := ioutil.ReadFile(ExpandDir(filename))
+		if err != nil {
+			return proxyOptions, errors.Wrap(err, "failed to read additional ca cert")
+		}
+		proxyOptions.AdditionalCACert = caCert
+	}
+
+	return proxyOptions, nil
+}
+
+// registryOptionsFromFlags returns the registry that images are pushed to, with the credentials
+// from docker login, after checking that they can push to it
+func registryOptionsFromFlags(v *viper.Viper) (registry.RegistryOptions, error) {
+	registryOptions := registry.RegistryOptions{
+		Endpoint:  v.GetString("registry-endpoint"),
+		Namespace: v.GetString("image-namespace"),
+		OCIOnly:   v.GetBool("registry-oci-only"),
+	}
+	if registryOptions.Endpoint == "" {
+		return registryOptions, nil
+	}
+
+	registryOptions, err := registryOptions.WithDockerCredentials()
+	if err != nil {
+		return registryOptions, errors.Wrap(err, "failed to load registry credentials")
+	}
+
+	if !v.GetBool("skip-registry-check") {
+		if err := registryOptions.Validate(); err != nil {
+			return registryOptions, errors.Wrapf(err, "failed to validate registry %s", registryOptions.Endpoint)
+		}
+	}
+
+	return registryOptions, nil
+}
+
+// progressReporterFromFlags returns a reporter that writes the progress of long running steps
+// to stderr as json lines when --progress is json, for wrappers that show it in a ui
+func progressReporterFromFlags(v *viper.Viper) (logger.ProgressReporter, error) {
+	switch v.GetString("progress") {
+	case "":
+		return nil, nil
+	case "json":
+		return logger.NewJSONProgressReporter(os.Stderr), nil
+	default:
+		return nil, errors.Errorf("unknown progress format %q", v.GetString("progress"))
+	}
+}
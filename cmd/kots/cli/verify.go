@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/rendermanifest"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func VerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "verify [app dir]",
+		Short:         "Verify that the rendered files in an app directory haven't been changed",
+		Long:          `Compare the files in a directory created by kots pull to the checksums that were recorded when it was rendered, e.g. to check in a GitOps pipeline that the rendered output wasn't edited by hand. The command fails when files were changed.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			appDir := ExpandDir(args[0])
+			changes, err := rendermanifest.VerifyAppDir(appDir)
+			if err != nil {
+				return err
+			}
+
+			if err := printVerifyChanges(changes, v.GetString("output")); err != nil {
+				return err
+			}
+
+			if changes.HasChanges() {
+				return errors.Errorf("%s has been changed since it was rendered", appDir)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "", "output format, one of: table, json")
+
+	return cmd
+}
+
+func printVerifyChanges(changes *rendermanifest.Changes, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal changes")
+		}
+		fmt.Println(string(b))
+		return nil
+	case "", "table":
+	default:
+		return errors.Errorf("unknown output format %q", format)
+	}
+
+	if !changes.HasChanges() {
+		fmt.Println("No files have been changed since they were rendered")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tFILE")
+	for _, file := range changes.Added {
+		fmt.Fprintf(w, "added\t%s\n", file)
+	}
+	for _, file := range changes.Modified {
+		fmt.Fprintf(w, "modified\t%s\n", file)
+	}
+	for _, file := range changes.Removed {
+		fmt.Fprintf(w, "removed\t%s\n", file)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,33 @@
+#!/bin/bash
+
+# Prints the digests of the admin console images for a release of kots, as name=digest pairs
+# to build kots with (see KOTSADM_IMAGE_DIGESTS in the Makefile).
+# The third party tags must match the ones in pkg/kotsadm.
+
+set -euo pipefail
+
+TAG=$1
+
+IMAGES=(
+  "kotsadm-api=kotsadm/kotsadm-api:${TAG}"
+  "kotsadm-web=kotsadm/kotsadm-web:${TAG}"
+  "kotsadm-operator=kotsadm/kotsadm-operator:${TAG}"
+  "kotsadm-migrations=kotsadm/kotsadm-migrations:${TAG}"
+  "minio=kotsadm/minio:${TAG}"
+  "postgres=postgres:12.2"
+  "minio/mc=minio/mc:RELEASE.2020-04-25T00-43-23Z"
+  "amazon/aws-cli=amazon/aws-cli:2.0.10"
+  "google/cloud-sdk=google/cloud-sdk:290.0.1-alpine"
+  "mcr.microsoft.com/azure-cli=mcr.microsoft.com/azure-cli:2.5.1"
+)
+
+DIGESTS=()
+for entry in "${IMAGES[@]}"; do
+  name=${entry%%=*}
+  image=${entry#*=}
+  docker pull -q "${image}" > /dev/null
+  digest=$(docker inspect --format '{{index .RepoDigests 0}}' "${image}")
+  DIGESTS+=("${name}=${digest#*@}")
+done
+
+(IFS=,; echo "${DIGESTS[*]}")
@@ -3,6 +3,7 @@ package main
 import "C"
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/replicatedhq/kots/pkg/config"
@@ -18,3 +19,34 @@ func TemplateConfig(configSpecData string, configValuesData string) *C.char {
 	}
 	return C.CString(rendered)
 }
+
+//export TemplateConfigForValidation
+func TemplateConfigForValidation(configSpecData string, configValuesData string) *C.char {
+	options := config.TemplateConfigOptions{
+		EnableDNSLookups: true,
+	}
+	rendered, err := config.TemplateConfigWithOptions(logger.NewLogger(), configSpecData, configValuesData, options)
+	if err != nil {
+		fmt.Printf("failed to apply templates to config for validation: %s\n", err.Error())
+		return C.CString("")
+	}
+	return C.CString(rendered)
+}
+
+// ValidateConfig returns the items whose values don't pass their validation rules, as a json array
+//
+//export ValidateConfig
+func ValidateConfig(configSpecData string, configValuesData string) *C.char {
+	itemErrors, err := config.ValidateConfig(logger.NewLogger(), configSpecData, configValuesData, config.TemplateConfigOptions{})
+	if err != nil {
+		fmt.Printf("failed to validate config: %s\n", err.Error())
+		return C.CString("")
+	}
+
+	b, err := json.Marshal(itemErrors)
+	if err != nil {
+		fmt.Printf("failed to marshal config errors: %s\n", err.Error())
+		return C.CString("")
+	}
+	return C.CString(string(b))
+}
@@ -0,0 +1,176 @@
+package main
+
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mholt/archiver"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/rewrite"
+	"github.com/replicatedhq/kots/pkg/upstream"
+)
+
+// SyncLicense diffs the entitlements of the license in the archive with the latest license.
+// When a changed entitlement is used in a template, the app is rendered again with the latest
+// license and written to outputFile as a new version.
+//
+//export SyncLicense
+func SyncLicense(socket, fromArchivePath, licenseData, outputFile, downstreamsStr, k8sNamespace, registryJson string) {
+	go func() {
+		var ffiResult *FFIResult
+
+		statusClient, err := connectToStatusServer(socket)
+		if err != nil {
+			fmt.Printf("failed to connect to status server: %s\n", err)
+			return
+		}
+		defer func() {
+			statusClient.end(ffiResult)
+		}()
+
+		registryInfo := struct {
+			Host      string `json:"registryHostname"`
+			Username  string `json:"registryUsername"`
+			Password  string `json:"registryPassword"`
+			Namespace string `json:"namespace"`
+			OCIOnly   bool   `json:"ociOnly"`
+		}{}
+		if err := json.Unmarshal([]byte(registryJson), &registryInfo); err != nil {
+			fmt.Printf("failed to unmarshal registry info: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		downstreams := []string{}
+		if err := json.Unmarshal([]byte(downstreamsStr), &downstreams); err != nil {
+			fmt.Printf("failed to decode downstreams: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		latestLicense, err := loadLicense(licenseData)
+		if err != nil {
+			fmt.Printf("failed to load latest license: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		tmpRoot, err := ioutil.TempDir("", "kots")
+		if err != nil {
+			fmt.Printf("failed to create temp root path: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+		defer os.RemoveAll(tmpRoot)
+
+		tarGz := archiver.TarGz{
+			Tar: &archiver.Tar{
+				ImplicitTopLevelFolder: false,
+			},
+		}
+		if err := tarGz.Unarchive(fromArchivePath, tmpRoot); err != nil {
+			fmt.Printf("failed to unarchive: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		upstreamDir := filepath.Join(tmpRoot, "upstream")
+		installedLicense, err := loadLicenseFromPath(filepath.Join(upstreamDir, "userdata", "license.yaml"))
+		if err != nil {
+			fmt.Printf("failed to load installed license: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		if installedLicense.Spec.LicenseID != latestLicense.Spec.LicenseID {
+			err := errors.New("license ids do not match")
+			fmt.Printf("failed to sync license: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		changes := upstream.DiffEntitlements(installedLicense, latestLicense)
+		templated, err := upstream.FindTemplatedEntitlements(upstreamDir)
+		if err != nil {
+			fmt.Printf("failed to find templated entitlements: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+		requiresRender := upstream.MarkTemplatedEntitlements(changes, templated)
+
+		result := struct {
+			Changes  []upstream.EntitlementChange `json:"changes"`
+			Rendered bool                         `json:"rendered"`
+		}{
+			Changes:  changes,
+			Rendered: requiresRender,
+		}
+
+		if requiresRender {
+			installation, err := loadInstallationFromPath(filepath.Join(upstreamDir, "userdata", "installation.yaml"))
+			if err != nil {
+				fmt.Printf("failed to read installation: %s\n", err.Error())
+				ffiResult = NewFFIResult(-1).WithError(err)
+				return
+			}
+
+			configValues, err := parseConfigValuesFromFile(filepath.Join(upstreamDir, "userdata", "config.yaml"))
+			if err != nil {
+				fmt.Printf("failed to decode config values: %s\n", err.Error())
+				ffiResult = NewFFIResult(-1).WithError(err)
+				return
+			}
+
+			options := rewrite.RewriteOptions{
+				RootDir:           tmpRoot,
+				UpstreamURI:       fmt.Sprintf("replicated://%s", latestLicense.Spec.AppSlug),
+				UpstreamPath:      upstreamDir,
+				Installation:      installation,
+				Downstreams:       downstreams,
+				Silent:            true,
+				CreateAppDir:      false,
+				ExcludeKotsKinds:  true,
+				License:           latestLicense,
+				ConfigValues:      configValues,
+				K8sNamespace:      k8sNamespace,
+				ReportWriter:      statusClient.getOutputWriter(),
+				RegistryEndpoint:  registryInfo.Host,
+				RegistryUsername:  registryInfo.Username,
+				RegistryPassword:  registryInfo.Password,
+				RegistryNamespace: registryInfo.Namespace,
+				RegistryOCIOnly:   registryInfo.OCIOnly,
+			}
+
+			if err := rewrite.Rewrite(options); err != nil {
+				fmt.Printf("failed to render with latest license: %s\n", err.Error())
+				ffiResult = NewFFIResult(1).WithError(err)
+				return
+			}
+
+			paths := []string{
+				filepath.Join(tmpRoot, "upstream"),
+				filepath.Join(tmpRoot, "base"),
+				filepath.Join(tmpRoot, "overlays"),
+			}
+			if err := tarGz.Archive(paths, outputFile); err != nil {
+				fmt.Printf("failed to write archive: %s", err.Error())
+				ffiResult = NewFFIResult(1).WithError(err)
+				return
+			}
+		}
+
+		b, err := json.Marshal(result)
+		if err != nil {
+			fmt.Printf("failed to marshal result: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		ffiResult = NewFFIResult(0).WithData(string(b))
+	}()
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
 	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/replicatedhq/kots/pkg/upstream"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -196,7 +197,7 @@ func VerifyAirgapLicense(licenseData string) *C.char {
 	}
 	license := obj.(*kotsv1beta1.License)
 
-	verifiedLicense, err := pull.VerifySignature(license)
+	verifiedLicense, err := kotslicense.VerifySignature(license)
 	if err != nil {
 		fmt.Printf("failed to verify airgap license signature: %s\n", err.Error())
 		return nil
@@ -112,6 +112,7 @@ func RewriteVersion(socket, fromArchivePath, outputFile, downstreamsStr, k8sName
 			Username  string `json:"registryUsername"`
 			Password  string `json:"registryPassword"`
 			Namespace string `json:"namespace"`
+			OCIOnly   bool   `json:"ociOnly"`
 		}{}
 		if err := json.Unmarshal([]byte(registryJson), &registryInfo); err != nil {
 			fmt.Printf("failed to unmarshal registry info: %s\n", err.Error())
@@ -204,6 +205,7 @@ func RewriteVersion(socket, fromArchivePath, outputFile, downstreamsStr, k8sName
 			RegistryUsername:  registryInfo.Username,
 			RegistryPassword:  registryInfo.Password,
 			RegistryNamespace: registryInfo.Namespace,
+			RegistryOCIOnly:   registryInfo.OCIOnly,
 		}
 
 		if err := rewrite.Rewrite(options); err != nil {
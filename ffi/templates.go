@@ -94,12 +94,17 @@ func RenderFile(socket string, filePath string, archivePath string) {
 			kotsconfig.ApplyValuesToConfig(config, configCtx.ItemValues)
 		}
 
-		if license != nil {
-			licenseCtx := template.LicenseCtx{
-				License: license,
-			}
-			builder.AddCtx(licenseCtx)
+		// without a license, the license functions render empty values
+		licenseCtx := template.LicenseCtx{
+			License: license,
+		}
+		builder.AddCtx(licenseCtx)
+
+		appCtx := template.AppCtx{
+			Installation: installation,
+			License:      license,
 		}
+		builder.AddCtx(appCtx)
 
 		inputContent, err := ioutil.ReadFile(filePath)
 		if err != nil {
@@ -31,6 +31,7 @@ func UpdateDownload(socket, fromArchivePath, namespace, registryJson, cursor str
 			Username  string `json:"registryUsername"`
 			Password  string `json:"registryPassword"`
 			Namespace string `json:"namespace"`
+			OCIOnly   bool   `json:"ociOnly"`
 		}{}
 		if err := json.Unmarshal([]byte(registryJson), &registryInfo); err != nil {
 			fmt.Printf("failed to unmarshal registry info: %s\n", err.Error())
@@ -88,6 +89,7 @@ func UpdateDownload(socket, fromArchivePath, namespace, registryJson, cursor str
 				Namespace: registryInfo.Namespace,
 				Username:  registryInfo.Username,
 				Password:  registryInfo.Password,
+				OCIOnly:   registryInfo.OCIOnly,
 			}
 		}
 
@@ -44,14 +44,33 @@ type ApplicationList struct {
 
 // ApplicationSpec defines the desired state of ApplicationSpec
 type ApplicationSpec struct {
-	Title            string            `json:"title"`
-	Icon             string            `json:"icon,omitempty"`
-	ApplicationPorts []ApplicationPort `json:"ports,omitempty"`
-	ReleaseNotes     string            `json:"releaseNotes,omitempty"`
-	AllowRollback    bool              `json:"allowRollback,omitempty"`
-	StatusInformers  []string          `json:"statusInformers,omitempty"`
-	Graphs           []MetricGraph     `json:"graphs,omitempty"`
-	KubectlVersion   string            `json:"kubectlVersion,omitempty"`
+	Title             string            `json:"title"`
+	Icon              string            `json:"icon,omitempty"`
+	ApplicationPorts  []ApplicationPort `json:"ports,omitempty"`
+	ReleaseNotes      string            `json:"releaseNotes,omitempty"`
+	AllowRollback     bool              `json:"allowRollback,omitempty"`
+	StatusInformers   []string          `json:"statusInformers,omitempty"`
+	Graphs            []MetricGraph     `json:"graphs,omitempty"`
+	KubectlVersion    string            `json:"kubectlVersion,omitempty"`
+	MinKotsVersion    string            `json:"minKotsVersion,omitempty"`
+	TargetKotsVersion string            `json:"targetKotsVersion,omitempty"`
+	BaseRules         []BaseRule        `json:"baseRules,omitempty"`
+}
+
+// BaseRule includes or excludes the objects of the release that it matches in the base, e.g. to leave
+// out an optional component. The last rule that matches an object decides, objects that no rule
+// matches are included. A rule matches the objects that all of its fields match.
+type BaseRule struct {
+	// Exclude leaves the matching objects out of the base, they are included when it's false
+	Exclude bool `json:"exclude,omitempty"`
+	// Paths are globs of the paths of the files in the release, e.g. "monitoring/**" or "prometheus-*.yaml"
+	Paths []string `json:"paths,omitempty"`
+	// Selector is a label selector of the objects, e.g. "app.kubernetes.io/part-of=prometheus"
+	Selector string `json:"selector,omitempty"`
+	// Namespaces are globs of the namespaces of the objects, e.g. "monitoring-*", they can be templates
+	Namespaces []string `json:"namespaces,omitempty"`
+	// When applies the rule when it renders to true, e.g. '{{repl ConfigOptionEquals "monitoring" "0" }}'
+	When string `json:"when,omitempty"`
 }
 
 type ApplicationPort struct {
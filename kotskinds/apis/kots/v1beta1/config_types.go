@@ -29,29 +29,45 @@ type ConfigChildItem struct {
 }
 
 type ConfigItem struct {
-	Name        string            `json:"name"`
-	Type        string            `json:"type"`
-	Title       string            `json:"title,omitempty"`
-	HelpText    string            `json:"help_text,omitempty"`
-	Recommended bool              `json:"recommended,omitempty"`
-	Default     string            `json:"default,omitempty"`
-	Value       string            `json:"value,omitempty"`
-	MultiValue  []string          `json:"multi_value,omitempty"`
-	ReadOnly    bool              `json:"readonly,omitempty"`
-	WriteOnce   bool              `json:"write_once,omitempty"`
-	When        string            `json:"when,omitempty"`
-	Multiple    bool              `json:"multiple,omitempty"`
-	Hidden      bool              `json:"hidden,omitempty"`
-	Position    int               `json:"-"`
-	Affix       string            `json:"affix,omitempty"`
-	Required    bool              `json:"required,omitempty"`
-	Items       []ConfigChildItem `json:"items,omitempty"`
+	Name        string                `json:"name"`
+	Type        string                `json:"type"`
+	Title       string                `json:"title,omitempty"`
+	HelpText    string                `json:"help_text,omitempty"`
+	Recommended bool                  `json:"recommended,omitempty"`
+	Default     string                `json:"default,omitempty"`
+	Value       string                `json:"value,omitempty"`
+	MultiValue  []string              `json:"multi_value,omitempty"`
+	ReadOnly    bool                  `json:"readonly,omitempty"`
+	WriteOnce   bool                  `json:"write_once,omitempty"`
+	When        string                `json:"when,omitempty"`
+	Multiple    bool                  `json:"multiple,omitempty"`
+	Hidden      bool                  `json:"hidden,omitempty"`
+	Position    int                   `json:"-"`
+	Affix       string                `json:"affix,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Items       []ConfigChildItem     `json:"items,omitempty"`
+	Validation  *ConfigItemValidation `json:"validation,omitempty"`
 	// Props       map[string]interface{} `json:"props,omitempty"`
 	// DefaultCmd  *ConfigItemCmd         `json:"default_cmd,omitempty"`
 	// ValueCmd    *ConfigItemCmd         `json:"value_cmd,omitempty"`
 	// DataCmd     *ConfigItemCmd         `json:"data_cmd,omitempty"`
 }
 
+// ConfigItemValidation are the built in rules that the value of an item is checked against
+type ConfigItemValidation struct {
+	MinLength int `json:"min_length,omitempty"`
+
+	// ComplexityClasses is the number of kinds of characters (lowercase letters, uppercase letters,
+	// digits and symbols) that the value must contain
+	ComplexityClasses int `json:"complexity_classes,omitempty"`
+
+	// Matches is the name of another item that the value must be equal to, e.g. to confirm a password
+	Matches string `json:"matches,omitempty"`
+
+	// DisallowDefault rejects the default value, e.g. for a password that has to be changed
+	DisallowDefault bool `json:"disallow_default,omitempty"`
+}
+
 type ConfigGroup struct {
 	Name        string       `json:"name"`
 	Title       string       `json:"title"`
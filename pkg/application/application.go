@@ -0,0 +1,123 @@
+package application
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var (
+	ErrNotApplication = errors.New("not a kots application")
+)
+
+func init() {
+	kotsscheme.AddToScheme(scheme.Scheme)
+}
+
+// ParseApplication decodes an Application kots kind, the vendor branding and settings of an app
+func ParseApplication(data []byte) (*kotsv1beta1.Application, error) {
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	obj, gvk, err := decode(data, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode application")
+	}
+
+	if gvk.Group != "kots.io" || gvk.Version != "v1beta1" || gvk.Kind != "Application" {
+		return nil, ErrNotApplication
+	}
+
+	return obj.(*kotsv1beta1.Application), nil
+}
+
+// FindApplication returns the content of the Application kots kind in the yaml files of dir, or
+// nil when the app doesn't have one
+func FindApplication(dir string) ([]byte, error) {
+	var found []byte
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if found != nil || info.IsDir() {
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		// decoding every manifest of an app is slow, only the kots kinds are needed
+		if !bytes.Contains(content, []byte("kots.io")) {
+			return nil
+		}
+		if _, err := ParseApplication(content); err == nil {
+			found = content
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk app files")
+	}
+
+	return found, nil
+}
+
+// CheckMinKotsVersion returns an error when kotsVersion is older than the minKotsVersion of the
+// application. Builds of kots without a release version (e.g. "alpha") can install any app.
+func CheckMinKotsVersion(app *kotsv1beta1.Application, kotsVersion string) error {
+	if app.Spec.MinKotsVersion == "" {
+		return nil
+	}
+
+	minSemver, err := semver.NewVersion(app.Spec.MinKotsVersion)
+	if err != nil {
+		return errors.Wrapf(err, "minKotsVersion %s does not parse as semver", app.Spec.MinKotsVersion)
+	}
+
+	currentSemver, err := semver.NewVersion(kotsVersion)
+	if err != nil {
+		return nil
+	}
+
+	if currentSemver.LessThan(minSemver) {
+		return errors.Errorf("%s requires kots %s or later, this is kots %s", title(app), app.Spec.MinKotsVersion, kotsVersion)
+	}
+
+	return nil
+}
+
+// IsBehindTargetKotsVersion returns true when kotsVersion is older than the version of kots the
+// application was released for. The app can still be installed, but may not work as released.
+func IsBehindTargetKotsVersion(app *kotsv1beta1.Application, kotsVersion string) bool {
+	if app.Spec.TargetKotsVersion == "" {
+		return false
+	}
+
+	targetSemver, err := semver.NewVersion(app.Spec.TargetKotsVersion)
+	if err != nil {
+		return false
+	}
+
+	currentSemver, err := semver.NewVersion(kotsVersion)
+	if err != nil {
+		return false
+	}
+
+	return currentSemver.LessThan(targetSemver)
+}
+
+func title(app *kotsv1beta1.Application) string {
+	if app.Spec.Title != "" {
+		return app.Spec.Title
+	}
+	return "this application"
+}
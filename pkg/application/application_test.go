@@ -0,0 +1,148 @@
+package application
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const applicationYAML = `apiVersion: kots.io/v1beta1
+kind: Application
+metadata:
+  name: my-app
+spec:
+  title: My App
+  icon: https://example.com/icon.png
+  releaseNotes: fixed the bugs
+  minKotsVersion: 1.15.0
+  targetKotsVersion: 1.16.0
+  ports:
+    - serviceName: web
+      servicePort: 80
+      localPort: 8888
+      applicationUrl: http://web`
+
+func Test_ParseApplication(t *testing.T) {
+	app, err := ParseApplication([]byte(applicationYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "My App", app.Spec.Title)
+	assert.Equal(t, "https://example.com/icon.png", app.Spec.Icon)
+	assert.Equal(t, "fixed the bugs", app.Spec.ReleaseNotes)
+	assert.Equal(t, "1.15.0", app.Spec.MinKotsVersion)
+	assert.Equal(t, "1.16.0", app.Spec.TargetKotsVersion)
+	require.Len(t, app.Spec.ApplicationPorts, 1)
+	assert.Equal(t, 8888, app.Spec.ApplicationPorts[0].LocalPort)
+
+	_, err = ParseApplication([]byte("apiVersion: kots.io/v1beta1\nkind: Config\nmetadata:\n  name: config\n"))
+	assert.Equal(t, ErrNotApplication, errors.Cause(err))
+}
+
+func Test_FindApplication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kots-application")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"deployment.yaml":       "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"config.yaml":           "apiVersion: kots.io/v1beta1\nkind: Config\nmetadata:\n  name: config\n",
+		"notes/application.txt": applicationYAML,
+		"kots/application.yaml": applicationYAML,
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	found, err := FindApplication(dir)
+	require.NoError(t, err)
+	assert.Equal(t, applicationYAML, string(found))
+
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "kots")))
+	found, err = FindApplication(dir)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func Test_CheckMinKotsVersion(t *testing.T) {
+	tests := []struct {
+		name           string
+		minKotsVersion string
+		kotsVersion    string
+		wantErr        bool
+	}{
+		{
+			name:           "no min version",
+			minKotsVersion: "",
+			kotsVersion:    "1.10.0",
+		},
+		{
+			name:           "newer",
+			minKotsVersion: "1.15.0",
+			kotsVersion:    "1.16.2",
+		},
+		{
+			name:           "same",
+			minKotsVersion: "1.15.0",
+			kotsVersion:    "v1.15.0",
+		},
+		{
+			name:           "older",
+			minKotsVersion: "1.15.0",
+			kotsVersion:    "1.14.9",
+			wantErr:        true,
+		},
+		{
+			name:           "prerelease of the min version",
+			minKotsVersion: "1.15.0",
+			kotsVersion:    "1.15.0-beta.1",
+			wantErr:        true,
+		},
+		{
+			name:           "unreleased build",
+			minKotsVersion: "1.15.0",
+			kotsVersion:    "alpha",
+		},
+		{
+			name:           "invalid min version",
+			minKotsVersion: "latest",
+			kotsVersion:    "1.15.0",
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &kotsv1beta1.Application{
+				Spec: kotsv1beta1.ApplicationSpec{
+					Title:          "My App",
+					MinKotsVersion: tt.minKotsVersion,
+				},
+			}
+
+			err := CheckMinKotsVersion(app, tt.kotsVersion)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_IsBehindTargetKotsVersion(t *testing.T) {
+	app := &kotsv1beta1.Application{
+		Spec: kotsv1beta1.ApplicationSpec{
+			TargetKotsVersion: "1.16.0",
+		},
+	}
+
+	assert.True(t, IsBehindTargetKotsVersion(app, "1.15.3"))
+	assert.False(t, IsBehindTargetKotsVersion(app, "1.16.0"))
+	assert.False(t, IsBehindTargetKotsVersion(app, "alpha"))
+	assert.False(t, IsBehindTargetKotsVersion(&kotsv1beta1.Application{}, "1.15.3"))
+}
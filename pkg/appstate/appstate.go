@@ -0,0 +1,218 @@
+package appstate
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// resyncPeriod recomputes the state even when no events are received
+const resyncPeriod = 10 * time.Minute
+
+// getters get the resources of the status informers, from the api or from the informer caches
+type getters struct {
+	deployment  func(namespace, name string) (*appsv1.Deployment, error)
+	statefulSet func(namespace, name string) (*appsv1.StatefulSet, error)
+	service     func(namespace, name string) (*corev1.Service, error)
+	endpoints   func(namespace, name string) (*corev1.Endpoints, error)
+	ingress     func(namespace, name string) (*networkingv1beta1.Ingress, error)
+	pvc         func(namespace, name string) (*corev1.PersistentVolumeClaim, error)
+}
+
+// GetState returns the current state of the app with the status informers
+func GetState(clientset kubernetes.Interface, statusInformers []StatusInformer) (AppState, error) {
+	get := getters{
+		deployment: func(namespace, name string) (*appsv1.Deployment, error) {
+			return clientset.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+		},
+		statefulSet: func(namespace, name string) (*appsv1.StatefulSet, error) {
+			return clientset.AppsV1().StatefulSets(namespace).Get(name, metav1.GetOptions{})
+		},
+		service: func(namespace, name string) (*corev1.Service, error) {
+			return clientset.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+		},
+		endpoints: func(namespace, name string) (*corev1.Endpoints, error) {
+			return clientset.CoreV1().Endpoints(namespace).Get(name, metav1.GetOptions{})
+		},
+		ingress: func(namespace, name string) (*networkingv1beta1.Ingress, error) {
+			return clientset.NetworkingV1beta1().Ingresses(namespace).Get(name, metav1.GetOptions{})
+		},
+		pvc: func(namespace, name string) (*corev1.PersistentVolumeClaim, error) {
+			return clientset.CoreV1().PersistentVolumeClaims(namespace).Get(name, metav1.GetOptions{})
+		},
+	}
+
+	return getState(get, statusInformers)
+}
+
+// Watch calls callback with the state of the app with the status informers, and again every time
+// the state changes, until the context is done. The resources are watched with informers, so the
+// state isn't polled. Callback is called from one goroutine at a time.
+func Watch(ctx context.Context, clientset kubernetes.Interface, statusInformers []StatusInformer, callback func(AppState)) error {
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify() },
+		UpdateFunc: func(interface{}, interface{}) { notify() },
+		DeleteFunc: func(interface{}) { notify() },
+	}
+
+	// the informers only watch the namespaces of the status informers
+	factories := map[string]informers.SharedInformerFactory{}
+	for _, statusInformer := range statusInformers {
+		factory, ok := factories[statusInformer.Namespace]
+		if !ok {
+			factory = informers.NewSharedInformerFactoryWithOptions(clientset, resyncPeriod, informers.WithNamespace(statusInformer.Namespace))
+			factories[statusInformer.Namespace] = factory
+		}
+
+		switch statusInformer.Kind {
+		case KindDeployment:
+			factory.Apps().V1().Deployments().Informer().AddEventHandler(handler)
+		case KindStatefulSet:
+			factory.Apps().V1().StatefulSets().Informer().AddEventHandler(handler)
+		case KindService:
+			factory.Core().V1().Services().Informer().AddEventHandler(handler)
+			factory.Core().V1().Endpoints().Informer().AddEventHandler(handler)
+		case KindIngress:
+			factory.Networking().V1beta1().Ingresses().Informer().AddEventHandler(handler)
+		case KindPersistentVolumeClaim:
+			factory.Core().V1().PersistentVolumeClaims().Informer().AddEventHandler(handler)
+		}
+	}
+
+	for namespace, factory := range factories {
+		factory.Start(ctx.Done())
+		for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+			if !synced {
+				return errors.Errorf("failed to sync %v informer in namespace %s", informerType, namespace)
+			}
+		}
+	}
+
+	get := getters{
+		deployment: func(namespace, name string) (*appsv1.Deployment, error) {
+			return factories[namespace].Apps().V1().Deployments().Lister().Deployments(namespace).Get(name)
+		},
+		statefulSet: func(namespace, name string) (*appsv1.StatefulSet, error) {
+			return factories[namespace].Apps().V1().StatefulSets().Lister().StatefulSets(namespace).Get(name)
+		},
+		service: func(namespace, name string) (*corev1.Service, error) {
+			return factories[namespace].Core().V1().Services().Lister().Services(namespace).Get(name)
+		},
+		endpoints: func(namespace, name string) (*corev1.Endpoints, error) {
+			return factories[namespace].Core().V1().Endpoints().Lister().Endpoints(namespace).Get(name)
+		},
+		ingress: func(namespace, name string) (*networkingv1beta1.Ingress, error) {
+			return factories[namespace].Networking().V1beta1().Ingresses().Lister().Ingresses(namespace).Get(name)
+		},
+		pvc: func(namespace, name string) (*corev1.PersistentVolumeClaim, error) {
+			return factories[namespace].Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(namespace).Get(name)
+		},
+	}
+
+	var last *AppState
+	for {
+		appState, err := getState(get, statusInformers)
+		if err != nil {
+			return errors.Wrap(err, "failed to get app state")
+		}
+		if last == nil || !reflect.DeepEqual(*last, appState) {
+			callback(appState)
+			last = &appState
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+	}
+}
+
+func getState(get getters, statusInformers []StatusInformer) (AppState, error) {
+	resources := []ResourceState{}
+	for _, statusInformer := range statusInformers {
+		state, message, err := resourceState(get, statusInformer)
+		if err != nil {
+			return AppState{}, errors.Wrapf(err, "failed to get state of %s %s", statusInformer.Kind, statusInformer.Name)
+		}
+		resources = append(resources, ResourceState{
+			Kind:      statusInformer.Kind,
+			Namespace: statusInformer.Namespace,
+			Name:      statusInformer.Name,
+			State:     state,
+			Message:   message,
+		})
+	}
+
+	return aggregate(resources), nil
+}
+
+// resourceState returns the state of the resource, resources that don't exist are degraded
+func resourceState(get getters, statusInformer StatusInformer) (State, string, error) {
+	namespace, name := statusInformer.Namespace, statusInformer.Name
+
+	var state State
+	var message string
+	var err error
+	switch statusInformer.Kind {
+	case KindDeployment:
+		var deployment *appsv1.Deployment
+		if deployment, err = get.deployment(namespace, name); err == nil {
+			state, message = deploymentState(deployment)
+		}
+	case KindStatefulSet:
+		var statefulSet *appsv1.StatefulSet
+		if statefulSet, err = get.statefulSet(namespace, name); err == nil {
+			state, message = statefulSetState(statefulSet)
+		}
+	case KindService:
+		var service *corev1.Service
+		if service, err = get.service(namespace, name); err == nil {
+			endpoints, endpointsErr := get.endpoints(namespace, name)
+			if kuberneteserrors.IsNotFound(endpointsErr) {
+				endpoints, endpointsErr = nil, nil
+			}
+			if endpointsErr != nil {
+				return "", "", errors.Wrap(endpointsErr, "failed to get endpoints")
+			}
+			state, message = serviceState(service, endpoints)
+		}
+	case KindIngress:
+		var ingress *networkingv1beta1.Ingress
+		if ingress, err = get.ingress(namespace, name); err == nil {
+			state, message = ingressState(ingress)
+		}
+	case KindPersistentVolumeClaim:
+		var pvc *corev1.PersistentVolumeClaim
+		if pvc, err = get.pvc(namespace, name); err == nil {
+			state, message = persistentVolumeClaimState(pvc)
+		}
+	default:
+		return "", "", errors.Errorf("unsupported kind %q", statusInformer.Kind)
+	}
+
+	if kuberneteserrors.IsNotFound(err) {
+		return StateDegraded, "not found", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return state, message, nil
+}
@@ -0,0 +1,160 @@
+package appstate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ParseStatusInformer(t *testing.T) {
+	tests := []struct {
+		name        string
+		informer    string
+		expected    StatusInformer
+		expectError bool
+	}{
+		{
+			name:     "default namespace",
+			informer: "deployment/web",
+			expected: StatusInformer{Kind: KindDeployment, Namespace: "default", Name: "web"},
+		},
+		{
+			name:     "namespace and alias",
+			informer: "db/sts/postgres",
+			expected: StatusInformer{Kind: KindStatefulSet, Namespace: "db", Name: "postgres"},
+		},
+		{
+			name:     "plural",
+			informer: "PersistentVolumeClaims/data",
+			expected: StatusInformer{Kind: KindPersistentVolumeClaim, Namespace: "default", Name: "data"},
+		},
+		{
+			name:        "unsupported kind",
+			informer:    "daemonset/agent",
+			expectError: true,
+		},
+		{
+			name:        "no kind",
+			informer:    "web",
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			informer, err := ParseStatusInformer(test.informer, "default")
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, informer)
+		})
+	}
+}
+
+func testDeployment(replicas int32, available int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			UpdatedReplicas:    replicas,
+			AvailableReplicas:  available,
+		},
+	}
+}
+
+func Test_GetState(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{
+			{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}},
+		},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+	clientset := fake.NewSimpleClientset(testDeployment(2, 2), service, endpoints, pvc)
+
+	statusInformers := []StatusInformer{
+		{Kind: KindDeployment, Namespace: "default", Name: "web"},
+		{Kind: KindService, Namespace: "default", Name: "web"},
+	}
+	appState, err := GetState(clientset, statusInformers)
+	require.NoError(t, err)
+	assert.Equal(t, StateReady, appState.State)
+
+	statusInformers = append(statusInformers, StatusInformer{Kind: KindPersistentVolumeClaim, Namespace: "default", Name: "data"})
+	appState, err = GetState(clientset, statusInformers)
+	require.NoError(t, err)
+	assert.Equal(t, StateUpdating, appState.State)
+	assert.Equal(t, "waiting for a volume to be bound", appState.Resources[2].Message)
+
+	statusInformers = append(statusInformers, StatusInformer{Kind: KindStatefulSet, Namespace: "default", Name: "missing"})
+	appState, err = GetState(clientset, statusInformers)
+	require.NoError(t, err)
+	assert.Equal(t, StateDegraded, appState.State)
+	assert.Equal(t, "statefulset/missing in default is degraded: not found", appState.Resources[3].String())
+}
+
+func Test_deploymentState(t *testing.T) {
+	state, _ := deploymentState(testDeployment(3, 3))
+	assert.Equal(t, StateReady, state)
+
+	state, message := deploymentState(testDeployment(3, 1))
+	assert.Equal(t, StateDegraded, state)
+	assert.Equal(t, "1 of 3 replicas are available", message)
+
+	rollingOut := testDeployment(3, 3)
+	rollingOut.Generation = 2
+	state, _ = deploymentState(rollingOut)
+	assert.Equal(t, StateUpdating, state)
+}
+
+func Test_Watch(t *testing.T) {
+	clientset := fake.NewSimpleClientset(testDeployment(2, 1))
+	statusInformers := []StatusInformer{{Kind: KindDeployment, Namespace: "default", Name: "web"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	states := make(chan State, 10)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Watch(ctx, clientset, statusInformers, func(appState AppState) {
+			states <- appState.State
+		})
+	}()
+
+	select {
+	case state := <-states:
+		assert.Equal(t, StateDegraded, state)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the initial state")
+	}
+
+	_, err := clientset.AppsV1().Deployments("default").UpdateStatus(testDeployment(2, 2))
+	require.NoError(t, err)
+
+	select {
+	case state := <-states:
+		assert.Equal(t, StateReady, state)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the state to change")
+	}
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
@@ -0,0 +1,80 @@
+package appstate
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+)
+
+// kinds of the resources that can be status informers
+const (
+	KindDeployment            = "deployment"
+	KindStatefulSet           = "statefulset"
+	KindService               = "service"
+	KindIngress               = "ingress"
+	KindPersistentVolumeClaim = "persistentvolumeclaim"
+)
+
+// kindAliases are the names that kinds can be written with in the status informers, like kubectl
+// accepts them
+var kindAliases = map[string]string{
+	"deployment":             KindDeployment,
+	"deployments":            KindDeployment,
+	"deploy":                 KindDeployment,
+	"statefulset":            KindStatefulSet,
+	"statefulsets":           KindStatefulSet,
+	"sts":                    KindStatefulSet,
+	"service":                KindService,
+	"services":               KindService,
+	"svc":                    KindService,
+	"ingress":                KindIngress,
+	"ingresses":              KindIngress,
+	"ing":                    KindIngress,
+	"persistentvolumeclaim":  KindPersistentVolumeClaim,
+	"persistentvolumeclaims": KindPersistentVolumeClaim,
+	"pvc":                    KindPersistentVolumeClaim,
+}
+
+// StatusInformer is a resource whose state is part of the state of the app
+type StatusInformer struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// ParseStatusInformer parses a status informer of the kots application, "[namespace/]kind/name".
+// Resources without a namespace are in the default namespace.
+func ParseStatusInformer(informer string, defaultNamespace string) (StatusInformer, error) {
+	parts := strings.Split(informer, "/")
+	if len(parts) == 2 {
+		parts = append([]string{defaultNamespace}, parts...)
+	}
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return StatusInformer{}, errors.Errorf("invalid status informer %q, expected [namespace/]kind/name", informer)
+	}
+
+	kind, ok := kindAliases[strings.ToLower(parts[1])]
+	if !ok {
+		return StatusInformer{}, errors.Errorf("status informer %q has unsupported kind %q", informer, parts[1])
+	}
+
+	return StatusInformer{
+		Kind:      kind,
+		Namespace: parts[0],
+		Name:      parts[2],
+	}, nil
+}
+
+// ApplicationStatusInformers returns the status informers of the kots application
+func ApplicationStatusInformers(application *kotsv1beta1.Application, defaultNamespace string) ([]StatusInformer, error) {
+	informers := []StatusInformer{}
+	for _, s := range application.Spec.StatusInformers {
+		informer, err := ParseStatusInformer(s, defaultNamespace)
+		if err != nil {
+			return nil, err
+		}
+		informers = append(informers, informer)
+	}
+	return informers, nil
+}
@@ -0,0 +1,88 @@
+package appstate
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+)
+
+// deploymentState is ready when all of the replicas are available, and updating while a new
+// version is rolling out
+func deploymentState(deployment *appsv1.Deployment) (State, string) {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+
+	if status.ObservedGeneration < deployment.Generation || status.UpdatedReplicas < replicas {
+		return StateUpdating, fmt.Sprintf("%d of %d replicas are updated", status.UpdatedReplicas, replicas)
+	}
+	if status.AvailableReplicas < replicas {
+		return StateDegraded, fmt.Sprintf("%d of %d replicas are available", status.AvailableReplicas, replicas)
+	}
+	return StateReady, ""
+}
+
+// statefulSetState is ready when all of the replicas are ready, and updating while a new revision
+// is rolling out
+func statefulSetState(statefulSet *appsv1.StatefulSet) (State, string) {
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	status := statefulSet.Status
+
+	rollingOut := statefulSet.Spec.UpdateStrategy.Type != appsv1.OnDeleteStatefulSetStrategyType && status.UpdateRevision != status.CurrentRevision
+	if status.ObservedGeneration < statefulSet.Generation || rollingOut {
+		return StateUpdating, fmt.Sprintf("%d of %d replicas are updated", status.UpdatedReplicas, replicas)
+	}
+	if status.ReadyReplicas < replicas {
+		return StateDegraded, fmt.Sprintf("%d of %d replicas are ready", status.ReadyReplicas, replicas)
+	}
+	return StateReady, ""
+}
+
+// serviceState is ready when the service has a ready endpoint, and a load balancer service when it
+// has an address too. Endpoints is nil when the service has none.
+func serviceState(service *corev1.Service, endpoints *corev1.Endpoints) (State, string) {
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return StateReady, ""
+	}
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer && len(service.Status.LoadBalancer.Ingress) == 0 {
+		return StateUpdating, "waiting for a load balancer address"
+	}
+
+	ready, notReady := 0, 0
+	if endpoints != nil {
+		for _, subset := range endpoints.Subsets {
+			ready += len(subset.Addresses)
+			notReady += len(subset.NotReadyAddresses)
+		}
+	}
+	if ready == 0 {
+		return StateDegraded, fmt.Sprintf("0 of %d endpoints are ready", notReady)
+	}
+	return StateReady, ""
+}
+
+// ingressState is ready when the ingress controller has given the ingress an address
+func ingressState(ingress *networkingv1beta1.Ingress) (State, string) {
+	if len(ingress.Status.LoadBalancer.Ingress) == 0 {
+		return StateUpdating, "waiting for an ingress address"
+	}
+	return StateReady, ""
+}
+
+// persistentVolumeClaimState is ready when the claim is bound to a volume
+func persistentVolumeClaimState(pvc *corev1.PersistentVolumeClaim) (State, string) {
+	switch pvc.Status.Phase {
+	case corev1.ClaimBound:
+		return StateReady, ""
+	case corev1.ClaimLost:
+		return StateDegraded, "the bound volume was lost"
+	}
+	return StateUpdating, "waiting for a volume to be bound"
+}
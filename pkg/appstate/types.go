@@ -0,0 +1,64 @@
+package appstate
+
+import (
+	"fmt"
+)
+
+// State is the health of a resource or of the whole app
+type State string
+
+const (
+	// StateReady is a resource that's available as specified
+	StateReady State = "ready"
+	// StateUpdating is a resource that's rolling out or waiting for the cluster, e.g. for a load
+	// balancer or a volume
+	StateUpdating State = "updating"
+	// StateDegraded is a resource that isn't available as specified and isn't changing, or that
+	// doesn't exist
+	StateDegraded State = "degraded"
+)
+
+// stateOrder ranks the states, the state of an app is its lowest ranked resource state
+var stateOrder = map[State]int{
+	StateDegraded: 0,
+	StateUpdating: 1,
+	StateReady:    2,
+}
+
+// ResourceState is the state of one of the status informers. Message says why it isn't ready.
+type ResourceState struct {
+	Kind      string
+	Namespace string
+	Name      string
+	State     State
+	Message   string
+}
+
+func (r ResourceState) String() string {
+	s := fmt.Sprintf("%s/%s in %s is %s", r.Kind, r.Name, r.Namespace, r.State)
+	if r.Message != "" {
+		s = fmt.Sprintf("%s: %s", s, r.Message)
+	}
+	return s
+}
+
+// AppState is the state of an app, the lowest state of its resources, and the states of the
+// resources in the order of the status informers
+type AppState struct {
+	State     State
+	Resources []ResourceState
+}
+
+// aggregate returns the app state of the resources, an app without status informers is ready
+func aggregate(resources []ResourceState) AppState {
+	appState := AppState{
+		State:     StateReady,
+		Resources: resources,
+	}
+	for _, r := range resources {
+		if stateOrder[r.State] < stateOrder[appState.State] {
+			appState.State = r.State
+		}
+	}
+	return appState
+}
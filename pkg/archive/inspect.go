@@ -0,0 +1,179 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mholt/archiver"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	"github.com/replicatedhq/kots/pkg/image"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func init() {
+	kotsscheme.AddToScheme(scheme.Scheme)
+}
+
+var yamlDocSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// Inspection is a summary of an application archive or directory
+type Inspection struct {
+	Name            string   `json:"name"`
+	Title           string   `json:"title,omitempty"`
+	VersionLabel    string   `json:"versionLabel,omitempty"`
+	UpdateCursor    string   `json:"updateCursor,omitempty"`
+	KotsKinds       []string `json:"kotsKinds"`
+	ConfigItemCount int      `json:"configItemCount"`
+	Images          []string `json:"images"`
+}
+
+// Inspect reads an application from a tar.gz archive, as created by kots pull or kots upload, or
+// from a directory that has been rendered by kots pull. It doesn't need a cluster.
+func Inspect(path string) (*Inspection, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat path")
+	}
+
+	rootDir := path
+	if !fi.IsDir() {
+		tempDir, err := ioutil.TempDir("", "kots")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create temp dir")
+		}
+		defer os.RemoveAll(tempDir)
+
+		tarGz := archiver.TarGz{
+			Tar: &archiver.Tar{
+				ImplicitTopLevelFolder: false,
+			},
+		}
+		if err := tarGz.Unarchive(path, tempDir); err != nil {
+			return nil, errors.Wrap(err, "failed to extract archive")
+		}
+		rootDir = tempDir
+	}
+
+	appDir, err := findAppDir(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	inspection, err := inspectUpstream(filepath.Join(appDir, "upstream"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the base has the images after the templates have been rendered
+	imagesDir := filepath.Join(appDir, "base")
+	if _, err := os.Stat(imagesDir); err != nil {
+		imagesDir = filepath.Join(appDir, "upstream")
+	}
+	images, err := image.ListImages(imagesDir, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list images")
+	}
+	inspection.Images = images
+
+	return inspection, nil
+}
+
+// findAppDir returns the directory with the upstream, archives can have it at the root or in a
+// single top level folder
+func findAppDir(rootDir string) (string, error) {
+	if isDir(filepath.Join(rootDir, "upstream")) {
+		return rootDir, nil
+	}
+
+	entries, err := ioutil.ReadDir(rootDir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read dir")
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		appDir := filepath.Join(rootDir, entries[0].Name())
+		if isDir(filepath.Join(appDir, "upstream")) {
+			return appDir, nil
+		}
+	}
+
+	return "", errors.New("not an application archive or directory, there is no upstream")
+}
+
+func inspectUpstream(upstreamDir string) (*Inspection, error) {
+	inspection := Inspection{
+		KotsKinds: []string{},
+		Images:    []string{},
+	}
+	kinds := map[string]bool{}
+	appName := ""
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	err := filepath.Walk(upstreamDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		for _, doc := range yamlDocSeparator.Split(string(content), -1) {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			obj, gvk, err := decode([]byte(doc), nil, nil)
+			if err != nil || gvk.Group != "kots.io" {
+				continue
+			}
+			kinds[gvk.Kind] = true
+
+			switch o := obj.(type) {
+			case *kotsv1beta1.Installation:
+				inspection.Name = o.Name
+				inspection.UpdateCursor = o.Spec.UpdateCursor
+				inspection.VersionLabel = o.Spec.VersionLabel
+			case *kotsv1beta1.Application:
+				appName = o.Name
+				inspection.Title = o.Spec.Title
+			case *kotsv1beta1.Config:
+				for _, group := range o.Spec.Groups {
+					inspection.ConfigItemCount += len(group.Items)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk upstream")
+	}
+
+	if inspection.Name == "" {
+		inspection.Name = appName
+	}
+	for kind := range kinds {
+		inspection.KotsKinds = append(inspection.KotsKinds, kind)
+	}
+	sort.Strings(inspection.KotsKinds)
+
+	return &inspection, nil
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
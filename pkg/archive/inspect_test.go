@@ -0,0 +1,139 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mholt/archiver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFiles = map[string]string{
+	"upstream/userdata/installation.yaml": `apiVersion: kots.io/v1beta1
+kind: Installation
+metadata:
+  name: my-app
+spec:
+  updateCursor: "4"
+  versionLabel: 1.0.2
+`,
+	"upstream/application.yaml": `apiVersion: kots.io/v1beta1
+kind: Application
+metadata:
+  name: my-app
+spec:
+  title: My App
+`,
+	"upstream/config.yaml": `apiVersion: kots.io/v1beta1
+kind: Config
+metadata:
+  name: my-app
+spec:
+  groups:
+  - name: database
+    title: Database
+    items:
+    - name: hostname
+      type: text
+    - name: password
+      type: password
+  - name: smtp
+    title: SMTP
+    items:
+    - name: enabled
+      type: bool
+`,
+	"upstream/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.17
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+spec:
+  template:
+    spec:
+      containers:
+      - name: worker
+        image: redis:5
+`,
+}
+
+var expectedInspection = Inspection{
+	Name:            "my-app",
+	Title:           "My App",
+	VersionLabel:    "1.0.2",
+	UpdateCursor:    "4",
+	KotsKinds:       []string{"Application", "Config", "Installation"},
+	ConfigItemCount: 3,
+	Images:          []string{"nginx:1.17", "redis:5"},
+}
+
+func writeTestFiles(t *testing.T, dir string) {
+	for name, content := range testFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func Test_Inspect(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	appDir := filepath.Join(tempDir, "my-app")
+	writeTestFiles(t, appDir)
+
+	tarGz := archiver.TarGz{
+		Tar: &archiver.Tar{
+			ImplicitTopLevelFolder: true,
+		},
+	}
+	archivePath := filepath.Join(tempDir, "my-app.tar.gz")
+	req.NoError(tarGz.Archive([]string{filepath.Join(appDir, "upstream")}, archivePath))
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{
+			name: "directory",
+			path: appDir,
+		},
+		{
+			name: "archive",
+			path: archivePath,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inspection, err := Inspect(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, expectedInspection, *inspection)
+		})
+	}
+}
+
+func Test_InspectNotAnApp(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	_, err = Inspect(tempDir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,224 @@
+package audit
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapName is the config map in the admin console namespace that holds the audit log. It's a
+// record of what was done with the cli, not tamper proof: anyone who can edit config maps in the
+// namespace can change or remove events, and only the last maxEvents events are kept.
+const ConfigMapName = "kotsadm-audit-log"
+
+const eventsKey = "events"
+
+// maxEvents keeps the config map well below the 1mb limit, the oldest events are removed first
+// without a trace
+const maxEvents = 1000
+
+// Actions that are recorded
+const (
+	ActionInstall             = "install"
+	ActionUpload              = "upload"
+	ActionAdminConsoleUpgrade = "admin-console-upgrade"
+	ActionUpstreamUpgrade     = "upstream-upgrade"
+	ActionResetPassword       = "reset-password"
+	ActionDeploy              = "deploy"
+	ActionRegistryChange      = "registry-change"
+)
+
+// Event is one operation done with the cli. Parameters must not contain secrets.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	User   string    `json:"user"`
+	// Host is the machine that the cli ran on, and KotsVersion the version of the cli
+	Host        string            `json:"host,omitempty"`
+	KotsVersion string            `json:"kotsVersion,omitempty"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+}
+
+type ListOptions struct {
+	Action string
+	User   string
+	Since  time.Time
+}
+
+// Record adds the event to the audit log in the namespace
+func Record(clientset kubernetes.Interface, namespace string, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(ConfigMapName, metav1.GetOptions{})
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get audit log")
+		}
+
+		create := kuberneteserrors.IsNotFound(err)
+		if create {
+			configMap = auditConfigMap(namespace)
+		}
+
+		events, err := eventsFromConfigMap(configMap)
+		if err != nil {
+			return errors.Wrap(err, "failed to read audit log")
+		}
+
+		events = append(events, event)
+		if len(events) > maxEvents {
+			events = events[len(events)-maxEvents:]
+		}
+
+		b, err := json.Marshal(events)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal audit log")
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[eventsKey] = string(b)
+
+		if create {
+			_, err = clientset.CoreV1().ConfigMaps(namespace).Create(configMap)
+			if kuberneteserrors.IsAlreadyExists(err) {
+				// created by another cli at the same time, retry with the new config map
+				return kuberneteserrors.NewConflict(corev1.Resource("configmaps"), ConfigMapName, err)
+			}
+		} else {
+			_, err = clientset.CoreV1().ConfigMaps(namespace).Update(configMap)
+		}
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to write audit log")
+	}
+
+	return nil
+}
+
+// List returns the events in the audit log that match the options, oldest first
+func List(clientset kubernetes.Interface, namespace string, options ListOptions) ([]Event, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(ConfigMapName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return []Event{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get audit log")
+	}
+
+	events, err := eventsFromConfigMap(configMap)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read audit log")
+	}
+
+	matching := []Event{}
+	for _, event := range events {
+		if options.Action != "" && event.Action != options.Action {
+			continue
+		}
+		if options.User != "" && event.User != options.User {
+			continue
+		}
+		if !options.Since.IsZero() && event.Time.Before(options.Since) {
+			continue
+		}
+		matching = append(matching, event)
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		return matching[i].Time.Before(matching[j].Time)
+	})
+
+	return matching, nil
+}
+
+// CurrentHost returns the host name of the machine that the cli runs on
+func CurrentHost() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "unknown"
+	}
+	return host
+}
+
+// CurrentUser returns the user that the cluster authenticates the cli as: the user that the
+// cluster reviews the bearer token of the config as, or the common name of its client certificate.
+// The name of the user in the kubeconfig isn't used, it can be anything. It's "unknown" when the
+// identity can't be found, e.g. for credentials from an exec plugin or when the user can't create
+// token reviews.
+func CurrentUser(clientset kubernetes.Interface, cfg *rest.Config) string {
+	token := cfg.BearerToken
+	if token == "" && cfg.BearerTokenFile != "" {
+		b, err := ioutil.ReadFile(cfg.BearerTokenFile)
+		if err == nil {
+			token = strings.TrimSpace(string(b))
+		}
+	}
+	if token != "" {
+		review, err := clientset.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+			Spec: authenticationv1.TokenReviewSpec{Token: token},
+		})
+		if err == nil && review.Status.Authenticated && review.Status.User.Username != "" {
+			return review.Status.User.Username
+		}
+	}
+
+	certData := cfg.TLSClientConfig.CertData
+	if len(certData) == 0 && cfg.TLSClientConfig.CertFile != "" {
+		certData, _ = ioutil.ReadFile(cfg.TLSClientConfig.CertFile)
+	}
+	if block, _ := pem.Decode(certData); block != nil {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err == nil && cert.Subject.CommonName != "" {
+			return cert.Subject.CommonName
+		}
+	}
+
+	return "unknown"
+}
+
+func eventsFromConfigMap(configMap *corev1.ConfigMap) ([]Event, error) {
+	events := []Event{}
+	data, ok := configMap.Data[eventsKey]
+	if !ok || data == "" {
+		return events, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &events); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal events")
+	}
+	return events, nil
+}
+
+func auditConfigMap(namespace string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"kots.io/audit": "true",
+			},
+		},
+	}
+}
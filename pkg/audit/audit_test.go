@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_RecordAndList(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	events, err := List(clientset, "default", ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	require.NoError(t, Record(clientset, "default", Event{Time: start, Action: ActionInstall, User: "admin"}))
+	require.NoError(t, Record(clientset, "default", Event{Time: start.Add(time.Hour), Action: ActionUpload, User: "ci", Parameters: map[string]string{"slug": "my-app"}}))
+	require.NoError(t, Record(clientset, "default", Event{Time: start.Add(2 * time.Hour), Action: ActionUpload, User: "admin"}))
+
+	events, err = List(clientset, "default", ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	events, err = List(clientset, "default", ListOptions{Action: ActionUpload, Since: start.Add(90 * time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{Time: start.Add(2 * time.Hour), Action: ActionUpload, User: "admin"}}, events)
+
+	events, err = List(clientset, "default", ListOptions{User: "ci"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, map[string]string{"slug": "my-app"}, events[0].Parameters)
+
+	events, err = List(clientset, "other", ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func Test_RecordKeepsNewestEvents(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < maxEvents+5; i++ {
+		require.NoError(t, Record(clientset, "default", Event{Time: start.Add(time.Duration(i) * time.Minute), Action: ActionUpload}))
+	}
+
+	events, err := List(clientset, "default", ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events, maxEvents)
+	assert.Equal(t, start.Add(5*time.Minute), events[0].Time)
+}
+
+func Test_RecordHostAndVersion(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	event := Event{Action: ActionDeploy, User: "admin", Host: "ci-runner-1", KotsVersion: "v1.20.0", Parameters: map[string]string{"downstream": "production"}}
+	require.NoError(t, Record(clientset, "default", event))
+
+	events, err := List(clientset, "default", ListOptions{Action: ActionDeploy})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "ci-runner-1", events[0].Host)
+	assert.Equal(t, "v1.20.0", events[0].KotsVersion)
+	assert.False(t, events[0].Time.IsZero())
+}
+
+func Test_CurrentUser(t *testing.T) {
+	req := require.New(t)
+
+	// the token is reviewed by the cluster
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "valid" {
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:ci:uploader"
+		}
+		return true, review, nil
+	})
+	assert.Equal(t, "system:serviceaccount:ci:uploader", CurrentUser(clientset, &rest.Config{BearerToken: "valid"}))
+	assert.Equal(t, "unknown", CurrentUser(clientset, &rest.Config{BearerToken: "invalid"}))
+
+	// the common name of the client certificate is the user
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	req.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "jane", Organization: []string{"system:masters"}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	req.NoError(err)
+	cfg := &rest.Config{
+		TLSClientConfig: rest.TLSClientConfig{
+			CertData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+	assert.Equal(t, "jane", CurrentUser(clientset, cfg))
+
+	assert.Equal(t, "unknown", CurrentUser(clientset, &rest.Config{}))
+}
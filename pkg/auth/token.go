@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// scopes of an api token
+const (
+	// TokenScopeUpload can upload new versions of an app, and read
+	TokenScopeUpload = "upload"
+	// TokenScopeReadOnly can only read
+	TokenScopeReadOnly = "read-only"
+)
+
+const (
+	// TokenLabel is set on the secrets of api tokens
+	TokenLabel = "kots.io/api-token"
+
+	tokenSecretPrefix = "kotsadm-api-token-"
+
+	tokenHashKey  = "tokenHash"
+	tokenScopeKey = "scope"
+	expiresAtKey  = "expiresAt"
+)
+
+// APIToken is a token that CI systems authenticate to the admin console with instead of a
+// kubeconfig. Only the sha256 of the token is stored, in a secret in the namespace of the admin
+// console, the token itself is only returned when it's created.
+type APIToken struct {
+	// Name is the name of the secret of the token, it's also the prefix of the token
+	Name      string
+	Scope     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Expired is true once the token can't be used anymore
+func (t APIToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// Allows is true when the scope of the token includes scope
+func (t APIToken) Allows(scope string) bool {
+	return t.Scope == scope || (t.Scope == TokenScopeUpload && scope == TokenScopeReadOnly)
+}
+
+// CreateAPIToken creates a token with the scope that expires after ttl. The token is returned
+// with its secret and can't be read again later.
+func CreateAPIToken(clientset kubernetes.Interface, namespace string, scope string, ttl time.Duration) (string, *APIToken, error) {
+	if scope != TokenScopeUpload && scope != TokenScopeReadOnly {
+		return "", nil, errors.Errorf("unknown token scope %q, expected %s or %s", scope, TokenScopeUpload, TokenScopeReadOnly)
+	}
+	if ttl <= 0 {
+		return "", nil, errors.New("the token must expire")
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to generate token id")
+	}
+	secretValue, err := randomHex(32)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to generate token")
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	apiToken := &APIToken{
+		Name:      tokenSecretPrefix + id,
+		Scope:     scope,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	token := fmt.Sprintf("%s.%s", apiToken.Name, secretValue)
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      apiToken.Name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"kots.io/kotsadm": "true",
+				TokenLabel:        "true",
+			},
+		},
+		Data: map[string][]byte{
+			tokenHashKey:  []byte(hashToken(token)),
+			tokenScopeKey: []byte(scope),
+			expiresAtKey:  []byte(apiToken.ExpiresAt.Format(time.RFC3339)),
+		},
+	}
+	if _, err := clientset.CoreV1().Secrets(namespace).Create(secret); err != nil {
+		return "", nil, errors.Wrap(err, "failed to create token secret")
+	}
+
+	return token, apiToken, nil
+}
+
+// RotateAPIToken replaces the token with a new one with the same scope that expires after ttl.
+// The old token stops working right away.
+func RotateAPIToken(clientset kubernetes.Interface, namespace string, name string, ttl time.Duration) (string, *APIToken, error) {
+	secret, err := getTokenSecret(clientset, namespace, name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, apiToken, err := CreateAPIToken(clientset, namespace, string(secret.Data[tokenScopeKey]), ttl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := DeleteAPIToken(clientset, namespace, name); err != nil {
+		return "", nil, err
+	}
+
+	return token, apiToken, nil
+}
+
+// DeleteAPIToken revokes the token
+func DeleteAPIToken(clientset kubernetes.Interface, namespace string, name string) error {
+	if !strings.HasPrefix(name, tokenSecretPrefix) {
+		return errors.Errorf("%s is not an api token", name)
+	}
+
+	err := clientset.CoreV1().Secrets(namespace).Delete(name, &metav1.DeleteOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return errors.Errorf("api token %s does not exist", name)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to delete token secret")
+	}
+	return nil
+}
+
+// ListAPITokens returns the tokens in the namespace by name, including the ones that expired
+func ListAPITokens(clientset kubernetes.Interface, namespace string) ([]APIToken, error) {
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(metav1.ListOptions{LabelSelector: TokenLabel + "=true"})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list token secrets")
+	}
+
+	apiTokens := []APIToken{}
+	for _, secret := range secrets.Items {
+		apiToken, err := apiTokenFromSecret(&secret)
+		if err != nil {
+			return nil, err
+		}
+		apiTokens = append(apiTokens, *apiToken)
+	}
+
+	sort.Slice(apiTokens, func(i, j int) bool {
+		return apiTokens[i].Name < apiTokens[j].Name
+	})
+	return apiTokens, nil
+}
+
+// ValidateAPIToken returns the token when it exists, hasn't expired and its scope includes scope
+func ValidateAPIToken(clientset kubernetes.Interface, namespace string, token string, scope string) (*APIToken, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], tokenSecretPrefix) {
+		return nil, errors.New("invalid api token")
+	}
+
+	secret, err := getTokenSecret(clientset, namespace, parts[0])
+	if err != nil {
+		return nil, errors.New("invalid api token")
+	}
+	if subtle.ConstantTimeCompare(secret.Data[tokenHashKey], []byte(hashToken(token))) != 1 {
+		return nil, errors.New("invalid api token")
+	}
+
+	apiToken, err := apiTokenFromSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	if apiToken.Expired(time.Now()) {
+		return nil, errors.Errorf("api token %s expired at %s", apiToken.Name, apiToken.ExpiresAt.Format(time.RFC3339))
+	}
+	if !apiToken.Allows(scope) {
+		return nil, errors.Errorf("api token %s has scope %s, %s is required", apiToken.Name, apiToken.Scope, scope)
+	}
+
+	return apiToken, nil
+}
+
+func getTokenSecret(clientset kubernetes.Interface, namespace string, name string) (*corev1.Secret, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return nil, errors.Errorf("api token %s does not exist", name)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get token secret")
+	}
+	if secret.Labels[TokenLabel] != "true" {
+		return nil, errors.Errorf("%s is not an api token", name)
+	}
+	return secret, nil
+}
+
+func apiTokenFromSecret(secret *corev1.Secret) (*APIToken, error) {
+	expiresAt, err := time.Parse(time.RFC3339, string(secret.Data[expiresAtKey]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse expiry of api token %s", secret.Name)
+	}
+
+	return &APIToken{
+		Name:      secret.Name,
+		Scope:     string(secret.Data[tokenScopeKey]),
+		CreatedAt: secret.CreationTimestamp.Time,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_APITokens(t *testing.T) {
+	req := require.New(t)
+	clientset := fake.NewSimpleClientset()
+
+	uploadToken, uploadAPIToken, err := CreateAPIToken(clientset, "default", TokenScopeUpload, time.Hour)
+	req.NoError(err)
+	assert.True(t, strings.HasPrefix(uploadToken, uploadAPIToken.Name+"."))
+
+	readToken, _, err := CreateAPIToken(clientset, "default", TokenScopeReadOnly, time.Hour)
+	req.NoError(err)
+
+	// only the hash of the token is stored
+	secret, err := clientset.CoreV1().Secrets("default").Get(uploadAPIToken.Name, metav1.GetOptions{})
+	req.NoError(err)
+	for _, value := range secret.Data {
+		assert.NotContains(t, string(value), uploadToken)
+	}
+
+	_, err = ValidateAPIToken(clientset, "default", uploadToken, TokenScopeUpload)
+	req.NoError(err)
+	_, err = ValidateAPIToken(clientset, "default", uploadToken, TokenScopeReadOnly)
+	req.NoError(err)
+	_, err = ValidateAPIToken(clientset, "default", readToken, TokenScopeReadOnly)
+	req.NoError(err)
+	_, err = ValidateAPIToken(clientset, "default", readToken, TokenScopeUpload)
+	req.Error(err)
+	_, err = ValidateAPIToken(clientset, "default", uploadToken+"x", TokenScopeUpload)
+	req.Error(err)
+	_, err = ValidateAPIToken(clientset, "other", uploadToken, TokenScopeUpload)
+	req.Error(err)
+
+	apiTokens, err := ListAPITokens(clientset, "default")
+	req.NoError(err)
+	assert.Len(t, apiTokens, 2)
+
+	// the old token stops working when it's rotated, the new one has the same scope
+	rotatedToken, rotatedAPIToken, err := RotateAPIToken(clientset, "default", uploadAPIToken.Name, time.Hour)
+	req.NoError(err)
+	assert.Equal(t, TokenScopeUpload, rotatedAPIToken.Scope)
+	assert.NotEqual(t, uploadAPIToken.Name, rotatedAPIToken.Name)
+	_, err = ValidateAPIToken(clientset, "default", uploadToken, TokenScopeUpload)
+	req.Error(err)
+	_, err = ValidateAPIToken(clientset, "default", rotatedToken, TokenScopeUpload)
+	req.NoError(err)
+
+	req.NoError(DeleteAPIToken(clientset, "default", rotatedAPIToken.Name))
+	_, err = ValidateAPIToken(clientset, "default", rotatedToken, TokenScopeUpload)
+	req.Error(err)
+
+	_, _, err = CreateAPIToken(clientset, "default", "admin", time.Hour)
+	req.Error(err)
+	_, _, err = CreateAPIToken(clientset, "default", TokenScopeUpload, 0)
+	req.Error(err)
+}
+
+func Test_APITokenExpired(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	apiToken := APIToken{Scope: TokenScopeReadOnly, ExpiresAt: now.Add(time.Minute)}
+
+	assert.False(t, apiToken.Expired(now))
+	assert.True(t, apiToken.Expired(now.Add(time.Minute)))
+}
@@ -0,0 +1,153 @@
+package autodeploy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+)
+
+// UpgradeLevel is the largest change of the semver version of the app that's deployed
+// automatically
+type UpgradeLevel string
+
+const (
+	UpgradeLevelPatch UpgradeLevel = "patch"
+	UpgradeLevelMinor UpgradeLevel = "minor"
+	UpgradeLevelMajor UpgradeLevel = "major"
+)
+
+type Policy struct {
+	Level UpgradeLevel
+	// Window limits deploys to a maintenance window, updates are deployed as soon as they are
+	// found when it's nil
+	Window *MaintenanceWindow
+}
+
+// VersionConstraint returns the semver constraint of the releases that the policy deploys when
+// currentVersion is deployed
+func (p Policy) VersionConstraint(currentVersion string) (string, error) {
+	current, err := semver.NewVersion(currentVersion)
+	if err != nil {
+		return "", errors.Wrapf(err, "version %q of the deployed release is not semver, the upgrade level can't be applied", currentVersion)
+	}
+
+	switch p.Level {
+	case UpgradeLevelPatch:
+		return fmt.Sprintf(">= %s, < %s", current, current.IncMinor().String()), nil
+	case UpgradeLevelMinor:
+		return fmt.Sprintf(">= %s, < %s", current, current.IncMajor().String()), nil
+	case UpgradeLevelMajor:
+		return fmt.Sprintf(">= %s", current), nil
+	}
+
+	return "", errors.Errorf("unknown upgrade level %q, expected patch, minor or major", p.Level)
+}
+
+// MaintenanceWindow is a time of day that updates can be deployed in, on some days of the week.
+// A window that ends after midnight belongs to the day it starts on.
+type MaintenanceWindow struct {
+	// Days are the days of the week that the window starts on, every day when it's empty
+	Days []time.Weekday
+	// Start is the time after midnight that the window starts at
+	Start    time.Duration
+	Duration time.Duration
+	Location *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseMaintenanceWindow parses windows in the form "Sat,Sun 02:00-04:00", or "02:00-04:00" for
+// every day, in the time zone of location
+func ParseMaintenanceWindow(value string, location *time.Location) (*MaintenanceWindow, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, errors.Errorf("invalid maintenance window %q, expected days and a time range like Sat,Sun 02:00-04:00", value)
+	}
+
+	window := &MaintenanceWindow{
+		Location: location,
+	}
+	if window.Location == nil {
+		window.Location = time.UTC
+	}
+
+	if len(fields) == 2 {
+		for _, day := range strings.Split(fields[0], ",") {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, errors.Errorf("invalid day %q in maintenance window, expected Sun, Mon, Tue, Wed, Thu, Fri or Sat", day)
+			}
+			window.Days = append(window.Days, weekday)
+		}
+	}
+
+	times := strings.Split(fields[len(fields)-1], "-")
+	if len(times) != 2 {
+		return nil, errors.Errorf("invalid time range %q in maintenance window, expected a range like 02:00-04:00", fields[len(fields)-1])
+	}
+	start, err := parseTimeOfDay(times[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTimeOfDay(times[1])
+	if err != nil {
+		return nil, err
+	}
+	if end <= start {
+		end += 24 * time.Hour
+	}
+	window.Start = start
+	window.Duration = end - start
+
+	return window, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, errors.Errorf("invalid time %q in maintenance window, expected hours and minutes like 02:00", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains returns true if t is in the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	t = t.In(w.Location)
+
+	// a window that started the day before can still be open
+	for _, offset := range []int{0, -1} {
+		day := t.AddDate(0, 0, offset)
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, w.Location).Add(w.Start)
+		if !w.startsOn(start.Weekday()) {
+			continue
+		}
+		if !t.Before(start) && t.Before(start.Add(w.Duration)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w MaintenanceWindow) startsOn(weekday time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package autodeploy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_VersionConstraint(t *testing.T) {
+	tests := []struct {
+		name               string
+		level              UpgradeLevel
+		currentVersion     string
+		expectedConstraint string
+		wantErr            bool
+	}{
+		{
+			name:               "patch",
+			level:              UpgradeLevelPatch,
+			currentVersion:     "1.4.2",
+			expectedConstraint: ">= 1.4.2, < 1.5.0",
+		},
+		{
+			name:               "minor",
+			level:              UpgradeLevelMinor,
+			currentVersion:     "v1.4.2",
+			expectedConstraint: ">= 1.4.2, < 2.0.0",
+		},
+		{
+			name:               "major",
+			level:              UpgradeLevelMajor,
+			currentVersion:     "1.4.2",
+			expectedConstraint: ">= 1.4.2",
+		},
+		{
+			name:           "not semver",
+			level:          UpgradeLevelPatch,
+			currentVersion: "nightly",
+			wantErr:        true,
+		},
+		{
+			name:           "unknown level",
+			level:          "all",
+			currentVersion: "1.4.2",
+			wantErr:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			constraint, err := Policy{Level: test.level}.VersionConstraint(test.currentVersion)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedConstraint, constraint)
+		})
+	}
+}
+
+func Test_MaintenanceWindow(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		window   string
+		location *time.Location
+		time     time.Time
+		expected bool
+	}{
+		{
+			name:     "every day, inside",
+			window:   "02:00-04:00",
+			time:     time.Date(2020, 6, 3, 3, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name:     "every day, at the end",
+			window:   "02:00-04:00",
+			time:     time.Date(2020, 6, 3, 4, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name:     "weekend, on a saturday",
+			window:   "Sat,Sun 02:00-04:00",
+			time:     time.Date(2020, 6, 6, 2, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name:     "weekend, on a wednesday",
+			window:   "Sat,Sun 02:00-04:00",
+			time:     time.Date(2020, 6, 3, 3, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name:     "past midnight, on the next day",
+			window:   "Fri 23:00-01:00",
+			time:     time.Date(2020, 6, 6, 0, 30, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name:     "past midnight, started on the wrong day",
+			window:   "Fri 23:00-01:00",
+			time:     time.Date(2020, 6, 7, 0, 30, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name:     "time zone",
+			window:   "02:00-04:00",
+			location: newYork,
+			time:     time.Date(2020, 6, 3, 7, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			window, err := ParseMaintenanceWindow(test.window, test.location)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, window.Contains(test.time))
+		})
+	}
+}
+
+func Test_ParseMaintenanceWindowErrors(t *testing.T) {
+	for _, value := range []string{"", "Someday 02:00-04:00", "02:00", "2am-4am", "Sat 02:00-04:00 UTC"} {
+		_, err := ParseMaintenanceWindow(value, nil)
+		assert.Error(t, err, value)
+	}
+}
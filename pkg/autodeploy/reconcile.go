@@ -0,0 +1,107 @@
+package autodeploy
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/updatecheck"
+)
+
+// Deployer deploys a release of the app, e.g. by rendering an app directory and applying it
+type Deployer interface {
+	Deploy(ctx context.Context, update updatecheck.Update) error
+}
+
+type Options struct {
+	License  *kotsv1beta1.License
+	Policy   Policy
+	Deployer Deployer
+	// CurrentCursor and CurrentVersion are the release that's deployed
+	CurrentCursor  string
+	CurrentVersion string
+}
+
+// now and checkForUpdates are replaced in tests
+var (
+	now             = time.Now
+	checkForUpdates = updatecheck.CheckForUpdatesWithContext
+)
+
+// Reconcile deploys the update that the policy allows, if there is one and the maintenance
+// window is open. Required releases are deployed before any release after them. The update that
+// was deployed is returned, or nil if nothing was deployed.
+func Reconcile(ctx context.Context, options Options) (*updatecheck.Update, error) {
+	if options.Deployer == nil {
+		return nil, errors.New("a deployer is required")
+	}
+	if options.Policy.Window != nil && !options.Policy.Window.Contains(now()) {
+		return nil, nil
+	}
+
+	constraint, err := options.Policy.VersionConstraint(options.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	updates, err := checkForUpdates(ctx, updatecheck.Options{
+		License:           options.License,
+		CurrentCursor:     options.CurrentCursor,
+		VersionConstraint: constraint,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check for updates")
+	}
+
+	update := nextUpdate(updates)
+	if update == nil {
+		return nil, nil
+	}
+
+	if err := options.Deployer.Deploy(ctx, *update); err != nil {
+		return nil, errors.Wrapf(err, "failed to deploy %s", update.Version)
+	}
+
+	return update, nil
+}
+
+// Run reconciles every interval until ctx is done. Failures are logged and retried at the next
+// interval, and after a deploy the next update is looked for right away, so that the releases
+// after a required release are deployed in the same window.
+func Run(ctx context.Context, options Options, interval time.Duration, log *logger.Logger) error {
+	for {
+		update, err := Reconcile(ctx, options)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Error(err)
+		} else if update != nil {
+			log.Info("Deployed %s", update.Version)
+			options.CurrentCursor = update.Cursor
+			options.CurrentVersion = update.Version
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// nextUpdate returns the first required update, it can't be skipped, or else the latest update
+func nextUpdate(updates []updatecheck.Update) *updatecheck.Update {
+	if len(updates) == 0 {
+		return nil
+	}
+	for i := range updates {
+		if updates[i].Required {
+			return &updates[i]
+		}
+	}
+	return &updates[len(updates)-1]
+}
@@ -0,0 +1,182 @@
+package autodeploy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/updatecheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingDeployer struct {
+	deployed []string
+	err      error
+	// deployedCh is sent the version of each deploy when it's set
+	deployedCh chan string
+}
+
+func (d *recordingDeployer) Deploy(ctx context.Context, update updatecheck.Update) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.deployed = append(d.deployed, update.Version)
+	if d.deployedCh != nil {
+		d.deployedCh <- update.Version
+	}
+	return nil
+}
+
+// fakeUpdates replaces the update check with updates, and returns a func that restores it
+func fakeUpdates(t *testing.T, expectedConstraint string, updates []updatecheck.Update) func() {
+	checkForUpdates = func(ctx context.Context, options updatecheck.Options) ([]updatecheck.Update, error) {
+		assert.Equal(t, expectedConstraint, options.VersionConstraint)
+		return updates, nil
+	}
+	return func() {
+		checkForUpdates = updatecheck.CheckForUpdatesWithContext
+	}
+}
+
+func Test_Reconcile(t *testing.T) {
+	tests := []struct {
+		name             string
+		updates          []updatecheck.Update
+		expectedDeployed []string
+	}{
+		{
+			name: "no updates",
+		},
+		{
+			name: "latest",
+			updates: []updatecheck.Update{
+				{Cursor: "11", Version: "1.4.3"},
+				{Cursor: "12", Version: "1.4.4"},
+			},
+			expectedDeployed: []string{"1.4.4"},
+		},
+		{
+			name: "required first",
+			updates: []updatecheck.Update{
+				{Cursor: "11", Version: "1.4.3", Required: true},
+				{Cursor: "12", Version: "1.4.4"},
+			},
+			expectedDeployed: []string{"1.4.3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer fakeUpdates(t, ">= 1.4.2, < 1.5.0", test.updates)()
+
+			deployer := &recordingDeployer{}
+			update, err := Reconcile(context.Background(), Options{
+				Policy:         Policy{Level: UpgradeLevelPatch},
+				Deployer:       deployer,
+				CurrentCursor:  "10",
+				CurrentVersion: "1.4.2",
+			})
+			require.NoError(t, err)
+
+			if len(test.expectedDeployed) == 0 {
+				assert.Nil(t, update)
+				assert.Empty(t, deployer.deployed)
+				return
+			}
+			require.NotNil(t, update)
+			assert.Equal(t, test.expectedDeployed, deployer.deployed)
+			assert.Equal(t, test.expectedDeployed[0], update.Version)
+		})
+	}
+}
+
+func Test_ReconcileOutsideWindow(t *testing.T) {
+	defer fakeUpdates(t, ">= 1.4.2, < 1.5.0", []updatecheck.Update{{Cursor: "11", Version: "1.4.3"}})()
+	defer func() { now = time.Now }()
+
+	window, err := ParseMaintenanceWindow("Sat,Sun 02:00-04:00", time.UTC)
+	require.NoError(t, err)
+	options := Options{
+		Policy:         Policy{Level: UpgradeLevelPatch, Window: window},
+		CurrentCursor:  "10",
+		CurrentVersion: "1.4.2",
+	}
+
+	// a wednesday
+	now = func() time.Time { return time.Date(2020, 6, 3, 3, 0, 0, 0, time.UTC) }
+	deployer := &recordingDeployer{}
+	options.Deployer = deployer
+	update, err := Reconcile(context.Background(), options)
+	require.NoError(t, err)
+	assert.Nil(t, update)
+	assert.Empty(t, deployer.deployed)
+
+	// a saturday
+	now = func() time.Time { return time.Date(2020, 6, 6, 3, 0, 0, 0, time.UTC) }
+	update, err = Reconcile(context.Background(), options)
+	require.NoError(t, err)
+	require.NotNil(t, update)
+	assert.Equal(t, []string{"1.4.3"}, deployer.deployed)
+}
+
+func Test_ReconcileDeployError(t *testing.T) {
+	defer fakeUpdates(t, ">= 1.4.2", []updatecheck.Update{{Cursor: "11", Version: "2.0.0"}})()
+
+	_, err := Reconcile(context.Background(), Options{
+		Policy:         Policy{Level: UpgradeLevelMajor},
+		Deployer:       &recordingDeployer{err: errors.New("rollout failed")},
+		CurrentCursor:  "10",
+		CurrentVersion: "1.4.2",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to deploy 2.0.0: rollout failed")
+}
+
+func Test_RunDeploysRequiredReleasesInOrder(t *testing.T) {
+	releases := []updatecheck.Update{
+		{Cursor: "11", Version: "1.4.3", Required: true},
+		{Cursor: "12", Version: "1.4.4"},
+	}
+	checkForUpdates = func(ctx context.Context, options updatecheck.Options) ([]updatecheck.Update, error) {
+		updates := []updatecheck.Update{}
+		for _, release := range releases {
+			if release.Cursor > options.CurrentCursor {
+				updates = append(updates, release)
+			}
+		}
+		return updates, nil
+	}
+	defer func() { checkForUpdates = updatecheck.CheckForUpdatesWithContext }()
+
+	log := logger.NewLogger()
+	log.Silence()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	deployer := &recordingDeployer{deployedCh: make(chan string)}
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, Options{
+			Policy:         Policy{Level: UpgradeLevelPatch},
+			Deployer:       deployer,
+			CurrentCursor:  "10",
+			CurrentVersion: "1.4.2",
+		}, time.Hour, log)
+	}()
+
+	// both releases are deployed without waiting for the interval
+	deployed := []string{}
+	for len(deployed) < 2 {
+		select {
+		case version := <-deployer.deployedCh:
+			deployed = append(deployed, version)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("deployed %v before the timeout", deployed)
+		}
+	}
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"1.4.3", "1.4.4"}, deployed)
+}
@@ -1,7 +1,11 @@
 package base
 
 import (
+	"bytes"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	"github.com/replicatedhq/kots/pkg/application"
 	troubleshootscheme "github.com/replicatedhq/troubleshoot/pkg/client/troubleshootclientset/scheme"
 	"gopkg.in/yaml.v2"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -86,3 +90,21 @@ func (f BaseFile) ShouldBeIncludedInBaseFilesystem(excludeKotsKinds bool) bool {
 
 	return true
 }
+
+// GetApplication returns the Application kots kind of the base, or nil when the app doesn't have
+// one. Kots kinds are in the base until it's written, even when they are excluded from it.
+func (b *Base) GetApplication() *kotsv1beta1.Application {
+	for _, file := range b.Files {
+		// decoding every manifest of an app is slow, only the kots kinds are needed
+		if !bytes.Contains(file.Content, []byte("kots.io")) {
+			continue
+		}
+
+		app, err := application.ParseApplication(file.Content)
+		if err == nil {
+			return app
+		}
+	}
+
+	return nil
+}
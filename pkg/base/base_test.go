@@ -136,3 +136,26 @@ connection:
 		})
 	}
 }
+
+func TestGetApplication(t *testing.T) {
+	b := Base{
+		Files: []BaseFile{
+			{
+				Path:    "deployment.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"),
+			},
+			{
+				Path:    "application.yaml",
+				Content: []byte("apiVersion: kots.io/v1beta1\nkind: Application\nmetadata:\n  name: my-app\nspec:\n  title: My App\n  minKotsVersion: 1.15.0\n"),
+			},
+		},
+	}
+
+	app := b.GetApplication()
+	if assert.NotNil(t, app) {
+		assert.Equal(t, "My App", app.Spec.Title)
+		assert.Equal(t, "1.15.0", app.Spec.MinKotsVersion)
+	}
+
+	assert.Nil(t, (&Base{Files: b.Files[:1]}).GetApplication())
+}
@@ -0,0 +1,64 @@
+package base
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// TemplateAnnotation opts a text file that isn't yaml into template rendering, e.g. a config
+// file or a script. It's looked for in the first line of the file, so that it can be written
+// as a comment in the syntax of the file, e.g. '# kots.io/template: true'.
+const TemplateAnnotation = "kots.io/template"
+
+// renderedExtensions are the extensions of the files that are always rendered, including files
+// without an extension
+var renderedExtensions = map[string]bool{
+	"":      true,
+	".yaml": true,
+	".yml":  true,
+	".txt":  true,
+}
+
+// isBinaryContent returns true if the content isn't utf-8 text. Text can't contain null bytes,
+// which is what utf-16 text and most binary formats that happen to be valid utf-8 have.
+func isBinaryContent(content []byte) bool {
+	return !utf8.Valid(content) || bytes.IndexByte(content, 0) != -1
+}
+
+// isYAMLFile returns true if the file is rendered as yaml, with the documents that are excluded by
+// their WhenAnnotation removed
+func isYAMLFile(filePath string) bool {
+	ext := strings.ToLower(path.Ext(filePath))
+	return ext == ".yaml" || ext == ".yml" || ext == ""
+}
+
+// shouldRenderFile returns true if the template functions should be executed in the file. Binary
+// files are never rendered, they're passed through as they are. Text files are rendered when
+// they're yaml or plain text, or when they opt in with the TemplateAnnotation.
+func shouldRenderFile(filePath string, content []byte) bool {
+	if isBinaryContent(content) {
+		return false
+	}
+
+	if renderedExtensions[strings.ToLower(path.Ext(filePath))] {
+		return true
+	}
+
+	return hasTemplateAnnotation(content)
+}
+
+func hasTemplateAnnotation(content []byte) bool {
+	line := string(content)
+	if i := strings.IndexByte(line, '\n'); i != -1 {
+		line = line[:i]
+	}
+
+	i := strings.Index(line, TemplateAnnotation)
+	if i == -1 {
+		return false
+	}
+	value := strings.TrimLeft(line[i+len(TemplateAnnotation):], `"': `)
+	return strings.HasPrefix(strings.ToLower(value), "true")
+}
@@ -0,0 +1,77 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_shouldRenderFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		content  []byte
+		expected bool
+	}{
+		{
+			name:     "yaml",
+			path:     "manifests/deployment.yaml",
+			content:  []byte("kind: Deployment\n"),
+			expected: true,
+		},
+		{
+			name:     "no extension",
+			path:     "manifests/service",
+			content:  []byte("kind: Service\n"),
+			expected: true,
+		},
+		{
+			name:     "text",
+			path:     "NOTES.TXT",
+			content:  []byte("{{repl ConfigOption \"hostname\" }}\n"),
+			expected: true,
+		},
+		{
+			name:     "png",
+			path:     "files/logo.png",
+			content:  []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+			expected: false,
+		},
+		{
+			name:     "not utf-8 with yaml extension",
+			path:     "files/font.yaml",
+			content:  []byte{0xff, 0xfe, 'k', 0x00},
+			expected: false,
+		},
+		{
+			name:     "other text type",
+			path:     "files/nginx.conf",
+			content:  []byte("server_name {{repl ConfigOption \"hostname\" }};\n"),
+			expected: false,
+		},
+		{
+			name:     "other text type with annotation",
+			path:     "files/nginx.conf",
+			content:  []byte("# kots.io/template: true\nserver_name {{repl ConfigOption \"hostname\" }};\n"),
+			expected: true,
+		},
+		{
+			name:     "annotation not on first line",
+			path:     "files/run.sh",
+			content:  []byte("#!/bin/sh\n# kots.io/template: true\n"),
+			expected: false,
+		},
+		{
+			name:     "annotation false",
+			path:     "files/index.html",
+			content:  []byte("<!-- kots.io/template: \"false\" -->\n"),
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, shouldRenderFile(test.path, test.content))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package base
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DuplicateResourcesDedupe keeps one copy of objects that are rendered more than once with the
+	// same content, and fails when the copies are different
+	DuplicateResourcesDedupe = "dedupe"
+	// DuplicateResourcesError fails when an object is rendered more than once
+	DuplicateResourcesError = "error"
+	// DuplicateResourcesPreferFirst keeps the first copy of an object, in path order, and drops the others
+	DuplicateResourcesPreferFirst = "prefer-first"
+)
+
+// ValidateDuplicateResources returns an error if mode isn't one of the duplicate resource modes.
+// An empty mode is the same as DuplicateResourcesDedupe.
+func ValidateDuplicateResources(mode string) error {
+	switch mode {
+	case "", DuplicateResourcesDedupe, DuplicateResourcesError, DuplicateResourcesPreferFirst:
+		return nil
+	}
+	return errors.Errorf("unknown duplicate resources mode %q, expected one of %s, %s or %s", mode, DuplicateResourcesDedupe, DuplicateResourcesError, DuplicateResourcesPreferFirst)
+}
+
+type resourceID struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+func (id resourceID) String() string {
+	s := fmt.Sprintf("%s %s", id.Kind, id.Name)
+	if id.Namespace != "" {
+		s = fmt.Sprintf("%s in namespace %s", s, id.Namespace)
+	}
+	return s
+}
+
+// resolveDuplicateResources finds objects with the same apiVersion, kind, namespace and name in
+// more than one file, as happens when several charts ship the same crd or rbac, and resolves them
+// with mode. Kustomize refuses to build a base that has the same object twice.
+// Files that aren't a single kubernetes object are never treated as duplicates.
+func resolveDuplicateResources(files []BaseFile, mode string, log *logger.Logger) ([]BaseFile, error) {
+	if err := ValidateDuplicateResources(mode); err != nil {
+		return nil, err
+	}
+
+	type firstFile struct {
+		path    string
+		content interface{}
+	}
+	seen := map[resourceID]firstFile{}
+
+	// the first copy is the first in path order, so it doesn't depend on the order files were rendered in
+	files = append([]BaseFile{}, files...)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	conflicts := []string{}
+	resolved := []BaseFile{}
+	for _, file := range files {
+		id, content, ok := parseResource(file.Content)
+		if !ok {
+			resolved = append(resolved, file)
+			continue
+		}
+
+		first, exists := seen[id]
+		if !exists {
+			seen[id] = firstFile{path: file.Path, content: content}
+			resolved = append(resolved, file)
+			continue
+		}
+
+		switch mode {
+		case DuplicateResourcesError:
+			conflicts = append(conflicts, fmt.Sprintf("%s is in %s and %s", id, first.path, file.Path))
+		case DuplicateResourcesPreferFirst:
+			log.ChildActionWithoutSpinner("Skipping %s in %s, using the one in %s", id, file.Path, first.path)
+		default:
+			if !reflect.DeepEqual(first.content, content) {
+				conflicts = append(conflicts, fmt.Sprintf("%s in %s is different from %s", id, file.Path, first.path))
+				continue
+			}
+			log.ChildActionWithoutSpinner("Skipping %s in %s, it's the same as %s", id, file.Path, first.path)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, errors.Errorf("duplicate resources: %s", strings.Join(conflicts, "; "))
+	}
+
+	return resolved, nil
+}
+
+// parseResource returns the id and parsed content of a file with exactly one kubernetes object.
+// The content is compared instead of the bytes so that comments, such as the source that helm
+// adds, and formatting don't make identical objects different.
+func parseResource(content []byte) (resourceID, interface{}, bool) {
+	if len(splitYAMLDocs(content)) != 1 {
+		return resourceID{}, nil, false
+	}
+
+	doc := splitDoc{}
+	if err := yaml.Unmarshal(content, &doc); err != nil || doc.APIVersion == "" || doc.Kind == "" || doc.Metadata.Name == "" {
+		return resourceID{}, nil, false
+	}
+
+	var parsed interface{}
+	if err := yaml.Unmarshal(content, &parsed); err != nil {
+		return resourceID{}, nil, false
+	}
+
+	id := resourceID{
+		APIVersion: doc.APIVersion,
+		Kind:       doc.Kind,
+		Namespace:  doc.Metadata.Namespace,
+		Name:       doc.Metadata.Name,
+	}
+	return id, parsed, true
+}
@@ -0,0 +1,111 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveDuplicateResources(t *testing.T) {
+	crd := "apiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\nspec:\n  group: example.com\n"
+	crdFromOtherChart := "# Source: other/templates/crd.yaml\napiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\nspec:\n    group: example.com\n"
+	changedCRD := "apiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\nspec:\n  group: other.example.com\n"
+	role := "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: reader\n  namespace: a\n"
+	roleInOtherNamespace := "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: reader\n  namespace: b\n"
+
+	tests := []struct {
+		name        string
+		files       []BaseFile
+		mode        string
+		expected    []BaseFile
+		expectedErr bool
+	}{
+		{
+			name: "identical objects are deduplicated by default",
+			files: []BaseFile{
+				{Path: "charts/b/crd.yaml", Content: []byte(crdFromOtherChart)},
+				{Path: "charts/a/crd.yaml", Content: []byte(crd)},
+			},
+			expected: []BaseFile{
+				{Path: "charts/a/crd.yaml", Content: []byte(crd)},
+			},
+		},
+		{
+			name: "different objects with the same name fail by default",
+			files: []BaseFile{
+				{Path: "charts/a/crd.yaml", Content: []byte(crd)},
+				{Path: "charts/b/crd.yaml", Content: []byte(changedCRD)},
+			},
+			mode:        DuplicateResourcesDedupe,
+			expectedErr: true,
+		},
+		{
+			name: "objects in different namespaces are not duplicates",
+			files: []BaseFile{
+				{Path: "a.yaml", Content: []byte(role)},
+				{Path: "b.yaml", Content: []byte(roleInOtherNamespace)},
+			},
+			mode: DuplicateResourcesError,
+			expected: []BaseFile{
+				{Path: "a.yaml", Content: []byte(role)},
+				{Path: "b.yaml", Content: []byte(roleInOtherNamespace)},
+			},
+		},
+		{
+			name: "error mode fails on identical objects",
+			files: []BaseFile{
+				{Path: "charts/a/crd.yaml", Content: []byte(crd)},
+				{Path: "charts/b/crd.yaml", Content: []byte(crd)},
+			},
+			mode:        DuplicateResourcesError,
+			expectedErr: true,
+		},
+		{
+			name: "prefer first keeps the first in path order",
+			files: []BaseFile{
+				{Path: "charts/b/crd.yaml", Content: []byte(changedCRD)},
+				{Path: "charts/a/crd.yaml", Content: []byte(crd)},
+			},
+			mode: DuplicateResourcesPreferFirst,
+			expected: []BaseFile{
+				{Path: "charts/a/crd.yaml", Content: []byte(crd)},
+			},
+		},
+		{
+			name: "files that aren't a single object are kept",
+			files: []BaseFile{
+				{Path: "NOTES.txt", Content: []byte("thanks for installing")},
+				{Path: "all.yaml", Content: []byte(crd + "---\n" + role)},
+				{Path: "crd.yaml", Content: []byte(crd)},
+			},
+			mode: DuplicateResourcesError,
+			expected: []BaseFile{
+				{Path: "NOTES.txt", Content: []byte("thanks for installing")},
+				{Path: "all.yaml", Content: []byte(crd + "---\n" + role)},
+				{Path: "crd.yaml", Content: []byte(crd)},
+			},
+		},
+		{
+			name:        "unknown mode",
+			files:       []BaseFile{},
+			mode:        "prefer-last",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := resolveDuplicateResources(test.files, test.mode, nil)
+			if test.expectedErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package base
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/ghodss/yaml"
@@ -17,6 +20,21 @@ import (
 	"k8s.io/helm/pkg/timeconv"
 )
 
+// how helm charts are rendered
+const (
+	// HelmRendererV2 renders charts with the helm v2 libraries that are built into kots
+	HelmRendererV2 = "helm2"
+	// HelmRendererV3 renders charts with helm template of a helm v3 executable. It supports
+	// library charts and the template functions that were added in helm v3. The helm v3 libraries
+	// need newer kubernetes libraries than kots is built with, so the executable is used instead,
+	// and this renderer is only available to the kots cli: the admin console images don't have
+	// helm, and apps that are installed in the admin console are rendered with HelmRendererV2.
+	HelmRendererV3 = "helm3"
+)
+
+// DefaultHelmKubeVersion is the kubernetes version that charts are rendered for when none is set
+const DefaultHelmKubeVersion = "1.16.0"
+
 func renderHelm(u *upstream.Upstream, renderOptions *RenderOptions) (*Base, error) {
 	chartPath, err := ioutil.TempDir("", "kots")
 	if err != nil {
@@ -24,25 +42,22 @@ func renderHelm(u *upstream.Upstream, renderOptions *RenderOptions) (*Base, erro
 	}
 	defer os.RemoveAll(chartPath)
 
+	vals := map[string]interface{}{}
 	for _, file := range u.Files {
-		p := path.Join(chartPath, file.Path)
-		d, _ := path.Split(p)
-		if _, err := os.Stat(d); err != nil {
-			if os.IsNotExist(err) {
-				if err := os.MkdirAll(d, 0744); err != nil {
-					return nil, errors.Wrap(err, "failed to mkdir for chart resource")
-				}
-			} else {
-				return nil, errors.Wrap(err, "failed to check if dir exists")
+		if file.Path == upstream.HelmValuesPath {
+			if err := yaml.Unmarshal(file.Content, &vals); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal helm values")
 			}
+			continue
 		}
-
-		if err := ioutil.WriteFile(p, file.Content, 0644); err != nil {
+		if strings.HasPrefix(file.Path, "userdata/") {
+			continue
+		}
+		if _, err := util.WriteFile(chartPath, file.Path, file.Content); err != nil {
 			return nil, errors.Wrap(err, "failed to write chart file")
 		}
 	}
 
-	vals := map[string]interface{}{}
 	for _, value := range renderOptions.HelmOptions {
 		if err := strvals.ParseInto(value, vals); err != nil {
 			return nil, errors.Wrap(err, "failed to parse helm value")
@@ -53,7 +68,31 @@ func renderHelm(u *upstream.Upstream, renderOptions *RenderOptions) (*Base, erro
 		return nil, errors.Wrap(err, "failed to marshal helm values")
 	}
 
-	config := &chart.Config{Raw: string(marshalledVals), Values: map[string]*chart.Value{}}
+	kubeVersion := renderOptions.KubeVersion
+	if kubeVersion == "" {
+		kubeVersion = DefaultHelmKubeVersion
+	}
+
+	var baseFiles []BaseFile
+	switch renderOptions.HelmRenderer {
+	case "", HelmRendererV2:
+		baseFiles, err = renderHelmV2(u.Name, chartPath, marshalledVals, kubeVersion, renderOptions)
+	case HelmRendererV3:
+		baseFiles, err = renderHelmV3(u.Name, chartPath, marshalledVals, kubeVersion, renderOptions)
+	default:
+		return nil, errors.Errorf("unknown helm renderer %q, expected %s or %s", renderOptions.HelmRenderer, HelmRendererV2, HelmRendererV3)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Base{
+		Files: removeCommonPrefix(baseFiles),
+	}, nil
+}
+
+func renderHelmV2(name string, chartPath string, values []byte, kubeVersion string, renderOptions *RenderOptions) ([]BaseFile, error) {
+	config := &chart.Config{Raw: string(values), Values: map[string]*chart.Value{}}
 
 	c, err := chartutil.Load(chartPath)
 	if err != nil {
@@ -62,13 +101,13 @@ func renderHelm(u *upstream.Upstream, renderOptions *RenderOptions) (*Base, erro
 
 	renderOpts := renderutil.Options{
 		ReleaseOptions: chartutil.ReleaseOptions{
-			Name:      u.Name,
+			Name:      name,
 			IsInstall: true,
 			IsUpgrade: false,
 			Time:      timeconv.Now(),
 			Namespace: renderOptions.Namespace,
 		},
-		KubeVersion: "1.16.0",
+		KubeVersion: kubeVersion,
 	}
 
 	rendered, err := renderutil.Render(c, config, renderOpts)
@@ -86,35 +125,112 @@ func renderHelm(u *upstream.Upstream, renderOptions *RenderOptions) (*Base, erro
 		baseFiles = append(baseFiles, baseFile)
 	}
 
-	// remove any common prefix from all files
-	if len(baseFiles) > 0 {
-		firstFileDir, _ := path.Split(baseFiles[0].Path)
-		commonPrefix := strings.Split(firstFileDir, string(os.PathSeparator))
+	return baseFiles, nil
+}
 
-		for _, file := range baseFiles {
-			d, _ := path.Split(file.Path)
-			dirs := strings.Split(d, string(os.PathSeparator))
+// renderHelmV3 runs helm template of the helm executable in the render options, see
+// HelmRendererV3. It runs without a cluster, so lookup returns empty results and the capabilities
+// are the kube version and api versions of the render options.
+func renderHelmV3(name string, chartPath string, values []byte, kubeVersion string, renderOptions *RenderOptions) ([]BaseFile, error) {
+	helm := renderOptions.Helm
+	if helm == "" {
+		helm = "helm"
+	}
+	if _, err := exec.LookPath(helm); err != nil {
+		return nil, errors.Wrapf(err, "helm v3 is required to render charts with the %s renderer", HelmRendererV3)
+	}
 
-			commonPrefix = util.CommonSlicePrefix(commonPrefix, dirs)
+	valuesDir, err := ioutil.TempDir("", "kots")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create values dir")
+	}
+	defer os.RemoveAll(valuesDir)
+	valuesFile := filepath.Join(valuesDir, "values.yaml")
+	if err := ioutil.WriteFile(valuesFile, values, 0644); err != nil {
+		return nil, errors.Wrap(err, "failed to write values")
+	}
 
-		}
+	args := []string{"template", name, chartPath, "--values", valuesFile, "--kube-version", kubeVersion, "--include-crds"}
+	if renderOptions.Namespace != "" {
+		args = append(args, "--namespace", renderOptions.Namespace)
+	}
+	for _, apiVersion := range renderOptions.HelmAPIVersions {
+		args = append(args, "--api-versions", apiVersion)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(helm, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "failed to render chart: %s", strings.TrimSpace(stderr.String()))
+	}
 
-		cleanedBaseFiles := []BaseFile{}
-		for _, file := range baseFiles {
-			d, f := path.Split(file.Path)
-			d2 := strings.Split(d, string(os.PathSeparator))
+	return splitHelmTemplateOutput(stdout.Bytes()), nil
+}
 
-			cleanedBaseFile := file
-			d2 = d2[len(commonPrefix):]
-			cleanedBaseFile.Path = path.Join(path.Join(d2...), f)
+// splitHelmTemplateOutput returns a file for each template in the output of helm template, which
+// starts each document with a "# Source: <template path>" comment
+func splitHelmTemplateOutput(output []byte) []BaseFile {
+	paths := []string{}
+	docs := map[string][][]byte{}
+	for _, doc := range bytes.Split(append([]byte("\n"), output...), []byte("\n---\n")) {
+		doc = bytes.TrimSpace(doc)
+		if len(doc) == 0 {
+			continue
+		}
 
-			cleanedBaseFiles = append(cleanedBaseFiles, cleanedBaseFile)
+		firstLine := strings.SplitN(string(doc), "\n", 2)[0]
+		if !strings.HasPrefix(firstLine, "# Source: ") {
+			continue
 		}
+		source := strings.TrimSpace(strings.TrimPrefix(firstLine, "# Source: "))
 
-		baseFiles = cleanedBaseFiles
+		if _, ok := docs[source]; !ok {
+			paths = append(paths, source)
+		}
+		docs[source] = append(docs[source], doc)
 	}
 
-	return &Base{
-		Files: baseFiles,
-	}, nil
+	baseFiles := []BaseFile{}
+	for _, p := range paths {
+		content := bytes.Join(docs[p], []byte("\n---\n"))
+		baseFiles = append(baseFiles, BaseFile{
+			Path:    p,
+			Content: append(content, '\n'),
+		})
+	}
+	return baseFiles
+}
+
+// removeCommonPrefix removes the directories that all files are in from their paths
+func removeCommonPrefix(baseFiles []BaseFile) []BaseFile {
+	if len(baseFiles) == 0 {
+		return baseFiles
+	}
+
+	firstFileDir, _ := path.Split(baseFiles[0].Path)
+	commonPrefix := strings.Split(firstFileDir, "/")
+
+	for _, file := range baseFiles {
+		d, _ := path.Split(file.Path)
+		dirs := strings.Split(d, "/")
+
+		commonPrefix = util.CommonSlicePrefix(commonPrefix, dirs)
+
+	}
+
+	cleanedBaseFiles := []BaseFile{}
+	for _, file := range baseFiles {
+		d, f := path.Split(file.Path)
+		d2 := strings.Split(d, "/")
+
+		cleanedBaseFile := file
+		d2 = d2[len(commonPrefix):]
+		cleanedBaseFile.Path = path.Join(path.Join(d2...), f)
+
+		cleanedBaseFiles = append(cleanedBaseFiles, cleanedBaseFile)
+	}
+
+	return cleanedBaseFiles
 }
@@ -0,0 +1,111 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testChart() *upstream.Upstream {
+	return &upstream.Upstream{
+		Name: "nginx",
+		Type: "helm",
+		Files: []upstream.UpstreamFile{
+			{Path: "Chart.yaml", Content: []byte("apiVersion: v1\nname: nginx\nversion: 0.1.0\n")},
+			{Path: "values.yaml", Content: []byte("replicas: 1\n")},
+			{Path: "templates/deployment.yaml", Content: []byte("kind: Deployment\nreplicas: {{ .Values.replicas }}\n")},
+			{Path: upstream.HelmValuesPath, Content: []byte("replicas: 2\n")},
+		},
+	}
+}
+
+func Test_renderHelmV2(t *testing.T) {
+	b, err := renderHelm(testChart(), &RenderOptions{HelmOptions: []string{"replicas=3"}})
+	require.NoError(t, err)
+	require.Len(t, b.Files, 1)
+	assert.Equal(t, "deployment.yaml", b.Files[0].Path)
+	assert.Contains(t, string(b.Files[0].Content), "replicas: 3")
+
+	b, err = renderHelm(testChart(), &RenderOptions{})
+	require.NoError(t, err)
+	require.Len(t, b.Files, 1)
+	assert.Contains(t, string(b.Files[0].Content), "replicas: 2")
+}
+
+func Test_renderHelmV3(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "kots-helm")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// helm is replaced with a script that records its args and values, and prints templates
+	argsFile := filepath.Join(tempDir, "args")
+	helm := filepath.Join(tempDir, "helm")
+	script := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+while [ "$1" != "--values" ]; do shift; done
+cp "$2" ` + filepath.Join(tempDir, "values.yaml") + `
+cat <<EOF
+---
+# Source: nginx/templates/service.yaml
+kind: Service
+---
+# Source: nginx/templates/deployment.yaml
+kind: Deployment
+---
+# Source: nginx/templates/service.yaml
+kind: Service
+metadata:
+  name: headless
+EOF
+`
+	require.NoError(t, ioutil.WriteFile(helm, []byte(script), 0755))
+
+	b, err := renderHelm(testChart(), &RenderOptions{
+		HelmRenderer:    HelmRendererV3,
+		Helm:            helm,
+		Namespace:       "web",
+		KubeVersion:     "1.18.0",
+		HelmAPIVersions: []string{"monitoring.coreos.com/v1"},
+	})
+	require.NoError(t, err)
+
+	args, err := ioutil.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(args), "template nginx "), string(args))
+	assert.Contains(t, string(args), "--kube-version 1.18.0 --include-crds --namespace web --api-versions monitoring.coreos.com/v1")
+
+	values, err := ioutil.ReadFile(filepath.Join(tempDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "replicas: 2\n", string(values))
+
+	assert.Equal(t, []BaseFile{
+		{
+			Path:    "service.yaml",
+			Content: []byte("# Source: nginx/templates/service.yaml\nkind: Service\n---\n# Source: nginx/templates/service.yaml\nkind: Service\nmetadata:\n  name: headless\n"),
+		},
+		{
+			Path:    "deployment.yaml",
+			Content: []byte("# Source: nginx/templates/deployment.yaml\nkind: Deployment\n"),
+		},
+	}, b.Files)
+}
+
+func Test_renderHelmV3NotInstalled(t *testing.T) {
+	_, err := renderHelm(testChart(), &RenderOptions{
+		HelmRenderer: HelmRendererV3,
+		Helm:         "/nonexistent/helm",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "helm v3 is required")
+}
+
+func Test_renderHelmUnknownRenderer(t *testing.T) {
+	_, err := renderHelm(testChart(), &RenderOptions{HelmRenderer: "helm4"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+package base
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	helmHookAnnotation             = "helm.sh/hook"
+	helmHookWeightAnnotation       = "helm.sh/hook-weight"
+	helmHookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+)
+
+// translateHelmHooks replaces the helm hook annotations on rendered chart objects with kots
+// annotations, so that they can be deployed in order. Hooks that only run on test, delete or
+// rollback are removed since they should not be deployed with the application.
+// Files are returned ordered by phase and then weight.
+func translateHelmHooks(files []BaseFile) []BaseFile {
+	translatedFiles := []BaseFile{}
+	fileHooks := map[string]k8sdoc.Position{}
+
+	for _, file := range files {
+		docs := bytes.Split(file.Content, []byte("\n---\n"))
+
+		keptDocs := [][]byte{}
+		var fileHook *k8sdoc.Position
+		for _, doc := range docs {
+			translated, hook, keep := translateHelmHookDoc(doc)
+			if !keep {
+				continue
+			}
+			if hook != nil && fileHook == nil {
+				fileHook = hook
+			}
+			keptDocs = append(keptDocs, translated)
+		}
+		if len(keptDocs) == 0 {
+			continue
+		}
+
+		if fileHook != nil {
+			fileHooks[file.Path] = *fileHook
+		}
+		translatedFiles = append(translatedFiles, BaseFile{
+			Path:    file.Path,
+			Content: bytes.Join(keptDocs, []byte("\n---\n")),
+		})
+	}
+
+	sort.SliceStable(translatedFiles, func(i, j int) bool {
+		return fileHooks[translatedFiles[i].Path].Less(fileHooks[translatedFiles[j].Path])
+	})
+
+	return translatedFiles
+}
+
+// translateHelmHookDoc returns the doc with kots hook annotations, and false if the doc should be removed.
+// Docs without hooks, or that can't be parsed, are returned unchanged.
+func translateHelmHookDoc(doc []byte) ([]byte, *k8sdoc.Position, bool) {
+	obj := yaml.MapSlice{}
+	if err := yaml.Unmarshal(doc, &obj); err != nil {
+		return doc, nil, true
+	}
+
+	metadata, ok := mapSliceValue(obj, "metadata").(yaml.MapSlice)
+	if !ok {
+		return doc, nil, true
+	}
+	annotations, ok := mapSliceValue(metadata, "annotations").(yaml.MapSlice)
+	if !ok {
+		return doc, nil, true
+	}
+	hooks, ok := mapSliceValue(annotations, helmHookAnnotation).(string)
+	if !ok {
+		return doc, nil, true
+	}
+
+	phase, keep := hookPhase(hooks)
+	if !keep {
+		return nil, nil, false
+	}
+
+	weight := 0
+	if w, ok := mapSliceValue(annotations, helmHookWeightAnnotation).(string); ok {
+		weight, _ = strconv.Atoi(strings.TrimSpace(w))
+	}
+	deletePolicy, _ := mapSliceValue(annotations, helmHookDeletePolicyAnnotation).(string)
+
+	translatedAnnotations := yaml.MapSlice{}
+	for _, item := range annotations {
+		switch item.Key {
+		case helmHookAnnotation, helmHookWeightAnnotation, helmHookDeletePolicyAnnotation:
+			continue
+		}
+		translatedAnnotations = append(translatedAnnotations, item)
+	}
+	translatedAnnotations = append(translatedAnnotations,
+		yaml.MapItem{Key: k8sdoc.HookAnnotation, Value: phase},
+		yaml.MapItem{Key: k8sdoc.HookWeightAnnotation, Value: strconv.Itoa(weight)},
+	)
+	if deletePolicy != "" {
+		translatedAnnotations = append(translatedAnnotations, yaml.MapItem{Key: k8sdoc.HookDeletePolicyAnnotation, Value: deletePolicy})
+	}
+
+	metadata = setMapSliceValue(metadata, "annotations", translatedAnnotations)
+	obj = setMapSliceValue(obj, "metadata", metadata)
+
+	translated, err := yaml.Marshal(obj)
+	if err != nil {
+		return doc, nil, true
+	}
+
+	return translated, &k8sdoc.Position{HookPhase: phase, HookWeight: weight}, true
+}
+
+// hookPhase returns the kots phase for a comma separated list of helm hooks,
+// and false if none of the hooks run on install or upgrade
+func hookPhase(hooks string) (string, bool) {
+	phase := ""
+	for _, hook := range strings.Split(hooks, ",") {
+		switch strings.TrimSpace(hook) {
+		case "crd-install":
+			return k8sdoc.HookPhaseCRDInstall, true
+		case "pre-install", "pre-upgrade":
+			phase = k8sdoc.HookPhasePreInstall
+		case "post-install", "post-upgrade":
+			if phase == "" {
+				phase = k8sdoc.HookPhasePostInstall
+			}
+		}
+	}
+
+	return phase, phase != ""
+}
+
+func mapSliceValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setMapSliceValue(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
@@ -0,0 +1,109 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_translateHelmHooks(t *testing.T) {
+	files := []BaseFile{
+		{
+			Path: "deployment.yaml",
+			Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`),
+		},
+		{
+			Path: "migrate.yaml",
+			Content: []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-weight: "5"
+    helm.sh/hook-delete-policy: before-hook-creation
+    owner: db
+`),
+		},
+		{
+			Path: "seed.yaml",
+			Content: []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: seed
+  annotations:
+    helm.sh/hook: pre-install
+    helm.sh/hook-weight: "-1"
+`),
+		},
+		{
+			Path: "crd.yaml",
+			Content: []byte(`apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+  annotations:
+    helm.sh/hook: crd-install
+`),
+		},
+		{
+			Path: "test-connection.yaml",
+			Content: []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: test-connection
+  annotations:
+    helm.sh/hook: test-success
+`),
+		},
+	}
+
+	translated := translateHelmHooks(files)
+
+	paths := []string{}
+	for _, file := range translated {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{"crd.yaml", "seed.yaml", "migrate.yaml", "deployment.yaml"}, paths)
+
+	assert.Equal(t, `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    owner: db
+    kots.io/hook: pre-install
+    kots.io/hook-weight: "5"
+    kots.io/hook-delete-policy: before-hook-creation
+`, string(translated[2].Content))
+
+	assert.Equal(t, string(files[0].Content), string(translated[3].Content))
+}
+
+func Test_hookPhase(t *testing.T) {
+	tests := []struct {
+		hooks         string
+		expectedPhase string
+		expectedKeep  bool
+	}{
+		{hooks: "pre-install", expectedPhase: k8sdoc.HookPhasePreInstall, expectedKeep: true},
+		{hooks: "post-install, post-upgrade", expectedPhase: k8sdoc.HookPhasePostInstall, expectedKeep: true},
+		{hooks: "post-install,pre-upgrade", expectedPhase: k8sdoc.HookPhasePreInstall, expectedKeep: true},
+		{hooks: "crd-install", expectedPhase: k8sdoc.HookPhaseCRDInstall, expectedKeep: true},
+		{hooks: "pre-delete", expectedPhase: "", expectedKeep: false},
+		{hooks: "test-success", expectedPhase: "", expectedKeep: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.hooks, func(t *testing.T) {
+			phase, keep := hookPhase(test.hooks)
+			assert.Equal(t, test.expectedPhase, phase)
+			assert.Equal(t, test.expectedKeep, keep)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package base
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// RedactedValue replaces sensitive values when rendering for support
+const RedactedValue = "kots-redacted"
+
+// redactedItemValue returns the placeholder for a config item, or false if the item is not sensitive.
+// File items are base64 encoded so that templates using ConfigOptionData still render.
+func redactedItemValue(item kotsv1beta1.ConfigItem) (string, bool) {
+	switch item.Type {
+	case "password":
+		return RedactedValue, true
+	case "file":
+		return base64.StdEncoding.EncodeToString([]byte(RedactedValue)), true
+	}
+	return "", false
+}
+
+// redactSensitiveConfig replaces the values of all password and file items in the template context
+func redactSensitiveConfig(configGroups []kotsv1beta1.ConfigGroup, itemValues map[string]template.ItemValue) {
+	for _, configGroup := range configGroups {
+		for _, configItem := range configGroup.Items {
+			redacted, ok := redactedItemValue(configItem)
+			if !ok {
+				continue
+			}
+			itemValues[configItem.Name] = template.ItemValue{
+				Value:   redacted,
+				Default: redacted,
+			}
+		}
+	}
+}
+
+// RedactedConfigValues returns the config values of the upstream with all password and file
+// values replaced, ready to be written as yaml. It returns nil if there are no config values.
+func RedactedConfigValues(u *upstream.Upstream, log *logger.Logger) ([]byte, error) {
+	config, configValues, _, _ := findConfig(u, log)
+	if configValues == nil {
+		return nil, nil
+	}
+
+	redacted := configValues.DeepCopy()
+	if config != nil {
+		for _, configGroup := range config.Spec.Groups {
+			for _, configItem := range configGroup.Items {
+				value, ok := redactedItemValue(configItem)
+				if !ok {
+					continue
+				}
+				if _, exists := redacted.Spec.Values[configItem.Name]; !exists {
+					continue
+				}
+				redacted.Spec.Values[configItem.Name] = kotsv1beta1.ConfigValue{
+					Value:   value,
+					Default: value,
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+	if err := s.Encode(redacted, &b); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal config values")
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,35 @@
+package base
+
+import (
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_redactSensitiveConfig(t *testing.T) {
+	configGroups := []kotsv1beta1.ConfigGroup{
+		{
+			Name: "database",
+			Items: []kotsv1beta1.ConfigItem{
+				{Name: "hostname", Type: "text"},
+				{Name: "password", Type: "password"},
+				{Name: "tls_cert", Type: "file"},
+			},
+		},
+	}
+	itemValues := map[string]template.ItemValue{
+		"hostname": {Value: "db.internal"},
+		"password": {Value: "hunter2"},
+		"tls_cert": {Value: "Y2VydA=="},
+	}
+
+	redactSensitiveConfig(configGroups, itemValues)
+
+	assert.Equal(t, map[string]template.ItemValue{
+		"hostname": {Value: "db.internal"},
+		"password": {Value: "kots-redacted", Default: "kots-redacted"},
+		"tls_cert": {Value: "a290cy1yZWRhY3RlZA==", Default: "a290cy1yZWRhY3RlZA=="},
+	}, itemValues)
+}
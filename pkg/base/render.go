@@ -8,21 +8,70 @@ import (
 
 type RenderOptions struct {
 	SplitMultiDocYAML bool
-	Namespace         string
-	HelmOptions       []string
-	Log               *logger.Logger
+	// RedactSensitiveConfig renders password and file config items with placeholder values
+	RedactSensitiveConfig bool
+	// EnvPrefixes are the prefixes of environment variables that templates can read with GetEnv
+	EnvPrefixes []string
+	Namespace   string
+	// HelmOptions are set overrides, e.g. "a.b=c", over the values in upstream.HelmValuesPath
+	HelmOptions []string
+	// HelmRenderer is how helm charts are rendered, HelmRendererV2 when it's empty
+	HelmRenderer string
+	// Helm is the helm v3 executable for HelmRendererV3
+	Helm string
+	// KubeVersion is the kubernetes version that helm charts are rendered for,
+	// DefaultHelmKubeVersion when it's empty
+	KubeVersion string
+	// HelmAPIVersions are the api versions, e.g. "monitoring.coreos.com/v1", that helm charts can
+	// check for with .Capabilities.APIVersions. They are only passed to HelmRendererV3.
+	HelmAPIVersions []string
+	// DuplicateResources is how objects rendered in more than one file are resolved, one of the
+	// DuplicateResources modes. Objects with the same content are deduplicated when it's empty.
+	DuplicateResources string
+	// Parallelism is the number of files that are rendered at the same time, the number of CPUs
+	// when it's 0
+	Parallelism int
+	Log         *logger.Logger
 }
 
 // RenderUpstream is responsible for any conversions or transpilation steps are required
 // to take an upstream and make it a valid kubernetes base
 func RenderUpstream(u *upstream.Upstream, renderOptions *RenderOptions) (*Base, error) {
+	var b *Base
+	var err error
 	if u.Type == "helm" {
-		return renderHelm(u, renderOptions)
+		b, err = renderHelm(u, renderOptions)
+	} else if u.Type == "replicated" {
+		b, err = renderReplicated(u, renderOptions)
+	} else {
+		return nil, errors.New("unknown upstream type")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the rules match the paths of the files in the release, so they are applied before the files are split
+	if app := b.GetApplication(); app != nil {
+		files, err := applyBaseRules(b.Files, app.Spec.BaseRules)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to apply base rules")
+		}
+		b.Files = files
+	}
+
+	if renderOptions.SplitMultiDocYAML {
+		b.Files = splitMultiDocYAML(b.Files)
+	}
+
+	if u.Type == "helm" {
+		b.Files = translateHelmHooks(b.Files)
 	}
 
-	if u.Type == "replicated" {
-		return renderReplicated(u, renderOptions)
+	files, err := resolveDuplicateResources(b.Files, renderOptions.DuplicateResources, renderOptions.Log)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve duplicate resources")
 	}
+	b.Files = files
 
-	return nil, errors.New("unknown upstream type")
+	return b, nil
 }
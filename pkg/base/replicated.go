@@ -1,6 +1,10 @@
 package base
 
 import (
+	"bytes"
+	"runtime"
+	"sync"
+
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/crypto"
@@ -36,38 +40,38 @@ func renderReplicated(u *upstream.Upstream, renderOptions *RenderOptions) (*Base
 		cipher = c
 	}
 
-	baseFiles := []BaseFile{}
-
 	builder := template.Builder{}
 	builder.AddCtx(template.StaticCtx{})
+	if len(renderOptions.EnvPrefixes) > 0 {
+		builder.AddCtx(template.EnvCtx{AllowedPrefixes: renderOptions.EnvPrefixes})
+	}
 
 	if config != nil {
 		configCtx, err := builder.NewConfigContext(config.Spec.Groups, templateContext, cipher)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create config context")
 		}
+		if renderOptions.RedactSensitiveConfig {
+			redactSensitiveConfig(config.Spec.Groups, configCtx.ItemValues)
+		}
 		builder.AddCtx(configCtx)
 	}
 
-	if license != nil {
-		licenseCtx := template.LicenseCtx{
-			License: license,
-		}
-		builder.AddCtx(licenseCtx)
+	// without a license, the license functions render empty values
+	licenseCtx := template.LicenseCtx{
+		License: license,
 	}
+	builder.AddCtx(licenseCtx)
 
-	for _, upstreamFile := range u.Files {
-		rendered, err := builder.RenderTemplate(upstreamFile.Path, string(upstreamFile.Content))
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to render file template")
-		}
-
-		baseFile := BaseFile{
-			Path:    upstreamFile.Path,
-			Content: []byte(rendered),
-		}
+	appCtx := template.AppCtx{
+		Installation: installation,
+		License:      license,
+	}
+	builder.AddCtx(appCtx)
 
-		baseFiles = append(baseFiles, baseFile)
+	baseFiles, err := renderFiles(&builder, u.Files, renderOptions.Parallelism)
+	if err != nil {
+		return nil, err
 	}
 
 	base := Base{
@@ -137,8 +141,13 @@ func findConfig(u *upstream.Upstream, log *logger.Logger) (*kotsv1beta1.Config,
 	var license *kotsv1beta1.License
 	var installation *kotsv1beta1.Installation
 
+	decode := scheme.Codecs.UniversalDeserializer().Decode
 	for _, file := range u.Files {
-		decode := scheme.Codecs.UniversalDeserializer().Decode
+		// decoding every manifest of an app is slow, only the kots kinds are needed
+		if !bytes.Contains(file.Content, []byte("kots.io")) {
+			continue
+		}
+
 		obj, gvk, err := decode(file.Content, nil, nil)
 		if err != nil {
 			log.Debug("%s", err)
@@ -157,3 +166,78 @@ func findConfig(u *upstream.Upstream, log *logger.Logger) (*kotsv1beta1.Config,
 	}
 	return config, values, license, installation
 }
+
+// renderFiles renders the files on parallelism workers, each with a copy of the builder. The base
+// files are in the order of the upstream files, whatever order they were rendered in, and the error
+// is the one of the first file that failed.
+func renderFiles(builder *template.Builder, files []upstream.UpstreamFile, parallelism int) ([]BaseFile, error) {
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+	if parallelism > len(files) {
+		parallelism = len(files)
+	}
+
+	results := make([]*BaseFile, len(files))
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workerBuilder := builder.Copy()
+			for index := range indexes {
+				results[index], errs[index] = renderFile(workerBuilder, files[index])
+			}
+		}()
+	}
+
+	for index := range files {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	baseFiles := []BaseFile{}
+	for i, result := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if result != nil {
+			baseFiles = append(baseFiles, *result)
+		}
+	}
+
+	return baseFiles, nil
+}
+
+// renderFile returns the base file of an upstream file, or nil when all of its docs are excluded
+func renderFile(builder *template.Builder, upstreamFile upstream.UpstreamFile) (*BaseFile, error) {
+	if !shouldRenderFile(upstreamFile.Path, upstreamFile.Content) {
+		return &BaseFile{
+			Path:    upstreamFile.Path,
+			Content: upstreamFile.Content,
+		}, nil
+	}
+
+	rendered, err := builder.RenderTemplate(upstreamFile.Path, string(upstreamFile.Content))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render file template")
+	}
+
+	content := []byte(rendered)
+	if isYAMLFile(upstreamFile.Path) {
+		c, included := excludeDocsWhenFalse(content)
+		if !included {
+			return nil, nil
+		}
+		content = c
+	}
+
+	return &BaseFile{
+		Path:    upstreamFile.Path,
+		Content: content,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package base
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/upstream"
+)
+
+const benchmarkConfig = `apiVersion: kots.io/v1beta1
+kind: Config
+metadata:
+  name: config
+spec:
+  groups:
+  - name: settings
+    title: Settings
+    items:
+    - name: hostname
+      type: text
+      default: app.example.com
+    - name: replicas
+      type: text
+      default: "2"
+`
+
+const benchmarkManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web-%d
+spec:
+  replicas: repl{{ ConfigOption "replicas" | ParseInt }}
+  template:
+    spec:
+      containers:
+      - name: web
+        image: registry.example.com/web:1.0.0
+        env:
+        - name: HOSTNAME
+          value: '{{repl ConfigOption "hostname" }}'
+`
+
+func benchmarkRenderReplicated(b *testing.B, n int, parallelism int) {
+	u := &upstream.Upstream{
+		Type: "replicated",
+		Files: []upstream.UpstreamFile{
+			{Path: "config.yaml", Content: []byte(benchmarkConfig)},
+		},
+	}
+	for i := 0; i < n; i++ {
+		u.Files = append(u.Files, upstream.UpstreamFile{
+			Path:    fmt.Sprintf("deployment-%d.yaml", i),
+			Content: []byte(fmt.Sprintf(benchmarkManifest, i)),
+		})
+	}
+
+	log := logger.NewLogger()
+	log.Silence()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := renderReplicated(u, &RenderOptions{Parallelism: parallelism, Log: log}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderReplicated_100(b *testing.B) {
+	benchmarkRenderReplicated(b, 100, 0)
+}
+
+func BenchmarkRenderReplicated_500(b *testing.B) {
+	benchmarkRenderReplicated(b, 500, 0)
+}
+
+// the sequential benchmarks render one file at a time, for comparison with the ones above
+func BenchmarkRenderReplicated_100_Sequential(b *testing.B) {
+	benchmarkRenderReplicated(b, 100, 1)
+}
+
+func BenchmarkRenderReplicated_500_Sequential(b *testing.B) {
+	benchmarkRenderReplicated(b, 500, 1)
+}
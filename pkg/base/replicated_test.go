@@ -0,0 +1,65 @@
+package base
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_renderFiles(t *testing.T) {
+	files := []upstream.UpstreamFile{}
+	want := []BaseFile{}
+	for i := 0; i < 50; i++ {
+		path := fmt.Sprintf("configmap-%d.yaml", i)
+		if i%10 == 3 {
+			// excluded files aren't in the base
+			files = append(files, upstream.UpstreamFile{
+				Path:    path,
+				Content: []byte(fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm-%d\n  annotations:\n    kots.io/when: '{{repl eq 1 2 }}'\n", i)),
+			})
+			continue
+		}
+		files = append(files, upstream.UpstreamFile{
+			Path:    path,
+			Content: []byte(fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm-{{repl add %d 1 }}\n", i)),
+		})
+		want = append(want, BaseFile{
+			Path:    path,
+			Content: []byte(fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm-%d\n", i+1)),
+		})
+	}
+	files = append(files, upstream.UpstreamFile{Path: "logo.png", Content: []byte{0x89, 'P', 'N', 'G', 0x00}})
+	want = append(want, BaseFile{Path: "logo.png", Content: []byte{0x89, 'P', 'N', 'G', 0x00}})
+
+	for _, parallelism := range []int{0, 1, 4, 100} {
+		t.Run(fmt.Sprintf("parallelism %d", parallelism), func(t *testing.T) {
+			builder := template.Builder{}
+			builder.AddCtx(template.StaticCtx{})
+
+			got, err := renderFiles(&builder, files, parallelism)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func Test_renderFilesError(t *testing.T) {
+	files := []upstream.UpstreamFile{
+		{Path: "a.yaml", Content: []byte("name: ok\n")},
+		{Path: "b.yaml", Content: []byte("name: '{{repl fail \"b failed\" }}'\n")},
+		{Path: "c.yaml", Content: []byte("name: '{{repl fail \"c failed\" }}'\n")},
+	}
+
+	builder := template.Builder{}
+	builder.AddCtx(template.StaticCtx{})
+
+	for i := 0; i < 10; i++ {
+		_, err := renderFiles(&builder, files, 3)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "b failed")
+	}
+}
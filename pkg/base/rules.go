@@ -0,0 +1,207 @@
+package base
+
+import (
+	"bytes"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type baseRule struct {
+	exclude    bool
+	paths      [][]string
+	selector   labels.Selector
+	namespaces []string
+}
+
+type ruleDoc struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Namespace string            `yaml:"namespace"`
+		Labels    map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+}
+
+// parseBaseRules returns the rules that apply. The when of the rules has been rendered with the
+// Application, rules whose when isn't a boolean, e.g. a template that failed to render, don't apply.
+func parseBaseRules(rules []kotsv1beta1.BaseRule) ([]baseRule, error) {
+	parsed := []baseRule{}
+	for i, rule := range rules {
+		if rule.When != "" {
+			apply, err := strconv.ParseBool(strings.TrimSpace(rule.When))
+			if err != nil || !apply {
+				continue
+			}
+		}
+
+		r := baseRule{
+			exclude:    rule.Exclude,
+			namespaces: rule.Namespaces,
+		}
+		for _, p := range rule.Paths {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid path %q in base rule %d", p, i)
+			}
+			r.paths = append(r.paths, strings.Split(strings.TrimPrefix(p, "/"), "/"))
+		}
+		for _, namespace := range rule.Namespaces {
+			if _, err := path.Match(namespace, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid namespace %q in base rule %d", namespace, i)
+			}
+		}
+		if rule.Selector != "" {
+			selector, err := labels.Parse(rule.Selector)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid selector in base rule %d", i)
+			}
+			r.selector = selector
+		}
+		parsed = append(parsed, r)
+	}
+
+	return parsed, nil
+}
+
+// applyBaseRules removes the objects that the base rules of the Application exclude from the files,
+// and the files that all of the objects are removed from. The kots kinds are always kept.
+func applyBaseRules(files []BaseFile, rules []kotsv1beta1.BaseRule) ([]BaseFile, error) {
+	parsed, err := parseBaseRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return files, nil
+	}
+
+	included := []BaseFile{}
+	for _, file := range files {
+		if !isYAMLFile(file.Path) {
+			if isIncludedByRules(parsed, file.Path, nil) {
+				included = append(included, file)
+			}
+			continue
+		}
+
+		docs := splitYAMLDocs(file.Content)
+		includedDocs := [][]byte{}
+		for _, doc := range docs {
+			parsedDoc := &ruleDoc{}
+			if err := yaml.Unmarshal(doc, parsedDoc); err != nil || parsedDoc.Kind == "" {
+				parsedDoc = nil
+			}
+			if parsedDoc != nil && isKotsKind(parsedDoc.APIVersion) {
+				includedDocs = append(includedDocs, doc)
+				continue
+			}
+			if isIncludedByRules(parsed, file.Path, parsedDoc) {
+				includedDocs = append(includedDocs, doc)
+			}
+		}
+
+		if len(includedDocs) == len(docs) {
+			included = append(included, file)
+			continue
+		}
+		if len(includedDocs) == 0 {
+			continue
+		}
+		for i, doc := range includedDocs {
+			includedDocs[i] = withTrailingNewline(doc)
+		}
+		included = append(included, BaseFile{
+			Path:    file.Path,
+			Content: bytes.Join(includedDocs, []byte("---\n")),
+		})
+	}
+
+	return included, nil
+}
+
+// isIncludedByRules returns the decision of the last rule that matches. Rules with a selector or
+// namespaces don't match content that isn't an object.
+func isIncludedByRules(rules []baseRule, filePath string, doc *ruleDoc) bool {
+	included := true
+	for _, rule := range rules {
+		if rule.matches(filePath, doc) {
+			included = !rule.exclude
+		}
+	}
+	return included
+}
+
+func (r baseRule) matches(filePath string, doc *ruleDoc) bool {
+	if len(r.paths) > 0 {
+		segments := strings.Split(filePath, "/")
+		matched := false
+		for _, p := range r.paths {
+			if matchPathSegments(p, segments) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if r.selector == nil && len(r.namespaces) == 0 {
+		return true
+	}
+	if doc == nil {
+		return false
+	}
+
+	if r.selector != nil && !r.selector.Matches(labels.Set(doc.Metadata.Labels)) {
+		return false
+	}
+	if len(r.namespaces) > 0 {
+		matched := false
+		for _, namespace := range r.namespaces {
+			if ok, _ := path.Match(namespace, doc.Metadata.Namespace); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchPathSegments matches the segments of a path with the segments of a glob, where "**" matches
+// any number of directories
+func matchPathSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchPathSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	matched, err := path.Match(pattern[0], segments[0])
+	if err != nil || !matched {
+		return false
+	}
+	return matchPathSegments(pattern[1:], segments[1:])
+}
+
+func isKotsKind(apiVersion string) bool {
+	return strings.HasPrefix(apiVersion, "kots.io/") || strings.HasPrefix(apiVersion, "troubleshoot.replicated.com/")
+}
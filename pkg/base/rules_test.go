@@ -0,0 +1,174 @@
+package base
+
+import (
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_applyBaseRules(t *testing.T) {
+	web := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  labels:\n    app: web\n"
+	prometheus := "apiVersion: apps/v1\nkind: StatefulSet\nmetadata:\n  name: prometheus\n  namespace: monitoring-system\n  labels:\n    app.kubernetes.io/part-of: prometheus\n"
+	alertmanager := "apiVersion: v1\nkind: Service\nmetadata:\n  name: alertmanager\n  namespace: monitoring-system\n  labels:\n    app.kubernetes.io/part-of: alertmanager\n"
+	app := "apiVersion: kots.io/v1beta1\nkind: Application\nmetadata:\n  name: app\n"
+
+	files := func() []BaseFile {
+		return []BaseFile{
+			{Path: "web.yaml", Content: []byte(web)},
+			{Path: "monitoring/prometheus.yaml", Content: []byte(prometheus + "---\n" + alertmanager)},
+			{Path: "monitoring/dashboards/README.md", Content: []byte("# dashboards\n")},
+			{Path: "kots-app.yaml", Content: []byte(app)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		rules    []kotsv1beta1.BaseRule
+		expected map[string]string
+	}{
+		{
+			name:  "no rules",
+			rules: nil,
+			expected: map[string]string{
+				"web.yaml":                        web,
+				"monitoring/prometheus.yaml":      prometheus + "---\n" + alertmanager,
+				"monitoring/dashboards/README.md": "# dashboards\n",
+				"kots-app.yaml":                   app,
+			},
+		},
+		{
+			name:  "paths",
+			rules: []kotsv1beta1.BaseRule{{Exclude: true, Paths: []string{"monitoring/**"}}},
+			expected: map[string]string{
+				"web.yaml":      web,
+				"kots-app.yaml": app,
+			},
+		},
+		{
+			name:  "a path glob doesn't match files in directories",
+			rules: []kotsv1beta1.BaseRule{{Exclude: true, Paths: []string{"*.yaml"}}},
+			expected: map[string]string{
+				"monitoring/prometheus.yaml":      prometheus + "---\n" + alertmanager,
+				"monitoring/dashboards/README.md": "# dashboards\n",
+				"kots-app.yaml":                   app,
+			},
+		},
+		{
+			name:  "selector removes objects from a file",
+			rules: []kotsv1beta1.BaseRule{{Exclude: true, Selector: "app.kubernetes.io/part-of=prometheus"}},
+			expected: map[string]string{
+				"web.yaml":                        web,
+				"monitoring/prometheus.yaml":      alertmanager,
+				"monitoring/dashboards/README.md": "# dashboards\n",
+				"kots-app.yaml":                   app,
+			},
+		},
+		{
+			name: "the last rule that matches decides",
+			rules: []kotsv1beta1.BaseRule{
+				{Exclude: true, Namespaces: []string{"monitoring-*"}},
+				{Paths: []string{"monitoring/*.yaml"}, Selector: "app.kubernetes.io/part-of=prometheus,!ignored"},
+				{Exclude: true, Selector: "app.kubernetes.io/part-of"},
+				{Namespaces: []string{"*-system"}, Selector: "app.kubernetes.io/part-of"},
+			},
+			expected: map[string]string{
+				"web.yaml":                        web,
+				"monitoring/prometheus.yaml":      prometheus + "---\n" + alertmanager,
+				"monitoring/dashboards/README.md": "# dashboards\n",
+				"kots-app.yaml":                   app,
+			},
+		},
+		{
+			name: "when",
+			rules: []kotsv1beta1.BaseRule{
+				{Exclude: true, Paths: []string{"web.yaml"}, When: "false"},
+				{Exclude: true, Selector: "app=web", When: "{{repl ConfigOption \"missing\" }}"},
+				{Exclude: true, Paths: []string{"**/*.yaml"}, Namespaces: []string{"monitoring-system"}, When: "true"},
+			},
+			expected: map[string]string{
+				"web.yaml":                        web,
+				"monitoring/dashboards/README.md": "# dashboards\n",
+				"kots-app.yaml":                   app,
+			},
+		},
+		{
+			name:  "kots kinds are kept",
+			rules: []kotsv1beta1.BaseRule{{Exclude: true, Paths: []string{"**"}}},
+			expected: map[string]string{
+				"kots-app.yaml": app,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			included, err := applyBaseRules(files(), test.rules)
+			require.NoError(t, err)
+
+			actual := map[string]string{}
+			for _, file := range included {
+				actual[file.Path] = string(file.Content)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_applyBaseRulesInvalid(t *testing.T) {
+	_, err := applyBaseRules(nil, []kotsv1beta1.BaseRule{{Selector: "app in (web"}})
+	assert.Error(t, err)
+
+	_, err = applyBaseRules(nil, []kotsv1beta1.BaseRule{{Paths: []string{"monitoring/[a-"}}})
+	assert.Error(t, err)
+}
+
+func Test_RenderUpstreamBaseRules(t *testing.T) {
+	u := &upstream.Upstream{
+		Type: "replicated",
+		Files: []upstream.UpstreamFile{
+			{
+				Path: "kots-app.yaml",
+				Content: []byte(`apiVersion: kots.io/v1beta1
+kind: Application
+metadata:
+  name: app
+spec:
+  title: App
+  baseRules:
+  - exclude: true
+    paths: ["monitoring/**"]
+    when: '{{repl ConfigOptionEquals "monitoring" "0" }}'
+`),
+			},
+			{
+				Path: "kots-config.yaml",
+				Content: []byte(`apiVersion: kots.io/v1beta1
+kind: Config
+metadata:
+  name: config
+spec:
+  groups:
+  - name: settings
+    items:
+    - name: monitoring
+      type: bool
+      default: "0"
+`),
+			},
+			{Path: "web.yaml", Content: []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n")},
+			{Path: "monitoring/prometheus.yaml", Content: []byte("apiVersion: apps/v1\nkind: StatefulSet\nmetadata:\n  name: prometheus\n")},
+		},
+	}
+
+	b, err := RenderUpstream(u, &RenderOptions{})
+	require.NoError(t, err)
+
+	paths := []string{}
+	for _, file := range b.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.ElementsMatch(t, []string{"kots-app.yaml", "kots-config.yaml", "web.yaml"}, paths)
+}
@@ -0,0 +1,137 @@
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	yamlDocSeparator = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$`)
+	unsafeFilename   = regexp.MustCompile(`[^a-z0-9.-]+`)
+)
+
+type splitDoc struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+// splitMultiDocYAML splits files that contain more than one kubernetes object into one
+// file per object, named <kind>-<name>.yaml in the directory of the original file.
+// Files with a single object, or content that isn't kubernetes yaml, are not changed.
+func splitMultiDocYAML(files []BaseFile) []BaseFile {
+	// names are assigned in path order so that they don't depend on the order files were rendered in
+	files = append([]BaseFile{}, files...)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	usedPaths := map[string]bool{}
+	for _, file := range files {
+		usedPaths[file.Path] = true
+	}
+
+	splitFiles := []BaseFile{}
+	for _, file := range files {
+		docs := splitYAMLDocs(file.Content)
+		if len(docs) < 2 {
+			splitFiles = append(splitFiles, file)
+			continue
+		}
+
+		parsedDocs := []splitDoc{}
+		for _, doc := range docs {
+			parsed := splitDoc{}
+			if err := yaml.Unmarshal(doc, &parsed); err != nil || parsed.Kind == "" || parsed.Metadata.Name == "" {
+				break
+			}
+			parsedDocs = append(parsedDocs, parsed)
+		}
+		if len(parsedDocs) != len(docs) {
+			// at least one of the docs can't be named, so leave the file as it is
+			splitFiles = append(splitFiles, file)
+			continue
+		}
+
+		delete(usedPaths, file.Path)
+		dir, _ := path.Split(file.Path)
+		for i, doc := range docs {
+			filePath := splitFilePath(dir, parsedDocs[i], usedPaths)
+			usedPaths[filePath] = true
+
+			splitFiles = append(splitFiles, BaseFile{
+				Path:    filePath,
+				Content: withTrailingNewline(doc),
+			})
+		}
+	}
+
+	return splitFiles
+}
+
+// splitYAMLDocs returns the non-empty documents in a yaml stream
+func splitYAMLDocs(content []byte) [][]byte {
+	docs := [][]byte{}
+	for _, doc := range yamlDocSeparator.Split(string(content), -1) {
+		if isEmptyYAMLDoc(doc) {
+			continue
+		}
+		docs = append(docs, []byte(strings.TrimLeft(doc, "\n")))
+	}
+	return docs
+}
+
+func isEmptyYAMLDoc(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// splitFilePath returns a stable file name for the object, adding the namespace
+// and then a counter when objects of the same kind and name already exist
+func splitFilePath(dir string, doc splitDoc, usedPaths map[string]bool) string {
+	name := fmt.Sprintf("%s-%s", doc.Kind, doc.Metadata.Name)
+	filePath := path.Join(dir, safeFilename(name)+".yaml")
+	if !usedPaths[filePath] {
+		return filePath
+	}
+
+	if doc.Metadata.Namespace != "" {
+		name = fmt.Sprintf("%s-%s", name, doc.Metadata.Namespace)
+		filePath = path.Join(dir, safeFilename(name)+".yaml")
+		if !usedPaths[filePath] {
+			return filePath
+		}
+	}
+
+	for i := 2; ; i++ {
+		filePath = path.Join(dir, fmt.Sprintf("%s-%d.yaml", safeFilename(name), i))
+		if !usedPaths[filePath] {
+			return filePath
+		}
+	}
+}
+
+func safeFilename(name string) string {
+	return strings.Trim(unsafeFilename.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func withTrailingNewline(content []byte) []byte {
+	if bytes.HasSuffix(content, []byte("\n")) {
+		return content
+	}
+	return append(content, '\n')
+}
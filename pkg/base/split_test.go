@@ -0,0 +1,70 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitMultiDocYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []BaseFile
+		expected []BaseFile
+	}{
+		{
+			name: "single doc is not changed",
+			files: []BaseFile{
+				{Path: "deployment.yaml", Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n")},
+			},
+			expected: []BaseFile{
+				{Path: "deployment.yaml", Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n")},
+			},
+		},
+		{
+			name: "multi doc is split by kind and name",
+			files: []BaseFile{
+				{Path: "templates/all.yaml", Content: []byte(`---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: other
+---
+`)},
+			},
+			expected: []BaseFile{
+				{Path: "templates/deployment-app.yaml", Content: []byte("# Source: app/templates/deployment.yaml\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n")},
+				{Path: "templates/service-app.yaml", Content: []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: app\n")},
+				{Path: "templates/service-app-other.yaml", Content: []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: app\n  namespace: other\n")},
+			},
+		},
+		{
+			name: "multi doc with an unnamed object is not changed",
+			files: []BaseFile{
+				{Path: "all.yaml", Content: []byte("kind: Service\nmetadata:\n  name: app\n---\nkind: Service\n")},
+			},
+			expected: []BaseFile{
+				{Path: "all.yaml", Content: []byte("kind: Service\nmetadata:\n  name: app\n---\nkind: Service\n")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := splitMultiDocYAML(test.files)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+type ValidateOptions struct {
+	ExcludeKotsKinds bool
+
+	// Discovery is optional. When set, kinds that are not served by the cluster are
+	// reported, unless a CustomResourceDefinition for them is part of the base.
+	Discovery discovery.DiscoveryInterface
+}
+
+// ValidationError is a problem with one object in a base file. Line is the line in the
+// file that the object, or the yaml error, starts on. Warnings are problems that don't stop
+// the object from being applied by a cluster, e.g. fields that are newer than the kinds that
+// kots knows about.
+type ValidationError struct {
+	Path    string
+	Line    int
+	Message string
+	Warning bool
+}
+
+func (e ValidationError) Error() string {
+	if e.Warning {
+		return fmt.Sprintf("%s:%d: warning: %s", e.Path, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+}
+
+// ValidationErrors is returned when a base has invalid objects
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d invalid objects in base:\n%s", len(e), strings.Join(messages, "\n"))
+}
+
+// Errors returns the validation errors that are not warnings
+func (e ValidationErrors) Errors() ValidationErrors {
+	errs := ValidationErrors{}
+	for _, err := range e {
+		if !err.Warning {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Warnings returns the validation errors that are only warnings
+func (e ValidationErrors) Warnings() ValidationErrors {
+	warnings := ValidationErrors{}
+	for _, err := range e {
+		if err.Warning {
+			warnings = append(warnings, err)
+		}
+	}
+	return warnings
+}
+
+type validationDoc struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name string `json:"name"`
+		} `json:"versions"`
+		Version string `json:"version"`
+	} `json:"spec"`
+}
+
+// Validate checks every object that will be included in the kustomization: that it's valid
+// yaml with an apiVersion, kind and name, and for built in kinds that it decodes without type
+// errors. Fields that the built in kinds don't have are returned as warnings, since the cluster
+// can be newer than the kinds that kots is built with. All problems are returned, not only the
+// first one.
+func (b *Base) Validate(options ValidateOptions) (ValidationErrors, error) {
+	validationErrors := ValidationErrors{}
+	type docRef struct {
+		path string
+		line int
+		gvk  schema.GroupVersionKind
+	}
+	docRefs := []docRef{}
+	crdKinds := map[schema.GroupVersionKind]bool{}
+
+	for _, file := range b.Files {
+		if !file.ShouldBeIncludedInBaseKustomization(options.ExcludeKotsKinds) {
+			continue
+		}
+
+		line := 1
+		for _, doc := range bytes.Split(file.Content, []byte("\n---\n")) {
+			docLine := line
+			line += bytes.Count(doc, []byte("\n")) + 2
+
+			if isEmptyYAMLDoc(string(doc)) {
+				continue
+			}
+
+			gvk, docErrors := validateDoc(doc)
+			for _, docError := range docErrors {
+				docError.Path = file.Path
+				docError.Line += docLine
+				validationErrors = append(validationErrors, docError)
+			}
+			if gvk == nil {
+				continue
+			}
+
+			docRefs = append(docRefs, docRef{path: file.Path, line: docLine, gvk: *gvk})
+			for _, crdGVK := range customResourceKinds(doc) {
+				crdKinds[crdGVK] = true
+			}
+		}
+	}
+
+	if options.Discovery != nil {
+		served, err := servedKinds(options.Discovery)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list kinds served by the cluster")
+		}
+		for _, ref := range docRefs {
+			if served[ref.gvk] || crdKinds[ref.gvk] {
+				continue
+			}
+			validationErrors = append(validationErrors, ValidationError{
+				Path:    ref.path,
+				Line:    ref.line,
+				Message: fmt.Sprintf("%s %s is not available in the cluster", ref.gvk.GroupVersion().String(), ref.gvk.Kind),
+			})
+		}
+	}
+
+	return validationErrors, nil
+}
+
+// validateDoc returns the kind of the doc and its problems, with lines relative to the start of the doc
+func validateDoc(doc []byte) (*schema.GroupVersionKind, []ValidationError) {
+	jsonDoc, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		line := 0
+		if matches := yamlErrorLine.FindStringSubmatch(err.Error()); len(matches) == 2 {
+			l, _ := strconv.Atoi(matches[1])
+			line = l - 1
+		}
+		return nil, []ValidationError{{Line: line, Message: fmt.Sprintf("invalid yaml: %s", err)}}
+	}
+
+	parsed := validationDoc{}
+	if err := json.Unmarshal(jsonDoc, &parsed); err != nil {
+		return nil, []ValidationError{{Message: fmt.Sprintf("invalid object: %s", err)}}
+	}
+
+	validationErrors := []ValidationError{}
+	if parsed.APIVersion == "" {
+		validationErrors = append(validationErrors, ValidationError{Message: "missing apiVersion"})
+	}
+	if parsed.Kind == "" {
+		validationErrors = append(validationErrors, ValidationError{Message: "missing kind"})
+	}
+	if parsed.Metadata.Name == "" && !strings.HasSuffix(parsed.Kind, "List") {
+		validationErrors = append(validationErrors, ValidationError{Message: "missing metadata.name"})
+	}
+	if len(validationErrors) > 0 {
+		return nil, validationErrors
+	}
+
+	gv, err := schema.ParseGroupVersion(parsed.APIVersion)
+	if err != nil {
+		return nil, []ValidationError{{Message: fmt.Sprintf("invalid apiVersion: %s", err)}}
+	}
+	gvk := gv.WithKind(parsed.Kind)
+
+	// only kinds that are known to kots can be checked for structure
+	if !scheme.Scheme.Recognizes(gvk) {
+		return &gvk, nil
+	}
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+	if err != nil {
+		return &gvk, []ValidationError{{Message: fmt.Sprintf("invalid %s: %s", parsed.Kind, err)}}
+	}
+
+	decoded, err := json.Marshal(obj)
+	if err != nil {
+		return &gvk, []ValidationError{{Message: fmt.Sprintf("failed to marshal %s: %s", parsed.Kind, err)}}
+	}
+
+	original, roundTripped := map[string]interface{}{}, map[string]interface{}{}
+	if err := json.Unmarshal(jsonDoc, &original); err != nil {
+		return &gvk, nil
+	}
+	if err := json.Unmarshal(decoded, &roundTripped); err != nil {
+		return &gvk, nil
+	}
+
+	for _, field := range unknownFields("", original, roundTripped) {
+		validationErrors = append(validationErrors, ValidationError{
+			Message: fmt.Sprintf("unknown field %q in %s", field, parsed.Kind),
+			Warning: true,
+		})
+	}
+
+	return &gvk, validationErrors
+}
+
+// unknownFields returns the paths of fields that are set in the original object but were
+// dropped when decoding into the typed object. Fields with zero values are ignored since
+// they are also dropped when they have omitempty.
+func unknownFields(prefix string, original interface{}, decoded interface{}) []string {
+	fields := []string{}
+
+	switch o := original.(type) {
+	case map[string]interface{}:
+		d, _ := decoded.(map[string]interface{})
+		keys := []string{}
+		for k := range o {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fieldPath := k
+			if prefix != "" {
+				fieldPath = prefix + "." + k
+			}
+
+			dv, ok := d[k]
+			if !ok {
+				if !isZeroValue(o[k]) {
+					fields = append(fields, fieldPath)
+				}
+				continue
+			}
+			fields = append(fields, unknownFields(fieldPath, o[k], dv)...)
+		}
+
+	case []interface{}:
+		d, _ := decoded.([]interface{})
+		for i := range o {
+			if i >= len(d) {
+				break
+			}
+			fields = append(fields, unknownFields(fmt.Sprintf("%s[%d]", prefix, i), o[i], d[i])...)
+		}
+	}
+
+	return fields
+}
+
+func isZeroValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// customResourceKinds returns the kinds defined by a CustomResourceDefinition
+func customResourceKinds(doc []byte) []schema.GroupVersionKind {
+	parsed := validationDoc{}
+	if err := yaml.Unmarshal(doc, &parsed); err != nil {
+		return nil
+	}
+	if parsed.Kind != "CustomResourceDefinition" {
+		return nil
+	}
+
+	kinds := []schema.GroupVersionKind{}
+	if parsed.Spec.Version != "" {
+		kinds = append(kinds, schema.GroupVersionKind{Group: parsed.Spec.Group, Version: parsed.Spec.Version, Kind: parsed.Spec.Names.Kind})
+	}
+	for _, version := range parsed.Spec.Versions {
+		kinds = append(kinds, schema.GroupVersionKind{Group: parsed.Spec.Group, Version: version.Name, Kind: parsed.Spec.Names.Kind})
+	}
+	return kinds
+}
+
+func servedKinds(d discovery.DiscoveryInterface) (map[schema.GroupVersionKind]bool, error) {
+	resourceLists, err := d.ServerResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, errors.Wrap(err, "failed to get server resources")
+	}
+
+	served := map[schema.GroupVersionKind]bool{}
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			served[gv.WithKind(resource.Kind)] = true
+		}
+	}
+	return served, nil
+}
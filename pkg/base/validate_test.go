@@ -0,0 +1,182 @@
+package base
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []BaseFile
+		expected ValidationErrors
+	}{
+		{
+			name: "valid deployment",
+			files: []BaseFile{
+				{
+					Path: "deployment.yaml",
+					Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+  template:
+    spec:
+      hostNetwork: false
+      containers:
+        - name: app
+          image: nginx
+          resources:
+            limits:
+              cpu: 1
+              memory: 1Gi
+`),
+				},
+			},
+			expected: ValidationErrors{},
+		},
+		{
+			name: "unknown fields and type errors",
+			files: []BaseFile{
+				{
+					Path: "deployment.yaml",
+					Content: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          imagePullPolice: Always
+`),
+				},
+				{
+					Path: "service.yaml",
+					Content: []byte(`apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  ports:
+    - port: eighty
+`),
+				},
+			},
+			expected: ValidationErrors{
+				{Path: "deployment.yaml", Line: 6, Message: `unknown field "spec.template.spec.containers[0].imagePullPolice" in Deployment`, Warning: true},
+				{Path: "service.yaml", Line: 1, Message: "invalid Service"},
+			},
+		},
+		{
+			name: "invalid yaml and missing name",
+			files: []BaseFile{
+				{
+					Path: "configmap.yaml",
+					Content: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    app: test
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  key: [value
+`),
+				},
+			},
+			expected: ValidationErrors{
+				{Path: "configmap.yaml", Line: 1, Message: "missing metadata.name"},
+				{Path: "configmap.yaml", Line: 12, Message: "invalid yaml: yaml: line 6: did not find expected ',' or ']'"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := Base{Files: test.files}
+			actual, err := b.Validate(ValidateOptions{})
+			require.NoError(t, err)
+			require.Len(t, actual, len(test.expected))
+
+			// decoding errors include the position in the json, only the start of the message is compared
+			for i, expected := range test.expected {
+				assert.Equal(t, expected.Path, actual[i].Path)
+				assert.Equal(t, expected.Line, actual[i].Line)
+				assert.Equal(t, expected.Warning, actual[i].Warning)
+				assert.True(t, strings.HasPrefix(actual[i].Message, expected.Message), actual[i].Message)
+			}
+		})
+	}
+}
+
+func Test_ValidateWithDiscovery(t *testing.T) {
+	clientset := fakeclientset.NewSimpleClientset()
+	discovery := clientset.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "configmaps", Kind: "ConfigMap"}},
+		},
+	}
+
+	b := Base{
+		Files: []BaseFile{
+			{
+				Path: "crd.yaml",
+				Content: []byte(`apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  version: v1
+  names:
+    kind: Widget
+`),
+			},
+			{
+				Path: "resources.yaml",
+				Content: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: gadget
+`),
+			},
+		},
+	}
+
+	actual, err := b.Validate(ValidateOptions{Discovery: discovery})
+	require.NoError(t, err)
+	assert.Equal(t, ValidationErrors{
+		{Path: "crd.yaml", Line: 1, Message: "apiextensions.k8s.io/v1beta1 CustomResourceDefinition is not available in the cluster"},
+		{Path: "resources.yaml", Line: 11, Message: "example.com/v1 Gadget is not available in the cluster"},
+	}, actual)
+}
@@ -0,0 +1,65 @@
+package base
+
+import (
+	"bytes"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// WhenAnnotation includes an object in the base only when its rendered value is true, e.g.
+// '{{repl ConfigOptionEquals "database" "embedded" }}'. Objects without it are always included.
+const WhenAnnotation = "kots.io/when"
+
+type whenDoc struct {
+	Metadata struct {
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+}
+
+// excludeDocsWhenFalse removes the documents whose WhenAnnotation is false from a rendered file. It
+// returns false when all of the documents were removed, so that the file can be left out.
+func excludeDocsWhenFalse(content []byte) ([]byte, bool) {
+	docs := splitYAMLDocs(content)
+	if len(docs) == 0 {
+		return content, true
+	}
+
+	included := [][]byte{}
+	for _, doc := range docs {
+		if isIncludedWhen(doc) {
+			included = append(included, doc)
+		}
+	}
+
+	if len(included) == len(docs) {
+		return content, true
+	}
+	if len(included) == 0 {
+		return nil, false
+	}
+
+	for i, doc := range included {
+		included[i] = withTrailingNewline(doc)
+	}
+	return bytes.Join(included, []byte("---\n")), true
+}
+
+func isIncludedWhen(doc []byte) bool {
+	parsed := whenDoc{}
+	if err := yaml.Unmarshal(doc, &parsed); err != nil {
+		return true
+	}
+
+	when, ok := parsed.Metadata.Annotations[WhenAnnotation]
+	if !ok {
+		return true
+	}
+
+	// values that aren't booleans, e.g. templates that failed to render, keep the object
+	include, err := strconv.ParseBool(when)
+	if err != nil {
+		return true
+	}
+	return include
+}
@@ -0,0 +1,60 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_excludeDocsWhenFalse(t *testing.T) {
+	included := "apiVersion: v1\nkind: Service\nmetadata:\n  name: included\n  annotations:\n    kots.io/when: \"true\"\n"
+	excluded := "apiVersion: v1\nkind: Service\nmetadata:\n  name: excluded\n  annotations:\n    kots.io/when: \"false\"\n"
+	unannotated := "apiVersion: v1\nkind: Service\nmetadata:\n  name: unannotated\n"
+
+	tests := []struct {
+		name             string
+		content          string
+		expectedContent  string
+		expectedIncluded bool
+	}{
+		{
+			name:             "included",
+			content:          included,
+			expectedContent:  included,
+			expectedIncluded: true,
+		},
+		{
+			name:             "excluded",
+			content:          excluded,
+			expectedIncluded: false,
+		},
+		{
+			name:             "excluded doc is removed from the file",
+			content:          unannotated + "---\n" + excluded + "---\n" + included,
+			expectedContent:  unannotated + "---\n" + included,
+			expectedIncluded: true,
+		},
+		{
+			name:             "not a boolean",
+			content:          "apiVersion: v1\nkind: Service\nmetadata:\n  name: svc\n  annotations:\n    kots.io/when: maybe\n",
+			expectedContent:  "apiVersion: v1\nkind: Service\nmetadata:\n  name: svc\n  annotations:\n    kots.io/when: maybe\n",
+			expectedIncluded: true,
+		},
+		{
+			name:             "not yaml",
+			content:          "# notes\n{{ not yaml",
+			expectedContent:  "# notes\n{{ not yaml",
+			expectedIncluded: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, included := excludeDocsWhenFalse([]byte(test.content))
+			assert.Equal(t, test.expectedIncluded, included)
+			if test.expectedIncluded {
+				assert.Equal(t, test.expectedContent, string(content))
+			}
+		})
+	}
+}
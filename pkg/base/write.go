@@ -2,12 +2,13 @@ package base
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/util"
 	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
 )
 
@@ -15,6 +16,8 @@ type WriteOptions struct {
 	BaseDir          string
 	Overwrite        bool
 	ExcludeKotsKinds bool
+	// FileModes are the modes that the base is written with, and whether files are written atomically
+	FileModes util.FileModes
 }
 
 func (b *Base) WriteBase(options WriteOptions) error {
@@ -41,19 +44,11 @@ func (b *Base) WriteBase(options WriteOptions) error {
 		}
 
 		if writeToKustomization {
-			kustomizeResources = append(kustomizeResources, path.Join(".", file.Path))
+			kustomizeResources = append(kustomizeResources, util.SanitizeFilePath(path.Join(".", file.Path)))
 		}
 
 		if writeToBase {
-			fileRenderPath := path.Join(renderDir, file.Path)
-			d, _ := path.Split(fileRenderPath)
-			if _, err := os.Stat(d); os.IsNotExist(err) {
-				if err := os.MkdirAll(d, 0744); err != nil {
-					return errors.Wrap(err, "failed to mkdir")
-				}
-			}
-
-			if err := ioutil.WriteFile(fileRenderPath, file.Content, 0644); err != nil {
+			if _, err := util.WriteFileWithModes(renderDir, file.Path, file.Content, options.FileModes); err != nil {
 				return errors.Wrap(err, "failed to write base file")
 			}
 		}
@@ -67,7 +62,7 @@ func (b *Base) WriteBase(options WriteOptions) error {
 		Resources: kustomizeResources,
 	}
 
-	if err := k8sutil.WriteKustomizationToFile(&kustomization, path.Join(renderDir, "kustomization.yaml")); err != nil {
+	if err := k8sutil.WriteKustomizationToFileWithModes(&kustomization, filepath.Join(renderDir, "kustomization.yaml"), options.FileModes); err != nil {
 		return errors.Wrap(err, "failed to write kustomization to file")
 	}
 
@@ -77,5 +72,5 @@ func (b *Base) WriteBase(options WriteOptions) error {
 func (b *Base) GetOverlaysDir(options WriteOptions) string {
 	renderDir := options.BaseDir
 
-	return path.Join(renderDir, "..", "overlays")
+	return filepath.Join(renderDir, "..", "overlays")
 }
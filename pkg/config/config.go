@@ -12,7 +12,20 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
+type TemplateConfigOptions struct {
+	// EnableDNSLookups makes DnsLookup and DnsTxt resolve names. These make network
+	// requests, so they should only be enabled when validating the config screen.
+	EnableDNSLookups bool
+
+	// EnvPrefixes are the prefixes of environment variables that GetEnv is allowed to read
+	EnvPrefixes []string
+}
+
 func TemplateConfig(log *logger.Logger, configSpecData string, configValuesData string) (string, error) {
+	return TemplateConfigWithOptions(log, configSpecData, configValuesData, TemplateConfigOptions{})
+}
+
+func TemplateConfigWithOptions(log *logger.Logger, configSpecData string, configValuesData string, options TemplateConfigOptions) (string, error) {
 	// This function will
 	// 1. unmarshal config
 	// 2. replace all item values with values that already exist
@@ -29,6 +42,12 @@ func TemplateConfig(log *logger.Logger, configSpecData string, configValuesData
 
 	builder := template.Builder{}
 	builder.AddCtx(template.StaticCtx{})
+	if options.EnableDNSLookups {
+		builder.AddCtx(template.DNSCtx{})
+	}
+	if len(options.EnvPrefixes) > 0 {
+		builder.AddCtx(template.EnvCtx{AllowedPrefixes: options.EnvPrefixes})
+	}
 
 	// get template context from config values
 	templateContext, err := base.UnmarshalConfigValuesContent([]byte(configValuesData))
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// ConfigItemError is an item whose value doesn't pass its validation rules
+type ConfigItemError struct {
+	Group   string `json:"group"`
+	Item    string `json:"item"`
+	Message string `json:"message"`
+}
+
+func (e ConfigItemError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Item, e.Message)
+}
+
+// ValidateConfig renders the config with the values, and checks the items that are shown against
+// their validation rules
+func ValidateConfig(log *logger.Logger, configSpecData string, configValuesData string, options TemplateConfigOptions) ([]ConfigItemError, error) {
+	rendered, err := TemplateConfigWithOptions(log, configSpecData, configValuesData, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render config")
+	}
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	obj, _, err := decode([]byte(rendered), nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode rendered config")
+	}
+	config, ok := obj.(*kotsv1beta1.Config)
+	if !ok {
+		return nil, errors.Errorf("rendered config is a %T", obj)
+	}
+
+	return validateItems(config), nil
+}
+
+func validateItems(config *kotsv1beta1.Config) []ConfigItemError {
+	items := map[string]kotsv1beta1.ConfigItem{}
+	for _, group := range config.Spec.Groups {
+		for _, item := range group.Items {
+			items[item.Name] = item
+		}
+	}
+
+	itemErrors := []ConfigItemError{}
+	for _, group := range config.Spec.Groups {
+		for _, item := range group.Items {
+			if item.Hidden || item.When == "false" {
+				continue
+			}
+
+			for _, message := range validateItem(item, items) {
+				itemErrors = append(itemErrors, ConfigItemError{
+					Group:   group.Name,
+					Item:    item.Name,
+					Message: message,
+				})
+			}
+		}
+	}
+
+	return itemErrors
+}
+
+func validateItem(item kotsv1beta1.ConfigItem, items map[string]kotsv1beta1.ConfigItem) []string {
+	value := itemValue(item)
+
+	messages := []string{}
+	if item.Required && value == "" {
+		messages = append(messages, "a value is required")
+	}
+
+	validation := item.Validation
+	if validation == nil {
+		return messages
+	}
+
+	if validation.DisallowDefault && item.Default != "" && value == item.Default {
+		messages = append(messages, "the default value must be changed")
+	}
+
+	if validation.Matches != "" {
+		other, ok := items[validation.Matches]
+		if !ok {
+			messages = append(messages, fmt.Sprintf("must match item %q, which does not exist", validation.Matches))
+		} else if value != itemValue(other) {
+			messages = append(messages, fmt.Sprintf("does not match %s", itemTitle(other)))
+		}
+	}
+
+	// length and complexity are not checked for empty values, which are caught by required
+	if value == "" {
+		return messages
+	}
+
+	if validation.MinLength > 0 && len([]rune(value)) < validation.MinLength {
+		messages = append(messages, fmt.Sprintf("must be at least %d characters", validation.MinLength))
+	}
+
+	if validation.ComplexityClasses > 0 && complexityClasses(value) < validation.ComplexityClasses {
+		messages = append(messages, fmt.Sprintf("must contain at least %d of lowercase letters, uppercase letters, digits and symbols", validation.ComplexityClasses))
+	}
+
+	return messages
+}
+
+// itemValue returns the value of an item that the values have been applied to, or its default
+func itemValue(item kotsv1beta1.ConfigItem) string {
+	if item.Value != "" {
+		return item.Value
+	}
+	return item.Default
+}
+
+func itemTitle(item kotsv1beta1.ConfigItem) string {
+	if item.Title != "" {
+		return item.Title
+	}
+	return item.Name
+}
+
+// complexityClasses returns how many of lowercase letters, uppercase letters, digits and symbols
+// are in value
+func complexityClasses(value string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range value {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, symbol} {
+		if has {
+			classes++
+		}
+	}
+	return classes
+}
+
+// ConfigItemErrorsToString returns the errors on separate lines, to report them from the cli
+func ConfigItemErrorsToString(itemErrors []ConfigItemError) string {
+	messages := []string{}
+	for _, itemError := range itemErrors {
+		messages = append(messages, itemError.Error())
+	}
+	return strings.Join(messages, "\n")
+}
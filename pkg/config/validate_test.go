@@ -0,0 +1,105 @@
+package config
+
+import (
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/stretchr/testify/assert"
+)
+
+// renderedConfig returns a config as it is after TemplateConfig, with the values applied to the items
+func renderedConfig(password string, passwordConfirm string, ldapEnabled bool) *kotsv1beta1.Config {
+	when := "false"
+	if ldapEnabled {
+		when = "true"
+	}
+
+	return &kotsv1beta1.Config{
+		Spec: kotsv1beta1.ConfigSpec{
+			Groups: []kotsv1beta1.ConfigGroup{
+				{
+					Name: "admin",
+					Items: []kotsv1beta1.ConfigItem{
+						{
+							Name:    "password",
+							Title:   "Password",
+							Type:    "password",
+							Default: "changeme",
+							Value:   password,
+							Validation: &kotsv1beta1.ConfigItemValidation{
+								MinLength:         8,
+								ComplexityClasses: 3,
+								DisallowDefault:   true,
+							},
+						},
+						{
+							Name:  "password_confirm",
+							Title: "Confirm Password",
+							Type:  "password",
+							Value: passwordConfirm,
+							Validation: &kotsv1beta1.ConfigItemValidation{
+								Matches: "password",
+							},
+						},
+						{
+							Name:     "ldap_password",
+							Type:     "password",
+							Required: true,
+							When:     when,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func Test_validateItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *kotsv1beta1.Config
+		expected []ConfigItemError
+	}{
+		{
+			name:     "valid",
+			config:   renderedConfig("Secret-pass1", "Secret-pass1", false),
+			expected: []ConfigItemError{},
+		},
+		{
+			name:   "default password",
+			config: renderedConfig("", "changeme", false),
+			expected: []ConfigItemError{
+				{Group: "admin", Item: "password", Message: "the default value must be changed"},
+				{Group: "admin", Item: "password", Message: "must contain at least 3 of lowercase letters, uppercase letters, digits and symbols"},
+			},
+		},
+		{
+			name:   "short password that does not match",
+			config: renderedConfig("Ab1!", "Ab1?", false),
+			expected: []ConfigItemError{
+				{Group: "admin", Item: "password", Message: "must be at least 8 characters"},
+				{Group: "admin", Item: "password_confirm", Message: "does not match Password"},
+			},
+		},
+		{
+			name:   "required item that is shown",
+			config: renderedConfig("Secret-pass1", "Secret-pass1", true),
+			expected: []ConfigItemError{
+				{Group: "admin", Item: "ldap_password", Message: "a value is required"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, validateItems(test.config))
+		})
+	}
+}
+
+func Test_complexityClasses(t *testing.T) {
+	assert.Equal(t, 1, complexityClasses("password"))
+	assert.Equal(t, 2, complexityClasses("Password"))
+	assert.Equal(t, 3, complexityClasses("Password1"))
+	assert.Equal(t, 4, complexityClasses("Pass word1!"))
+}
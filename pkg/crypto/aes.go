@@ -109,3 +109,25 @@ func (c *AESCipher) Decrypt(in []byte) (result []byte, err error) {
 	result, err = c.cipher.Open(nil, c.nonce, in, nil)
 	return
 }
+
+// Seal encrypts with a new random nonce that is prepended to the output, for content that is
+// encrypted more than once with the same key, e.g. a file that is written again on every pull.
+// Encrypt always uses the nonce of the key.
+func (c *AESCipher) Seal(in []byte) ([]byte, error) {
+	nonce := make([]byte, c.cipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to read nonce")
+	}
+
+	return c.cipher.Seal(nonce, nonce, in, nil), nil
+}
+
+// Open decrypts content that was encrypted with Seal
+func (c *AESCipher) Open(in []byte) ([]byte, error) {
+	nonceSize := c.cipher.NonceSize()
+	if len(in) < nonceSize {
+		return nil, errors.New("encrypted content is too short")
+	}
+
+	return c.cipher.Open(nil, in[:nonceSize], in[nonceSize:], nil)
+}
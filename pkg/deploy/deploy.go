@@ -0,0 +1,312 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/diff"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// AppLabel is set on every object that's deployed, its value is the slug of the app. Objects
+	// are only pruned when they still have it.
+	AppLabel = "kots.io/app"
+
+	defaultTimeout = 5 * time.Minute
+)
+
+// actions of the objects in the result
+const (
+	ActionCreated    = "created"
+	ActionConfigured = "configured"
+	ActionUnchanged  = "unchanged"
+	ActionPruned     = "pruned"
+)
+
+// mappingRetries is how many times a kind that isn't served yet is looked up again after custom
+// resource definitions are applied, they take a moment to be served
+var mappingRetries = 15
+
+// resettableMapper is a mapper that caches discovery, like the mapper of the cluster clients
+type resettableMapper interface {
+	Reset()
+}
+
+type DeployOptions struct {
+	// AppSlug is the value of the AppLabel and names the inventory of the deployed objects
+	AppSlug string
+	// Kubectl is the kubectl executable that builds the overlay, defaults to kubectl in the path
+	Kubectl string
+	// Prune deletes the objects that were deployed before and aren't in the overlay anymore
+	Prune bool
+	// Wait waits for deployments and statefulsets to roll out and for jobs to complete
+	Wait bool
+	// Timeout of waiting, defaults to 5 minutes
+	Timeout time.Duration
+}
+
+// ObjectResult is an object that was deployed or pruned. Message says why an object isn't ready.
+type ObjectResult struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	Action     string
+	Message    string
+}
+
+func (r ObjectResult) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s in %s", r.Kind, r.Name, r.Namespace)
+}
+
+// Result of a deploy. NotReady are the objects that didn't roll out or complete before the
+// timeout, or that failed.
+type Result struct {
+	Applied  []ObjectResult
+	Pruned   []ObjectResult
+	NotReady []ObjectResult
+}
+
+// Ready returns true if all of the objects that were waited for are ready
+func (r Result) Ready() bool {
+	return len(r.NotReady) == 0
+}
+
+// Deploy builds the overlay and applies its objects to the cluster of the kubeconfig, or of the
+// current context when kubeconfig is nil, like kubectl apply. The objects are labeled with the
+// AppLabel and saved in an inventory, so that the ones that are removed from the overlay can be
+// pruned by the next deploy. Hooks are applied in order of phase and weight, and the jobs of each
+// hook have to complete before the objects after it are applied.
+func Deploy(overlayDir string, kubeconfig []byte, options DeployOptions) (*Result, error) {
+	if errs := validation.IsValidLabelValue(options.AppSlug); options.AppSlug == "" || len(errs) > 0 {
+		return nil, errors.Errorf("invalid app slug %q", options.AppSlug)
+	}
+
+	manifests, err := diff.Build(overlayDir, options.Kubectl)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := k8sutil.DecodeObjects(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := k8sutil.GetClusterClients(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return deployObjects(objs, clients, options)
+}
+
+func deployObjects(objs []*unstructured.Unstructured, clients *k8sutil.ClusterClients, options DeployOptions) (*Result, error) {
+	result := &Result{
+		Applied:  []ObjectResult{},
+		Pruned:   []ObjectResult{},
+		NotReady: []ObjectResult{},
+	}
+
+	previous, err := getInventory(clients, options.AppSlug)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get inventory")
+	}
+
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	applied := []*unstructured.Unstructured{}
+	for _, group := range applyGroups(orderForApply(objs)) {
+		for _, obj := range group {
+			action, err := applyObject(clients, obj, options.AppSlug)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to apply %s %s", obj.GetKind(), obj.GetName())
+			}
+			result.Applied = append(result.Applied, objectResult(obj, action))
+			applied = append(applied, obj)
+		}
+
+		if positionOf(group[0]).HookPhase != "" {
+			if err := waitForHookJobs(clients, group, timeout); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	current := inventoryOf(applied)
+	if options.Prune {
+		pruned, err := pruneObjects(clients, previous, current, options.AppSlug)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to prune objects")
+		}
+		result.Pruned = pruned
+	} else {
+		// objects that weren't pruned stay in the inventory so that a later deploy can prune them
+		current = mergeInventories(current, previous)
+	}
+
+	if err := saveInventory(clients, options.AppSlug, current); err != nil {
+		return nil, errors.Wrap(err, "failed to save inventory")
+	}
+
+	if options.Wait {
+		notReady, err := waitForObjects(clients, applied, timeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to wait for objects")
+		}
+		result.NotReady = notReady
+	}
+
+	return result, nil
+}
+
+// applyObject creates the object, or patches it with a three way patch like kubectl apply, and
+// returns what was done
+func applyObject(clients *k8sutil.ClusterClients, obj *unstructured.Unstructured, appSlug string) (string, error) {
+	mapping, err := restMapping(clients, obj)
+	if err != nil {
+		return "", err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && obj.GetNamespace() == "" {
+		obj.SetNamespace(clients.Namespace)
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[AppLabel] = appSlug
+	obj.SetLabels(labels)
+
+	modified, err := k8sutil.WithLastAppliedConfig(obj)
+	if err != nil {
+		return "", err
+	}
+
+	resourceClient := clients.Dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+	live, err := resourceClient.Get(obj.GetName(), metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		if _, err := resourceClient.Create(modified, metav1.CreateOptions{}); err != nil {
+			return "", errors.Wrap(err, "failed to create")
+		}
+		return ActionCreated, nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get live object")
+	}
+
+	patchType, patch, err := k8sutil.ThreeWayPatch(live, modified)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create patch")
+	}
+	if string(patch) == "{}" {
+		return ActionUnchanged, nil
+	}
+	if _, err := resourceClient.Patch(obj.GetName(), patchType, patch, metav1.PatchOptions{}); err != nil {
+		return "", errors.Wrap(err, "failed to patch")
+	}
+	return ActionConfigured, nil
+}
+
+// restMapping returns the resource of the object. Kinds of custom resource definitions that were
+// just applied are looked up again until they're served.
+func restMapping(clients *k8sutil.ClusterClients, obj *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	gvk := obj.GroupVersionKind()
+	for i := 0; ; i++ {
+		mapping, err := clients.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err == nil {
+			return mapping, nil
+		}
+
+		resettable, ok := clients.Mapper.(resettableMapper)
+		if !meta.IsNoMatchError(err) || !ok || i >= mappingRetries {
+			return nil, errors.Wrapf(err, "failed to find resource of %s", gvk)
+		}
+		time.Sleep(pollInterval)
+		resettable.Reset()
+	}
+}
+
+// orderForApply returns the objects in the order they're deployed, by hook phase and weight and
+// then by kind, so that namespaces and custom resource definitions are created before the objects
+// in them. The order of objects at the same position is kept.
+func orderForApply(objs []*unstructured.Unstructured) []*unstructured.Unstructured {
+	ordered := append([]*unstructured.Unstructured{}, objs...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return positionOf(ordered[i]).Less(positionOf(ordered[j]))
+	})
+	return ordered
+}
+
+// applyGroups splits the ordered objects into the groups that are applied together: the hooks of
+// each phase and weight, and the objects without a hook
+func applyGroups(ordered []*unstructured.Unstructured) [][]*unstructured.Unstructured {
+	groups := [][]*unstructured.Unstructured{}
+	for i, obj := range ordered {
+		if i > 0 && sameHook(positionOf(ordered[i-1]), positionOf(obj)) {
+			groups[len(groups)-1] = append(groups[len(groups)-1], obj)
+			continue
+		}
+		groups = append(groups, []*unstructured.Unstructured{obj})
+	}
+	return groups
+}
+
+func sameHook(a k8sdoc.Position, b k8sdoc.Position) bool {
+	if a.HookPhase == "" || b.HookPhase == "" {
+		return a.HookPhase == b.HookPhase
+	}
+	return a.HookPhase == b.HookPhase && a.HookWeight == b.HookWeight
+}
+
+// waitForHookJobs waits for the jobs of a hook to complete, so that the objects after the hook
+// are applied once e.g. the migrations have run. A hook job that fails or doesn't complete before
+// the timeout stops the deploy.
+func waitForHookJobs(clients *k8sutil.ClusterClients, hooks []*unstructured.Unstructured, timeout time.Duration) error {
+	jobs := []*unstructured.Unstructured{}
+	for _, obj := range hooks {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group == "batch" && gvk.Kind == "Job" {
+			jobs = append(jobs, obj)
+		}
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	notReady, err := waitForObjects(clients, jobs, timeout)
+	if err != nil {
+		return errors.Wrap(err, "failed to wait for hook jobs")
+	}
+	if len(notReady) > 0 {
+		return errors.Errorf("%s hook %s did not complete: %s", notReady[0].Kind, notReady[0].Name, notReady[0].Message)
+	}
+	return nil
+}
+
+func positionOf(obj *unstructured.Unstructured) k8sdoc.Position {
+	return k8sdoc.PositionOf(obj.GetKind(), obj.GetAnnotations())
+}
+
+func objectResult(obj *unstructured.Unstructured, action string) ObjectResult {
+	return ObjectResult{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		Action:     action,
+	}
+}
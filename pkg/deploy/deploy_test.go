@@ -0,0 +1,198 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var widgetResource = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+func testWidget(name string, size int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+		"spec": map[string]interface{}{
+			"size": size,
+		},
+	}}
+}
+
+func testClients(objs ...runtime.Object) *k8sutil.ClusterClients {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gvk.GroupVersion()})
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+
+	return &k8sutil.ClusterClients{
+		Dynamic:   dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objs...),
+		Mapper:    mapper,
+		Namespace: "app",
+	}
+}
+
+func resultNames(results []ObjectResult) []string {
+	names := []string{}
+	for _, r := range results {
+		names = append(names, r.Name+" "+r.Action)
+	}
+	return names
+}
+
+func Test_deployObjects(t *testing.T) {
+	req := require.New(t)
+	clients := testClients()
+	options := DeployOptions{AppSlug: "my-app", Prune: true}
+
+	result, err := deployObjects([]*unstructured.Unstructured{testWidget("a", 1), testWidget("b", 1)}, clients, options)
+	req.NoError(err)
+	assert.Equal(t, []string{"a created", "b created"}, resultNames(result.Applied))
+	assert.Empty(t, result.Pruned)
+
+	a, err := clients.Dynamic.Resource(widgetResource).Namespace("app").Get("a", metav1.GetOptions{})
+	req.NoError(err)
+	assert.Equal(t, "my-app", a.GetLabels()[AppLabel])
+
+	// b is removed from the overlay and a is changed
+	result, err = deployObjects([]*unstructured.Unstructured{testWidget("a", 2)}, clients, options)
+	req.NoError(err)
+	assert.Equal(t, []string{"a configured"}, resultNames(result.Applied))
+	assert.Equal(t, []string{"b pruned"}, resultNames(result.Pruned))
+
+	a, err = clients.Dynamic.Resource(widgetResource).Namespace("app").Get("a", metav1.GetOptions{})
+	req.NoError(err)
+	size, _, _ := unstructured.NestedInt64(a.Object, "spec", "size")
+	assert.Equal(t, int64(2), size)
+	_, err = clients.Dynamic.Resource(widgetResource).Namespace("app").Get("b", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	result, err = deployObjects([]*unstructured.Unstructured{testWidget("a", 2)}, clients, options)
+	req.NoError(err)
+	assert.Equal(t, []string{"a unchanged"}, resultNames(result.Applied))
+	assert.Empty(t, result.Pruned)
+}
+
+func Test_deployObjectsWithoutPrune(t *testing.T) {
+	req := require.New(t)
+	clients := testClients()
+
+	_, err := deployObjects([]*unstructured.Unstructured{testWidget("a", 1), testWidget("b", 1)}, clients, DeployOptions{AppSlug: "my-app"})
+	req.NoError(err)
+
+	// b stays in the inventory when it isn't pruned, so that a later deploy prunes it
+	result, err := deployObjects([]*unstructured.Unstructured{testWidget("a", 1)}, clients, DeployOptions{AppSlug: "my-app"})
+	req.NoError(err)
+	assert.Empty(t, result.Pruned)
+
+	result, err = deployObjects([]*unstructured.Unstructured{testWidget("a", 1)}, clients, DeployOptions{AppSlug: "my-app", Prune: true})
+	req.NoError(err)
+	assert.Equal(t, []string{"b pruned"}, resultNames(result.Pruned))
+}
+
+func Test_pruneObjectsOwnership(t *testing.T) {
+	req := require.New(t)
+
+	// the object was taken over by another app since it was deployed
+	other := testWidget("shared", 1)
+	other.SetNamespace("app")
+	other.SetLabels(map[string]string{AppLabel: "other-app"})
+	clients := testClients(other)
+
+	previous := []objectRef{{APIVersion: "example.com/v1", Kind: "Widget", Namespace: "app", Name: "shared"}}
+	pruned, err := pruneObjects(clients, previous, []objectRef{}, "my-app")
+	req.NoError(err)
+	assert.Empty(t, pruned)
+
+	_, err = clients.Dynamic.Resource(widgetResource).Namespace("app").Get("shared", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func Test_orderForApply(t *testing.T) {
+	namespace := &unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("ns")
+
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1beta1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName("widgets.example.com")
+
+	ordered := orderForApply([]*unstructured.Unstructured{testWidget("a", 1), crd, testWidget("b", 1), namespace})
+	names := []string{}
+	for _, obj := range ordered {
+		names = append(names, obj.GetName())
+	}
+	assert.Equal(t, []string{"ns", "widgets.example.com", "a", "b"}, names)
+}
+
+func testHookJob(name string, phase string, weight string, condition string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name": name,
+			"annotations": map[string]interface{}{
+				k8sdoc.HookAnnotation:       phase,
+				k8sdoc.HookWeightAnnotation: weight,
+			},
+		},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": condition, "status": "True", "message": "backoff limit exceeded"},
+			},
+		},
+	}}
+}
+
+func Test_deployObjectsWithHooks(t *testing.T) {
+	req := require.New(t)
+	clients := testClients()
+	clients.Mapper.(*meta.DefaultRESTMapper).Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, meta.RESTScopeNamespace)
+
+	objs := []*unstructured.Unstructured{
+		testWidget("a", 1),
+		testHookJob("notify", k8sdoc.HookPhasePostInstall, "0", "Complete"),
+		testHookJob("migrate", k8sdoc.HookPhasePreInstall, "5", "Complete"),
+		testHookJob("seed", k8sdoc.HookPhasePreInstall, "-1", "Complete"),
+	}
+
+	groups := applyGroups(orderForApply(objs))
+	groupNames := [][]string{}
+	for _, group := range groups {
+		names := []string{}
+		for _, obj := range group {
+			names = append(names, obj.GetName())
+		}
+		groupNames = append(groupNames, names)
+	}
+	assert.Equal(t, [][]string{{"seed"}, {"migrate"}, {"a"}, {"notify"}}, groupNames)
+
+	result, err := deployObjects(objs, clients, DeployOptions{AppSlug: "my-app"})
+	req.NoError(err)
+	assert.Equal(t, []string{"seed created", "migrate created", "a created", "notify created"}, resultNames(result.Applied))
+
+	// the objects after a hook that failed are not applied
+	clients = testClients()
+	clients.Mapper.(*meta.DefaultRESTMapper).Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, meta.RESTScopeNamespace)
+	objs = []*unstructured.Unstructured{
+		testWidget("a", 1),
+		testHookJob("migrate", k8sdoc.HookPhasePreInstall, "0", "Failed"),
+	}
+	_, err = deployObjects(objs, clients, DeployOptions{AppSlug: "my-app"})
+	req.EqualError(err, "Job hook migrate did not complete: job failed: backoff limit exceeded")
+
+	_, err = clients.Dynamic.Resource(widgetResource).Namespace("app").Get("a", metav1.GetOptions{})
+	assert.Error(t, err)
+}
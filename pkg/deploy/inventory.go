@@ -0,0 +1,184 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const inventoryKey = "objects"
+
+var configMapResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// objectRef is an object in the inventory
+type objectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// inventoryName is the name of the config map, in the namespace of the context, with the objects
+// that the app was deployed with
+func inventoryName(appSlug string) string {
+	return fmt.Sprintf("kots-%s-inventory", appSlug)
+}
+
+func getInventory(clients *k8sutil.ClusterClients, appSlug string) ([]objectRef, error) {
+	configMap, err := clients.Dynamic.Resource(configMapResource).Namespace(clients.Namespace).Get(inventoryName(appSlug), metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return []objectRef{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config map")
+	}
+
+	data, _, err := unstructured.NestedString(configMap.Object, "data", inventoryKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config map")
+	}
+	refs := []objectRef{}
+	if data == "" {
+		return refs, nil
+	}
+	if err := json.Unmarshal([]byte(data), &refs); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal inventory")
+	}
+	return refs, nil
+}
+
+func saveInventory(clients *k8sutil.ClusterClients, appSlug string, refs []objectRef) error {
+	b, err := json.Marshal(refs)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal inventory")
+	}
+
+	configMapClient := clients.Dynamic.Resource(configMapResource).Namespace(clients.Namespace)
+	configMap, err := configMapClient.Get(inventoryName(appSlug), metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      inventoryName(appSlug),
+				"namespace": clients.Namespace,
+			},
+			"data": map[string]interface{}{
+				inventoryKey: string(b),
+			},
+		}}
+		if _, err := configMapClient.Create(configMap, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, "failed to create config map")
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get config map")
+	}
+
+	if err := unstructured.SetNestedField(configMap.Object, string(b), "data", inventoryKey); err != nil {
+		return errors.Wrap(err, "failed to set inventory")
+	}
+	if _, err := configMapClient.Update(configMap, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "failed to update config map")
+	}
+	return nil
+}
+
+func inventoryOf(objs []*unstructured.Unstructured) []objectRef {
+	refs := []objectRef{}
+	for _, obj := range objs {
+		refs = append(refs, objectRef{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		})
+	}
+	return refs
+}
+
+// mergeInventories returns the refs of current followed by the refs of previous that aren't in it
+func mergeInventories(current []objectRef, previous []objectRef) []objectRef {
+	merged := append([]objectRef{}, current...)
+	for _, ref := range previous {
+		if !containsRef(current, ref) {
+			merged = append(merged, ref)
+		}
+	}
+	return merged
+}
+
+// containsRef compares the group and not the version, an object that moved to a new version of
+// its api is the same object
+func containsRef(refs []objectRef, ref objectRef) bool {
+	group := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).Group
+	for _, r := range refs {
+		if r.Kind == ref.Kind && r.Namespace == ref.Namespace && r.Name == ref.Name &&
+			schema.FromAPIVersionAndKind(r.APIVersion, r.Kind).Group == group {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneObjects deletes the objects of the previous inventory that aren't in the current one, in
+// the reverse of the order they were applied in. Objects that don't have the AppLabel of the app
+// anymore were taken over by something else and are left alone, as are objects whose kind isn't
+// served anymore.
+func pruneObjects(clients *k8sutil.ClusterClients, previous []objectRef, current []objectRef, appSlug string) ([]ObjectResult, error) {
+	pruned := []ObjectResult{}
+
+	// dependents are deleted in the background, like kubectl delete does
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := &metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	for i := len(previous) - 1; i >= 0; i-- {
+		ref := previous[i]
+		if containsRef(current, ref) {
+			continue
+		}
+
+		gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+		mapping, err := clients.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find resource of %s", gvk)
+		}
+
+		resourceClient := clients.Dynamic.Resource(mapping.Resource).Namespace(ref.Namespace)
+		live, err := resourceClient.Get(ref.Name, metav1.GetOptions{})
+		if kuberneteserrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %s %s", ref.Kind, ref.Name)
+		}
+		if live.GetLabels()[AppLabel] != appSlug {
+			continue
+		}
+
+		err = resourceClient.Delete(ref.Name, deleteOptions)
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to delete %s %s", ref.Kind, ref.Name)
+		}
+		pruned = append(pruned, ObjectResult{
+			APIVersion: ref.APIVersion,
+			Kind:       ref.Kind,
+			Namespace:  ref.Namespace,
+			Name:       ref.Name,
+			Action:     ActionPruned,
+		})
+	}
+
+	return pruned, nil
+}
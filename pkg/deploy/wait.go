@@ -0,0 +1,193 @@
+package deploy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// pollInterval is how often the status of the objects is checked
+var pollInterval = 2 * time.Second
+
+// readiness is the status of an object that's waited for
+type readiness struct {
+	ready   bool
+	failed  bool
+	message string
+}
+
+// waitForObjects waits until the deployments and statefulsets have rolled out and the jobs have
+// completed, or until the timeout. It returns the objects that aren't ready, with the reason in the
+// message. A job that failed isn't waited for any longer.
+func waitForObjects(clients *k8sutil.ClusterClients, objs []*unstructured.Unstructured, timeout time.Duration) ([]ObjectResult, error) {
+	pending := []*unstructured.Unstructured{}
+	for _, obj := range objs {
+		if readinessFunc(obj) != nil {
+			pending = append(pending, obj)
+		}
+	}
+
+	notReady := []ObjectResult{}
+	deadline := time.Now().Add(timeout)
+	for {
+		waiting := []*unstructured.Unstructured{}
+		messages := map[*unstructured.Unstructured]string{}
+		for _, obj := range pending {
+			status, err := getReadiness(clients, obj)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get status of %s %s", obj.GetKind(), obj.GetName())
+			}
+			if status.ready {
+				continue
+			}
+			if status.failed {
+				result := objectResult(obj, "")
+				result.Message = status.message
+				notReady = append(notReady, result)
+				continue
+			}
+			waiting = append(waiting, obj)
+			messages[obj] = status.message
+		}
+		pending = waiting
+
+		if len(pending) == 0 {
+			return notReady, nil
+		}
+		if time.Now().After(deadline) {
+			for _, obj := range pending {
+				result := objectResult(obj, "")
+				result.Message = fmt.Sprintf("timed out: %s", messages[obj])
+				notReady = append(notReady, result)
+			}
+			return notReady, nil
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+func getReadiness(clients *k8sutil.ClusterClients, obj *unstructured.Unstructured) (readiness, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := clients.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return readiness{}, errors.Wrapf(err, "failed to find resource of %s", gvk)
+	}
+
+	live, err := clients.Dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Get(obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return readiness{}, errors.Wrap(err, "failed to get live object")
+	}
+
+	return readinessFunc(obj)(live), nil
+}
+
+// readinessFunc returns the func that checks if an object is ready, or nil if the kind of the
+// object isn't waited for
+func readinessFunc(obj *unstructured.Unstructured) func(*unstructured.Unstructured) readiness {
+	gvk := obj.GroupVersionKind()
+	switch {
+	case gvk.Group == "apps" && gvk.Kind == "Deployment":
+		return deploymentReadiness
+	case gvk.Group == "apps" && gvk.Kind == "StatefulSet":
+		return statefulSetReadiness
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		return jobReadiness
+	}
+	return nil
+}
+
+// deploymentReadiness is ready when all of the replicas have been updated and are available, and
+// the pods of the previous replica sets are gone, like kubectl rollout status
+func deploymentReadiness(obj *unstructured.Unstructured) readiness {
+	if !observedGeneration(obj) {
+		return readiness{message: "waiting for the deployment spec to be observed"}
+	}
+
+	replicas := specReplicas(obj)
+	updated := statusInt(obj, "updatedReplicas")
+	available := statusInt(obj, "availableReplicas")
+	total := statusInt(obj, "replicas")
+
+	if updated < replicas {
+		return readiness{message: fmt.Sprintf("%d of %d replicas are updated", updated, replicas)}
+	}
+	if total > updated {
+		return readiness{message: fmt.Sprintf("%d old replicas are pending termination", total-updated)}
+	}
+	if available < updated {
+		return readiness{message: fmt.Sprintf("%d of %d updated replicas are available", available, updated)}
+	}
+	return readiness{ready: true}
+}
+
+// statefulSetReadiness is ready when all of the replicas are ready and on the current revision.
+// Statefulsets that are updated on delete are ready when their replicas are.
+func statefulSetReadiness(obj *unstructured.Unstructured) readiness {
+	if !observedGeneration(obj) {
+		return readiness{message: "waiting for the statefulset spec to be observed"}
+	}
+
+	replicas := specReplicas(obj)
+	ready := statusInt(obj, "readyReplicas")
+	if ready < replicas {
+		return readiness{message: fmt.Sprintf("%d of %d replicas are ready", ready, replicas)}
+	}
+
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy == "OnDelete" {
+		return readiness{ready: true}
+	}
+
+	updated := statusInt(obj, "updatedReplicas")
+	currentRevision, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	updateRevision, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+	if updateRevision != currentRevision {
+		return readiness{message: fmt.Sprintf("%d of %d replicas are updated", updated, replicas)}
+	}
+	return readiness{ready: true}
+}
+
+// jobReadiness is ready when the job is complete, and failed when the job failed
+func jobReadiness(obj *unstructured.Unstructured) readiness {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["status"] != "True" {
+			continue
+		}
+		switch condition["type"] {
+		case "Complete":
+			return readiness{ready: true}
+		case "Failed":
+			message, _ := condition["message"].(string)
+			return readiness{failed: true, message: fmt.Sprintf("job failed: %s", message)}
+		}
+	}
+
+	succeeded := statusInt(obj, "succeeded")
+	return readiness{message: fmt.Sprintf("%d pods succeeded", succeeded)}
+}
+
+func observedGeneration(obj *unstructured.Unstructured) bool {
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	return observed >= obj.GetGeneration()
+}
+
+// specReplicas defaults to 1, like the api server does
+func specReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
+
+func statusInt(obj *unstructured.Unstructured, field string) int64 {
+	value, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+	return value
+}
@@ -0,0 +1,121 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testStatusObject(apiVersion string, kind string, spec map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":       "test",
+			"generation": int64(2),
+		},
+		"spec":   spec,
+		"status": status,
+	}}
+}
+
+func Test_readiness(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		expected readiness
+	}{
+		{
+			name: "deployment not observed",
+			obj: testStatusObject("apps/v1", "Deployment", map[string]interface{}{}, map[string]interface{}{
+				"observedGeneration": int64(1),
+			}),
+			expected: readiness{message: "waiting for the deployment spec to be observed"},
+		},
+		{
+			name: "deployment rolling out",
+			obj: testStatusObject("apps/v1", "Deployment", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"replicas":           int64(4),
+				"updatedReplicas":    int64(2),
+				"availableReplicas":  int64(3),
+			}),
+			expected: readiness{message: "2 of 3 replicas are updated"},
+		},
+		{
+			name: "deployment terminating old replicas",
+			obj: testStatusObject("apps/v1", "Deployment", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"replicas":           int64(4),
+				"updatedReplicas":    int64(3),
+				"availableReplicas":  int64(3),
+			}),
+			expected: readiness{message: "1 old replicas are pending termination"},
+		},
+		{
+			name: "deployment ready with default replicas",
+			obj: testStatusObject("apps/v1", "Deployment", map[string]interface{}{}, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"replicas":           int64(1),
+				"updatedReplicas":    int64(1),
+				"availableReplicas":  int64(1),
+			}),
+			expected: readiness{ready: true},
+		},
+		{
+			name: "statefulset updating",
+			obj: testStatusObject("apps/v1", "StatefulSet", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"readyReplicas":      int64(2),
+				"updatedReplicas":    int64(1),
+				"currentRevision":    "web-1",
+				"updateRevision":     "web-2",
+			}),
+			expected: readiness{message: "1 of 2 replicas are updated"},
+		},
+		{
+			name: "statefulset on delete",
+			obj: testStatusObject("apps/v1", "StatefulSet", map[string]interface{}{"updateStrategy": map[string]interface{}{"type": "OnDelete"}}, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"readyReplicas":      int64(1),
+				"currentRevision":    "web-1",
+				"updateRevision":     "web-2",
+			}),
+			expected: readiness{ready: true},
+		},
+		{
+			name: "job running",
+			obj: testStatusObject("batch/v1", "Job", map[string]interface{}{}, map[string]interface{}{
+				"active": int64(1),
+			}),
+			expected: readiness{message: "0 pods succeeded"},
+		},
+		{
+			name: "job complete",
+			obj: testStatusObject("batch/v1", "Job", map[string]interface{}{}, map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Complete", "status": "True"},
+				},
+			}),
+			expected: readiness{ready: true},
+		},
+		{
+			name: "job failed",
+			obj: testStatusObject("batch/v1", "Job", map[string]interface{}{}, map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Failed", "status": "True", "message": "Job has reached the specified backoff limit"},
+				},
+			}),
+			expected: readiness{failed: true, message: "job failed: Job has reached the specified backoff limit"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, readinessFunc(test.obj)(test.obj))
+		})
+	}
+
+	assert.Nil(t, readinessFunc(testWidget("a", 1)))
+}
@@ -0,0 +1,149 @@
+package devloop
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// DefaultDebounce is how long to wait for more changes after a file changes, editors often write
+// a file more than once when it's saved
+const DefaultDebounce = 500 * time.Millisecond
+
+// phases of a run, in order
+const (
+	PhaseRendering = "rendering"
+	PhaseUploading = "uploading"
+	PhaseUploaded  = "uploaded"
+	PhaseFailed    = "failed"
+)
+
+// Event is the status of a run. Changed are the files that started it, it's empty for the first run.
+type Event struct {
+	Phase   string
+	Changed []string
+	Err     error
+}
+
+type WatchOptions struct {
+	// Dirs are watched, with their subdirectories
+	Dirs []string
+	// Debounce defaults to DefaultDebounce
+	Debounce time.Duration
+	// Render is optional, it's run before Upload. The dirs it writes to must not be watched.
+	Render func() error
+	Upload func() error
+	// Events is optional, it's sent the phases of every run and must be read from
+	Events chan<- Event
+}
+
+// Watch runs render and upload once, and then again every time files in the dirs change, until
+// stopCh is closed. A run that fails is reported as an event, it doesn't stop watching.
+func Watch(watchOptions WatchOptions, stopCh <-chan struct{}) error {
+	if watchOptions.Upload == nil {
+		return errors.New("upload is required")
+	}
+	debounce := watchOptions.Debounce
+	if debounce == 0 {
+		debounce = DefaultDebounce
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create watcher")
+	}
+	defer watcher.Close()
+
+	for _, dir := range watchOptions.Dirs {
+		if err := addRecursive(watcher, dir); err != nil {
+			return errors.Wrapf(err, "failed to watch %s", dir)
+		}
+	}
+
+	run(watchOptions, nil)
+
+	changed := map[string]bool{}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			// new directories are watched too
+			if event.Op&fsnotify.Create != 0 {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := addRecursive(watcher, event.Name); err != nil {
+						return errors.Wrapf(err, "failed to watch %s", event.Name)
+					}
+				}
+			}
+			changed[event.Name] = true
+			timer.Reset(debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return errors.Wrap(err, "failed to watch")
+
+		case <-timer.C:
+			paths := []string{}
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			changed = map[string]bool{}
+
+			run(watchOptions, paths)
+		}
+	}
+}
+
+func run(watchOptions WatchOptions, changed []string) {
+	send := func(phase string, err error) {
+		if watchOptions.Events != nil {
+			watchOptions.Events <- Event{Phase: phase, Changed: changed, Err: err}
+		}
+	}
+
+	if watchOptions.Render != nil {
+		send(PhaseRendering, nil)
+		if err := watchOptions.Render(); err != nil {
+			send(PhaseFailed, errors.Wrap(err, "failed to render"))
+			return
+		}
+	}
+
+	send(PhaseUploading, nil)
+	if err := watchOptions.Upload(); err != nil {
+		send(PhaseFailed, errors.Wrap(err, "failed to upload"))
+		return
+	}
+	send(PhaseUploaded, nil)
+}
+
+func addRecursive(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		return watcher.Add(path)
+	})
+}
@@ -0,0 +1,97 @@
+package devloop
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Watch(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	renders := 0
+	uploads := 0
+	failUpload := false
+	events := make(chan Event)
+	stopCh := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- Watch(WatchOptions{
+			Dirs:     []string{dir},
+			Debounce: 100 * time.Millisecond,
+			Render: func() error {
+				renders++
+				return nil
+			},
+			Upload: func() error {
+				uploads++
+				if failUpload {
+					return errors.New("admin console is unavailable")
+				}
+				return nil
+			},
+			Events: events,
+		}, stopCh)
+	}()
+
+	nextEvent := func() Event {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			req.FailNow("timed out waiting for an event")
+			return Event{}
+		}
+	}
+
+	// the first run is when it starts
+	assert.Equal(t, Event{Phase: PhaseRendering}, nextEvent())
+	assert.Equal(t, Event{Phase: PhaseUploading}, nextEvent())
+	assert.Equal(t, Event{Phase: PhaseUploaded}, nextEvent())
+
+	// changes close together are one run, including files in new directories
+	filename := filepath.Join(dir, "deployment.yaml")
+	req.NoError(ioutil.WriteFile(filename, []byte("kind: Deployment"), 0644))
+	req.NoError(ioutil.WriteFile(filename, []byte("kind: Deployment\n"), 0644))
+	req.NoError(os.MkdirAll(filepath.Join(dir, "charts"), 0755))
+	time.Sleep(20 * time.Millisecond)
+	chartFilename := filepath.Join(dir, "charts", "Chart.yaml")
+	req.NoError(ioutil.WriteFile(chartFilename, []byte("name: web"), 0644))
+
+	event := nextEvent()
+	assert.Equal(t, PhaseRendering, event.Phase)
+	assert.Contains(t, event.Changed, filename)
+	assert.Contains(t, event.Changed, chartFilename)
+	assert.Equal(t, PhaseUploading, nextEvent().Phase)
+	assert.Equal(t, PhaseUploaded, nextEvent().Phase)
+	assert.Equal(t, 2, renders)
+	assert.Equal(t, 2, uploads)
+
+	// a failed run doesn't stop watching
+	failUpload = true
+	req.NoError(ioutil.WriteFile(filename, []byte("kind: StatefulSet\n"), 0644))
+	assert.Equal(t, PhaseRendering, nextEvent().Phase)
+	assert.Equal(t, PhaseUploading, nextEvent().Phase)
+	event = nextEvent()
+	assert.Equal(t, PhaseFailed, event.Phase)
+	assert.EqualError(t, errors.Cause(event.Err), "admin console is unavailable")
+
+	failUpload = false
+	req.NoError(ioutil.WriteFile(filename, []byte("kind: Deployment\n"), 0644))
+	assert.Equal(t, PhaseRendering, nextEvent().Phase)
+	assert.Equal(t, PhaseUploading, nextEvent().Phase)
+	assert.Equal(t, PhaseUploaded, nextEvent().Phase)
+
+	close(stopCh)
+	req.NoError(<-watchErr)
+}
@@ -0,0 +1,257 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// actions of an object in the diff
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+)
+
+// ignoredFields change on every update and are left out of the changes
+var ignoredFields = map[string]bool{
+	"metadata.resourceVersion":                                   true,
+	"metadata.generation":                                        true,
+	"metadata.managedFields":                                     true,
+	"metadata.creationTimestamp":                                 true,
+	"metadata.annotations." + corev1.LastAppliedConfigAnnotation: true,
+}
+
+type DiffOptions struct {
+	// Kubectl is the kubectl executable that builds the overlay, defaults to kubectl in the path
+	Kubectl string
+	// Kinds are the kinds of the objects that are diffed, all objects are diffed when it's empty
+	Kinds []string
+}
+
+// ObjectDiff is the change to one object of the overlay. Changes are empty unless the object is
+// updated.
+type ObjectDiff struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	Action     string
+	Changes    []FieldChange
+}
+
+func (d ObjectDiff) String() string {
+	if d.Namespace == "" {
+		return fmt.Sprintf("%s/%s", d.Kind, d.Name)
+	}
+	return fmt.Sprintf("%s/%s in %s", d.Kind, d.Name, d.Namespace)
+}
+
+// FieldChange is a field of an object that's different in the cluster. Path is the dotted path to
+// the field, with the indexes of list items in brackets. Live is nil when the field is added, and
+// Desired is nil when it's removed.
+type FieldChange struct {
+	Path    string
+	Live    interface{}
+	Desired interface{}
+}
+
+// Build runs kustomize build on the overlay with kubectl and returns the manifests
+func Build(overlayDir string, kubectl string) ([]byte, error) {
+	if kubectl == "" {
+		kubectl = "kubectl"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(kubectl, "kustomize", overlayDir)
+	cmd.Env = os.Environ()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "failed to build %s: %s", overlayDir, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Diff builds the overlay and compares its objects to the live objects in the cluster of the
+// kubeconfig, or of the current context when kubeconfig is nil. Like kubectl diff, the changes
+// are what a kubectl apply would make: the overlay is merged with the live objects and the last
+// applied configuration on the server with a dry run, so fields set by the cluster or by
+// controllers aren't reported as changes. Nothing in the cluster is changed.
+func Diff(overlayDir string, kubeconfig []byte, options DiffOptions) ([]ObjectDiff, error) {
+	manifests, err := Build(overlayDir, options.Kubectl)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := k8sutil.GetClusterClients(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffObjects(manifests, clients.Namespace, clients.Dynamic, clients.Mapper, options.Kinds)
+}
+
+func diffObjects(manifests []byte, namespace string, client dynamic.Interface, mapper meta.RESTMapper, kinds []string) ([]ObjectDiff, error) {
+	diffs := []ObjectDiff{}
+
+	objs, err := k8sutil.DecodeObjects(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objs {
+		if !includesKind(kinds, obj.GetKind()) {
+			continue
+		}
+
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find resource of %s", gvk)
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace && obj.GetNamespace() == "" {
+			obj.SetNamespace(namespace)
+		}
+
+		resourceClient := client.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+		diff, err := diffObject(resourceClient, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to diff %s %s", obj.GetKind(), obj.GetName())
+		}
+		diffs = append(diffs, *diff)
+	}
+
+	return diffs, nil
+}
+
+func diffObject(resourceClient dynamic.ResourceInterface, obj *unstructured.Unstructured) (*ObjectDiff, error) {
+	diff := &ObjectDiff{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+
+	// the overlay is saved as the last applied configuration, like kubectl apply does
+	modified, err := k8sutil.WithLastAppliedConfig(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := resourceClient.Get(obj.GetName(), metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		_, err := resourceClient.Create(modified, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to dry run create")
+		}
+		diff.Action = ActionCreate
+		return diff, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get live object")
+	}
+
+	patchType, patch, err := k8sutil.ThreeWayPatch(live, modified)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create patch")
+	}
+
+	merged := live
+	if string(patch) != "{}" {
+		merged, err = resourceClient.Patch(obj.GetName(), patchType, patch, metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to dry run patch")
+		}
+	}
+
+	diff.Changes = compareObjects(live.Object, merged.Object)
+	if len(diff.Changes) == 0 {
+		diff.Action = ActionUnchanged
+	} else {
+		diff.Action = ActionUpdate
+	}
+
+	return diff, nil
+}
+
+func includesKind(kinds []string, kind string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, k := range kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareObjects returns the fields that are different in the two objects, sorted by path. Lists
+// of the same length are compared item by item, other lists are one change.
+func compareObjects(live map[string]interface{}, desired map[string]interface{}) []FieldChange {
+	changes := []FieldChange{}
+	compareValues("", live, desired, &changes)
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func compareValues(path string, live interface{}, desired interface{}, changes *[]FieldChange) {
+	if ignoredFields[path] || reflect.DeepEqual(live, desired) {
+		return
+	}
+
+	liveMap, liveIsMap := live.(map[string]interface{})
+	desiredMap, desiredIsMap := desired.(map[string]interface{})
+	if liveIsMap && desiredIsMap {
+		for key, value := range liveMap {
+			compareValues(joinPath(path, key), value, desiredMap[key], changes)
+		}
+		for key, value := range desiredMap {
+			if _, ok := liveMap[key]; !ok {
+				compareValues(joinPath(path, key), nil, value, changes)
+			}
+		}
+		return
+	}
+
+	liveList, liveIsList := live.([]interface{})
+	desiredList, desiredIsList := desired.([]interface{})
+	if liveIsList && desiredIsList && len(liveList) == len(desiredList) {
+		for i := range liveList {
+			compareValues(fmt.Sprintf("%s[%d]", path, i), liveList[i], desiredList[i], changes)
+		}
+		return
+	}
+
+	*changes = append(*changes, FieldChange{
+		Path:    path,
+		Live:    live,
+		Desired: desired,
+	})
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,112 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func Test_compareObjects(t *testing.T) {
+	live := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"resourceVersion": "2",
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"ports":    []interface{}{int64(80), int64(443)},
+			"paused":   true,
+		},
+	}
+	desired := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"resourceVersion": "3",
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"ports":    []interface{}{int64(8080), int64(443)},
+			"image":    "nginx",
+		},
+	}
+
+	expected := []FieldChange{
+		{Path: "spec.image", Live: nil, Desired: "nginx"},
+		{Path: "spec.paused", Live: true, Desired: nil},
+		{Path: "spec.ports[0]", Live: int64(80), Desired: int64(8080)},
+		{Path: "spec.replicas", Live: int64(1), Desired: int64(3)},
+	}
+	assert.Equal(t, expected, compareObjects(live, desired))
+}
+
+func Test_diffObjects(t *testing.T) {
+	req := require.New(t)
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gvk.GroupVersion()})
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+
+	// the live object was applied with a color, which was removed from the overlay, and a
+	// controller set the status
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata": map[string]interface{}{
+			"name":      "existing",
+			"namespace": "app",
+			"annotations": map[string]interface{}{
+				corev1.LastAppliedConfigAnnotation: `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"existing"},"spec":{"color":"blue","size":1}}`,
+			},
+		},
+		"spec": map[string]interface{}{
+			"color": "blue",
+			"size":  int64(1),
+		},
+		"status": map[string]interface{}{
+			"ready": true,
+		},
+	}}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), live)
+
+	manifests := []byte(`apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: existing
+spec:
+  size: 2
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: new
+spec:
+  size: 1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-selected
+`)
+
+	diffs, err := diffObjects(manifests, "app", client, mapper, []string{"widget"})
+	req.NoError(err)
+	req.Len(diffs, 2)
+
+	assert.Equal(t, "Widget/existing in app", diffs[0].String())
+	assert.Equal(t, ActionUpdate, diffs[0].Action)
+	assert.Equal(t, []FieldChange{
+		{Path: "spec.color", Live: "blue", Desired: nil},
+		{Path: "spec.size", Live: int64(1), Desired: int64(2)},
+	}, diffs[0].Changes)
+
+	assert.Equal(t, "Widget/new in app", diffs[1].String())
+	assert.Equal(t, ActionCreate, diffs[1].Action)
+	assert.Empty(t, diffs[1].Changes)
+}
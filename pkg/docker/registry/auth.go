@@ -4,14 +4,11 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/url"
 	"strings"
 
 	"github.com/containers/image/pkg/docker/config"
 	"github.com/containers/image/types"
-	"github.com/docker/distribution/registry/client/auth/challenge"
 	"github.com/pkg/errors"
 )
 
@@ -25,125 +22,54 @@ var (
 	}
 )
 
+// LoadAuthForRegistry returns the credentials for endpoint from the containers auth.json or the
+// docker config.json, including the ones kept by credential helpers
 func LoadAuthForRegistry(endpoint string) (string, string, error) {
+	endpoint = sanitizeEndpoint(endpoint)
+
 	sys := &types.SystemContext{}
 	username, password, err := config.GetAuthentication(sys, endpoint)
 	if err != nil {
 		return "", "", errors.Wrapf(err, "error loading username and password")
 	}
-
-	return username, password, nil
-}
-
-func TestPushAccess(endpoint, username, password, org string) error {
-
-	endpoint = sanitizeEndpoint(endpoint)
-
-	// We need to check if we can push images to a repo.
-	// We cannot get push permission to an org alone.
-	scope := org + "/testrepo"
-	basicAuthToken := makeBasicAuthToken(username, password)
-
-	if IsECREndpoint(endpoint) {
-		token, err := GetECRBasicAuthToken(endpoint, username, password)
-		if err != nil {
-			return errors.Wrap(err, "failed to ping registry")
-		}
-		basicAuthToken = token
-		scope = org // ECR has no concept of organization and it should be an empty string
+	if username != "" {
+		return username, password, nil
 	}
 
-	// TODO: Support http
-	pingURL := fmt.Sprintf("https://%s/v2/", endpoint)
-
-	resp, err := insecureClient.Get(pingURL)
+	// containers/image does not read DOCKER_CONFIG or the default credential store
+	username, password, err = loadDockerConfigAuth(endpoint)
 	if err != nil {
-		return errors.Wrap(err, "failed to ping registry")
-	}
-
-	if resp.StatusCode == http.StatusOK {
-		// Anonymous registry that does not require authentication
-		return nil
+		return "", "", errors.Wrap(err, "failed to load docker config credentials")
 	}
 
-	if resp.StatusCode != http.StatusUnauthorized {
-		return errors.Errorf("unexpected status code: %v", resp.StatusCode)
-	}
-
-	challenges := challenge.ResponseChallenges(resp)
-	if len(challenges) == 0 {
-		return errors.Wrap(err, "no auth challenges found for endpoint")
-	}
-
-	if challenges[0].Scheme == "basic" {
-		// ecr uses basic auth. not much more we can do here without actually pushing an image
-		return nil
-	}
-
-	host := challenges[0].Parameters["realm"]
-	v := url.Values{}
-	v.Set("service", challenges[0].Parameters["service"])
-	v.Set("scope", fmt.Sprintf("repository:%s:push", scope))
-
-	authURL := host + "?" + v.Encode()
-
-	req, err := http.NewRequest("GET", authURL, nil)
-	if err != nil {
-		return errors.Wrap(err, "failed to create auth request")
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", basicAuthToken))
-
-	resp, err = insecureClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "failed to execute auth request")
-	}
-	defer resp.Body.Close()
-
-	authBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return errors.Wrap(err, "failed to load auth response")
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return errors.New(errorResponseToString(authBody))
-	}
-
-	if strings.Contains(endpoint, "gcr.io") {
-		// gcr tokens are in a different format. it's enough to test that a token has been issued.
-		return nil
-	}
+	return username, password, nil
+}
 
-	bearerToken, err := newBearerTokenFromJSONBlob(authBody)
-	if err != nil {
-		return errors.Wrap(err, "failed to parse bearer token")
+// WithDockerCredentials returns the options with the username and password that docker login
+// stored for the endpoint, when they are not already set
+func (o RegistryOptions) WithDockerCredentials() (RegistryOptions, error) {
+	if o.Endpoint == "" || o.Username != "" {
+		return o, nil
 	}
 
-	jwtToken, err := bearerToken.getJwtToken()
+	username, password, err := LoadAuthForRegistry(o.Endpoint)
 	if err != nil {
-		return errors.Wrap(err, "failed to parse JWT token")
+		return o, errors.Wrapf(err, "failed to load registry auth for %q", o.Endpoint)
 	}
+	o.Username = username
+	o.Password = password
 
-	claims, err := getJwtTokenClaims(jwtToken)
-	if err != nil {
-		return errors.Wrap(err, "failed to get claims")
-	}
+	return o, nil
+}
 
-	for _, access := range claims.Access {
-		if access.Type != "repository" {
-			continue
-		}
-		if access.Name != scope {
-			continue
-		}
-		for _, action := range access.Actions {
-			if action == "push" {
-				return nil
-			}
-		}
+func TestPushAccess(endpoint, username, password, org string) error {
+	options := RegistryOptions{
+		Endpoint:  endpoint,
+		Namespace: org,
+		Username:  username,
+		Password:  password,
 	}
-
-	return errors.Errorf("%q has no push permission in %q", username, org)
+	return options.Validate()
 }
 
 func makeBasicAuthToken(username, password string) string {
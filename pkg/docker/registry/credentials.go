@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/clientcredentials"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	CredentialProviderECR = "ecr"
+	CredentialProviderGCR = "gcr"
+	CredentialProviderACR = "acr"
+
+	// ACRTokenUsername is the username that acr expects with a refresh token as the password
+	ACRTokenUsername = "00000000-0000-0000-0000-000000000000"
+)
+
+var (
+	azureLoginEndpoint = "https://login.microsoftonline.com"
+)
+
+// CredentialProvider exchanges the long lived credentials of a cloud registry for a short lived
+// login, so that pull secrets don't hold the cloud credentials and can be refreshed
+type CredentialProvider interface {
+	// Name is the cloud of the registry, one of CredentialProviderECR, CredentialProviderGCR or CredentialProviderACR
+	Name() string
+	Login() (*Login, error)
+}
+
+// CredentialProviderForRegistry returns the provider for registries whose credentials are
+// exchanged for tokens, or nil when the username and password are used as they are:
+//   - ecr: an aws access key id and secret access key
+//   - gcr: _json_key with a service account key
+//   - acr: <tenant id>/<client id> with the secret of an azure ad service principal
+func CredentialProviderForRegistry(options RegistryOptions) CredentialProvider {
+	endpoint := sanitizeEndpoint(options.Endpoint)
+
+	switch {
+	case IsECREndpoint(endpoint):
+		return ecrCredentialProvider{
+			endpoint:        endpoint,
+			accessKeyID:     options.Username,
+			secretAccessKey: options.Password,
+		}
+	case isGCREndpoint(endpoint) && options.Username == "_json_key":
+		return gcrCredentialProvider{
+			serviceAccountKey: []byte(options.Password),
+		}
+	case isACREndpoint(endpoint) && strings.Contains(options.Username, "/"):
+		parts := strings.SplitN(options.Username, "/", 2)
+		return acrCredentialProvider{
+			endpoint:     endpoint,
+			tenantID:     parts[0],
+			clientID:     parts[1],
+			clientSecret: options.Password,
+		}
+	}
+
+	return nil
+}
+
+// PullSecretLogin returns the login to write into the pull secret for the registry, which is a
+// token for registries with a credential provider
+func PullSecretLogin(options RegistryOptions) (*Login, error) {
+	provider := CredentialProviderForRegistry(options)
+	if provider == nil {
+		return &Login{Username: options.Username, Password: options.Password}, nil
+	}
+
+	login, err := provider.Login()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s login", provider.Name())
+	}
+	return login, nil
+}
+
+type ecrCredentialProvider struct {
+	endpoint        string
+	accessKeyID     string
+	secretAccessKey string
+}
+
+func (p ecrCredentialProvider) Name() string {
+	return CredentialProviderECR
+}
+
+// Login returns an ecr token, which is valid for 12 hours
+func (p ecrCredentialProvider) Login() (*Login, error) {
+	return GetECRLogin(p.endpoint, p.accessKeyID, p.secretAccessKey)
+}
+
+type gcrCredentialProvider struct {
+	serviceAccountKey []byte
+}
+
+func (p gcrCredentialProvider) Name() string {
+	return CredentialProviderGCR
+}
+
+// Login returns an access token of the service account that can read from gcr, which is valid for 1 hour
+func (p gcrCredentialProvider) Login() (*Login, error) {
+	jwtConfig, err := google.JWTConfigFromJSON(p.serviceAccountKey, "https://www.googleapis.com/auth/devstorage.read_only")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse service account key")
+	}
+
+	token, err := jwtConfig.TokenSource(context.Background()).Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get access token")
+	}
+
+	return &Login{Username: "oauth2accesstoken", Password: token.AccessToken}, nil
+}
+
+type acrCredentialProvider struct {
+	endpoint     string
+	tenantID     string
+	clientID     string
+	clientSecret string
+}
+
+func (p acrCredentialProvider) Name() string {
+	return CredentialProviderACR
+}
+
+// Login exchanges an azure ad token of the service principal for an acr refresh token, which is
+// valid for 3 hours
+func (p acrCredentialProvider) Login() (*Login, error) {
+	config := clientcredentials.Config{
+		ClientID:     p.clientID,
+		ClientSecret: p.clientSecret,
+		TokenURL:     fmt.Sprintf("%s/%s/oauth2/v2.0/token", azureLoginEndpoint, p.tenantID),
+		Scopes:       []string{"https://management.azure.com/.default"},
+	}
+	aadToken, err := config.Token(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get azure ad token")
+	}
+
+	// TODO: Support http
+	v := url.Values{}
+	v.Set("grant_type", "access_token")
+	v.Set("service", p.endpoint)
+	v.Set("tenant", p.tenantID)
+	v.Set("access_token", aadToken.AccessToken)
+	resp, err := http.PostForm(fmt.Sprintf("https://%s/oauth2/exchange", p.endpoint), v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to exchange azure ad token")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read exchange response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code %d exchanging azure ad token: %s", resp.StatusCode, errorResponseToString(body))
+	}
+
+	exchanged := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	if err := json.Unmarshal(body, &exchanged); err != nil {
+		return nil, errors.Wrap(err, "failed to parse exchange response")
+	}
+	if exchanged.RefreshToken == "" {
+		return nil, errors.New("exchange response has no refresh token")
+	}
+
+	return &Login{Username: ACRTokenUsername, Password: exchanged.RefreshToken}, nil
+}
+
+func isACREndpoint(endpoint string) bool {
+	return strings.HasSuffix(endpoint, ".azurecr.io")
+}
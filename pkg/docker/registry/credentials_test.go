@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CredentialProviderForRegistry(t *testing.T) {
+	tests := []struct {
+		name             string
+		options          RegistryOptions
+		expectedProvider string
+	}{
+		{
+			name:             "ecr",
+			options:          RegistryOptions{Endpoint: "123456789012.dkr.ecr.us-east-1.amazonaws.com", Username: "AKIA", Password: "secret"},
+			expectedProvider: CredentialProviderECR,
+		},
+		{
+			name:             "gcr with a service account key",
+			options:          RegistryOptions{Endpoint: "https://us.gcr.io", Username: "_json_key", Password: "{}"},
+			expectedProvider: CredentialProviderGCR,
+		},
+		{
+			name:    "gcr with an access token",
+			options: RegistryOptions{Endpoint: "gcr.io", Username: "oauth2accesstoken", Password: "token"},
+		},
+		{
+			name:             "acr with a service principal",
+			options:          RegistryOptions{Endpoint: "myregistry.azurecr.io", Username: "tenant/client", Password: "secret"},
+			expectedProvider: CredentialProviderACR,
+		},
+		{
+			name:    "acr with an admin user",
+			options: RegistryOptions{Endpoint: "myregistry.azurecr.io", Username: "myregistry", Password: "password"},
+		},
+		{
+			name:    "other registry",
+			options: RegistryOptions{Endpoint: "registry.example.com", Username: "user", Password: "password"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := CredentialProviderForRegistry(test.options)
+			if test.expectedProvider == "" {
+				assert.Nil(t, provider)
+				return
+			}
+			require.NotNil(t, provider)
+			assert.Equal(t, test.expectedProvider, provider.Name())
+		})
+	}
+}
+
+func Test_PullSecretLoginWithoutProvider(t *testing.T) {
+	login, err := PullSecretLogin(RegistryOptions{Endpoint: "registry.example.com", Username: "user", Password: "password"})
+	require.NoError(t, err)
+	assert.Equal(t, &Login{Username: "user", Password: "password"}, login)
+}
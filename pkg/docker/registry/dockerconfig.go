@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	helperclient "github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/pkg/errors"
+)
+
+type dockerConfig struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+type dockerConfigAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func dockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// loadDockerConfigAuth reads the credentials for endpoint from the docker config.json the same
+// way that docker login stores them: a credential helper for the registry, the auths entry, or
+// the default credential store
+func loadDockerConfigAuth(endpoint string) (string, string, error) {
+	configPath := dockerConfigPath()
+	if configPath == "" {
+		return "", "", nil
+	}
+
+	b, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", nil
+		}
+		return "", "", errors.Wrapf(err, "failed to read %s", configPath)
+	}
+
+	dockerConfig := dockerConfig{}
+	if err := json.Unmarshal(b, &dockerConfig); err != nil {
+		return "", "", errors.Wrapf(err, "failed to parse %s", configPath)
+	}
+
+	if helper, ok := dockerConfig.CredHelpers[endpoint]; ok {
+		return getAuthFromCredHelper(helper, endpoint)
+	}
+
+	for server, auth := range dockerConfig.Auths {
+		if sanitizeEndpoint(server) != endpoint {
+			continue
+		}
+		if auth.Auth == "" {
+			if auth.Username != "" {
+				return auth.Username, auth.Password, nil
+			}
+			// the credentials are in the credential store
+			break
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "failed to decode auth for %s", server)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", errors.Errorf("auth for %s is not in the form username:password", server)
+		}
+		return parts[0], parts[1], nil
+	}
+
+	if dockerConfig.CredsStore != "" {
+		return getAuthFromCredHelper(dockerConfig.CredsStore, endpoint)
+	}
+
+	return "", "", nil
+}
+
+func getAuthFromCredHelper(helper string, endpoint string) (string, string, error) {
+	program := helperclient.NewShellProgramFunc(fmt.Sprintf("docker-credential-%s", helper))
+	creds, err := helperclient.Get(program, endpoint)
+	if err != nil {
+		if credentials.IsErrCredentialsNotFound(err) {
+			return "", "", nil
+		}
+		return "", "", errors.Wrapf(err, "failed to get credentials from docker-credential-%s", helper)
+	}
+
+	return creds.Username, creds.Secret, nil
+}
@@ -56,6 +56,10 @@ func (r *RegistryProxyInfo) ToSlice() []string {
 	}
 }
 
+// PullSecretName is the name of the secret that the images of an app are pulled with, see
+// util.InstanceObjectName for the name of the secret of an app instance
+const PullSecretName = "kotsadm-replicated-registry"
+
 func PullSecretForRegistries(registries []string, username, password string, namespace string) (*corev1.Secret, error) {
 	dockercfgAuth := struct {
 		Auth string `json:"auth,omitempty"`
@@ -84,7 +88,7 @@ func PullSecretForRegistries(registries []string, username, password string, nam
 			Kind:       "Secret",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "kotsadm-replicated-registry",
+			Name:      PullSecretName,
 			Namespace: namespace,
 		},
 		Type: corev1.SecretTypeDockerConfigJson,
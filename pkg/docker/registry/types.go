@@ -6,4 +6,8 @@ type RegistryOptions struct {
 	Namespace     string
 	Username      string
 	Password      string
+
+	// OCIOnly is set for registries that reject docker manifests. Images pushed to them
+	// are converted to oci media types.
+	OCIOnly bool
 }
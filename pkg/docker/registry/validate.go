@@ -0,0 +1,199 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/util"
+)
+
+// Validate checks that the registry serves the v2 api and that the credentials can push to the
+// namespace, so that a wrong endpoint or credentials fail before any images are uploaded
+func (o RegistryOptions) Validate() error {
+	endpoint := sanitizeEndpoint(o.Endpoint)
+	if endpoint == "" {
+		return errors.New("registry endpoint is required")
+	}
+
+	if isGCREndpoint(endpoint) {
+		if o.Namespace == "" {
+			return errors.Errorf("a namespace is required for %s, set it to the id of the google cloud project", endpoint)
+		}
+		if o.Username != "" && o.Username != "_json_key" && o.Username != "oauth2accesstoken" {
+			return errors.Errorf("%s expects the username _json_key with a service account key as the password, or oauth2accesstoken with an access token", endpoint)
+		}
+	}
+	if isRobotAccount(o.Username) && o.Namespace == "" {
+		return errors.Errorf("robot account %q can only push to its own project, set the namespace to the name of the project", o.Username)
+	}
+
+	// We need to check if we can push images to a repo.
+	// We cannot get push permission to an org alone.
+	repository := path.Join(o.Namespace, "testrepo")
+	basicAuthToken := ""
+	if o.Username != "" {
+		basicAuthToken = makeBasicAuthToken(o.Username, o.Password)
+	}
+
+	if IsECREndpoint(endpoint) {
+		token, err := GetECRBasicAuthToken(endpoint, o.Username, o.Password)
+		if err != nil {
+			return util.NewError(util.ErrRegistryAuth, fmt.Sprintf("failed to get an auth token for %s, the username and password must be an aws access key id and secret access key that can call ecr:GetAuthorizationToken", endpoint), err)
+		}
+		basicAuthToken = token
+		repository = o.Namespace // ECR has no concept of organization, the namespace is the repository
+	}
+
+	// TODO: Support http
+	pingURL := fmt.Sprintf("https://%s/v2/", endpoint)
+
+	resp, err := insecureClient.Get(pingURL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to reach registry %s, check that the endpoint is correct and reachable from here", endpoint)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		// Anonymous registry that does not require authentication
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.Errorf("%s does not serve the docker registry v2 api, check that the endpoint is the registry and not a web interface", endpoint)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return errors.Errorf("unexpected status code %d from %s", resp.StatusCode, pingURL)
+	}
+
+	if basicAuthToken == "" {
+		return util.NewError(util.ErrRegistryAuth, fmt.Sprintf("%s requires authentication, run docker login %s or pass a username and password", endpoint, endpoint), nil)
+	}
+
+	challenges := challenge.ResponseChallenges(resp)
+	if len(challenges) == 0 {
+		return errors.Errorf("no auth challenges found for %s", endpoint)
+	}
+
+	if challenges[0].Scheme == "basic" {
+		// ecr and plain registries use basic auth. not much more we can do here without actually pushing an image
+		return checkBasicAuth(pingURL, basicAuthToken)
+	}
+
+	return checkTokenPushAccess(o, endpoint, repository, challenges[0], basicAuthToken)
+}
+
+func checkBasicAuth(pingURL string, basicAuthToken string) error {
+	req, err := http.NewRequest("GET", pingURL, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create ping request")
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", basicAuthToken))
+
+	resp, err := insecureClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute ping request")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return util.NewError(util.ErrRegistryAuth, "invalid username or password", nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code %d from %s", resp.StatusCode, pingURL)
+	}
+
+	return nil
+}
+
+func checkTokenPushAccess(o RegistryOptions, endpoint string, repository string, authChallenge challenge.Challenge, basicAuthToken string) error {
+	realm := authChallenge.Parameters["realm"]
+	if realm == "" {
+		return errors.Errorf("auth challenge from %s has no realm", endpoint)
+	}
+
+	v := url.Values{}
+	v.Set("service", authChallenge.Parameters["service"])
+	v.Set("scope", fmt.Sprintf("repository:%s:push,pull", repository))
+
+	authURL := realm + "?" + v.Encode()
+
+	req, err := http.NewRequest("GET", authURL, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create auth request")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", basicAuthToken))
+
+	resp, err := insecureClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to reach the token service at %s", realm)
+	}
+	defer resp.Body.Close()
+
+	authBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to load auth response")
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return util.NewError(util.ErrRegistryAuth, fmt.Sprintf("invalid username or password for %s: %s", endpoint, errorResponseToString(authBody)), nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(errorResponseToString(authBody))
+	}
+
+	if isGCREndpoint(endpoint) {
+		// gcr tokens are in a different format. it's enough to test that a token has been issued.
+		return nil
+	}
+
+	bearerToken, err := newBearerTokenFromJSONBlob(authBody)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse bearer token")
+	}
+
+	jwtToken, err := bearerToken.getJwtToken()
+	if err != nil {
+		return errors.Wrap(err, "failed to parse JWT token")
+	}
+
+	claims, err := getJwtTokenClaims(jwtToken)
+	if err != nil {
+		return errors.Wrap(err, "failed to get claims")
+	}
+
+	for _, access := range claims.Access {
+		if access.Type != "repository" {
+			continue
+		}
+		if access.Name != repository {
+			continue
+		}
+		for _, action := range access.Actions {
+			if action == "push" {
+				return nil
+			}
+		}
+	}
+
+	if isRobotAccount(o.Username) {
+		// harbor robot accounts are created per project, with the permissions picked at creation
+		return util.NewError(util.ErrRegistryAuth, fmt.Sprintf("robot account %q has no push permission in project %q, check that the account belongs to the project and was created with push access", o.Username, o.Namespace), nil)
+	}
+
+	return util.NewError(util.ErrRegistryAuth, fmt.Sprintf("%q has no push permission in %q", o.Username, o.Namespace), nil)
+}
+
+func isGCREndpoint(endpoint string) bool {
+	return endpoint == "gcr.io" || strings.HasSuffix(endpoint, ".gcr.io")
+}
+
+// isRobotAccount returns true for harbor robot accounts, which are named robot$<name>
+func isRobotAccount(username string) bool {
+	return strings.HasPrefix(username, "robot$")
+}
@@ -0,0 +1,244 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/replicatedhq/kots/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRegistry serves the v2 ping and a token service that grants the actions in access to
+// users with the password "password"
+func newTestRegistry(t *testing.T, scheme string, access map[string][]string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, hasAuth := r.BasicAuth()
+
+		switch r.URL.Path {
+		case "/v2/":
+			switch scheme {
+			case "":
+				w.WriteHeader(http.StatusOK)
+			case "basic":
+				if hasAuth && password == "password" {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			case "bearer":
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+
+		case "/token":
+			if !hasAuth || password != "password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`))
+				return
+			}
+
+			// scope is repository:<name>:push,pull
+			scope := strings.Split(r.URL.Query().Get("scope"), ":")
+			require.Len(t, scope, 3)
+			tokenClaims := claims{}
+			if actions, ok := access[username]; ok {
+				tokenClaims.Access = []accessItem{{Type: "repository", Name: scope[1], Actions: actions}}
+			}
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims).SignedString([]byte("secret"))
+			require.NoError(t, err)
+			json.NewEncoder(w).Encode(BearerToken{Token: token})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func Test_Validate(t *testing.T) {
+	access := map[string][]string{
+		"writer":   {"pull", "push"},
+		"reader":   {"pull"},
+		"robot$ci": {"pull"},
+	}
+
+	tests := []struct {
+		name          string
+		scheme        string
+		endpoint      string
+		options       RegistryOptions
+		expectedError string
+		expectedCode  util.ErrorCode
+	}{
+		{
+			name:    "anonymous",
+			scheme:  "",
+			options: RegistryOptions{Namespace: "org"},
+		},
+		{
+			name:    "basic auth",
+			scheme:  "basic",
+			options: RegistryOptions{Namespace: "org", Username: "admin", Password: "password"},
+		},
+		{
+			name:          "basic auth with wrong password",
+			scheme:        "basic",
+			options:       RegistryOptions{Namespace: "org", Username: "admin", Password: "wrong"},
+			expectedError: "invalid username or password",
+			expectedCode:  util.ErrRegistryAuth,
+		},
+		{
+			name:          "no credentials",
+			scheme:        "basic",
+			options:       RegistryOptions{Namespace: "org"},
+			expectedError: "requires authentication",
+			expectedCode:  util.ErrRegistryAuth,
+		},
+		{
+			name:    "token with push access",
+			scheme:  "bearer",
+			options: RegistryOptions{Namespace: "org", Username: "writer", Password: "password"},
+		},
+		{
+			name:          "token without push access",
+			scheme:        "bearer",
+			options:       RegistryOptions{Namespace: "org", Username: "reader", Password: "password"},
+			expectedError: `"reader" has no push permission in "org"`,
+			expectedCode:  util.ErrRegistryAuth,
+		},
+		{
+			name:          "token with wrong password",
+			scheme:        "bearer",
+			options:       RegistryOptions{Namespace: "org", Username: "writer", Password: "wrong"},
+			expectedError: "invalid username or password",
+			expectedCode:  util.ErrRegistryAuth,
+		},
+		{
+			name:          "robot account without push access",
+			scheme:        "bearer",
+			options:       RegistryOptions{Namespace: "project", Username: "robot$ci", Password: "password"},
+			expectedError: `robot account "robot$ci" has no push permission in project "project"`,
+			expectedCode:  util.ErrRegistryAuth,
+		},
+		{
+			name:          "robot account without namespace",
+			scheme:        "bearer",
+			options:       RegistryOptions{Username: "robot$ci", Password: "password"},
+			expectedError: "set the namespace to the name of the project",
+		},
+		{
+			name:          "gcr with wrong username",
+			endpoint:      "gcr.io",
+			options:       RegistryOptions{Namespace: "project", Username: "admin", Password: "password"},
+			expectedError: "expects the username _json_key",
+		},
+		{
+			name:          "gcr without namespace",
+			endpoint:      "us.gcr.io",
+			options:       RegistryOptions{Username: "_json_key", Password: "{}"},
+			expectedError: "a namespace is required",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestRegistry(t, test.scheme, access)
+			defer server.Close()
+
+			test.options.Endpoint = test.endpoint
+			if test.options.Endpoint == "" {
+				test.options.Endpoint = server.URL
+			}
+
+			err := test.options.Validate()
+			if test.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+			assert.Equal(t, test.expectedCode, util.ErrorCodeOf(err))
+		})
+	}
+}
+
+func Test_ValidateNotARegistry(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	err := RegistryOptions{Endpoint: server.URL}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not serve the docker registry v2 api")
+}
+
+func Test_loadDockerConfigAuth(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(configDir)
+
+	// a credential helper that knows one registry
+	helper := `#!/bin/sh
+read server
+if [ "$server" = "helper.example.com" ]; then
+  echo '{"ServerURL":"helper.example.com","Username":"helper-user","Secret":"helper-secret"}'
+  exit 0
+fi
+echo "credentials not found in native keychain"
+exit 1
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configDir, "docker-credential-test"), []byte(helper), 0755))
+
+	config := `{
+  "auths": {
+    "https://registry.example.com": {"auth": "dXNlcjpwYXNzd29yZA=="},
+    "helper.example.com": {}
+  },
+  "credsStore": "test"
+}`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0644))
+
+	defer os.Setenv("DOCKER_CONFIG", os.Getenv("DOCKER_CONFIG"))
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("DOCKER_CONFIG", configDir)
+	os.Setenv("PATH", configDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		endpoint         string
+		expectedUsername string
+		expectedPassword string
+	}{
+		{
+			endpoint:         "registry.example.com",
+			expectedUsername: "user",
+			expectedPassword: "password",
+		},
+		{
+			endpoint:         "helper.example.com",
+			expectedUsername: "helper-user",
+			expectedPassword: "helper-secret",
+		},
+		{
+			endpoint: "unknown.example.com",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			username, password, err := loadDockerConfigAuth(test.endpoint)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedUsername, username)
+			assert.Equal(t, test.expectedPassword, password)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package downstream
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// KubeconfigFilename is the file in a downstream directory with the encrypted kubeconfig of the
+// cluster that the downstream is deployed to. It's not referenced by the kustomization.
+const KubeconfigFilename = "kubeconfig.enc"
+
+// KubeconfigCipher returns the cipher of the key in the key file that kubeconfigs are encrypted
+// with. The key is kept outside of the app dir, so that a copy of the app dir, e.g. in a git repo,
+// can't be used to decrypt them. When create is set the key is generated the first time, otherwise
+// nil is returned when there is no key file.
+func KubeconfigCipher(keyFile string, create bool) (*crypto.AESCipher, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		if !create {
+			return nil, nil
+		}
+		return createKubeconfigKey(keyFile)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read kubeconfig key")
+	}
+
+	cipher, err := crypto.AESCipherFromString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create cipher from %s", keyFile)
+	}
+	return cipher, nil
+}
+
+func createKubeconfigKey(keyFile string) (*crypto.AESCipher, error) {
+	cipher, err := crypto.NewAESCipher()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create new AES cipher")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create kubeconfig key dir")
+	}
+	if err := ioutil.WriteFile(keyFile, []byte(cipher.ToString()), 0600); err != nil {
+		return nil, errors.Wrap(err, "failed to write kubeconfig key")
+	}
+	return cipher, nil
+}
+
+// DeployOptions are used to apply a downstream to its cluster
+type DeployOptions struct {
+	// Kubectl is the kubectl executable, defaults to kubectl in the path
+	Kubectl string
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+// SetKubeconfig stores the kubeconfig of the cluster that the downstream is deployed to. Only the
+// current context is kept, and files that it references are inlined so that the kubeconfig still
+// works on another machine. It's encrypted with the cipher of the kubeconfig key, see KubeconfigCipher.
+func SetKubeconfig(overlaysDir string, name string, kubeconfig []byte, cipher *crypto.AESCipher) (string, error) {
+	downstreamDir := Dir(overlaysDir, name)
+	if _, err := os.Stat(filepath.Join(downstreamDir, "kustomization.yaml")); err != nil {
+		return "", errors.Errorf("downstream %s does not exist", name)
+	}
+
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to load kubeconfig")
+	}
+	host, err := bundleKubeconfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := clientcmd.Write(*config)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal kubeconfig")
+	}
+
+	if err := writeKubeconfig(downstreamDir, b, cipher); err != nil {
+		return "", err
+	}
+
+	return host, nil
+}
+
+// bundleKubeconfig removes everything but the current context from the config and inlines the
+// files that it references. It returns the server of the cluster.
+func bundleKubeconfig(config *clientcmdapi.Config) (string, error) {
+	if err := clientcmdapi.MinifyConfig(config); err != nil {
+		return "", errors.Wrap(err, "failed to minify kubeconfig")
+	}
+	if err := clientcmdapi.FlattenConfig(config); err != nil {
+		return "", errors.Wrap(err, "failed to flatten kubeconfig")
+	}
+
+	restConfig, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return "", errors.Wrap(err, "invalid kubeconfig")
+	}
+
+	return restConfig.Host, nil
+}
+
+func writeKubeconfig(downstreamDir string, kubeconfig []byte, cipher *crypto.AESCipher) error {
+	// every kubeconfig is encrypted with the same key, so each write needs a new nonce
+	sealed, err := cipher.Seal(kubeconfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt kubeconfig")
+	}
+
+	encrypted := base64.StdEncoding.EncodeToString(sealed)
+	if err := ioutil.WriteFile(filepath.Join(downstreamDir, KubeconfigFilename), []byte(encrypted), 0600); err != nil {
+		return errors.Wrap(err, "failed to write kubeconfig")
+	}
+	return nil
+}
+
+// GetKubeconfig returns the decrypted kubeconfig of the downstream, or nil if it's deployed to the
+// cluster of the current context
+func GetKubeconfig(overlaysDir string, name string, cipher *crypto.AESCipher) ([]byte, error) {
+	encrypted, err := ioutil.ReadFile(filepath.Join(Dir(overlaysDir, name), KubeconfigFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read kubeconfig")
+	}
+	if cipher == nil {
+		return nil, errors.Errorf("downstream %s has a kubeconfig but there is no key to decrypt it", name)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(encrypted))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode kubeconfig")
+	}
+
+	kubeconfig, err := cipher.Open(decoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt kubeconfig")
+	}
+
+	return kubeconfig, nil
+}
+
+// RemoveKubeconfig deploys the downstream to the cluster of the current context again
+func RemoveKubeconfig(overlaysDir string, name string) error {
+	err := os.Remove(filepath.Join(Dir(overlaysDir, name), KubeconfigFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove kubeconfig")
+	}
+	return nil
+}
+
+// Deploy renders the downstream and applies it with kubectl, to the cluster of its kubeconfig if it
+// has one. The decrypted kubeconfig is only written to a temp file for kubectl.
+func Deploy(overlaysDir string, name string, cipher *crypto.AESCipher, options DeployOptions) error {
+	downstreamDir := Dir(overlaysDir, name)
+	if _, err := os.Stat(filepath.Join(downstreamDir, "kustomization.yaml")); err != nil {
+		return errors.Errorf("downstream %s does not exist", name)
+	}
+
+	kubeconfig, err := GetKubeconfig(overlaysDir, name, cipher)
+	if err != nil {
+		return errors.Wrap(err, "failed to get kubeconfig")
+	}
+
+	args := []string{}
+	if kubeconfig != nil {
+		tempDir, err := ioutil.TempDir("", "kots")
+		if err != nil {
+			return errors.Wrap(err, "failed to create temp dir")
+		}
+		defer os.RemoveAll(tempDir)
+
+		kubeconfigFile := filepath.Join(tempDir, "kubeconfig")
+		if err := ioutil.WriteFile(kubeconfigFile, kubeconfig, 0600); err != nil {
+			return errors.Wrap(err, "failed to write kubeconfig")
+		}
+		args = append(args, "--kubeconfig", kubeconfigFile)
+	}
+	args = append(args, "apply", "-k", downstreamDir)
+
+	kubectl := options.Kubectl
+	if kubectl == "" {
+		kubectl = "kubectl"
+	}
+
+	cmd := exec.Command(kubectl, args...)
+	cmd.Env = os.Environ()
+	cmd.Stdout = options.Stdout
+	cmd.Stderr = options.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "failed to apply downstream %s", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package downstream
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: prod
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+- name: staging
+  context:
+    cluster: staging
+    user: admin
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+    certificate-authority: ca.crt
+- name: staging
+  cluster:
+    server: https://staging.example.com:6443
+users:
+- name: admin
+  user:
+    token: abc123
+`
+
+func Test_bundleKubeconfig(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	// files that the kubeconfig references are relative to the kubeconfig
+	req.NoError(ioutil.WriteFile(filepath.Join(tempDir, "ca.crt"), []byte("my ca"), 0644))
+	kubeconfigFile := filepath.Join(tempDir, "kubeconfig")
+	req.NoError(ioutil.WriteFile(kubeconfigFile, []byte(testKubeconfig), 0600))
+	config, err := clientcmd.LoadFromFile(kubeconfigFile)
+	req.NoError(err)
+
+	host, err := bundleKubeconfig(config)
+	req.NoError(err)
+	assert.Equal(t, "https://prod.example.com:6443", host)
+	assert.Equal(t, "prod", config.CurrentContext)
+	assert.Len(t, config.Contexts, 1)
+	assert.Len(t, config.Clusters, 1)
+	assert.Equal(t, []byte("my ca"), config.Clusters["prod"].CertificateAuthorityData)
+	assert.Equal(t, "", config.Clusters["prod"].CertificateAuthority)
+}
+
+func Test_DeployWithKubeconfig(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	overlaysDir := filepath.Join(tempDir, "overlays")
+	req.NoError(Add(overlaysDir, "prod-us", AddOptions{}))
+
+	cipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	req.NoError(writeKubeconfig(Dir(overlaysDir, "prod-us"), []byte(testKubeconfig), cipher))
+	encrypted, err := ioutil.ReadFile(filepath.Join(Dir(overlaysDir, "prod-us"), KubeconfigFilename))
+	req.NoError(err)
+	assert.NotContains(t, string(encrypted), "abc123")
+
+	kubeconfig, err := GetKubeconfig(overlaysDir, "prod-us", cipher)
+	req.NoError(err)
+	assert.Equal(t, testKubeconfig, string(kubeconfig))
+
+	// kubectl is replaced with a script that prints its arguments and the kubeconfig
+	stdout := bytes.Buffer{}
+	kubectl := filepath.Join(tempDir, "kubectl")
+	req.NoError(ioutil.WriteFile(kubectl, []byte("#!/bin/sh\necho \"$@\"\nif [ \"$1\" = --kubeconfig ]; then cat \"$2\"; fi\n"), 0755))
+	req.NoError(Deploy(overlaysDir, "prod-us", cipher, DeployOptions{Kubectl: kubectl, Stdout: &stdout}))
+	assert.Contains(t, stdout.String(), "apply -k "+Dir(overlaysDir, "prod-us"))
+	assert.Contains(t, stdout.String(), "token: abc123")
+
+	req.NoError(RemoveKubeconfig(overlaysDir, "prod-us"))
+	req.NoError(RemoveKubeconfig(overlaysDir, "prod-us"))
+	kubeconfig, err = GetKubeconfig(overlaysDir, "prod-us", cipher)
+	req.NoError(err)
+	assert.Nil(t, kubeconfig)
+
+	// without a kubeconfig, the current context is used
+	stdout.Reset()
+	req.NoError(Deploy(overlaysDir, "prod-us", cipher, DeployOptions{Kubectl: kubectl, Stdout: &stdout}))
+	assert.Equal(t, "apply -k "+Dir(overlaysDir, "prod-us")+"\n", stdout.String())
+
+	_, err = SetKubeconfig(overlaysDir, "staging", []byte(testKubeconfig), cipher)
+	assert.Error(t, err)
+	_, err = SetKubeconfig(overlaysDir, "prod-us", []byte("not a kubeconfig"), cipher)
+	assert.Error(t, err)
+	assert.Error(t, Deploy(overlaysDir, "staging", cipher, DeployOptions{Kubectl: kubectl}))
+}
+
+func Test_KubeconfigCipher(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	keyFile := filepath.Join(tempDir, ".kots", "kubeconfig.key")
+
+	cipher, err := KubeconfigCipher(keyFile, false)
+	req.NoError(err)
+	assert.Nil(t, cipher)
+
+	created, err := KubeconfigCipher(keyFile, true)
+	req.NoError(err)
+	info, err := os.Stat(keyFile)
+	req.NoError(err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// the key is read from the file once it exists
+	cipher, err = KubeconfigCipher(keyFile, false)
+	req.NoError(err)
+	sealed, err := created.Seal([]byte("kubeconfig"))
+	req.NoError(err)
+	decrypted, err := cipher.Open(sealed)
+	req.NoError(err)
+	assert.Equal(t, []byte("kubeconfig"), decrypted)
+
+	// a kubeconfig can't be read without the key
+	overlaysDir := filepath.Join(tempDir, "overlays")
+	downstreamDir := Dir(overlaysDir, "prod")
+	req.NoError(os.MkdirAll(downstreamDir, 0755))
+	req.NoError(writeKubeconfig(downstreamDir, []byte("kubeconfig"), created))
+	_, err = GetKubeconfig(overlaysDir, "prod", nil)
+	req.EqualError(err, "downstream prod has a kubeconfig but there is no key to decrypt it")
+}
+
+func Test_writeKubeconfigNonce(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	overlaysDir := filepath.Join(tempDir, "overlays")
+	req.NoError(Add(overlaysDir, "prod", AddOptions{}))
+	req.NoError(Add(overlaysDir, "staging", AddOptions{}))
+
+	cipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	// the same kubeconfig written twice with the same key must not give the same ciphertext
+	req.NoError(writeKubeconfig(Dir(overlaysDir, "prod"), []byte(testKubeconfig), cipher))
+	req.NoError(writeKubeconfig(Dir(overlaysDir, "staging"), []byte(testKubeconfig), cipher))
+
+	prod, err := ioutil.ReadFile(filepath.Join(Dir(overlaysDir, "prod"), KubeconfigFilename))
+	req.NoError(err)
+	staging, err := ioutil.ReadFile(filepath.Join(Dir(overlaysDir, "staging"), KubeconfigFilename))
+	req.NoError(err)
+	assert.NotEqual(t, prod, staging)
+
+	for _, name := range []string{"prod", "staging"} {
+		kubeconfig, err := GetKubeconfig(overlaysDir, name, cipher)
+		req.NoError(err)
+		assert.Equal(t, testKubeconfig, string(kubeconfig))
+	}
+}
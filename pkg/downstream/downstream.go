@@ -8,6 +8,10 @@ import (
 type Downstream struct {
 	Kustomization *kustomizetypes.Kustomization
 	Midstream     *midstream.Midstream
+
+	// Patches are strategic merge patches of the midstream objects by file name, they're written
+	// to the downstream directory and added to its kustomization
+	Patches map[string][]byte
 }
 
 func CreateDownstream(m *midstream.Midstream, name string) (*Downstream, error) {
@@ -0,0 +1,128 @@
+package downstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+)
+
+// DirName is the directory in the overlays directory that has a directory for each downstream
+const DirName = "downstreams"
+
+var validName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// AddOptions are the patches of a downstream, by file name. They are strategic merge patches of
+// the objects in the midstream.
+type AddOptions struct {
+	Patches map[string][]byte
+}
+
+// ValidateName checks that a downstream name can be used as a directory name, e.g. prod-us
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return errors.Errorf("invalid downstream name %q, it can only contain lowercase letters, numbers and dashes", name)
+	}
+	return nil
+}
+
+// Dir returns the directory of the downstream in the overlays directory
+func Dir(overlaysDir string, name string) string {
+	return filepath.Join(overlaysDir, DirName, name)
+}
+
+// List returns the names of the downstreams in the overlays directory, sorted
+func List(overlaysDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(overlaysDir, DirName))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read downstreams dir")
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(overlaysDir, DirName, entry.Name(), "kustomization.yaml")); err != nil {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Add creates a downstream of the midstream in the overlays directory, with the patches in the
+// options. Adding a downstream that exists only writes the patches and adds the ones that are
+// missing to its kustomization, the rest of the kustomization is kept.
+func Add(overlaysDir string, name string, options AddOptions) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if err := validatePatchNames(options.Patches); err != nil {
+		return err
+	}
+
+	downstreamDir := Dir(overlaysDir, name)
+	kustomizationFile := filepath.Join(downstreamDir, "kustomization.yaml")
+	if _, err := os.Stat(kustomizationFile); os.IsNotExist(err) {
+		d, err := CreateDownstream(nil, name)
+		if err != nil {
+			return errors.Wrap(err, "failed to create downstream")
+		}
+		d.Patches = options.Patches
+
+		writeOptions := WriteOptions{
+			DownstreamDir: downstreamDir,
+			MidstreamDir:  filepath.Join(overlaysDir, "midstream"),
+		}
+		if err := d.WriteDownstream(writeOptions); err != nil {
+			return errors.Wrap(err, "failed to write downstream")
+		}
+		return nil
+	}
+
+	if len(options.Patches) == 0 {
+		return nil
+	}
+
+	kustomization, err := k8sutil.ReadKustomizationFromFile(kustomizationFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read kustomization")
+	}
+
+	d := Downstream{
+		Kustomization: kustomization,
+		Patches:       options.Patches,
+	}
+	if err := d.writePatches(downstreamDir); err != nil {
+		return errors.Wrap(err, "failed to write patches")
+	}
+
+	if err := k8sutil.WriteKustomizationToFile(d.Kustomization, kustomizationFile); err != nil {
+		return errors.Wrap(err, "failed to write kustomization")
+	}
+
+	return nil
+}
+
+// Remove deletes the downstream from the overlays directory. It's not an error if it doesn't exist.
+func Remove(overlaysDir string, name string) error {
+	// downstreams that were created by kotsadm can have names that ValidateName doesn't allow
+	if name == "" || name == "." || name == ".." || name != filepath.Base(name) {
+		return errors.Errorf("invalid downstream name %q", name)
+	}
+
+	if err := os.RemoveAll(Dir(overlaysDir, name)); err != nil {
+		return errors.Wrap(err, "failed to remove downstream")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package downstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+func Test_AddAndRemove(t *testing.T) {
+	req := require.New(t)
+
+	overlaysDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(overlaysDir)
+
+	replicas := []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 3\n")
+	req.NoError(Add(overlaysDir, "prod-us", AddOptions{Patches: map[string][]byte{"replicas.yaml": replicas}}))
+	req.NoError(Add(overlaysDir, "staging", AddOptions{}))
+
+	names, err := List(overlaysDir)
+	req.NoError(err)
+	assert.Equal(t, []string{"prod-us", "staging"}, names)
+
+	kustomization, err := k8sutil.ReadKustomizationFromFile(filepath.Join(Dir(overlaysDir, "prod-us"), "kustomization.yaml"))
+	req.NoError(err)
+	assert.Equal(t, []string{"../../midstream"}, kustomization.Bases)
+	assert.Equal(t, []kustomizetypes.PatchStrategicMerge{"replicas.yaml"}, kustomization.PatchesStrategicMerge)
+
+	patch, err := ioutil.ReadFile(filepath.Join(Dir(overlaysDir, "prod-us"), "replicas.yaml"))
+	req.NoError(err)
+	assert.Equal(t, replicas, patch)
+
+	// adding again keeps the kustomization and only adds new patches
+	req.NoError(Add(overlaysDir, "prod-us", AddOptions{Patches: map[string][]byte{
+		"replicas.yaml":  replicas,
+		"resources.yaml": []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"),
+	}}))
+	kustomization, err = k8sutil.ReadKustomizationFromFile(filepath.Join(Dir(overlaysDir, "prod-us"), "kustomization.yaml"))
+	req.NoError(err)
+	assert.Equal(t, []kustomizetypes.PatchStrategicMerge{"replicas.yaml", "resources.yaml"}, kustomization.PatchesStrategicMerge)
+
+	req.NoError(Remove(overlaysDir, "staging"))
+	req.NoError(Remove(overlaysDir, "staging"))
+	names, err = List(overlaysDir)
+	req.NoError(err)
+	assert.Equal(t, []string{"prod-us"}, names)
+
+	assert.Error(t, Add(overlaysDir, "Prod_EU", AddOptions{}))
+	assert.Error(t, Add(overlaysDir, "prod-eu", AddOptions{Patches: map[string][]byte{"../patch.yaml": replicas}}))
+	assert.Error(t, Remove(overlaysDir, ".."))
+}
@@ -1,12 +1,14 @@
 package downstream
 
 import (
+	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
+	"sort"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
 )
 
 type WriteOptions struct {
@@ -31,16 +33,21 @@ func (d *Downstream) WriteDownstream(options WriteOptions) error {
 		return nil
 	}
 
-	fileRenderPath := path.Join(renderDir, "kustomization.yaml")
-	dir, _ := path.Split(fileRenderPath)
+	fileRenderPath := filepath.Join(renderDir, "kustomization.yaml")
+	dir := filepath.Dir(fileRenderPath)
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		if err := os.MkdirAll(dir, 0744); err != nil {
 			return errors.Wrap(err, "failed to mkdir")
 		}
 	}
 
+	// kustomizations are the same on every platform, their paths are separated by slashes
 	d.Kustomization.Bases = []string{
-		relativeMidstreamDir,
+		filepath.ToSlash(relativeMidstreamDir),
+	}
+
+	if err := d.writePatches(renderDir); err != nil {
+		return errors.Wrap(err, "failed to write patches")
 	}
 
 	if err := k8sutil.WriteKustomizationToFile(d.Kustomization, fileRenderPath); err != nil {
@@ -49,3 +56,45 @@ func (d *Downstream) WriteDownstream(options WriteOptions) error {
 
 	return nil
 }
+
+// writePatches writes the patches to the downstream directory, and adds the ones that aren't in the
+// kustomization yet
+func (d *Downstream) writePatches(downstreamDir string) error {
+	if err := validatePatchNames(d.Patches); err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range d.Patches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(downstreamDir, name), d.Patches[name], 0644); err != nil {
+			return errors.Wrapf(err, "failed to write patch %s", name)
+		}
+
+		found := false
+		for _, patch := range d.Kustomization.PatchesStrategicMerge {
+			if string(patch) == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.Kustomization.PatchesStrategicMerge = append(d.Kustomization.PatchesStrategicMerge, kustomizetypes.PatchStrategicMerge(name))
+		}
+	}
+
+	return nil
+}
+
+func validatePatchNames(patches map[string][]byte) error {
+	for name := range patches {
+		if name != filepath.Base(name) || name == "kustomization.yaml" {
+			return errors.Errorf("invalid patch file name %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/diff"
+	"github.com/replicatedhq/kots/pkg/downstream"
+	"github.com/replicatedhq/kots/pkg/rendermanifest"
+)
+
+// what is committed to the repository
+const (
+	// ContentRendered is the downstream built with kubectl kustomize, in a single file
+	ContentRendered = "rendered"
+	// ContentTree is the base and the overlays of the app directory, to be built by the deploy
+	ContentTree = "tree"
+)
+
+type GitOpsOptions struct {
+	// RepoURI is the url of the repository, e.g. https://github.com/org/repo
+	RepoURI string
+	// Provider opens the pull request, github, gitlab or bitbucket. It's found from the host of
+	// RepoURI when it's empty.
+	Provider string
+	// APIEndpoint is the api of the provider, e.g. for github enterprise. The public api of the
+	// provider is used when it's empty.
+	APIEndpoint string
+	// Token authenticates the push and the provider api
+	Token string
+	// Branch is the branch that the pull request is opened against, main when it's empty
+	Branch string
+	// Path is the directory in the repository that the files are written to, the slug of the app
+	// when it's empty. Its contents are replaced.
+	Path string
+	// Content is ContentRendered or ContentTree, ContentRendered when it's empty
+	Content     string
+	Git         string
+	Kubectl     string
+	AuthorName  string
+	AuthorEmail string
+}
+
+// Result of a push. It's empty when the repository already had the files of the release.
+type Result struct {
+	Branch         string `json:"branch,omitempty"`
+	Commit         string `json:"commit,omitempty"`
+	PullRequestURL string `json:"pullRequestUrl,omitempty"`
+}
+
+var invalidBranchChars = regexp.MustCompile(`[^A-Za-z0-9._/-]+`)
+
+// Push commits a downstream of an app directory created by kots pull to a branch of the repository,
+// and opens a pull request of the branch, so that the release is reviewed before it's deployed
+func Push(appDir string, downstreamName string, options GitOpsOptions) (*Result, error) {
+	manifest, err := rendermanifest.Load(appDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load render manifest")
+	}
+	if manifest == nil {
+		return nil, errors.Errorf("%s was not created by kots pull, it has no %s", appDir, rendermanifest.Filename)
+	}
+	appSlug := filepath.Base(appDir)
+
+	if options.Branch == "" {
+		options.Branch = "main"
+	}
+	if options.Path == "" {
+		options.Path = appSlug
+	}
+	if options.Content == "" {
+		options.Content = ContentRendered
+	}
+	if options.Git == "" {
+		options.Git = "git"
+	}
+	if options.AuthorName == "" {
+		options.AuthorName = "kots"
+	}
+	if options.AuthorEmail == "" {
+		options.AuthorEmail = "kots@replicated.com"
+	}
+
+	repo, err := parseRepo(options)
+	if err != nil {
+		return nil, err
+	}
+
+	workDir, err := ioutil.TempDir("", "kots-gitops")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(workDir)
+
+	g := &gitRunner{
+		git:   options.Git,
+		token: options.Token,
+		env: []string{
+			"GIT_AUTHOR_NAME=" + options.AuthorName,
+			"GIT_AUTHOR_EMAIL=" + options.AuthorEmail,
+			"GIT_COMMITTER_NAME=" + options.AuthorName,
+			"GIT_COMMITTER_EMAIL=" + options.AuthorEmail,
+		},
+	}
+	if _, err := g.run("clone", "--depth", "1", "--branch", options.Branch, repo.cloneURL(options.Token), filepath.Join(workDir, "repo")); err != nil {
+		return nil, errors.Wrap(err, "failed to clone repository")
+	}
+	g.dir = filepath.Join(workDir, "repo")
+
+	version := manifest.VersionLabel
+	if version == "" {
+		version = manifest.UpdateCursor
+	}
+	branch := invalidBranchChars.ReplaceAllString(fmt.Sprintf("kots/%s/%s", appSlug, version), "-")
+	if _, err := g.run("checkout", "-b", branch); err != nil {
+		return nil, errors.Wrap(err, "failed to create branch")
+	}
+
+	targetDir := filepath.Join(g.dir, filepath.Clean("/"+options.Path))
+	if targetDir == g.dir {
+		return nil, errors.New("the path in the repository can't be the root")
+	}
+	if err := os.RemoveAll(targetDir); err != nil {
+		return nil, errors.Wrap(err, "failed to remove previous files")
+	}
+	if err := writeContent(appDir, downstreamName, targetDir, options); err != nil {
+		return nil, err
+	}
+
+	if _, err := g.run("add", "--all"); err != nil {
+		return nil, errors.Wrap(err, "failed to add files")
+	}
+	status, err := g.run("status", "--porcelain")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get status")
+	}
+	if status == "" {
+		return &Result{}, nil
+	}
+
+	title := fmt.Sprintf("Deploy %s %s to %s", appSlug, version, downstreamName)
+	if _, err := g.run("commit", "--message", title); err != nil {
+		return nil, errors.Wrap(err, "failed to commit")
+	}
+	commit, err := g.run("rev-parse", "HEAD")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get commit")
+	}
+	// the branch of a release is pushed again when it's rendered again, e.g. with other config values
+	if _, err := g.run("push", "--force", "origin", branch); err != nil {
+		return nil, errors.Wrap(err, "failed to push branch")
+	}
+
+	body := fmt.Sprintf("Release %s (sequence %s) of %s, rendered by kots for the %s downstream.", version, manifest.UpdateCursor, appSlug, downstreamName)
+	pullRequestURL, err := repo.provider.openPullRequest(pullRequest{
+		title: title,
+		body:  body,
+		head:  branch,
+		base:  options.Branch,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open pull request")
+	}
+
+	result := Result{
+		Branch:         branch,
+		Commit:         commit,
+		PullRequestURL: pullRequestURL,
+	}
+	return &result, nil
+}
+
+// writeContent writes the content of the downstream to targetDir
+func writeContent(appDir string, downstreamName string, targetDir string, options GitOpsOptions) error {
+	overlaysDir := filepath.Join(appDir, "overlays")
+	downstreamDir := downstream.Dir(overlaysDir, downstreamName)
+	if _, err := os.Stat(filepath.Join(downstreamDir, "kustomization.yaml")); err != nil {
+		return errors.Errorf("downstream %s does not exist", downstreamName)
+	}
+
+	switch options.Content {
+	case ContentRendered:
+		manifests, err := diff.Build(downstreamDir, options.Kubectl)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return errors.Wrap(err, "failed to create dir")
+		}
+		if err := ioutil.WriteFile(filepath.Join(targetDir, downstreamName+".yaml"), manifests, 0644); err != nil {
+			return errors.Wrap(err, "failed to write manifests")
+		}
+		return nil
+
+	case ContentTree:
+		for _, dir := range []string{"base", "overlays"} {
+			if err := copyDir(filepath.Join(appDir, dir), filepath.Join(targetDir, dir)); err != nil {
+				return errors.Wrapf(err, "failed to copy %s", dir)
+			}
+		}
+		return nil
+	}
+
+	return errors.Errorf("unknown content %q, expected %s or %s", options.Content, ContentRendered, ContentTree)
+}
+
+// copyDir copies the files of srcDir to destDir, except for the encrypted kubeconfigs of the
+// downstreams, which are only needed to deploy from the app directory
+func copyDir(srcDir string, destDir string) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(destDir, relPath)
+
+		if info.IsDir() {
+			return os.MkdirAll(destPath, 0755)
+		}
+		if info.Name() == downstream.KubeconfigFilename {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(destPath, content, 0644)
+	})
+}
+
+type gitRunner struct {
+	git   string
+	dir   string
+	token string
+	env   []string
+}
+
+// run returns the trimmed stdout of git. The token is removed from the errors, it's in the url of
+// the remote.
+func (g *gitRunner) run(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(g.git, args...)
+	cmd.Dir = g.dir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), g.env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if g.token != "" {
+			message = strings.Replace(message, g.token, "***", -1)
+		}
+		return "", errors.Wrapf(err, "git %s: %s", args[0], message)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
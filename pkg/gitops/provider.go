@@ -0,0 +1,237 @@
+package gitops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/util"
+)
+
+const (
+	ProviderGitHub    = "github"
+	ProviderGitLab    = "gitlab"
+	ProviderBitbucket = "bitbucket"
+)
+
+type pullRequest struct {
+	title string
+	body  string
+	head  string
+	base  string
+}
+
+// provider opens pull requests with the api of a git host
+type provider interface {
+	openPullRequest(pr pullRequest) (string, error)
+	// tokenUser is the user name that the token is pushed with over https
+	tokenUser() string
+}
+
+type repo struct {
+	url      *url.URL
+	path     string
+	provider provider
+}
+
+// parseRepo returns the repository of the options, with the provider that opens its pull requests
+func parseRepo(options GitOpsOptions) (*repo, error) {
+	u, err := url.Parse(options.RepoURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse repository url")
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if strings.Count(path, "/") < 1 {
+		return nil, errors.Errorf("repository url %q has no owner and name", options.RepoURI)
+	}
+
+	name := options.Provider
+	if name == "" {
+		switch {
+		case strings.Contains(u.Host, "github"):
+			name = ProviderGitHub
+		case strings.Contains(u.Host, "gitlab"):
+			name = ProviderGitLab
+		case strings.Contains(u.Host, "bitbucket"):
+			name = ProviderBitbucket
+		default:
+			return nil, errors.Errorf("the provider of %s is unknown, it has to be set", u.Host)
+		}
+	}
+
+	api := providerAPI{endpoint: strings.TrimSuffix(options.APIEndpoint, "/"), path: path, token: options.Token}
+	r := &repo{url: u, path: path}
+	switch name {
+	case ProviderGitHub:
+		if api.endpoint == "" {
+			api.endpoint = "https://api.github.com"
+			if u.Host != "github.com" {
+				api.endpoint = fmt.Sprintf("https://%s/api/v3", u.Host)
+			}
+		}
+		r.provider = &gitHub{api}
+	case ProviderGitLab:
+		if api.endpoint == "" {
+			api.endpoint = fmt.Sprintf("https://%s/api/v4", u.Host)
+		}
+		r.provider = &gitLab{api}
+	case ProviderBitbucket:
+		if api.endpoint == "" {
+			api.endpoint = "https://api.bitbucket.org/2.0"
+		}
+		r.provider = &bitbucket{api}
+	default:
+		return nil, errors.Errorf("unknown provider %q, expected %s, %s or %s", name, ProviderGitHub, ProviderGitLab, ProviderBitbucket)
+	}
+
+	return r, nil
+}
+
+// cloneURL returns the url with the token, for https urls. Other urls, e.g. ssh, are authenticated
+// by git.
+func (r *repo) cloneURL(token string) string {
+	if token == "" || (r.url.Scheme != "https" && r.url.Scheme != "http") {
+		return r.url.String()
+	}
+	u := *r.url
+	u.User = url.UserPassword(r.provider.tokenUser(), token)
+	return u.String()
+}
+
+type providerAPI struct {
+	endpoint string
+	path     string
+	token    string
+}
+
+// post sends the request as json and decodes the response into response. conflictStatus is the
+// status the api responds with when a pull request of the branch is already open, it's not an
+// error.
+func (a providerAPI) post(path string, header http.Header, request interface{}, response interface{}, conflictStatus int) (bool, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to marshal request")
+	}
+
+	req, err := http.NewRequest("POST", a.endpoint+path, bytes.NewReader(b))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create request")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read response body")
+	}
+
+	if resp.StatusCode == conflictStatus {
+		return false, nil
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return false, util.NewError(util.ErrUnauthorized, fmt.Sprintf("the token was not accepted by %s", a.endpoint), nil)
+	}
+	if resp.StatusCode >= 300 {
+		return false, errors.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, response); err != nil {
+		return false, errors.Wrap(err, "failed to unmarshal response")
+	}
+	return true, nil
+}
+
+type gitHub struct {
+	providerAPI
+}
+
+func (p *gitHub) tokenUser() string {
+	return "x-access-token"
+}
+
+func (p *gitHub) openPullRequest(pr pullRequest) (string, error) {
+	request := map[string]string{
+		"title": pr.title,
+		"body":  pr.body,
+		"head":  pr.head,
+		"base":  pr.base,
+	}
+	response := struct {
+		HTMLURL string `json:"html_url"`
+	}{}
+	header := http.Header{"Authorization": []string{"token " + p.token}}
+	if _, err := p.post(fmt.Sprintf("/repos/%s/pulls", p.path), header, request, &response, http.StatusUnprocessableEntity); err != nil {
+		return "", err
+	}
+	return response.HTMLURL, nil
+}
+
+type gitLab struct {
+	providerAPI
+}
+
+func (p *gitLab) tokenUser() string {
+	return "oauth2"
+}
+
+func (p *gitLab) openPullRequest(pr pullRequest) (string, error) {
+	request := map[string]string{
+		"title":         pr.title,
+		"description":   pr.body,
+		"source_branch": pr.head,
+		"target_branch": pr.base,
+	}
+	response := struct {
+		WebURL string `json:"web_url"`
+	}{}
+	header := http.Header{"Private-Token": []string{p.token}}
+	if _, err := p.post(fmt.Sprintf("/projects/%s/merge_requests", url.PathEscape(p.path)), header, request, &response, http.StatusConflict); err != nil {
+		return "", err
+	}
+	return response.WebURL, nil
+}
+
+type bitbucket struct {
+	providerAPI
+}
+
+func (p *bitbucket) tokenUser() string {
+	return "x-token-auth"
+}
+
+func (p *bitbucket) openPullRequest(pr pullRequest) (string, error) {
+	branch := func(name string) map[string]interface{} {
+		return map[string]interface{}{"branch": map[string]string{"name": name}}
+	}
+	request := map[string]interface{}{
+		"title":       pr.title,
+		"description": pr.body,
+		"source":      branch(pr.head),
+		"destination": branch(pr.base),
+	}
+	response := struct {
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	}{}
+	header := http.Header{"Authorization": []string{"Bearer " + p.token}}
+	if _, err := p.post(fmt.Sprintf("/repositories/%s/pullrequests", p.path), header, request, &response, http.StatusConflict); err != nil {
+		return "", err
+	}
+	return response.Links.HTML.Href, nil
+}
@@ -15,16 +15,17 @@ import (
 	"github.com/containers/image/copy"
 	imagedocker "github.com/containers/image/docker"
 	dockerref "github.com/containers/image/docker/reference"
+	"github.com/containers/image/manifest"
 	"github.com/containers/image/signature"
 	"github.com/containers/image/transports/alltransports"
 	"github.com/containers/image/types"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	"github.com/replicatedhq/kots/pkg/logger"
-	"gopkg.in/yaml.v2"
 	kustomizeimage "sigs.k8s.io/kustomize/v3/pkg/image"
 )
 
@@ -44,46 +45,46 @@ type RegistryAuth struct {
 	Password string
 }
 
-func CopyImages(srcRegistry, destRegistry registry.RegistryOptions, appSlug string, log *logger.Logger, reportWriter io.Writer, upstreamDir string) ([]kustomizeimage.Image, error) {
-	savedImages := make(map[string]bool)
-	newImages := []kustomizeimage.Image{}
-
-	err := filepath.Walk(upstreamDir,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			if info.IsDir() {
-				return nil
-			}
-
-			contents, err := ioutil.ReadFile(path)
-			if err != nil {
-				return err
-			}
+// CopyImages copies all of the images referenced by the manifests in upstreamDir to the destination
+// registry, and returns the images to rewrite them to. Private images are pulled through the
+// replicated proxy registry. Images that are already in the destination are skipped, so a copy
+// that was interrupted can be run again. Multi-architecture images are copied with their manifest
+// list, which only keeps the images for platforms (e.g. linux/arm64) when they're set.
+func CopyImages(srcRegistry, destRegistry registry.RegistryOptions, appSlug string, upstreamDir string, additionalLocations []k8sdoc.ImageLocation, platforms []string, pipelineOptions CopyPipelineOptions) ([]kustomizeimage.Image, error) {
+	parsedPlatforms, err := ParsePlatforms(platforms)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse platforms")
+	}
 
-			newImagesSubset, err := copyImagesBetweenRegistries(srcRegistry, destRegistry, appSlug, log, reportWriter, contents, savedImages)
-			if err != nil {
-				return errors.Wrapf(err, "failed to copy images mentioned in %s", path)
-			}
+	images, err := ListImages(upstreamDir, additionalLocations)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list images")
+	}
 
-			newImages = append(newImages, newImagesSubset...)
-			return nil
+	tasks := []CopyTask{}
+	for _, image := range images {
+		image := image
+		tasks = append(tasks, CopyTask{
+			Image: image,
+			Copy: func(reportWriter io.Writer) ([]kustomizeimage.Image, bool, error) {
+				return copyOneImage(srcRegistry, destRegistry, image, appSlug, parsedPlatforms, reportWriter, pipelineOptions.Log)
+			},
 		})
-
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to walk upstream dir")
 	}
 
-	return newImages, nil
+	return RunCopyPipeline(tasks, pipelineOptions)
 }
 
-func GetPrivateImages(upstreamDir string) ([]string, []*k8sdoc.Doc, error) {
+func GetPrivateImages(upstreamDir string, additionalLocations []k8sdoc.ImageLocation) ([]string, []*k8sdoc.Doc, error) {
+	locations, err := imageLocations(additionalLocations)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	uniqueImages := make(map[string]bool)
 	objects := make([]*k8sdoc.Doc, 0) // all objects where images are referenced from
 
-	err := filepath.Walk(upstreamDir,
+	err = filepath.Walk(upstreamDir,
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -98,7 +99,7 @@ func GetPrivateImages(upstreamDir string) ([]string, []*k8sdoc.Doc, error) {
 				return err
 			}
 
-			return listImagesInFile(contents, func(images []string, doc *k8sdoc.Doc) error {
+			return listImagesInFile(contents, locations, func(images []string, doc *k8sdoc.Doc) error {
 				numPrivateImages := 0
 				for _, image := range images {
 					isPrivate, err := isPrivateImage(image)
@@ -133,10 +134,15 @@ func GetPrivateImages(upstreamDir string) ([]string, []*k8sdoc.Doc, error) {
 	return result, objects, nil
 }
 
-func GetObjectsWithImages(upstreamDir string) ([]*k8sdoc.Doc, error) {
+func GetObjectsWithImages(upstreamDir string, additionalLocations []k8sdoc.ImageLocation) ([]*k8sdoc.Doc, error) {
+	locations, err := imageLocations(additionalLocations)
+	if err != nil {
+		return nil, err
+	}
+
 	objects := make([]*k8sdoc.Doc, 0)
 
-	err := filepath.Walk(upstreamDir,
+	err = filepath.Walk(upstreamDir,
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -151,7 +157,7 @@ func GetObjectsWithImages(upstreamDir string) ([]*k8sdoc.Doc, error) {
 				return err
 			}
 
-			return listImagesInFile(contents, func(images []string, doc *k8sdoc.Doc) error {
+			return listImagesInFile(contents, locations, func(images []string, doc *k8sdoc.Doc) error {
 				if len(images) > 0 {
 					objects = append(objects, doc)
 				}
@@ -166,54 +172,25 @@ func GetObjectsWithImages(upstreamDir string) ([]*k8sdoc.Doc, error) {
 	return objects, nil
 }
 
-func copyImagesBetweenRegistries(srcRegistry, destRegistry registry.RegistryOptions, appSlug string, log *logger.Logger, reportWriter io.Writer, fileData []byte, savedImages map[string]bool) ([]kustomizeimage.Image, error) {
-	newImages := []kustomizeimage.Image{}
-	err := listImagesInFile(fileData, func(images []string, doc *k8sdoc.Doc) error {
-		for _, image := range images {
-			if _, saved := savedImages[image]; saved {
-				continue
-			}
-
-			log.ChildActionWithSpinner("Transferring image %s", image)
-			newImage, err := copyOneImage(srcRegistry, destRegistry, image, appSlug, reportWriter, log)
-			if err != nil {
-				log.FinishChildSpinner()
-				return errors.Wrapf(err, "failed to transfer image %s", image)
-			}
-
-			if newImage != nil {
-				newImages = append(newImages, newImage...)
-			}
-
-			log.FinishChildSpinner()
-			savedImages[image] = true
-		}
+type processImagesFunc func([]string, *k8sdoc.Doc) error
 
-		return nil
-	})
+// imageLocations returns the default image locations along with any additional ones
+func imageLocations(additionalLocations []k8sdoc.ImageLocation) ([]k8sdoc.ImageLocation, error) {
+	if err := k8sdoc.ValidateImageLocations(additionalLocations); err != nil {
+		return nil, errors.Wrap(err, "failed to validate image locations")
+	}
 
-	return newImages, err
+	return append(k8sdoc.DefaultImageLocations(), additionalLocations...), nil
 }
 
-type processImagesFunc func([]string, *k8sdoc.Doc) error
-
-func listImagesInFile(contents []byte, handler processImagesFunc) error {
+func listImagesInFile(contents []byte, locations []k8sdoc.ImageLocation, handler processImagesFunc) error {
 	yamlDocs := bytes.Split(contents, []byte("\n---\n"))
 	for _, yamlDoc := range yamlDocs {
-		parsed := &k8sdoc.Doc{}
-		if err := yaml.Unmarshal(yamlDoc, parsed); err != nil {
+		images, parsed, err := k8sdoc.FindImages(yamlDoc, locations)
+		if err != nil {
 			continue
 		}
 
-		images := make([]string, 0)
-		for _, container := range parsed.Spec.Template.Spec.Containers {
-			images = append(images, container.Image)
-		}
-
-		for _, container := range parsed.Spec.Template.Spec.InitContainers {
-			images = append(images, container.Image)
-		}
-
 		if err := handler(images, parsed); err != nil {
 			return err
 		}
@@ -222,21 +199,28 @@ func listImagesInFile(contents []byte, handler processImagesFunc) error {
 	return nil
 }
 
-func copyOneImage(srcRegistry, destRegistry registry.RegistryOptions, image string, appSlug string, reportWriter io.Writer, log *logger.Logger) ([]kustomizeimage.Image, error) {
+// copyOneImage copies the image to the destination registry unless it's already there, and
+// returns the images to rewrite it to and whether the copy was skipped
+func copyOneImage(srcRegistry, destRegistry registry.RegistryOptions, image string, appSlug string, platforms []Platform, reportWriter io.Writer, log *logger.Logger) ([]kustomizeimage.Image, bool, error) {
 	policy, err := signature.NewPolicyFromBytes(imagePolicy)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read default policy")
+		return nil, false, errors.Wrap(err, "failed to read default policy")
 	}
 	policyContext, err := signature.NewPolicyContext(policy)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create policy")
+		return nil, false, errors.Wrap(err, "failed to create policy")
 	}
 
 	sourceCtx := &types.SystemContext{}
+	if len(platforms) > 0 {
+		// when a manifest list has to be flattened, keep the first platform instead of the host's
+		sourceCtx.OSChoice = platforms[0].OS
+		sourceCtx.ArchitectureChoice = platforms[0].Architecture
+	}
 
 	isPrivate, err := isPrivateImage(image)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to check if image is private")
+		return nil, false, errors.Wrap(err, "failed to check if image is private")
 	}
 
 	sourceImage := image
@@ -247,14 +231,14 @@ func copyOneImage(srcRegistry, destRegistry registry.RegistryOptions, image stri
 		}
 		rewritten, err := rewritePrivateImage(srcRegistry, image, appSlug)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to rewrite private image")
+			return nil, false, errors.Wrap(err, "failed to rewrite private image")
 		}
 
 		sourceImage = rewritten
 	}
 	srcRef, err := alltransports.ParseImageName(fmt.Sprintf("docker://%s", sourceImage))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to parse source image name %s", sourceImage)
+		return nil, false, errors.Wrapf(err, "failed to parse source image name %s", sourceImage)
 	}
 
 	destCtx := &types.SystemContext{
@@ -267,17 +251,33 @@ func copyOneImage(srcRegistry, destRegistry registry.RegistryOptions, image stri
 
 	destRef, err := alltransports.ParseImageName(fmt.Sprintf("docker://%s", DestRef(destRegistry, image)))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to parse dest image name %s", DestRef(destRegistry, image))
+		return nil, false, errors.Wrapf(err, "failed to parse dest image name %s", DestRef(destRegistry, image))
 	}
 
-	_, err = copy.Image(context.Background(), policyContext, destRef, srcRef, &copy.Options{
-		RemoveSignatures:      true,
-		SignBy:                "",
-		ReportWriter:          reportWriter,
-		SourceCtx:             sourceCtx,
-		DestinationCtx:        destCtx,
-		ForceManifestMIMEType: "",
-	})
+	exists, err := isImageInRegistry(destRef, srcRef, sourceCtx, destCtx)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to check destination registry")
+	}
+	if exists {
+		newImages, err := buildImageAlts(destRegistry, image)
+		if err != nil {
+			return nil, false, err
+		}
+		return newImages, true, nil
+	}
+
+	// manifest lists can't be converted, so they're flattened for registries that only accept oci
+	var convertedDigest string
+	copiedList := false
+	if !destRegistry.OCIOnly {
+		convertedDigest, copiedList, err = copyManifestList(policyContext, destRef, srcRef, sourceCtx, destCtx, platforms, reportWriter)
+		if err != nil {
+			log.Info("failed to copy manifest list with error %q, copying a single platform", err.Error())
+		}
+	}
+	if !copiedList {
+		convertedDigest, err = copyImageToRegistry(policyContext, destRef, srcRef, sourceCtx, destCtx, destRegistry.OCIOnly, reportWriter)
+	}
 	if err != nil {
 		log.Info("failed to copy image directly with error %q, attempting fallback transfer method", err.Error())
 		// direct image copy failed
@@ -287,7 +287,7 @@ func copyOneImage(srcRegistry, destRegistry registry.RegistryOptions, image stri
 		// make a temp directory
 		tempDir, err := ioutil.TempDir("", "temp-image-pull")
 		if err != nil {
-			return nil, errors.Wrapf(err, "temp directory %s not created", tempDir)
+			return nil, false, errors.Wrapf(err, "temp directory %s not created", tempDir)
 		}
 		defer os.RemoveAll(tempDir)
 
@@ -295,7 +295,7 @@ func copyOneImage(srcRegistry, destRegistry registry.RegistryOptions, image stri
 		destStr := fmt.Sprintf("docker-archive:%s", destPath)
 		localRef, err := alltransports.ParseImageName(destStr)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to parse local image name: %s", destStr)
+			return nil, false, errors.Wrapf(err, "failed to parse local image name: %s", destStr)
 		}
 
 		// copy image from remote to local
@@ -308,24 +308,74 @@ func copyOneImage(srcRegistry, destRegistry registry.RegistryOptions, image stri
 			ForceManifestMIMEType: "",
 		})
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to download image")
+			return nil, false, errors.Wrapf(err, "failed to download image")
 		}
 
 		// copy image from local to remote
-		_, err = copy.Image(context.Background(), policyContext, destRef, localRef, &copy.Options{
-			RemoveSignatures:      true,
-			SignBy:                "",
-			ReportWriter:          reportWriter,
-			SourceCtx:             nil,
-			DestinationCtx:        destCtx,
-			ForceManifestMIMEType: "",
-		})
+		convertedDigest, err = copyImageToRegistry(policyContext, destRef, localRef, nil, destCtx, destRegistry.OCIOnly, reportWriter)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to push image")
+			return nil, false, errors.Wrapf(err, "failed to push image")
 		}
 	}
 
-	return buildImageAlts(destRegistry, image)
+	newImages, err := buildImageAlts(destRegistry, image)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// converting the media types changes the manifest, so images that are referenced by digest
+	// have to be rewritten to the digest of the converted image
+	if convertedDigest != "" {
+		for i := range newImages {
+			if newImages[i].Digest != "" {
+				newImages[i].Digest = convertedDigest
+			}
+		}
+	}
+
+	return newImages, false, nil
+}
+
+// copyImageToRegistry copies the image to a registry. The image is converted to oci media types
+// when forceOCI is set, or when the registry rejects the manifest type of the source image.
+// The digest of the pushed manifest is returned when the image was converted.
+func copyImageToRegistry(policyContext *signature.PolicyContext, destRef, srcRef types.ImageReference, sourceCtx, destCtx *types.SystemContext, forceOCI bool, reportWriter io.Writer) (string, error) {
+	copyOptions := &copy.Options{
+		RemoveSignatures:      true,
+		SignBy:                "",
+		ReportWriter:          reportWriter,
+		SourceCtx:             sourceCtx,
+		DestinationCtx:        destCtx,
+		ForceManifestMIMEType: "",
+	}
+	if forceOCI {
+		copyOptions.ForceManifestMIMEType = imgspecv1.MediaTypeImageManifest
+	}
+
+	pushedManifest, err := copy.Image(context.Background(), policyContext, destRef, srcRef, copyOptions)
+	if err != nil && !forceOCI && isManifestTypeRejected(err) {
+		copyOptions.ForceManifestMIMEType = imgspecv1.MediaTypeImageManifest
+		pushedManifest, err = copy.Image(context.Background(), policyContext, destRef, srcRef, copyOptions)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if copyOptions.ForceManifestMIMEType == "" {
+		return "", nil
+	}
+
+	pushedDigest, err := manifest.Digest(pushedManifest)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to calculate digest of converted image")
+	}
+
+	return pushedDigest.String(), nil
+}
+
+func isManifestTypeRejected(err error) bool {
+	_, ok := errors.Cause(err).(types.ManifestTypeRejectedError)
+	return ok
 }
 
 func imageRefImage(image string) (*ImageRef, error) {
@@ -367,25 +417,27 @@ func (ref *ImageRef) pathInBundle(formatPrefix string) string {
 	return filepath.Join(path...)
 }
 
-func CopyFromFileToRegistry(path string, name string, tag string, digest string, auth RegistryAuth, reportWriter io.Writer) error {
+// CopyFromFileToRegistry pushes an image archive to a registry, unless the registry already has it.
+// When the image had to be converted to oci media types, the digest of the converted image is returned.
+func CopyFromFileToRegistry(path string, name string, tag string, digest string, auth RegistryAuth, ociOnly bool, reportWriter io.Writer) (string, bool, error) {
 	policy, err := signature.NewPolicyFromBytes(imagePolicy)
 	if err != nil {
-		return errors.Wrap(err, "failed to read default policy")
+		return "", false, errors.Wrap(err, "failed to read default policy")
 	}
 	policyContext, err := signature.NewPolicyContext(policy)
 	if err != nil {
-		return errors.Wrap(err, "failed to create policy")
+		return "", false, errors.Wrap(err, "failed to create policy")
 	}
 
 	srcRef, err := alltransports.ParseImageName(fmt.Sprintf("docker-archive:%s", path))
 	if err != nil {
-		return errors.Wrap(err, "failed to parse src image name")
+		return "", false, errors.Wrap(err, "failed to parse src image name")
 	}
 
 	destStr := fmt.Sprintf("docker://%s:%s", name, tag)
 	destRef, err := alltransports.ParseImageName(destStr)
 	if err != nil {
-		return errors.Wrapf(err, "failed to parse dest image name: %s", destStr)
+		return "", false, errors.Wrapf(err, "failed to parse dest image name: %s", destStr)
 	}
 
 	destCtx := &types.SystemContext{
@@ -397,7 +449,7 @@ func CopyFromFileToRegistry(path string, name string, tag string, digest string,
 		if registry.IsECREndpoint(registryHost) {
 			login, err := registry.GetECRLogin(registryHost, auth.Username, auth.Password)
 			if err != nil {
-				return errors.Wrap(err, "failed to get ECR login")
+				return "", false, errors.Wrap(err, "failed to get ECR login")
 			}
 			auth.Username = login.Username
 			auth.Password = login.Password
@@ -409,19 +461,20 @@ func CopyFromFileToRegistry(path string, name string, tag string, digest string,
 		}
 	}
 
-	_, err = copy.Image(context.Background(), policyContext, destRef, srcRef, &copy.Options{
-		RemoveSignatures:      true,
-		SignBy:                "",
-		ReportWriter:          reportWriter,
-		SourceCtx:             nil,
-		DestinationCtx:        destCtx,
-		ForceManifestMIMEType: "",
-	})
+	exists, err := isImageInRegistry(destRef, srcRef, nil, destCtx)
 	if err != nil {
-		return errors.Wrap(err, "failed to copy image")
+		return "", false, errors.Wrap(err, "failed to check destination registry")
+	}
+	if exists {
+		return "", true, nil
 	}
 
-	return nil
+	convertedDigest, err := copyImageToRegistry(policyContext, destRef, srcRef, nil, destCtx, ociOnly, reportWriter)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to copy image")
+	}
+
+	return convertedDigest, false, nil
 }
 
 func isPrivateImage(image string) (bool, error) {
@@ -1,8 +1,20 @@
 package image
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/signature"
+	"github.com/containers/image/transports/alltransports"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/require"
 )
 
@@ -65,3 +77,87 @@ func TestReleaseStartNum(t *testing.T) {
 		"docker-archive/docker.io/myorg/ubuntu/sha256/45b23dee08af5e43a7fea6c4cf9c25ccf269ee113168c19722f87876677c5cb2",
 		ref.pathInBundle("docker-archive"))
 }
+
+func Test_copyImageToRegistry(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots-image-test")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	srcDir := filepath.Join(tempDir, "src")
+	req.NoError(os.MkdirAll(srcDir, 0755))
+	srcManifest := writeTestDockerImage(t, srcDir)
+
+	policy, err := signature.NewPolicyFromBytes(imagePolicy)
+	req.NoError(err)
+	policyContext, err := signature.NewPolicyContext(policy)
+	req.NoError(err)
+
+	srcRef, err := alltransports.ParseImageName("dir:" + srcDir)
+	req.NoError(err)
+
+	// without conversion the manifest is copied unchanged
+	unchangedDir := filepath.Join(tempDir, "unchanged")
+	destRef, err := alltransports.ParseImageName("dir:" + unchangedDir)
+	req.NoError(err)
+	convertedDigest, err := copyImageToRegistry(policyContext, destRef, srcRef, nil, nil, false, ioutil.Discard)
+	req.NoError(err)
+	req.Equal("", convertedDigest)
+
+	unchangedManifest, err := ioutil.ReadFile(filepath.Join(unchangedDir, "manifest.json"))
+	req.NoError(err)
+	req.Equal(srcManifest, unchangedManifest)
+
+	// with conversion the manifest is oci and the digest of the converted manifest is returned
+	convertedDir := filepath.Join(tempDir, "converted")
+	destRef, err = alltransports.ParseImageName("dir:" + convertedDir)
+	req.NoError(err)
+	convertedDigest, err = copyImageToRegistry(policyContext, destRef, srcRef, nil, nil, true, ioutil.Discard)
+	req.NoError(err)
+
+	convertedManifest, err := ioutil.ReadFile(filepath.Join(convertedDir, "manifest.json"))
+	req.NoError(err)
+	req.Equal(imgspecv1.MediaTypeImageManifest, manifest.GuessMIMEType(convertedManifest))
+
+	expectedDigest, err := manifest.Digest(convertedManifest)
+	req.NoError(err)
+	srcDigest, err := manifest.Digest(srcManifest)
+	req.NoError(err)
+	req.Equal(expectedDigest.String(), convertedDigest)
+	req.NotEqual(srcDigest.String(), convertedDigest)
+}
+
+// writeTestDockerImage writes a single layer docker schema2 image in the dir transport format
+// and returns its manifest
+func writeTestDockerImage(t *testing.T, dir string) []byte {
+	req := require.New(t)
+
+	var layer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&layer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	content := []byte("hello")
+	req.NoError(tarWriter.WriteHeader(&tar.Header{Name: "hello.txt", Mode: 0644, Size: int64(len(content))}))
+	_, err := tarWriter.Write(content)
+	req.NoError(err)
+	req.NoError(tarWriter.Close())
+	req.NoError(gzipWriter.Close())
+
+	config := []byte(`{"architecture":"amd64","os":"linux","config":{},"rootfs":{"type":"layers","diff_ids":[]}}`)
+
+	layerDigest := digest.FromBytes(layer.Bytes())
+	configDigest := digest.FromBytes(config)
+	req.NoError(ioutil.WriteFile(filepath.Join(dir, layerDigest.Hex()), layer.Bytes(), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(dir, configDigest.Hex()), config, 0644))
+
+	imageManifest := []byte(fmt.Sprintf(`{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+  "config": {"mediaType": "application/vnd.docker.container.image.v1+json", "size": %d, "digest": "%s"},
+  "layers": [{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": %d, "digest": "%s"}]
+}`, len(config), configDigest, layer.Len(), layerDigest))
+	req.NoError(ioutil.WriteFile(filepath.Join(dir, "manifest.json"), imageManifest, 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(dir, "version"), []byte("Directory Transport Version: 1.1\n"), 0644))
+
+	return imageManifest
+}
@@ -0,0 +1,237 @@
+package image
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/containers/image/types"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/logger"
+	kustomizeimage "sigs.k8s.io/kustomize/v3/pkg/image"
+)
+
+// DefaultCopyParallelism is the number of images that are copied at the same time when the
+// options don't set it. The layers of each image are also copied in parallel.
+const DefaultCopyParallelism = 3
+
+// CopyProgress is reported after each image is copied, or skipped because the destination
+// registry already has it
+type CopyProgress struct {
+	Image     string
+	Skipped   bool
+	Completed int
+	Total     int
+}
+
+type CopyPipelineOptions struct {
+	Parallelism int
+	Log         *logger.Logger
+	// ReportWriter receives the layer progress of the copies, it's only used when images are
+	// copied one at a time so that the output isn't interleaved
+	ReportWriter io.Writer
+	OnProgress   func(CopyProgress)
+	// ProgressReporter is optional, it's sent the number of images that have been copied
+	ProgressReporter logger.ProgressReporter
+}
+
+// CopyTask copies one image, and returns the images to rewrite it to and whether it was
+// skipped because the destination already had it
+type CopyTask struct {
+	Image string
+	Copy  func(reportWriter io.Writer) ([]kustomizeimage.Image, bool, error)
+}
+
+// ListImages returns all of the images referenced by the manifests in upstreamDir, sorted and
+// without duplicates
+func ListImages(upstreamDir string, additionalLocations []k8sdoc.ImageLocation) ([]string, error) {
+	locations, err := imageLocations(additionalLocations)
+	if err != nil {
+		return nil, err
+	}
+
+	uniqueImages := map[string]bool{}
+	err = filepath.Walk(upstreamDir,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			return listImagesInFile(contents, locations, func(images []string, doc *k8sdoc.Doc) error {
+				for _, image := range images {
+					uniqueImages[image] = true
+				}
+				return nil
+			})
+		})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk upstream dir")
+	}
+
+	result := make([]string, 0, len(uniqueImages))
+	for image := range uniqueImages {
+		result = append(result, image)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// RunCopyPipeline runs the tasks on options.Parallelism workers, and returns the rewritten images
+// in the order of the tasks. The first error stops tasks that haven't started yet.
+func RunCopyPipeline(tasks []CopyTask, options CopyPipelineOptions) ([]kustomizeimage.Image, error) {
+	parallelism := options.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultCopyParallelism
+	}
+	if parallelism > len(tasks) {
+		parallelism = len(tasks)
+	}
+
+	reportWriter := options.ReportWriter
+	if parallelism > 1 || reportWriter == nil {
+		reportWriter = ioutil.Discard
+	}
+
+	tracker := logger.NewProgressTracker(options.ProgressReporter, "Transferring images", logger.ProgressUnitItems, int64(len(tasks)))
+	defer tracker.Done()
+
+	results := make([][]kustomizeimage.Image, len(tasks))
+	indexes := make(chan int)
+
+	var mtx sync.Mutex
+	var firstErr error
+	completed := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				mtx.Lock()
+				failed := firstErr != nil
+				mtx.Unlock()
+				if failed {
+					continue
+				}
+
+				task := tasks[index]
+				options.Log.Progress(task.Image, "transferring")
+				newImages, skipped, err := task.Copy(reportWriter)
+				options.Log.FinishProgress(task.Image)
+
+				mtx.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = errors.Wrapf(err, "failed to transfer image %s", task.Image)
+					}
+					mtx.Unlock()
+					continue
+				}
+				results[index] = newImages
+				completed++
+				tracker.Add(1)
+				if skipped {
+					options.Log.ChildActionWithoutSpinner("Image %s is already in the registry (%d/%d)", task.Image, completed, len(tasks))
+				} else {
+					options.Log.ChildActionWithoutSpinner("Transferred image %s (%d/%d)", task.Image, completed, len(tasks))
+				}
+				if options.OnProgress != nil {
+					options.OnProgress(CopyProgress{
+						Image:     task.Image,
+						Skipped:   skipped,
+						Completed: completed,
+						Total:     len(tasks),
+					})
+				}
+				mtx.Unlock()
+			}
+		}()
+	}
+
+	for index := range tasks {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	newImages := []kustomizeimage.Image{}
+	for _, result := range results {
+		newImages = append(newImages, result...)
+	}
+	return newImages, nil
+}
+
+// isImageInRegistry returns true when the destination has the same image as the source, so that
+// copies that were interrupted can be resumed without copying it again. Layers of partially copied
+// images are reused by the copy itself. The config and layers are compared rather than the
+// manifests, which are different after the image is converted to oci or a manifest list is
+// filtered to some platforms. Of a manifest list, the image of the platform that sourceCtx
+// chooses is compared.
+func isImageInRegistry(destRef, srcRef types.ImageReference, sourceCtx, destCtx *types.SystemContext) (bool, error) {
+	destPlatformCtx := &types.SystemContext{}
+	if destCtx != nil {
+		*destPlatformCtx = *destCtx
+	}
+	if sourceCtx != nil {
+		destPlatformCtx.OSChoice = sourceCtx.OSChoice
+		destPlatformCtx.ArchitectureChoice = sourceCtx.ArchitectureChoice
+	}
+
+	destBlobs, err := imageBlobDigests(destRef, destPlatformCtx)
+	if err != nil {
+		// missing images and repositories are errors too, the image is copied either way
+		return false, nil
+	}
+
+	srcBlobs, err := imageBlobDigests(srcRef, sourceCtx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get source image")
+	}
+
+	if len(srcBlobs) != len(destBlobs) {
+		return false, nil
+	}
+	for i := range srcBlobs {
+		if srcBlobs[i] != destBlobs[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// imageBlobDigests returns the digest of the config of the image followed by the digests of its
+// layers, which don't change when only the manifest is converted
+func imageBlobDigests(ref types.ImageReference, sys *types.SystemContext) ([]string, error) {
+	img, err := ref.NewImage(context.Background(), sys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create image")
+	}
+	defer img.Close()
+
+	digests := []string{img.ConfigInfo().Digest.String()}
+	for _, layer := range img.LayerInfos() {
+		digests = append(digests, layer.Digest.String())
+	}
+
+	return digests, nil
+}
@@ -0,0 +1,221 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containers/image/signature"
+	"github.com/containers/image/transports/alltransports"
+	"github.com/opencontainers/go-digest"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kustomizeimage "sigs.k8s.io/kustomize/v3/pkg/image"
+)
+
+func Test_ListImages(t *testing.T) {
+	upstreamDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(upstreamDir)
+
+	deployment := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.31
+      containers:
+        - name: web
+          image: nginx:1.17
+        - name: sidecar
+          image: registry.example.com/app/sidecar@sha256:d2ae9e7e6c6a0a4a8d0c5b8d5ed5a0e8e3c1e1bb3f3b0a3f39f3a7a7e0fbd3c9
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: cache
+spec:
+  containers:
+    - name: redis
+      image: redis:5
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(upstreamDir, "app.yaml"), []byte(deployment), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(upstreamDir, "more"), 0755))
+	pod := `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:1.17
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(upstreamDir, "more", "pod.yaml"), []byte(pod), 0644))
+
+	images, err := ListImages(upstreamDir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"busybox:1.31",
+		"nginx:1.17",
+		"redis:5",
+		"registry.example.com/app/sidecar@sha256:d2ae9e7e6c6a0a4a8d0c5b8d5ed5a0e8e3c1e1bb3f3b0a3f39f3a7a7e0fbd3c9",
+	}, images)
+}
+
+func Test_RunCopyPipeline(t *testing.T) {
+	var running, maxRunning int32
+	tasks := []CopyTask{}
+	for i := 0; i < 6; i++ {
+		i := i
+		tasks = append(tasks, CopyTask{
+			Image: fmt.Sprintf("image-%d", i),
+			Copy: func(reportWriter io.Writer) ([]kustomizeimage.Image, bool, error) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				// later tasks finish first
+				time.Sleep(time.Duration(6-i) * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return []kustomizeimage.Image{{Name: fmt.Sprintf("image-%d", i)}}, i%2 == 0, nil
+			},
+		})
+	}
+
+	progress := []CopyProgress{}
+	var reported bytes.Buffer
+	newImages, err := RunCopyPipeline(tasks, CopyPipelineOptions{
+		Parallelism: 2,
+		OnProgress: func(p CopyProgress) {
+			progress = append(progress, p)
+		},
+		ProgressReporter: logger.NewJSONProgressReporter(&reported),
+	})
+	require.NoError(t, err)
+
+	// results are in the order of the tasks, not the order they finished in
+	names := []string{}
+	for _, newImage := range newImages {
+		names = append(names, newImage.Name)
+	}
+	assert.Equal(t, []string{"image-0", "image-1", "image-2", "image-3", "image-4", "image-5"}, names)
+	assert.Equal(t, int32(2), maxRunning)
+
+	require.Len(t, progress, 6)
+	skipped := 0
+	for i, p := range progress {
+		assert.Equal(t, i+1, p.Completed)
+		assert.Equal(t, 6, p.Total)
+		if p.Skipped {
+			skipped++
+		}
+	}
+	assert.Equal(t, 3, skipped)
+
+	lines := strings.Split(strings.TrimSpace(reported.String()), "\n")
+	require.Len(t, lines, 7)
+	assert.Equal(t, `{"step":"Transferring images","current":6,"total":6,"unit":"items","done":true}`, lines[6])
+}
+
+func Test_RunCopyPipelineError(t *testing.T) {
+	var started int32
+	tasks := []CopyTask{}
+	for i := 0; i < 10; i++ {
+		i := i
+		tasks = append(tasks, CopyTask{
+			Image: fmt.Sprintf("image-%d", i),
+			Copy: func(reportWriter io.Writer) ([]kustomizeimage.Image, bool, error) {
+				atomic.AddInt32(&started, 1)
+				if i == 0 {
+					return nil, false, fmt.Errorf("unauthorized")
+				}
+				return nil, false, nil
+			},
+		})
+	}
+
+	_, err := RunCopyPipeline(tasks, CopyPipelineOptions{Parallelism: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to transfer image image-0: unauthorized")
+	assert.Equal(t, int32(1), started)
+}
+
+func Test_isImageInRegistry(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots-image-test")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	srcDir := filepath.Join(tempDir, "src")
+	req.NoError(os.MkdirAll(srcDir, 0755))
+	writeTestDockerImage(t, srcDir)
+	srcRef, err := alltransports.ParseImageName("dir:" + srcDir)
+	req.NoError(err)
+
+	policy, err := signature.NewPolicyFromBytes(imagePolicy)
+	req.NoError(err)
+	policyContext, err := signature.NewPolicyContext(policy)
+	req.NoError(err)
+
+	// nothing has been copied yet
+	convertedRef, err := alltransports.ParseImageName("dir:" + filepath.Join(tempDir, "converted"))
+	req.NoError(err)
+	exists, err := isImageInRegistry(convertedRef, srcRef, nil, nil)
+	req.NoError(err)
+	assert.False(t, exists)
+
+	// the manifest of an image that was converted to oci has another digest, but it's the same image
+	convertedDigest, err := copyImageToRegistry(policyContext, convertedRef, srcRef, nil, nil, true, ioutil.Discard)
+	req.NoError(err)
+	req.NotEmpty(convertedDigest)
+	exists, err = isImageInRegistry(convertedRef, srcRef, nil, nil)
+	req.NoError(err)
+	assert.True(t, exists)
+
+	unchangedRef, err := alltransports.ParseImageName("dir:" + filepath.Join(tempDir, "unchanged"))
+	req.NoError(err)
+	_, err = copyImageToRegistry(policyContext, unchangedRef, srcRef, nil, nil, false, ioutil.Discard)
+	req.NoError(err)
+	exists, err = isImageInRegistry(unchangedRef, srcRef, nil, nil)
+	req.NoError(err)
+	assert.True(t, exists)
+
+	// an image with another layer is copied again
+	otherDir := filepath.Join(tempDir, "other")
+	req.NoError(os.MkdirAll(otherDir, 0755))
+	otherManifest := writeTestDockerImage(t, otherDir)
+	otherLayer := []byte("not the same layer")
+	otherLayerDigest := digest.FromBytes(otherLayer)
+	req.NoError(ioutil.WriteFile(filepath.Join(otherDir, otherLayerDigest.Hex()), otherLayer, 0644))
+	imageManifest := map[string]interface{}{}
+	req.NoError(json.Unmarshal(otherManifest, &imageManifest))
+	imageManifest["layers"] = []map[string]interface{}{
+		{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": len(otherLayer), "digest": otherLayerDigest.String()},
+	}
+	otherManifest, err = json.Marshal(imageManifest)
+	req.NoError(err)
+	req.NoError(ioutil.WriteFile(filepath.Join(otherDir, "manifest.json"), otherManifest, 0644))
+
+	otherRef, err := alltransports.ParseImageName("dir:" + otherDir)
+	req.NoError(err)
+	exists, err = isImageInRegistry(otherRef, srcRef, nil, nil)
+	req.NoError(err)
+	assert.False(t, exists)
+}
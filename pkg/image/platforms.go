@@ -0,0 +1,236 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/containers/image/copy"
+	imagedocker "github.com/containers/image/docker"
+	dockerref "github.com/containers/image/docker/reference"
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/signature"
+	"github.com/containers/image/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// Platform is an os and architecture, with an optional variant (e.g. linux/arm64/v8)
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string
+}
+
+func (p Platform) String() string {
+	s := fmt.Sprintf("%s/%s", p.OS, p.Architecture)
+	if p.Variant != "" {
+		s = fmt.Sprintf("%s/%s", s, p.Variant)
+	}
+	return s
+}
+
+// ParsePlatforms parses values in the form os/architecture[/variant]. The os defaults to linux
+// when only the architecture is given.
+func ParsePlatforms(values []string) ([]Platform, error) {
+	platforms := []Platform{}
+	for _, value := range values {
+		parts := strings.Split(value, "/")
+		for _, part := range parts {
+			if part == "" {
+				return nil, errors.Errorf("invalid platform %q, expected os/architecture[/variant]", value)
+			}
+		}
+
+		switch len(parts) {
+		case 1:
+			platforms = append(platforms, Platform{OS: "linux", Architecture: parts[0]})
+		case 2:
+			platforms = append(platforms, Platform{OS: parts[0], Architecture: parts[1]})
+		case 3:
+			platforms = append(platforms, Platform{OS: parts[0], Architecture: parts[1], Variant: parts[2]})
+		default:
+			return nil, errors.Errorf("invalid platform %q, expected os/architecture[/variant]", value)
+		}
+	}
+
+	return platforms, nil
+}
+
+// matchesPlatforms is true when the platform of an image in a manifest list is one of the platforms, or
+// there are no platforms to filter by. A platform without a variant matches all variants.
+func matchesPlatforms(platforms []Platform, os, architecture, variant string) bool {
+	if len(platforms) == 0 {
+		return true
+	}
+
+	for _, p := range platforms {
+		if p.OS != os || p.Architecture != architecture {
+			continue
+		}
+		if p.Variant == "" || p.Variant == variant {
+			return true
+		}
+	}
+	return false
+}
+
+type manifestListPlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type manifestListEntry struct {
+	Digest   digest.Digest         `json:"digest"`
+	Platform *manifestListPlatform `json:"platform,omitempty"`
+}
+
+func isManifestList(mimeType string) bool {
+	return manifest.MIMETypeIsMultiImage(mimeType) || mimeType == imgspecv1.MediaTypeImageIndex
+}
+
+// filterManifestList removes the images that aren't for one of the platforms from a docker manifest
+// list or oci index, and returns the digests of the images that are left. The list is returned
+// unchanged when all of its images are kept, so that its digest doesn't change.
+func filterManifestList(list []byte, platforms []Platform) ([]byte, []digest.Digest, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(list, &raw); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse manifest list")
+	}
+	rawEntries := []json.RawMessage{}
+	if err := json.Unmarshal(raw["manifests"], &rawEntries); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse manifest list entries")
+	}
+
+	kept := []json.RawMessage{}
+	digests := []digest.Digest{}
+	for _, rawEntry := range rawEntries {
+		entry := manifestListEntry{}
+		if err := json.Unmarshal(rawEntry, &entry); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to parse manifest list entry")
+		}
+
+		platform := manifestListPlatform{}
+		if entry.Platform != nil {
+			platform = *entry.Platform
+		}
+		if !matchesPlatforms(platforms, platform.OS, platform.Architecture, platform.Variant) {
+			continue
+		}
+
+		kept = append(kept, rawEntry)
+		digests = append(digests, entry.Digest)
+	}
+
+	if len(kept) == 0 {
+		return nil, nil, errors.Errorf("manifest list has no images for platforms %s", platformsString(platforms))
+	}
+	if len(kept) == len(rawEntries) {
+		return list, digests, nil
+	}
+
+	keptJSON, err := json.Marshal(kept)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to marshal manifest list entries")
+	}
+	raw["manifests"] = keptJSON
+
+	filtered, err := json.MarshalIndent(raw, "", "   ")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to marshal manifest list")
+	}
+
+	return filtered, digests, nil
+}
+
+// copyManifestList copies each image of a multi-architecture source that is for one of the
+// platforms, and then the manifest list that references them. It returns false when the source
+// is a single image. The digest of the pushed list is returned when images were filtered out.
+func copyManifestList(policyContext *signature.PolicyContext, destRef, srcRef types.ImageReference, sourceCtx, destCtx *types.SystemContext, platforms []Platform, reportWriter io.Writer) (string, bool, error) {
+	ctx := context.Background()
+
+	src, err := srcRef.NewImageSource(ctx, sourceCtx)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to create image source")
+	}
+	list, mimeType, err := src.GetManifest(ctx, nil)
+	src.Close()
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to get manifest")
+	}
+	if !isManifestList(manifest.NormalizedMIMEType(mimeType)) {
+		return "", false, nil
+	}
+
+	filtered, digests, err := filterManifestList(list, platforms)
+	if err != nil {
+		return "", false, err
+	}
+
+	srcName := dockerref.TrimNamed(srcRef.DockerReference())
+	destName := dockerref.TrimNamed(destRef.DockerReference())
+	for _, d := range digests {
+		instanceSrcRef, err := digestedReference(srcName, d)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "failed to create source reference for %s", d)
+		}
+		instanceDestRef, err := digestedReference(destName, d)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "failed to create destination reference for %s", d)
+		}
+
+		// the images are copied as they are, so that their digests match the list
+		_, err = copy.Image(ctx, policyContext, instanceDestRef, instanceSrcRef, &copy.Options{
+			RemoveSignatures: true,
+			ReportWriter:     reportWriter,
+			SourceCtx:        sourceCtx,
+			DestinationCtx:   destCtx,
+		})
+		if err != nil {
+			return "", false, errors.Wrapf(err, "failed to copy image %s", d)
+		}
+	}
+
+	dest, err := destRef.NewImageDestination(ctx, destCtx)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to create image destination")
+	}
+	defer dest.Close()
+
+	if err := dest.PutManifest(ctx, filtered); err != nil {
+		return "", false, errors.Wrap(err, "failed to push manifest list")
+	}
+	if err := dest.Commit(ctx); err != nil {
+		return "", false, errors.Wrap(err, "failed to commit manifest list")
+	}
+
+	if string(filtered) == string(list) {
+		return "", true, nil
+	}
+
+	filteredDigest, err := manifest.Digest(filtered)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to calculate digest of manifest list")
+	}
+	return filteredDigest.String(), true, nil
+}
+
+func digestedReference(name dockerref.Named, d digest.Digest) (types.ImageReference, error) {
+	digested, err := dockerref.WithDigest(name, d)
+	if err != nil {
+		return nil, err
+	}
+	return imagedocker.NewReference(digested)
+}
+
+func platformsString(platforms []Platform) string {
+	s := []string{}
+	for _, p := range platforms {
+		s = append(s, p.String())
+	}
+	return strings.Join(s, ",")
+}
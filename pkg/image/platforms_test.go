@@ -0,0 +1,135 @@
+package image
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParsePlatforms(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []string
+		expected    []Platform
+		expectError bool
+	}{
+		{
+			name:     "none",
+			values:   []string{},
+			expected: []Platform{},
+		},
+		{
+			name:   "os, architecture and variant",
+			values: []string{"arm64", "linux/amd64", "linux/arm/v7"},
+			expected: []Platform{
+				{OS: "linux", Architecture: "arm64"},
+				{OS: "linux", Architecture: "amd64"},
+				{OS: "linux", Architecture: "arm", Variant: "v7"},
+			},
+		},
+		{
+			name:        "empty part",
+			values:      []string{"linux/"},
+			expectError: true,
+		},
+		{
+			name:        "too many parts",
+			values:      []string{"linux/arm/v7/extra"},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			platforms, err := ParsePlatforms(test.values)
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, platforms)
+		})
+	}
+}
+
+func Test_filterManifestList(t *testing.T) {
+	amd64 := digest.FromString("amd64")
+	arm64 := digest.FromString("arm64")
+	armv7 := digest.FromString("armv7")
+
+	list := []byte(`{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+   "manifests": [
+      {"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "size": 1, "digest": "` + amd64.String() + `", "platform": {"architecture": "amd64", "os": "linux"}},
+      {"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "size": 1, "digest": "` + arm64.String() + `", "platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}},
+      {"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "size": 1, "digest": "` + armv7.String() + `", "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}}
+   ]
+}`)
+
+	tests := []struct {
+		name            string
+		platforms       []Platform
+		expectedDigests []digest.Digest
+		expectUnchanged bool
+		expectError     bool
+	}{
+		{
+			name:            "all platforms",
+			platforms:       []Platform{},
+			expectedDigests: []digest.Digest{amd64, arm64, armv7},
+			expectUnchanged: true,
+		},
+		{
+			name:            "arm64 matches any variant",
+			platforms:       []Platform{{OS: "linux", Architecture: "arm64"}},
+			expectedDigests: []digest.Digest{arm64},
+		},
+		{
+			name:            "arm with variant",
+			platforms:       []Platform{{OS: "linux", Architecture: "arm", Variant: "v7"}, {OS: "linux", Architecture: "amd64"}},
+			expectedDigests: []digest.Digest{amd64, armv7},
+		},
+		{
+			name:        "no matching platform",
+			platforms:   []Platform{{OS: "windows", Architecture: "amd64"}},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filtered, digests, err := filterManifestList(list, test.platforms)
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedDigests, digests)
+
+			if test.expectUnchanged {
+				assert.Equal(t, list, filtered)
+				return
+			}
+
+			parsed := struct {
+				SchemaVersion int    `json:"schemaVersion"`
+				MediaType     string `json:"mediaType"`
+				Manifests     []struct {
+					Digest digest.Digest `json:"digest"`
+				} `json:"manifests"`
+			}{}
+			require.NoError(t, json.Unmarshal(filtered, &parsed))
+			assert.Equal(t, 2, parsed.SchemaVersion)
+			assert.Equal(t, "application/vnd.docker.distribution.manifest.list.v2+json", parsed.MediaType)
+			filteredDigests := []digest.Digest{}
+			for _, m := range parsed.Manifests {
+				filteredDigests = append(filteredDigests, m.Digest)
+			}
+			assert.Equal(t, test.expectedDigests, filteredDigests)
+		})
+	}
+}
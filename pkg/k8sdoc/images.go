@@ -0,0 +1,271 @@
+package k8sdoc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// ImageLocation describes where images are referenced in documents of a kind.
+//
+// ImagePaths use a small subset of JSONPath: dot separated field names, "[*]" to
+// descend into every element of a list, and "[field=pattern]" to descend into the list
+// elements whose field matches a glob pattern, e.g.
+//
+//	spec.template.spec.containers[*].env[name=RELATED_IMAGE_*].value
+//
+// A location with an empty Kind applies to every document that no other location matches.
+type ImageLocation struct {
+	APIVersion  string   `yaml:"apiVersion,omitempty"`
+	Kind        string   `yaml:"kind"`
+	ImagePaths  []string `yaml:"imagePaths"`
+	PodSpecPath string   `yaml:"podSpecPath,omitempty"`
+}
+
+// DefaultImageLocations returns the image locations of the built in workload kinds.
+func DefaultImageLocations() []ImageLocation {
+	locations := []ImageLocation{}
+
+	for _, kind := range []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job"} {
+		locations = append(locations, podSpecImageLocation(kind, "spec.template.spec"))
+	}
+	locations = append(locations, podSpecImageLocation("CronJob", "spec.jobTemplate.spec.template.spec"))
+	locations = append(locations, podSpecImageLocation("Pod", "spec"))
+
+	// anything else that looks like it has a pod template, this matches what was supported before
+	locations = append(locations, podSpecImageLocation("", "spec.template.spec"))
+
+	return locations
+}
+
+// ValidateImageLocations checks that all image paths can be parsed
+func ValidateImageLocations(locations []ImageLocation) error {
+	for _, location := range locations {
+		for _, imagePath := range location.ImagePaths {
+			if _, err := parseImagePath(imagePath); err != nil {
+				return errors.Wrapf(err, "invalid image path %q for kind %q", imagePath, location.Kind)
+			}
+		}
+	}
+
+	return nil
+}
+
+func podSpecImageLocation(kind string, podSpecPath string) ImageLocation {
+	imagePaths := []string{}
+	for _, containers := range []string{"containers", "initContainers", "ephemeralContainers"} {
+		imagePaths = append(imagePaths,
+			fmt.Sprintf("%s.%s[*].image", podSpecPath, containers),
+			fmt.Sprintf("%s.%s[*].env[name=RELATED_IMAGE_*].value", podSpecPath, containers),
+		)
+	}
+
+	return ImageLocation{
+		Kind:        kind,
+		ImagePaths:  imagePaths,
+		PodSpecPath: podSpecPath,
+	}
+}
+
+// FindImages parses a single yaml document and returns the images referenced in it
+func FindImages(content []byte, locations []ImageLocation) ([]string, *Doc, error) {
+	// only the header is parsed into a typed struct, custom resources can use
+	// the same field names as pod templates for something else entirely
+	header := struct {
+		APIVersion string   `yaml:"apiVersion"`
+		Kind       string   `yaml:"kind"`
+		Metadata   Metadata `yaml:"metadata"`
+	}{}
+	if err := yaml.Unmarshal(content, &header); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal doc")
+	}
+	doc := &Doc{
+		APIVersion: header.APIVersion,
+		Kind:       header.Kind,
+		Metadata:   header.Metadata,
+	}
+
+	obj := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(content, &obj); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal object")
+	}
+
+	images := []string{}
+	seen := map[string]bool{}
+	for _, location := range matchingLocations(doc, locations) {
+		found := false
+		for _, imagePath := range location.ImagePaths {
+			segments, err := parseImagePath(imagePath)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to parse image path %q", imagePath)
+			}
+
+			for _, value := range walkImagePath(obj, segments) {
+				image, ok := value.(string)
+				if !ok || image == "" {
+					continue
+				}
+				found = true
+				if seen[image] {
+					continue
+				}
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+
+		if found && doc.PodSpecPath == "" {
+			doc.PodSpecPath = location.PodSpecPath
+		}
+	}
+
+	return images, doc, nil
+}
+
+// PullSecretPatch returns a strategic merge patch that adds an image pull secret
+// to the pod spec of the document, or nil if the document has no known pod spec.
+func (d *Doc) PullSecretPatch(secretName string) map[string]interface{} {
+	if d.PodSpecPath == "" {
+		return nil
+	}
+
+	metadata := map[string]interface{}{
+		"name": d.Metadata.Name,
+	}
+	if d.Metadata.Namespace != "" {
+		metadata["namespace"] = d.Metadata.Namespace
+	}
+
+	var current interface{} = map[string]interface{}{
+		"imagePullSecrets": []map[string]string{
+			{"name": secretName},
+		},
+	}
+	fields := strings.Split(d.PodSpecPath, ".")
+	for i := len(fields) - 1; i >= 0; i-- {
+		current = map[string]interface{}{
+			fields[i]: current,
+		}
+	}
+
+	patch := current.(map[string]interface{})
+	patch["apiVersion"] = d.APIVersion
+	patch["kind"] = d.Kind
+	patch["metadata"] = metadata
+
+	return patch
+}
+
+func matchingLocations(doc *Doc, locations []ImageLocation) []ImageLocation {
+	matching := []ImageLocation{}
+	fallback := []ImageLocation{}
+	for _, location := range locations {
+		if location.Kind == "" {
+			fallback = append(fallback, location)
+			continue
+		}
+		if location.Kind != doc.Kind {
+			continue
+		}
+		if location.APIVersion != "" && location.APIVersion != doc.APIVersion {
+			continue
+		}
+		matching = append(matching, location)
+	}
+
+	if len(matching) > 0 {
+		return matching
+	}
+	return fallback
+}
+
+type pathSegment struct {
+	field       string
+	allElements bool
+	filterField string
+	filterGlob  string
+}
+
+func parseImagePath(imagePath string) ([]pathSegment, error) {
+	segments := []pathSegment{}
+	for _, part := range strings.Split(imagePath, ".") {
+		segment := pathSegment{field: part}
+
+		if i := strings.Index(part, "["); i >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, errors.Errorf("unterminated selector in %q", part)
+			}
+			segment.field = part[:i]
+
+			selector := part[i+1 : len(part)-1]
+			if selector == "*" {
+				segment.allElements = true
+			} else {
+				filter := strings.SplitN(selector, "=", 2)
+				if len(filter) != 2 {
+					return nil, errors.Errorf("unsupported selector %q", selector)
+				}
+				if _, err := filepath.Match(filter[1], ""); err != nil {
+					return nil, errors.Wrapf(err, "invalid pattern %q", filter[1])
+				}
+				segment.filterField = filter[0]
+				segment.filterGlob = filter[1]
+			}
+		}
+
+		if segment.field == "" {
+			return nil, errors.Errorf("empty field name in %q", imagePath)
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
+
+func walkImagePath(obj interface{}, segments []pathSegment) []interface{} {
+	if len(segments) == 0 {
+		return []interface{}{obj}
+	}
+
+	m, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+	segment := segments[0]
+	value, ok := m[segment.field]
+	if !ok {
+		return nil
+	}
+
+	if !segment.allElements && segment.filterField == "" {
+		return walkImagePath(value, segments[1:])
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	values := []interface{}{}
+	for _, element := range list {
+		if segment.filterField != "" {
+			elementMap, ok := element.(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			fieldValue, ok := elementMap[segment.filterField].(string)
+			if !ok {
+				continue
+			}
+			if matched, _ := filepath.Match(segment.filterGlob, fieldValue); !matched {
+				continue
+			}
+		}
+		values = append(values, walkImagePath(element, segments[1:])...)
+	}
+
+	return values
+}
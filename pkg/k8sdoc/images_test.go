@@ -0,0 +1,184 @@
+package k8sdoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_FindImages(t *testing.T) {
+	tests := []struct {
+		name                string
+		content             string
+		additionalLocations []ImageLocation
+		expectImages        []string
+		expectPodSpecPath   string
+	}{
+		{
+			name: "deployment with init container and related image env",
+			content: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: quay.io/replicatedcom/init:1
+      containers:
+      - name: app
+        image: quay.io/replicatedcom/app:1
+        env:
+        - name: RELATED_IMAGE_WORKER
+          value: quay.io/replicatedcom/worker:1
+        - name: OTHER
+          value: not-an-image`,
+			expectImages: []string{
+				"quay.io/replicatedcom/app:1",
+				"quay.io/replicatedcom/worker:1",
+				"quay.io/replicatedcom/init:1",
+			},
+			expectPodSpecPath: "spec.template.spec",
+		},
+		{
+			name: "cronjob",
+			content: `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: my-job
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: job
+            image: quay.io/replicatedcom/job:1`,
+			expectImages:      []string{"quay.io/replicatedcom/job:1"},
+			expectPodSpecPath: "spec.jobTemplate.spec.template.spec",
+		},
+		{
+			name: "pod with ephemeral container",
+			content: `apiVersion: v1
+kind: Pod
+metadata:
+  name: my-pod
+spec:
+  containers:
+  - name: app
+    image: quay.io/replicatedcom/app:1
+  ephemeralContainers:
+  - name: debug
+    image: quay.io/replicatedcom/debug:1`,
+			expectImages: []string{
+				"quay.io/replicatedcom/app:1",
+				"quay.io/replicatedcom/debug:1",
+			},
+			expectPodSpecPath: "spec",
+		},
+		{
+			name: "custom resource without configured location",
+			content: `apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: my-db
+spec:
+  image: quay.io/replicatedcom/db:1`,
+			expectImages:      []string{},
+			expectPodSpecPath: "",
+		},
+		{
+			name: "custom resource with configured location",
+			content: `apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: my-db
+spec:
+  image: quay.io/replicatedcom/db:1
+  sidecars:
+  - image: quay.io/replicatedcom/exporter:1`,
+			additionalLocations: []ImageLocation{
+				{
+					Kind:       "Database",
+					ImagePaths: []string{"spec.image", "spec.sidecars[*].image"},
+				},
+			},
+			expectImages: []string{
+				"quay.io/replicatedcom/db:1",
+				"quay.io/replicatedcom/exporter:1",
+			},
+			expectPodSpecPath: "",
+		},
+		{
+			name: "unknown kind with pod template",
+			content: `apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: my-rollout
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: quay.io/replicatedcom/app:1`,
+			expectImages:      []string{"quay.io/replicatedcom/app:1"},
+			expectPodSpecPath: "spec.template.spec",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			locations := append(DefaultImageLocations(), test.additionalLocations...)
+			images, doc, err := FindImages([]byte(test.content), locations)
+			req.NoError(err)
+
+			assert.Equal(t, test.expectImages, images)
+			assert.Equal(t, test.expectPodSpecPath, doc.PodSpecPath)
+		})
+	}
+}
+
+func Test_PullSecretPatch(t *testing.T) {
+	doc := &Doc{
+		APIVersion:  "batch/v1beta1",
+		Kind:        "CronJob",
+		Metadata:    Metadata{Name: "my-job"},
+		PodSpecPath: "spec.jobTemplate.spec.template.spec",
+	}
+
+	b, err := yaml.Marshal(doc.PullSecretPatch("my-secret"))
+	require.NoError(t, err)
+
+	expect := `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: my-job
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          imagePullSecrets:
+          - name: my-secret
+`
+	assert.Equal(t, expect, string(b))
+
+	assert.Nil(t, (&Doc{Kind: "Database"}).PullSecretPatch("my-secret"))
+}
+
+func Test_ValidateImageLocations(t *testing.T) {
+	valid := []ImageLocation{
+		{Kind: "Database", ImagePaths: []string{"spec.containers[*].env[name=RELATED_IMAGE_*].value"}},
+	}
+	assert.NoError(t, ValidateImageLocations(valid))
+
+	invalid := []ImageLocation{
+		{Kind: "Database", ImagePaths: []string{"spec.containers[0"}},
+	}
+	assert.Error(t, ValidateImageLocations(invalid))
+}
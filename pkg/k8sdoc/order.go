@@ -0,0 +1,112 @@
+package k8sdoc
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// HookAnnotation is the deploy phase of an object that was created from a helm hook
+	HookAnnotation = "kots.io/hook"
+	// HookWeightAnnotation orders objects within the same deploy phase, lowest first
+	HookWeightAnnotation = "kots.io/hook-weight"
+	// HookDeletePolicyAnnotation is copied from the helm hook and says when the object can be removed
+	HookDeletePolicyAnnotation = "kots.io/hook-delete-policy"
+)
+
+// hook phases, in the order they are deployed
+const (
+	HookPhaseCRDInstall  = "crd-install"
+	HookPhasePreInstall  = "pre-install"
+	HookPhasePostInstall = "post-install"
+)
+
+// objects without a hook are deployed between the pre-install and post-install phases
+var hookPhaseOrder = map[string]int{
+	HookPhaseCRDInstall:  0,
+	HookPhasePreInstall:  1,
+	"":                   2,
+	HookPhasePostInstall: 3,
+}
+
+// kindOrder is the order that kinds are deployed in within a phase, so that objects are created
+// after the objects they use. Kinds that aren't listed are last.
+var kindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+// Position is where an object is deployed: by hook phase, then by hook weight and then by kind.
+// Release files, base files and the objects that are applied are all ordered by it.
+type Position struct {
+	HookPhase  string
+	HookWeight int
+	Kind       string
+}
+
+// PositionOf returns the position of an object from its kind and its kots hook annotations
+func PositionOf(kind string, annotations map[string]string) Position {
+	weight, _ := strconv.Atoi(strings.TrimSpace(annotations[HookWeightAnnotation]))
+	return Position{
+		HookPhase:  annotations[HookAnnotation],
+		HookWeight: weight,
+		Kind:       kind,
+	}
+}
+
+// Less is true when an object at p is deployed before an object at other
+func (p Position) Less(other Position) bool {
+	if phaseRank(p.HookPhase) != phaseRank(other.HookPhase) {
+		return phaseRank(p.HookPhase) < phaseRank(other.HookPhase)
+	}
+	if p.HookWeight != other.HookWeight {
+		return p.HookWeight < other.HookWeight
+	}
+	return KindRank(p.Kind) < KindRank(other.Kind)
+}
+
+// phaseRank is the position of the hook phase, phases that aren't known are deployed with the
+// objects without a hook
+func phaseRank(phase string) int {
+	if rank, ok := hookPhaseOrder[phase]; ok {
+		return rank
+	}
+	return hookPhaseOrder[""]
+}
+
+// KindRank is the position of the kind in the deploy order, kinds that aren't known are last
+func KindRank(kind string) int {
+	for i, k := range kindOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(kindOrder)
+}
@@ -0,0 +1,36 @@
+package k8sdoc
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PositionLess(t *testing.T) {
+	positions := []Position{
+		PositionOf("Widget", nil),
+		PositionOf("Deployment", nil),
+		PositionOf("Job", map[string]string{HookAnnotation: HookPhasePostInstall}),
+		PositionOf("Job", map[string]string{HookAnnotation: HookPhasePreInstall, HookWeightAnnotation: "5"}),
+		PositionOf("ConfigMap", map[string]string{HookAnnotation: HookPhasePreInstall, HookWeightAnnotation: "-1"}),
+		PositionOf("CustomResourceDefinition", nil),
+		PositionOf("Namespace", nil),
+		PositionOf("CustomResourceDefinition", map[string]string{HookAnnotation: HookPhaseCRDInstall}),
+	}
+
+	sort.SliceStable(positions, func(i, j int) bool {
+		return positions[i].Less(positions[j])
+	})
+
+	assert.Equal(t, []Position{
+		{HookPhase: HookPhaseCRDInstall, Kind: "CustomResourceDefinition"},
+		{HookPhase: HookPhasePreInstall, HookWeight: -1, Kind: "ConfigMap"},
+		{HookPhase: HookPhasePreInstall, HookWeight: 5, Kind: "Job"},
+		{Kind: "Namespace"},
+		{Kind: "CustomResourceDefinition"},
+		{Kind: "Deployment"},
+		{Kind: "Widget"},
+		{HookPhase: HookPhasePostInstall, Kind: "Job"},
+	}, positions)
+}
@@ -7,10 +7,16 @@ type Doc struct {
 	Kind       string   `yaml:"kind"`
 	Metadata   Metadata `yaml:"metadata"`
 	Spec       Spec     `yaml:"spec"`
+
+	// PodSpecPath is the location of the pod spec in this document, if it has one.
+	// Documents without a known pod spec can have their images rewritten, but
+	// cannot be patched with image pull secrets.
+	PodSpecPath string `yaml:"-"`
 }
 
 type Metadata struct {
-	Name string `yaml:"name"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
 }
 
 type Spec struct {
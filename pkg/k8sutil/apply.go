@@ -0,0 +1,151 @@
+package k8sutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ClusterClients are the clients that objects are applied and compared with
+type ClusterClients struct {
+	Dynamic dynamic.Interface
+	// Mapper caches discovery, it can be reset when kinds are added to the cluster
+	Mapper meta.RESTMapper
+	// Namespace is the namespace of the context, for objects that don't have one
+	Namespace string
+}
+
+// GetClusterClients returns the clients of the cluster of the kubeconfig, or of the current context
+// when kubeconfig is nil
+func GetClusterClients(kubeconfig []byte) (*ClusterClients, error) {
+	var clientConfig clientcmd.ClientConfig
+	if kubeconfig != nil {
+		c, err := clientcmd.NewClientConfigFromBytes(kubeconfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load kubeconfig")
+		}
+		clientConfig = c
+	} else {
+		clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{})
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get namespace")
+	}
+
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create dynamic client")
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create discovery client")
+	}
+
+	return &ClusterClients{
+		Dynamic:   client,
+		Mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		Namespace: namespace,
+	}, nil
+}
+
+// DecodeObjects returns the objects in yaml or json manifests, empty documents are skipped
+func DecodeObjects(manifests []byte) ([]*unstructured.Unstructured, error) {
+	objs := []*unstructured.Unstructured{}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to decode manifests")
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+
+	return objs, nil
+}
+
+// WithLastAppliedConfig returns a copy of the object with itself as the last applied configuration,
+// like kubectl apply saves it
+func WithLastAppliedConfig(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	modified := obj.DeepCopy()
+	annotations := modified.GetAnnotations()
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	modified.SetAnnotations(annotations)
+
+	lastApplied, err := json.Marshal(modified.Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal last applied configuration")
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[corev1.LastAppliedConfigAnnotation] = string(lastApplied)
+	modified.SetAnnotations(annotations)
+
+	return modified, nil
+}
+
+// ThreeWayPatch returns the patch from the live object to the modified one, which removes the
+// fields that were in the last applied configuration and aren't anymore. Built in kinds are
+// patched with a strategic merge patch, so that lists are merged by their keys.
+func ThreeWayPatch(live *unstructured.Unstructured, modified *unstructured.Unstructured) (types.PatchType, []byte, error) {
+	original := []byte(live.GetAnnotations()[corev1.LastAppliedConfigAnnotation])
+	if len(original) == 0 {
+		original = []byte("{}")
+	}
+
+	modifiedJSON, err := json.Marshal(modified.Object)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to marshal modified object")
+	}
+	currentJSON, err := json.Marshal(live.Object)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to marshal live object")
+	}
+
+	versioned, err := scheme.Scheme.New(modified.GroupVersionKind())
+	if err != nil {
+		patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modifiedJSON, currentJSON)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "failed to create json merge patch")
+		}
+		return types.MergePatchType, patch, nil
+	}
+
+	patchMeta, err := strategicpatch.NewPatchMetaFromStruct(versioned)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to get patch meta")
+	}
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modifiedJSON, currentJSON, patchMeta, true)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to create strategic merge patch")
+	}
+	return types.StrategicMergePatchType, patch, nil
+}
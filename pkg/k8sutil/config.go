@@ -5,6 +5,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 )
@@ -52,3 +53,39 @@ func GetCurrentRules(kubeconfig, context string, clientset *kubernetes.Clientset
 
 	return rules, nil
 }
+
+// GetContextConfig returns the config of a context of the kubeconfig, or of its current context when
+// context is empty
+func GetContextConfig(kubeconfig string, context string) (*rest.Config, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: context}).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get client config")
+	}
+	return config, nil
+}
+
+// GetContextKubeconfig returns the kubeconfig with context as its current context, e.g. to pass to
+// GetClusterClients. It's the kubeconfig as it is when context is empty.
+func GetContextKubeconfig(kubeconfig string, context string) ([]byte, error) {
+	rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{}).RawConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get raw config")
+	}
+
+	if context != "" {
+		if _, found := rawConfig.Contexts[context]; !found {
+			return nil, errors.Errorf("no context: %q", context)
+		}
+		rawConfig.CurrentContext = context
+	}
+
+	b, err := clientcmd.Write(rawConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to write kubeconfig")
+	}
+	return b, nil
+}
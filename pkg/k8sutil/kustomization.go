@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/util"
 	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
 	"sigs.k8s.io/yaml"
 )
@@ -38,6 +39,10 @@ func (s kustPatches) Less(i, j int) bool {
 }
 
 func WriteKustomizationToFile(kustomization *kustomizetypes.Kustomization, file string) error {
+	return WriteKustomizationToFileWithModes(kustomization, file, util.FileModes{})
+}
+
+func WriteKustomizationToFileWithModes(kustomization *kustomizetypes.Kustomization, file string, modes util.FileModes) error {
 	sort.Strings(kustomization.Bases)
 	sort.Strings(kustomization.Resources)
 	sort.Sort(kustPatches(kustomization.PatchesStrategicMerge))
@@ -47,7 +52,7 @@ func WriteKustomizationToFile(kustomization *kustomizetypes.Kustomization, file
 		return errors.Wrap(err, "failed to marshal kustomization")
 	}
 
-	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+	if err := modes.WriteFile(file, b); err != nil {
 		return errors.Wrap(err, "failed to write kustomization file")
 	}
 
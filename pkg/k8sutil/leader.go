@@ -0,0 +1,102 @@
+package k8sutil
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+type LeaderTaskOptions struct {
+	// Namespace and LeaseName are the Lease that replicas of the task compete for
+	Namespace string
+	LeaseName string
+
+	// Identity of this replica, defaults to the hostname (the pod name) with a random suffix
+	Identity string
+
+	// LeaseDuration, RenewDeadline and RetryPeriod default to the values used by kubernetes controllers
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// RunLeaderTask runs task in only one replica at a time. The context passed to task is cancelled
+// when this replica stops being the leader, and task is run again if it becomes the leader again.
+// RunLeaderTask blocks until ctx is cancelled, and releases the lease when it returns.
+func RunLeaderTask(ctx context.Context, clientset kubernetes.Interface, options LeaderTaskOptions, task func(ctx context.Context)) error {
+	elector, err := newLeaderElector(clientset, options, task)
+	if err != nil {
+		return errors.Wrap(err, "failed to create leader elector")
+	}
+
+	for {
+		// Run returns when leadership is lost, so keep competing for the lease until the caller stops
+		elector.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+func newLeaderElector(clientset kubernetes.Interface, options LeaderTaskOptions, task func(ctx context.Context)) (*leaderelection.LeaderElector, error) {
+	if options.Namespace == "" || options.LeaseName == "" {
+		return nil, errors.New("namespace and lease name are required")
+	}
+
+	identity := options.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get hostname")
+		}
+		// the suffix keeps two processes in the same pod from both thinking they hold the lease
+		identity = hostname + "_" + uuid.New().String()
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      options.LeaseName,
+			Namespace: options.Namespace,
+		},
+		Client: clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            options.LeaseName,
+		LeaseDuration:   durationOrDefault(options.LeaseDuration, defaultLeaseDuration),
+		RenewDeadline:   durationOrDefault(options.RenewDeadline, defaultRenewDeadline),
+		RetryPeriod:     durationOrDefault(options.RetryPeriod, defaultRetryPeriod),
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: task,
+			OnStoppedLeading: func() {},
+		},
+	})
+}
+
+func durationOrDefault(d time.Duration, defaultDuration time.Duration) time.Duration {
+	if d == 0 {
+		return defaultDuration
+	}
+	return d
+}
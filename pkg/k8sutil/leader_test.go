@@ -0,0 +1,101 @@
+package k8sutil
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_RunLeaderTask(t *testing.T) {
+	req := require.New(t)
+	clientset := fake.NewSimpleClientset()
+
+	var mu sync.Mutex
+	running := map[string]bool{}
+	maxRunning := 0
+	started := make(chan string, 10)
+
+	runReplica := func(ctx context.Context, identity string) chan error {
+		done := make(chan error, 1)
+		options := LeaderTaskOptions{
+			Namespace:     "default",
+			LeaseName:     "test-task",
+			Identity:      identity,
+			LeaseDuration: 2 * time.Second,
+			RenewDeadline: time.Second,
+			RetryPeriod:   100 * time.Millisecond,
+		}
+		go func() {
+			done <- RunLeaderTask(ctx, clientset, options, func(taskCtx context.Context) {
+				mu.Lock()
+				running[identity] = true
+				count := 0
+				for _, r := range running {
+					if r {
+						count++
+					}
+				}
+				if count > maxRunning {
+					maxRunning = count
+				}
+				mu.Unlock()
+
+				started <- identity
+				<-taskCtx.Done()
+
+				mu.Lock()
+				running[identity] = false
+				mu.Unlock()
+			})
+		}()
+		return done
+	}
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := runReplica(ctxA, "a")
+
+	select {
+	case leader := <-started:
+		req.Equal("a", leader)
+	case <-time.After(5 * time.Second):
+		t.Fatal("first replica did not become the leader")
+	}
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	doneB := runReplica(ctxB, "b")
+
+	// b can't lead while a holds the lease
+	select {
+	case leader := <-started:
+		t.Fatalf("%s started while a was the leader", leader)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// a releases the lease when it stops, and b takes over
+	cancelA()
+	req.NoError(<-doneA)
+
+	select {
+	case leader := <-started:
+		req.Equal("b", leader)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second replica did not become the leader")
+	}
+
+	cancelB()
+	req.NoError(<-doneB)
+
+	mu.Lock()
+	defer mu.Unlock()
+	req.Equal(1, maxRunning)
+}
+
+func Test_RunLeaderTaskOptions(t *testing.T) {
+	err := RunLeaderTask(context.Background(), fake.NewSimpleClientset(), LeaderTaskOptions{Namespace: "default"}, func(context.Context) {})
+	require.Error(t, err)
+}
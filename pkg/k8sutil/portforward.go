@@ -20,6 +20,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/transport/spdy"
@@ -49,17 +50,53 @@ func IsPortAvailable(port int) bool {
 	return true
 }
 
+// AvailablePort returns a local port that nothing is listening on, to forward to when more than one
+// port forward is running
+func AvailablePort() (int, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to listen")
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
 func PortForward(kubeContext string, localPort int, remotePort int, namespace string, podName string, pollForAdditionalPorts bool, stopCh <-chan struct{}) (<-chan error, error) {
-	if !IsPortAvailable(localPort) {
-		return nil, errors.Errorf("Unable to connect to cluster. There's another process using port %d.", localPort)
+	config, err := clientcmd.BuildConfigFromFlags("", kubeContext)
+	if err != nil {
+		return nil, err
 	}
+	return portForward(config, kubeContext, localPort, remotePort, namespace, podName, "", pollForAdditionalPorts, stopCh)
+}
 
-	// port forward
+// PortForwardWithHealthCheck is PortForward for pods that don't respond with 200 on /, it waits for healthPath instead
+func PortForwardWithHealthCheck(kubeContext string, localPort int, remotePort int, namespace string, podName string, healthPath string, stopCh <-chan struct{}) (<-chan error, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", kubeContext)
 	if err != nil {
 		return nil, err
 	}
+	return portForward(config, kubeContext, localPort, remotePort, namespace, podName, healthPath, false, stopCh)
+}
+
+// PortForwardWithConfig is PortForward to a pod in the cluster of config, e.g. of a context that
+// isn't the current one. The port is forwarded until stopCh is closed.
+func PortForwardWithConfig(config *rest.Config, localPort int, remotePort int, namespace string, podName string, stopCh <-chan struct{}) (<-chan error, error) {
+	return portForward(config, "", localPort, remotePort, namespace, podName, "", false, stopCh)
+}
+
+// PortForwardWithConfigAndHealthCheck is PortForwardWithConfig for pods that don't respond with 200
+// on /, it waits for healthPath instead
+func PortForwardWithConfigAndHealthCheck(config *rest.Config, localPort int, remotePort int, namespace string, podName string, healthPath string, stopCh <-chan struct{}) (<-chan error, error) {
+	return portForward(config, "", localPort, remotePort, namespace, podName, healthPath, false, stopCh)
+}
+
+func portForward(config *rest.Config, kubeContext string, localPort int, remotePort int, namespace string, podName string, healthPath string, pollForAdditionalPorts bool, stopCh <-chan struct{}) (<-chan error, error) {
+	if !IsPortAvailable(localPort) {
+		return nil, errors.Errorf("Unable to connect to cluster. There's another process using port %d.", localPort)
+	}
 
+	// port forward
 	roundTripper, upgrader, err := spdy.RoundTripperFor(config)
 	if err != nil {
 		return nil, err
@@ -89,6 +126,13 @@ func PortForward(kubeContext string, localPort int, remotePort int, namespace st
 		return nil, err
 	}
 
+	if stopCh != nil {
+		go func() {
+			<-stopCh
+			close(stopChan)
+		}()
+	}
+
 	errChan := make(chan error, 2) // 2 go routines are writing to this channel
 
 	go func() {
@@ -123,7 +167,7 @@ func PortForward(kubeContext string, localPort int, remotePort int, namespace st
 			return nil, forwardErr
 		}
 
-		response, err := quickClient.Get(fmt.Sprintf("http://localhost:%d", localPort))
+		response, err := quickClient.Get(fmt.Sprintf("http://localhost:%d%s", localPort, healthPath))
 		if err == nil && response.StatusCode == http.StatusOK {
 			break
 		}
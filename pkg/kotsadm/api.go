@@ -15,36 +15,36 @@ import (
 
 var timeoutWaitingForAPI = time.Duration(time.Minute * 2)
 
-func getApiYAML(namespace, autoCreateClusterToken string) (map[string][]byte, error) {
+func getApiYAML(deployOptions DeployOptions) (map[string][]byte, error) {
 	docs := map[string][]byte{}
 	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
 
 	var role bytes.Buffer
-	if err := s.Encode(apiRole(namespace), &role); err != nil {
+	if err := s.Encode(apiRole(deployOptions.Namespace), &role); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal api role")
 	}
 	docs["api-role.yaml"] = role.Bytes()
 
 	var roleBinding bytes.Buffer
-	if err := s.Encode(apiRoleBinding(namespace), &roleBinding); err != nil {
+	if err := s.Encode(apiRoleBinding(deployOptions.Namespace), &roleBinding); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal api role binding")
 	}
 	docs["api-rolebinding.yaml"] = roleBinding.Bytes()
 
 	var serviceAccount bytes.Buffer
-	if err := s.Encode(apiServiceAccount(namespace), &serviceAccount); err != nil {
+	if err := s.Encode(apiServiceAccount(deployOptions.Namespace), &serviceAccount); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal api service account")
 	}
 	docs["api-serviceaccount.yaml"] = serviceAccount.Bytes()
 
 	var deployment bytes.Buffer
-	if err := s.Encode(apiDeployment(namespace, autoCreateClusterToken), &deployment); err != nil {
+	if err := s.Encode(apiDeployment(deployOptions), &deployment); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal api deployment")
 	}
 	docs["api-deployment.yaml"] = deployment.Bytes()
 
 	var service bytes.Buffer
-	if err := s.Encode(apiService(namespace), &service); err != nil {
+	if err := s.Encode(apiService(deployOptions), &service); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal api service")
 	}
 	docs["api-service.yaml"] = service.Bytes()
@@ -69,16 +69,18 @@ func waitForAPI(deployOptions *DeployOptions, clientset *kubernetes.Clientset) e
 			}
 		}
 
-		time.Sleep(time.Second)
+		if err := waitToPoll(deployOptions.context()); err != nil {
+			return err
+		}
 
-		if time.Now().Sub(start) > timeoutWaitingForAPI {
+		if time.Now().Sub(start) > deployOptions.TuningProfile.timeout(timeoutWaitingForAPI) {
 			return errors.New("timeout waiting for api pod")
 		}
 	}
 }
 
 func ensureAPI(deployOptions *DeployOptions, clientset *kubernetes.Clientset) error {
-	if err := ensureApiRBAC(deployOptions.Namespace, clientset); err != nil {
+	if err := ensureApiRBAC(*deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure api rbac")
 	}
 
@@ -89,14 +91,16 @@ func ensureAPI(deployOptions *DeployOptions, clientset *kubernetes.Clientset) er
 		return errors.Wrap(err, "failed to ensure api deployment")
 	}
 
-	if err := ensureAPIService(deployOptions.Namespace, clientset); err != nil {
+	if err := ensureAPIService(*deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure api service")
 	}
 
 	return nil
 }
 
-func ensureApiRBAC(namespace string, clientset *kubernetes.Clientset) error {
+func ensureApiRBAC(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	namespace := deployOptions.Namespace
+
 	if err := ensureApiRole(namespace, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure api role")
 	}
@@ -105,7 +109,7 @@ func ensureApiRBAC(namespace string, clientset *kubernetes.Clientset) error {
 		return errors.Wrap(err, "failed to ensure api role binding")
 	}
 
-	if err := ensureApiServiceAccount(namespace, clientset); err != nil {
+	if err := ensureApiServiceAccount(deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure api service account")
 	}
 
@@ -144,14 +148,20 @@ func ensureApiRoleBinding(namespace string, clientset *kubernetes.Clientset) err
 	return nil
 }
 
-func ensureApiServiceAccount(namespace string, clientset *kubernetes.Clientset) error {
+func ensureApiServiceAccount(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	namespace := deployOptions.Namespace
+
 	_, err := clientset.CoreV1().ServiceAccounts(namespace).Get("kotsadm-api", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get serviceaccouont")
 		}
 
-		_, err := clientset.CoreV1().ServiceAccounts(namespace).Create(apiServiceAccount(namespace))
+		serviceAccount := apiServiceAccount(namespace)
+		if err := applyPatches(deployOptions, serviceAccount); err != nil {
+			return errors.Wrap(err, "failed to patch api service account")
+		}
+		_, err := clientset.CoreV1().ServiceAccounts(namespace).Create(serviceAccount)
 		if err != nil {
 			return errors.Wrap(err, "failed to create serviceaccount")
 		}
@@ -161,29 +171,46 @@ func ensureApiServiceAccount(namespace string, clientset *kubernetes.Clientset)
 }
 
 func ensureAPIDeployment(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
-	_, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Get("kotsadm-api", metav1.GetOptions{})
+	existing, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Get("kotsadm-api", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing deployment")
 		}
 
-		_, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Create(apiDeployment(deployOptions.Namespace, deployOptions.AutoCreateClusterToken))
+		deployment := apiDeployment(deployOptions)
+		if err := applyPatches(deployOptions, deployment); err != nil {
+			return errors.Wrap(err, "failed to patch api deployment")
+		}
+		_, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Create(deployment)
 		if err != nil {
 			return errors.Wrap(err, "failed to create deployment")
 		}
+		return nil
+	}
+
+	deployment := apiDeployment(deployOptions)
+	if err := applyPatches(deployOptions, deployment); err != nil {
+		return errors.Wrap(err, "failed to patch api deployment")
+	}
+	if err := updateDeployment(deployOptions, clientset, existing, deployment); err != nil {
+		return errors.Wrap(err, "failed to update deployment")
 	}
 
 	return nil
 }
 
-func ensureAPIService(namespace string, clientset *kubernetes.Clientset) error {
-	_, err := clientset.CoreV1().Services(namespace).Get("kotsadm-api", metav1.GetOptions{})
+func ensureAPIService(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	_, err := clientset.CoreV1().Services(deployOptions.Namespace).Get("kotsadm-api", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing service")
 		}
 
-		_, err := clientset.CoreV1().Services(namespace).Create(apiService(namespace))
+		service := apiService(deployOptions)
+		if err := applyPatches(deployOptions, service); err != nil {
+			return errors.Wrap(err, "failed to patch api service")
+		}
+		_, err := clientset.CoreV1().Services(deployOptions.Namespace).Create(service)
 		if err != nil {
 			return errors.Wrap(err, "Failed to create service")
 		}
@@ -2,6 +2,7 @@ package kotsadm
 
 import (
 	"fmt"
+	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -21,6 +22,7 @@ func apiRole(namespace string) *rbacv1.Role {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-api-role",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		// creation cannot be restricted by name
 		Rules: []rbacv1.PolicyRule{
@@ -46,6 +48,12 @@ func apiRole(namespace string) *rbacv1.Role {
 				Resources: []string{"secrets"},
 				Verbs:     metav1.Verbs{"create"},
 			},
+			// leases are used to run background tasks in only one replica
+			{
+				APIGroups: []string{"coordination.k8s.io"},
+				Resources: []string{"leases"},
+				Verbs:     metav1.Verbs{"get", "create", "update"},
+			},
 		},
 	}
 
@@ -61,6 +69,7 @@ func apiRoleBinding(namespace string) *rbacv1.RoleBinding {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-api-rolebinding",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Subjects: []rbacv1.Subject{
 			{
@@ -88,13 +97,16 @@ func apiServiceAccount(namespace string) *corev1.ServiceAccount {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-api",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 	}
 
 	return serviceAccount
 }
 
-func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment {
+func apiDeployment(deployOptions DeployOptions) *appsv1.Deployment {
+	replicas := componentReplicas(deployOptions, "kotsadm-api")
+
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
@@ -102,9 +114,11 @@ func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-api",
-			Namespace: namespace,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app": "kotsadm-api",
@@ -117,6 +131,9 @@ func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment
 					},
 				},
 				Spec: corev1.PodSpec{
+					NodeSelector: deployOptions.NodeSelector,
+					Tolerations:  deployOptions.Tolerations,
+					Affinity:     componentAffinity(deployOptions, "kotsadm-api"),
 					SecurityContext: &corev1.PodSecurityContext{
 						RunAsUser: util.IntPointer(1001),
 					},
@@ -124,16 +141,17 @@ func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment
 					RestartPolicy:      corev1.RestartPolicyAlways,
 					Containers: []corev1.Container{
 						{
-							Image:           fmt.Sprintf("%s/kotsadm-api:%s", kotsadmRegistry(), kotsadmTag()),
+							Image:           kotsadmImage("kotsadm-api"),
 							ImagePullPolicy: corev1.PullAlways,
 							Name:            "kotsadm-api",
+							Resources:       deployOptions.Resources.API,
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "http",
 									ContainerPort: 3000,
 								},
 							},
-							ReadinessProbe: &corev1.Probe{
+							ReadinessProbe: deployOptions.TuningProfile.readinessProbe(&corev1.Probe{
 								FailureThreshold:    3,
 								InitialDelaySeconds: 10,
 								PeriodSeconds:       10,
@@ -144,7 +162,7 @@ func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment
 										Scheme: corev1.URISchemeHTTP,
 									},
 								},
-							},
+							}),
 							Env: []corev1.EnvVar{
 								{
 									Name: "SHARED_PASSWORD_BCRYPT",
@@ -167,11 +185,11 @@ func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment
 								},
 								{
 									Name:  "AUTO_CREATE_CLUSTER_TOKEN",
-									Value: autoCreateClusterToken,
+									Value: deployOptions.AutoCreateClusterToken,
 								},
 								{
 									Name:  "SHIP_API_ENDPOINT",
-									Value: fmt.Sprintf("http://kotsadm-api.%s.svc.cluster.local:3000", namespace),
+									Value: apiEndpoint(deployOptions),
 								},
 								{
 									Name:  "SHIP_API_ADVERTISE_ENDPOINT",
@@ -179,11 +197,11 @@ func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment
 								},
 								{
 									Name:  "S3_ENDPOINT",
-									Value: "http://kotsadm-minio:9000",
+									Value: objectStoreEndpoint(deployOptions),
 								},
 								{
 									Name:  "S3_BUCKET_NAME",
-									Value: "kotsadm",
+									Value: objectStoreBucket(deployOptions),
 								},
 								{
 									Name: "API_ENCRYPTION_KEY",
@@ -220,7 +238,7 @@ func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment
 								},
 								{
 									Name:  "S3_BUCKET_ENDPOINT",
-									Value: "true",
+									Value: strconv.FormatBool(!usesExternalObjectStore(deployOptions)),
 								},
 								{
 									Name: "SESSION_KEY",
@@ -268,17 +286,67 @@ func apiDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment
 		},
 	}
 
+	if deployOptions.EnableTLS {
+		// https is served on another port so that port-forwards from the cli keep using http
+		podSpec := &deployment.Spec.Template.Spec
+		podSpec.Volumes = append(podSpec.Volumes, tlsVolume(apiTLSSecretName, nil))
+		container := &podSpec.Containers[0]
+		container.VolumeMounts = append(container.VolumeMounts, tlsVolumeMount())
+		container.Ports = append(container.Ports, corev1.ContainerPort{
+			Name:          "https",
+			ContainerPort: apiHTTPSPort,
+		})
+		container.Env = append(container.Env,
+			corev1.EnvVar{
+				Name:  "TLS_PORT",
+				Value: fmt.Sprintf("%d", apiHTTPSPort),
+			},
+			corev1.EnvVar{
+				Name:  "TLS_CERT_FILE",
+				Value: tlsFile(corev1.TLSCertKey),
+			},
+			corev1.EnvVar{
+				Name:  "TLS_KEY_FILE",
+				Value: tlsFile(corev1.TLSPrivateKeyKey),
+			},
+			// trust the CA for connections to postgres and to the api itself
+			corev1.EnvVar{
+				Name:  "NODE_EXTRA_CA_CERTS",
+				Value: tlsFile("ca.crt"),
+			},
+		)
+	}
+
+	container := &deployment.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env, identityEnv(deployOptions)...)
+	container.Env = append(container.Env, apiObjectStoreEnv(deployOptions)...)
+
+	addProxy(deployOptions, &deployment.Spec.Template.Spec)
+	addSidecarInjection(deployOptions, &deployment.Spec.Template.ObjectMeta, &deployment.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &deployment.Spec.Template.Spec)
+
 	return deployment
 }
 
-func apiService(namespace string) *corev1.Service {
+func apiService(deployOptions DeployOptions) *corev1.Service {
 	port := corev1.ServicePort{
 		Name:       "http",
 		Port:       3000,
 		TargetPort: intstr.FromString("http"),
 	}
 
-	serviceType := corev1.ServiceTypeClusterIP
+	ports := []corev1.ServicePort{
+		port,
+	}
+	if deployOptions.EnableTLS {
+		ports = append(ports, corev1.ServicePort{
+			Name:       "https",
+			Port:       apiHTTPSPort,
+			TargetPort: intstr.FromString("https"),
+		})
+	}
+
+	serviceType, ports := serviceSpec(deployOptions.APIServiceType, deployOptions.APINodePort, ports)
 
 	service := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -286,17 +354,17 @@ func apiService(namespace string) *corev1.Service {
 			Kind:       "Service",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "kotsadm-api",
-			Namespace: namespace,
+			Name:        "kotsadm-api",
+			Namespace:   deployOptions.Namespace,
+			Annotations: deployOptions.APIServiceAnnotations,
+			Labels:      kotsadmLabels(),
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{
 				"app": "kotsadm-api",
 			},
-			Type: serviceType,
-			Ports: []corev1.ServicePort{
-				port,
-			},
+			Type:  serviceType,
+			Ports: ports,
 		},
 	}
 
@@ -0,0 +1,47 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_deploymentFieldRefs(t *testing.T) {
+	deployOptions := DeployOptions{Namespace: "default"}
+
+	tests := []struct {
+		name      string
+		podSpec   corev1.PodSpec
+		env       string
+		fieldPath string
+	}{
+		{
+			name:      "api",
+			podSpec:   apiDeployment(deployOptions).Spec.Template.Spec,
+			env:       "POD_NAMESPACE",
+			fieldPath: "metadata.namespace",
+		},
+		{
+			name:      "operator",
+			podSpec:   operatorDeployment(deployOptions).Spec.Template.Spec,
+			env:       "KOTSADM_TARGET_NAMESPACE",
+			fieldPath: "metadata.namespace",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fieldRef *corev1.ObjectFieldSelector
+			for _, env := range test.podSpec.Containers[0].Env {
+				if env.Name == test.env {
+					require.NotNil(t, env.ValueFrom)
+					fieldRef = env.ValueFrom.FieldRef
+				}
+			}
+			require.NotNil(t, fieldRef)
+			assert.Equal(t, test.fieldPath, fieldRef.FieldPath)
+		})
+	}
+}
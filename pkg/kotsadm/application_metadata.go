@@ -4,6 +4,7 @@ import (
 	"bytes"
 
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/application"
 	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
@@ -24,17 +25,51 @@ func getApplicationMetadataYAML(data []byte, namespace string) (map[string][]byt
 	return docs, nil
 }
 
+// validateApplicationMetadata checks that the admin console that's deployed is new enough for the
+// application, the application metadata is an Application kots kind
+func validateApplicationMetadata(deployOptions DeployOptions) error {
+	if deployOptions.ApplicationMetadata == nil {
+		return nil
+	}
+
+	app, err := application.ParseApplication(deployOptions.ApplicationMetadata)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse application metadata")
+	}
+
+	return application.CheckMinKotsVersion(app, kotsadmTag())
+}
+
 func ensureApplicationMetadata(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
-	_, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Get("kotsadm-application-metadata", metav1.GetOptions{})
+	existing, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Get("kotsadm-application-metadata", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing metadata config map")
 		}
 
-		_, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(applicationMetadataConfig(deployOptions.ApplicationMetadata, deployOptions.Namespace))
+		configMap := applicationMetadataConfig(deployOptions.ApplicationMetadata, deployOptions.Namespace)
+		if err := applyPatches(deployOptions, configMap); err != nil {
+			return errors.Wrap(err, "failed to patch application metadata config map")
+		}
+		_, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(configMap)
 		if err != nil {
 			return errors.Wrap(err, "failed to create metadata config map")
 		}
+
+		return nil
+	}
+
+	// the branding of a new release replaces the branding of the installed one
+	if deployOptions.ApplicationMetadata == nil || existing.Data["application.yaml"] == string(deployOptions.ApplicationMetadata) {
+		return nil
+	}
+
+	if existing.Data == nil {
+		existing.Data = map[string]string{}
+	}
+	existing.Data["application.yaml"] = string(deployOptions.ApplicationMetadata)
+	if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update metadata config map")
 	}
 
 	return nil
@@ -0,0 +1,365 @@
+package kotsadm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/prompt"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	backupSecretName  = "kotsadm-backup"
+	backupCronJobName = "kotsadm-backup"
+
+	snapshotDumpFile          = "kotsadm.dump"
+	snapshotEncryptionKeyFile = "encryptionKey"
+	snapshotObjectStoreDir    = "objectstore"
+)
+
+var timeoutWaitingForSnapshot = time.Duration(time.Minute * 30)
+
+// BackupDestination is an S3 compatible bucket that snapshots are written to. Each snapshot
+// is stored under Prefix in a directory named after the time it was taken.
+type BackupDestination struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+type SnapshotOptions struct {
+	Namespace  string
+	Kubeconfig string
+	// Prompter and Silent are the same as the DeployOptions fields
+	Prompter prompt.Prompter
+	Silent   bool
+}
+
+type RestoreSnapshotOptions struct {
+	// DeployOptions are used to install the admin console that the snapshot is restored into,
+	// BackupDestination is where the snapshot is read from
+	DeployOptions DeployOptions
+	SnapshotName  string
+}
+
+// Snapshot takes a snapshot of the admin console database and object store now, using the
+// destination that scheduled backups were configured with, and returns the name of the snapshot
+func Snapshot(snapshotOptions SnapshotOptions) (string, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	_, err = clientset.CoreV1().Secrets(snapshotOptions.Namespace).Get(backupSecretName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return "", errors.New("backups are not configured, install the admin console with a backup destination first")
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get backup secret")
+	}
+
+	deployOptions, err := readDeployOptionsFromCluster(snapshotOptions.Namespace, snapshotOptions.Kubeconfig, clientset, snapshotOptions.Prompter)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read deploy options")
+	}
+
+	snapshotName := newSnapshotName(time.Now())
+
+	log := logger.NewLogger()
+	if snapshotOptions.Silent {
+		log.Silence()
+	}
+	log.ChildActionWithSpinner("Taking snapshot %s", snapshotName)
+	if _, err := runJob(*deployOptions, snapshotJob(*deployOptions, snapshotName), timeoutWaitingForSnapshot, clientset); err != nil {
+		return "", errors.Wrap(err, "failed to take snapshot")
+	}
+	log.FinishChildSpinner()
+
+	return snapshotName, nil
+}
+
+// RestoreSnapshot installs the admin console with the encryption key from the snapshot, and then
+// replaces its database and object store with the ones in the snapshot. It can be used to rebuild
+// an install in a new cluster.
+func RestoreSnapshot(restoreOptions RestoreSnapshotOptions) error {
+	deployOptions := restoreOptions.DeployOptions
+	if restoreOptions.SnapshotName == "" {
+		return errors.New("snapshot name is required")
+	}
+	if err := validateBackupDestination(deployOptions.BackupDestination); err != nil {
+		return errors.Wrap(err, "invalid backup destination")
+	}
+
+	// data in the database is encrypted with this key, so it has to be set before the api starts
+	encryptionKey, err := readSnapshotFile(deployOptions.BackupDestination, restoreOptions.SnapshotName, snapshotEncryptionKeyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read encryption key from snapshot")
+	}
+	deployOptions.APIEncryptionKey = string(encryptionKey)
+
+	if err := Deploy(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to deploy admin console")
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	if err := ensureBackupSecret(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure backup secret")
+	}
+
+	restoreAPI, err := scaleDownAPI(deployOptions.Namespace, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to stop api")
+	}
+
+	log := deployOptions.newLogger()
+	log.ChildActionWithSpinner("Restoring snapshot %s", restoreOptions.SnapshotName)
+	_, err = runJob(deployOptions, restoreSnapshotJob(deployOptions, restoreOptions.SnapshotName), timeoutWaitingForSnapshot, clientset)
+	restoreAPI()
+	if err != nil {
+		return errors.Wrap(err, "failed to restore snapshot")
+	}
+	log.FinishChildSpinner()
+
+	log.ChildActionWithSpinner("Waiting for Admin Console to be ready")
+	if err := waitForAPI(&deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to wait for API")
+	}
+	log.FinishSpinner()
+
+	return nil
+}
+
+func usesBackups(deployOptions DeployOptions) bool {
+	return deployOptions.BackupSchedule != ""
+}
+
+func validateBackupOptions(deployOptions DeployOptions) error {
+	if !usesBackups(deployOptions) {
+		return nil
+	}
+
+	if err := validateBackupSchedule(deployOptions.BackupSchedule); err != nil {
+		return errors.Wrap(err, "invalid schedule")
+	}
+	if err := validateBackupDestination(deployOptions.BackupDestination); err != nil {
+		return errors.Wrap(err, "invalid destination")
+	}
+
+	return nil
+}
+
+// validateBackupSchedule checks the form of the schedule, the fields are validated when the cronjob is created
+func validateBackupSchedule(schedule string) error {
+	if strings.HasPrefix(schedule, "@") {
+		switch schedule {
+		case "@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly":
+			return nil
+		}
+		return errors.Errorf("unknown schedule %q", schedule)
+	}
+
+	if len(strings.Fields(schedule)) != 5 {
+		return errors.Errorf("schedule %q must have 5 fields (minute hour day-of-month month day-of-week)", schedule)
+	}
+
+	return nil
+}
+
+func validateBackupDestination(destination BackupDestination) error {
+	if destination.Endpoint == "" {
+		return errors.New("endpoint is required")
+	}
+	if !strings.HasPrefix(destination.Endpoint, "http://") && !strings.HasPrefix(destination.Endpoint, "https://") {
+		return errors.Errorf("endpoint %q must start with http:// or https://", destination.Endpoint)
+	}
+	if destination.Bucket == "" {
+		return errors.New("bucket is required")
+	}
+	if destination.AccessKeyID == "" || destination.SecretAccessKey == "" {
+		return errors.New("access key id and secret access key are required")
+	}
+
+	return nil
+}
+
+func getBackupYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var secret bytes.Buffer
+	if err := s.Encode(backupSecret(deployOptions.Namespace, deployOptions.BackupDestination), &secret); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal backup secret")
+	}
+	docs["secret-backup.yaml"] = secret.Bytes()
+
+	var cronJob bytes.Buffer
+	if err := s.Encode(backupCronJob(deployOptions), &cronJob); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal backup cronjob")
+	}
+	docs["backup-cronjob.yaml"] = cronJob.Bytes()
+
+	return docs, nil
+}
+
+func ensureBackup(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	if err := ensureBackupSecret(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure backup secret")
+	}
+
+	if err := ensureBackupCronJob(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure backup cronjob")
+	}
+
+	return nil
+}
+
+func ensureBackupSecret(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(backupSecretName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing backup secret")
+		}
+
+		_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Create(backupSecret(deployOptions.Namespace, deployOptions.BackupDestination))
+		if err != nil {
+			return errors.Wrap(err, "failed to create backup secret")
+		}
+	}
+
+	return nil
+}
+
+func ensureBackupCronJob(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	existing, err := clientset.BatchV1beta1().CronJobs(deployOptions.Namespace).Get(backupCronJobName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing cronjob")
+		}
+
+		cronJob := backupCronJob(deployOptions)
+		if err := applyPatches(deployOptions, cronJob); err != nil {
+			return errors.Wrap(err, "failed to patch backup cronjob")
+		}
+		_, err := clientset.BatchV1beta1().CronJobs(deployOptions.Namespace).Create(cronJob)
+		if err != nil {
+			return errors.Wrap(err, "failed to create backup cronjob")
+		}
+
+		return nil
+	}
+
+	// the job template references the postgres and minio images, which change on upgrade
+	desired := backupCronJob(deployOptions)
+	existing.Spec.Schedule = desired.Spec.Schedule
+	existing.Spec.JobTemplate = desired.Spec.JobTemplate
+	if _, err := clientset.BatchV1beta1().CronJobs(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update backup cronjob")
+	}
+
+	return nil
+}
+
+// readBackupOptions returns the schedule and destination that backups were configured with,
+// and an empty schedule if they're not configured
+func readBackupOptions(namespace string, clientset *kubernetes.Clientset) (string, BackupDestination, error) {
+	cronJob, err := clientset.BatchV1beta1().CronJobs(namespace).Get(backupCronJobName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return "", BackupDestination{}, nil
+	}
+	if err != nil {
+		return "", BackupDestination{}, errors.Wrap(err, "failed to get backup cronjob")
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(backupSecretName, metav1.GetOptions{})
+	if err != nil {
+		return "", BackupDestination{}, errors.Wrap(err, "failed to get backup secret")
+	}
+
+	destination := BackupDestination{
+		Endpoint:        string(secret.Data["endpoint"]),
+		Region:          string(secret.Data["region"]),
+		Bucket:          string(secret.Data["bucket"]),
+		Prefix:          string(secret.Data["prefix"]),
+		AccessKeyID:     string(secret.Data["accessKeyID"]),
+		SecretAccessKey: string(secret.Data["secretAccessKey"]),
+	}
+
+	return cronJob.Spec.Schedule, destination, nil
+}
+
+// newSnapshotName names a snapshot after the time it was taken, in the same form as scheduled snapshots
+func newSnapshotName(t time.Time) string {
+	return t.UTC().Format("20060102-150405")
+}
+
+func snapshotKey(destination BackupDestination, snapshotName string, filename string) string {
+	// the same prefix as the snapshot jobs, which have it without leading or trailing slashes
+	return path.Join(strings.Trim(destination.Prefix, "/"), snapshotName, filename)
+}
+
+// readSnapshotFile reads a file from a snapshot in the destination
+func readSnapshotFile(destination BackupDestination, snapshotName string, filename string) ([]byte, error) {
+	region := destination.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(destination.Endpoint),
+		Region:           aws.String(region),
+		Credentials:      credentials.NewStaticCredentials(destination.AccessKeyID, destination.SecretAccessKey, ""),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create session")
+	}
+
+	key := snapshotKey(destination, snapshotName, filename)
+	output, err := s3.New(sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(destination.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s", fmt.Sprintf("s3://%s/%s", destination.Bucket, key))
+	}
+	defer output.Body.Close()
+
+	content, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read object")
+	}
+
+	return content, nil
+}
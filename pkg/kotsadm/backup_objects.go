@@ -0,0 +1,284 @@
+package kotsadm
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const mcTag = "RELEASE.2020-04-25T00-43-23Z"
+
+func mcImage() string {
+	return thirdPartyImage("minio/mc", mcTag)
+}
+
+var (
+	snapshotJobBackoffLimit   = int32(0)
+	backupJobsHistoryLimit    = int32(3)
+	snapshotDumpPath          = path.Join(postgresBackupMountPath, snapshotDumpFile)
+	snapshotEncryptionKeyPath = path.Join("/encryption", "encryptionKey")
+)
+
+// mcScript configures the admin console object store as "kotsadm" and the backup destination
+// as "backup", and sets $snapshot to the path of the snapshot in the destination
+const mcScript = `set -e
+mc --quiet --config-dir /tmp/mc config host add kotsadm "$OBJECT_STORE_ENDPOINT" "$OBJECT_STORE_ACCESS_KEY" "$OBJECT_STORE_SECRET_KEY" --lookup "$OBJECT_STORE_LOOKUP" > /dev/null
+mc --quiet --config-dir /tmp/mc config host add backup "$BACKUP_ENDPOINT" "$BACKUP_ACCESS_KEY_ID" "$BACKUP_SECRET_ACCESS_KEY" > /dev/null
+snapshot="backup/$BACKUP_BUCKET${BACKUP_PREFIX:+/$BACKUP_PREFIX}/$SNAPSHOT_NAME"
+`
+
+func backupSecret(namespace string, destination BackupDestination) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupSecretName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Data: map[string][]byte{
+			"endpoint":        []byte(destination.Endpoint),
+			"region":          []byte(destination.Region),
+			"bucket":          []byte(destination.Bucket),
+			"prefix":          []byte(strings.Trim(destination.Prefix, "/")),
+			"accessKeyID":     []byte(destination.AccessKeyID),
+			"secretAccessKey": []byte(destination.SecretAccessKey),
+		},
+	}
+
+	return secret
+}
+
+func backupCronJob(deployOptions DeployOptions) *batchv1beta1.CronJob {
+	cronJob := &batchv1beta1.CronJob{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1beta1",
+			Kind:       "CronJob",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupCronJobName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   deployOptions.BackupSchedule,
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &backupJobsHistoryLimit,
+			FailedJobsHistoryLimit:     &backupJobsHistoryLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &snapshotJobBackoffLimit,
+					Template:     snapshotPodTemplate(deployOptions, ""),
+				},
+			},
+		},
+	}
+
+	return cronJob
+}
+
+func snapshotJob(deployOptions DeployOptions, snapshotName string) *batchv1.Job {
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kotsadm-snapshot",
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &snapshotJobBackoffLimit,
+			Template:     snapshotPodTemplate(deployOptions, snapshotName),
+		},
+	}
+
+	return job
+}
+
+// snapshotPodTemplate dumps the database, and then uploads the dump, the api encryption key and
+// the object store to the destination. Scheduled snapshots leave snapshotName empty and are
+// named after the time they run.
+func snapshotPodTemplate(deployOptions DeployOptions, snapshotName string) corev1.PodTemplateSpec {
+	script := mcScript + fmt.Sprintf(`mc --quiet --config-dir /tmp/mc cp %s "$snapshot/%s"
+mc --quiet --config-dir /tmp/mc cp %s "$snapshot/%s"
+mc --quiet --config-dir /tmp/mc mirror "kotsadm/$OBJECT_STORE_BUCKET" "$snapshot/%s"
+echo "$SNAPSHOT_NAME" > /dev/termination-log`, snapshotDumpPath, snapshotDumpFile, snapshotEncryptionKeyPath, snapshotEncryptionKeyFile, snapshotObjectStoreDir)
+
+	env := []corev1.EnvVar{}
+	if snapshotName != "" {
+		env = append(env, corev1.EnvVar{Name: "SNAPSHOT_NAME", Value: snapshotName})
+	}
+
+	// the same form as newSnapshotName
+	script = "SNAPSHOT_NAME=${SNAPSHOT_NAME:-$(date -u +%Y%m%d-%H%M%S)}\n" + script
+
+	uploadContainer := snapshotMCContainer(deployOptions, "upload", env, script)
+	uploadContainer.VolumeMounts = append(uploadContainer.VolumeMounts, corev1.VolumeMount{
+		Name:      "encryption",
+		MountPath: path.Dir(snapshotEncryptionKeyPath),
+		ReadOnly:  true,
+	})
+
+	template := snapshotPodTemplateSpec(deployOptions, "kotsadm-snapshot")
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: "encryption",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: "kotsadm-encryption",
+			},
+		},
+	})
+	template.Spec.InitContainers = []corev1.Container{
+		snapshotPostgresContainer("dump", fmt.Sprintf(`pg_dump "$POSTGRES_URI" --format=custom --file=%s`, snapshotDumpPath)),
+	}
+	template.Spec.Containers = []corev1.Container{uploadContainer}
+
+	addProxy(deployOptions, &template.Spec)
+	addSidecarInjection(deployOptions, &template.ObjectMeta, &template.Spec)
+	addOpenShiftCompatibility(deployOptions, &template.Spec)
+
+	return template
+}
+
+// restoreSnapshotJob downloads the snapshot, replaces the object store contents and restores the database
+func restoreSnapshotJob(deployOptions DeployOptions, snapshotName string) *batchv1.Job {
+	downloadScript := mcScript + fmt.Sprintf(`mc --quiet --config-dir /tmp/mc cp "$snapshot/%s" %s
+if [ -n "$(mc --quiet --config-dir /tmp/mc ls "$snapshot/%s/")" ]; then
+  mc --quiet --config-dir /tmp/mc mirror --overwrite "$snapshot/%s" "kotsadm/$OBJECT_STORE_BUCKET"
+fi`, snapshotDumpFile, snapshotDumpPath, snapshotObjectStoreDir, snapshotObjectStoreDir)
+
+	template := snapshotPodTemplateSpec(deployOptions, "kotsadm-restore")
+	template.Spec.InitContainers = []corev1.Container{
+		snapshotMCContainer(deployOptions, "download", []corev1.EnvVar{{Name: "SNAPSHOT_NAME", Value: snapshotName}}, downloadScript),
+	}
+	template.Spec.Containers = []corev1.Container{
+		snapshotPostgresContainer("restore", fmt.Sprintf(`pg_restore --dbname="$POSTGRES_URI" --clean --if-exists --no-owner --exit-on-error %s`, snapshotDumpPath)),
+	}
+
+	addProxy(deployOptions, &template.Spec)
+	addSidecarInjection(deployOptions, &template.ObjectMeta, &template.Spec)
+	addOpenShiftCompatibility(deployOptions, &template.Spec)
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kotsadm-restore",
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &snapshotJobBackoffLimit,
+			Template:     template,
+		},
+	}
+
+	return job
+}
+
+func snapshotPodTemplateSpec(deployOptions DeployOptions, name string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				kotsadmLabelKey: kotsadmLabelValue,
+				"app":           name,
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeSelector:  deployOptions.NodeSelector,
+			Tolerations:   deployOptions.Tolerations,
+			Affinity:      deployOptions.Affinity,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Volumes: []corev1.Volume{
+				{
+					Name: "backup",
+					VolumeSource: corev1.VolumeSource{
+						EmptyDir: &corev1.EmptyDirVolumeSource{},
+					},
+				},
+			},
+		},
+	}
+}
+
+func snapshotPostgresContainer(name string, script string) corev1.Container {
+	return corev1.Container{
+		Image:           postgresImage(),
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Name:            name,
+		Command:         []string{"/bin/sh", "-c", "set -e\n" + script},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "backup",
+				MountPath: postgresBackupMountPath,
+			},
+		},
+		Env: []corev1.EnvVar{
+			{
+				Name: "POSTGRES_URI",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: "kotsadm-postgres",
+						},
+						Key: "uri",
+					},
+				},
+			},
+		},
+	}
+}
+
+func snapshotMCContainer(deployOptions DeployOptions, name string, env []corev1.EnvVar, script string) corev1.Container {
+	env = append(env, objectStoreEnv(deployOptions)...)
+
+	secretEnv := []struct {
+		name   string
+		secret string
+		key    string
+	}{
+		{"BACKUP_ENDPOINT", backupSecretName, "endpoint"},
+		{"BACKUP_BUCKET", backupSecretName, "bucket"},
+		{"BACKUP_PREFIX", backupSecretName, "prefix"},
+		{"BACKUP_ACCESS_KEY_ID", backupSecretName, "accessKeyID"},
+		{"BACKUP_SECRET_ACCESS_KEY", backupSecretName, "secretAccessKey"},
+	}
+	for _, e := range secretEnv {
+		env = append(env, corev1.EnvVar{
+			Name: e.name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: e.secret,
+					},
+					Key: e.key,
+				},
+			},
+		})
+	}
+
+	return corev1.Container{
+		Image:           mcImage(),
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Name:            name,
+		Command:         []string{"/bin/sh", "-c", script},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "backup",
+				MountPath: postgresBackupMountPath,
+			},
+		},
+		Env: env,
+	}
+}
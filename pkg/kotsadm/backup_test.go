@@ -0,0 +1,149 @@
+package kotsadm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateBackupOptions(t *testing.T) {
+	destination := BackupDestination{
+		Endpoint:        "https://s3.amazonaws.com",
+		Bucket:          "snapshots",
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+	}
+
+	tests := []struct {
+		name          string
+		deployOptions DeployOptions
+		expectError   bool
+	}{
+		{
+			name:          "disabled",
+			deployOptions: DeployOptions{},
+		},
+		{
+			name: "daily",
+			deployOptions: DeployOptions{
+				BackupSchedule:    "0 2 * * *",
+				BackupDestination: destination,
+			},
+		},
+		{
+			name: "macro",
+			deployOptions: DeployOptions{
+				BackupSchedule:    "@weekly",
+				BackupDestination: destination,
+			},
+		},
+		{
+			name: "unknown macro",
+			deployOptions: DeployOptions{
+				BackupSchedule:    "@fortnightly",
+				BackupDestination: destination,
+			},
+			expectError: true,
+		},
+		{
+			name: "missing field",
+			deployOptions: DeployOptions{
+				BackupSchedule:    "0 2 * *",
+				BackupDestination: destination,
+			},
+			expectError: true,
+		},
+		{
+			name: "no destination",
+			deployOptions: DeployOptions{
+				BackupSchedule: "0 2 * * *",
+			},
+			expectError: true,
+		},
+		{
+			name: "endpoint without scheme",
+			deployOptions: DeployOptions{
+				BackupSchedule: "0 2 * * *",
+				BackupDestination: BackupDestination{
+					Endpoint:        "s3.amazonaws.com",
+					Bucket:          "snapshots",
+					AccessKeyID:     "id",
+					SecretAccessKey: "secret",
+				},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateBackupOptions(test.deployOptions)
+			if test.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_backupCronJob(t *testing.T) {
+	cronJob := backupCronJob(DeployOptions{
+		Namespace:      "default",
+		BackupSchedule: "0 2 * * *",
+	})
+
+	assert.Equal(t, "0 2 * * *", cronJob.Spec.Schedule)
+
+	podSpec := cronJob.Spec.JobTemplate.Spec.Template.Spec
+	require.Len(t, podSpec.InitContainers, 1)
+	assert.Equal(t, postgresImage(), podSpec.InitContainers[0].Image)
+	require.Len(t, podSpec.Containers, 1)
+	assert.Equal(t, mcImage(), podSpec.Containers[0].Image)
+
+	// scheduled snapshots are named when they run
+	for _, env := range podSpec.Containers[0].Env {
+		assert.NotEqual(t, "SNAPSHOT_NAME", env.Name)
+	}
+}
+
+func Test_snapshotJob(t *testing.T) {
+	job := snapshotJob(DeployOptions{Namespace: "default"}, "20200501-020000")
+
+	env := job.Spec.Template.Spec.Containers[0].Env
+	require.NotEmpty(t, env)
+	assert.Equal(t, "SNAPSHOT_NAME", env[0].Name)
+	assert.Equal(t, "20200501-020000", env[0].Value)
+}
+
+func Test_newSnapshotName(t *testing.T) {
+	snapshotTime := time.Date(2020, 5, 1, 2, 3, 4, 0, time.UTC)
+	assert.Equal(t, "20200501-020304", newSnapshotName(snapshotTime))
+}
+
+func Test_snapshotKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		expected string
+	}{
+		{
+			name:     "no prefix",
+			expected: "20200501-020304/kotsadm.dump",
+		},
+		{
+			name:     "prefix",
+			prefix:   "kotsadm/prod/",
+			expected: "kotsadm/prod/20200501-020304/kotsadm.dump",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := snapshotKey(BackupDestination{Prefix: test.prefix}, "20200501-020304", snapshotDumpFile)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package kotsadm
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
+	kjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+const (
+	bootstrapName          = "kotsadm-bootstrap"
+	bootstrapRequestKey    = "request.json"
+	bootstrapLicensePath   = "/api/v1/kots/license"
+	configValuesKind       = "ConfigValues"
+	configValuesAPIVersion = "kots.io/v1beta1"
+)
+
+func usesBootstrap(deployOptions DeployOptions) bool {
+	return len(deployOptions.BootstrapLicense) > 0
+}
+
+func validateBootstrapOptions(deployOptions DeployOptions) error {
+	if !usesBootstrap(deployOptions) {
+		if len(deployOptions.BootstrapConfigValues) > 0 {
+			return errors.New("config values can only be bootstrapped with a license")
+		}
+		return nil
+	}
+
+	if _, err := kotslicense.ParseLicense(deployOptions.BootstrapLicense); err != nil {
+		return errors.Wrap(err, "failed to parse bootstrap license")
+	}
+
+	if len(deployOptions.BootstrapConfigValues) > 0 {
+		typeMeta := struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+		}{}
+		if err := k8syaml.Unmarshal(deployOptions.BootstrapConfigValues, &typeMeta); err != nil {
+			return errors.Wrap(err, "failed to parse bootstrap config values")
+		}
+		if typeMeta.APIVersion != configValuesAPIVersion || typeMeta.Kind != configValuesKind {
+			return errors.Errorf("bootstrap config values must be %s %s, not %s %s", configValuesAPIVersion, configValuesKind, typeMeta.APIVersion, typeMeta.Kind)
+		}
+	}
+
+	return nil
+}
+
+// bootstrapRequest is the body that the job posts to the api, the same request that kots install
+// uploads the license with. The app is named after the license when there's no name.
+func bootstrapRequest(deployOptions DeployOptions) ([]byte, error) {
+	license, err := kotslicense.ParseLicense(deployOptions.BootstrapLicense)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse license")
+	}
+
+	name := deployOptions.BootstrapAppName
+	if name == "" {
+		name = license.Spec.AppSlug
+	}
+
+	body := map[string]string{
+		"name":    name,
+		"license": string(deployOptions.BootstrapLicense),
+	}
+	if len(deployOptions.BootstrapConfigValues) > 0 {
+		body["configValues"] = string(deployOptions.BootstrapConfigValues)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request")
+	}
+	return b, nil
+}
+
+func getBootstrapYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := kjson.NewYAMLSerializer(kjson.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	request, err := bootstrapRequest(deployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create bootstrap request")
+	}
+
+	var secret bytes.Buffer
+	if err := s.Encode(bootstrapSecret(deployOptions.Namespace, request), &secret); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal bootstrap secret")
+	}
+	docs["secret-bootstrap.yaml"] = secret.Bytes()
+
+	var job bytes.Buffer
+	if err := s.Encode(bootstrapJob(deployOptions), &job); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal bootstrap job")
+	}
+	docs["bootstrap-job.yaml"] = job.Bytes()
+
+	return docs, nil
+}
@@ -0,0 +1,138 @@
+package kotsadm
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	curlTag              = "7.70.0"
+	bootstrapRequestDir  = "/bootstrap"
+	bootstrapCACertDir   = "/tls-ca"
+	bootstrapCACertFile  = bootstrapCACertDir + "/ca.crt"
+	bootstrapRequestFile = bootstrapRequestDir + "/" + bootstrapRequestKey
+	bootstrapWaitSeconds = 5
+)
+
+var bootstrapJobBackoffLimit = int32(6)
+
+func curlImage() string {
+	return thirdPartyImage("curlimages/curl", curlTag)
+}
+
+// bootstrapScript waits for the api to be ready and then uploads the license, the job is retried if
+// the api doesn't accept it
+func bootstrapScript(deployOptions DeployOptions) string {
+	curl := "curl --fail --silent --show-error --noproxy '*'"
+	if deployOptions.EnableTLS {
+		curl += " --cacert " + bootstrapCACertFile
+	}
+	endpoint := apiEndpoint(deployOptions)
+
+	return fmt.Sprintf(`until %s --output /dev/null %s/healthz; do
+  echo "waiting for the admin console api"
+  sleep %d
+done
+%s -H "Content-Type: application/json" --data @%s %s%s
+`, curl, endpoint, bootstrapWaitSeconds, curl, bootstrapRequestFile, endpoint, bootstrapLicensePath)
+}
+
+func bootstrapSecret(namespace string, request []byte) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootstrapName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Data: map[string][]byte{
+			bootstrapRequestKey: request,
+		},
+	}
+
+	return secret
+}
+
+func bootstrapJob(deployOptions DeployOptions) *batchv1.Job {
+	volumes := []corev1.Volume{
+		{
+			Name: "request",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: bootstrapName,
+				},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      "request",
+			MountPath: bootstrapRequestDir,
+			ReadOnly:  true,
+		},
+	}
+	if deployOptions.EnableTLS {
+		volumes = append(volumes, corev1.Volume{
+			Name: "tls-ca",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: tlsCASecretName,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      "tls-ca",
+			MountPath: bootstrapCACertDir,
+			ReadOnly:  true,
+		})
+	}
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootstrapName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &bootstrapJobBackoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": bootstrapName,
+					},
+				},
+				Spec: corev1.PodSpec{
+					NodeSelector:  deployOptions.NodeSelector,
+					Tolerations:   deployOptions.Tolerations,
+					Affinity:      deployOptions.Affinity,
+					RestartPolicy: corev1.RestartPolicyNever,
+					Volumes:       volumes,
+					Containers: []corev1.Container{
+						{
+							Image:           curlImage(),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Name:            bootstrapName,
+							Command:         []string{"/bin/sh", "-c", "set -e\n" + bootstrapScript(deployOptions)},
+							VolumeMounts:    volumeMounts,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	addSidecarInjection(deployOptions, &job.Spec.Template.ObjectMeta, &job.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &job.Spec.Template.Spec)
+
+	return job
+}
@@ -0,0 +1,105 @@
+package kotsadm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bootstrapTestLicense = `apiVersion: kots.io/v1beta1
+kind: License
+metadata:
+  name: local
+spec:
+  licenseID: abcdef
+  appSlug: my-app
+  signature: IA==`
+
+const bootstrapTestConfigValues = `apiVersion: kots.io/v1beta1
+kind: ConfigValues
+metadata:
+  name: my-app
+spec:
+  values:
+    hostname:
+      value: app.example.com`
+
+func Test_validateBootstrapOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		deployOptions DeployOptions
+		expectErr     bool
+	}{
+		{
+			name:          "no bootstrap",
+			deployOptions: DeployOptions{},
+		},
+		{
+			name:          "license and config values",
+			deployOptions: DeployOptions{BootstrapLicense: []byte(bootstrapTestLicense), BootstrapConfigValues: []byte(bootstrapTestConfigValues)},
+		},
+		{
+			name:          "config values without a license",
+			deployOptions: DeployOptions{BootstrapConfigValues: []byte(bootstrapTestConfigValues)},
+			expectErr:     true,
+		},
+		{
+			name:          "not a license",
+			deployOptions: DeployOptions{BootstrapLicense: []byte(bootstrapTestConfigValues)},
+			expectErr:     true,
+		},
+		{
+			name:          "not config values",
+			deployOptions: DeployOptions{BootstrapLicense: []byte(bootstrapTestLicense), BootstrapConfigValues: []byte(bootstrapTestLicense)},
+			expectErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateBootstrapOptions(test.deployOptions)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_bootstrapRequest(t *testing.T) {
+	req := require.New(t)
+
+	b, err := bootstrapRequest(DeployOptions{BootstrapLicense: []byte(bootstrapTestLicense)})
+	req.NoError(err)
+	body := map[string]string{}
+	req.NoError(json.Unmarshal(b, &body))
+	assert.Equal(t, map[string]string{"name": "my-app", "license": bootstrapTestLicense}, body)
+
+	b, err = bootstrapRequest(DeployOptions{
+		BootstrapLicense:      []byte(bootstrapTestLicense),
+		BootstrapConfigValues: []byte(bootstrapTestConfigValues),
+		BootstrapAppName:      "production",
+	})
+	req.NoError(err)
+	body = map[string]string{}
+	req.NoError(json.Unmarshal(b, &body))
+	assert.Equal(t, "production", body["name"])
+	assert.Equal(t, bootstrapTestConfigValues, body["configValues"])
+}
+
+func Test_bootstrapJob(t *testing.T) {
+	job := bootstrapJob(DeployOptions{Namespace: "kots"})
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "curlimages/curl:"+curlTag, container.Image)
+	assert.Contains(t, container.Command[2], "http://kotsadm-api.kots.svc.cluster.local:3000/api/v1/kots/license")
+	assert.Len(t, job.Spec.Template.Spec.Volumes, 1)
+
+	job = bootstrapJob(DeployOptions{Namespace: "kots", EnableTLS: true})
+	container = job.Spec.Template.Spec.Containers[0]
+	assert.Contains(t, container.Command[2], "--cacert "+bootstrapCACertFile)
+	assert.Contains(t, container.Command[2], "https://kotsadm-api.kots.svc.cluster.local")
+	assert.Equal(t, tlsCASecretName, job.Spec.Template.Spec.Volumes[1].Secret.SecretName)
+}
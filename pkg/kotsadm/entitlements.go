@@ -0,0 +1,323 @@
+package kotsadm
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	serializer "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	entitlementsLicenseKey = "license.yaml"
+	entitlementsInfoKey    = "license-info.json"
+	entitlementsFieldsKey  = "license-fields.json"
+)
+
+// entitlementFieldKeyRegex matches the entitlements that can be served on their own, the name is
+// part of a secret key
+var entitlementFieldKeyRegex = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// licenseInfo is served at /api/v1/license/info
+type licenseInfo struct {
+	LicenseID         string `json:"licenseID"`
+	AppSlug           string `json:"appSlug"`
+	ChannelName       string `json:"channelName,omitempty"`
+	LicenseType       string `json:"licenseType,omitempty"`
+	LicenseSequence   int64  `json:"licenseSequence"`
+	IsAirgapSupported bool   `json:"isAirgapSupported"`
+	IsGitOpsSupported bool   `json:"isGitOpsSupported"`
+}
+
+// licenseField is an entitlement, all of them are served at /api/v1/license/fields and each one
+// at /api/v1/license/fields/<name>
+type licenseField struct {
+	Name        string      `json:"name"`
+	Title       string      `json:"title,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Value       interface{} `json:"value"`
+	ValueType   string      `json:"valueType"`
+	IsHidden    bool        `json:"isHidden,omitempty"`
+}
+
+func usesEntitlements(deployOptions DeployOptions) bool {
+	return len(deployOptions.EntitlementsLicense) > 0
+}
+
+func validateEntitlementsOptions(deployOptions DeployOptions) error {
+	if !usesEntitlements(deployOptions) {
+		return nil
+	}
+
+	if _, err := kotslicense.ParseLicense(deployOptions.EntitlementsLicense); err != nil {
+		return errors.Wrap(err, "failed to parse entitlements license")
+	}
+
+	return nil
+}
+
+// entitlementsData returns the files of the entitlements secret: the signed license, so that the
+// values can be verified, and the json documents that the service responds with
+func entitlementsData(data []byte) (map[string][]byte, error) {
+	license, err := kotslicense.ParseLicense(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse license")
+	}
+
+	files := map[string][]byte{
+		entitlementsLicenseKey: data,
+	}
+
+	info, err := json.Marshal(licenseInfo{
+		LicenseID:         license.Spec.LicenseID,
+		AppSlug:           license.Spec.AppSlug,
+		ChannelName:       license.Spec.ChannelName,
+		LicenseType:       license.Spec.LicenseType,
+		LicenseSequence:   license.Spec.LicenseSequence,
+		IsAirgapSupported: license.Spec.IsAirgapSupported,
+		IsGitOpsSupported: license.Spec.IsGitOpsSupported,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal license info")
+	}
+	files[entitlementsInfoKey] = info
+
+	names := []string{}
+	for name := range license.Spec.Entitlements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := map[string]licenseField{}
+	for _, name := range names {
+		entitlement := license.Spec.Entitlements[name]
+		field := licenseField{
+			Name:        name,
+			Title:       entitlement.Title,
+			Description: entitlement.Description,
+			Value:       entitlement.Value.Value(),
+			ValueType:   entitlementValueType(entitlement.Value.Type),
+			IsHidden:    entitlement.IsHidden,
+		}
+		fields[name] = field
+
+		if !entitlementFieldKeyRegex.MatchString(name) {
+			continue
+		}
+		b, err := json.Marshal(field)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal entitlement %s", name)
+		}
+		files[entitlementFieldKey(name)] = b
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal entitlements")
+	}
+	files[entitlementsFieldsKey] = b
+
+	return files, nil
+}
+
+func entitlementFieldKey(name string) string {
+	return "field-" + name + ".json"
+}
+
+func entitlementValueType(t kotsv1beta1.Type) string {
+	switch t {
+	case kotsv1beta1.Int:
+		return "Integer"
+	case kotsv1beta1.Bool:
+		return "Boolean"
+	default:
+		return "String"
+	}
+}
+
+func getEntitlementsYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	data, err := entitlementsData(deployOptions.EntitlementsLicense)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get entitlements")
+	}
+
+	var secret bytes.Buffer
+	if err := s.Encode(entitlementsSecret(deployOptions, data), &secret); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal entitlements secret")
+	}
+	docs["secret-entitlements.yaml"] = secret.Bytes()
+
+	var configMap bytes.Buffer
+	if err := s.Encode(entitlementsConfigMap(deployOptions), &configMap); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal entitlements config map")
+	}
+	docs["entitlements-configmap.yaml"] = configMap.Bytes()
+
+	var deployment bytes.Buffer
+	if err := s.Encode(entitlementsDeployment(deployOptions), &deployment); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal entitlements deployment")
+	}
+	docs["entitlements-deployment.yaml"] = deployment.Bytes()
+
+	var service bytes.Buffer
+	if err := s.Encode(entitlementsService(deployOptions), &service); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal entitlements service")
+	}
+	docs["entitlements-service.yaml"] = service.Bytes()
+
+	return docs, nil
+}
+
+func ensureEntitlements(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	if err := ensureEntitlementsSecret(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure entitlements secret")
+	}
+
+	if err := ensureEntitlementsConfigMap(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure entitlements config map")
+	}
+
+	if err := ensureEntitlementsDeployment(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure entitlements deployment")
+	}
+
+	if err := ensureEntitlementsService(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure entitlements service")
+	}
+
+	return nil
+}
+
+// ensureEntitlementsSecret replaces the data of an existing secret, so that a new license is
+// served by installing again. The files in the pods are updated without restarting them.
+func ensureEntitlementsSecret(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	data, err := entitlementsData(deployOptions.EntitlementsLicense)
+	if err != nil {
+		return errors.Wrap(err, "failed to get entitlements")
+	}
+	secret := entitlementsSecret(deployOptions, data)
+
+	existing, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(entitlementsObjectName(deployOptions), metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing secret")
+		}
+
+		if err := applyPatches(deployOptions, secret); err != nil {
+			return errors.Wrap(err, "failed to patch secret")
+		}
+		if _, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Create(secret); err != nil {
+			return errors.Wrap(err, "failed to create secret")
+		}
+
+		return nil
+	}
+
+	existing.Data = secret.Data
+	if _, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update secret")
+	}
+
+	return nil
+}
+
+func ensureEntitlementsConfigMap(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	configMap := entitlementsConfigMap(deployOptions)
+
+	existing, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Get(entitlementsObjectName(deployOptions), metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing config map")
+		}
+
+		if err := applyPatches(deployOptions, configMap); err != nil {
+			return errors.Wrap(err, "failed to patch config map")
+		}
+		if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(configMap); err != nil {
+			return errors.Wrap(err, "failed to create config map")
+		}
+
+		return nil
+	}
+
+	existing.Data = configMap.Data
+	if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update config map")
+	}
+
+	return nil
+}
+
+// ensureEntitlementsDeployment updates the pod template of an existing deployment, nginx only
+// reads its config when it starts
+func ensureEntitlementsDeployment(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	deployment := entitlementsDeployment(deployOptions)
+	if err := applyPatches(deployOptions, deployment); err != nil {
+		return errors.Wrap(err, "failed to patch deployment")
+	}
+
+	existing, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Get(entitlementsObjectName(deployOptions), metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing deployment")
+		}
+
+		if _, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Create(deployment); err != nil {
+			return errors.Wrap(err, "failed to create deployment")
+		}
+
+		return nil
+	}
+
+	existing.Spec.Template = deployment.Spec.Template
+	if _, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update deployment")
+	}
+
+	return nil
+}
+
+func ensureEntitlementsService(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	_, err := clientset.CoreV1().Services(deployOptions.Namespace).Get(entitlementsObjectName(deployOptions), metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get existing service")
+	}
+
+	service := entitlementsService(deployOptions)
+	if err := applyPatches(deployOptions, service); err != nil {
+		return errors.Wrap(err, "failed to patch service")
+	}
+	if _, err := clientset.CoreV1().Services(deployOptions.Namespace).Create(service); err != nil {
+		return errors.Wrap(err, "failed to create service")
+	}
+
+	return nil
+}
+
+// readEntitlementsLicense returns the license that the entitlement service of single app installs was
+// deployed with, or nil when it isn't deployed
+func readEntitlementsLicense(namespace string, clientset *kubernetes.Clientset) ([]byte, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(entitlementsName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get entitlements secret")
+	}
+
+	return secret.Data[entitlementsLicenseKey], nil
+}
@@ -0,0 +1,217 @@
+package kotsadm
+
+import (
+	"fmt"
+
+	"github.com/replicatedhq/kots/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	nginxTag              = "1.19-alpine"
+	entitlementsName      = "kotsadm-entitlements"
+	entitlementsPort      = 3000
+	entitlementsNginxPort = 8080
+	entitlementsConfigKey = "default.conf"
+	entitlementsDir       = "/entitlements"
+)
+
+// entitlementsNginxConfig serves the files of the entitlements secret at the paths that
+// applications query for the license and its fields
+func entitlementsNginxConfig() string {
+	return fmt.Sprintf(`server {
+  listen %d;
+  default_type application/json;
+
+  location = /healthz {
+    return 200 '{}';
+  }
+  location = /api/v1/license/info {
+    alias %s/%s;
+  }
+  location = /api/v1/license/fields {
+    alias %s/%s;
+  }
+  location ~ ^/api/v1/license/fields/([-._a-zA-Z0-9]+)$ {
+    alias %s/field-$1.json;
+  }
+  location / {
+    return 404 '{"error":"not found"}';
+  }
+}
+`, entitlementsNginxPort, entitlementsDir, entitlementsInfoKey, entitlementsDir, entitlementsFieldsKey, entitlementsDir)
+}
+
+// entitlementsObjectName is the name of the entitlements objects of the app instance, the service
+// is http://kotsadm-entitlements:3000 for single app installs
+func entitlementsObjectName(deployOptions DeployOptions) string {
+	return util.InstanceObjectName(deployOptions.InstanceName, entitlementsName)
+}
+
+func entitlementsSecret(deployOptions DeployOptions, data map[string][]byte) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      entitlementsObjectName(deployOptions),
+			Namespace: deployOptions.Namespace,
+			Labels:    instanceLabels(deployOptions),
+		},
+		Data: data,
+	}
+
+	return secret
+}
+
+func entitlementsConfigMap(deployOptions DeployOptions) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      entitlementsObjectName(deployOptions),
+			Namespace: deployOptions.Namespace,
+			Labels:    instanceLabels(deployOptions),
+		},
+		Data: map[string]string{
+			entitlementsConfigKey: entitlementsNginxConfig(),
+		},
+	}
+
+	return configMap
+}
+
+func entitlementsDeployment(deployOptions DeployOptions) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      entitlementsObjectName(deployOptions),
+			Namespace: deployOptions.Namespace,
+			Labels:    instanceLabels(deployOptions),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": entitlementsObjectName(deployOptions),
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": entitlementsObjectName(deployOptions),
+					},
+				},
+				Spec: corev1.PodSpec{
+					NodeSelector: deployOptions.NodeSelector,
+					Tolerations:  deployOptions.Tolerations,
+					Affinity:     deployOptions.Affinity,
+					Volumes: []corev1.Volume{
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: entitlementsObjectName(deployOptions),
+									},
+								},
+							},
+						},
+						{
+							Name: "entitlements",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: entitlementsObjectName(deployOptions),
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							// the unprivileged image runs as any user, and listens on a port above 1024
+							Image:           thirdPartyImage("nginxinc/nginx-unprivileged", nginxTag),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Name:            "entitlements",
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "http",
+									ContainerPort: entitlementsNginxPort,
+								},
+							},
+							ReadinessProbe: deployOptions.TuningProfile.readinessProbe(&corev1.Probe{
+								FailureThreshold:    3,
+								InitialDelaySeconds: 2,
+								PeriodSeconds:       2,
+								SuccessThreshold:    1,
+								TimeoutSeconds:      1,
+								Handler: corev1.Handler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path:   "/healthz",
+										Port:   intstr.FromInt(entitlementsNginxPort),
+										Scheme: corev1.URISchemeHTTP,
+									},
+								},
+							}),
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "config",
+									MountPath: "/etc/nginx/conf.d",
+									ReadOnly:  true,
+								},
+								{
+									Name:      "entitlements",
+									MountPath: entitlementsDir,
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	addSidecarInjection(deployOptions, &deployment.Spec.Template.ObjectMeta, &deployment.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &deployment.Spec.Template.Spec)
+
+	return deployment
+}
+
+// entitlementsService is the address that applications query, e.g.
+// http://kotsadm-entitlements:3000/api/v1/license/fields, or http://<instance name>-kotsadm-entitlements:3000
+func entitlementsService(deployOptions DeployOptions) *corev1.Service {
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      entitlementsObjectName(deployOptions),
+			Namespace: deployOptions.Namespace,
+			Labels:    instanceLabels(deployOptions),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				"app": entitlementsObjectName(deployOptions),
+			},
+			Type: corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       entitlementsPort,
+					TargetPort: intstr.FromString("http"),
+				},
+			},
+		},
+	}
+
+	return service
+}
@@ -0,0 +1,122 @@
+package kotsadm
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const entitlementsTestLicense = `apiVersion: kots.io/v1beta1
+kind: License
+metadata:
+  name: local
+spec:
+  licenseID: abcdef
+  appSlug: my-app
+  channelName: Stable
+  licenseSequence: 3
+  isAirgapSupported: true
+  entitlements:
+    seats:
+      title: Seats
+      value: 10
+      valueType: Integer
+    sso_enabled:
+      title: SSO
+      value: true
+      valueType: Boolean
+    tier:
+      value: enterprise
+      valueType: String
+      isHidden: true
+    "support plan":
+      value: gold
+      valueType: String
+  signature: IA==`
+
+func Test_entitlementsData(t *testing.T) {
+	req := require.New(t)
+
+	files, err := entitlementsData([]byte(entitlementsTestLicense))
+	req.NoError(err)
+
+	keys := []string{}
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	// "support plan" isn't a valid secret key, it's only served with the other fields
+	assert.Equal(t, []string{
+		"field-seats.json",
+		"field-sso_enabled.json",
+		"field-tier.json",
+		"license-fields.json",
+		"license-info.json",
+		"license.yaml",
+	}, keys)
+
+	assert.Equal(t, entitlementsTestLicense, string(files[entitlementsLicenseKey]))
+
+	info := licenseInfo{}
+	req.NoError(json.Unmarshal(files[entitlementsInfoKey], &info))
+	assert.Equal(t, licenseInfo{
+		LicenseID:         "abcdef",
+		AppSlug:           "my-app",
+		ChannelName:       "Stable",
+		LicenseSequence:   3,
+		IsAirgapSupported: true,
+	}, info)
+
+	fields := map[string]licenseField{}
+	req.NoError(json.Unmarshal(files[entitlementsFieldsKey], &fields))
+	assert.Len(t, fields, 4)
+	assert.Equal(t, "gold", fields["support plan"].Value)
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{
+			name:     "seats",
+			expected: `{"name":"seats","title":"Seats","value":10,"valueType":"Integer"}`,
+		},
+		{
+			name:     "sso_enabled",
+			expected: `{"name":"sso_enabled","title":"SSO","value":true,"valueType":"Boolean"}`,
+		},
+		{
+			name:     "tier",
+			expected: `{"name":"tier","value":"enterprise","valueType":"String","isHidden":true}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, string(files[entitlementFieldKey(test.name)]))
+		})
+	}
+}
+
+func Test_validateEntitlementsOptions(t *testing.T) {
+	assert.NoError(t, validateEntitlementsOptions(DeployOptions{}))
+	assert.NoError(t, validateEntitlementsOptions(DeployOptions{EntitlementsLicense: []byte(entitlementsTestLicense)}))
+	assert.Error(t, validateEntitlementsOptions(DeployOptions{EntitlementsLicense: []byte(bootstrapTestConfigValues)}))
+}
+
+func Test_entitlementsObjects(t *testing.T) {
+	configMap := entitlementsConfigMap(DeployOptions{Namespace: "default"})
+	assert.Contains(t, configMap.Data[entitlementsConfigKey], "listen 8080;")
+	assert.Contains(t, configMap.Data[entitlementsConfigKey], "alias /entitlements/field-$1.json;")
+
+	deployment := entitlementsDeployment(DeployOptions{Namespace: "default"})
+	require.Len(t, deployment.Spec.Template.Spec.Volumes, 2)
+	assert.Equal(t, entitlementsName, deployment.Spec.Template.Spec.Volumes[1].Secret.SecretName)
+
+	service := entitlementsService(DeployOptions{Namespace: "default"})
+	require.Len(t, service.Spec.Ports, 1)
+	assert.Equal(t, int32(3000), service.Spec.Ports[0].Port)
+	assert.Equal(t, deployment.Spec.Template.Labels, service.Spec.Selector)
+}
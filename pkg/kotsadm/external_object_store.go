@@ -0,0 +1,183 @@
+package kotsadm
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// externalObjectStoreAnnotation is set on the kotsadm-minio secret when it points to a bucket
+// that is not managed by kots, so that upgrades don't deploy the bundled minio
+const externalObjectStoreAnnotation = "kots.io/external-object-store"
+
+const (
+	minioEndpoint = "http://kotsadm-minio:9000"
+	minioBucket   = "kotsadm"
+)
+
+var timeoutWaitingForObjectStorePreflight = time.Duration(time.Minute * 2)
+
+var validBucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// ExternalObjectStoreOptions are an existing S3 compatible bucket that the admin console keeps
+// archives in instead of deploying minio, e.g. on S3, on GCS with HMAC keys, or on Azure Blob
+// Storage through an S3 gateway
+type ExternalObjectStoreOptions struct {
+	// Endpoint is the url of the object store, e.g. https://s3.us-east-1.amazonaws.com
+	Endpoint string
+	Region   string
+	Bucket   string
+	// SecretName is an existing secret in the namespace with the access key id and secret access key
+	// in the accesskey and secretkey keys. Without a secret, they're DeployOptions.S3AccessKey and
+	// DeployOptions.S3SecretKey.
+	SecretName string
+	// PathStyle addresses the bucket in the path of the endpoint instead of in the host, which most
+	// S3 compatible object stores other than S3 need
+	PathStyle bool
+}
+
+func usesExternalObjectStore(deployOptions DeployOptions) bool {
+	return deployOptions.ExternalObjectStore.Endpoint != ""
+}
+
+func validateExternalObjectStore(deployOptions DeployOptions) error {
+	store := deployOptions.ExternalObjectStore
+	if !usesExternalObjectStore(deployOptions) {
+		if store.Bucket != "" || store.Region != "" || store.SecretName != "" || store.PathStyle {
+			return errors.New("the external object store endpoint is required")
+		}
+		return nil
+	}
+
+	u, err := url.Parse(store.Endpoint)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse external object store endpoint")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("unsupported scheme %q, expected http:// or https://", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("the external object store endpoint does not have a host")
+	}
+
+	if store.Bucket == "" {
+		return errors.New("the external object store bucket is required")
+	}
+	if !validBucketName.MatchString(store.Bucket) {
+		return errors.Errorf("invalid bucket name %q", store.Bucket)
+	}
+
+	hasKeys := deployOptions.S3AccessKey != "" || deployOptions.S3SecretKey != ""
+	if store.SecretName != "" && hasKeys {
+		return errors.New("only one of the external object store secret and keys can be set")
+	}
+	if store.SecretName == "" && (deployOptions.S3AccessKey == "" || deployOptions.S3SecretKey == "") {
+		return errors.New("the external object store secret, or the access key id and secret access key, are required")
+	}
+
+	return nil
+}
+
+// objectStoreEndpoint and objectStoreBucket are where the archives are kept, in the bundled minio
+// or in the external object store
+func objectStoreEndpoint(deployOptions DeployOptions) string {
+	if usesExternalObjectStore(deployOptions) {
+		return deployOptions.ExternalObjectStore.Endpoint
+	}
+	return minioEndpoint
+}
+
+func objectStoreBucket(deployOptions DeployOptions) string {
+	if usesExternalObjectStore(deployOptions) {
+		return deployOptions.ExternalObjectStore.Bucket
+	}
+	return minioBucket
+}
+
+// externalObjectStoreKeys returns the access key id and secret access key of the external object
+// store, reading them from the referenced secret if one was given
+func externalObjectStoreKeys(deployOptions DeployOptions, clientset kubernetes.Interface) (string, string, error) {
+	secretName := deployOptions.ExternalObjectStore.SecretName
+	if secretName == "" {
+		return deployOptions.S3AccessKey, deployOptions.S3SecretKey, nil
+	}
+
+	secret, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to get secret %s", secretName)
+	}
+	for _, key := range []string{"accesskey", "secretkey"} {
+		if len(secret.Data[key]) == 0 {
+			return "", "", errors.Errorf("secret %s does not have key %s", secretName, key)
+		}
+	}
+
+	return string(secret.Data["accesskey"]), string(secret.Data["secretkey"]), nil
+}
+
+// readExternalObjectStoreOptions returns the external object store that the kotsadm-minio secret
+// points to, or empty options for the bundled minio
+func readExternalObjectStoreOptions(s3Secret *corev1.Secret) ExternalObjectStoreOptions {
+	if s3Secret == nil || s3Secret.Annotations[externalObjectStoreAnnotation] != "true" {
+		return ExternalObjectStoreOptions{}
+	}
+
+	pathStyle, _ := strconv.ParseBool(string(s3Secret.Data["pathStyle"]))
+	return ExternalObjectStoreOptions{
+		Endpoint:  string(s3Secret.Data["endpoint"]),
+		Region:    string(s3Secret.Data["region"]),
+		Bucket:    string(s3Secret.Data["bucket"]),
+		PathStyle: pathStyle,
+	}
+}
+
+func ensureExternalObjectStore(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	accessKey, secretKey, err := externalObjectStoreKeys(deployOptions, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to get external object store keys")
+	}
+
+	if err := ensureExternalObjectStoreSecret(deployOptions, accessKey, secretKey, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure external object store secret")
+	}
+
+	if err := runPreflightPod(deployOptions, clientset, objectStorePreflightPod(deployOptions), timeoutWaitingForObjectStorePreflight); err != nil {
+		return errors.Wrap(err, "unable to write to the external object store")
+	}
+
+	return nil
+}
+
+func ensureExternalObjectStoreSecret(deployOptions DeployOptions, accessKey string, secretKey string, clientset *kubernetes.Clientset) error {
+	namespace := deployOptions.Namespace
+	secret := externalObjectStoreSecret(deployOptions, accessKey, secretKey)
+
+	existingSecret, err := getS3Secret(namespace, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to check for existing s3 secret")
+	}
+
+	if existingSecret == nil {
+		if _, err := clientset.CoreV1().Secrets(namespace).Create(secret); err != nil {
+			return errors.Wrap(err, "failed to create s3 secret")
+		}
+		return nil
+	}
+
+	if existingSecret.Annotations[externalObjectStoreAnnotation] != "true" {
+		return errors.New("the admin console is already using the bundled minio, changing to an external object store requires migrating the archives")
+	}
+
+	existingSecret.Data = secret.Data
+	if _, err := clientset.CoreV1().Secrets(namespace).Update(existingSecret); err != nil {
+		return errors.Wrap(err, "failed to update s3 secret")
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package kotsadm
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// objectStorePreflightScript writes an object to the bucket and removes it again
+const objectStorePreflightScript = `set -e
+mc --quiet --config-dir /tmp/mc config host add store "$OBJECT_STORE_ENDPOINT" "$OBJECT_STORE_ACCESS_KEY" "$OBJECT_STORE_SECRET_KEY" --api S3v4 --lookup "$OBJECT_STORE_LOOKUP" > /dev/null
+echo "kotsadm" | mc --quiet --config-dir /tmp/mc pipe "store/$OBJECT_STORE_BUCKET/.kotsadm-preflight"
+mc --quiet --config-dir /tmp/mc rm "store/$OBJECT_STORE_BUCKET/.kotsadm-preflight"
+`
+
+func externalObjectStoreSecret(deployOptions DeployOptions, accessKey string, secretKey string) *corev1.Secret {
+	store := deployOptions.ExternalObjectStore
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kotsadm-minio",
+			Namespace: deployOptions.Namespace,
+			Annotations: map[string]string{
+				externalObjectStoreAnnotation: "true",
+			},
+			Labels: kotsadmLabels(),
+		},
+		Data: map[string][]byte{
+			"accesskey": []byte(accessKey),
+			"secretkey": []byte(secretKey),
+			"endpoint":  []byte(store.Endpoint),
+			"region":    []byte(store.Region),
+			"bucket":    []byte(store.Bucket),
+			"pathStyle": []byte(strconv.FormatBool(store.PathStyle)),
+		},
+	}
+
+	return secret
+}
+
+// objectStoreLookup is how mc addresses the bucket, in the path or in the host
+func objectStoreLookup(deployOptions DeployOptions) string {
+	if !usesExternalObjectStore(deployOptions) || deployOptions.ExternalObjectStore.PathStyle {
+		return "path"
+	}
+	return "auto"
+}
+
+// objectStoreEnv are the endpoint, bucket and keys of the object store, for the mc containers
+func objectStoreEnv(deployOptions DeployOptions) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{Name: "OBJECT_STORE_ENDPOINT", Value: objectStoreEndpoint(deployOptions)},
+		{Name: "OBJECT_STORE_BUCKET", Value: objectStoreBucket(deployOptions)},
+		{Name: "OBJECT_STORE_LOOKUP", Value: objectStoreLookup(deployOptions)},
+	}
+	for _, e := range []struct{ name, key string }{
+		{"OBJECT_STORE_ACCESS_KEY", "accesskey"},
+		{"OBJECT_STORE_SECRET_KEY", "secretkey"},
+	} {
+		env = append(env, corev1.EnvVar{
+			Name: e.name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: "kotsadm-minio",
+					},
+					Key: e.key,
+				},
+			},
+		})
+	}
+	return env
+}
+
+// apiObjectStoreEnv are the settings of the external object store that the api needs in addition to
+// the endpoint, bucket and keys
+func apiObjectStoreEnv(deployOptions DeployOptions) []corev1.EnvVar {
+	if !usesExternalObjectStore(deployOptions) {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{Name: "S3_REGION", Value: deployOptions.ExternalObjectStore.Region},
+		{Name: "S3_FORCE_PATH_STYLE", Value: strconv.FormatBool(deployOptions.ExternalObjectStore.PathStyle)},
+	}
+}
+
+func objectStorePreflightPod(deployOptions DeployOptions) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("kotsadm-object-store-preflight-%d", time.Now().Unix()),
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: corev1.PodSpec{
+			NodeSelector:  deployOptions.NodeSelector,
+			Tolerations:   deployOptions.Tolerations,
+			Affinity:      deployOptions.Affinity,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Image:           mcImage(),
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Name:            "kotsadm-object-store-preflight",
+					Command:         []string{"/bin/sh", "-c", objectStorePreflightScript},
+					Env:             objectStoreEnv(deployOptions),
+				},
+			},
+		},
+	}
+
+	addProxy(deployOptions, &pod.Spec)
+	addSidecarInjection(deployOptions, &pod.ObjectMeta, &pod.Spec)
+	addOpenShiftCompatibility(deployOptions, &pod.Spec)
+
+	return pod
+}
@@ -0,0 +1,192 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_validateExternalObjectStore(t *testing.T) {
+	s3 := ExternalObjectStoreOptions{
+		Endpoint: "https://s3.us-east-1.amazonaws.com",
+		Region:   "us-east-1",
+		Bucket:   "kotsadm-archives",
+	}
+
+	tests := []struct {
+		name          string
+		deployOptions DeployOptions
+		expectError   bool
+	}{
+		{
+			name:          "bundled minio",
+			deployOptions: DeployOptions{S3AccessKey: "generated", S3SecretKey: "generated"},
+		},
+		{
+			name:          "keys",
+			deployOptions: DeployOptions{ExternalObjectStore: s3, S3AccessKey: "AKIA", S3SecretKey: "secret"},
+		},
+		{
+			name: "secret",
+			deployOptions: DeployOptions{ExternalObjectStore: ExternalObjectStoreOptions{
+				Endpoint:   "http://minio.storage:9000",
+				Bucket:     "kotsadm",
+				SecretName: "object-store",
+				PathStyle:  true,
+			}},
+		},
+		{
+			name:          "bucket without endpoint",
+			deployOptions: DeployOptions{ExternalObjectStore: ExternalObjectStoreOptions{Bucket: "kotsadm"}},
+			expectError:   true,
+		},
+		{
+			name: "unsupported scheme",
+			deployOptions: DeployOptions{
+				ExternalObjectStore: ExternalObjectStoreOptions{Endpoint: "s3://kotsadm", Bucket: "kotsadm"},
+				S3AccessKey:         "AKIA",
+				S3SecretKey:         "secret",
+			},
+			expectError: true,
+		},
+		{
+			name: "no bucket",
+			deployOptions: DeployOptions{
+				ExternalObjectStore: ExternalObjectStoreOptions{Endpoint: "https://storage.googleapis.com"},
+				S3AccessKey:         "GOOG",
+				S3SecretKey:         "secret",
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid bucket",
+			deployOptions: DeployOptions{
+				ExternalObjectStore: ExternalObjectStoreOptions{Endpoint: "https://storage.googleapis.com", Bucket: "Kotsadm_Archives"},
+				S3AccessKey:         "GOOG",
+				S3SecretKey:         "secret",
+			},
+			expectError: true,
+		},
+		{
+			name:          "no keys",
+			deployOptions: DeployOptions{ExternalObjectStore: s3, S3AccessKey: "AKIA"},
+			expectError:   true,
+		},
+		{
+			name: "secret and keys",
+			deployOptions: DeployOptions{
+				ExternalObjectStore: ExternalObjectStoreOptions{Endpoint: s3.Endpoint, Bucket: s3.Bucket, SecretName: "object-store"},
+				S3AccessKey:         "AKIA",
+				S3SecretKey:         "secret",
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateExternalObjectStore(test.deployOptions)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_externalObjectStoreKeys(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "object-store",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"accesskey": []byte("AKIA"),
+			"secretkey": []byte("secret"),
+		},
+	}, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "incomplete",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"accesskey": []byte("AKIA"),
+		},
+	})
+
+	deployOptions := DeployOptions{
+		Namespace:           "default",
+		ExternalObjectStore: ExternalObjectStoreOptions{SecretName: "object-store"},
+	}
+	accessKey, secretKey, err := externalObjectStoreKeys(deployOptions, clientset)
+	require.NoError(t, err)
+	assert.Equal(t, "AKIA", accessKey)
+	assert.Equal(t, "secret", secretKey)
+
+	deployOptions.ExternalObjectStore.SecretName = "incomplete"
+	_, _, err = externalObjectStoreKeys(deployOptions, clientset)
+	assert.Error(t, err)
+
+	accessKey, secretKey, err = externalObjectStoreKeys(DeployOptions{S3AccessKey: "flag", S3SecretKey: "flag-secret"}, clientset)
+	require.NoError(t, err)
+	assert.Equal(t, "flag", accessKey)
+	assert.Equal(t, "flag-secret", secretKey)
+}
+
+func Test_readExternalObjectStoreOptions(t *testing.T) {
+	store := ExternalObjectStoreOptions{
+		Endpoint:  "https://storage.googleapis.com",
+		Bucket:    "kotsadm-archives",
+		Region:    "auto",
+		PathStyle: true,
+	}
+
+	secret := externalObjectStoreSecret(DeployOptions{Namespace: "default", ExternalObjectStore: store}, "GOOG", "secret")
+	assert.Equal(t, store, readExternalObjectStoreOptions(secret))
+
+	// the secret of the bundled minio only has the keys
+	assert.Equal(t, ExternalObjectStoreOptions{}, readExternalObjectStoreOptions(s3Secret("default", "access", "secret")))
+	assert.Equal(t, ExternalObjectStoreOptions{}, readExternalObjectStoreOptions(nil))
+}
+
+func Test_objectStoreEnv(t *testing.T) {
+	envValue := func(env []corev1.EnvVar, name string) string {
+		for _, e := range env {
+			if e.Name == name {
+				return e.Value
+			}
+		}
+		return ""
+	}
+
+	bundled := DeployOptions{Namespace: "default"}
+	env := objectStoreEnv(bundled)
+	assert.Equal(t, "http://kotsadm-minio:9000", envValue(env, "OBJECT_STORE_ENDPOINT"))
+	assert.Equal(t, "kotsadm", envValue(env, "OBJECT_STORE_BUCKET"))
+	assert.Equal(t, "path", envValue(env, "OBJECT_STORE_LOOKUP"))
+	assert.Empty(t, apiObjectStoreEnv(bundled))
+
+	external := DeployOptions{
+		Namespace: "default",
+		ExternalObjectStore: ExternalObjectStoreOptions{
+			Endpoint: "https://s3.us-west-2.amazonaws.com",
+			Region:   "us-west-2",
+			Bucket:   "kotsadm-archives",
+		},
+	}
+	pod := objectStorePreflightPod(external)
+	env = pod.Spec.Containers[0].Env
+	assert.Equal(t, "https://s3.us-west-2.amazonaws.com", envValue(env, "OBJECT_STORE_ENDPOINT"))
+	assert.Equal(t, "kotsadm-archives", envValue(env, "OBJECT_STORE_BUCKET"))
+	assert.Equal(t, "auto", envValue(env, "OBJECT_STORE_LOOKUP"))
+	assert.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+
+	apiEnv := apiObjectStoreEnv(external)
+	assert.Equal(t, "us-west-2", envValue(apiEnv, "S3_REGION"))
+	assert.Equal(t, "false", envValue(apiEnv, "S3_FORCE_PATH_STYLE"))
+}
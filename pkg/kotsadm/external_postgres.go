@@ -0,0 +1,188 @@
+package kotsadm
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// externalPostgresAnnotation is set on the kotsadm-postgres secret when it points to a
+// database that is not managed by kots, so that upgrades don't deploy the bundled postgres
+const externalPostgresAnnotation = "kots.io/external-postgres"
+
+const defaultExternalPostgresSecretKey = "uri"
+
+var timeoutWaitingForPostgresPreflight = time.Duration(time.Minute * 2)
+
+var postgresSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+func usesExternalPostgres(deployOptions DeployOptions) bool {
+	return deployOptions.ExternalPostgresURI != "" || deployOptions.ExternalPostgresSecretName != ""
+}
+
+func validateExternalPostgres(deployOptions DeployOptions) error {
+	if deployOptions.ExternalPostgresURI != "" && deployOptions.ExternalPostgresSecretName != "" {
+		return errors.New("only one of the external postgres uri and secret can be set")
+	}
+
+	if deployOptions.ExternalPostgresSSLMode != "" {
+		valid := false
+		for _, sslMode := range postgresSSLModes {
+			if deployOptions.ExternalPostgresSSLMode == sslMode {
+				valid = true
+			}
+		}
+		if !valid {
+			return errors.Errorf("unknown postgres sslmode %q, must be one of %s", deployOptions.ExternalPostgresSSLMode, strings.Join(postgresSSLModes, ", "))
+		}
+	}
+
+	if deployOptions.ExternalPostgresURI != "" {
+		if _, err := postgresURIWithSSLMode(deployOptions.ExternalPostgresURI, ""); err != nil {
+			return errors.Wrap(err, "failed to parse external postgres uri")
+		}
+	}
+
+	return nil
+}
+
+// externalPostgresURI returns the connection string for the external database, reading it
+// from the referenced secret if one was given
+func externalPostgresURI(deployOptions DeployOptions, clientset kubernetes.Interface) (string, error) {
+	uri := deployOptions.ExternalPostgresURI
+	if deployOptions.ExternalPostgresSecretName != "" {
+		secret, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(deployOptions.ExternalPostgresSecretName, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get secret %s", deployOptions.ExternalPostgresSecretName)
+		}
+
+		key := deployOptions.ExternalPostgresSecretKey
+		if key == "" {
+			key = defaultExternalPostgresSecretKey
+		}
+		data, ok := secret.Data[key]
+		if !ok {
+			return "", errors.Errorf("secret %s does not have key %s", deployOptions.ExternalPostgresSecretName, key)
+		}
+		uri = strings.TrimSpace(string(data))
+	}
+
+	return postgresURIWithSSLMode(uri, deployOptions.ExternalPostgresSSLMode)
+}
+
+// postgresURIWithSSLMode sets the sslmode parameter of a postgres connection uri.
+// The uri is returned unchanged if sslMode is empty.
+func postgresURIWithSSLMode(uri string, sslMode string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse uri")
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return "", errors.Errorf("unsupported scheme %q, expected postgresql://", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", errors.New("uri does not have a host")
+	}
+
+	if sslMode == "" {
+		return uri, nil
+	}
+
+	query := u.Query()
+	query.Set("sslmode", sslMode)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func ensureExternalPostgres(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	uri, err := externalPostgresURI(deployOptions, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to get external postgres uri")
+	}
+
+	if err := ensureExternalPostgresSecret(deployOptions.Namespace, uri, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure external postgres secret")
+	}
+
+	if err := runPostgresPreflight(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to connect to external postgres")
+	}
+
+	return nil
+}
+
+func ensureExternalPostgresSecret(namespace string, uri string, clientset *kubernetes.Clientset) error {
+	existingSecret, err := getPostgresSecret(namespace, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to check for existing postgres secret")
+	}
+
+	if existingSecret == nil {
+		_, err := clientset.CoreV1().Secrets(namespace).Create(externalPgSecret(namespace, uri))
+		if err != nil {
+			return errors.Wrap(err, "failed to create postgres secret")
+		}
+		return nil
+	}
+
+	if existingSecret.Annotations[externalPostgresAnnotation] != "true" {
+		return errors.New("the admin console is already using the bundled postgres, changing to an external database requires migrating the data")
+	}
+
+	existingSecret.Data = externalPgSecret(namespace, uri).Data
+	if _, err := clientset.CoreV1().Secrets(namespace).Update(existingSecret); err != nil {
+		return errors.Wrap(err, "failed to update postgres secret")
+	}
+
+	return nil
+}
+
+// runPostgresPreflight connects to the database from inside the cluster, since the database
+// is often not reachable from where kots is run
+func runPostgresPreflight(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	if err := runPreflightPod(deployOptions, clientset, postgresPreflightPod(deployOptions), timeoutWaitingForPostgresPreflight); err != nil {
+		return errors.Wrap(err, "unable to connect to database")
+	}
+	return nil
+}
+
+// runPreflightPod runs the pod to completion and deletes it. The error of a failed pod is its logs.
+func runPreflightPod(deployOptions DeployOptions, clientset *kubernetes.Clientset, pod *corev1.Pod, timeout time.Duration) error {
+	_, err := clientset.CoreV1().Pods(deployOptions.Namespace).Create(pod)
+	if err != nil {
+		return errors.Wrap(err, "failed to create preflight pod")
+	}
+	defer clientset.CoreV1().Pods(deployOptions.Namespace).Delete(pod.Name, &metav1.DeleteOptions{})
+
+	start := time.Now()
+	for {
+		current, err := clientset.CoreV1().Pods(deployOptions.Namespace).Get(pod.Name, metav1.GetOptions{})
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get preflight pod")
+		}
+
+		if current != nil {
+			switch current.Status.Phase {
+			case corev1.PodSucceeded:
+				return nil
+			case corev1.PodFailed:
+				logs, _ := clientset.CoreV1().Pods(deployOptions.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).DoRaw()
+				return errors.New(strings.TrimSpace(string(logs)))
+			}
+		}
+
+		if err := waitToPoll(deployOptions.context()); err != nil {
+			return err
+		}
+
+		if time.Now().Sub(start) > deployOptions.TuningProfile.timeout(timeout) {
+			return errors.New("timeout waiting for preflight pod")
+		}
+	}
+}
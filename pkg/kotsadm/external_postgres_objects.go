@@ -0,0 +1,84 @@
+package kotsadm
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func externalPgSecret(namespace string, uri string) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kotsadm-postgres",
+			Namespace: namespace,
+			Annotations: map[string]string{
+				externalPostgresAnnotation: "true",
+			},
+			Labels: kotsadmLabels(),
+		},
+		Data: map[string][]byte{
+			"uri": []byte(uri),
+		},
+	}
+
+	return secret
+}
+
+func postgresPreflightPod(deployOptions DeployOptions) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("kotsadm-postgres-preflight-%d", time.Now().Unix()),
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: corev1.PodSpec{
+			NodeSelector:  deployOptions.NodeSelector,
+			Tolerations:   deployOptions.Tolerations,
+			Affinity:      deployOptions.Affinity,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Image:           postgresImage(),
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Name:            "kotsadm-postgres-preflight",
+					Command: []string{
+						"psql",
+						"$(POSTGRES_URI)",
+						"--no-psqlrc",
+						"--command",
+						"SELECT 1",
+					},
+					Env: []corev1.EnvVar{
+						{
+							Name: "POSTGRES_URI",
+							ValueFrom: &corev1.EnvVarSource{
+								SecretKeyRef: &corev1.SecretKeySelector{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: "kotsadm-postgres",
+									},
+									Key: "uri",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	addProxy(deployOptions, &pod.Spec)
+	addSidecarInjection(deployOptions, &pod.ObjectMeta, &pod.Spec)
+	addOpenShiftCompatibility(deployOptions, &pod.Spec)
+
+	return pod
+}
@@ -0,0 +1,142 @@
+package kotsadm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+const namespaceManifestName = "namespace.yaml"
+
+// GenerateManifests returns all objects needed to run the admin console, including the namespace and
+// secrets, without applying them to a cluster. Values that are generated when deploying, such as the
+// session key and database password, are generated here so that the manifests are complete.
+func GenerateManifests(deployOptions DeployOptions) (map[string][]byte, error) {
+	if err := ValidateTuningProfile(deployOptions.TuningProfile); err != nil {
+		return nil, errors.Wrap(err, "failed to validate tuning profile")
+	}
+	if err := validateExternalPostgres(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate external postgres")
+	}
+	if deployOptions.ExternalPostgresSecretName != "" {
+		return nil, errors.New("an external postgres secret can only be used when deploying to a cluster, set the external postgres uri instead")
+	}
+	if err := validateExternalObjectStore(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate external object store")
+	}
+	if deployOptions.ExternalObjectStore.SecretName != "" {
+		return nil, errors.New("an external object store secret can only be used when deploying to a cluster, set the access key id and secret access key instead")
+	}
+	if deployOptions.SharedPassword == "" && deployOptions.SharedPasswordBcrypt == "" {
+		return nil, errors.New("a shared password is required")
+	}
+
+	if deployOptions.JWT == "" {
+		deployOptions.JWT = uuid.New().String()
+	}
+	if deployOptions.PostgresPassword == "" {
+		deployOptions.PostgresPassword = uuid.New().String()
+	}
+	if deployOptions.AutoCreateClusterToken == "" {
+		deployOptions.AutoCreateClusterToken = uuid.New().String()
+	}
+
+	docs, err := YAML(deployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get admin console yaml")
+	}
+
+	// the namespace is created separately by whoever can create cluster scoped objects
+	if deployOptions.MinimalRBAC {
+		return docs, nil
+	}
+
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+	var namespace bytes.Buffer
+	if err := s.Encode(namespaceObject(deployOptions.Namespace), &namespace); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal namespace")
+	}
+	docs[namespaceManifestName] = namespace.Bytes()
+
+	return docs, nil
+}
+
+// ManifestsToStream returns the manifests as a single multi document yaml, with the namespace first
+func ManifestsToStream(docs map[string][]byte) []byte {
+	var stream bytes.Buffer
+	for i, name := range manifestNames(docs) {
+		if i > 0 {
+			stream.WriteString("---\n")
+		}
+		stream.Write(docs[name])
+	}
+	return stream.Bytes()
+}
+
+// WriteManifestsToDir writes each manifest to a file in dir, along with a kustomization.yaml that includes them
+func WriteManifestsToDir(docs map[string][]byte, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create dir")
+	}
+
+	resources := []string{}
+	for _, name := range manifestNames(docs) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), docs[name], 0644); err != nil {
+			return errors.Wrapf(err, "failed to write %s", name)
+		}
+		resources = append(resources, name)
+	}
+
+	kustomization := kustomizetypes.Kustomization{
+		TypeMeta: kustomizetypes.TypeMeta{
+			APIVersion: "kustomize.config.k8s.io/v1beta1",
+			Kind:       "Kustomization",
+		},
+		Resources: resources,
+	}
+	if err := k8sutil.WriteKustomizationToFile(&kustomization, filepath.Join(dir, "kustomization.yaml")); err != nil {
+		return errors.Wrap(err, "failed to write kustomization")
+	}
+
+	return nil
+}
+
+// manifestNames returns the names of the manifests in a stable order, with the namespace first
+// so that a stream can be applied with kubectl
+func manifestNames(docs map[string][]byte) []string {
+	names := []string{}
+	for name := range docs {
+		if name != namespaceManifestName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if _, ok := docs[namespaceManifestName]; ok {
+		names = append([]string{namespaceManifestName}, names...)
+	}
+	return names
+}
+
+func namespaceObject(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+}
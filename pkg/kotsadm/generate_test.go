@@ -0,0 +1,65 @@
+package kotsadm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GenerateManifestsValidation(t *testing.T) {
+	_, err := GenerateManifests(DeployOptions{Namespace: "default"})
+	assert.Error(t, err, "shared password is required")
+
+	_, err = GenerateManifests(DeployOptions{Namespace: "default", SharedPassword: "password", ExternalPostgresSecretName: "pg"})
+	assert.Error(t, err, "an existing secret can't be referenced without a cluster")
+
+	_, err = GenerateManifests(DeployOptions{Namespace: "default", SharedPassword: "password", TuningProfile: TuningProfile("fast")})
+	assert.Error(t, err)
+}
+
+func Test_ManifestsToStream(t *testing.T) {
+	docs := map[string][]byte{
+		"web-service.yaml":    []byte("kind: Service\n"),
+		"api-deployment.yaml": []byte("kind: Deployment\n"),
+		"namespace.yaml":      []byte("kind: Namespace\n"),
+	}
+
+	expected := `kind: Namespace
+---
+kind: Deployment
+---
+kind: Service
+`
+	assert.Equal(t, expected, string(ManifestsToStream(docs)))
+}
+
+func Test_WriteManifestsToDir(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kotsadm-manifests")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	docs := map[string][]byte{
+		"web-service.yaml": []byte("kind: Service\n"),
+		"namespace.yaml":   []byte("kind: Namespace\n"),
+	}
+
+	outputDir := filepath.Join(tempDir, "admin-console")
+	req.NoError(WriteManifestsToDir(docs, outputDir))
+
+	for name, content := range docs {
+		written, err := ioutil.ReadFile(filepath.Join(outputDir, name))
+		req.NoError(err)
+		assert.Equal(t, content, written)
+	}
+
+	kustomization, err := k8sutil.ReadKustomizationFromFile(filepath.Join(outputDir, "kustomization.yaml"))
+	req.NoError(err)
+	assert.Equal(t, []string{"namespace.yaml", "web-service.yaml"}, kustomization.Resources)
+}
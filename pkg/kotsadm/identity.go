@@ -0,0 +1,340 @@
+package kotsadm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	serializer "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// IdentityRoleAdmin can change the application and the admin console, IdentityRoleReadOnly
+	// can only view them
+	IdentityRoleAdmin    = "admin"
+	IdentityRoleReadOnly = "read-only"
+
+	// identityClientSecretKeyID and identityClientSecretKeySecret are the keys of the existing
+	// secret with the credentials that the admin console is registered with at the provider
+	identityClientSecretKeyID     = "client-id"
+	identityClientSecretKeySecret = "client-secret"
+)
+
+// IdentityOptions put the admin console behind the single sign-on of an OIDC provider. Dex is
+// deployed with the admin console to log in with the provider, and the groups of a user are
+// mapped to admin console roles.
+type IdentityOptions struct {
+	// IssuerURL is the issuer of the OIDC provider, and ClientSecretName is an existing secret
+	// in the namespace with the client-id and client-secret of the admin console at the provider
+	IssuerURL        string
+	ClientSecretName string
+
+	// GroupRoles maps the groups of the provider to IdentityRoleAdmin or IdentityRoleReadOnly,
+	// users that aren't in any of the groups can't log in
+	GroupRoles map[string]string
+
+	// IdentityServiceAddress is the url that dex is exposed at, which is the issuer of the tokens
+	// that the admin console gets. AdminConsoleAddress is the url that the admin console is
+	// exposed at, the address of the Ingress or http://<Hostname> when empty.
+	IdentityServiceAddress string
+	AdminConsoleAddress    string
+}
+
+func usesIdentity(deployOptions DeployOptions) bool {
+	return deployOptions.Identity.IssuerURL != ""
+}
+
+// ParseGroupRoles parses values in the form group=role
+func ParseGroupRoles(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	groupRoles := map[string]string{}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("invalid group role %q, expected group=role", value)
+		}
+		groupRoles[kv[0]] = kv[1]
+	}
+
+	return groupRoles, nil
+}
+
+func validateIdentityOptions(deployOptions DeployOptions) error {
+	identity := deployOptions.Identity
+	if !usesIdentity(deployOptions) {
+		if identity.ClientSecretName != "" || len(identity.GroupRoles) > 0 || identity.IdentityServiceAddress != "" || identity.AdminConsoleAddress != "" {
+			return errors.New("the identity provider issuer url is required")
+		}
+		return nil
+	}
+
+	if identity.ClientSecretName == "" {
+		return errors.New("the name of the secret with the identity provider client credentials is required")
+	}
+	if identity.IdentityServiceAddress == "" {
+		return errors.New("the identity service address is required")
+	}
+	if len(identity.GroupRoles) == 0 {
+		return errors.New("at least one group must be mapped to a role")
+	}
+
+	for _, address := range []string{identity.IssuerURL, identity.IdentityServiceAddress, identity.AdminConsoleAddress} {
+		if address == "" {
+			continue
+		}
+		u, err := url.Parse(address)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.Errorf("invalid address %q, expected a url like https://sso.example.com", address)
+		}
+	}
+
+	for group, role := range identity.GroupRoles {
+		if role != IdentityRoleAdmin && role != IdentityRoleReadOnly {
+			return errors.Errorf("invalid role %q of group %q, expected %s or %s", role, group, IdentityRoleAdmin, IdentityRoleReadOnly)
+		}
+	}
+
+	return nil
+}
+
+// adminConsoleAddress is the url that the provider redirects back to after a login
+func adminConsoleAddress(deployOptions DeployOptions) string {
+	if deployOptions.Identity.AdminConsoleAddress != "" {
+		return strings.TrimSuffix(deployOptions.Identity.AdminConsoleAddress, "/")
+	}
+	if usesIngress(deployOptions) {
+		return ingressAddress(deployOptions)
+	}
+	return "http://" + deployOptions.Hostname
+}
+
+func getIdentityYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var secret bytes.Buffer
+	if err := s.Encode(dexClientSecret(deployOptions.Namespace, generateDexClientSecret()), &secret); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal dex client secret")
+	}
+	docs["secret-dex-client.yaml"] = secret.Bytes()
+
+	dexConfig, err := dexConfigMap(deployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get dex config map")
+	}
+	var configMap bytes.Buffer
+	if err := s.Encode(dexConfig, &configMap); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal dex config map")
+	}
+	docs["dex-configmap.yaml"] = configMap.Bytes()
+
+	var deployment bytes.Buffer
+	if err := s.Encode(dexDeployment(deployOptions), &deployment); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal dex deployment")
+	}
+	docs["dex-deployment.yaml"] = deployment.Bytes()
+
+	var service bytes.Buffer
+	if err := s.Encode(dexService(deployOptions.Namespace), &service); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal dex service")
+	}
+	docs["dex-service.yaml"] = service.Bytes()
+
+	return docs, nil
+}
+
+func ensureIdentity(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	if err := ensureDexClientSecret(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure dex client secret")
+	}
+
+	if err := ensureDexConfigMap(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure dex config map")
+	}
+
+	if err := ensureDexDeployment(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure dex deployment")
+	}
+
+	if err := ensureDexService(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure dex service")
+	}
+
+	return nil
+}
+
+// ensureDexClientSecret keeps the generated secret of an existing install, the api and dex
+// both read it from the secret
+func ensureDexClientSecret(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(dexClientSecretName, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get existing secret")
+	}
+
+	if _, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Create(dexClientSecret(deployOptions.Namespace, generateDexClientSecret())); err != nil {
+		return errors.Wrap(err, "failed to create secret")
+	}
+
+	return nil
+}
+
+// ensureDexConfigMap replaces the config of an existing config map, so that the provider and
+// the addresses can be changed by installing again
+func ensureDexConfigMap(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	configMap, err := dexConfigMap(deployOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to get config map")
+	}
+
+	existing, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Get(dexName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing config map")
+		}
+
+		if err := applyPatches(deployOptions, configMap); err != nil {
+			return errors.Wrap(err, "failed to patch config map")
+		}
+		if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(configMap); err != nil {
+			return errors.Wrap(err, "failed to create config map")
+		}
+
+		return nil
+	}
+
+	existing.Data = configMap.Data
+	if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update config map")
+	}
+
+	return nil
+}
+
+// ensureDexDeployment updates the pod template of an existing deployment, dex only reads its
+// config when it starts and the annotation with the config checksum rolls the pods
+func ensureDexDeployment(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	configMap, err := dexConfigMap(deployOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to get config map")
+	}
+
+	deployment := dexDeployment(deployOptions)
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	checksum := sha256.Sum256([]byte(configMap.Data[dexConfigKey]))
+	deployment.Spec.Template.Annotations[dexConfigChecksumAnnotation] = fmt.Sprintf("%x", checksum)
+	if err := applyPatches(deployOptions, deployment); err != nil {
+		return errors.Wrap(err, "failed to patch deployment")
+	}
+
+	existing, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Get(dexName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing deployment")
+		}
+
+		if _, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Create(deployment); err != nil {
+			return errors.Wrap(err, "failed to create deployment")
+		}
+
+		return nil
+	}
+
+	existing.Spec.Template = deployment.Spec.Template
+	if _, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update deployment")
+	}
+
+	return nil
+}
+
+func ensureDexService(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	_, err := clientset.CoreV1().Services(deployOptions.Namespace).Get(dexName, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get existing service")
+	}
+
+	service := dexService(deployOptions.Namespace)
+	if err := applyPatches(deployOptions, service); err != nil {
+		return errors.Wrap(err, "failed to patch service")
+	}
+	if _, err := clientset.CoreV1().Services(deployOptions.Namespace).Create(service); err != nil {
+		return errors.Wrap(err, "failed to create service")
+	}
+
+	return nil
+}
+
+// readIdentityOptions returns the identity options that dex and the api were deployed with, or
+// empty options when the admin console doesn't use an identity provider
+func readIdentityOptions(namespace string, clientset *kubernetes.Clientset) (IdentityOptions, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(dexName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return IdentityOptions{}, nil
+	}
+	if err != nil {
+		return IdentityOptions{}, errors.Wrap(err, "failed to get dex config map")
+	}
+
+	config := dexConfig{}
+	if err := yaml.Unmarshal([]byte(configMap.Data[dexConfigKey]), &config); err != nil {
+		return IdentityOptions{}, errors.Wrap(err, "failed to unmarshal dex config")
+	}
+
+	options := IdentityOptions{
+		IdentityServiceAddress: config.Issuer,
+	}
+	if len(config.Connectors) > 0 {
+		options.IssuerURL = config.Connectors[0].Config.Issuer
+	}
+	if len(config.StaticClients) > 0 && len(config.StaticClients[0].RedirectURIs) > 0 {
+		options.AdminConsoleAddress = strings.TrimSuffix(config.StaticClients[0].RedirectURIs[0], identityCallbackPath)
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(dexName, metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return IdentityOptions{}, errors.Wrap(err, "failed to get dex deployment")
+	}
+	if err == nil && len(deployment.Spec.Template.Spec.Containers) > 0 {
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			if env.Name == "OIDC_CLIENT_ID" && env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				options.ClientSecretName = env.ValueFrom.SecretKeyRef.Name
+			}
+		}
+	}
+
+	deployment, err = clientset.AppsV1().Deployments(namespace).Get("kotsadm-api", metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return IdentityOptions{}, errors.Wrap(err, "failed to get api deployment")
+	}
+	if err == nil && len(deployment.Spec.Template.Spec.Containers) > 0 {
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			if env.Name == "IDENTITY_GROUP_ROLES" {
+				if err := json.Unmarshal([]byte(env.Value), &options.GroupRoles); err != nil {
+					return IdentityOptions{}, errors.Wrap(err, "failed to unmarshal group roles")
+				}
+			}
+		}
+	}
+
+	return options, nil
+}
@@ -0,0 +1,365 @@
+package kotsadm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	dexTag                      = "v2.26.0"
+	dexName                     = "kotsadm-dex"
+	dexPort                     = 5556
+	dexConfigKey                = "config.yaml"
+	dexConfigChecksumAnnotation = "kots.io/dex-config-checksum"
+	dexClientSecretName         = "kotsadm-dex-client"
+	dexClientSecretKey          = "clientSecret"
+
+	// dexClientID is the client that the admin console is registered as in dex
+	dexClientID = "kotsadm"
+
+	// identityCallbackPath is where the api finishes a login, after dex redirects back to the
+	// admin console
+	identityCallbackPath = "/api/v1/oidc/login/callback"
+)
+
+// dexConfig is the part of the dex config that the admin console sets, dex expands the $
+// variables in the connector config from its environment so that the provider credentials
+// stay in the secret
+type dexConfig struct {
+	Issuer        string            `json:"issuer"`
+	Storage       dexStorage        `json:"storage"`
+	Web           dexWeb            `json:"web"`
+	OAuth2        dexOAuth2         `json:"oauth2"`
+	StaticClients []dexStaticClient `json:"staticClients"`
+	Connectors    []dexConnector    `json:"connectors"`
+}
+
+type dexStorage struct {
+	Type string `json:"type"`
+}
+
+type dexWeb struct {
+	HTTP string `json:"http"`
+}
+
+type dexOAuth2 struct {
+	SkipApprovalScreen bool `json:"skipApprovalScreen"`
+}
+
+type dexStaticClient struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	SecretEnv    string   `json:"secretEnv"`
+	RedirectURIs []string `json:"redirectURIs"`
+}
+
+type dexConnector struct {
+	Type   string             `json:"type"`
+	ID     string             `json:"id"`
+	Name   string             `json:"name"`
+	Config dexConnectorConfig `json:"config"`
+}
+
+type dexConnectorConfig struct {
+	Issuer               string   `json:"issuer"`
+	ClientID             string   `json:"clientID"`
+	ClientSecret         string   `json:"clientSecret"`
+	RedirectURI          string   `json:"redirectURI"`
+	Scopes               []string `json:"scopes"`
+	InsecureEnableGroups bool     `json:"insecureEnableGroups"`
+}
+
+func generateDexClientSecret() string {
+	return uuid.New().String()
+}
+
+func dexClientSecret(namespace string, clientSecret string) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dexClientSecretName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Data: map[string][]byte{
+			dexClientSecretKey: []byte(clientSecret),
+		},
+	}
+
+	return secret
+}
+
+// dexConfigMap only has the admin console as a client, and keeps its state in memory because a
+// login only lasts until the api has exchanged the code for a token
+func dexConfigMap(deployOptions DeployOptions) (*corev1.ConfigMap, error) {
+	issuer := deployOptions.Identity.IdentityServiceAddress
+
+	config := dexConfig{
+		Issuer: issuer,
+		Storage: dexStorage{
+			Type: "memory",
+		},
+		Web: dexWeb{
+			HTTP: fmt.Sprintf("0.0.0.0:%d", dexPort),
+		},
+		OAuth2: dexOAuth2{
+			SkipApprovalScreen: true,
+		},
+		StaticClients: []dexStaticClient{
+			{
+				ID:           dexClientID,
+				Name:         "Admin Console",
+				SecretEnv:    "KOTSADM_CLIENT_SECRET",
+				RedirectURIs: []string{adminConsoleAddress(deployOptions) + identityCallbackPath},
+			},
+		},
+		Connectors: []dexConnector{
+			{
+				Type: "oidc",
+				ID:   "oidc",
+				Name: "Single Sign-On",
+				Config: dexConnectorConfig{
+					Issuer:               deployOptions.Identity.IssuerURL,
+					ClientID:             "$OIDC_CLIENT_ID",
+					ClientSecret:         "$OIDC_CLIENT_SECRET",
+					RedirectURI:          issuer + "/callback",
+					Scopes:               []string{"openid", "profile", "email", "groups"},
+					InsecureEnableGroups: true,
+				},
+			},
+		},
+	}
+
+	b, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal dex config")
+	}
+
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dexName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Data: map[string]string{
+			dexConfigKey: string(b),
+		},
+	}
+
+	return configMap, nil
+}
+
+// dexHealthzPath is under the path of the issuer, dex serves all of its endpoints there
+func dexHealthzPath(deployOptions DeployOptions) string {
+	u, err := url.Parse(deployOptions.Identity.IdentityServiceAddress)
+	if err != nil {
+		return "/healthz"
+	}
+	return path.Join("/", u.Path, "healthz")
+}
+
+func dexDeployment(deployOptions DeployOptions) *appsv1.Deployment {
+	env := []corev1.EnvVar{
+		{
+			Name: "KOTSADM_CLIENT_SECRET",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: dexClientSecretName,
+					},
+					Key: dexClientSecretKey,
+				},
+			},
+		},
+	}
+	for _, key := range []string{identityClientSecretKeyID, identityClientSecretKeySecret} {
+		env = append(env, corev1.EnvVar{
+			Name: "OIDC_" + strings.ToUpper(strings.Replace(key, "-", "_", -1)),
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: deployOptions.Identity.ClientSecretName,
+					},
+					Key: key,
+				},
+			},
+		})
+	}
+
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dexName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": dexName,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": dexName,
+					},
+				},
+				Spec: corev1.PodSpec{
+					NodeSelector: deployOptions.NodeSelector,
+					Tolerations:  deployOptions.Tolerations,
+					Affinity:     deployOptions.Affinity,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsUser: util.IntPointer(1001),
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: dexName,
+									},
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Image:           thirdPartyImage("quay.io/dexidp/dex", dexTag),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Name:            "dex",
+							Command:         []string{"/usr/local/bin/dex", "serve", "/etc/dex/" + dexConfigKey},
+							Env:             env,
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "http",
+									ContainerPort: dexPort,
+								},
+							},
+							ReadinessProbe: deployOptions.TuningProfile.readinessProbe(&corev1.Probe{
+								FailureThreshold:    3,
+								InitialDelaySeconds: 2,
+								PeriodSeconds:       2,
+								SuccessThreshold:    1,
+								TimeoutSeconds:      1,
+								Handler: corev1.Handler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path:   dexHealthzPath(deployOptions),
+										Port:   intstr.FromInt(dexPort),
+										Scheme: corev1.URISchemeHTTP,
+									},
+								},
+							}),
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "config",
+									MountPath: "/etc/dex",
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	addProxy(deployOptions, &deployment.Spec.Template.Spec)
+	addSidecarInjection(deployOptions, &deployment.Spec.Template.ObjectMeta, &deployment.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &deployment.Spec.Template.Spec)
+
+	return deployment
+}
+
+func dexService(namespace string) *corev1.Service {
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dexName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				"app": dexName,
+			},
+			Type: corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       dexPort,
+					TargetPort: intstr.FromString("http"),
+				},
+			},
+		},
+	}
+
+	return service
+}
+
+// identityEnv is the config of the api to log in with dex, and to map the groups of a user to
+// admin console roles
+func identityEnv(deployOptions DeployOptions) []corev1.EnvVar {
+	if !usesIdentity(deployOptions) {
+		return nil
+	}
+
+	// the roles have been validated, a map of strings always marshals
+	groupRoles, _ := json.Marshal(deployOptions.Identity.GroupRoles)
+
+	return []corev1.EnvVar{
+		{
+			Name:  "IDENTITY_ISSUER_URL",
+			Value: deployOptions.Identity.IdentityServiceAddress,
+		},
+		{
+			Name:  "IDENTITY_CLIENT_ID",
+			Value: dexClientID,
+		},
+		{
+			Name: "IDENTITY_CLIENT_SECRET",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: dexClientSecretName,
+					},
+					Key: dexClientSecretKey,
+				},
+			},
+		},
+		{
+			Name:  "IDENTITY_REDIRECT_URL",
+			Value: adminConsoleAddress(deployOptions) + identityCallbackPath,
+		},
+		{
+			Name:  "IDENTITY_GROUP_ROLES",
+			Value: string(groupRoles),
+		},
+	}
+}
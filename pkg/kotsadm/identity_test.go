@@ -0,0 +1,131 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_validateIdentityOptions(t *testing.T) {
+	valid := IdentityOptions{
+		IssuerURL:              "https://sso.example.com",
+		ClientSecretName:       "sso-client",
+		GroupRoles:             map[string]string{"ops": IdentityRoleAdmin, "dev": IdentityRoleReadOnly},
+		IdentityServiceAddress: "https://dex.example.com",
+	}
+
+	tests := []struct {
+		name      string
+		identity  func(IdentityOptions) IdentityOptions
+		expectErr bool
+	}{
+		{
+			name:     "not used",
+			identity: func(IdentityOptions) IdentityOptions { return IdentityOptions{} },
+		},
+		{
+			name:     "valid",
+			identity: func(o IdentityOptions) IdentityOptions { return o },
+		},
+		{
+			name:      "missing issuer",
+			identity:  func(o IdentityOptions) IdentityOptions { o.IssuerURL = ""; return o },
+			expectErr: true,
+		},
+		{
+			name:      "missing client secret",
+			identity:  func(o IdentityOptions) IdentityOptions { o.ClientSecretName = ""; return o },
+			expectErr: true,
+		},
+		{
+			name:      "missing identity service address",
+			identity:  func(o IdentityOptions) IdentityOptions { o.IdentityServiceAddress = ""; return o },
+			expectErr: true,
+		},
+		{
+			name:      "no group roles",
+			identity:  func(o IdentityOptions) IdentityOptions { o.GroupRoles = nil; return o },
+			expectErr: true,
+		},
+		{
+			name:      "invalid role",
+			identity:  func(o IdentityOptions) IdentityOptions { o.GroupRoles = map[string]string{"ops": "owner"}; return o },
+			expectErr: true,
+		},
+		{
+			name:      "invalid admin console address",
+			identity:  func(o IdentityOptions) IdentityOptions { o.AdminConsoleAddress = "kotsadm.example.com"; return o },
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateIdentityOptions(DeployOptions{Identity: test.identity(valid)})
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_ParseGroupRoles(t *testing.T) {
+	groupRoles, err := ParseGroupRoles([]string{"ops=admin", "dev=read-only"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ops": "admin", "dev": "read-only"}, groupRoles)
+
+	_, err = ParseGroupRoles([]string{"ops"})
+	assert.Error(t, err)
+}
+
+func Test_identityObjects(t *testing.T) {
+	deployOptions := DeployOptions{
+		Namespace: "default",
+		Hostname:  "localhost:8800",
+		Identity: IdentityOptions{
+			IssuerURL:              "https://sso.example.com",
+			ClientSecretName:       "sso-client",
+			GroupRoles:             map[string]string{"ops": IdentityRoleAdmin},
+			IdentityServiceAddress: "https://kotsadm.example.com/dex",
+		},
+	}
+
+	configMap, err := dexConfigMap(deployOptions)
+	require.NoError(t, err)
+	config := dexConfig{}
+	require.NoError(t, yaml.Unmarshal([]byte(configMap.Data[dexConfigKey]), &config))
+	assert.Equal(t, "https://kotsadm.example.com/dex", config.Issuer)
+	assert.Equal(t, []string{"http://localhost:8800/api/v1/oidc/login/callback"}, config.StaticClients[0].RedirectURIs)
+	assert.Equal(t, "https://sso.example.com", config.Connectors[0].Config.Issuer)
+	assert.Equal(t, "https://kotsadm.example.com/dex/callback", config.Connectors[0].Config.RedirectURI)
+
+	container := dexDeployment(deployOptions).Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "/dex/healthz", container.ReadinessProbe.HTTPGet.Path)
+	secretRefs := map[string]string{}
+	for _, env := range container.Env {
+		secretRefs[env.Name] = env.ValueFrom.SecretKeyRef.Name + "/" + env.ValueFrom.SecretKeyRef.Key
+	}
+	assert.Equal(t, map[string]string{
+		"KOTSADM_CLIENT_SECRET": "kotsadm-dex-client/clientSecret",
+		"OIDC_CLIENT_ID":        "sso-client/client-id",
+		"OIDC_CLIENT_SECRET":    "sso-client/client-secret",
+	}, secretRefs)
+
+	apiEnv := map[string]string{}
+	for _, env := range apiDeployment(deployOptions).Spec.Template.Spec.Containers[0].Env {
+		apiEnv[env.Name] = env.Value
+	}
+	assert.Equal(t, "https://kotsadm.example.com/dex", apiEnv["IDENTITY_ISSUER_URL"])
+	assert.Equal(t, "http://localhost:8800/api/v1/oidc/login/callback", apiEnv["IDENTITY_REDIRECT_URL"])
+	assert.Equal(t, `{"ops":"admin"}`, apiEnv["IDENTITY_GROUP_ROLES"])
+
+	apiEnv = map[string]string{}
+	for _, env := range apiDeployment(DeployOptions{Namespace: "default"}).Spec.Template.Spec.Containers[0].Env {
+		apiEnv[env.Name] = env.Value
+	}
+	assert.NotContains(t, apiEnv, "IDENTITY_ISSUER_URL")
+}
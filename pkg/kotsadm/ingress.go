@@ -0,0 +1,306 @@
+package kotsadm
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	certutil "k8s.io/client-go/util/cert"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ingressName            = "kotsadm"
+	ingressTLSSecretName   = "kotsadm-ingress-tls"
+	ingressClassAnnotation = "kubernetes.io/ingress.class"
+)
+
+var routeResource = schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"}
+
+// IngressOptions expose the admin console at a hostname, instead of port-forwarding to the web
+// service. An Ingress is created, or an OpenShift Route with OpenShiftRoute.
+type IngressOptions struct {
+	// Hostname is the host that the admin console is exposed at, nothing is created when it's empty
+	Hostname string
+
+	// TLSSecretName is an existing tls secret in the namespace with the certificate of the
+	// hostname. With AutoCert a certificate is signed for the hostname by the TLSCACert, or by a
+	// generated CA. The admin console is exposed over plain http with neither.
+	TLSSecretName string
+	AutoCert      bool
+
+	// IngressClass selects the ingress controller of the Ingress
+	IngressClass string
+
+	// OpenShiftRoute creates a Route instead of an Ingress. Routes can't reference a secret,
+	// they're edge terminated with the default certificate of the router unless AutoCert is set.
+	OpenShiftRoute bool
+}
+
+func usesIngress(deployOptions DeployOptions) bool {
+	return deployOptions.Ingress.Hostname != ""
+}
+
+// usesIngressTLS is true when the admin console is exposed at an https address
+func usesIngressTLS(deployOptions DeployOptions) bool {
+	ingress := deployOptions.Ingress
+	return ingress.TLSSecretName != "" || ingress.AutoCert || ingress.OpenShiftRoute
+}
+
+func validateIngressOptions(deployOptions DeployOptions) error {
+	ingress := deployOptions.Ingress
+	if !usesIngress(deployOptions) {
+		if ingress.TLSSecretName != "" || ingress.AutoCert || ingress.IngressClass != "" || ingress.OpenShiftRoute {
+			return errors.New("the ingress hostname is required")
+		}
+		return nil
+	}
+
+	if errs := validation.IsDNS1123Subdomain(ingress.Hostname); len(errs) > 0 {
+		return errors.Errorf("invalid ingress hostname %q: %s", ingress.Hostname, errs[0])
+	}
+	if ingress.TLSSecretName != "" && ingress.AutoCert {
+		return errors.New("a tls secret can't be used with an automatic certificate")
+	}
+	if ingress.OpenShiftRoute && ingress.TLSSecretName != "" {
+		return errors.New("routes can't reference a tls secret, use an automatic certificate or the default certificate of the router")
+	}
+	if ingress.OpenShiftRoute && ingress.IngressClass != "" {
+		return errors.New("an ingress class can't be set for routes")
+	}
+
+	return nil
+}
+
+// ingressAddress is the url that the admin console is exposed at
+func ingressAddress(deployOptions DeployOptions) string {
+	if usesIngressTLS(deployOptions) {
+		return "https://" + deployOptions.Ingress.Hostname
+	}
+	return "http://" + deployOptions.Ingress.Hostname
+}
+
+// signIngressCert returns a certificate and key for the hostname when AutoCert is set
+func signIngressCert(deployOptions DeployOptions) ([]byte, []byte, []byte, error) {
+	ca, err := loadOrGenerateCA(deployOptions)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to get CA")
+	}
+
+	hostname := deployOptions.Ingress.Hostname
+	certPEM, keyPEM, err := ca.signCert(hostname, []string{hostname})
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "failed to create certificate for %s", hostname)
+	}
+
+	return ca.certPEM, certPEM, keyPEM, nil
+}
+
+func getIngressYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var caCertPEM, certPEM, keyPEM []byte
+	if deployOptions.Ingress.AutoCert {
+		var err error
+		caCertPEM, certPEM, keyPEM, err = signIngressCert(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to sign ingress certificate")
+		}
+	}
+
+	if deployOptions.Ingress.OpenShiftRoute {
+		// routes aren't in the client-go scheme, so they're marshaled as plain objects
+		route, err := yaml.Marshal(kotsadmRoute(deployOptions, certPEM, keyPEM).Object)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal route")
+		}
+		docs["web-route.yaml"] = route
+		return docs, nil
+	}
+
+	if deployOptions.Ingress.AutoCert {
+		var secret bytes.Buffer
+		if err := s.Encode(tlsSecret(deployOptions.Namespace, ingressTLSSecretName, caCertPEM, certPEM, keyPEM), &secret); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal ingress tls secret")
+		}
+		docs["secret-ingress-tls.yaml"] = secret.Bytes()
+	}
+
+	var ingress bytes.Buffer
+	if err := s.Encode(kotsadmIngress(deployOptions), &ingress); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal ingress")
+	}
+	docs["web-ingress.yaml"] = ingress.Bytes()
+
+	return docs, nil
+}
+
+func ensureIngress(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	if deployOptions.Ingress.OpenShiftRoute {
+		if err := ensureRoute(deployOptions); err != nil {
+			return errors.Wrap(err, "failed to ensure route")
+		}
+		return nil
+	}
+
+	if deployOptions.Ingress.AutoCert {
+		if err := ensureIngressTLSSecret(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure ingress tls secret")
+		}
+	}
+
+	ingress := kotsadmIngress(deployOptions)
+	if err := applyPatches(deployOptions, ingress); err != nil {
+		return errors.Wrap(err, "failed to patch ingress")
+	}
+
+	existing, err := clientset.NetworkingV1beta1().Ingresses(deployOptions.Namespace).Get(ingressName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing ingress")
+		}
+
+		if _, err := clientset.NetworkingV1beta1().Ingresses(deployOptions.Namespace).Create(ingress); err != nil {
+			return errors.Wrap(err, "failed to create ingress")
+		}
+		return nil
+	}
+
+	// the hostname and the certificate can be changed by installing again
+	existing.Annotations = ingress.Annotations
+	existing.Spec = ingress.Spec
+	if _, err := clientset.NetworkingV1beta1().Ingresses(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update ingress")
+	}
+
+	return nil
+}
+
+// ensureIngressTLSSecret keeps the certificate of an existing secret while it's still valid for
+// the hostname, so that it doesn't have to be trusted again after every install
+func ensureIngressTLSSecret(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	existing, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(ingressTLSSecretName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing secret")
+		}
+		existing = nil
+	}
+	if existing != nil {
+		certs, err := certutil.ParseCertsPEM(existing.Data[corev1.TLSCertKey])
+		if err == nil && certs[0].VerifyHostname(deployOptions.Ingress.Hostname) == nil {
+			return nil
+		}
+	}
+
+	caCertPEM, certPEM, keyPEM, err := signIngressCert(deployOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign certificate")
+	}
+	secret := tlsSecret(deployOptions.Namespace, ingressTLSSecretName, caCertPEM, certPEM, keyPEM)
+
+	if existing == nil {
+		if _, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Create(secret); err != nil {
+			return errors.Wrap(err, "failed to create secret")
+		}
+		return nil
+	}
+
+	existing.Data = secret.Data
+	if _, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update secret")
+	}
+
+	return nil
+}
+
+func ensureRoute(deployOptions DeployOptions) error {
+	var certPEM, keyPEM []byte
+	if deployOptions.Ingress.AutoCert {
+		var err error
+		_, certPEM, keyPEM, err = signIngressCert(deployOptions)
+		if err != nil {
+			return errors.Wrap(err, "failed to sign certificate")
+		}
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create dynamic client")
+	}
+
+	if err := ensureUnstructured(client, routeResource, kotsadmRoute(deployOptions, certPEM, keyPEM)); err != nil {
+		return errors.Wrap(err, "failed to ensure route")
+	}
+
+	return nil
+}
+
+// readIngressOptions returns the options of the ingress or the route that the admin console is
+// exposed with, or empty options when it isn't
+func readIngressOptions(namespace string, clientset *kubernetes.Clientset) (IngressOptions, error) {
+	ingress, err := clientset.NetworkingV1beta1().Ingresses(namespace).Get(ingressName, metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return IngressOptions{}, errors.Wrap(err, "failed to get ingress")
+	}
+	if err == nil {
+		options := IngressOptions{
+			IngressClass: ingress.Annotations[ingressClassAnnotation],
+		}
+		if len(ingress.Spec.Rules) > 0 {
+			options.Hostname = ingress.Spec.Rules[0].Host
+		}
+		if len(ingress.Spec.TLS) > 0 {
+			if ingress.Spec.TLS[0].SecretName == ingressTLSSecretName {
+				options.AutoCert = true
+			} else {
+				options.TLSSecretName = ingress.Spec.TLS[0].SecretName
+			}
+		}
+		return options, nil
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return IngressOptions{}, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return IngressOptions{}, errors.Wrap(err, "failed to create dynamic client")
+	}
+
+	// routes can't be found on clusters that aren't openshift
+	route, err := client.Resource(routeResource).Namespace(namespace).Get(ingressName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return IngressOptions{}, nil
+	}
+	if err != nil {
+		return IngressOptions{}, errors.Wrap(err, "failed to get route")
+	}
+
+	options := IngressOptions{
+		OpenShiftRoute: true,
+	}
+	options.Hostname, _, _ = unstructured.NestedString(route.Object, "spec", "host")
+	certificate, _, _ := unstructured.NestedString(route.Object, "spec", "tls", "certificate")
+	options.AutoCert = certificate != ""
+
+	return options, nil
+}
@@ -0,0 +1,102 @@
+package kotsadm
+
+import (
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// kotsadmIngress routes the hostname to the web service, which proxies the api
+func kotsadmIngress(deployOptions DeployOptions) *networkingv1beta1.Ingress {
+	ingress := &networkingv1beta1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1beta1",
+			Kind:       "Ingress",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ingressName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: deployOptions.Ingress.Hostname,
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Path: "/",
+									Backend: networkingv1beta1.IngressBackend{
+										ServiceName: "kotsadm-web",
+										ServicePort: intstr.FromInt(3000),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if deployOptions.Ingress.IngressClass != "" {
+		ingress.Annotations = map[string]string{
+			ingressClassAnnotation: deployOptions.Ingress.IngressClass,
+		}
+	}
+
+	secretName := deployOptions.Ingress.TLSSecretName
+	if deployOptions.Ingress.AutoCert {
+		secretName = ingressTLSSecretName
+	}
+	if secretName != "" {
+		ingress.Spec.TLS = []networkingv1beta1.IngressTLS{
+			{
+				Hosts:      []string{deployOptions.Ingress.Hostname},
+				SecretName: secretName,
+			},
+		}
+	}
+
+	return ingress
+}
+
+// kotsadmRoute is edge terminated, with the certificate and key when they're set and with the
+// default certificate of the router when they aren't. Plain http requests are redirected.
+func kotsadmRoute(deployOptions DeployOptions, certPEM []byte, keyPEM []byte) *unstructured.Unstructured {
+	tls := map[string]interface{}{
+		"termination":                   "edge",
+		"insecureEdgeTerminationPolicy": "Redirect",
+	}
+	if len(certPEM) > 0 {
+		tls["certificate"] = string(certPEM)
+		tls["key"] = string(keyPEM)
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "route.openshift.io/v1",
+			"kind":       "Route",
+			"metadata": map[string]interface{}{
+				"name":      ingressName,
+				"namespace": deployOptions.Namespace,
+				"labels": map[string]interface{}{
+					kotsadmLabelKey: kotsadmLabelValue,
+				},
+			},
+			"spec": map[string]interface{}{
+				"host": deployOptions.Ingress.Hostname,
+				"to": map[string]interface{}{
+					"kind": "Service",
+					"name": "kotsadm-web",
+				},
+				"port": map[string]interface{}{
+					"targetPort": "http",
+				},
+				"tls": tls,
+			},
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func Test_validateIngressOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		ingress   IngressOptions
+		expectErr bool
+	}{
+		{
+			name: "not used",
+		},
+		{
+			name:    "hostname only",
+			ingress: IngressOptions{Hostname: "kotsadm.example.com"},
+		},
+		{
+			name:    "tls secret",
+			ingress: IngressOptions{Hostname: "kotsadm.example.com", TLSSecretName: "kotsadm-cert", IngressClass: "nginx"},
+		},
+		{
+			name:    "route with auto cert",
+			ingress: IngressOptions{Hostname: "kotsadm.example.com", AutoCert: true, OpenShiftRoute: true},
+		},
+		{
+			name:      "missing hostname",
+			ingress:   IngressOptions{AutoCert: true},
+			expectErr: true,
+		},
+		{
+			name:      "invalid hostname",
+			ingress:   IngressOptions{Hostname: "https://kotsadm.example.com"},
+			expectErr: true,
+		},
+		{
+			name:      "tls secret and auto cert",
+			ingress:   IngressOptions{Hostname: "kotsadm.example.com", TLSSecretName: "kotsadm-cert", AutoCert: true},
+			expectErr: true,
+		},
+		{
+			name:      "route with tls secret",
+			ingress:   IngressOptions{Hostname: "kotsadm.example.com", TLSSecretName: "kotsadm-cert", OpenShiftRoute: true},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateIngressOptions(DeployOptions{Ingress: test.ingress})
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_kotsadmIngress(t *testing.T) {
+	deployOptions := DeployOptions{
+		Namespace: "default",
+		Ingress: IngressOptions{
+			Hostname:     "kotsadm.example.com",
+			AutoCert:     true,
+			IngressClass: "nginx",
+		},
+	}
+
+	ingress := kotsadmIngress(deployOptions)
+	assert.Equal(t, "nginx", ingress.Annotations[ingressClassAnnotation])
+	assert.Equal(t, "kotsadm.example.com", ingress.Spec.Rules[0].Host)
+	assert.Equal(t, "kotsadm-web", ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName)
+	assert.Equal(t, ingressTLSSecretName, ingress.Spec.TLS[0].SecretName)
+	assert.Equal(t, "https://kotsadm.example.com", adminConsoleAddress(deployOptions))
+
+	ingress = kotsadmIngress(DeployOptions{Ingress: IngressOptions{Hostname: "kotsadm.example.com"}})
+	assert.Empty(t, ingress.Annotations)
+	assert.Empty(t, ingress.Spec.TLS)
+}
+
+func Test_signIngressCert(t *testing.T) {
+	deployOptions := DeployOptions{
+		Ingress: IngressOptions{
+			Hostname: "kotsadm.example.com",
+			AutoCert: true,
+		},
+	}
+
+	_, certPEM, keyPEM, err := signIngressCert(deployOptions)
+	require.NoError(t, err)
+	certs, err := certutil.ParseCertsPEM(certPEM)
+	require.NoError(t, err)
+	assert.NoError(t, certs[0].VerifyHostname("kotsadm.example.com"))
+
+	route := kotsadmRoute(deployOptions, certPEM, keyPEM)
+	host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+	assert.Equal(t, "kotsadm.example.com", host)
+	certificate, _, _ := unstructured.NestedString(route.Object, "spec", "tls", "certificate")
+	assert.Equal(t, string(certPEM), certificate)
+}
@@ -0,0 +1,128 @@
+package kotsadm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/util"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NameCollision is an existing object that an install would replace, that belongs to another app instance
+type NameCollision struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// InstanceName is the instance that the object belongs to, empty for single app installs
+	InstanceName string
+}
+
+func (c NameCollision) String() string {
+	owner := "an install without an instance name"
+	if c.InstanceName != "" {
+		owner = fmt.Sprintf("instance %q", c.InstanceName)
+	}
+	return fmt.Sprintf("%s %s/%s belongs to %s", c.Kind, c.Namespace, c.Name, owner)
+}
+
+func validateInstanceOptions(deployOptions DeployOptions) error {
+	return util.ValidateInstanceName(deployOptions.InstanceName)
+}
+
+// instanceLabels are the labels of the objects that belong to the app instance
+func instanceLabels(deployOptions DeployOptions) map[string]string {
+	labels := kotsadmLabels()
+	if deployOptions.InstanceName != "" {
+		labels[util.InstanceLabelKey] = deployOptions.InstanceName
+	}
+	return labels
+}
+
+type instanceObject struct {
+	kind string
+	name string
+	get  func(name string) (metav1.Object, error)
+}
+
+// FindNameCollisions returns the objects of the app instance of the deploy options that already exist in
+// the namespace and belong to another instance, so that an install can fail before it replaces them
+func FindNameCollisions(deployOptions DeployOptions, clientset kubernetes.Interface) ([]NameCollision, error) {
+	namespace := deployOptions.Namespace
+
+	objects := []instanceObject{
+		{
+			kind: "Secret",
+			name: instancePullSecretName(deployOptions),
+			get: func(name string) (metav1.Object, error) {
+				return clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+			},
+		},
+	}
+	if usesEntitlements(deployOptions) {
+		name := entitlementsObjectName(deployOptions)
+		objects = append(objects,
+			instanceObject{
+				kind: "Secret",
+				name: name,
+				get: func(name string) (metav1.Object, error) {
+					return clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+				},
+			},
+			instanceObject{
+				kind: "ConfigMap",
+				name: name,
+				get: func(name string) (metav1.Object, error) {
+					return clientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+				},
+			},
+			instanceObject{
+				kind: "Deployment",
+				name: name,
+				get: func(name string) (metav1.Object, error) {
+					return clientset.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+				},
+			},
+			instanceObject{
+				kind: "Service",
+				name: name,
+				get: func(name string) (metav1.Object, error) {
+					return clientset.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+				},
+			},
+		)
+	}
+
+	collisions := []NameCollision{}
+	for _, o := range objects {
+		existing, err := o.get(o.name)
+		if kuberneteserrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %s %s", strings.ToLower(o.kind), o.name)
+		}
+		if util.IsInstanceObject(existing.GetLabels(), deployOptions.InstanceName) {
+			continue
+		}
+
+		collisions = append(collisions, NameCollision{
+			Kind:         o.kind,
+			Namespace:    namespace,
+			Name:         o.name,
+			InstanceName: existing.GetLabels()[util.InstanceLabelKey],
+		})
+	}
+
+	return collisions, nil
+}
+
+func nameCollisionsError(collisions []NameCollision) error {
+	objects := []string{}
+	for _, c := range collisions {
+		objects = append(objects, c.String())
+	}
+	return util.NewError(util.ErrNameCollision, "objects of the app belong to another app instance", errors.New(strings.Join(objects, ", ")))
+}
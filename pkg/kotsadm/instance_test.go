@@ -0,0 +1,105 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testSecret(name string, instanceName string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+	}
+	if instanceName != "" {
+		secret.Labels = map[string]string{util.InstanceLabelKey: instanceName}
+	}
+	return secret
+}
+
+func Test_FindNameCollisions(t *testing.T) {
+	tests := []struct {
+		name          string
+		deployOptions DeployOptions
+		objects       []runtime.Object
+		expected      []NameCollision
+	}{
+		{
+			name:          "nothing installed",
+			deployOptions: DeployOptions{Namespace: "default", InstanceName: "my-app"},
+			expected:      []NameCollision{},
+		},
+		{
+			name:          "single app install again",
+			deployOptions: DeployOptions{Namespace: "default"},
+			objects:       []runtime.Object{testSecret("kotsadm-replicated-registry", "")},
+			expected:      []NameCollision{},
+		},
+		{
+			name:          "another instance",
+			deployOptions: DeployOptions{Namespace: "default", InstanceName: "my-app"},
+			objects:       []runtime.Object{testSecret("kotsadm-replicated-registry", ""), testSecret("other-app-kotsadm-replicated-registry", "other-app")},
+			expected:      []NameCollision{},
+		},
+		{
+			name:          "the same instance",
+			deployOptions: DeployOptions{Namespace: "default", InstanceName: "my-app"},
+			objects:       []runtime.Object{testSecret("my-app-kotsadm-replicated-registry", "my-app")},
+			expected:      []NameCollision{},
+		},
+		{
+			name:          "not created by the instance",
+			deployOptions: DeployOptions{Namespace: "default", InstanceName: "my-app"},
+			objects:       []runtime.Object{testSecret("my-app-kotsadm-replicated-registry", "")},
+			expected: []NameCollision{
+				{Kind: "Secret", Namespace: "default", Name: "my-app-kotsadm-replicated-registry"},
+			},
+		},
+		{
+			name:          "entitlements of an instance",
+			deployOptions: DeployOptions{Namespace: "default", EntitlementsLicense: []byte(entitlementsTestLicense)},
+			objects:       []runtime.Object{testSecret("kotsadm-entitlements", "my-app")},
+			expected: []NameCollision{
+				{Kind: "Secret", Namespace: "default", Name: "kotsadm-entitlements", InstanceName: "my-app"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(test.objects...)
+			collisions, err := FindNameCollisions(test.deployOptions, clientset)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, collisions)
+		})
+	}
+}
+
+func Test_nameCollisionsError(t *testing.T) {
+	err := nameCollisionsError([]NameCollision{
+		{Kind: "Secret", Namespace: "default", Name: "kotsadm-entitlements", InstanceName: "my-app"},
+		{Kind: "Secret", Namespace: "default", Name: "kotsadm-replicated-registry"},
+	})
+	assert.Equal(t, util.ErrNameCollision, util.ErrorCodeOf(err))
+	assert.Equal(t, `objects of the app belong to another app instance: Secret default/kotsadm-entitlements belongs to instance "my-app", Secret default/kotsadm-replicated-registry belongs to an install without an instance name`, err.Error())
+}
+
+func Test_entitlementsInstanceObjects(t *testing.T) {
+	deployOptions := DeployOptions{Namespace: "default", InstanceName: "my-app"}
+
+	service := entitlementsService(deployOptions)
+	assert.Equal(t, "my-app-kotsadm-entitlements", service.Name)
+	assert.Equal(t, "my-app", service.Labels[util.InstanceLabelKey])
+
+	deployment := entitlementsDeployment(deployOptions)
+	assert.Equal(t, service.Spec.Selector, deployment.Spec.Template.Labels)
+	assert.Equal(t, "my-app-kotsadm-entitlements", deployment.Spec.Template.Spec.Volumes[1].Secret.SecretName)
+}
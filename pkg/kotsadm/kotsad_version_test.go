@@ -1,9 +1,11 @@
 package kotsadm
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_kotsadmRegistry(t *testing.T) {
@@ -42,3 +44,82 @@ func Test_kotsadmRegistry(t *testing.T) {
 		})
 	}
 }
+
+func Test_kotsadmImage(t *testing.T) {
+	apiDigest := "sha256:0b7a4a0b5a1c3fbb6a76e2b5f5d0e4ed4a0aa7a5d2f1c8d8e8b7f6a5c4d3e2f1"
+	postgresDigest := "sha256:1c8b5b1c6b2d4fcc7b87f3c6f6e1f5fe5b1bb8b6e3f2d9e9f9c8f7b6d5e4f3a2"
+	overrideDigest := "sha256:2d9c6c2d7c3e5fdd8c98f4d7f7f2f6ff6c2cc9c7f4f3e0f0f0d9f8c7e6f5f4b3"
+
+	tests := []struct {
+		name             string
+		imageDigests     string
+		overrideVersion  string
+		overrideDigests  map[string]string
+		expectedAPI      string
+		expectedPostgres string
+	}{
+		{
+			name:             "no digests",
+			expectedAPI:      "kotsadm/kotsadm-api:alpha",
+			expectedPostgres: "postgres:12.2",
+		},
+		{
+			name:             "released digests",
+			imageDigests:     fmt.Sprintf("kotsadm-api=%s,postgres=%s", apiDigest, postgresDigest),
+			expectedAPI:      fmt.Sprintf("kotsadm/kotsadm-api:alpha@%s", apiDigest),
+			expectedPostgres: fmt.Sprintf("postgres:12.2@%s", postgresDigest),
+		},
+		{
+			name:             "overridden tag",
+			imageDigests:     fmt.Sprintf("kotsadm-api=%s,postgres=%s", apiDigest, postgresDigest),
+			overrideVersion:  "v1.15.0",
+			expectedAPI:      "kotsadm/kotsadm-api:v1.15.0",
+			expectedPostgres: fmt.Sprintf("postgres:12.2@%s", postgresDigest),
+		},
+		{
+			name:             "overridden digests",
+			imageDigests:     fmt.Sprintf("kotsadm-api=%s,postgres=%s", apiDigest, postgresDigest),
+			overrideVersion:  "v1.15.0",
+			overrideDigests:  map[string]string{"kotsadm-api": overrideDigest, "postgres": ""},
+			expectedAPI:      fmt.Sprintf("kotsadm/kotsadm-api:v1.15.0@%s", overrideDigest),
+			expectedPostgres: "postgres:12.2",
+		},
+	}
+
+	defer func() {
+		imageDigests = ""
+		OverrideVersion = ""
+		OverrideDigests = map[string]string{}
+	}()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			imageDigests = test.imageDigests
+			OverrideVersion = test.overrideVersion
+			OverrideRegistry = ""
+			OverrideNamespace = ""
+			OverrideDigests = map[string]string{}
+			if test.overrideDigests != nil {
+				OverrideDigests = test.overrideDigests
+			}
+
+			require.NoError(t, validateImageDigests())
+			assert.Equal(t, test.expectedAPI, kotsadmImage("kotsadm-api"))
+			assert.Equal(t, test.expectedPostgres, postgresImage())
+		})
+	}
+}
+
+func Test_ParseImageDigests(t *testing.T) {
+	digest := "sha256:0b7a4a0b5a1c3fbb6a76e2b5f5d0e4ed4a0aa7a5d2f1c8d8e8b7f6a5c4d3e2f1"
+
+	digests, err := ParseImageDigests([]string{"kotsadm-api=" + digest, "minio/mc="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"kotsadm-api": digest, "minio/mc": ""}, digests)
+
+	_, err = ParseImageDigests([]string{"kotsadm-api"})
+	assert.Error(t, err)
+
+	_, err = ParseImageDigests([]string{"kotsadm-api=sha256:abc"})
+	assert.Error(t, err)
+}
@@ -1,9 +1,18 @@
 package kotsadm
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/prompt"
+	"github.com/replicatedhq/kots/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,17 +38,214 @@ type DeployOptions struct {
 	NodePort               int32
 	Hostname               string
 	ApplicationMetadata    []byte
+	TuningProfile          TuningProfile
+
+	// ExternalPostgresURI, or the key in an existing secret, is the connection string of a
+	// database to use instead of deploying postgres with the admin console
+	ExternalPostgresURI        string
+	ExternalPostgresSecretName string
+	ExternalPostgresSecretKey  string
+	ExternalPostgresSSLMode    string
+
+	// ExternalObjectStore is a bucket to keep the archives in instead of deploying minio with the
+	// admin console
+	ExternalObjectStore ExternalObjectStoreOptions
+
+	// Resources are the cpu and memory requests and limits of each component
+	Resources ComponentResources
+
+	// PostgresStorageSize and MinioStorageSize are the sizes of the volumes claimed by the
+	// statefulsets. StorageClassName is used for both, or the cluster default when empty.
+	PostgresStorageSize string
+	MinioStorageSize    string
+	StorageClassName    string
+
+	// ServiceType, NodePort and ServiceAnnotations configure the web service, and the APIService
+	// fields the api service. A node port can be fixed for NodePort and LoadBalancer services.
+	ServiceAnnotations    map[string]string
+	APIServiceType        string
+	APINodePort           int32
+	APIServiceAnnotations map[string]string
+
+	// Ingress exposes the admin console at a hostname with an Ingress or an OpenShift Route
+	Ingress IngressOptions
+
+	// NetworkPolicies only let the pods that use postgres and minio connect to them, and limit the
+	// egress of the api to DNS, the pods in the namespace, the kubernetes api, and the proxy,
+	// registry, replicated.app and EgressEndpoints. The endpoints are hostnames, ip addresses or
+	// CIDRs, hostnames are resolved when the policies are created. The address of the kubernetes
+	// api is only known when deploying to a cluster, it has to be an egress endpoint of generated
+	// manifests.
+	NetworkPolicies bool
+	EgressEndpoints []string
+
+	// APIReplicas and WebReplicas run more than one api and web pod, with pod disruption budgets
+	// so that the admin console keeps running while nodes are drained. Postgres always runs one
+	// pod, an admin console that has to survive the loss of the database node should use a
+	// replicated database with ExternalPostgresURI.
+	APIReplicas int32
+	WebReplicas int32
+
+	// MinimalRBAC only creates namespace scoped roles, see MinimalRBACLimitations for
+	// what the admin console can't do without cluster scoped roles
+	MinimalRBAC bool
+
+	// OpenShift runs the admin console pods with the user and group ids that the restricted SCC
+	// assigns instead of fixed ids, and lets the service accounts of the namespace use the
+	// restricted SCC. It's set when deploying to a cluster that serves the openshift security api.
+	OpenShift bool
+
+	// NodeSelector, Tolerations and Affinity are set on all admin console pods
+	NodeSelector map[string]string
+	Tolerations  []corev1.Toleration
+	Affinity     *corev1.Affinity
+
+	// EnableTLS serves postgres and the api over TLS inside the cluster, with certificates signed
+	// by TLSCACert and TLSCAKey (PEM encoded), or by a generated CA when they're not set
+	EnableTLS bool
+	TLSCACert []byte
+	TLSCAKey  []byte
+
+	// BackupSchedule is the cron schedule that snapshots of the database and object store are
+	// taken on, and BackupDestination is the S3 compatible bucket they're written to
+	BackupSchedule    string
+	BackupDestination BackupDestination
+
+	// Patches are applied to the admin console objects before they're created, so that they can
+	// be customized (e.g. with annotations or a priorityClassName) without changing the generators
+	Patches []ObjectPatch
+
+	// HTTPProxy, HTTPSProxy and NoProxy are set on all admin console pods, for clusters that can only
+	// reach upstreams and the license api through a proxy. AdditionalCACert (PEM encoded) is trusted
+	// by the pods in addition to the system roots.
+	HTTPProxy        string
+	HTTPSProxy       string
+	NoProxy          string
+	AdditionalCACert []byte
+
+	// RegistryCredentials are the cloud credentials of the registry that images were pushed to.
+	// When they're exchanged for short lived tokens (ecr, gcr or acr), a cronjob refreshes the
+	// token in the pull secret before it expires.
+	RegistryCredentials registry.RegistryOptions
+
+	// BootstrapLicense, and the optional BootstrapConfigValues, are uploaded by a job once the api is
+	// ready, so that the app is installed without running kots install against the cluster. The app
+	// is named BootstrapAppName, or after the license when it's empty. The job is only generated with
+	// the manifests, kots install uploads the license itself.
+	BootstrapLicense      []byte
+	BootstrapConfigValues []byte
+	BootstrapAppName      string
+
+	// EntitlementsLicense is the signed license that the entitlement service serves to the
+	// application, so that it can read its license fields at runtime from
+	// http://kotsadm-entitlements:3000. The service isn't deployed when it's empty.
+	EntitlementsLicense []byte
+
+	// InstanceName scopes the names of the objects that belong to one app, the pull secret that
+	// is refreshed and the entitlement service, so that more than one app can be installed in
+	// the namespace. See FindNameCollisions.
+	InstanceName string
+
+	// SidecarInjection sets the istio sidecar injection of the admin console pods to
+	// SidecarInjectionEnabled or SidecarInjectionDisabled, the namespace default is kept when empty.
+	// ServiceMesh creates the istio objects that postgres needs to be reachable in a namespace
+	// that requires mutual TLS.
+	SidecarInjection string
+	ServiceMesh      bool
+
+	// Identity puts the admin console behind the single sign-on of an OIDC provider, instead
+	// of the shared password
+	Identity IdentityOptions
+
+	// Prompter asks for the options that are required and missing, e.g. the shared password. It
+	// prompts on the terminal when it's nil, use prompt.NonInteractive to get an error instead.
+	Prompter prompt.Prompter
+	// Silent doesn't log the progress of the deploy to stdout
+	Silent bool
+
+	// ctx is the context of DeployWithContext and UpgradeWithContext
+	ctx context.Context
 }
 
 type UpgradeOptions struct {
 	Namespace  string
 	Kubeconfig string
+	// Prompter and Silent are the same as the DeployOptions fields
+	Prompter prompt.Prompter
+	Silent   bool
 }
 
 // YAML will return a map containing the YAML needed to run the admin console
 func YAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	if err := validateStorageOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate storage options")
+	}
+	if err := validateTLSOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate tls options")
+	}
+	if err := validateServiceOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate service options")
+	}
+	if err := validateIngressOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate ingress options")
+	}
+	if err := validateNetworkPolicyOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate network policy options")
+	}
+	if err := validateReplicaOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate replica options")
+	}
+	if err := validateBackupOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate backup options")
+	}
+	if err := validatePatches(deployOptions.Patches); err != nil {
+		return nil, errors.Wrap(err, "failed to validate patches")
+	}
+	if err := validateProxyOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate proxy options")
+	}
+	if err := validateIdentityOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate identity options")
+	}
+	if err := validateBootstrapOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate bootstrap options")
+	}
+	if err := validateServiceMeshOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate service mesh options")
+	}
+	if err := validateEntitlementsOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate entitlements options")
+	}
+	if err := validateInstanceOptions(deployOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to validate instance options")
+	}
+	if err := validateImageDigests(); err != nil {
+		return nil, err
+	}
+	if err := validateApplicationMetadata(deployOptions); err != nil {
+		return nil, err
+	}
+
 	docs := map[string][]byte{}
 
+	if deployOptions.EnableTLS {
+		tlsDocs, err := getTLSYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get tls yaml")
+		}
+		for n, v := range tlsDocs {
+			docs[n] = v
+		}
+	}
+
+	proxyDocs, err := getProxyYAML(deployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get proxy yaml")
+	}
+	for n, v := range proxyDocs {
+		docs[n] = v
+	}
+
 	if deployOptions.ApplicationMetadata != nil {
 		metadataDocs, err := getApplicationMetadataYAML(deployOptions.ApplicationMetadata, deployOptions.Namespace)
 		if err != nil {
@@ -50,22 +256,62 @@ func YAML(deployOptions DeployOptions) (map[string][]byte, error) {
 		}
 	}
 
-	minioDocs, err := getMinioYAML(deployOptions.Namespace)
+	if deployOptions.NetworkPolicies {
+		networkPolicyDocs, err := getNetworkPolicyYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get network policy yaml")
+		}
+		for n, v := range networkPolicyDocs {
+			docs[n] = v
+		}
+	}
+
+	if deployOptions.OpenShift {
+		openshiftDocs, err := getOpenShiftYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get openshift yaml")
+		}
+		for n, v := range openshiftDocs {
+			docs[n] = v
+		}
+	}
+
+	pdbDocs, err := getPodDisruptionBudgetYAML(deployOptions)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get minio yaml")
+		return nil, errors.Wrap(err, "failed to get pod disruption budget yaml")
 	}
-	for n, v := range minioDocs {
+	for n, v := range pdbDocs {
 		docs[n] = v
 	}
 
-	postgresDocs, err := getPostgresYAML(deployOptions.Namespace, deployOptions.PostgresPassword)
+	serviceMeshDocs, err := getServiceMeshYAML(deployOptions)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get postgres yaml")
+		return nil, errors.Wrap(err, "failed to get service mesh yaml")
 	}
-	for n, v := range postgresDocs {
+	for n, v := range serviceMeshDocs {
 		docs[n] = v
 	}
 
+	if !usesExternalObjectStore(deployOptions) {
+		minioDocs, err := getMinioYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get minio yaml")
+		}
+		for n, v := range minioDocs {
+			docs[n] = v
+		}
+	}
+
+	if !usesExternalPostgres(deployOptions) {
+		postgresDocs, err := getPostgresYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get postgres yaml")
+		}
+		for n, v := range postgresDocs {
+			docs[n] = v
+		}
+	}
+
 	migrationDocs, err := getMigrationsYAML(deployOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get migrations yaml")
@@ -84,7 +330,7 @@ func YAML(deployOptions DeployOptions) (map[string][]byte, error) {
 	}
 
 	// api
-	apiDocs, err := getApiYAML(deployOptions.Namespace, deployOptions.AutoCreateClusterToken)
+	apiDocs, err := getApiYAML(deployOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get api yaml")
 	}
@@ -101,8 +347,18 @@ func YAML(deployOptions DeployOptions) (map[string][]byte, error) {
 		docs[n] = v
 	}
 
+	if usesIngress(deployOptions) {
+		ingressDocs, err := getIngressYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get ingress yaml")
+		}
+		for n, v := range ingressDocs {
+			docs[n] = v
+		}
+	}
+
 	// operator
-	operatorDocs, err := getOperatorYAML(deployOptions.Namespace, deployOptions.AutoCreateClusterToken)
+	operatorDocs, err := getOperatorYAML(deployOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get operator yaml")
 	}
@@ -110,10 +366,70 @@ func YAML(deployOptions DeployOptions) (map[string][]byte, error) {
 		docs[n] = v
 	}
 
+	if usesBackups(deployOptions) {
+		backupDocs, err := getBackupYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get backup yaml")
+		}
+		for n, v := range backupDocs {
+			docs[n] = v
+		}
+	}
+
+	if usesRegistryRefresh(deployOptions) {
+		registryRefreshDocs, err := getRegistryRefreshYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get registry refresh yaml")
+		}
+		for n, v := range registryRefreshDocs {
+			docs[n] = v
+		}
+	}
+
+	if usesIdentity(deployOptions) {
+		identityDocs, err := getIdentityYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get identity yaml")
+		}
+		for n, v := range identityDocs {
+			docs[n] = v
+		}
+	}
+
+	if usesEntitlements(deployOptions) {
+		entitlementsDocs, err := getEntitlementsYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get entitlements yaml")
+		}
+		for n, v := range entitlementsDocs {
+			docs[n] = v
+		}
+	}
+
+	if usesBootstrap(deployOptions) {
+		bootstrapDocs, err := getBootstrapYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get bootstrap yaml")
+		}
+		for n, v := range bootstrapDocs {
+			docs[n] = v
+		}
+	}
+
+	if err := patchDocs(deployOptions, docs); err != nil {
+		return nil, errors.Wrap(err, "failed to patch yaml")
+	}
+
 	return docs, nil
 }
 
 func Upgrade(upgradeOptions UpgradeOptions) error {
+	return UpgradeWithContext(context.Background(), upgradeOptions)
+}
+
+// UpgradeWithContext is Upgrade, stopping when ctx is canceled or its deadline passes. See
+// DeployWithContext for the parts of the upgrade that can be stopped.
+func UpgradeWithContext(ctx context.Context, upgradeOptions UpgradeOptions) error {
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return errors.Wrap(err, "failed to get cluster config")
@@ -125,6 +441,9 @@ func Upgrade(upgradeOptions UpgradeOptions) error {
 	}
 
 	log := logger.NewLogger()
+	if upgradeOptions.Silent {
+		log.Silence()
+	}
 
 	_, err = clientset.CoreV1().Namespaces().Get(upgradeOptions.Namespace, metav1.GetOptions{})
 	if kuberneteserrors.IsNotFound(err) {
@@ -133,10 +452,12 @@ func Upgrade(upgradeOptions UpgradeOptions) error {
 		return err
 	}
 
-	deployOptions, err := readDeployOptionsFromCluster(upgradeOptions.Namespace, upgradeOptions.Kubeconfig, clientset)
+	deployOptions, err := readDeployOptionsFromCluster(upgradeOptions.Namespace, upgradeOptions.Kubeconfig, clientset, upgradeOptions.Prompter)
 	if err != nil {
 		return errors.Wrap(err, "failed to read deploy options")
 	}
+	deployOptions.Silent = upgradeOptions.Silent
+	deployOptions.ctx = ctx
 
 	if err := ensureKotsadm(*deployOptions, clientset, log); err != nil {
 		return errors.Wrap(err, "failed to uppgrade admin console")
@@ -146,6 +467,70 @@ func Upgrade(upgradeOptions UpgradeOptions) error {
 }
 
 func Deploy(deployOptions DeployOptions) error {
+	return DeployWithContext(context.Background(), deployOptions)
+}
+
+// DeployWithContext is Deploy, stopping when ctx is canceled or its deadline passes. The kubernetes
+// api calls can't be canceled, ctx is checked between the steps of the deploy and while waiting for
+// pods and jobs. The error wraps the error of ctx, objects that were created before are kept.
+func DeployWithContext(ctx context.Context, deployOptions DeployOptions) error {
+	deployOptions.ctx = ctx
+
+	if err := ValidateTuningProfile(deployOptions.TuningProfile); err != nil {
+		return errors.Wrap(err, "failed to validate tuning profile")
+	}
+	if err := validateExternalPostgres(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate external postgres")
+	}
+	if err := validateExternalObjectStore(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate external object store")
+	}
+	if err := validateStorageOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate storage options")
+	}
+	if err := validateTLSOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate tls options")
+	}
+	if err := validateServiceOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate service options")
+	}
+	if err := validateIngressOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate ingress options")
+	}
+	if err := validateNetworkPolicyOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate network policy options")
+	}
+	if err := validateReplicaOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate replica options")
+	}
+	if err := validateBackupOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate backup options")
+	}
+	if err := validatePatches(deployOptions.Patches); err != nil {
+		return errors.Wrap(err, "failed to validate patches")
+	}
+	if err := validateProxyOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate proxy options")
+	}
+	if err := validateIdentityOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate identity options")
+	}
+	if err := validateServiceMeshOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate service mesh options")
+	}
+	if err := validateEntitlementsOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate entitlements options")
+	}
+	if err := validateInstanceOptions(deployOptions); err != nil {
+		return errors.Wrap(err, "failed to validate instance options")
+	}
+	if err := validateImageDigests(); err != nil {
+		return err
+	}
+	if err := validateApplicationMetadata(deployOptions); err != nil {
+		return err
+	}
+
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return errors.Wrap(err, "failed to get cluster config")
@@ -156,29 +541,47 @@ func Deploy(deployOptions DeployOptions) error {
 		return errors.Wrap(err, "failed to create kubernetes clientset")
 	}
 
-	log := logger.NewLogger()
-
-	namespace := &corev1.Namespace{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "v1",
-			Kind:       "Namespace",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: deployOptions.Namespace,
-		},
+	if !deployOptions.OpenShift {
+		deployOptions.OpenShift, err = isOpenShift(clientset)
+		if err != nil {
+			return errors.Wrap(err, "failed to detect openshift")
+		}
 	}
 
+	log := deployOptions.newLogger()
+
 	log.ChildActionWithSpinner("Creating namespace")
-	_, err = clientset.CoreV1().Namespaces().Create(namespace)
+	if deployOptions.MinimalRBAC {
+		// creating a namespace needs cluster scoped permissions
+		err = errors.New("namespaces are not created with minimal rbac")
+	} else {
+		_, err = clientset.CoreV1().Namespaces().Create(namespaceObject(deployOptions.Namespace))
+	}
 	if err != nil && !kuberneteserrors.IsAlreadyExists(err) {
 		// Can't create namespace, but this might be a role restriction and namespace might already exist.
 		_, err := clientset.CoreV1().Pods(deployOptions.Namespace).List(metav1.ListOptions{})
+		if kuberneteserrors.IsForbidden(err) {
+			return &util.Error{
+				Code:        util.ErrUnauthorized,
+				Message:     "failed to verify access to namespace",
+				Remediation: fmt.Sprintf("Create the %s namespace and grant access to it, or use a kubeconfig with cluster-admin permissions.", deployOptions.Namespace),
+				Err:         err,
+			}
+		}
 		if err != nil {
 			return errors.Wrap(err, "failed to verify access to namespace")
 		}
 	}
 	log.FinishChildSpinner()
 
+	collisions, err := FindNameCollisions(deployOptions, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to check for name collisions")
+	}
+	if len(collisions) > 0 {
+		return nameCollisionsError(collisions)
+	}
+
 	if deployOptions.AutoCreateClusterToken == "" {
 		deployOptions.AutoCreateClusterToken = uuid.New().String()
 	}
@@ -191,22 +594,90 @@ func Deploy(deployOptions DeployOptions) error {
 }
 
 func ensureKotsadm(deployOptions DeployOptions, clientset *kubernetes.Clientset, log *logger.Logger) error {
-	if err := ensureMinio(deployOptions, clientset); err != nil {
-		return errors.Wrap(err, "failed to ensure minio")
+	if err := checkCanceled(deployOptions); err != nil {
+		return err
+	}
+
+	if deployOptions.EnableTLS {
+		if err := ensureTLS(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure tls")
+		}
+	}
+
+	if deployOptions.OpenShift {
+		if err := ensureOpenShiftSCCRoleBinding(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure scc rolebinding")
+		}
+	}
+
+	if usesCABundle(deployOptions) {
+		if err := ensureCABundle(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure ca bundle")
+		}
+	}
+
+	if usesServiceMeshObjects(deployOptions) {
+		if err := ensureServiceMesh(deployOptions); err != nil {
+			return errors.Wrap(err, "failed to ensure service mesh")
+		}
+	}
+
+	if deployOptions.NetworkPolicies {
+		if err := ensureNetworkPolicies(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure network policies")
+		}
 	}
 
-	if err := ensurePostgres(deployOptions, clientset); err != nil {
-		return errors.Wrap(err, "failed to ensure postgres")
+	if err := ensurePodDisruptionBudgets(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure pod disruption budgets")
 	}
 
+	if err := checkCanceled(deployOptions); err != nil {
+		return err
+	}
+	if usesExternalObjectStore(deployOptions) {
+		if err := ensureExternalObjectStore(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure external object store")
+		}
+	} else {
+		if err := ensureMinio(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure minio")
+		}
+	}
+
+	if err := checkCanceled(deployOptions); err != nil {
+		return err
+	}
+	if usesExternalPostgres(deployOptions) {
+		if err := ensureExternalPostgres(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure external postgres")
+		}
+	} else {
+		if err := ensurePostgres(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure postgres")
+		}
+	}
+
+	if err := checkCanceled(deployOptions); err != nil {
+		return err
+	}
 	if err := runSchemaHeroMigrations(deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to run database migrations")
 	}
 
+	if err := checkCanceled(deployOptions); err != nil {
+		return err
+	}
 	if err := ensureSecrets(&deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure secrets exist")
 	}
 
+	if usesIdentity(deployOptions) {
+		if err := ensureIdentity(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure identity")
+		}
+	}
+
 	if err := ensureAPI(&deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure api exists")
 	}
@@ -217,19 +688,120 @@ func ensureKotsadm(deployOptions DeployOptions, clientset *kubernetes.Clientset,
 	}
 	log.FinishSpinner()
 
+	if err := checkCanceled(deployOptions); err != nil {
+		return err
+	}
 	if err := ensureWeb(&deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure web exists")
 	}
 
+	if usesIngress(deployOptions) {
+		if err := ensureIngress(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure ingress")
+		}
+	}
+
+	if err := checkCanceled(deployOptions); err != nil {
+		return err
+	}
 	if err := ensureOperator(deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure operator")
 	}
 
+	if usesBackups(deployOptions) {
+		if err := ensureBackup(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure backup")
+		}
+	}
+
+	if usesRegistryRefresh(deployOptions) {
+		if err := ensureRegistryRefresh(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure registry refresh")
+		}
+	}
+
+	if usesEntitlements(deployOptions) {
+		if err := ensureEntitlements(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure entitlements")
+		}
+	}
+
+	return nil
+}
+
+// pollInterval is how long to wait between checks of the objects that are being deployed
+const pollInterval = time.Second
+
+func (o DeployOptions) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+func (o DeployOptions) newLogger() *logger.Logger {
+	log := logger.NewLogger()
+	if o.Silent {
+		log.Silence()
+	}
+	return log
+}
+
+// updateDeployment sets the images and replicas of an existing deployment to the ones it's
+// generated with, so that upgrading rolls the admin console to the version of kots and
+// installing again changes the replicas. The rest of the existing deployment is kept.
+func updateDeployment(deployOptions DeployOptions, clientset *kubernetes.Clientset, existing *appsv1.Deployment, desired *appsv1.Deployment) error {
+	updated := existing.DeepCopy()
+	if desired.Spec.Replicas != nil {
+		updated.Spec.Replicas = desired.Spec.Replicas
+	}
+
+	images := map[string]string{}
+	for _, container := range desired.Spec.Template.Spec.Containers {
+		images[container.Name] = container.Image
+	}
+	for i, container := range updated.Spec.Template.Spec.Containers {
+		if image, ok := images[container.Name]; ok {
+			updated.Spec.Template.Spec.Containers[i].Image = image
+		}
+	}
+
+	if reflect.DeepEqual(updated.Spec, existing.Spec) {
+		return nil
+	}
+
+	if _, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Update(updated); err != nil {
+		return errors.Wrap(err, "failed to update deployment")
+	}
+
 	return nil
 }
 
-func readDeployOptionsFromCluster(namespace string, kubeconfig string, clientset *kubernetes.Clientset) (*DeployOptions, error) {
+// checkCanceled returns an error when the deploy was canceled or its deadline passed
+func checkCanceled(deployOptions DeployOptions) error {
+	if err := deployOptions.context().Err(); err != nil {
+		return errors.Wrap(err, "deploy stopped")
+	}
+	return nil
+}
+
+// waitToPoll waits before the next check of an object, it returns early with the error of ctx
+// when it's done
+func waitToPoll(ctx context.Context) error {
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func readDeployOptionsFromCluster(namespace string, kubeconfig string, clientset *kubernetes.Clientset, prompter prompt.Prompter) (*DeployOptions, error) {
 	deployOptions := DeployOptions{
+		Prompter:      prompter,
 		Namespace:     namespace,
 		Kubeconfig:    kubeconfig,
 		IncludeShip:   false,
@@ -251,7 +823,7 @@ func readDeployOptionsFromCluster(namespace string, kubeconfig string, clientset
 		}
 	}
 	if deployOptions.SharedPasswordBcrypt == "" {
-		sharedPassword, err := promptForSharedPassword()
+		sharedPassword, err := promptForSharedPassword(prompt.OrTerminal(prompter))
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to prompt for shared password")
 		}
@@ -274,6 +846,7 @@ func readDeployOptionsFromCluster(namespace string, kubeconfig string, clientset
 		if ok {
 			deployOptions.S3SecretKey = string(secretyKey)
 		}
+		deployOptions.ExternalObjectStore = readExternalObjectStoreOptions(s3Secret)
 	}
 	if deployOptions.S3AccessKey == "" {
 		deployOptions.S3AccessKey = uuid.New().String()
@@ -304,6 +877,118 @@ func readDeployOptionsFromCluster(namespace string, kubeconfig string, clientset
 		if ok {
 			deployOptions.PostgresPassword = string(password)
 		}
+
+		// the secret already has the full connection string of an external database
+		if pgSecret.Annotations[externalPostgresAnnotation] == "true" {
+			deployOptions.ExternalPostgresURI = string(pgSecret.Data["uri"])
+		}
+	}
+
+	// node scheduling, keep what the admin console was deployed with
+	existingAPIDeployment, err := clientset.AppsV1().Deployments(namespace).Get("kotsadm-api", metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return nil, errors.Wrap(err, "failed to get api deployment")
+	}
+	var apiPodAnnotations map[string]string
+	if err == nil {
+		podSpec := existingAPIDeployment.Spec.Template.Spec
+		deployOptions.NodeSelector = podSpec.NodeSelector
+		deployOptions.Tolerations = podSpec.Tolerations
+		deployOptions.Affinity = podSpec.Affinity
+		apiPodAnnotations = existingAPIDeployment.Spec.Template.Annotations
+	}
+
+	// replicas, the affinity that spreads the api pods isn't a custom affinity for all pods
+	apiReplicas, webReplicas, generatedAffinity, err := readReplicaOptions(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read replica options")
+	}
+	deployOptions.APIReplicas = apiReplicas
+	deployOptions.WebReplicas = webReplicas
+	if generatedAffinity {
+		deployOptions.Affinity = nil
+	}
+
+	// service mesh, keep the sidecar injection and the postgres objects
+	deployOptions.SidecarInjection, deployOptions.ServiceMesh, err = readServiceMeshOptions(namespace, apiPodAnnotations)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read service mesh options")
+	}
+
+	// services, keep the type, node port and annotations they were created with
+	webServiceType, nodePort, serviceAnnotations, err := readServiceOptions(namespace, "kotsadm-web", clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read web service")
+	}
+	if webServiceType != "" {
+		deployOptions.ServiceType = webServiceType
+		deployOptions.NodePort = nodePort
+		deployOptions.ServiceAnnotations = serviceAnnotations
+	}
+	deployOptions.APIServiceType, deployOptions.APINodePort, deployOptions.APIServiceAnnotations, err = readServiceOptions(namespace, "kotsadm-api", clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read api service")
+	}
+
+	// keep the operator namespace scoped if it was installed that way
+	minimalRBAC, err := usesMinimalRBAC(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check operator role")
+	}
+	deployOptions.MinimalRBAC = minimalRBAC
+
+	// tls, the certificates are kept and only created if they're missing
+	enableTLS, err := usesTLS(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check tls")
+	}
+	deployOptions.EnableTLS = enableTLS
+
+	// scheduled backups, keep the schedule and destination
+	deployOptions.BackupSchedule, deployOptions.BackupDestination, err = readBackupOptions(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read backup options")
+	}
+
+	// registry token refresh, keep the credentials it was configured with
+	deployOptions.RegistryCredentials, err = readRegistryCredentials(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read registry credentials")
+	}
+
+	// proxy and additional CA certificates, keep what the pods were deployed with
+	deployOptions.HTTPProxy, deployOptions.HTTPSProxy, deployOptions.NoProxy, deployOptions.AdditionalCACert, err = readProxyOptions(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read proxy options")
+	}
+
+	// ingress or route, keep the hostname that the admin console is exposed at
+	deployOptions.Ingress, err = readIngressOptions(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read ingress options")
+	}
+
+	// openshift, the pods were deployed without fixed ids
+	deployOptions.OpenShift, err = isOpenShift(clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to detect openshift")
+	}
+
+	// network policies, keep the egress endpoints of the api
+	deployOptions.NetworkPolicies, deployOptions.EgressEndpoints, err = readNetworkPolicyOptions(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read network policy options")
+	}
+
+	// identity provider, keep the provider and the group roles that dex and the api were deployed with
+	deployOptions.Identity, err = readIdentityOptions(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read identity options")
+	}
+
+	deployOptions.EntitlementsLicense, err = readEntitlementsLicense(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read entitlements license")
 	}
 
 	// API encryption key, read from the secret or create new password
@@ -2,8 +2,11 @@ package kotsadm
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Masterminds/semver"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/version"
 )
 
@@ -11,8 +14,17 @@ var (
 	OverrideVersion   = ""
 	OverrideRegistry  = ""
 	OverrideNamespace = ""
+
+	// OverrideDigests replaces the digests that images are pinned to, by image name. An empty
+	// digest deploys the image by tag only.
+	OverrideDigests = map[string]string{}
 )
 
+// imageDigests are the digests of the images that this version of kots deploys, as a comma
+// separated list of name=digest pairs (e.g. kotsadm-api=sha256:...,postgres=sha256:...).
+// It's set when kots is released, with -ldflags "-X github.com/replicatedhq/kots/pkg/kotsadm.imageDigests=..."
+var imageDigests = ""
+
 // return "alpha" for all prerelease or invalid versions of kots,
 // kotsadm tag that matches this version for others
 func kotsadmTag() string {
@@ -48,3 +60,80 @@ func kotsadmRegistry() string {
 
 	return fmt.Sprintf("%s/%s", OverrideRegistry, OverrideNamespace)
 }
+
+// kotsadmImage returns the reference to one of the kotsadm images, pinned by digest when the
+// digest of the released tag is known
+func kotsadmImage(name string) string {
+	image := fmt.Sprintf("%s/%s:%s", kotsadmRegistry(), name, kotsadmTag())
+
+	d, ok := OverrideDigests[name]
+	if !ok && OverrideVersion == "" {
+		// the released digests belong to the released tag only
+		d = releasedImageDigests()[name]
+	}
+	return withDigest(image, d)
+}
+
+// thirdPartyImage returns the reference to an image that isn't built with kots (e.g. postgres),
+// pinned by digest when it's known
+func thirdPartyImage(name string, tag string) string {
+	image := fmt.Sprintf("%s:%s", name, tag)
+
+	d, ok := OverrideDigests[name]
+	if !ok {
+		d = releasedImageDigests()[name]
+	}
+	return withDigest(image, d)
+}
+
+func withDigest(image string, d string) string {
+	if d == "" {
+		return image
+	}
+	return fmt.Sprintf("%s@%s", image, d)
+}
+
+// releasedImageDigests returns the digests that kots was released with. Invalid digests are
+// reported by validateImageDigests before any objects are generated.
+func releasedImageDigests() map[string]string {
+	if imageDigests == "" {
+		return map[string]string{}
+	}
+
+	digests, err := ParseImageDigests(strings.Split(imageDigests, ","))
+	if err != nil {
+		return map[string]string{}
+	}
+	return digests
+}
+
+func validateImageDigests() error {
+	if imageDigests == "" {
+		return nil
+	}
+
+	if _, err := ParseImageDigests(strings.Split(imageDigests, ",")); err != nil {
+		return errors.Wrap(err, "invalid image digests in this build of kots")
+	}
+	return nil
+}
+
+// ParseImageDigests parses values in the form name=digest (e.g. kotsadm-api=sha256:...).
+// The digest can be left empty to deploy the image by tag.
+func ParseImageDigests(values []string) (map[string]string, error) {
+	digests := map[string]string{}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("invalid image digest %q, expected name=digest", value)
+		}
+		if kv[1] != "" {
+			if _, err := digest.Parse(kv[1]); err != nil {
+				return nil, errors.Wrapf(err, "invalid digest for image %s", kv[0])
+			}
+		}
+		digests[kv[0]] = kv[1]
+	}
+
+	return digests, nil
+}
@@ -0,0 +1,144 @@
+package kotsadm
+
+import (
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// SidecarInjectionEnabled and SidecarInjectionDisabled set the istio sidecar injection
+	// annotation of the admin console pods, instead of using the default of the namespace
+	SidecarInjectionEnabled  = "enabled"
+	SidecarInjectionDisabled = "disabled"
+
+	sidecarInjectAnnotation = "sidecar.istio.io/inject"
+)
+
+var (
+	peerAuthenticationResource = schema.GroupVersionResource{Group: "security.istio.io", Version: "v1beta1", Resource: "peerauthentications"}
+	destinationRuleResource    = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "destinationrules"}
+)
+
+func usesSidecarInjection(deployOptions DeployOptions) bool {
+	return deployOptions.SidecarInjection != "" || deployOptions.ServiceMesh
+}
+
+// usesServiceMeshObjects is true when postgres is deployed in a mesh enabled namespace
+func usesServiceMeshObjects(deployOptions DeployOptions) bool {
+	return deployOptions.ServiceMesh && !usesExternalPostgres(deployOptions)
+}
+
+func validateServiceMeshOptions(deployOptions DeployOptions) error {
+	switch deployOptions.SidecarInjection {
+	case "", SidecarInjectionEnabled, SidecarInjectionDisabled:
+		return nil
+	default:
+		return errors.Errorf("unknown sidecar injection %q, expected %s or %s", deployOptions.SidecarInjection, SidecarInjectionEnabled, SidecarInjectionDisabled)
+	}
+}
+
+func getServiceMeshYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	if !usesServiceMeshObjects(deployOptions) {
+		return docs, nil
+	}
+
+	// the istio kinds aren't in the client-go scheme, so they're marshaled as plain objects
+	peerAuthentication, err := yaml.Marshal(postgresPeerAuthentication(deployOptions.Namespace).Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal postgres peer authentication")
+	}
+	docs["postgres-peerauthentication.yaml"] = peerAuthentication
+
+	destinationRule, err := yaml.Marshal(postgresDestinationRule(deployOptions.Namespace).Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal postgres destination rule")
+	}
+	docs["postgres-destinationrule.yaml"] = destinationRule
+
+	return docs, nil
+}
+
+// ensureServiceMesh creates the istio objects that let the admin console connect to postgres, or
+// replaces their specs if they already exist
+func ensureServiceMesh(deployOptions DeployOptions) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create dynamic client")
+	}
+
+	if err := ensureUnstructured(client, peerAuthenticationResource, postgresPeerAuthentication(deployOptions.Namespace)); err != nil {
+		return errors.Wrap(err, "failed to ensure postgres peer authentication")
+	}
+	if err := ensureUnstructured(client, destinationRuleResource, postgresDestinationRule(deployOptions.Namespace)); err != nil {
+		return errors.Wrap(err, "failed to ensure postgres destination rule")
+	}
+
+	return nil
+}
+
+func ensureUnstructured(client dynamic.Interface, resource schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	resourceClient := client.Resource(resource).Namespace(obj.GetNamespace())
+
+	existing, err := resourceClient.Get(obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to get existing %s", obj.GetKind())
+		}
+
+		if _, err := resourceClient.Create(obj, metav1.CreateOptions{}); err != nil {
+			return errors.Wrapf(err, "failed to create %s", obj.GetKind())
+		}
+		return nil
+	}
+
+	existing.Object["spec"] = obj.Object["spec"]
+	if _, err := resourceClient.Update(existing, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to update %s", obj.GetKind())
+	}
+
+	return nil
+}
+
+// readServiceMeshOptions returns the sidecar injection of the api pods and whether the postgres
+// peer authentication exists. The peer authentication can't be found when istio isn't installed.
+func readServiceMeshOptions(namespace string, podAnnotations map[string]string) (string, bool, error) {
+	sidecarInjection := ""
+	switch podAnnotations[sidecarInjectAnnotation] {
+	case "true":
+		sidecarInjection = SidecarInjectionEnabled
+	case "false":
+		sidecarInjection = SidecarInjectionDisabled
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to create dynamic client")
+	}
+
+	_, err = client.Resource(peerAuthenticationResource).Namespace(namespace).Get("kotsadm-postgres", metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return sidecarInjection, false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to get postgres peer authentication")
+	}
+
+	return sidecarInjection, true, nil
+}
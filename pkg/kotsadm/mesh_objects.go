@@ -0,0 +1,89 @@
+package kotsadm
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// postgresPeerAuthentication lets postgres accept connections without mutual TLS, from the
+// migration and snapshot pods that run without a sidecar
+func postgresPeerAuthentication(namespace string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "security.istio.io/v1beta1",
+			"kind":       "PeerAuthentication",
+			"metadata": map[string]interface{}{
+				"name":      "kotsadm-postgres",
+				"namespace": namespace,
+				"labels": map[string]interface{}{
+					kotsadmLabelKey: kotsadmLabelValue,
+				},
+			},
+			"spec": map[string]interface{}{
+				"selector": map[string]interface{}{
+					"matchLabels": map[string]interface{}{
+						"app": "kotsadm-postgres",
+					},
+				},
+				"mtls": map[string]interface{}{
+					"mode": "PERMISSIVE",
+				},
+			},
+		},
+	}
+}
+
+// postgresDestinationRule makes the pods with a sidecar connect to postgres the same way as the
+// ones without, even when a mesh wide rule requires mutual TLS. The connection is still encrypted
+// with EnableTLS.
+func postgresDestinationRule(namespace string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "DestinationRule",
+			"metadata": map[string]interface{}{
+				"name":      "kotsadm-postgres",
+				"namespace": namespace,
+				"labels": map[string]interface{}{
+					kotsadmLabelKey: kotsadmLabelValue,
+				},
+			},
+			"spec": map[string]interface{}{
+				"host": fmt.Sprintf("kotsadm-postgres.%s.svc.cluster.local", namespace),
+				"trafficPolicy": map[string]interface{}{
+					"tls": map[string]interface{}{
+						"mode": "DISABLE",
+					},
+				},
+			},
+		},
+	}
+}
+
+// addSidecarInjection sets the istio sidecar injection annotation of a pod. Pods that run to
+// completion never get a sidecar, it would keep running after them and the job would never finish.
+func addSidecarInjection(deployOptions DeployOptions, objectMeta *metav1.ObjectMeta, podSpec *corev1.PodSpec) {
+	if !usesSidecarInjection(deployOptions) {
+		return
+	}
+
+	inject := ""
+	switch {
+	case podSpec.RestartPolicy == corev1.RestartPolicyNever || podSpec.RestartPolicy == corev1.RestartPolicyOnFailure:
+		inject = "false"
+	case deployOptions.SidecarInjection == SidecarInjectionEnabled:
+		inject = "true"
+	case deployOptions.SidecarInjection == SidecarInjectionDisabled:
+		inject = "false"
+	default:
+		return
+	}
+
+	if objectMeta.Annotations == nil {
+		objectMeta.Annotations = map[string]string{}
+	}
+	objectMeta.Annotations[sidecarInjectAnnotation] = inject
+}
@@ -0,0 +1,119 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_addSidecarInjection(t *testing.T) {
+	tests := []struct {
+		name          string
+		deployOptions DeployOptions
+		expected      map[string]string
+	}{
+		{
+			name:          "not set",
+			deployOptions: DeployOptions{Namespace: "default"},
+			expected:      map[string]string{},
+		},
+		{
+			name:          "enabled",
+			deployOptions: DeployOptions{Namespace: "default", SidecarInjection: SidecarInjectionEnabled},
+			expected: map[string]string{
+				"api":        "true",
+				"web":        "true",
+				"operator":   "true",
+				"postgres":   "true",
+				"minio":      "true",
+				"migrations": "false",
+				"snapshot":   "false",
+				"backup":     "false",
+				"bootstrap":  "false",
+			},
+		},
+		{
+			name:          "disabled",
+			deployOptions: DeployOptions{Namespace: "default", SidecarInjection: SidecarInjectionDisabled},
+			expected: map[string]string{
+				"api":        "false",
+				"web":        "false",
+				"operator":   "false",
+				"postgres":   "false",
+				"minio":      "false",
+				"migrations": "false",
+				"snapshot":   "false",
+				"backup":     "false",
+				"bootstrap":  "false",
+			},
+		},
+		{
+			name:          "service mesh with the namespace default",
+			deployOptions: DeployOptions{Namespace: "default", ServiceMesh: true},
+			expected: map[string]string{
+				"migrations": "false",
+				"snapshot":   "false",
+				"backup":     "false",
+				"bootstrap":  "false",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, validateServiceMeshOptions(test.deployOptions))
+
+			podMeta := map[string]metav1.ObjectMeta{
+				"api":        apiDeployment(test.deployOptions).Spec.Template.ObjectMeta,
+				"web":        webDeployment(test.deployOptions).Spec.Template.ObjectMeta,
+				"operator":   operatorDeployment(test.deployOptions).Spec.Template.ObjectMeta,
+				"postgres":   postgresStatefulset(test.deployOptions).Spec.Template.ObjectMeta,
+				"minio":      minioStatefulset(test.deployOptions).Spec.Template.ObjectMeta,
+				"migrations": migrationsPod(test.deployOptions).ObjectMeta,
+				"snapshot":   snapshotJob(test.deployOptions, "").Spec.Template.ObjectMeta,
+				"backup":     backupCronJob(test.deployOptions).Spec.JobTemplate.Spec.Template.ObjectMeta,
+				"bootstrap":  bootstrapJob(test.deployOptions).Spec.Template.ObjectMeta,
+			}
+
+			for name, meta := range podMeta {
+				inject, ok := meta.Annotations[sidecarInjectAnnotation]
+				expected, expectedOK := test.expected[name]
+				assert.Equal(t, expectedOK, ok, name)
+				assert.Equal(t, expected, inject, name)
+			}
+		})
+	}
+}
+
+func Test_validateServiceMeshOptions(t *testing.T) {
+	assert.NoError(t, validateServiceMeshOptions(DeployOptions{}))
+	assert.Error(t, validateServiceMeshOptions(DeployOptions{SidecarInjection: "true"}))
+}
+
+func Test_getServiceMeshYAML(t *testing.T) {
+	docs, err := getServiceMeshYAML(DeployOptions{Namespace: "kotsadm"})
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+
+	// an external database isn't in the mesh
+	docs, err = getServiceMeshYAML(DeployOptions{Namespace: "kotsadm", ServiceMesh: true, ExternalPostgresURI: "postgres://db.example.com/kotsadm"})
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+
+	docs, err = getServiceMeshYAML(DeployOptions{Namespace: "kotsadm", ServiceMesh: true})
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+
+	peerAuthentication := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal(docs["postgres-peerauthentication.yaml"], &peerAuthentication))
+	assert.Equal(t, "PeerAuthentication", peerAuthentication["kind"])
+	assert.Equal(t, map[string]interface{}{"mode": "PERMISSIVE"}, peerAuthentication["spec"].(map[string]interface{})["mtls"])
+
+	destinationRule := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal(docs["postgres-destinationrule.yaml"], &destinationRule))
+	assert.Equal(t, "DestinationRule", destinationRule["kind"])
+	assert.Equal(t, "kotsadm-postgres.kotsadm.svc.cluster.local", destinationRule["spec"].(map[string]interface{})["host"])
+}
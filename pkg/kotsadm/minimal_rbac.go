@@ -0,0 +1,34 @@
+package kotsadm
+
+import (
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/pkg/errors"
+)
+
+// MinimalRBACLimitations returns what the admin console can't do when it's installed
+// with only namespace scoped roles
+func MinimalRBACLimitations() []string {
+	return []string{
+		"The namespace must already exist, it will not be created",
+		"Applications can only be deployed to the admin console namespace",
+		"Cluster scoped objects in applications, such as CustomResourceDefinitions, ClusterRoles and Namespaces, can't be deployed",
+		"Image pull secrets can't be created in additional namespaces",
+		"Nodes and other cluster scoped resources can't be read, so preflight checks and support bundles that need them will be incomplete",
+	}
+}
+
+// usesMinimalRBAC returns true if the operator in the namespace was deployed with a namespace scoped role
+func usesMinimalRBAC(namespace string, clientset kubernetes.Interface) (bool, error) {
+	_, err := clientset.RbacV1().Roles(namespace).Get("kotsadm-operator-role", metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get operator role")
+	}
+
+	return true, nil
+}
@@ -0,0 +1,63 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_ensureOperatorRole(t *testing.T) {
+	tests := []struct {
+		name                   string
+		minimalRBAC            bool
+		expectClusterRoleCheck bool
+	}{
+		{
+			name:                   "cluster role forbidden",
+			minimalRBAC:            false,
+			expectClusterRoleCheck: true,
+		},
+		{
+			name:                   "minimal rbac",
+			minimalRBAC:            true,
+			expectClusterRoleCheck: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("create", "clusterroles", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, kuberneteserrors.NewForbidden(schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}, "kotsadm-operator-role", nil)
+			})
+
+			scope, err := ensureOperatorRole("kotsadm", test.minimalRBAC, clientset, nil)
+			req.NoError(err)
+			assert.Equal(t, RoleScope(Namespace), scope)
+
+			clusterRoleCheck := false
+			for _, action := range clientset.Actions() {
+				if action.GetVerb() == "create" && action.GetResource().Resource == "clusterroles" {
+					clusterRoleCheck = true
+				}
+			}
+			assert.Equal(t, test.expectClusterRoleCheck, clusterRoleCheck)
+
+			// upgrades keep the namespace scoped role either way
+			minimalRBAC, err := usesMinimalRBAC("kotsadm", clientset)
+			req.NoError(err)
+			assert.True(t, minimalRBAC)
+		})
+	}
+}
+
+func Test_MinimalRBACLimitations(t *testing.T) {
+	assert.NotEmpty(t, MinimalRBACLimitations())
+}
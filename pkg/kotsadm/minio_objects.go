@@ -1,18 +1,15 @@
 package kotsadm
 
 import (
-	"fmt"
-
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/replicatedhq/kots/pkg/util"
 )
 
-func minioStatefulset(namespace string) *appsv1.StatefulSet {
+func minioStatefulset(deployOptions DeployOptions) *appsv1.StatefulSet {
 	statefulset := &appsv1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
@@ -20,7 +17,8 @@ func minioStatefulset(namespace string) *appsv1.StatefulSet {
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-minio",
-			Namespace: namespace,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Spec: appsv1.StatefulSetSpec{
 			Selector: &metav1.LabelSelector{
@@ -34,18 +32,10 @@ func minioStatefulset(namespace string) *appsv1.StatefulSet {
 			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
 				{
 					ObjectMeta: metav1.ObjectMeta{
-						Name: "kotsadm-minio",
-					},
-					Spec: corev1.PersistentVolumeClaimSpec{
-						AccessModes: []corev1.PersistentVolumeAccessMode{
-							corev1.ReadWriteOnce,
-						},
-						Resources: corev1.ResourceRequirements{
-							Requests: corev1.ResourceList{
-								corev1.ResourceName(corev1.ResourceStorage): resource.MustParse("4Gi"),
-							},
-						},
+						Name:   "kotsadm-minio",
+						Labels: kotsadmLabels(),
 					},
+					Spec: volumeClaimSpec(deployOptions.MinioStorageSize, defaultMinioStorageSize, deployOptions.StorageClassName),
 				},
 			},
 			Template: corev1.PodTemplateSpec{
@@ -55,6 +45,9 @@ func minioStatefulset(namespace string) *appsv1.StatefulSet {
 					},
 				},
 				Spec: corev1.PodSpec{
+					NodeSelector: deployOptions.NodeSelector,
+					Tolerations:  deployOptions.Tolerations,
+					Affinity:     deployOptions.Affinity,
 					SecurityContext: &corev1.PodSecurityContext{
 						RunAsUser: util.IntPointer(1001),
 						FSGroup:   util.IntPointer(1001),
@@ -77,9 +70,10 @@ func minioStatefulset(namespace string) *appsv1.StatefulSet {
 					},
 					Containers: []corev1.Container{
 						{
-							Image:           fmt.Sprintf("%s/minio:%s", kotsadmRegistry(), kotsadmTag()),
+							Image:           kotsadmImage("minio"),
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Name:            "kotsadm-minio",
+							Resources:       deployOptions.Resources.Minio,
 							Command: []string{
 								"/bin/sh",
 								"-ce",
@@ -129,7 +123,7 @@ func minioStatefulset(namespace string) *appsv1.StatefulSet {
 									Value: "on",
 								},
 							},
-							LivenessProbe: &corev1.Probe{
+							LivenessProbe: deployOptions.TuningProfile.livenessProbe(&corev1.Probe{
 								InitialDelaySeconds: 5,
 								TimeoutSeconds:      1,
 								FailureThreshold:    3,
@@ -142,8 +136,8 @@ func minioStatefulset(namespace string) *appsv1.StatefulSet {
 										Scheme: corev1.URISchemeHTTP,
 									},
 								},
-							},
-							ReadinessProbe: &corev1.Probe{
+							}),
+							ReadinessProbe: deployOptions.TuningProfile.readinessProbe(&corev1.Probe{
 								InitialDelaySeconds: 5,
 								TimeoutSeconds:      1,
 								FailureThreshold:    3,
@@ -156,12 +150,12 @@ func minioStatefulset(namespace string) *appsv1.StatefulSet {
 										Scheme: corev1.URISchemeHTTP,
 									},
 								},
-							},
+							}),
 						},
 					},
 					InitContainers: []corev1.Container{
 						{
-							Image:           fmt.Sprintf("%s/minio:%s", kotsadmRegistry(), kotsadmTag()),
+							Image:           kotsadmImage("minio"),
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Name:            "kotsadm-minio-init",
 							Command: []string{
@@ -186,6 +180,10 @@ func minioStatefulset(namespace string) *appsv1.StatefulSet {
 		},
 	}
 
+	addProxy(deployOptions, &statefulset.Spec.Template.Spec)
+	addSidecarInjection(deployOptions, &statefulset.Spec.Template.ObjectMeta, &statefulset.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &statefulset.Spec.Template.Spec)
+
 	return statefulset
 }
 
@@ -198,6 +196,7 @@ func minioService(namespace string) *corev1.Service {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-minio",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{
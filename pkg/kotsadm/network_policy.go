@@ -0,0 +1,247 @@
+package kotsadm
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	networkingv1 "k8s.io/api/networking/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	postgresNetworkPolicyName = "kotsadm-postgres"
+	minioNetworkPolicyName    = "kotsadm-minio"
+	apiNetworkPolicyName      = "kotsadm-api"
+
+	// egressEndpointsAnnotation keeps the endpoints of the api network policy, so that they're
+	// resolved again when upgrading
+	egressEndpointsAnnotation = "kots.io/egress-endpoints"
+)
+
+// defaultEgressEndpoints are always reachable by the api, it downloads replicated apps and
+// their updates from there
+var defaultEgressEndpoints = []string{"replicated.app"}
+
+// lookupIP resolves the hostnames of the egress endpoints
+var lookupIP = net.LookupIP
+
+// egressEndpoints are the endpoints that the api connects to outside of the cluster, the
+// EgressEndpoints and the ones that the other options configure
+func egressEndpoints(deployOptions DeployOptions) []string {
+	endpoints := append([]string{}, defaultEgressEndpoints...)
+	endpoints = append(endpoints, deployOptions.EgressEndpoints...)
+
+	for _, endpoint := range []string{
+		deployOptions.HTTPProxy,
+		deployOptions.HTTPSProxy,
+		deployOptions.RegistryCredentials.Endpoint,
+		deployOptions.ExternalPostgresURI,
+		deployOptions.ExternalObjectStore.Endpoint,
+		deployOptions.Identity.IdentityServiceAddress,
+	} {
+		if endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	return endpoints
+}
+
+// endpointHost returns the host of an endpoint that is a url, a host and port or a host
+func endpointHost(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err == nil {
+			return u.Hostname()
+		}
+	}
+	// registry endpoints can include a namespace
+	endpoint = strings.SplitN(endpoint, "/", 2)[0]
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+	return endpoint
+}
+
+// resolveEgressCIDRs returns the CIDRs of the endpoints, hostnames are resolved to all of their
+// addresses
+func resolveEgressCIDRs(endpoints []string) ([]string, error) {
+	cidrs := map[string]bool{}
+	for _, endpoint := range endpoints {
+		if _, ipNet, err := net.ParseCIDR(endpoint); err == nil {
+			cidrs[ipNet.String()] = true
+			continue
+		}
+
+		host := endpointHost(endpoint)
+		ips := []net.IP{net.ParseIP(host)}
+		if ips[0] == nil {
+			resolved, err := lookupIP(host)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to resolve %s", host)
+			}
+			ips = resolved
+		}
+
+		for _, ip := range ips {
+			cidrs[ipCIDR(ip)] = true
+		}
+	}
+
+	sorted := []string{}
+	for cidr := range cidrs {
+		sorted = append(sorted, cidr)
+	}
+	sort.Strings(sorted)
+
+	return sorted, nil
+}
+
+func ipCIDR(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+func validateNetworkPolicyOptions(deployOptions DeployOptions) error {
+	if !deployOptions.NetworkPolicies && len(deployOptions.EgressEndpoints) > 0 {
+		return errors.New("egress endpoints can only be set with network policies")
+	}
+
+	for _, endpoint := range deployOptions.EgressEndpoints {
+		if endpointHost(endpoint) == "" {
+			return errors.Errorf("invalid egress endpoint %q, expected a hostname, an ip address or a cidr", endpoint)
+		}
+	}
+
+	return nil
+}
+
+func getNetworkPolicyYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	if !usesExternalPostgres(deployOptions) {
+		var postgres bytes.Buffer
+		if err := s.Encode(postgresNetworkPolicy(deployOptions.Namespace), &postgres); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal postgres network policy")
+		}
+		docs["postgres-networkpolicy.yaml"] = postgres.Bytes()
+	}
+
+	if !usesExternalObjectStore(deployOptions) {
+		var minio bytes.Buffer
+		if err := s.Encode(minioNetworkPolicy(deployOptions.Namespace), &minio); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal minio network policy")
+		}
+		docs["minio-networkpolicy.yaml"] = minio.Bytes()
+	}
+
+	// without a cluster, the address of the kubernetes api has to be one of the egress endpoints
+	cidrs, err := resolveEgressCIDRs(egressEndpoints(deployOptions))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve egress endpoints")
+	}
+	var api bytes.Buffer
+	if err := s.Encode(apiNetworkPolicy(deployOptions, cidrs), &api); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal api network policy")
+	}
+	docs["api-networkpolicy.yaml"] = api.Bytes()
+
+	return docs, nil
+}
+
+func ensureNetworkPolicies(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	endpoints := egressEndpoints(deployOptions)
+	kubernetesEndpoints, err := kubernetesAPIAddresses(clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to get kubernetes api addresses")
+	}
+	cidrs, err := resolveEgressCIDRs(append(endpoints, kubernetesEndpoints...))
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve egress endpoints")
+	}
+
+	policies := []*networkingv1.NetworkPolicy{
+		apiNetworkPolicy(deployOptions, cidrs),
+	}
+	if !usesExternalObjectStore(deployOptions) {
+		policies = append(policies, minioNetworkPolicy(deployOptions.Namespace))
+	}
+	if !usesExternalPostgres(deployOptions) {
+		policies = append(policies, postgresNetworkPolicy(deployOptions.Namespace))
+	}
+
+	for _, policy := range policies {
+		if err := applyPatches(deployOptions, policy); err != nil {
+			return errors.Wrapf(err, "failed to patch %s network policy", policy.Name)
+		}
+
+		existing, err := clientset.NetworkingV1().NetworkPolicies(deployOptions.Namespace).Get(policy.Name, metav1.GetOptions{})
+		if err != nil {
+			if !kuberneteserrors.IsNotFound(err) {
+				return errors.Wrapf(err, "failed to get existing %s network policy", policy.Name)
+			}
+
+			if _, err := clientset.NetworkingV1().NetworkPolicies(deployOptions.Namespace).Create(policy); err != nil {
+				return errors.Wrapf(err, "failed to create %s network policy", policy.Name)
+			}
+			continue
+		}
+
+		// the addresses of the endpoints can change, they're resolved again on every install
+		existing.Annotations = policy.Annotations
+		existing.Spec = policy.Spec
+		if _, err := clientset.NetworkingV1().NetworkPolicies(deployOptions.Namespace).Update(existing); err != nil {
+			return errors.Wrapf(err, "failed to update %s network policy", policy.Name)
+		}
+	}
+
+	return nil
+}
+
+// kubernetesAPIAddresses returns the addresses that the kubernetes service forwards to. Network
+// policies apply after the service address is translated, so the service address isn't enough.
+func kubernetesAPIAddresses(clientset *kubernetes.Clientset) ([]string, error) {
+	endpoints, err := clientset.CoreV1().Endpoints(metav1.NamespaceDefault).Get("kubernetes", metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get kubernetes endpoints")
+	}
+
+	addresses := []string{}
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			addresses = append(addresses, address.IP)
+		}
+	}
+
+	return addresses, nil
+}
+
+// readNetworkPolicyOptions returns whether the network policies were created, and the egress
+// endpoints that the api policy was created with
+func readNetworkPolicyOptions(namespace string, clientset *kubernetes.Clientset) (bool, []string, error) {
+	policy, err := clientset.NetworkingV1().NetworkPolicies(namespace).Get(apiNetworkPolicyName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, errors.Wrap(err, "failed to get api network policy")
+	}
+
+	var endpoints []string
+	if value := policy.Annotations[egressEndpointsAnnotation]; value != "" {
+		endpoints = strings.Split(value, ",")
+	}
+
+	return true, endpoints, nil
+}
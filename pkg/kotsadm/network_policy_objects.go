@@ -0,0 +1,160 @@
+package kotsadm
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var (
+	// postgresClients are the apps of the pods that connect to postgres, the api and the pods
+	// that migrate, back up and restore the database
+	postgresClients = []string{
+		"kotsadm-api",
+		"kotsadm-migrations",
+		"kotsadm-snapshot",
+		"kotsadm-restore",
+		"kotsadm-postgres-backup",
+		"kotsadm-postgres-restore",
+	}
+
+	// minioClients are the apps of the pods that connect to minio
+	minioClients = []string{
+		"kotsadm-api",
+		"kotsadm-snapshot",
+		"kotsadm-restore",
+	}
+)
+
+// clientsNetworkPolicy only lets the pods of the client apps connect to the port of the app
+func clientsNetworkPolicy(namespace string, name string, app string, port int, clients []string) *networkingv1.NetworkPolicy {
+	tcp := corev1.ProtocolTCP
+	policyPort := intstr.FromInt(port)
+
+	policy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": app,
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: []networkingv1.NetworkPolicyPort{
+						{
+							Protocol: &tcp,
+							Port:     &policyPort,
+						},
+					},
+					From: []networkingv1.NetworkPolicyPeer{
+						{
+							PodSelector: &metav1.LabelSelector{
+								MatchExpressions: []metav1.LabelSelectorRequirement{
+									{
+										Key:      "app",
+										Operator: metav1.LabelSelectorOpIn,
+										Values:   clients,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return policy
+}
+
+func postgresNetworkPolicy(namespace string) *networkingv1.NetworkPolicy {
+	return clientsNetworkPolicy(namespace, postgresNetworkPolicyName, "kotsadm-postgres", 5432, postgresClients)
+}
+
+func minioNetworkPolicy(namespace string) *networkingv1.NetworkPolicy {
+	return clientsNetworkPolicy(namespace, minioNetworkPolicyName, "kotsadm-minio", 9000, minioClients)
+}
+
+// apiNetworkPolicy lets the api resolve names, connect to the pods in the namespace and to the
+// CIDRs of the egress endpoints. Connections to the api aren't restricted.
+func apiNetworkPolicy(deployOptions DeployOptions, cidrs []string) *networkingv1.NetworkPolicy {
+	tcp := corev1.ProtocolTCP
+	udp := corev1.ProtocolUDP
+	dnsPort := intstr.FromInt(53)
+
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{
+					Protocol: &udp,
+					Port:     &dnsPort,
+				},
+				{
+					Protocol: &tcp,
+					Port:     &dnsPort,
+				},
+			},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{
+				{
+					PodSelector: &metav1.LabelSelector{},
+				},
+			},
+		},
+	}
+
+	if len(cidrs) > 0 {
+		rule := networkingv1.NetworkPolicyEgressRule{}
+		for _, cidr := range cidrs {
+			rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{
+				IPBlock: &networkingv1.IPBlock{
+					CIDR: cidr,
+				},
+			})
+		}
+		egress = append(egress, rule)
+	}
+
+	policy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      apiNetworkPolicyName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "kotsadm-api",
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      egress,
+		},
+	}
+
+	if len(deployOptions.EgressEndpoints) > 0 {
+		policy.Annotations = map[string]string{
+			egressEndpointsAnnotation: strings.Join(deployOptions.EgressEndpoints, ","),
+		}
+	}
+
+	return policy
+}
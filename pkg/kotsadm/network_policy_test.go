@@ -0,0 +1,67 @@
+package kotsadm
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveEgressCIDRs(t *testing.T) {
+	defer func(original func(string) ([]net.IP, error)) { lookupIP = original }(lookupIP)
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "replicated.app":
+			return []net.IP{net.ParseIP("162.159.133.41"), net.ParseIP("2606:4700:7::a29f:8529")}, nil
+		case "registry.example.com":
+			return []net.IP{net.ParseIP("10.0.0.5")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	deployOptions := DeployOptions{
+		NetworkPolicies: true,
+		EgressEndpoints: []string{"192.168.0.0/16", "172.16.0.10"},
+		HTTPSProxy:      "http://10.0.0.1:3128",
+	}
+	deployOptions.RegistryCredentials.Endpoint = "registry.example.com:5000/apps"
+
+	cidrs, err := resolveEgressCIDRs(egressEndpoints(deployOptions))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"10.0.0.1/32",
+		"10.0.0.5/32",
+		"162.159.133.41/32",
+		"172.16.0.10/32",
+		"192.168.0.0/16",
+		"2606:4700:7::a29f:8529/128",
+	}, cidrs)
+
+	_, err = resolveEgressCIDRs([]string{"unknown.example.com"})
+	assert.Error(t, err)
+}
+
+func Test_validateNetworkPolicyOptions(t *testing.T) {
+	assert.NoError(t, validateNetworkPolicyOptions(DeployOptions{}))
+	assert.NoError(t, validateNetworkPolicyOptions(DeployOptions{NetworkPolicies: true, EgressEndpoints: []string{"10.0.0.0/8"}}))
+	assert.Error(t, validateNetworkPolicyOptions(DeployOptions{EgressEndpoints: []string{"10.0.0.0/8"}}))
+	assert.Error(t, validateNetworkPolicyOptions(DeployOptions{NetworkPolicies: true, EgressEndpoints: []string{"https://"}}))
+}
+
+func Test_networkPolicies(t *testing.T) {
+	postgres := postgresNetworkPolicy("default")
+	assert.Equal(t, "kotsadm-postgres", postgres.Spec.PodSelector.MatchLabels["app"])
+	assert.Equal(t, 5432, postgres.Spec.Ingress[0].Ports[0].Port.IntValue())
+	assert.Contains(t, postgres.Spec.Ingress[0].From[0].PodSelector.MatchExpressions[0].Values, "kotsadm-migrations")
+	assert.Equal(t, "kotsadm-migrations", migrationsPod(DeployOptions{}).Labels["app"])
+
+	minio := minioNetworkPolicy("default")
+	assert.NotContains(t, minio.Spec.Ingress[0].From[0].PodSelector.MatchExpressions[0].Values, "kotsadm-migrations")
+
+	api := apiNetworkPolicy(DeployOptions{Namespace: "default", EgressEndpoints: []string{"10.0.0.0/8", "example.com"}}, []string{"10.0.0.0/8"})
+	require.Len(t, api.Spec.Egress, 3)
+	assert.Equal(t, "10.0.0.0/8", api.Spec.Egress[2].To[0].IPBlock.CIDR)
+	assert.Equal(t, "10.0.0.0/8,example.com", api.Annotations[egressEndpointsAnnotation])
+}
@@ -0,0 +1,73 @@
+package kotsadm
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	// openshiftSecurityAPIGroup is only served by openshift clusters, it's the api group of the
+	// security context constraints
+	openshiftSecurityAPIGroup = "security.openshift.io"
+
+	openshiftSCCRoleBindingName = "kotsadm-scc"
+
+	// openshiftRestrictedSCCClusterRole lets subjects use the restricted SCC, it's created by
+	// openshift 4
+	openshiftRestrictedSCCClusterRole = "system:openshift:scc:restricted"
+)
+
+// isOpenShift returns whether the cluster serves the openshift security api
+func isOpenShift(clientset kubernetes.Interface) (bool, error) {
+	groups, err := clientset.Discovery().ServerGroups()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get server groups")
+	}
+
+	for _, group := range groups.Groups {
+		if group.Name == openshiftSecurityAPIGroup {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func getOpenShiftYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var roleBinding bytes.Buffer
+	if err := s.Encode(openshiftSCCRoleBinding(deployOptions.Namespace), &roleBinding); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal scc rolebinding")
+	}
+	docs["scc-rolebinding.yaml"] = roleBinding.Bytes()
+
+	return docs, nil
+}
+
+func ensureOpenShiftSCCRoleBinding(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	_, err := clientset.RbacV1().RoleBindings(deployOptions.Namespace).Get(openshiftSCCRoleBindingName, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get existing scc rolebinding")
+	}
+
+	roleBinding := openshiftSCCRoleBinding(deployOptions.Namespace)
+	if err := applyPatches(deployOptions, roleBinding); err != nil {
+		return errors.Wrap(err, "failed to patch scc rolebinding")
+	}
+	if _, err := clientset.RbacV1().RoleBindings(deployOptions.Namespace).Create(roleBinding); err != nil {
+		return errors.Wrap(err, "failed to create scc rolebinding")
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package kotsadm
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// postgresOpenShiftInitScriptTmpl only fixes the mode of the data directories. The restricted SCC
+// runs the pods with a user id from the range of the namespace and the volumes are writable by
+// the fsGroup of the namespace, so the owner can't and doesn't have to be changed.
+const postgresOpenShiftInitScriptTmpl = `find %[1]s -mindepth 1 -maxdepth 1 -type d -name 'pgdata*' -user "$(id -u)" -exec chmod 700 {} +`
+
+// openshiftSCCRoleBinding lets all service accounts of the namespace use the restricted SCC
+func openshiftSCCRoleBinding(namespace string) *rbacv1.RoleBinding {
+	roleBinding := &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "RoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      openshiftSCCRoleBindingName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "Group",
+				Name:     fmt.Sprintf("system:serviceaccounts:%s", namespace),
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     openshiftRestrictedSCCClusterRole,
+		},
+	}
+
+	return roleBinding
+}
+
+// addOpenShiftCompatibility removes the fixed user and group ids of the pod, the restricted SCC
+// assigns them from the ranges of the namespace. The postgres init container runs as that user
+// without added capabilities.
+func addOpenShiftCompatibility(deployOptions DeployOptions, podSpec *corev1.PodSpec) {
+	if !deployOptions.OpenShift {
+		return
+	}
+
+	if podSpec.SecurityContext != nil {
+		podSpec.SecurityContext.RunAsUser = nil
+		podSpec.SecurityContext.RunAsGroup = nil
+		podSpec.SecurityContext.FSGroup = nil
+	}
+
+	for i := range podSpec.InitContainers {
+		container := &podSpec.InitContainers[i]
+		if container.Name == postgresInitName {
+			container.Command = []string{"/bin/sh", "-c", "set -e\n" + fmt.Sprintf(postgresOpenShiftInitScriptTmpl, postgresDataMountPath)}
+			container.SecurityContext = &corev1.SecurityContext{
+				ReadOnlyRootFilesystem:   &postgresReadOnlyRootFilesystem,
+				AllowPrivilegeEscalation: &postgresAllowPrivilegeEscalation,
+			}
+			continue
+		}
+		removeContainerIDs(container)
+	}
+	for i := range podSpec.Containers {
+		removeContainerIDs(&podSpec.Containers[i])
+	}
+}
+
+func removeContainerIDs(container *corev1.Container) {
+	if container.SecurityContext == nil {
+		return
+	}
+	container.SecurityContext.RunAsUser = nil
+	container.SecurityContext.RunAsGroup = nil
+}
@@ -0,0 +1,44 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_isOpenShift(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1"},
+	}
+	openShift, err := isOpenShift(clientset)
+	require.NoError(t, err)
+	assert.False(t, openShift)
+
+	clientset.Resources = append(clientset.Resources, &metav1.APIResourceList{GroupVersion: "security.openshift.io/v1"})
+	openShift, err = isOpenShift(clientset)
+	require.NoError(t, err)
+	assert.True(t, openShift)
+}
+
+func Test_addOpenShiftCompatibility(t *testing.T) {
+	statefulset := postgresStatefulset(DeployOptions{Namespace: "default"})
+	assert.NotNil(t, statefulset.Spec.Template.Spec.SecurityContext.RunAsUser)
+
+	statefulset = postgresStatefulset(DeployOptions{Namespace: "default", OpenShift: true})
+	podSpec := statefulset.Spec.Template.Spec
+	assert.Nil(t, podSpec.SecurityContext.RunAsUser)
+	assert.Nil(t, podSpec.SecurityContext.FSGroup)
+
+	require.Len(t, podSpec.InitContainers, 1)
+	init := podSpec.InitContainers[0]
+	assert.Nil(t, init.SecurityContext.RunAsUser)
+	assert.Nil(t, init.SecurityContext.Capabilities)
+	assert.NotContains(t, init.Command[2], "chown")
+
+	roleBinding := openshiftSCCRoleBinding("default")
+	assert.Equal(t, "system:serviceaccounts:default", roleBinding.Subjects[0].Name)
+}
@@ -21,30 +21,30 @@ const (
 	Namespace = "namespace"
 )
 
-func getOperatorYAML(namespace, autoCreateClusterToken string) (map[string][]byte, error) {
+func getOperatorYAML(deployOptions DeployOptions) (map[string][]byte, error) {
 	docs := map[string][]byte{}
 	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
 
 	var role bytes.Buffer
-	if err := s.Encode(operatorRole(namespace), &role); err != nil {
+	if err := s.Encode(operatorRole(deployOptions.Namespace), &role); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal operator role")
 	}
 	docs["operator-role.yaml"] = role.Bytes()
 
 	var roleBinding bytes.Buffer
-	if err := s.Encode(operatorRoleBinding(namespace), &roleBinding); err != nil {
+	if err := s.Encode(operatorRoleBinding(deployOptions.Namespace), &roleBinding); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal operator role binding")
 	}
 	docs["operator-rolebinding.yaml"] = roleBinding.Bytes()
 
 	var serviceAccount bytes.Buffer
-	if err := s.Encode(operatorServiceAccount(namespace), &serviceAccount); err != nil {
+	if err := s.Encode(operatorServiceAccount(deployOptions.Namespace), &serviceAccount); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal operator service account")
 	}
 	docs["operator-serviceaccount.yaml"] = serviceAccount.Bytes()
 
 	var deployment bytes.Buffer
-	if err := s.Encode(operatorDeployment(namespace, autoCreateClusterToken), &deployment); err != nil {
+	if err := s.Encode(operatorDeployment(deployOptions), &deployment); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal operator deployment")
 	}
 	docs["operator-deployment.yaml"] = deployment.Bytes()
@@ -56,7 +56,7 @@ func ensureOperator(deployOptions DeployOptions, clientset *kubernetes.Clientset
 	// TODO: log this error on debug level
 	rules, _ := k8sutil.GetCurrentRules(deployOptions.Kubeconfig, deployOptions.Context, clientset)
 
-	if err := ensureOperatorRBAC(deployOptions.Namespace, clientset, rules); err != nil {
+	if err := ensureOperatorRBAC(deployOptions, clientset, rules); err != nil {
 		return errors.Wrap(err, "failed to ensure operator rbac")
 	}
 
@@ -67,8 +67,10 @@ func ensureOperator(deployOptions DeployOptions, clientset *kubernetes.Clientset
 	return nil
 }
 
-func ensureOperatorRBAC(namespace string, clientset *kubernetes.Clientset, rules []rbacv1.PolicyRule) error {
-	scope, err := ensureOperatorRole(namespace, clientset, rules)
+func ensureOperatorRBAC(deployOptions DeployOptions, clientset *kubernetes.Clientset, rules []rbacv1.PolicyRule) error {
+	namespace := deployOptions.Namespace
+
+	scope, err := ensureOperatorRole(namespace, deployOptions.MinimalRBAC, clientset, rules)
 	if err != nil {
 		return errors.Wrap(err, "failed to ensure operator role")
 	}
@@ -77,27 +79,29 @@ func ensureOperatorRBAC(namespace string, clientset *kubernetes.Clientset, rules
 		return errors.Wrap(err, "failed to ensure operator role binding")
 	}
 
-	if err := ensureOperatorServiceAccount(namespace, clientset); err != nil {
+	if err := ensureOperatorServiceAccount(deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure operator service account")
 	}
 
 	return nil
 }
 
-func ensureOperatorRole(namespace string, clientset *kubernetes.Clientset, rules []rbacv1.PolicyRule) (RoleScope, error) {
+func ensureOperatorRole(namespace string, minimalRBAC bool, clientset kubernetes.Interface, rules []rbacv1.PolicyRule) (RoleScope, error) {
 	// we'd like to create a cluster scope role, but will settle for namespace scope...
 
-	_, err := clientset.RbacV1().ClusterRoles().Create(operatorClusterRole(namespace))
-	if err == nil || kuberneteserrors.IsAlreadyExists(err) {
-		return Cluster, nil
-	}
-	if !kuberneteserrors.IsForbidden(err) {
-		return None, errors.Wrap(err, "failed to create cluster role")
+	if !minimalRBAC {
+		_, err := clientset.RbacV1().ClusterRoles().Create(operatorClusterRole(namespace))
+		if err == nil || kuberneteserrors.IsAlreadyExists(err) {
+			return Cluster, nil
+		}
+		if !kuberneteserrors.IsForbidden(err) {
+			return None, errors.Wrap(err, "failed to create cluster role")
+		}
 	}
 
 	role := operatorRole(namespace)
 
-	_, err = clientset.RbacV1().Roles(namespace).Create(role)
+	_, err := clientset.RbacV1().Roles(namespace).Create(role)
 	if err == nil || kuberneteserrors.IsAlreadyExists(err) {
 		return Namespace, nil
 	}
@@ -160,14 +164,20 @@ func ensureOperatorRoleBinding(scope RoleScope, namespace string, clientset *kub
 	return errors.Errorf("failed to create rolebinding for scope %q", scope)
 }
 
-func ensureOperatorServiceAccount(namespace string, clientset *kubernetes.Clientset) error {
+func ensureOperatorServiceAccount(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	namespace := deployOptions.Namespace
+
 	_, err := clientset.CoreV1().ServiceAccounts(namespace).Get("kotsadm-operator", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get serviceaccount")
 		}
 
-		_, err := clientset.CoreV1().ServiceAccounts(namespace).Create(operatorServiceAccount(namespace))
+		serviceAccount := operatorServiceAccount(namespace)
+		if err := applyPatches(deployOptions, serviceAccount); err != nil {
+			return errors.Wrap(err, "failed to patch operator service account")
+		}
+		_, err := clientset.CoreV1().ServiceAccounts(namespace).Create(serviceAccount)
 		if err != nil {
 			return errors.Wrap(err, "failed to create serviceaccount")
 		}
@@ -177,17 +187,30 @@ func ensureOperatorServiceAccount(namespace string, clientset *kubernetes.Client
 }
 
 func ensureOperatorDeployment(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
-	_, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Get("kotsadm-operator", metav1.GetOptions{})
+	existing, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Get("kotsadm-operator", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing deployment")
 		}
 
-		_, err = clientset.AppsV1().Deployments(deployOptions.Namespace).Create(operatorDeployment(deployOptions.Namespace, deployOptions.AutoCreateClusterToken))
+		deployment := operatorDeployment(deployOptions)
+		if err := applyPatches(deployOptions, deployment); err != nil {
+			return errors.Wrap(err, "failed to patch operator deployment")
+		}
+		_, err = clientset.AppsV1().Deployments(deployOptions.Namespace).Create(deployment)
 		if err != nil {
 			return errors.Wrap(err, "failed to create deployment")
 		}
 
+		return nil
+	}
+
+	deployment := operatorDeployment(deployOptions)
+	if err := applyPatches(deployOptions, deployment); err != nil {
+		return errors.Wrap(err, "failed to patch operator deployment")
+	}
+	if err := updateDeployment(deployOptions, clientset, existing, deployment); err != nil {
+		return errors.Wrap(err, "failed to update deployment")
 	}
 
 	return nil
@@ -1,8 +1,6 @@
 package kotsadm
 
 import (
-	"fmt"
-
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -20,6 +18,7 @@ func operatorClusterRole(namespace string) *rbacv1.ClusterRole {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-operator-role",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Rules: []rbacv1.PolicyRule{
 			{
@@ -42,6 +41,7 @@ func operatorClusterRoleBinding(namespace string) *rbacv1.ClusterRoleBinding {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-operator-rolebinding",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Subjects: []rbacv1.Subject{
 			{
@@ -69,6 +69,7 @@ func operatorRole(namespace string) *rbacv1.Role {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-operator-role",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Rules: []rbacv1.PolicyRule{
 			{
@@ -91,6 +92,7 @@ func operatorRoleBinding(namespace string) *rbacv1.RoleBinding {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-operator-rolebinding",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Subjects: []rbacv1.Subject{
 			{
@@ -118,13 +120,14 @@ func operatorServiceAccount(namespace string) *corev1.ServiceAccount {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-operator",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 	}
 
 	return serviceAccount
 }
 
-func operatorDeployment(namespace, autoCreateClusterToken string) *appsv1.Deployment {
+func operatorDeployment(deployOptions DeployOptions) *appsv1.Deployment {
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
@@ -132,7 +135,8 @@ func operatorDeployment(namespace, autoCreateClusterToken string) *appsv1.Deploy
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-operator",
-			Namespace: namespace,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Selector: &metav1.LabelSelector{
@@ -147,6 +151,9 @@ func operatorDeployment(namespace, autoCreateClusterToken string) *appsv1.Deploy
 					},
 				},
 				Spec: corev1.PodSpec{
+					NodeSelector: deployOptions.NodeSelector,
+					Tolerations:  deployOptions.Tolerations,
+					Affinity:     deployOptions.Affinity,
 					SecurityContext: &corev1.PodSecurityContext{
 						RunAsUser: util.IntPointer(1001),
 					},
@@ -154,17 +161,18 @@ func operatorDeployment(namespace, autoCreateClusterToken string) *appsv1.Deploy
 					RestartPolicy:      corev1.RestartPolicyAlways,
 					Containers: []corev1.Container{
 						{
-							Image:           fmt.Sprintf("%s/kotsadm-operator:%s", kotsadmRegistry(), kotsadmTag()),
+							Image:           kotsadmImage("kotsadm-operator"),
 							ImagePullPolicy: corev1.PullAlways,
 							Name:            "kotsadm-operator",
+							Resources:       deployOptions.Resources.Operator,
 							Env: []corev1.EnvVar{
 								{
 									Name:  "KOTSADM_API_ENDPOINT",
-									Value: fmt.Sprintf("http://kotsadm-api.%s.svc.cluster.local:3000", namespace),
+									Value: apiEndpoint(deployOptions),
 								},
 								{
 									Name:  "KOTSADM_TOKEN",
-									Value: autoCreateClusterToken,
+									Value: deployOptions.AutoCreateClusterToken,
 								},
 								{
 									Name: "KOTSADM_TARGET_NAMESPACE",
@@ -182,5 +190,20 @@ func operatorDeployment(namespace, autoCreateClusterToken string) *appsv1.Deploy
 		},
 	}
 
+	if deployOptions.EnableTLS {
+		// the operator only needs to trust the api certificate
+		podSpec := &deployment.Spec.Template.Spec
+		podSpec.Volumes = append(podSpec.Volumes, tlsVolume(tlsCASecretName, nil))
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, tlsVolumeMount())
+		podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, corev1.EnvVar{
+			Name:  "SSL_CERT_FILE",
+			Value: tlsFile("ca.crt"),
+		})
+	}
+
+	addProxy(deployOptions, &deployment.Spec.Template.Spec)
+	addSidecarInjection(deployOptions, &deployment.Spec.Template.ObjectMeta, &deployment.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &deployment.Spec.Template.Spec)
+
 	return deployment
 }
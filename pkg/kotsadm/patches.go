@@ -0,0 +1,221 @@
+package kotsadm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	kjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// ObjectPatch is a patch of one of the admin console objects, in the same forms that kustomize accepts
+type ObjectPatch struct {
+	Kind string
+	Name string
+
+	// StrategicMerge is a partial object that is merged into the object, and JSON6902 is a list of
+	// json patch operations. Only one of them is set, either can be json or yaml.
+	StrategicMerge []byte
+	JSON6902       []byte
+}
+
+// patchableKinds are the kinds of objects that can be patched. Secrets and rbac objects are left
+// out, so that credentials and permissions are always the ones the admin console expects.
+var patchableKinds = []string{"ConfigMap", "CronJob", "Deployment", "Job", "Pod", "Service", "ServiceAccount", "StatefulSet"}
+
+// LoadStrategicMergePatches reads strategic merge patches from yaml files. Each document in a
+// file is a patch of the object with the same kind and metadata.name.
+func LoadStrategicMergePatches(filenames []string) ([]ObjectPatch, error) {
+	patches := []ObjectPatch{}
+	for _, filename := range filenames {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", filename)
+		}
+
+		for _, doc := range bytes.Split(content, []byte("\n---\n")) {
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+
+			target := struct {
+				Kind     string `json:"kind"`
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			}{}
+			if err := yaml.Unmarshal(doc, &target); err != nil {
+				return nil, errors.Wrapf(err, "failed to parse patch in %s", filename)
+			}
+			if target.Kind == "" || target.Metadata.Name == "" {
+				return nil, errors.Errorf("patch in %s must have a kind and metadata.name", filename)
+			}
+
+			patches = append(patches, ObjectPatch{
+				Kind:           target.Kind,
+				Name:           target.Metadata.Name,
+				StrategicMerge: doc,
+			})
+		}
+	}
+
+	return patches, nil
+}
+
+// LoadJSON6902Patches reads json patches from values in the form kind/name=filename
+func LoadJSON6902Patches(values []string) ([]ObjectPatch, error) {
+	patches := []ObjectPatch{}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		target := strings.SplitN(kv[0], "/", 2)
+		if len(kv) != 2 || len(target) != 2 || target[0] == "" || target[1] == "" || kv[1] == "" {
+			return nil, errors.Errorf("invalid patch %q, expected kind/name=filename", value)
+		}
+
+		content, err := ioutil.ReadFile(kv[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", kv[1])
+		}
+
+		patches = append(patches, ObjectPatch{
+			Kind:     target[0],
+			Name:     target[1],
+			JSON6902: content,
+		})
+	}
+
+	return patches, nil
+}
+
+func validatePatches(patches []ObjectPatch) error {
+	for _, patch := range patches {
+		if !isPatchableKind(patch.Kind) {
+			return errors.Errorf("%s %s can't be patched, only %s objects can", patch.Kind, patch.Name, strings.Join(patchableKinds, ", "))
+		}
+
+		if len(patch.JSON6902) > 0 {
+			if _, err := decodeJSON6902Patch(patch.JSON6902); err != nil {
+				return errors.Wrapf(err, "invalid patch of %s %s", patch.Kind, patch.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isPatchableKind(kind string) bool {
+	i := sort.SearchStrings(patchableKinds, kind)
+	return i < len(patchableKinds) && patchableKinds[i] == kind
+}
+
+// applyPatches applies the patches of the object, in the order they were given
+func applyPatches(deployOptions DeployOptions, obj runtime.Object) error {
+	if len(deployOptions.Patches) == 0 {
+		return nil
+	}
+
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed to get object metadata")
+	}
+
+	for _, patch := range deployOptions.Patches {
+		if patch.Kind != kind || patch.Name != accessor.GetName() {
+			continue
+		}
+
+		if err := applyPatch(patch, obj); err != nil {
+			return errors.Wrapf(err, "failed to patch %s %s", kind, accessor.GetName())
+		}
+	}
+
+	return nil
+}
+
+func applyPatch(patch ObjectPatch, obj runtime.Object) error {
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal object")
+	}
+
+	var patched []byte
+	if len(patch.JSON6902) > 0 {
+		decoded, err := decodeJSON6902Patch(patch.JSON6902)
+		if err != nil {
+			return errors.Wrap(err, "failed to decode patch")
+		}
+		patched, err = decoded.Apply(original)
+		if err != nil {
+			return errors.Wrap(err, "failed to apply json patch")
+		}
+	} else {
+		patchJSON, err := yaml.YAMLToJSON(patch.StrategicMerge)
+		if err != nil {
+			return errors.Wrap(err, "failed to convert patch to json")
+		}
+		patched, err = strategicpatch.StrategicMergePatch(original, patchJSON, obj)
+		if err != nil {
+			return errors.Wrap(err, "failed to apply strategic merge patch")
+		}
+	}
+
+	// unmarshal into an empty object so that removed fields don't remain
+	value := reflect.New(reflect.TypeOf(obj).Elem())
+	if err := json.Unmarshal(patched, value.Interface()); err != nil {
+		return errors.Wrap(err, "failed to unmarshal patched object")
+	}
+	reflect.ValueOf(obj).Elem().Set(value.Elem())
+
+	return nil
+}
+
+func decodeJSON6902Patch(content []byte) (jsonpatch.Patch, error) {
+	patchJSON, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert patch to json")
+	}
+
+	return jsonpatch.DecodePatch(patchJSON)
+}
+
+// patchDocs applies the patches to the generated yaml documents
+func patchDocs(deployOptions DeployOptions, docs map[string][]byte) error {
+	if len(deployOptions.Patches) == 0 {
+		return nil
+	}
+
+	s := kjson.NewYAMLSerializer(kjson.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+	for name, doc := range docs {
+		obj, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decode %s", name)
+		}
+		if !isPatchableKind(gvk.Kind) {
+			continue
+		}
+		obj.GetObjectKind().SetGroupVersionKind(*gvk)
+
+		if err := applyPatches(deployOptions, obj); err != nil {
+			return errors.Wrapf(err, "failed to patch %s", name)
+		}
+
+		var patched bytes.Buffer
+		if err := s.Encode(obj, &patched); err != nil {
+			return errors.Wrapf(err, "failed to marshal %s", name)
+		}
+		docs[name] = patched.Bytes()
+	}
+
+	return nil
+}
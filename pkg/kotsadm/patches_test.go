@@ -0,0 +1,138 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_applyPatches(t *testing.T) {
+	deployOptions := DeployOptions{
+		Namespace: "default",
+		Patches: []ObjectPatch{
+			{
+				Kind: "Deployment",
+				Name: "kotsadm-api",
+				StrategicMerge: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: kotsadm-api
+spec:
+  template:
+    metadata:
+      annotations:
+        sidecar.istio.io/inject: "false"
+    spec:
+      priorityClassName: high-priority
+      containers:
+        - name: kotsadm-api
+          env:
+            - name: DEBUG
+              value: "true"
+`),
+			},
+			{
+				Kind: "Deployment",
+				Name: "kotsadm-api",
+				JSON6902: []byte(`- op: add
+  path: /metadata/labels
+  value:
+    team: platform
+`),
+			},
+			{
+				Kind: "Deployment",
+				Name: "kotsadm-web",
+				JSON6902: []byte(`- op: add
+  path: /metadata/labels
+  value:
+    team: web
+`),
+			},
+		},
+	}
+
+	deployment := apiDeployment(deployOptions)
+	envCount := len(deployment.Spec.Template.Spec.Containers[0].Env)
+
+	err := applyPatches(deployOptions, deployment)
+	require.NoError(t, err)
+
+	podSpec := deployment.Spec.Template.Spec
+	assert.Equal(t, "high-priority", podSpec.PriorityClassName)
+	assert.Equal(t, "false", deployment.Spec.Template.Annotations["sidecar.istio.io/inject"])
+	assert.Equal(t, map[string]string{"team": "platform"}, deployment.Labels)
+
+	// containers and env are merged by name, not replaced
+	require.Len(t, podSpec.Containers, 1)
+	assert.NotEmpty(t, podSpec.Containers[0].Image)
+	assert.Len(t, podSpec.Containers[0].Env, envCount+1)
+}
+
+func Test_applyPatchesRemovesFields(t *testing.T) {
+	deployOptions := DeployOptions{
+		Namespace: "default",
+		Patches: []ObjectPatch{
+			{
+				Kind:     "Deployment",
+				Name:     "kotsadm-api",
+				JSON6902: []byte(`[{"op": "remove", "path": "/spec/template/spec/containers/0/readinessProbe"}]`),
+			},
+		},
+	}
+
+	deployment := apiDeployment(deployOptions)
+	require.NotNil(t, deployment.Spec.Template.Spec.Containers[0].ReadinessProbe)
+
+	err := applyPatches(deployOptions, deployment)
+	require.NoError(t, err)
+	assert.Nil(t, deployment.Spec.Template.Spec.Containers[0].ReadinessProbe)
+}
+
+func Test_validatePatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		patches     []ObjectPatch
+		expectError bool
+	}{
+		{
+			name: "service",
+			patches: []ObjectPatch{
+				{Kind: "Service", Name: "kotsadm-api", StrategicMerge: []byte("metadata: {}")},
+			},
+		},
+		{
+			name: "secret",
+			patches: []ObjectPatch{
+				{Kind: "Secret", Name: "kotsadm-postgres", StrategicMerge: []byte("metadata: {}")},
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid json patch",
+			patches: []ObjectPatch{
+				{Kind: "Deployment", Name: "kotsadm-api", JSON6902: []byte("op: add")},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validatePatches(test.patches)
+			if test.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_LoadJSON6902PatchesInvalid(t *testing.T) {
+	for _, value := range []string{"Deployment=patch.yaml", "Deployment/kotsadm-api", "/kotsadm-api=patch.yaml"} {
+		_, err := LoadJSON6902Patches([]string{value})
+		assert.Error(t, err, value)
+	}
+}
@@ -12,21 +12,21 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
-func getPostgresYAML(namespace string, password string) (map[string][]byte, error) {
+func getPostgresYAML(deployOptions DeployOptions) (map[string][]byte, error) {
 	docs := map[string][]byte{}
 	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
 
 	var statefulset bytes.Buffer
-	if password == "" {
-		password = uuid.New().String()
+	if deployOptions.PostgresPassword == "" {
+		deployOptions.PostgresPassword = uuid.New().String()
 	}
-	if err := s.Encode(postgresStatefulset(namespace), &statefulset); err != nil {
+	if err := s.Encode(postgresStatefulset(deployOptions), &statefulset); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal postgres statefulset")
 	}
 	docs["postgres-statefulset.yaml"] = statefulset.Bytes()
 
 	var service bytes.Buffer
-	if err := s.Encode(postgresService(namespace), &service); err != nil {
+	if err := s.Encode(postgresService(deployOptions.Namespace), &service); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal postgres service")
 	}
 	docs["postgres-service.yaml"] = service.Bytes()
@@ -39,41 +39,70 @@ func ensurePostgres(deployOptions DeployOptions, clientset *kubernetes.Clientset
 		return errors.Wrap(err, "failed to ensure postgres secret")
 	}
 
-	if err := ensurePostgresStatefulset(deployOptions.Namespace, clientset); err != nil {
+	if err := ensurePostgresStatefulset(deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure postgres statefulset")
 	}
 
-	if err := ensurePostgresService(deployOptions.Namespace, clientset); err != nil {
+	if err := ensurePostgresService(deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure postgres service")
 	}
 
 	return nil
 }
 
-func ensurePostgresStatefulset(namespace string, clientset *kubernetes.Clientset) error {
-	_, err := clientset.AppsV1().StatefulSets(namespace).Get("kotsadm-postgres", metav1.GetOptions{})
+func ensurePostgresStatefulset(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	existing, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Get("kotsadm-postgres", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing statefulset")
 		}
 
-		_, err := clientset.AppsV1().StatefulSets(namespace).Create(postgresStatefulset(namespace))
+		statefulset := postgresStatefulset(deployOptions)
+		if err := applyPatches(deployOptions, statefulset); err != nil {
+			return errors.Wrap(err, "failed to patch postgres statefulset")
+		}
+		_, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Create(statefulset)
 		if err != nil {
 			return errors.Wrap(err, "failed to create postgres statefulset")
 		}
+
+		return nil
+	}
+
+	if err := upgradePostgres(deployOptions, existing, clientset); err != nil {
+		return errors.Wrap(err, "failed to upgrade postgres")
+	}
+
+	// statefulsets from earlier versions are rolled once to run with a read-only root filesystem
+	updated, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Get("kotsadm-postgres", metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get upgraded statefulset")
+	}
+	if !hasPostgresInitContainer(&updated.Spec.Template.Spec) {
+		statefulset := updated.DeepCopy()
+		addPostgresReadOnlyRootFilesystem(&statefulset.Spec.Template.Spec)
+		if err := rollPostgresStatefulset(deployOptions, statefulset, clientset); err != nil {
+			return errors.Wrap(err, "failed to update postgres to a read-only root filesystem")
+		}
 	}
 
 	return nil
 }
 
-func ensurePostgresService(namespace string, clientset *kubernetes.Clientset) error {
+func ensurePostgresService(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	namespace := deployOptions.Namespace
+
 	_, err := clientset.CoreV1().Services(namespace).Get("kotsadm-postgres", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing service")
 		}
 
-		_, err := clientset.CoreV1().Services(namespace).Create(postgresService(namespace))
+		service := postgresService(namespace)
+		if err := applyPatches(deployOptions, service); err != nil {
+			return errors.Wrap(err, "failed to patch postgres service")
+		}
+		_, err := clientset.CoreV1().Services(namespace).Create(service)
 		if err != nil {
 			return errors.Wrap(err, "Failed to create service")
 		}
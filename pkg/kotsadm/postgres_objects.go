@@ -1,16 +1,17 @@
 package kotsadm
 
 import (
+	"fmt"
+
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/replicatedhq/kots/pkg/util"
 )
 
-func postgresStatefulset(namespace string) *appsv1.StatefulSet {
+func postgresStatefulset(deployOptions DeployOptions) *appsv1.StatefulSet {
 	statefulset := &appsv1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
@@ -18,7 +19,8 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-postgres",
-			Namespace: namespace,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Spec: appsv1.StatefulSetSpec{
 			Selector: &metav1.LabelSelector{
@@ -29,18 +31,10 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
 				{
 					ObjectMeta: metav1.ObjectMeta{
-						Name: "kotsadm-postgres",
-					},
-					Spec: corev1.PersistentVolumeClaimSpec{
-						AccessModes: []corev1.PersistentVolumeAccessMode{
-							corev1.ReadWriteOnce,
-						},
-						Resources: corev1.ResourceRequirements{
-							Requests: corev1.ResourceList{
-								corev1.ResourceName(corev1.ResourceStorage): resource.MustParse("1Gi"),
-							},
-						},
+						Name:   "kotsadm-postgres",
+						Labels: kotsadmLabels(),
 					},
+					Spec: volumeClaimSpec(deployOptions.PostgresStorageSize, defaultPostgresStorageSize, deployOptions.StorageClassName),
 				},
 			},
 			Template: corev1.PodTemplateSpec{
@@ -50,9 +44,12 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 					},
 				},
 				Spec: corev1.PodSpec{
+					NodeSelector: deployOptions.NodeSelector,
+					Tolerations:  deployOptions.Tolerations,
+					Affinity:     deployOptions.Affinity,
 					SecurityContext: &corev1.PodSecurityContext{
-						RunAsUser: util.IntPointer(999),
-						FSGroup:   util.IntPointer(999),
+						RunAsUser: util.IntPointer(postgresUID),
+						FSGroup:   util.IntPointer(postgresUID),
 					},
 					Volumes: []corev1.Volume{
 						{
@@ -66,9 +63,10 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 					},
 					Containers: []corev1.Container{
 						{
-							Image:           "postgres:10.7",
+							Image:           postgresImage(),
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Name:            "kotsadm-postgres",
+							Resources:       deployOptions.Resources.Postgres,
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "postgres",
@@ -78,13 +76,13 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "kotsadm-postgres",
-									MountPath: "/var/lib/postgresql/data",
+									MountPath: postgresDataMountPath,
 								},
 							},
 							Env: []corev1.EnvVar{
 								{
 									Name:  "PGDATA",
-									Value: "/var/lib/postgresql/data/pgdata",
+									Value: defaultPostgresDataDir,
 								},
 								{
 									Name:  "POSTGRES_USER",
@@ -106,7 +104,7 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 									Value: "kotsadm",
 								},
 							},
-							LivenessProbe: &corev1.Probe{
+							LivenessProbe: deployOptions.TuningProfile.livenessProbe(&corev1.Probe{
 								InitialDelaySeconds: 30,
 								TimeoutSeconds:      5,
 								FailureThreshold:    3,
@@ -120,8 +118,8 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 										},
 									},
 								},
-							},
-							ReadinessProbe: &corev1.Probe{
+							}),
+							ReadinessProbe: deployOptions.TuningProfile.readinessProbe(&corev1.Probe{
 								InitialDelaySeconds: 1,
 								PeriodSeconds:       1,
 								TimeoutSeconds:      1,
@@ -135,7 +133,7 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 										},
 									},
 								},
-							},
+							}),
 						},
 					},
 				},
@@ -143,9 +141,126 @@ func postgresStatefulset(namespace string) *appsv1.StatefulSet {
 		},
 	}
 
+	addPostgresReadOnlyRootFilesystem(&statefulset.Spec.Template.Spec)
+
+	if deployOptions.EnableTLS {
+		podSpec := &statefulset.Spec.Template.Spec
+		// the key is only readable by its group, postgres reads it as a member of the fs group.
+		// openshift assigns the fs group from the range of the namespace instead.
+		if podSpec.SecurityContext == nil {
+			podSpec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		podSpec.SecurityContext.FSGroup = util.IntPointer(postgresUID)
+		podSpec.Volumes = append(podSpec.Volumes, tlsVolume(postgresTLSSecretName, &tlsKeyMode))
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, tlsVolumeMount())
+		podSpec.Containers[0].Args = []string{
+			"-c", "ssl=on",
+			"-c", fmt.Sprintf("ssl_cert_file=%s", tlsFile(corev1.TLSCertKey)),
+			"-c", fmt.Sprintf("ssl_key_file=%s", tlsFile(corev1.TLSPrivateKeyKey)),
+			"-c", fmt.Sprintf("ssl_ca_file=%s", tlsFile("ca.crt")),
+		}
+	}
+
+	addProxy(deployOptions, &statefulset.Spec.Template.Spec)
+	addSidecarInjection(deployOptions, &statefulset.Spec.Template.ObjectMeta, &statefulset.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &statefulset.Spec.Template.Spec)
+
 	return statefulset
 }
 
+const (
+	postgresUID            = 999
+	postgresInitName       = "kotsadm-postgres-init"
+	postgresRunMountPath   = "/var/run/postgresql"
+	postgresTmpMountPath   = "/tmp"
+	postgresRunVolumeName  = "kotsadm-postgres-run"
+	postgresTmpVolumeName  = "kotsadm-postgres-tmp"
+	postgresInitScriptTmpl = `find %[1]s \( ! -user %[2]d -o ! -group %[2]d \) -exec chown %[2]d:%[2]d {} +
+find %[1]s -mindepth 1 -maxdepth 1 -type d -name 'pgdata*' -exec chmod 700 {} +`
+)
+
+var (
+	postgresReadOnlyRootFilesystem   = true
+	postgresAllowPrivilegeEscalation = false
+	postgresInitRunAsNonRoot         = false
+)
+
+// addPostgresReadOnlyRootFilesystem lets postgres run with a read-only root filesystem by mounting
+// empty dirs where it writes the socket and temporary files. The init container fixes the owner
+// of the data volume for storage providers that don't apply the fsGroup, and the mode of the data
+// directories for ones that make them group writable, which postgres refuses to start with.
+func addPostgresReadOnlyRootFilesystem(podSpec *corev1.PodSpec) {
+	if hasPostgresInitContainer(podSpec) {
+		return
+	}
+
+	podSpec.Volumes = append(podSpec.Volumes,
+		corev1.Volume{
+			Name: postgresRunVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+		corev1.Volume{
+			Name: postgresTmpVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+	)
+
+	container := &podSpec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts,
+		corev1.VolumeMount{
+			Name:      postgresRunVolumeName,
+			MountPath: postgresRunMountPath,
+		},
+		corev1.VolumeMount{
+			Name:      postgresTmpVolumeName,
+			MountPath: postgresTmpMountPath,
+		},
+	)
+	container.SecurityContext = &corev1.SecurityContext{
+		ReadOnlyRootFilesystem:   &postgresReadOnlyRootFilesystem,
+		AllowPrivilegeEscalation: &postgresAllowPrivilegeEscalation,
+	}
+
+	dataMount := corev1.VolumeMount{}
+	for _, mount := range container.VolumeMounts {
+		if mount.MountPath == postgresDataMountPath {
+			dataMount = mount
+		}
+	}
+
+	podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+		Image:           container.Image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Name:            postgresInitName,
+		Command:         []string{"/bin/sh", "-c", "set -e\n" + fmt.Sprintf(postgresInitScriptTmpl, postgresDataMountPath, postgresUID)},
+		VolumeMounts:    []corev1.VolumeMount{dataMount},
+		// root only has the capabilities to change owners and modes, e.g. to read directories owned by postgres
+		SecurityContext: &corev1.SecurityContext{
+			RunAsUser:                util.IntPointer(0),
+			RunAsNonRoot:             &postgresInitRunAsNonRoot,
+			ReadOnlyRootFilesystem:   &postgresReadOnlyRootFilesystem,
+			AllowPrivilegeEscalation: &postgresAllowPrivilegeEscalation,
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+				Add:  []corev1.Capability{"CHOWN", "FOWNER", "DAC_OVERRIDE"},
+			},
+		},
+	})
+}
+
+func hasPostgresInitContainer(podSpec *corev1.PodSpec) bool {
+	for _, container := range podSpec.InitContainers {
+		if container.Name == postgresInitName {
+			return true
+		}
+	}
+	return false
+}
+
 func postgresService(namespace string) *corev1.Service {
 	service := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -155,6 +270,7 @@ func postgresService(namespace string) *corev1.Service {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-postgres",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{
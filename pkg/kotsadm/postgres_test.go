@@ -32,7 +32,7 @@ func Test_getPostgresYAML(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			req := require.New(t)
 
-			manifests, err := getPostgresYAML(test.namespace, test.password)
+			manifests, err := getPostgresYAML(DeployOptions{Namespace: test.namespace, PostgresPassword: test.password})
 			req.NoError(err)
 			assert.NotNil(t, manifests)
 
@@ -56,3 +56,30 @@ func Test_getPostgresYAML(t *testing.T) {
 		})
 	}
 }
+
+func Test_addPostgresReadOnlyRootFilesystem(t *testing.T) {
+	statefulset := postgresStatefulset(DeployOptions{Namespace: "default"})
+	podSpec := &statefulset.Spec.Template.Spec
+
+	container := podSpec.Containers[0]
+	require.NotNil(t, container.SecurityContext)
+	assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem)
+
+	mountPaths := []string{}
+	for _, mount := range container.VolumeMounts {
+		mountPaths = append(mountPaths, mount.MountPath)
+	}
+	assert.ElementsMatch(t, []string{postgresDataMountPath, postgresRunMountPath, postgresTmpMountPath}, mountPaths)
+
+	require.Len(t, podSpec.InitContainers, 1)
+	initContainer := podSpec.InitContainers[0]
+	assert.Equal(t, postgresInitName, initContainer.Name)
+	assert.Equal(t, int64(0), *initContainer.SecurityContext.RunAsUser)
+	assert.Equal(t, []corev1.VolumeMount{{Name: "kotsadm-postgres", MountPath: postgresDataMountPath}}, initContainer.VolumeMounts)
+
+	// statefulsets that already have the layout are not changed
+	addPostgresReadOnlyRootFilesystem(podSpec)
+	assert.Len(t, podSpec.InitContainers, 1)
+	assert.Len(t, podSpec.Volumes, 3)
+	assert.Len(t, podSpec.Containers[0].VolumeMounts, 3)
+}
@@ -0,0 +1,351 @@
+package kotsadm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	postgresTag = "12.2"
+
+	postgresDataMountPath  = "/var/lib/postgresql/data"
+	defaultPostgresDataDir = postgresDataMountPath + "/pgdata"
+
+	postgresBackupClaimName = "kotsadm-postgres-backup"
+	postgresBackupMountPath = "/backup"
+)
+
+func postgresImage() string {
+	return thirdPartyImage("postgres", postgresTag)
+}
+
+var timeoutWaitingForPostgresUpgrade = time.Duration(time.Minute * 10)
+
+// upgradePostgres rolls an existing postgres statefulset to postgresImage. Minor versions use the
+// same data directory, so only the image is changed. When the major version changes the database
+// is dumped to a backup volume, the new version is started with an empty data directory next to
+// the old one, and the dump is restored into it. The old data directory is never modified, so if
+// any step fails the statefulset is rolled back to the old image and data directory.
+func upgradePostgres(deployOptions DeployOptions, existing *appsv1.StatefulSet, clientset *kubernetes.Clientset) error {
+	if len(existing.Spec.Template.Spec.Containers) == 0 {
+		return errors.New("postgres statefulset has no containers")
+	}
+	container := existing.Spec.Template.Spec.Containers[0]
+	if container.Image == postgresImage() {
+		return nil
+	}
+
+	targetMajor, err := postgresImageMajorVersion(postgresImage())
+	if err != nil {
+		return errors.Wrap(err, "failed to get target postgres version")
+	}
+
+	log := deployOptions.newLogger()
+
+	// images that aren't tagged with a version are checked by connecting to the database below
+	if currentMajor, err := postgresImageMajorVersion(container.Image); err == nil && currentMajor == targetMajor {
+		log.ChildActionWithSpinner("Updating datastore to %s", postgresImage())
+		if err := rollPostgresStatefulset(deployOptions, upgradedPostgresStatefulset(existing, postgresImage(), postgresDataDir(existing)), clientset); err != nil {
+			return errors.Wrap(err, "failed to update postgres")
+		}
+		log.FinishChildSpinner()
+		return nil
+	}
+
+	// stop writes so that nothing is lost between the backup and the restore
+	restoreAPI, err := scaleDownAPI(deployOptions.Namespace, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to stop api")
+	}
+	defer restoreAPI()
+
+	log.ChildActionWithSpinner("Backing up datastore")
+	if err := ensurePostgresBackupClaim(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure backup volume")
+	}
+	serverVersion, err := runJob(deployOptions, postgresBackupJob(deployOptions, container.Image), timeoutWaitingForPostgresUpgrade, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to back up postgres")
+	}
+	currentMajor, err := postgresServerMajorVersion(serverVersion)
+	if err != nil {
+		return errors.Wrap(err, "failed to get current postgres version")
+	}
+	log.FinishChildSpinner()
+
+	if currentMajor == targetMajor {
+		log.ChildActionWithSpinner("Updating datastore to %s", postgresImage())
+		if err := rollPostgresStatefulset(deployOptions, upgradedPostgresStatefulset(existing, postgresImage(), postgresDataDir(existing)), clientset); err != nil {
+			return errors.Wrap(err, "failed to update postgres")
+		}
+		log.FinishChildSpinner()
+		return nil
+	}
+	if currentMajor > targetMajor {
+		return errors.Errorf("postgres %d is newer than %d and can't be downgraded", currentMajor, targetMajor)
+	}
+
+	log.ChildActionWithSpinner("Upgrading datastore from postgres %d to %d", currentMajor, targetMajor)
+	upgradeErr := migratePostgres(deployOptions, existing, targetMajor, clientset)
+	if upgradeErr == nil {
+		log.FinishChildSpinner()
+		return nil
+	}
+	log.FinishChildSpinner()
+
+	log.ChildActionWithSpinner("Upgrade failed, rolling back datastore to %s", container.Image)
+	if err := rollPostgresStatefulset(deployOptions, existing, clientset); err != nil {
+		return errors.Wrapf(err, "failed to roll back postgres after upgrade failed: %s", upgradeErr.Error())
+	}
+	log.FinishChildSpinner()
+
+	return errors.Wrap(upgradeErr, "failed to upgrade postgres, it was rolled back to the previous version")
+}
+
+// migratePostgres starts the new version in its own data directory and restores the backup into it
+func migratePostgres(deployOptions DeployOptions, existing *appsv1.StatefulSet, targetMajor int, clientset *kubernetes.Clientset) error {
+	dataDir := fmt.Sprintf("%s-%d", defaultPostgresDataDir, targetMajor)
+	if err := rollPostgresStatefulset(deployOptions, upgradedPostgresStatefulset(existing, postgresImage(), dataDir), clientset); err != nil {
+		return errors.Wrap(err, "failed to start new postgres version")
+	}
+
+	if _, err := runJob(deployOptions, postgresRestoreJob(deployOptions), timeoutWaitingForPostgresUpgrade, clientset); err != nil {
+		return errors.Wrap(err, "failed to restore backup")
+	}
+
+	return nil
+}
+
+// rollPostgresStatefulset updates the statefulset and waits for the new pod to be ready
+func rollPostgresStatefulset(deployOptions DeployOptions, statefulset *appsv1.StatefulSet, clientset *kubernetes.Clientset) error {
+	current, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Get(statefulset.Name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get statefulset")
+	}
+	current.Spec.Template = statefulset.Spec.Template
+
+	updated, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Update(current)
+	if err != nil {
+		return errors.Wrap(err, "failed to update statefulset")
+	}
+
+	start := time.Now()
+	for {
+		current, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Get(statefulset.Name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrap(err, "failed to get statefulset")
+		}
+
+		status := current.Status
+		if status.ObservedGeneration >= updated.Generation && status.UpdateRevision == status.CurrentRevision && status.ReadyReplicas > 0 {
+			return nil
+		}
+
+		if err := waitToPoll(deployOptions.context()); err != nil {
+			return err
+		}
+
+		if time.Now().Sub(start) > deployOptions.TuningProfile.timeout(timeoutWaitingForPostgresUpgrade) {
+			return errors.New("timeout waiting for postgres to be ready")
+		}
+	}
+}
+
+// runJob runs the job to completion and returns the termination message of its pod
+func runJob(deployOptions DeployOptions, job *batchv1.Job, timeout time.Duration, clientset *kubernetes.Clientset) (string, error) {
+	// a job from an earlier attempt would prevent this one from being created
+	propagation := metav1.DeletePropagationBackground
+	err := clientset.BatchV1().Jobs(deployOptions.Namespace).Delete(job.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return "", errors.Wrap(err, "failed to delete previous job")
+	}
+	if err := waitForJobDeleted(deployOptions, job.Name, clientset); err != nil {
+		return "", errors.Wrap(err, "failed to wait for previous job to be deleted")
+	}
+
+	if err := applyPatches(deployOptions, job); err != nil {
+		return "", errors.Wrap(err, "failed to patch job")
+	}
+	if _, err := clientset.BatchV1().Jobs(deployOptions.Namespace).Create(job); err != nil {
+		return "", errors.Wrap(err, "failed to create job")
+	}
+
+	start := time.Now()
+	for {
+		current, err := clientset.BatchV1().Jobs(deployOptions.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get job")
+		}
+
+		if current.Status.Succeeded > 0 || current.Status.Failed > 0 {
+			message, err := jobTerminationMessage(deployOptions.Namespace, job.Name, clientset)
+			if err != nil {
+				return "", errors.Wrap(err, "failed to get job result")
+			}
+			if current.Status.Failed > 0 {
+				return "", errors.Errorf("job %s failed: %s", job.Name, message)
+			}
+			return message, nil
+		}
+
+		if err := waitToPoll(deployOptions.context()); err != nil {
+			return "", err
+		}
+
+		if time.Now().Sub(start) > deployOptions.TuningProfile.timeout(timeout) {
+			return "", errors.Errorf("timeout waiting for job %s", job.Name)
+		}
+	}
+}
+
+func waitForJobDeleted(deployOptions DeployOptions, name string, clientset *kubernetes.Clientset) error {
+	start := time.Now()
+	for {
+		_, err := clientset.BatchV1().Jobs(deployOptions.Namespace).Get(name, metav1.GetOptions{})
+		if kuberneteserrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to get job")
+		}
+
+		if err := waitToPoll(deployOptions.context()); err != nil {
+			return err
+		}
+
+		if time.Now().Sub(start) > deployOptions.TuningProfile.timeout(time.Minute) {
+			return errors.Errorf("timeout waiting for job %s to be deleted", name)
+		}
+	}
+}
+
+func jobTerminationMessage(namespace string, jobName string, clientset *kubernetes.Clientset) (string, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: fmt.Sprintf("job-name=%s", jobName)})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list job pods")
+	}
+
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil {
+				return strings.TrimSpace(status.State.Terminated.Message), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+func ensurePostgresBackupClaim(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	_, err := clientset.CoreV1().PersistentVolumeClaims(deployOptions.Namespace).Get(postgresBackupClaimName, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get existing backup volume claim")
+	}
+
+	if _, err := clientset.CoreV1().PersistentVolumeClaims(deployOptions.Namespace).Create(postgresBackupClaim(deployOptions)); err != nil {
+		return errors.Wrap(err, "failed to create backup volume claim")
+	}
+
+	return nil
+}
+
+// scaleDownAPI scales the api deployment to 0 and returns a function that scales it back
+func scaleDownAPI(namespace string, clientset *kubernetes.Clientset) (func(), error) {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get("kotsadm-api", metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get api deployment")
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	zero := int32(0)
+	deployment.Spec.Replicas = &zero
+	if _, err := clientset.AppsV1().Deployments(namespace).Update(deployment); err != nil {
+		return nil, errors.Wrap(err, "failed to scale api deployment")
+	}
+
+	return func() {
+		deployment, err := clientset.AppsV1().Deployments(namespace).Get("kotsadm-api", metav1.GetOptions{})
+		if err != nil {
+			return
+		}
+		deployment.Spec.Replicas = &replicas
+		clientset.AppsV1().Deployments(namespace).Update(deployment)
+	}, nil
+}
+
+// upgradedPostgresStatefulset returns a copy of the statefulset running image with the data directory
+func upgradedPostgresStatefulset(existing *appsv1.StatefulSet, image string, dataDir string) *appsv1.StatefulSet {
+	statefulset := existing.DeepCopy()
+	container := &statefulset.Spec.Template.Spec.Containers[0]
+	container.Image = image
+
+	for i, env := range container.Env {
+		if env.Name == "PGDATA" {
+			container.Env[i].Value = dataDir
+			return statefulset
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: "PGDATA", Value: dataDir})
+
+	return statefulset
+}
+
+func postgresDataDir(statefulset *appsv1.StatefulSet) string {
+	for _, env := range statefulset.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "PGDATA" {
+			return env.Value
+		}
+	}
+	return defaultPostgresDataDir
+}
+
+// postgresImageMajorVersion returns the major version from the tag of a postgres image (e.g. 10 for postgres:10.7)
+func postgresImageMajorVersion(image string) (int, error) {
+	if idx := strings.Index(image, "@"); idx != -1 {
+		image = image[:idx]
+	}
+
+	idx := strings.LastIndex(image, ":")
+	if idx == -1 || strings.Contains(image[idx:], "/") {
+		return 0, errors.Errorf("image %s does not have a tag", image)
+	}
+
+	tag := image[idx+1:]
+	major, err := strconv.Atoi(strings.SplitN(tag, ".", 2)[0])
+	if err != nil {
+		return 0, errors.Errorf("image %s does not have a version tag", image)
+	}
+
+	return major, nil
+}
+
+// postgresServerMajorVersion returns the major version from server_version_num (e.g. 10 for 100007)
+func postgresServerMajorVersion(serverVersionNum string) (int, error) {
+	version, err := strconv.Atoi(strings.TrimSpace(serverVersionNum))
+	if err != nil {
+		return 0, errors.Errorf("invalid server version %q", serverVersionNum)
+	}
+
+	// versions before 10 had two part major versions (90600 for 9.6), these are all older than 10
+	return version / 10000, nil
+}
@@ -0,0 +1,134 @@
+package kotsadm
+
+import (
+	"fmt"
+	"path"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/replicatedhq/kots/pkg/util"
+)
+
+var (
+	postgresBackupFile = path.Join(postgresBackupMountPath, "kotsadm.dump")
+
+	postgresUpgradeJobBackoffLimit = int32(0)
+)
+
+func postgresBackupClaim(deployOptions DeployOptions) *corev1.PersistentVolumeClaim {
+	claim := &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      postgresBackupClaimName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: volumeClaimSpec(deployOptions.PostgresStorageSize, defaultPostgresStorageSize, deployOptions.StorageClassName),
+	}
+
+	return claim
+}
+
+// postgresBackupJob dumps the database with the tools from the running version, and reports that
+// version in the termination message
+func postgresBackupJob(deployOptions DeployOptions, image string) *batchv1.Job {
+	script := fmt.Sprintf(`set -e
+version=$(psql "$POSTGRES_URI" --no-psqlrc -tAc 'SHOW server_version_num')
+pg_dump "$POSTGRES_URI" --format=custom --file=%s.tmp
+mv %s.tmp %s
+echo "$version" > /dev/termination-log`, postgresBackupFile, postgresBackupFile, postgresBackupFile)
+
+	return postgresUpgradeJob(deployOptions, "kotsadm-postgres-backup", image, script)
+}
+
+// postgresRestoreJob restores the backup into the new version. Existing objects are dropped first
+// so that a restore that failed part way can be retried.
+func postgresRestoreJob(deployOptions DeployOptions) *batchv1.Job {
+	script := fmt.Sprintf(`set -e
+pg_restore --dbname="$POSTGRES_URI" --clean --if-exists --no-owner --exit-on-error %s`, postgresBackupFile)
+
+	return postgresUpgradeJob(deployOptions, "kotsadm-postgres-restore", postgresImage(), script)
+}
+
+func postgresUpgradeJob(deployOptions DeployOptions, name string, image string, script string) *batchv1.Job {
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: batchv1.JobSpec{
+			// failures are handled by rolling back, not by retrying
+			BackoffLimit: &postgresUpgradeJobBackoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					NodeSelector:  deployOptions.NodeSelector,
+					Tolerations:   deployOptions.Tolerations,
+					Affinity:      deployOptions.Affinity,
+					RestartPolicy: corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsUser: util.IntPointer(999),
+						FSGroup:   util.IntPointer(999),
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "backup",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: postgresBackupClaimName,
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Image:           image,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Name:            name,
+							Command:         []string{"/bin/sh", "-c", script},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "backup",
+									MountPath: postgresBackupMountPath,
+								},
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name: "POSTGRES_URI",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "kotsadm-postgres",
+											},
+											Key: "uri",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	addProxy(deployOptions, &job.Spec.Template.Spec)
+	addSidecarInjection(deployOptions, &job.Spec.Template.ObjectMeta, &job.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &job.Spec.Template.Spec)
+
+	return job
+}
@@ -0,0 +1,117 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_postgresImageMajorVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		image       string
+		expected    int
+		expectError bool
+	}{
+		{
+			name:     "minor version",
+			image:    "postgres:10.7",
+			expected: 10,
+		},
+		{
+			name:     "major version only",
+			image:    "postgres:12",
+			expected: 12,
+		},
+		{
+			name:     "registry with port",
+			image:    "registry.example.com:5000/library/postgres:12.2-alpine",
+			expected: 12,
+		},
+		{
+			name:     "pinned by digest",
+			image:    "postgres:12.2@sha256:e4be5b4c2c1cd5ae5b8a2fbbc0d0e2a0d7e4d5e7b1b0d7ad2b2ef8b1a8a1e0f1",
+			expected: 12,
+		},
+		{
+			name:        "no tag",
+			image:       "registry.example.com:5000/library/postgres",
+			expectError: true,
+		},
+		{
+			name:        "latest",
+			image:       "postgres:latest",
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := postgresImageMajorVersion(test.image)
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_postgresServerMajorVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		expected    int
+		expectError bool
+	}{
+		{
+			name:     "10",
+			version:  "100007\n",
+			expected: 10,
+		},
+		{
+			name:     "12",
+			version:  "120002",
+			expected: 12,
+		},
+		{
+			name:     "9.6",
+			version:  "90600",
+			expected: 9,
+		},
+		{
+			name:        "empty",
+			version:     "",
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := postgresServerMajorVersion(test.version)
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_upgradedPostgresStatefulset(t *testing.T) {
+	existing := postgresStatefulset(DeployOptions{Namespace: "default"})
+	existing.Spec.Template.Spec.Containers[0].Image = "postgres:10.7"
+
+	upgraded := upgradedPostgresStatefulset(existing, postgresImage(), defaultPostgresDataDir+"-12")
+
+	assert.Equal(t, postgresImage(), upgraded.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, defaultPostgresDataDir+"-12", postgresDataDir(upgraded))
+	assert.Len(t, upgraded.Spec.Template.Spec.Containers[0].Env, len(existing.Spec.Template.Spec.Containers[0].Env))
+
+	// the existing statefulset is kept to roll back to
+	assert.Equal(t, "postgres:10.7", existing.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, defaultPostgresDataDir, postgresDataDir(existing))
+}
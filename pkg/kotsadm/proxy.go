@@ -0,0 +1,131 @@
+package kotsadm
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+const (
+	caBundleConfigMapName = "kotsadm-ca-bundle"
+	caBundleKey           = "ca-bundle.crt"
+	caBundleMountPath     = "/etc/kotsadm/ca"
+
+	// caTrustMountPath has the additional CA certificates combined with the ones that a container
+	// already trusted, for runtimes that only read one extra file
+	caTrustMountPath = "/etc/kotsadm/trust"
+)
+
+func usesProxy(deployOptions DeployOptions) bool {
+	return deployOptions.HTTPProxy != "" || deployOptions.HTTPSProxy != "" || deployOptions.NoProxy != ""
+}
+
+func usesCABundle(deployOptions DeployOptions) bool {
+	return len(deployOptions.AdditionalCACert) > 0
+}
+
+func validateProxyOptions(deployOptions DeployOptions) error {
+	for _, proxy := range []string{deployOptions.HTTPProxy, deployOptions.HTTPSProxy} {
+		if proxy == "" {
+			continue
+		}
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return errors.Errorf("invalid proxy %q, expected a url like http://proxy.example.com:3128", proxy)
+		}
+	}
+
+	if usesCABundle(deployOptions) {
+		if _, err := certutil.ParseCertsPEM(deployOptions.AdditionalCACert); err != nil {
+			return errors.Wrap(err, "failed to parse additional CA certificates")
+		}
+	}
+
+	return nil
+}
+
+func getProxyYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	if usesCABundle(deployOptions) {
+		var configMap bytes.Buffer
+		if err := s.Encode(caBundleConfigMap(deployOptions), &configMap); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal ca bundle config map")
+		}
+		docs["ca-bundle-configmap.yaml"] = configMap.Bytes()
+	}
+
+	return docs, nil
+}
+
+// ensureCABundle creates the config map with the additional CA certificates, or replaces the
+// certificates in an existing one so that they can be rotated
+func ensureCABundle(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	existing, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Get(caBundleConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing config map")
+		}
+
+		configMap := caBundleConfigMap(deployOptions)
+		if err := applyPatches(deployOptions, configMap); err != nil {
+			return errors.Wrap(err, "failed to patch ca bundle config map")
+		}
+		_, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(configMap)
+		if err != nil {
+			return errors.Wrap(err, "failed to create config map")
+		}
+
+		return nil
+	}
+
+	existing.Data = caBundleConfigMap(deployOptions).Data
+	if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update config map")
+	}
+
+	return nil
+}
+
+// readProxyOptions returns the proxy and the additional CA certificates that the api was deployed with
+func readProxyOptions(namespace string, clientset *kubernetes.Clientset) (string, string, string, []byte, error) {
+	var httpProxy, httpsProxy, noProxy string
+
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get("kotsadm-api", metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return "", "", "", nil, errors.Wrap(err, "failed to get api deployment")
+	}
+	if err == nil && len(deployment.Spec.Template.Spec.Containers) > 0 {
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			switch env.Name {
+			case "HTTP_PROXY":
+				httpProxy = env.Value
+			case "HTTPS_PROXY":
+				httpsProxy = env.Value
+			case "NO_PROXY":
+				noProxy = strings.TrimPrefix(strings.TrimPrefix(env.Value, defaultNoProxy(namespace)), ",")
+			}
+		}
+	}
+
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(caBundleConfigMapName, metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return "", "", "", nil, errors.Wrap(err, "failed to get ca bundle config map")
+	}
+
+	var caBundle []byte
+	if err == nil {
+		caBundle = []byte(configMap.Data[caBundleKey])
+	}
+
+	return httpProxy, httpsProxy, noProxy, caBundle, nil
+}
@@ -0,0 +1,157 @@
+package kotsadm
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func caBundleConfigMap(deployOptions DeployOptions) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      caBundleConfigMapName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Data: map[string]string{
+			caBundleKey: string(deployOptions.AdditionalCACert),
+		},
+	}
+
+	return configMap
+}
+
+// defaultNoProxy are the in-cluster addresses that admin console pods connect to directly. The
+// kubernetes api is reached at $(KUBERNETES_SERVICE_HOST), which the kubelet expands.
+func defaultNoProxy(namespace string) string {
+	return strings.Join([]string{
+		"localhost",
+		"127.0.0.1",
+		"$(KUBERNETES_SERVICE_HOST)",
+		"kotsadm-api",
+		"kotsadm-minio",
+		"kotsadm-postgres",
+		"kotsadm-web",
+		fmt.Sprintf(".%s", namespace),
+		".svc",
+		".cluster.local",
+	}, ",")
+}
+
+func proxyEnv(deployOptions DeployOptions) []corev1.EnvVar {
+	noProxy := defaultNoProxy(deployOptions.Namespace)
+	if deployOptions.NoProxy != "" {
+		noProxy = fmt.Sprintf("%s,%s", noProxy, deployOptions.NoProxy)
+	}
+
+	env := []corev1.EnvVar{}
+	if deployOptions.HTTPProxy != "" {
+		env = append(env, corev1.EnvVar{Name: "HTTP_PROXY", Value: deployOptions.HTTPProxy})
+	}
+	if deployOptions.HTTPSProxy != "" {
+		env = append(env, corev1.EnvVar{Name: "HTTPS_PROXY", Value: deployOptions.HTTPSProxy})
+	}
+	env = append(env, corev1.EnvVar{Name: "NO_PROXY", Value: noProxy})
+
+	return env
+}
+
+// addProxy sets the proxy environment variables on all containers of the pod, and mounts the
+// additional CA certificates so that go (SSL_CERT_DIR) and node (NODE_EXTRA_CA_CERTS) trust them
+func addProxy(deployOptions DeployOptions, podSpec *corev1.PodSpec) {
+	if !usesProxy(deployOptions) && !usesCABundle(deployOptions) {
+		return
+	}
+
+	if usesCABundle(deployOptions) {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "kotsadm-ca-bundle",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: caBundleConfigMapName,
+					},
+				},
+			},
+		})
+	}
+
+	trustInitContainers := []corev1.Container{}
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			container := &containers[i]
+			if usesProxy(deployOptions) {
+				container.Env = append(container.Env, proxyEnv(deployOptions)...)
+			}
+			if !usesCABundle(deployOptions) {
+				continue
+			}
+
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      "kotsadm-ca-bundle",
+				MountPath: caBundleMountPath,
+				ReadOnly:  true,
+			})
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  "SSL_CERT_DIR",
+				Value: fmt.Sprintf("/etc/ssl/certs:%s", caBundleMountPath),
+			})
+
+			// node reads only one extra file, so a file that is already trusted is combined with the bundle
+			trusted := ""
+			for j, env := range container.Env {
+				if env.Name == "NODE_EXTRA_CA_CERTS" {
+					trusted = env.Value
+					container.Env[j].Value = path.Join(caTrustMountPath, caBundleKey)
+				}
+			}
+			if trusted == "" {
+				container.Env = append(container.Env, corev1.EnvVar{
+					Name:  "NODE_EXTRA_CA_CERTS",
+					Value: path.Join(caBundleMountPath, caBundleKey),
+				})
+				continue
+			}
+
+			trustInitContainers = append(trustInitContainers, corev1.Container{
+				Image:           container.Image,
+				ImagePullPolicy: container.ImagePullPolicy,
+				Name:            fmt.Sprintf("%s-ca-bundle", container.Name),
+				Command: []string{
+					"/bin/sh",
+					"-c",
+					fmt.Sprintf("cat %s %s > %s", trusted, path.Join(caBundleMountPath, caBundleKey), path.Join(caTrustMountPath, caBundleKey)),
+				},
+				VolumeMounts: append([]corev1.VolumeMount{}, container.VolumeMounts...),
+			})
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      "kotsadm-ca-trust",
+				MountPath: caTrustMountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+
+	if len(trustInitContainers) > 0 {
+		for i := range trustInitContainers {
+			trustInitContainers[i].VolumeMounts = append(trustInitContainers[i].VolumeMounts, corev1.VolumeMount{
+				Name:      "kotsadm-ca-trust",
+				MountPath: caTrustMountPath,
+			})
+		}
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "kotsadm-ca-trust",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+		podSpec.InitContainers = append(podSpec.InitContainers, trustInitContainers...)
+	}
+}
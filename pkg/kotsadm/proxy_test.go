@@ -0,0 +1,116 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_addProxy(t *testing.T) {
+	ca, err := loadOrGenerateCA(DeployOptions{})
+	require.NoError(t, err)
+
+	deployOptions := DeployOptions{
+		Namespace:        "default",
+		HTTPSProxy:       "http://proxy.example.com:3128",
+		NoProxy:          "internal.example.com",
+		AdditionalCACert: ca.certPEM,
+	}
+	require.NoError(t, validateProxyOptions(deployOptions))
+
+	podSpecs := map[string]corev1.PodSpec{
+		"postgres":           postgresStatefulset(deployOptions).Spec.Template.Spec,
+		"minio":              minioStatefulset(deployOptions).Spec.Template.Spec,
+		"api":                apiDeployment(deployOptions).Spec.Template.Spec,
+		"web":                webDeployment(deployOptions).Spec.Template.Spec,
+		"operator":           operatorDeployment(deployOptions).Spec.Template.Spec,
+		"migrations":         migrationsPod(deployOptions).Spec,
+		"postgres-preflight": postgresPreflightPod(deployOptions).Spec,
+		"snapshot":           snapshotJob(deployOptions, "").Spec.Template.Spec,
+		"restore":            restoreSnapshotJob(deployOptions, "").Spec.Template.Spec,
+		"postgres-backup":    postgresBackupJob(deployOptions, postgresImage()).Spec.Template.Spec,
+	}
+
+	for name, podSpec := range podSpecs {
+		for _, container := range append(podSpec.InitContainers, podSpec.Containers...) {
+			env := map[string]string{}
+			for _, e := range container.Env {
+				env[e.Name] = e.Value
+			}
+
+			assert.Equal(t, "http://proxy.example.com:3128", env["HTTPS_PROXY"], name)
+			assert.NotContains(t, env, "HTTP_PROXY", name)
+			assert.Contains(t, env["NO_PROXY"], "kotsadm-minio", name)
+			assert.Contains(t, env["NO_PROXY"], ",internal.example.com", name)
+			assert.Equal(t, "/etc/ssl/certs:/etc/kotsadm/ca", env["SSL_CERT_DIR"], name)
+			assert.Equal(t, "/etc/kotsadm/ca/ca-bundle.crt", env["NODE_EXTRA_CA_CERTS"], name)
+		}
+	}
+}
+
+func Test_addProxyWithTLS(t *testing.T) {
+	ca, err := loadOrGenerateCA(DeployOptions{})
+	require.NoError(t, err)
+
+	deployOptions := DeployOptions{
+		Namespace:        "default",
+		EnableTLS:        true,
+		AdditionalCACert: ca.certPEM,
+	}
+
+	podSpec := apiDeployment(deployOptions).Spec.Template.Spec
+
+	// the api trusts both the tls CA and the additional certificates
+	require.Len(t, podSpec.InitContainers, 1)
+	assert.Equal(t, []string{"/bin/sh", "-c", "cat /etc/kotsadm/tls/ca.crt /etc/kotsadm/ca/ca-bundle.crt > /etc/kotsadm/trust/ca-bundle.crt"}, podSpec.InitContainers[0].Command)
+
+	env := map[string]string{}
+	for _, e := range podSpec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	assert.Equal(t, "/etc/kotsadm/trust/ca-bundle.crt", env["NODE_EXTRA_CA_CERTS"])
+	assert.NotContains(t, env, "NO_PROXY")
+}
+
+func Test_validateProxyOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		deployOptions DeployOptions
+		expectError   bool
+	}{
+		{
+			name: "proxy",
+			deployOptions: DeployOptions{
+				HTTPProxy:  "http://proxy.example.com:3128",
+				HTTPSProxy: "http://proxy.example.com:3128",
+			},
+		},
+		{
+			name: "proxy without scheme",
+			deployOptions: DeployOptions{
+				HTTPSProxy: "proxy.example.com:3128",
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid ca",
+			deployOptions: DeployOptions{
+				AdditionalCACert: []byte("not a certificate"),
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateProxyOptions(test.deployOptions)
+			if test.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
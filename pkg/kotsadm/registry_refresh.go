@@ -0,0 +1,212 @@
+package kotsadm
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	registryCredentialsSecretName = "kotsadm-registry-credentials"
+	registryRefreshName           = "kotsadm-registry-refresh"
+)
+
+// registryRefreshProvider returns the cloud whose registry tokens are refreshed by the cronjob,
+// or an empty string when the pull secret holds credentials that don't expire
+func registryRefreshProvider(deployOptions DeployOptions) string {
+	if deployOptions.RegistryCredentials.Endpoint == "" {
+		return ""
+	}
+
+	provider := registry.CredentialProviderForRegistry(deployOptions.RegistryCredentials)
+	if provider == nil {
+		return ""
+	}
+	return provider.Name()
+}
+
+func usesRegistryRefresh(deployOptions DeployOptions) bool {
+	return registryRefreshProvider(deployOptions) != ""
+}
+
+func getRegistryRefreshYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var secret bytes.Buffer
+	if err := s.Encode(registryCredentialsSecret(deployOptions.Namespace, deployOptions.RegistryCredentials), &secret); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal registry credentials secret")
+	}
+	docs["secret-registry-credentials.yaml"] = secret.Bytes()
+
+	var serviceAccount bytes.Buffer
+	if err := s.Encode(registryRefreshServiceAccount(deployOptions.Namespace), &serviceAccount); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal registry refresh service account")
+	}
+	docs["registry-refresh-serviceaccount.yaml"] = serviceAccount.Bytes()
+
+	var role bytes.Buffer
+	if err := s.Encode(registryRefreshRole(deployOptions), &role); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal registry refresh role")
+	}
+	docs["registry-refresh-role.yaml"] = role.Bytes()
+
+	var roleBinding bytes.Buffer
+	if err := s.Encode(registryRefreshRoleBinding(deployOptions.Namespace), &roleBinding); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal registry refresh role binding")
+	}
+	docs["registry-refresh-rolebinding.yaml"] = roleBinding.Bytes()
+
+	var cronJob bytes.Buffer
+	if err := s.Encode(registryRefreshCronJob(deployOptions, registryRefreshProvider(deployOptions)), &cronJob); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal registry refresh cronjob")
+	}
+	docs["registry-refresh-cronjob.yaml"] = cronJob.Bytes()
+
+	return docs, nil
+}
+
+func ensureRegistryRefresh(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	if err := ensureRegistryCredentialsSecret(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure registry credentials secret")
+	}
+
+	if err := ensureRegistryRefreshRBAC(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure registry refresh rbac")
+	}
+
+	if err := ensureRegistryRefreshCronJob(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure registry refresh cronjob")
+	}
+
+	return nil
+}
+
+func ensureRegistryCredentialsSecret(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	existing, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(registryCredentialsSecretName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing registry credentials secret")
+		}
+
+		_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Create(registryCredentialsSecret(deployOptions.Namespace, deployOptions.RegistryCredentials))
+		if err != nil {
+			return errors.Wrap(err, "failed to create registry credentials secret")
+		}
+
+		return nil
+	}
+
+	// the credentials can be rotated by installing again
+	existing.Data = registryCredentialsSecret(deployOptions.Namespace, deployOptions.RegistryCredentials).Data
+	if _, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update registry credentials secret")
+	}
+
+	return nil
+}
+
+func ensureRegistryRefreshRBAC(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	_, err := clientset.CoreV1().ServiceAccounts(deployOptions.Namespace).Get(registryRefreshName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing service account")
+		}
+
+		serviceAccount := registryRefreshServiceAccount(deployOptions.Namespace)
+		if err := applyPatches(deployOptions, serviceAccount); err != nil {
+			return errors.Wrap(err, "failed to patch service account")
+		}
+		if _, err := clientset.CoreV1().ServiceAccounts(deployOptions.Namespace).Create(serviceAccount); err != nil {
+			return errors.Wrap(err, "failed to create service account")
+		}
+	}
+
+	existingRole, err := clientset.RbacV1().Roles(deployOptions.Namespace).Get(registryRefreshName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing role")
+		}
+
+		if _, err := clientset.RbacV1().Roles(deployOptions.Namespace).Create(registryRefreshRole(deployOptions)); err != nil {
+			return errors.Wrap(err, "failed to create role")
+		}
+	} else {
+		// the pull secret is renamed when installing again with an instance name
+		existingRole.Rules = registryRefreshRole(deployOptions).Rules
+		if _, err := clientset.RbacV1().Roles(deployOptions.Namespace).Update(existingRole); err != nil {
+			return errors.Wrap(err, "failed to update role")
+		}
+	}
+
+	_, err = clientset.RbacV1().RoleBindings(deployOptions.Namespace).Get(registryRefreshName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing role binding")
+		}
+
+		if _, err := clientset.RbacV1().RoleBindings(deployOptions.Namespace).Create(registryRefreshRoleBinding(deployOptions.Namespace)); err != nil {
+			return errors.Wrap(err, "failed to create role binding")
+		}
+	}
+
+	return nil
+}
+
+func ensureRegistryRefreshCronJob(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	provider := registryRefreshProvider(deployOptions)
+
+	existing, err := clientset.BatchV1beta1().CronJobs(deployOptions.Namespace).Get(registryRefreshName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing cronjob")
+		}
+
+		cronJob := registryRefreshCronJob(deployOptions, provider)
+		if err := applyPatches(deployOptions, cronJob); err != nil {
+			return errors.Wrap(err, "failed to patch registry refresh cronjob")
+		}
+		_, err := clientset.BatchV1beta1().CronJobs(deployOptions.Namespace).Create(cronJob)
+		if err != nil {
+			return errors.Wrap(err, "failed to create registry refresh cronjob")
+		}
+
+		return nil
+	}
+
+	// the registry, and so the provider image, can change when installing again
+	desired := registryRefreshCronJob(deployOptions, provider)
+	existing.Spec.Schedule = desired.Spec.Schedule
+	existing.Spec.JobTemplate = desired.Spec.JobTemplate
+	if _, err := clientset.BatchV1beta1().CronJobs(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update registry refresh cronjob")
+	}
+
+	return nil
+}
+
+// readRegistryCredentials returns the registry credentials that the pull secret is refreshed with,
+// or empty options if it isn't refreshed
+func readRegistryCredentials(namespace string, clientset *kubernetes.Clientset) (registry.RegistryOptions, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(registryCredentialsSecretName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return registry.RegistryOptions{}, nil
+	}
+	if err != nil {
+		return registry.RegistryOptions{}, errors.Wrap(err, "failed to get registry credentials secret")
+	}
+
+	options := registry.RegistryOptions{
+		Endpoint: string(secret.Data["endpoint"]),
+		Username: string(secret.Data["username"]),
+		Password: string(secret.Data["password"]),
+	}
+
+	return options, nil
+}
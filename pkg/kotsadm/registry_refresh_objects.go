@@ -0,0 +1,252 @@
+package kotsadm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/util"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	awsCLITag      = "2.0.10"
+	cloudSDKTag    = "290.0.1-alpine"
+	azureCLITag    = "2.5.1"
+	pullSecretName = registry.PullSecretName
+)
+
+var registryRefreshJobBackoffLimit = int32(2)
+
+// registryRefreshSchedules refresh the tokens well before they expire, ecr tokens are valid for
+// 12 hours, acr refresh tokens for 3 hours and gcr access tokens for 1 hour
+var registryRefreshSchedules = map[string]string{
+	registry.CredentialProviderECR: "0 */6 * * *",
+	registry.CredentialProviderGCR: "*/30 * * * *",
+	registry.CredentialProviderACR: "0 * * * *",
+}
+
+// registryLoginScripts set $username and $password to a token for the registry, using the cli of the cloud
+var registryLoginScripts = map[string]string{
+	registry.CredentialProviderECR: `export AWS_ACCESS_KEY_ID="$REGISTRY_USERNAME" AWS_SECRET_ACCESS_KEY="$REGISTRY_PASSWORD"
+export AWS_DEFAULT_REGION=$(echo "$REGISTRY_ENDPOINT" | cut -d. -f4)
+username=AWS
+password=$(aws ecr get-login-password)
+`,
+	registry.CredentialProviderGCR: `echo "$REGISTRY_PASSWORD" > /tmp/key.json
+gcloud auth activate-service-account --key-file=/tmp/key.json --quiet
+username=oauth2accesstoken
+password=$(gcloud auth print-access-token)
+`,
+	registry.CredentialProviderACR: `az login --service-principal --tenant "${REGISTRY_USERNAME%%/*}" --username "${REGISTRY_USERNAME#*/}" --password "$REGISTRY_PASSWORD" > /dev/null
+username=` + registry.ACRTokenUsername + `
+password=$(az acr login --name "${REGISTRY_ENDPOINT%%.*}" --expose-token --output tsv --query accessToken)
+`,
+}
+
+// registryRefreshScript writes the login to the pull secret with a merge patch, the same way
+// that registry.PullSecretForRegistries creates it
+func registryRefreshScript(secretName string) string {
+	return `auth=$(printf '%s:%s' "$username" "$password" | base64 | tr -d '\n')
+config=$(printf '{"auths":{"%s":{"auth":"%s"}}}' "$REGISTRY_ENDPOINT" "$auth" | base64 | tr -d '\n')
+serviceaccount=/var/run/secrets/kubernetes.io/serviceaccount
+curl --fail --silent --show-error --noproxy kubernetes.default.svc \
+  --cacert "$serviceaccount/ca.crt" \
+  -H "Authorization: Bearer $(cat "$serviceaccount/token")" \
+  -H "Content-Type: application/merge-patch+json" \
+  -X PATCH --data "{\"data\":{\".dockerconfigjson\":\"$config\"}}" \
+  "https://kubernetes.default.svc/api/v1/namespaces/$NAMESPACE/secrets/` + secretName + `" > /dev/null
+`
+}
+
+// instancePullSecretName is the name of the pull secret of the app instance, see midstream.SetInstanceName
+func instancePullSecretName(deployOptions DeployOptions) string {
+	return util.InstanceObjectName(deployOptions.InstanceName, pullSecretName)
+}
+
+func registryRefreshImage(provider string) string {
+	switch provider {
+	case registry.CredentialProviderECR:
+		return thirdPartyImage("amazon/aws-cli", awsCLITag)
+	case registry.CredentialProviderGCR:
+		return thirdPartyImage("google/cloud-sdk", cloudSDKTag)
+	case registry.CredentialProviderACR:
+		return thirdPartyImage("mcr.microsoft.com/azure-cli", azureCLITag)
+	}
+	return ""
+}
+
+func registryCredentialsSecret(namespace string, options registry.RegistryOptions) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registryCredentialsSecretName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Data: map[string][]byte{
+			"endpoint": []byte(options.Endpoint),
+			"username": []byte(options.Username),
+			"password": []byte(options.Password),
+		},
+	}
+
+	return secret
+}
+
+func registryRefreshServiceAccount(namespace string) *corev1.ServiceAccount {
+	serviceAccount := &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ServiceAccount",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registryRefreshName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+	}
+
+	return serviceAccount
+}
+
+// registryRefreshRole can only update the pull secret
+func registryRefreshRole(deployOptions DeployOptions) *rbacv1.Role {
+	role := &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "Role",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registryRefreshName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{instancePullSecretName(deployOptions)},
+				Verbs:         metav1.Verbs{"get", "patch"},
+			},
+		},
+	}
+
+	return role
+}
+
+func registryRefreshRoleBinding(namespace string) *rbacv1.RoleBinding {
+	roleBinding := &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "RoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registryRefreshName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      registryRefreshName,
+				Namespace: namespace,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     registryRefreshName,
+		},
+	}
+
+	return roleBinding
+}
+
+func registryRefreshCronJob(deployOptions DeployOptions, provider string) *batchv1beta1.CronJob {
+	env := []corev1.EnvVar{
+		{
+			Name: "NAMESPACE",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "metadata.namespace",
+				},
+			},
+		},
+	}
+	for _, key := range []string{"endpoint", "username", "password"} {
+		env = append(env, corev1.EnvVar{
+			Name: fmt.Sprintf("REGISTRY_%s", strings.ToUpper(key)),
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: registryCredentialsSecretName,
+					},
+					Key: key,
+				},
+			},
+		})
+	}
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				kotsadmLabelKey: kotsadmLabelValue,
+				"app":           registryRefreshName,
+			},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: registryRefreshName,
+			NodeSelector:       deployOptions.NodeSelector,
+			Tolerations:        deployOptions.Tolerations,
+			Affinity:           deployOptions.Affinity,
+			RestartPolicy:      corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Image:           registryRefreshImage(provider),
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Name:            "refresh",
+					Command:         []string{"/bin/sh", "-c", "set -e\n" + registryLoginScripts[provider] + registryRefreshScript(instancePullSecretName(deployOptions))},
+					Env:             env,
+				},
+			},
+		},
+	}
+
+	addProxy(deployOptions, &template.Spec)
+	addSidecarInjection(deployOptions, &template.ObjectMeta, &template.Spec)
+	addOpenShiftCompatibility(deployOptions, &template.Spec)
+
+	cronJob := &batchv1beta1.CronJob{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1beta1",
+			Kind:       "CronJob",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registryRefreshName,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   registryRefreshSchedules[provider],
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &backupJobsHistoryLimit,
+			FailedJobsHistoryLimit:     &backupJobsHistoryLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &registryRefreshJobBackoffLimit,
+					Template:     template,
+				},
+			},
+		},
+	}
+
+	return cronJob
+}
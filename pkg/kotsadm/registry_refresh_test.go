@@ -0,0 +1,85 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_registryRefreshCronJob(t *testing.T) {
+	tests := []struct {
+		name             string
+		credentials      registry.RegistryOptions
+		expectedProvider string
+		expectedImage    string
+		expectedSchedule string
+	}{
+		{
+			name:        "no registry",
+			credentials: registry.RegistryOptions{},
+		},
+		{
+			name:        "static credentials",
+			credentials: registry.RegistryOptions{Endpoint: "registry.example.com", Username: "user", Password: "password"},
+		},
+		{
+			name:             "ecr",
+			credentials:      registry.RegistryOptions{Endpoint: "123456789012.dkr.ecr.us-east-1.amazonaws.com", Username: "AKIA", Password: "secret"},
+			expectedProvider: registry.CredentialProviderECR,
+			expectedImage:    "amazon/aws-cli:" + awsCLITag,
+			expectedSchedule: "0 */6 * * *",
+		},
+		{
+			name:             "gcr",
+			credentials:      registry.RegistryOptions{Endpoint: "gcr.io", Username: "_json_key", Password: "{}"},
+			expectedProvider: registry.CredentialProviderGCR,
+			expectedImage:    "google/cloud-sdk:" + cloudSDKTag,
+			expectedSchedule: "*/30 * * * *",
+		},
+		{
+			name:             "acr",
+			credentials:      registry.RegistryOptions{Endpoint: "myregistry.azurecr.io", Username: "tenant/client", Password: "secret"},
+			expectedProvider: registry.CredentialProviderACR,
+			expectedImage:    "mcr.microsoft.com/azure-cli:" + azureCLITag,
+			expectedSchedule: "0 * * * *",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployOptions := DeployOptions{Namespace: "default", RegistryCredentials: test.credentials}
+
+			provider := registryRefreshProvider(deployOptions)
+			assert.Equal(t, test.expectedProvider, provider)
+			assert.Equal(t, test.expectedProvider != "", usesRegistryRefresh(deployOptions))
+			if provider == "" {
+				return
+			}
+
+			cronJob := registryRefreshCronJob(deployOptions, provider)
+			assert.Equal(t, test.expectedSchedule, cronJob.Spec.Schedule)
+
+			podSpec := cronJob.Spec.JobTemplate.Spec.Template.Spec
+			assert.Equal(t, registryRefreshName, podSpec.ServiceAccountName)
+			require.Len(t, podSpec.Containers, 1)
+			assert.Equal(t, test.expectedImage, podSpec.Containers[0].Image)
+			assert.Contains(t, podSpec.Containers[0].Command[2], "/secrets/"+pullSecretName)
+		})
+	}
+}
+
+func Test_registryRefreshRole(t *testing.T) {
+	role := registryRefreshRole(DeployOptions{Namespace: "default"})
+	require.Len(t, role.Rules, 1)
+	assert.Equal(t, []string{pullSecretName}, role.Rules[0].ResourceNames)
+	assert.Equal(t, []string{"get", "patch"}, []string(role.Rules[0].Verbs))
+
+	// the pull secret of an app instance is refreshed
+	deployOptions := DeployOptions{Namespace: "default", InstanceName: "my-app", RegistryCredentials: registry.RegistryOptions{Endpoint: "123456789012.dkr.ecr.us-east-1.amazonaws.com"}}
+	role = registryRefreshRole(deployOptions)
+	assert.Equal(t, []string{"my-app-kotsadm-replicated-registry"}, role.Rules[0].ResourceNames)
+	cronJob := registryRefreshCronJob(deployOptions, registryRefreshProvider(deployOptions))
+	assert.Contains(t, cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Command[2], "/secrets/my-app-kotsadm-replicated-registry")
+}
@@ -0,0 +1,204 @@
+package kotsadm
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	// kotsadmLabelKey is set on all objects that the admin console is deployed with, so that they
+	// can be found when it's removed
+	kotsadmLabelKey   = "kots.io/kotsadm"
+	kotsadmLabelValue = "true"
+
+	operatorClusterRoleName        = "kotsadm-operator-role"
+	operatorClusterRoleBindingName = "kotsadm-operator-rolebinding"
+)
+
+var persistentVolumeClaimResource = schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}
+
+// removableResources are the resources of the objects that are removed, in the order they're
+// deleted. Workloads go first so that nothing uses the configuration and volumes that follow.
+// The istio and openshift resources aren't served by all clusters.
+var removableResources = []schema.GroupVersionResource{
+	{Group: "batch", Version: "v1beta1", Resource: "cronjobs"},
+	{Group: "batch", Version: "v1", Resource: "jobs"},
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Version: "v1", Resource: "pods"},
+	{Group: "policy", Version: "v1beta1", Resource: "poddisruptionbudgets"},
+	{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ingresses"},
+	routeResource,
+	{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
+	peerAuthenticationResource,
+	destinationRuleResource,
+	{Version: "v1", Resource: "services"},
+	{Version: "v1", Resource: "configmaps"},
+	{Version: "v1", Resource: "secrets"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "roles"},
+	{Version: "v1", Resource: "serviceaccounts"},
+	persistentVolumeClaimResource,
+}
+
+// RemoveOptions are the options of removing the admin console from a namespace
+type RemoveOptions struct {
+	Namespace string
+
+	// PreserveVolumes keeps the persistent volume claims, with the database, the object store
+	// and the postgres backups
+	PreserveVolumes bool
+
+	// DryRun doesn't delete anything, the objects that would be deleted are returned
+	DryRun bool
+}
+
+// RemovedObject is an object that was deleted when removing the admin console
+type RemovedObject struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+func (o RemovedObject) String() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s/%s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s/%s in %s", o.Kind, o.Name, o.Namespace)
+}
+
+// Remove deletes the objects that the admin console was deployed with from the namespace, found
+// by the kots.io/kotsadm label. The namespace isn't deleted. The operator cluster role binding is
+// shared by namespaces, only the subject of the namespace is removed, and it's deleted with the
+// cluster role when no other namespace uses it. Objects created by versions that didn't label
+// them aren't found.
+func Remove(removeOptions RemoveOptions) ([]RemovedObject, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create dynamic client")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	return removeObjects(removeOptions, client, clientset)
+}
+
+func removeObjects(removeOptions RemoveOptions, client dynamic.Interface, clientset kubernetes.Interface) ([]RemovedObject, error) {
+	removed := []RemovedObject{}
+	selector := fmt.Sprintf("%s=%s", kotsadmLabelKey, kotsadmLabelValue)
+
+	// pods of jobs are orphaned by default
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := &metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	for _, resource := range removableResources {
+		if removeOptions.PreserveVolumes && resource == persistentVolumeClaimResource {
+			continue
+		}
+
+		resourceClient := client.Resource(resource).Namespace(removeOptions.Namespace)
+		list, err := resourceClient.List(metav1.ListOptions{LabelSelector: selector})
+		if kuberneteserrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list %s", resource.Resource)
+		}
+
+		for _, item := range list.Items {
+			if !removeOptions.DryRun {
+				err := resourceClient.Delete(item.GetName(), deleteOptions)
+				if err != nil && !kuberneteserrors.IsNotFound(err) {
+					return nil, errors.Wrapf(err, "failed to delete %s %s", item.GetKind(), item.GetName())
+				}
+			}
+			removed = append(removed, RemovedObject{
+				Kind:      item.GetKind(),
+				Name:      item.GetName(),
+				Namespace: removeOptions.Namespace,
+			})
+		}
+	}
+
+	removedOperatorRBAC, err := removeOperatorClusterRoleBinding(removeOptions, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to remove operator cluster role binding")
+	}
+
+	return append(removed, removedOperatorRBAC...), nil
+}
+
+// removeOperatorClusterRoleBinding removes the operator service account of the namespace from the
+// subjects of the operator cluster role binding, and deletes the binding and the cluster role
+// when there are no other subjects
+func removeOperatorClusterRoleBinding(removeOptions RemoveOptions, clientset kubernetes.Interface) ([]RemovedObject, error) {
+	clusterRoleBinding, err := clientset.RbacV1().ClusterRoleBindings().Get(operatorClusterRoleBindingName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster role binding")
+	}
+
+	found := false
+	for i, subject := range clusterRoleBinding.Subjects {
+		if subject.Kind == "ServiceAccount" && subject.Name == "kotsadm-operator" && subject.Namespace == removeOptions.Namespace {
+			clusterRoleBinding.Subjects = append(clusterRoleBinding.Subjects[:i], clusterRoleBinding.Subjects[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	if len(clusterRoleBinding.Subjects) > 0 {
+		if removeOptions.DryRun {
+			return nil, nil
+		}
+		if _, err := clientset.RbacV1().ClusterRoleBindings().Update(clusterRoleBinding); err != nil {
+			return nil, errors.Wrap(err, "failed to update cluster role binding")
+		}
+		return nil, nil
+	}
+
+	removed := []RemovedObject{
+		{Kind: "ClusterRoleBinding", Name: operatorClusterRoleBindingName},
+		{Kind: "ClusterRole", Name: operatorClusterRoleName},
+	}
+	if removeOptions.DryRun {
+		return removed, nil
+	}
+
+	err = clientset.RbacV1().ClusterRoleBindings().Delete(operatorClusterRoleBindingName, &metav1.DeleteOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return nil, errors.Wrap(err, "failed to delete cluster role binding")
+	}
+	err = clientset.RbacV1().ClusterRoles().Delete(operatorClusterRoleName, &metav1.DeleteOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return nil, errors.Wrap(err, "failed to delete cluster role")
+	}
+
+	return removed, nil
+}
+
+func kotsadmLabels() map[string]string {
+	return map[string]string{
+		kotsadmLabelKey: kotsadmLabelValue,
+	}
+}
@@ -0,0 +1,141 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testObject(apiVersion string, kind string, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetNamespace("default")
+	obj.SetLabels(labels)
+	return obj
+}
+
+// testOperatorClusterRBAC returns the operator cluster role and binding without the namespace,
+// which a cluster ignores and the fake clientset doesn't
+func testOperatorClusterRBAC(namespaces ...string) (*rbacv1.ClusterRole, *rbacv1.ClusterRoleBinding) {
+	clusterRole := operatorClusterRole(namespaces[0])
+	clusterRole.Namespace = ""
+
+	clusterRoleBinding := operatorClusterRoleBinding(namespaces[0])
+	clusterRoleBinding.Namespace = ""
+	for _, namespace := range namespaces[1:] {
+		clusterRoleBinding.Subjects = append(clusterRoleBinding.Subjects, rbacv1.Subject{
+			Kind:      "ServiceAccount",
+			Name:      "kotsadm-operator",
+			Namespace: namespace,
+		})
+	}
+
+	return clusterRole, clusterRoleBinding
+}
+
+func Test_removeObjects(t *testing.T) {
+	tests := []struct {
+		name          string
+		removeOptions RemoveOptions
+		want          []string
+		wantRemaining []string
+	}{
+		{
+			name:          "remove",
+			removeOptions: RemoveOptions{Namespace: "default"},
+			want: []string{
+				"Deployment/kotsadm-api in default",
+				"Secret/kotsadm-postgres in default",
+				"PersistentVolumeClaim/kotsadm-postgres-kotsadm-postgres-0 in default",
+				"ClusterRoleBinding/kotsadm-operator-rolebinding",
+				"ClusterRole/kotsadm-operator-role",
+			},
+			wantRemaining: []string{"app"},
+		},
+		{
+			name:          "preserve volumes",
+			removeOptions: RemoveOptions{Namespace: "default", PreserveVolumes: true},
+			want: []string{
+				"Deployment/kotsadm-api in default",
+				"Secret/kotsadm-postgres in default",
+				"ClusterRoleBinding/kotsadm-operator-rolebinding",
+				"ClusterRole/kotsadm-operator-role",
+			},
+			wantRemaining: []string{"app", "kotsadm-postgres-kotsadm-postgres-0"},
+		},
+		{
+			name:          "dry run",
+			removeOptions: RemoveOptions{Namespace: "default", DryRun: true},
+			want: []string{
+				"Deployment/kotsadm-api in default",
+				"Secret/kotsadm-postgres in default",
+				"PersistentVolumeClaim/kotsadm-postgres-kotsadm-postgres-0 in default",
+				"ClusterRoleBinding/kotsadm-operator-rolebinding",
+				"ClusterRole/kotsadm-operator-role",
+			},
+			wantRemaining: []string{"app", "kotsadm-api", "kotsadm-postgres", "kotsadm-postgres-kotsadm-postgres-0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+				testObject("apps/v1", "Deployment", "kotsadm-api", kotsadmLabels()),
+				testObject("apps/v1", "Deployment", "app", map[string]string{"app": "app"}),
+				testObject("v1", "Secret", "kotsadm-postgres", kotsadmLabels()),
+				testObject("v1", "PersistentVolumeClaim", "kotsadm-postgres-kotsadm-postgres-0", kotsadmLabels()),
+			)
+			clusterRole, clusterRoleBinding := testOperatorClusterRBAC("default")
+			clientset := fake.NewSimpleClientset(clusterRole, clusterRoleBinding)
+
+			removed, err := removeObjects(test.removeOptions, client, clientset)
+			require.NoError(t, err)
+
+			got := []string{}
+			for _, obj := range removed {
+				got = append(got, obj.String())
+			}
+			assert.Equal(t, test.want, got)
+
+			remaining := []string{}
+			for _, resource := range removableResources {
+				list, err := client.Resource(resource).Namespace("default").List(metav1.ListOptions{})
+				require.NoError(t, err)
+				for _, item := range list.Items {
+					remaining = append(remaining, item.GetName())
+				}
+			}
+			assert.ElementsMatch(t, test.wantRemaining, remaining)
+
+			_, err = clientset.RbacV1().ClusterRoles().Get(operatorClusterRoleName, metav1.GetOptions{})
+			assert.Equal(t, !test.removeOptions.DryRun, kuberneteserrors.IsNotFound(err))
+		})
+	}
+}
+
+func Test_removeOperatorClusterRoleBinding(t *testing.T) {
+	clusterRole, clusterRoleBinding := testOperatorClusterRBAC("default", "other")
+	clientset := fake.NewSimpleClientset(clusterRole, clusterRoleBinding)
+
+	removed, err := removeOperatorClusterRoleBinding(RemoveOptions{Namespace: "default"}, clientset)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+
+	updated, err := clientset.RbacV1().ClusterRoleBindings().Get(operatorClusterRoleBindingName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, updated.Subjects, 1)
+	assert.Equal(t, "other", updated.Subjects[0].Namespace)
+
+	_, err = clientset.RbacV1().ClusterRoles().Get(operatorClusterRoleName, metav1.GetOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,120 @@
+package kotsadm
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// replicatedComponents are the components that can run more than one pod, postgres, minio and the
+// operator always run one
+var replicatedComponents = []string{"kotsadm-api", "kotsadm-web"}
+
+func validateReplicaOptions(deployOptions DeployOptions) error {
+	if deployOptions.APIReplicas < 0 {
+		return errors.Errorf("invalid api replicas %d", deployOptions.APIReplicas)
+	}
+	if deployOptions.WebReplicas < 0 {
+		return errors.Errorf("invalid web replicas %d", deployOptions.WebReplicas)
+	}
+	return nil
+}
+
+// componentReplicas returns the number of pods of a replicated component, one when it isn't set
+func componentReplicas(deployOptions DeployOptions, component string) int32 {
+	replicas := int32(0)
+	switch component {
+	case "kotsadm-api":
+		replicas = deployOptions.APIReplicas
+	case "kotsadm-web":
+		replicas = deployOptions.WebReplicas
+	}
+
+	if replicas == 0 {
+		return 1
+	}
+	return replicas
+}
+
+// usesPodDisruptionBudget is true for the components with more than one pod, a budget for a single
+// pod would either block drains or not keep anything running
+func usesPodDisruptionBudget(deployOptions DeployOptions, component string) bool {
+	return componentReplicas(deployOptions, component) > 1
+}
+
+func getPodDisruptionBudgetYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	for _, component := range replicatedComponents {
+		if !usesPodDisruptionBudget(deployOptions, component) {
+			continue
+		}
+
+		var pdb bytes.Buffer
+		if err := s.Encode(podDisruptionBudget(deployOptions.Namespace, component), &pdb); err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal %s pod disruption budget", component)
+		}
+		docs[component[len("kotsadm-"):]+"-pdb.yaml"] = pdb.Bytes()
+	}
+
+	return docs, nil
+}
+
+func ensurePodDisruptionBudgets(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	for _, component := range replicatedComponents {
+		if !usesPodDisruptionBudget(deployOptions, component) {
+			continue
+		}
+
+		_, err := clientset.PolicyV1beta1().PodDisruptionBudgets(deployOptions.Namespace).Get(component, metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to get existing %s pod disruption budget", component)
+		}
+
+		pdb := podDisruptionBudget(deployOptions.Namespace, component)
+		if err := applyPatches(deployOptions, pdb); err != nil {
+			return errors.Wrapf(err, "failed to patch %s pod disruption budget", component)
+		}
+		if _, err := clientset.PolicyV1beta1().PodDisruptionBudgets(deployOptions.Namespace).Create(pdb); err != nil {
+			return errors.Wrapf(err, "failed to create %s pod disruption budget", component)
+		}
+	}
+
+	return nil
+}
+
+// readReplicaOptions returns the replicas of the api and web deployments, and whether the
+// affinity of the api pods is the one generated for its replicas rather than a custom affinity
+func readReplicaOptions(namespace string, clientset *kubernetes.Clientset) (int32, int32, bool, error) {
+	replicas := map[string]int32{}
+	generatedAffinity := false
+	for _, component := range replicatedComponents {
+		deployment, err := clientset.AppsV1().Deployments(namespace).Get(component, metav1.GetOptions{})
+		if kuberneteserrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return 0, 0, false, errors.Wrapf(err, "failed to get %s deployment", component)
+		}
+		if deployment.Spec.Replicas != nil {
+			replicas[component] = *deployment.Spec.Replicas
+		}
+
+		if component == "kotsadm-api" {
+			generated := componentAffinity(DeployOptions{APIReplicas: replicas[component]}, component)
+			generatedAffinity = generated != nil && reflect.DeepEqual(generated, deployment.Spec.Template.Spec.Affinity)
+		}
+	}
+
+	return replicas["kotsadm-api"], replicas["kotsadm-web"], generatedAffinity, nil
+}
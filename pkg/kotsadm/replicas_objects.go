@@ -0,0 +1,62 @@
+package kotsadm
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// podDisruptionBudget lets nodes be drained one pod of the component at a time
+func podDisruptionBudget(namespace string, component string) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "policy/v1beta1",
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      component,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": component,
+				},
+			},
+		},
+	}
+
+	return pdb
+}
+
+// componentAffinity prefers to schedule the pods of a component with more than one replica on
+// different nodes, so that draining a node doesn't stop all of them. The Affinity of the deploy
+// options is used instead when it's set.
+func componentAffinity(deployOptions DeployOptions, component string) *corev1.Affinity {
+	if deployOptions.Affinity != nil || componentReplicas(deployOptions, component) < 2 {
+		return deployOptions.Affinity
+	}
+
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						TopologyKey: corev1.LabelHostname,
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								"app": component,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_componentReplicas(t *testing.T) {
+	tests := []struct {
+		name          string
+		deployOptions DeployOptions
+		component     string
+		want          int32
+		wantPDB       bool
+	}{
+		{
+			name:          "default",
+			deployOptions: DeployOptions{},
+			component:     "kotsadm-api",
+			want:          1,
+		},
+		{
+			name:          "api replicas",
+			deployOptions: DeployOptions{APIReplicas: 3},
+			component:     "kotsadm-api",
+			want:          3,
+			wantPDB:       true,
+		},
+		{
+			name:          "web replicas",
+			deployOptions: DeployOptions{APIReplicas: 3, WebReplicas: 2},
+			component:     "kotsadm-web",
+			want:          2,
+			wantPDB:       true,
+		},
+		{
+			name:          "single replica",
+			deployOptions: DeployOptions{WebReplicas: 1},
+			component:     "kotsadm-web",
+			want:          1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, componentReplicas(test.deployOptions, test.component))
+			assert.Equal(t, test.wantPDB, usesPodDisruptionBudget(test.deployOptions, test.component))
+		})
+	}
+}
+
+func Test_componentAffinity(t *testing.T) {
+	assert.Nil(t, componentAffinity(DeployOptions{}, "kotsadm-api"))
+
+	affinity := componentAffinity(DeployOptions{APIReplicas: 2}, "kotsadm-api")
+	term := affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm
+	assert.Equal(t, corev1.LabelHostname, term.TopologyKey)
+	assert.Equal(t, "kotsadm-api", term.LabelSelector.MatchLabels["app"])
+
+	custom := &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}}
+	assert.Equal(t, custom, componentAffinity(DeployOptions{APIReplicas: 2, Affinity: custom}, "kotsadm-api"))
+
+	deployment := apiDeployment(DeployOptions{APIReplicas: 2})
+	assert.Equal(t, int32(2), *deployment.Spec.Replicas)
+	assert.NotNil(t, deployment.Spec.Template.Spec.Affinity)
+
+	pdb := podDisruptionBudget("default", "kotsadm-web")
+	assert.Equal(t, 1, pdb.Spec.MaxUnavailable.IntValue())
+	assert.Equal(t, "kotsadm-web", pdb.Spec.Selector.MatchLabels["app"])
+}
+
+func Test_validateReplicaOptions(t *testing.T) {
+	assert.NoError(t, validateReplicaOptions(DeployOptions{APIReplicas: 3}))
+	assert.Error(t, validateReplicaOptions(DeployOptions{APIReplicas: -1}))
+	assert.Error(t, validateReplicaOptions(DeployOptions{WebReplicas: -1}))
+}
@@ -0,0 +1,134 @@
+package kotsadm
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	defaultPostgresStorageSize = "1Gi"
+	defaultMinioStorageSize    = "4Gi"
+)
+
+// ComponentResources are the cpu and memory requests and limits of each admin console
+// component. Containers of components that are not set have no requests or limits.
+type ComponentResources struct {
+	API      corev1.ResourceRequirements
+	Web      corev1.ResourceRequirements
+	Operator corev1.ResourceRequirements
+	Postgres corev1.ResourceRequirements
+	Minio    corev1.ResourceRequirements
+}
+
+// ParseComponentResources parses values in the form <component>.<requests|limits>.<cpu|memory>=<quantity>,
+// for example "postgres.requests.memory=512Mi"
+func ParseComponentResources(values []string) (ComponentResources, error) {
+	resources := ComponentResources{}
+
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			return ComponentResources{}, errors.Errorf("invalid resource %q, expected <component>.<requests|limits>.<cpu|memory>=<quantity>", value)
+		}
+
+		parts := strings.Split(kv[0], ".")
+		if len(parts) != 3 {
+			return ComponentResources{}, errors.Errorf("invalid resource %q, expected <component>.<requests|limits>.<cpu|memory>=<quantity>", value)
+		}
+
+		requirements := resources.forComponent(parts[0])
+		if requirements == nil {
+			return ComponentResources{}, errors.Errorf("unknown component %q in resource %q", parts[0], value)
+		}
+
+		var name corev1.ResourceName
+		switch parts[2] {
+		case "cpu":
+			name = corev1.ResourceCPU
+		case "memory":
+			name = corev1.ResourceMemory
+		default:
+			return ComponentResources{}, errors.Errorf("unknown resource %q in resource %q", parts[2], value)
+		}
+
+		quantity, err := resource.ParseQuantity(kv[1])
+		if err != nil {
+			return ComponentResources{}, errors.Wrapf(err, "failed to parse quantity in resource %q", value)
+		}
+
+		switch parts[1] {
+		case "requests":
+			if requirements.Requests == nil {
+				requirements.Requests = corev1.ResourceList{}
+			}
+			requirements.Requests[name] = quantity
+		case "limits":
+			if requirements.Limits == nil {
+				requirements.Limits = corev1.ResourceList{}
+			}
+			requirements.Limits[name] = quantity
+		default:
+			return ComponentResources{}, errors.Errorf("expected requests or limits in resource %q", value)
+		}
+	}
+
+	return resources, nil
+}
+
+func (r *ComponentResources) forComponent(component string) *corev1.ResourceRequirements {
+	switch component {
+	case "api":
+		return &r.API
+	case "web":
+		return &r.Web
+	case "operator":
+		return &r.Operator
+	case "postgres":
+		return &r.Postgres
+	case "minio":
+		return &r.Minio
+	}
+	return nil
+}
+
+func validateStorageOptions(deployOptions DeployOptions) error {
+	for name, size := range map[string]string{
+		"postgres": deployOptions.PostgresStorageSize,
+		"minio":    deployOptions.MinioStorageSize,
+	} {
+		if size == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(size); err != nil {
+			return errors.Wrapf(err, "invalid %s storage size %q", name, size)
+		}
+	}
+
+	return nil
+}
+
+// volumeClaimSpec returns the spec of a ReadWriteOnce claim of the size, or the default size when not set
+func volumeClaimSpec(size string, defaultSize string, storageClassName string) corev1.PersistentVolumeClaimSpec {
+	if size == "" {
+		size = defaultSize
+	}
+
+	spec := corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{
+			corev1.ReadWriteOnce,
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceName(corev1.ResourceStorage): resource.MustParse(size),
+			},
+		},
+	}
+	if storageClassName != "" {
+		spec.StorageClassName = &storageClassName
+	}
+
+	return spec
+}
@@ -0,0 +1,111 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func Test_ParseComponentResources(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []string
+		expected    ComponentResources
+		expectError bool
+	}{
+		{
+			name:     "none",
+			values:   []string{},
+			expected: ComponentResources{},
+		},
+		{
+			name: "requests and limits",
+			values: []string{
+				"postgres.requests.memory=512Mi",
+				"postgres.limits.memory=1Gi",
+				"api.requests.cpu=100m",
+			},
+			expected: ComponentResources{
+				Postgres: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("512Mi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("1Gi"),
+					},
+				},
+				API: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("100m"),
+					},
+				},
+			},
+		},
+		{
+			name:        "unknown component",
+			values:      []string{"redis.requests.cpu=100m"},
+			expectError: true,
+		},
+		{
+			name:        "unknown resource",
+			values:      []string{"web.requests.storage=1Gi"},
+			expectError: true,
+		},
+		{
+			name:        "invalid quantity",
+			values:      []string{"web.limits.cpu=lots"},
+			expectError: true,
+		},
+		{
+			name:        "missing quantity",
+			values:      []string{"web.limits.cpu"},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := ParseComponentResources(test.values)
+			if test.expectError {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_postgresStatefulsetStorage(t *testing.T) {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+	}
+
+	statefulset := postgresStatefulset(DeployOptions{Namespace: "default"})
+	claim := statefulset.Spec.VolumeClaimTemplates[0].Spec
+	assert.Equal(t, resource.MustParse("1Gi"), claim.Resources.Requests[corev1.ResourceStorage])
+	assert.Nil(t, claim.StorageClassName)
+	assert.Equal(t, corev1.ResourceRequirements{}, statefulset.Spec.Template.Spec.Containers[0].Resources)
+
+	statefulset = postgresStatefulset(DeployOptions{
+		Namespace:           "default",
+		PostgresStorageSize: "10Gi",
+		StorageClassName:    "fast",
+		Resources:           ComponentResources{Postgres: resources},
+	})
+	claim = statefulset.Spec.VolumeClaimTemplates[0].Spec
+	assert.Equal(t, resource.MustParse("10Gi"), claim.Resources.Requests[corev1.ResourceStorage])
+	require.NotNil(t, claim.StorageClassName)
+	assert.Equal(t, "fast", *claim.StorageClassName)
+	assert.Equal(t, resources, statefulset.Spec.Template.Spec.Containers[0].Resources)
+
+	assert.Error(t, validateStorageOptions(DeployOptions{MinioStorageSize: "big"}))
+	assert.NoError(t, validateStorageOptions(DeployOptions{MinioStorageSize: "8Gi"}))
+}
@@ -0,0 +1,88 @@
+package kotsadm
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ParseNodeSelector parses values in the form key=value
+func ParseNodeSelector(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	nodeSelector := map[string]string{}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("invalid node selector %q, expected key=value", value)
+		}
+		nodeSelector[kv[0]] = kv[1]
+	}
+
+	return nodeSelector, nil
+}
+
+// ParseTolerations parses values in the same form as kubectl taint, key[=value]:effect.
+// The effect can be left empty to tolerate all effects of the taint.
+func ParseTolerations(values []string) ([]corev1.Toleration, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	tolerations := []corev1.Toleration{}
+	for _, value := range values {
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid toleration %q, expected key[=value]:effect", value)
+		}
+
+		toleration := corev1.Toleration{
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffect(parts[1]),
+		}
+
+		kv := strings.SplitN(parts[0], "=", 2)
+		toleration.Key = kv[0]
+		if len(kv) == 2 {
+			toleration.Operator = corev1.TolerationOpEqual
+			toleration.Value = kv[1]
+		}
+		if toleration.Key == "" {
+			return nil, errors.Errorf("invalid toleration %q, key is required", value)
+		}
+
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, errors.Errorf("invalid toleration %q, unknown effect %q", value, toleration.Effect)
+		}
+
+		tolerations = append(tolerations, toleration)
+	}
+
+	return tolerations, nil
+}
+
+// LoadAffinity reads an affinity from a yaml file
+func LoadAffinity(filename string) (*corev1.Affinity, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read affinity file")
+	}
+
+	affinity := corev1.Affinity{}
+	if err := yaml.Unmarshal(content, &affinity); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal affinity")
+	}
+
+	return &affinity, nil
+}
@@ -0,0 +1,149 @@
+package kotsadm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_ParseTolerations(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []string
+		expected    []corev1.Toleration
+		expectError bool
+	}{
+		{
+			name:     "none",
+			values:   []string{},
+			expected: nil,
+		},
+		{
+			name:   "key value and effect",
+			values: []string{"dedicated=kotsadm:NoSchedule"},
+			expected: []corev1.Toleration{
+				{
+					Key:      "dedicated",
+					Operator: corev1.TolerationOpEqual,
+					Value:    "kotsadm",
+					Effect:   corev1.TaintEffectNoSchedule,
+				},
+			},
+		},
+		{
+			name:   "key only, all effects",
+			values: []string{"dedicated:"},
+			expected: []corev1.Toleration{
+				{
+					Key:      "dedicated",
+					Operator: corev1.TolerationOpExists,
+				},
+			},
+		},
+		{
+			name:        "missing effect separator",
+			values:      []string{"dedicated=kotsadm"},
+			expectError: true,
+		},
+		{
+			name:        "unknown effect",
+			values:      []string{"dedicated=kotsadm:Never"},
+			expectError: true,
+		},
+		{
+			name:        "missing key",
+			values:      []string{"=kotsadm:NoSchedule"},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := ParseTolerations(test.values)
+			if test.expectError {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_ParseNodeSelector(t *testing.T) {
+	nodeSelector, err := ParseNodeSelector([]string{"node-role=admin", "zone=a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"node-role": "admin", "zone": "a=b"}, nodeSelector)
+
+	_, err = ParseNodeSelector([]string{"node-role"})
+	assert.Error(t, err)
+}
+
+func Test_LoadAffinity(t *testing.T) {
+	req := require.New(t)
+
+	f, err := ioutil.TempFile("", "affinity")
+	req.NoError(err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`nodeAffinity:
+  requiredDuringSchedulingIgnoredDuringExecution:
+    nodeSelectorTerms:
+    - matchExpressions:
+      - key: kubernetes.io/os
+        operator: In
+        values:
+        - linux
+`)
+	req.NoError(err)
+	req.NoError(f.Close())
+
+	affinity, err := LoadAffinity(f.Name())
+	req.NoError(err)
+	req.NotNil(affinity.NodeAffinity)
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	req.Len(terms, 1)
+	assert.Equal(t, "kubernetes.io/os", terms[0].MatchExpressions[0].Key)
+
+	affinity, err = LoadAffinity("")
+	req.NoError(err)
+	assert.Nil(t, affinity)
+}
+
+func Test_podSpecScheduling(t *testing.T) {
+	deployOptions := DeployOptions{
+		Namespace:    "default",
+		NodeSelector: map[string]string{"node-role": "admin"},
+		Tolerations: []corev1.Toleration{
+			{
+				Key:      "dedicated",
+				Operator: corev1.TolerationOpExists,
+			},
+		},
+		Affinity: &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{},
+		},
+	}
+
+	podSpecs := map[string]corev1.PodSpec{
+		"postgres":           postgresStatefulset(deployOptions).Spec.Template.Spec,
+		"minio":              minioStatefulset(deployOptions).Spec.Template.Spec,
+		"api":                apiDeployment(deployOptions).Spec.Template.Spec,
+		"web":                webDeployment(deployOptions).Spec.Template.Spec,
+		"operator":           operatorDeployment(deployOptions).Spec.Template.Spec,
+		"migrations":         migrationsPod(deployOptions).Spec,
+		"postgres-preflight": postgresPreflightPod(deployOptions).Spec,
+	}
+
+	for name, podSpec := range podSpecs {
+		assert.Equal(t, deployOptions.NodeSelector, podSpec.NodeSelector, name)
+		assert.Equal(t, deployOptions.Tolerations, podSpec.Tolerations, name)
+		assert.Equal(t, deployOptions.Affinity, podSpec.Affinity, name)
+	}
+}
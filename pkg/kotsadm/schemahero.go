@@ -2,10 +2,10 @@ package kotsadm
 
 import (
 	"bytes"
+	"context"
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/replicatedhq/kots/pkg/logger"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
@@ -31,13 +31,16 @@ func runSchemaHeroMigrations(deployOptions DeployOptions, clientset *kubernetes.
 	// a priv. so we just deploy database migrations here, at deployment time
 
 	// find a ready postgres container
-	log := logger.NewLogger()
-	log.ChildActionWithSpinner("Waiting for datastore to be ready")
-	_, err := waitForHealthyPostgres(deployOptions.Namespace, clientset)
-	if err != nil {
-		return errors.Wrap(err, "failed to find healthy postgres pod")
+	// an external database has already been checked by the preflight
+	if !usesExternalPostgres(deployOptions) {
+		log := deployOptions.newLogger()
+		log.ChildActionWithSpinner("Waiting for datastore to be ready")
+		_, err := waitForHealthyPostgres(deployOptions.context(), deployOptions.Namespace, deployOptions.TuningProfile.timeout(time.Minute), clientset)
+		if err != nil {
+			return errors.Wrap(err, "failed to find healthy postgres pod")
+		}
+		log.FinishChildSpinner()
 	}
-	log.FinishChildSpinner()
 
 	// Deploy the migration pod with an informer attached to clean it up
 	if err := createSchemaHeroPod(deployOptions, clientset); err != nil {
@@ -47,7 +50,7 @@ func runSchemaHeroMigrations(deployOptions DeployOptions, clientset *kubernetes.
 	return nil
 }
 
-func waitForHealthyPostgres(namespace string, clientset *kubernetes.Clientset) (string, error) {
+func waitForHealthyPostgres(ctx context.Context, namespace string, timeout time.Duration, clientset *kubernetes.Clientset) (string, error) {
 	start := time.Now()
 
 	for {
@@ -62,16 +65,22 @@ func waitForHealthyPostgres(namespace string, clientset *kubernetes.Clientset) (
 			}
 		}
 
-		time.Sleep(time.Second)
+		if err := waitToPoll(ctx); err != nil {
+			return "", err
+		}
 
-		if time.Now().Sub(start) > time.Duration(time.Minute) {
+		if time.Now().Sub(start) > timeout {
 			return "", errors.New("timeout waiting for postgres pod")
 		}
 	}
 }
 
 func createSchemaHeroPod(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
-	_, err := clientset.CoreV1().Pods(deployOptions.Namespace).Create(migrationsPod(deployOptions))
+	pod := migrationsPod(deployOptions)
+	if err := applyPatches(deployOptions, pod); err != nil {
+		return errors.Wrap(err, "failed to patch migrations pod")
+	}
+	_, err := clientset.CoreV1().Pods(deployOptions.Namespace).Create(pod)
 	if err != nil {
 		return errors.Wrap(err, "failed to create pod")
 	}
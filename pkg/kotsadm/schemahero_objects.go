@@ -21,8 +21,15 @@ func migrationsPod(deployOptions DeployOptions) *corev1.Pod {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: deployOptions.Namespace,
+			Labels: map[string]string{
+				kotsadmLabelKey: kotsadmLabelValue,
+				"app":           "kotsadm-migrations",
+			},
 		},
 		Spec: corev1.PodSpec{
+			NodeSelector: deployOptions.NodeSelector,
+			Tolerations:  deployOptions.Tolerations,
+			Affinity:     deployOptions.Affinity,
 			SecurityContext: &corev1.PodSecurityContext{
 				RunAsUser: util.IntPointer(1001),
 				FSGroup:   util.IntPointer(1001),
@@ -30,7 +37,7 @@ func migrationsPod(deployOptions DeployOptions) *corev1.Pod {
 			RestartPolicy: corev1.RestartPolicyOnFailure,
 			Containers: []corev1.Container{
 				{
-					Image:           fmt.Sprintf("%s/kotsadm-migrations:%s", kotsadmRegistry(), kotsadmTag()),
+					Image:           kotsadmImage("kotsadm-migrations"),
 					ImagePullPolicy: corev1.PullAlways,
 					Name:            name,
 					Env: []corev1.EnvVar{
@@ -59,5 +66,9 @@ func migrationsPod(deployOptions DeployOptions) *corev1.Pod {
 		},
 	}
 
+	addProxy(deployOptions, &pod.Spec)
+	addSidecarInjection(deployOptions, &pod.ObjectMeta, &pod.Spec)
+	addOpenShiftCompatibility(deployOptions, &pod.Spec)
+
 	return pod
 }
@@ -2,12 +2,11 @@ package kotsadm
 
 import (
 	"bytes"
-	"os"
 
 	"github.com/google/uuid"
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/prompt"
 	"golang.org/x/crypto/bcrypt"
 	corev1 "k8s.io/api/core/v1"
 	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
@@ -27,13 +26,27 @@ func getSecretsYAML(deployOptions *DeployOptions) (map[string][]byte, error) {
 	}
 	docs["secret-jwt.yaml"] = jwt.Bytes()
 
+	postgresSecret := pgSecret(deployOptions.Namespace, deployOptions.PostgresPassword, postgresSSLMode(*deployOptions))
+	if deployOptions.ExternalPostgresSecretName != "" {
+		return nil, errors.New("an external postgres secret can only be used when deploying to a cluster, set the external postgres uri instead")
+	} else if deployOptions.ExternalPostgresURI != "" {
+		uri, err := postgresURIWithSSLMode(deployOptions.ExternalPostgresURI, deployOptions.ExternalPostgresSSLMode)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get external postgres uri")
+		}
+		postgresSecret = externalPgSecret(deployOptions.Namespace, uri)
+	}
 	var pg bytes.Buffer
-	if err := s.Encode(pgSecret(deployOptions.Namespace, deployOptions.PostgresPassword), &pg); err != nil {
+	if err := s.Encode(postgresSecret, &pg); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal pg secret")
 	}
 	docs["secret-pg.yaml"] = pg.Bytes()
 
 	if deployOptions.SharedPasswordBcrypt == "" {
+		if deployOptions.SharedPassword == "" && usesIdentity(*deployOptions) {
+			// logins go through the identity provider, nobody needs to know the password
+			deployOptions.SharedPassword = uuid.New().String()
+		}
 		bcryptPassword, err := bcrypt.GenerateFromPassword([]byte(deployOptions.SharedPassword), 10)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to bcrypt shared password")
@@ -60,14 +73,20 @@ func getSecretsYAML(deployOptions *DeployOptions) (map[string][]byte, error) {
 	docs["secret-api-encryption.yaml"] = apiEncryptionBuffer.Bytes()
 
 	var s3 bytes.Buffer
-	if deployOptions.S3SecretKey == "" {
-		deployOptions.S3SecretKey = uuid.New().String()
-	}
-	if deployOptions.S3AccessKey == "" {
-		deployOptions.S3AccessKey = uuid.New().String()
-	}
-	if err := s.Encode(s3Secret(deployOptions.Namespace, deployOptions.S3AccessKey, deployOptions.S3SecretKey), &s3); err != nil {
-		return nil, errors.Wrap(err, "failed to marshal s3 secret")
+	if usesExternalObjectStore(*deployOptions) {
+		if err := s.Encode(externalObjectStoreSecret(*deployOptions, deployOptions.S3AccessKey, deployOptions.S3SecretKey), &s3); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal s3 secret")
+		}
+	} else {
+		if deployOptions.S3SecretKey == "" {
+			deployOptions.S3SecretKey = uuid.New().String()
+		}
+		if deployOptions.S3AccessKey == "" {
+			deployOptions.S3AccessKey = uuid.New().String()
+		}
+		if err := s.Encode(s3Secret(deployOptions.Namespace, deployOptions.S3AccessKey, deployOptions.S3SecretKey), &s3); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal s3 secret")
+		}
 	}
 	docs["secret-s3.yaml"] = s3.Bytes()
 
@@ -89,8 +108,11 @@ func ensureSecrets(deployOptions *DeployOptions, clientset *kubernetes.Clientset
 		}
 	}
 
-	if err := ensureS3Secret(deployOptions.Namespace, clientset); err != nil {
-		return errors.Wrap(err, "failed to ensure s3 secret")
+	// the secret of an external object store is created with the keys when it's ensured
+	if !usesExternalObjectStore(*deployOptions) {
+		if err := ensureS3Secret(deployOptions.Namespace, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure s3 secret")
+		}
 	}
 
 	if err := ensureAPIEncryptionSecret(deployOptions, clientset); err != nil {
@@ -172,13 +194,18 @@ func getPostgresSecret(namespace string, clientset *kubernetes.Clientset) (*core
 }
 
 func ensurePostgresSecret(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	// the secret for an external database is written by ensureExternalPostgres
+	if usesExternalPostgres(deployOptions) {
+		return nil
+	}
+
 	existingPgSecret, err := getPostgresSecret(deployOptions.Namespace, clientset)
 	if err != nil {
 		return errors.Wrap(err, "failed to check for existing postgres secret")
 	}
 
 	if existingPgSecret == nil {
-		_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Create(pgSecret(deployOptions.Namespace, deployOptions.PostgresPassword))
+		_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Create(pgSecret(deployOptions.Namespace, deployOptions.PostgresPassword, postgresSSLMode(deployOptions)))
 		if err != nil {
 			return errors.Wrap(err, "failed to create postgres secret")
 		}
@@ -201,8 +228,11 @@ func getSharedPasswordSecret(namespace string, clientset *kubernetes.Clientset)
 }
 
 func ensureSharedPasswordSecret(deployOptions *DeployOptions, clientset *kubernetes.Clientset) error {
-	if deployOptions.SharedPassword == "" {
-		sharedPassword, err := promptForSharedPassword()
+	if deployOptions.SharedPassword == "" && usesIdentity(*deployOptions) {
+		// logins go through the identity provider, nobody needs to know the password
+		deployOptions.SharedPassword = uuid.New().String()
+	} else if deployOptions.SharedPassword == "" {
+		sharedPassword, err := promptForSharedPassword(prompt.OrTerminal(deployOptions.Prompter))
 		if err != nil {
 			return errors.Wrap(err, "failed to prompt for shared password")
 		}
@@ -231,18 +261,10 @@ func ensureSharedPasswordSecret(deployOptions *DeployOptions, clientset *kuberne
 	return nil
 }
 
-func promptForSharedPassword() (string, error) {
-	templates := &promptui.PromptTemplates{
-		Prompt:  "{{ . | bold }} ",
-		Valid:   "{{ . | green }} ",
-		Invalid: "{{ . | red }} ",
-		Success: "{{ . | bold }} ",
-	}
-
-	prompt := promptui.Prompt{
-		Label:     "Enter a new password to be used for the Admin Console:",
-		Templates: templates,
-		Mask:      rune('•'),
+func promptForSharedPassword(prompter prompt.Prompter) (string, error) {
+	return prompter.Input(prompt.Input{
+		Label: "Enter a new password to be used for the Admin Console:",
+		Mask:  true,
 		Validate: func(input string) error {
 			if len(input) < 6 {
 				return errors.New("please enter a longer password")
@@ -250,20 +272,7 @@ func promptForSharedPassword() (string, error) {
 
 			return nil
 		},
-	}
-
-	for {
-		result, err := prompt.Run()
-		if err != nil {
-			if err == promptui.ErrInterrupt {
-				os.Exit(-1)
-			}
-			continue
-		}
-
-		return result, nil
-	}
-
+	})
 }
 
 func ensureAPIEncryptionSecret(deployOptions *DeployOptions, clientset *kubernetes.Clientset) error {
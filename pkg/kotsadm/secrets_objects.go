@@ -21,6 +21,7 @@ func jwtSecret(namespace string, jwt string) *corev1.Secret {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-session",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Data: map[string][]byte{
 			"key": []byte(jwt),
@@ -30,7 +31,7 @@ func jwtSecret(namespace string, jwt string) *corev1.Secret {
 	return secret
 }
 
-func pgSecret(namespace string, password string) *corev1.Secret {
+func pgSecret(namespace string, password string, sslMode string) *corev1.Secret {
 	if password == "" {
 		password = uuid.New().String()
 	}
@@ -43,9 +44,10 @@ func pgSecret(namespace string, password string) *corev1.Secret {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-postgres",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Data: map[string][]byte{
-			"uri":      []byte(fmt.Sprintf("postgresql://kotsadm:%s@kotsadm-postgres/kotsadm?connect_timeout=10&sslmode=disable", password)),
+			"uri":      []byte(fmt.Sprintf("postgresql://kotsadm:%s@kotsadm-postgres/kotsadm?connect_timeout=10&sslmode=%s", password, sslMode)),
 			"password": []byte(password),
 		},
 	}
@@ -62,6 +64,7 @@ func sharedPasswordSecret(namespace string, bcryptPassword string) *corev1.Secre
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-password",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Data: map[string][]byte{
 			"passwordBcrypt": []byte(bcryptPassword),
@@ -80,6 +83,7 @@ func s3Secret(namespace string, accessKey string, secretKey string) *corev1.Secr
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-minio",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Data: map[string][]byte{
 			"accesskey": []byte(accessKey),
@@ -99,6 +103,7 @@ func apiEncryptionKeySecret(namespace string, key string) *corev1.Secret {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-encryption",
 			Namespace: namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Data: map[string][]byte{
 			"encryptionKey": []byte(key),
@@ -0,0 +1,105 @@
+package kotsadm
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var serviceTypes = []string{"ClusterIP", "NodePort", "LoadBalancer"}
+
+// ParseServiceAnnotations parses values in the form key=value
+func ParseServiceAnnotations(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	annotations := map[string]string{}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("invalid annotation %q, expected key=value", value)
+		}
+		annotations[kv[0]] = kv[1]
+	}
+
+	return annotations, nil
+}
+
+func validateServiceOptions(deployOptions DeployOptions) error {
+	if err := validateService(deployOptions.ServiceType, deployOptions.NodePort); err != nil {
+		return errors.Wrap(err, "invalid web service")
+	}
+	if err := validateService(deployOptions.APIServiceType, deployOptions.APINodePort); err != nil {
+		return errors.Wrap(err, "invalid api service")
+	}
+
+	return nil
+}
+
+func validateService(serviceType string, nodePort int32) error {
+	if serviceType != "" {
+		valid := false
+		for _, t := range serviceTypes {
+			if serviceType == t {
+				valid = true
+			}
+		}
+		if !valid {
+			return errors.Errorf("unknown service type %q, must be one of %s", serviceType, strings.Join(serviceTypes, ", "))
+		}
+	}
+
+	if nodePort == 0 {
+		return nil
+	}
+	if nodePort < 0 || nodePort > 65535 {
+		return errors.Errorf("invalid node port %d", nodePort)
+	}
+	// load balancers are also exposed on a node port, so it can be fixed for both
+	if serviceType != "NodePort" && serviceType != "LoadBalancer" {
+		return errors.New("a node port can only be set for NodePort and LoadBalancer services")
+	}
+
+	return nil
+}
+
+// serviceSpec returns the type and ports of a service, with nodePort set on the first port
+func serviceSpec(serviceType string, nodePort int32, ports []corev1.ServicePort) (corev1.ServiceType, []corev1.ServicePort) {
+	specType := corev1.ServiceTypeClusterIP
+	switch serviceType {
+	case "NodePort":
+		specType = corev1.ServiceTypeNodePort
+	case "LoadBalancer":
+		specType = corev1.ServiceTypeLoadBalancer
+	}
+
+	if specType != corev1.ServiceTypeClusterIP && len(ports) > 0 {
+		ports[0].NodePort = nodePort
+	}
+
+	return specType, ports
+}
+
+// readServiceOptions returns the type, node port and annotations of an existing service,
+// and an empty type if the service doesn't exist
+func readServiceOptions(namespace string, name string, clientset kubernetes.Interface) (string, int32, map[string]string, error) {
+	service, err := clientset.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return "", 0, nil, nil
+	}
+	if err != nil {
+		return "", 0, nil, errors.Wrapf(err, "failed to get service %s", name)
+	}
+
+	nodePort := int32(0)
+	if len(service.Spec.Ports) > 0 {
+		nodePort = service.Spec.Ports[0].NodePort
+	}
+
+	return string(service.Spec.Type), nodePort, service.Annotations, nil
+}
@@ -0,0 +1,108 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_validateService(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceType string
+		nodePort    int32
+		expectError bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:        "cluster ip",
+			serviceType: "ClusterIP",
+		},
+		{
+			name:        "fixed node port",
+			serviceType: "NodePort",
+			nodePort:    30880,
+		},
+		{
+			name:        "load balancer with node port",
+			serviceType: "LoadBalancer",
+			nodePort:    30880,
+		},
+		{
+			name:        "unknown type",
+			serviceType: "ExternalName",
+			expectError: true,
+		},
+		{
+			name:        "node port on cluster ip",
+			serviceType: "ClusterIP",
+			nodePort:    30880,
+			expectError: true,
+		},
+		{
+			name:        "invalid node port",
+			serviceType: "NodePort",
+			nodePort:    70000,
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateService(test.serviceType, test.nodePort)
+			if test.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_ParseServiceAnnotations(t *testing.T) {
+	annotations, err := ParseServiceAnnotations([]string{"service.beta.kubernetes.io/aws-load-balancer-internal=true", "a=b=c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "true", "a": "b=c"}, annotations)
+
+	_, err = ParseServiceAnnotations([]string{"invalid"})
+	assert.Error(t, err)
+}
+
+func Test_services(t *testing.T) {
+	deployOptions := DeployOptions{
+		Namespace:             "default",
+		ServiceType:           "NodePort",
+		NodePort:              30880,
+		APIServiceType:        "LoadBalancer",
+		APINodePort:           30881,
+		APIServiceAnnotations: map[string]string{"internal": "true"},
+		EnableTLS:             true,
+	}
+
+	web := webService(deployOptions)
+	assert.Equal(t, corev1.ServiceTypeNodePort, web.Spec.Type)
+	assert.Equal(t, int32(30880), web.Spec.Ports[0].NodePort)
+
+	api := apiService(deployOptions)
+	assert.Equal(t, corev1.ServiceTypeLoadBalancer, api.Spec.Type)
+	assert.Equal(t, map[string]string{"internal": "true"}, api.Annotations)
+	assert.Equal(t, int32(30881), api.Spec.Ports[0].NodePort)
+	assert.Equal(t, int32(0), api.Spec.Ports[1].NodePort)
+
+	// upgrades keep the options the services were created with
+	clientset := fake.NewSimpleClientset(web, api)
+	serviceType, nodePort, annotations, err := readServiceOptions("default", "kotsadm-api", clientset)
+	require.NoError(t, err)
+	assert.Equal(t, "LoadBalancer", serviceType)
+	assert.Equal(t, int32(30881), nodePort)
+	assert.Equal(t, map[string]string{"internal": "true"}, annotations)
+
+	serviceType, _, _, err = readServiceOptions("default", "missing", clientset)
+	require.NoError(t, err)
+	assert.Equal(t, "", serviceType)
+}
@@ -0,0 +1,301 @@
+package kotsadm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+)
+
+const (
+	tlsCASecretName       = "kotsadm-tls-ca"
+	postgresTLSSecretName = "kotsadm-postgres-tls"
+	apiTLSSecretName      = "kotsadm-api-tls"
+
+	tlsMountPath = "/etc/kotsadm/tls"
+	apiHTTPSPort = 3443
+
+	tlsCertValidity = time.Hour * 24 * 365 * 10
+)
+
+type tlsCA struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     crypto.Signer
+}
+
+type tlsServer struct {
+	secretName string
+	service    string
+}
+
+// LoadTLSCA reads a PEM encoded CA certificate and key, returning nil for both when the filenames are empty
+func LoadTLSCA(certFilename string, keyFilename string) ([]byte, []byte, error) {
+	if certFilename == "" && keyFilename == "" {
+		return nil, nil, nil
+	}
+	if certFilename == "" || keyFilename == "" {
+		return nil, nil, errors.New("both the CA certificate and key are required")
+	}
+
+	cert, err := ioutil.ReadFile(certFilename)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read CA certificate")
+	}
+	key, err := ioutil.ReadFile(keyFilename)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read CA key")
+	}
+
+	return cert, key, nil
+}
+
+func validateTLSOptions(deployOptions DeployOptions) error {
+	if (len(deployOptions.TLSCACert) == 0) != (len(deployOptions.TLSCAKey) == 0) {
+		return errors.New("both the CA certificate and key must be set")
+	}
+	if len(deployOptions.TLSCACert) > 0 && !deployOptions.EnableTLS {
+		return errors.New("a CA can only be set when TLS is enabled")
+	}
+	if len(deployOptions.TLSCACert) > 0 {
+		if _, err := loadCA(deployOptions.TLSCACert, deployOptions.TLSCAKey); err != nil {
+			return errors.Wrap(err, "failed to load CA")
+		}
+	}
+
+	return nil
+}
+
+// postgresSSLMode is the sslmode the admin console connects to the bundled postgres with
+func postgresSSLMode(deployOptions DeployOptions) string {
+	if deployOptions.EnableTLS {
+		return "require"
+	}
+	return "disable"
+}
+
+func apiEndpoint(deployOptions DeployOptions) string {
+	if deployOptions.EnableTLS {
+		return fmt.Sprintf("https://kotsadm-api.%s.svc.cluster.local:%d", deployOptions.Namespace, apiHTTPSPort)
+	}
+	return fmt.Sprintf("http://kotsadm-api.%s.svc.cluster.local:3000", deployOptions.Namespace)
+}
+
+func tlsServers(deployOptions DeployOptions) []tlsServer {
+	servers := []tlsServer{
+		{secretName: apiTLSSecretName, service: "kotsadm-api"},
+	}
+	if !usesExternalPostgres(deployOptions) {
+		servers = append(servers, tlsServer{secretName: postgresTLSSecretName, service: "kotsadm-postgres"})
+	}
+	return servers
+}
+
+func getTLSYAML(deployOptions DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	ca, err := loadOrGenerateCA(deployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get CA")
+	}
+
+	var caSecret bytes.Buffer
+	if err := s.Encode(tlsCASecret(deployOptions.Namespace, ca.certPEM), &caSecret); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal CA secret")
+	}
+	docs["secret-tls-ca.yaml"] = caSecret.Bytes()
+
+	for _, server := range tlsServers(deployOptions) {
+		certPEM, keyPEM, err := ca.signServerCert(server.service, deployOptions.Namespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create certificate for %s", server.service)
+		}
+
+		var secret bytes.Buffer
+		if err := s.Encode(tlsSecret(deployOptions.Namespace, server.secretName, ca.certPEM, certPEM, keyPEM), &secret); err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal %s secret", server.secretName)
+		}
+		docs[fmt.Sprintf("secret-%s.yaml", server.secretName)] = secret.Bytes()
+	}
+
+	return docs, nil
+}
+
+// ensureTLS creates the CA and server certificate secrets that don't exist yet. Only the CA
+// certificate is stored in the cluster, so the CA key has to be passed again to replace a
+// server certificate secret after the CA was created.
+func ensureTLS(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	var ca *tlsCA
+
+	_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(tlsCASecretName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing CA secret")
+		}
+
+		ca, err = loadOrGenerateCA(deployOptions)
+		if err != nil {
+			return errors.Wrap(err, "failed to get CA")
+		}
+
+		_, err = clientset.CoreV1().Secrets(deployOptions.Namespace).Create(tlsCASecret(deployOptions.Namespace, ca.certPEM))
+		if err != nil {
+			return errors.Wrap(err, "failed to create CA secret")
+		}
+	}
+
+	for _, server := range tlsServers(deployOptions) {
+		_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(server.secretName, metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to get existing %s secret", server.secretName)
+		}
+
+		if ca == nil {
+			if len(deployOptions.TLSCAKey) == 0 {
+				return errors.Errorf("secret %s does not exist, the CA certificate and key are required to create it", server.secretName)
+			}
+			ca, err = loadCA(deployOptions.TLSCACert, deployOptions.TLSCAKey)
+			if err != nil {
+				return errors.Wrap(err, "failed to load CA")
+			}
+		}
+
+		certPEM, keyPEM, err := ca.signServerCert(server.service, deployOptions.Namespace)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create certificate for %s", server.service)
+		}
+
+		_, err = clientset.CoreV1().Secrets(deployOptions.Namespace).Create(tlsSecret(deployOptions.Namespace, server.secretName, ca.certPEM, certPEM, keyPEM))
+		if err != nil {
+			return errors.Wrapf(err, "failed to create %s secret", server.secretName)
+		}
+	}
+
+	return nil
+}
+
+func usesTLS(namespace string, clientset *kubernetes.Clientset) (bool, error) {
+	_, err := clientset.CoreV1().Secrets(namespace).Get(tlsCASecretName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get CA secret")
+	}
+
+	return true, nil
+}
+
+// loadOrGenerateCA returns the CA from the deploy options, or a new self-signed CA if none was set
+func loadOrGenerateCA(deployOptions DeployOptions) (*tlsCA, error) {
+	if len(deployOptions.TLSCACert) > 0 {
+		return loadCA(deployOptions.TLSCACert, deployOptions.TLSCAKey)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate key")
+	}
+
+	cert, err := certutil.NewSelfSignedCACert(certutil.Config{CommonName: "kotsadm-ca"}, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create certificate")
+	}
+
+	return &tlsCA{
+		cert:    cert,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: certutil.CertificateBlockType, Bytes: cert.Raw}),
+		key:     key,
+	}, nil
+}
+
+func loadCA(certPEM []byte, keyPEM []byte) (*tlsCA, error) {
+	certs, err := certutil.ParseCertsPEM(certPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse certificate")
+	}
+	if !certs[0].IsCA {
+		return nil, errors.New("certificate is not a CA")
+	}
+
+	key, err := keyutil.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse key")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key can't be used to sign certificates")
+	}
+
+	return &tlsCA{
+		cert:    certs[0],
+		certPEM: certPEM,
+		key:     signer,
+	}, nil
+}
+
+// signServerCert creates a key and a certificate for the names that the service can be reached at
+func (ca *tlsCA) signServerCert(service string, namespace string) ([]byte, []byte, error) {
+	return ca.signCert(service, []string{
+		service,
+		fmt.Sprintf("%s.%s", service, namespace),
+		fmt.Sprintf("%s.%s.svc", service, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace),
+	})
+}
+
+// signCert creates a key and a server certificate for the dns names
+func (ca *tlsCA) signCert(commonName string, dnsNames []string) ([]byte, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate key")
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate serial number")
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		DNSNames:    dnsNames,
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(tlsCertValidity),
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create certificate")
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: certutil.CertificateBlockType, Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: keyutil.RSAPrivateKeyBlockType, Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return certPEM, keyPEM, nil
+}
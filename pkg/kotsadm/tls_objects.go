@@ -0,0 +1,77 @@
+package kotsadm
+
+import (
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// postgres refuses to use a key that is readable by others. A root owned key is read through its
+// group, which is the fs group of the pod.
+var tlsKeyMode = int32(0640)
+
+func tlsCASecret(namespace string, caCertPEM []byte) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tlsCASecretName,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Data: map[string][]byte{
+			"ca.crt": caCertPEM,
+		},
+	}
+
+	return secret
+}
+
+func tlsSecret(namespace string, name string, caCertPEM []byte, certPEM []byte, keyPEM []byte) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    kotsadmLabels(),
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":                caCertPEM,
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}
+
+	return secret
+}
+
+func tlsVolume(secretName string, defaultMode *int32) corev1.Volume {
+	return corev1.Volume{
+		Name: "kotsadm-tls",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  secretName,
+				DefaultMode: defaultMode,
+			},
+		},
+	}
+}
+
+func tlsVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      "kotsadm-tls",
+		MountPath: tlsMountPath,
+		ReadOnly:  true,
+	}
+}
+
+func tlsFile(name string) string {
+	return path.Join(tlsMountPath, name)
+}
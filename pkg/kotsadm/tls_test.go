@@ -0,0 +1,115 @@
+package kotsadm
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func Test_signServerCert(t *testing.T) {
+	req := require.New(t)
+
+	ca, err := loadOrGenerateCA(DeployOptions{})
+	req.NoError(err)
+
+	certPEM, keyPEM, err := ca.signServerCert("kotsadm-postgres", "default")
+	req.NoError(err)
+	req.NotEmpty(keyPEM)
+
+	certs, err := certutil.ParseCertsPEM(certPEM)
+	req.NoError(err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	for _, name := range []string{"kotsadm-postgres", "kotsadm-postgres.default.svc.cluster.local"} {
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			DNSName: name,
+			Roots:   roots,
+		})
+		assert.NoError(t, err, name)
+	}
+}
+
+func Test_validateTLSOptions(t *testing.T) {
+	ca, err := loadOrGenerateCA(DeployOptions{})
+	require.NoError(t, err)
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(ca.key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		deployOptions DeployOptions
+		expectError   bool
+	}{
+		{
+			name:          "tls disabled",
+			deployOptions: DeployOptions{},
+		},
+		{
+			name:          "generated ca",
+			deployOptions: DeployOptions{EnableTLS: true},
+		},
+		{
+			name:          "user supplied ca",
+			deployOptions: DeployOptions{EnableTLS: true, TLSCACert: ca.certPEM, TLSCAKey: caKeyPEM},
+		},
+		{
+			name:          "ca without tls",
+			deployOptions: DeployOptions{TLSCACert: ca.certPEM, TLSCAKey: caKeyPEM},
+			expectError:   true,
+		},
+		{
+			name:          "ca without key",
+			deployOptions: DeployOptions{EnableTLS: true, TLSCACert: ca.certPEM},
+			expectError:   true,
+		},
+		{
+			name:          "invalid ca",
+			deployOptions: DeployOptions{EnableTLS: true, TLSCACert: []byte("not a cert"), TLSCAKey: caKeyPEM},
+			expectError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTLSOptions(test.deployOptions)
+			if test.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_tlsObjects(t *testing.T) {
+	deployOptions := DeployOptions{Namespace: "default", EnableTLS: true}
+
+	postgres := postgresStatefulset(deployOptions).Spec.Template.Spec
+	assert.Contains(t, postgres.Containers[0].Args, "ssl=on")
+	assert.Equal(t, postgresTLSSecretName, postgres.Volumes[len(postgres.Volumes)-1].Secret.SecretName)
+	assert.Equal(t, tlsKeyMode, *postgres.Volumes[len(postgres.Volumes)-1].Secret.DefaultMode)
+	// the group readable key can only be read by postgres through the fs group
+	require.NotNil(t, postgres.SecurityContext)
+	assert.Equal(t, int64(postgresUID), *postgres.SecurityContext.RunAsUser)
+	assert.Equal(t, int64(postgresUID), *postgres.SecurityContext.FSGroup)
+
+	secret := pgSecret("default", "password", postgresSSLMode(deployOptions))
+	assert.Contains(t, string(secret.Data["uri"]), "sslmode=require")
+
+	api := apiDeployment(deployOptions).Spec.Template.Spec
+	assert.Contains(t, api.Containers[0].Env, corev1.EnvVar{Name: "SHIP_API_ENDPOINT", Value: "https://kotsadm-api.default.svc.cluster.local:3443"})
+	assert.Len(t, apiService(deployOptions).Spec.Ports, 2)
+
+	operator := operatorDeployment(deployOptions).Spec.Template.Spec
+	assert.Contains(t, operator.Containers[0].Env, corev1.EnvVar{Name: "KOTSADM_API_ENDPOINT", Value: "https://kotsadm-api.default.svc.cluster.local:3443"})
+
+	deployOptions.EnableTLS = false
+	assert.Empty(t, postgresStatefulset(deployOptions).Spec.Template.Spec.Containers[0].Args)
+	assert.Len(t, apiService(deployOptions).Spec.Ports, 1)
+}
@@ -0,0 +1,83 @@
+package kotsadm
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TuningProfile adjusts probe timings and timeouts of the admin console for the
+// environment it's installed into
+type TuningProfile string
+
+const (
+	DefaultTuningProfile TuningProfile = ""
+
+	// SlowStorageTuningProfile is for clusters with slow persistent volumes (e.g. NFS),
+	// where postgres and the initial schema migration can take several minutes to start
+	SlowStorageTuningProfile TuningProfile = "slow-storage"
+)
+
+const (
+	slowStorageScale = 4
+
+	// slowStorageStartupSeconds is how long a container can take to start before
+	// the liveness probe is allowed to restart it
+	slowStorageStartupSeconds = 300
+)
+
+func ValidateTuningProfile(profile TuningProfile) error {
+	switch profile {
+	case DefaultTuningProfile, SlowStorageTuningProfile:
+		return nil
+	}
+
+	return errors.Errorf("unknown tuning profile %q", profile)
+}
+
+// livenessProbe returns the probe with timings scaled for the profile.
+// The version of the kubernetes api in use does not support startup probes, so containers
+// are given time to start by delaying the liveness probe.
+func (p TuningProfile) livenessProbe(probe *corev1.Probe) *corev1.Probe {
+	if p != SlowStorageTuningProfile {
+		return probe
+	}
+
+	scaled := p.readinessProbe(probe)
+	if scaled.InitialDelaySeconds < slowStorageStartupSeconds {
+		scaled.InitialDelaySeconds = slowStorageStartupSeconds
+	}
+	return scaled
+}
+
+// readinessProbe returns the probe with timings scaled for the profile
+func (p TuningProfile) readinessProbe(probe *corev1.Probe) *corev1.Probe {
+	if p != SlowStorageTuningProfile {
+		return probe
+	}
+
+	scaled := probe.DeepCopy()
+	scaled.InitialDelaySeconds = scaled.InitialDelaySeconds * slowStorageScale
+	scaled.TimeoutSeconds = scaledProbeValue(scaled.TimeoutSeconds, 1)
+	scaled.PeriodSeconds = scaledProbeValue(scaled.PeriodSeconds, 10)
+	scaled.FailureThreshold = scaledProbeValue(scaled.FailureThreshold, 3)
+	return scaled
+}
+
+// timeout returns the timeout scaled for the profile
+func (p TuningProfile) timeout(timeout time.Duration) time.Duration {
+	if p != SlowStorageTuningProfile {
+		return timeout
+	}
+
+	return timeout * slowStorageScale
+}
+
+// scaledProbeValue scales a probe field, using the kubernetes default when it is not set
+func scaledProbeValue(value int32, defaultValue int32) int32 {
+	if value == 0 {
+		value = defaultValue
+	}
+	return value * slowStorageScale
+}
@@ -0,0 +1,40 @@
+package kotsadm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_TuningProfile(t *testing.T) {
+	probe := &corev1.Probe{
+		InitialDelaySeconds: 30,
+		TimeoutSeconds:      5,
+		FailureThreshold:    3,
+	}
+
+	assert.Equal(t, probe, DefaultTuningProfile.livenessProbe(probe))
+	assert.Equal(t, time.Minute, DefaultTuningProfile.timeout(time.Minute))
+
+	assert.Equal(t, &corev1.Probe{
+		InitialDelaySeconds: 300,
+		TimeoutSeconds:      20,
+		PeriodSeconds:       40,
+		FailureThreshold:    12,
+	}, SlowStorageTuningProfile.livenessProbe(probe))
+	assert.Equal(t, &corev1.Probe{
+		InitialDelaySeconds: 120,
+		TimeoutSeconds:      20,
+		PeriodSeconds:       40,
+		FailureThreshold:    12,
+	}, SlowStorageTuningProfile.readinessProbe(probe))
+	assert.Equal(t, 4*time.Minute, SlowStorageTuningProfile.timeout(time.Minute))
+
+	// the original probe is not changed
+	assert.Equal(t, int32(30), probe.InitialDelaySeconds)
+
+	assert.NoError(t, ValidateTuningProfile(SlowStorageTuningProfile))
+	assert.Error(t, ValidateTuningProfile(TuningProfile("fast")))
+}
@@ -0,0 +1,111 @@
+package kotsadm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// VersionSkew is the difference between the version of kots and of the admin console that it
+// connects to
+type VersionSkew struct {
+	CLIVersion     string
+	KotsadmVersion string
+
+	// Incompatible is set when the major versions differ or the minor versions are more than one
+	// apart. Versions that are closer work together, possibly without the newest features.
+	Incompatible bool
+}
+
+// CLINewer is true when kots is newer than the admin console, so that upgrading the admin
+// console removes the skew
+func (s VersionSkew) CLINewer() bool {
+	cliVersion, err := semver.NewVersion(s.CLIVersion)
+	if err != nil {
+		return false
+	}
+	kotsadmVersion, err := semver.NewVersion(s.KotsadmVersion)
+	if err != nil {
+		return false
+	}
+	return cliVersion.GreaterThan(kotsadmVersion)
+}
+
+func (s VersionSkew) String() string {
+	return fmt.Sprintf("the admin console is running %s and kots is %s", s.KotsadmVersion, s.CLIVersion)
+}
+
+// GetVersionSkew returns the skew between this version of kots and the admin console in the
+// namespace. It's nil when the versions are the same, when the admin console isn't deployed, or
+// when either isn't a release (e.g. alpha).
+func GetVersionSkew(namespace string, clientset kubernetes.Interface) (*VersionSkew, error) {
+	kotsadmVersion, err := DeployedVersion(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deployed version")
+	}
+	if kotsadmVersion == "" {
+		return nil, nil
+	}
+
+	return versionSkew(kotsadmTag(), kotsadmVersion), nil
+}
+
+// DeployedVersion returns the version of the admin console in the namespace, the tag of the api
+// image. It's empty when the admin console isn't deployed.
+func DeployedVersion(namespace string, clientset kubernetes.Interface) (string, error) {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get("kotsadm-api", metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get api deployment")
+	}
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "kotsadm-api" {
+			return imageTag(container.Image), nil
+		}
+	}
+
+	return "", nil
+}
+
+// imageTag returns the tag of an image reference, which can include a registry port and a digest
+func imageTag(image string) string {
+	image = strings.SplitN(image, "@", 2)[0]
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i != -1 {
+		return name[i+1:]
+	}
+	return "latest"
+}
+
+func versionSkew(cliVersion string, kotsadmVersion string) *VersionSkew {
+	if cliVersion == kotsadmVersion {
+		return nil
+	}
+
+	cli, err := semver.NewVersion(cliVersion)
+	if err != nil || cli.Prerelease() != "" {
+		return nil
+	}
+	kotsadm, err := semver.NewVersion(kotsadmVersion)
+	if err != nil || kotsadm.Prerelease() != "" {
+		return nil
+	}
+	if cli.Equal(kotsadm) {
+		return nil
+	}
+
+	minorDiff := cli.Minor() - kotsadm.Minor()
+	return &VersionSkew{
+		CLIVersion:     cliVersion,
+		KotsadmVersion: kotsadmVersion,
+		Incompatible:   cli.Major() != kotsadm.Major() || minorDiff > 1 || minorDiff < -1,
+	}
+}
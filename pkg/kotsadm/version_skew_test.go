@@ -0,0 +1,92 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_versionSkew(t *testing.T) {
+	tests := []struct {
+		name           string
+		cliVersion     string
+		kotsadmVersion string
+		want           *VersionSkew
+		wantCLINewer   bool
+	}{
+		{
+			name:           "same version",
+			cliVersion:     "v1.16.0",
+			kotsadmVersion: "v1.16.0",
+		},
+		{
+			name:           "alpha",
+			cliVersion:     "alpha",
+			kotsadmVersion: "v1.16.0",
+		},
+		{
+			name:           "prerelease",
+			cliVersion:     "v1.17.0-beta.1",
+			kotsadmVersion: "v1.16.0",
+		},
+		{
+			name:           "patch",
+			cliVersion:     "v1.16.2",
+			kotsadmVersion: "v1.16.0",
+			want:           &VersionSkew{CLIVersion: "v1.16.2", KotsadmVersion: "v1.16.0"},
+			wantCLINewer:   true,
+		},
+		{
+			name:           "one minor older cli",
+			cliVersion:     "v1.15.1",
+			kotsadmVersion: "v1.16.0",
+			want:           &VersionSkew{CLIVersion: "v1.15.1", KotsadmVersion: "v1.16.0"},
+		},
+		{
+			name:           "two minors newer cli",
+			cliVersion:     "v1.18.0",
+			kotsadmVersion: "v1.16.3",
+			want:           &VersionSkew{CLIVersion: "v1.18.0", KotsadmVersion: "v1.16.3", Incompatible: true},
+			wantCLINewer:   true,
+		},
+		{
+			name:           "major",
+			cliVersion:     "v2.0.0",
+			kotsadmVersion: "v1.16.0",
+			want:           &VersionSkew{CLIVersion: "v2.0.0", KotsadmVersion: "v1.16.0", Incompatible: true},
+			wantCLINewer:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			skew := versionSkew(test.cliVersion, test.kotsadmVersion)
+			assert.Equal(t, test.want, skew)
+			if skew != nil {
+				assert.Equal(t, test.wantCLINewer, skew.CLINewer())
+			}
+		})
+	}
+}
+
+func Test_imageTag(t *testing.T) {
+	assert.Equal(t, "v1.16.0", imageTag("kotsadm/kotsadm-api:v1.16.0"))
+	assert.Equal(t, "v1.16.0", imageTag("localhost:32000/kotsadm/kotsadm-api:v1.16.0@sha256:8e5bd9f4c2f6b6e1f4a6f3c5c7f1d2d0e9b8a7c6d5e4f3a2b1c0d9e8f7a6b5c4"))
+	assert.Equal(t, "latest", imageTag("localhost:32000/kotsadm/kotsadm-api"))
+}
+
+func Test_DeployedVersion(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	deployed, err := DeployedVersion("default", clientset)
+	require.NoError(t, err)
+	assert.Equal(t, "", deployed)
+
+	OverrideVersion = "v1.16.0"
+	defer func() { OverrideVersion = "" }()
+	clientset = fake.NewSimpleClientset(apiDeployment(DeployOptions{Namespace: "default"}))
+	deployed, err = DeployedVersion("default", clientset)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.16.0", deployed)
+}
@@ -2,12 +2,11 @@ package kotsadm
 
 import (
 	"bytes"
-	"os"
 	"strconv"
 	"strings"
 
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/prompt"
 	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
@@ -31,7 +30,7 @@ func getWebYAML(deployOptions DeployOptions) (map[string][]byte, error) {
 	docs["web-config.yaml"] = config.Bytes()
 
 	var deployment bytes.Buffer
-	if err := s.Encode(webDeployment(deployOptions.Namespace), &deployment); err != nil {
+	if err := s.Encode(webDeployment(deployOptions), &deployment); err != nil {
 		return nil, errors.Wrap(err, "failed to marsha web deployment")
 	}
 	docs["web-deployment.yaml"] = deployment.Bytes()
@@ -47,7 +46,7 @@ func getWebYAML(deployOptions DeployOptions) (map[string][]byte, error) {
 
 func ensureWeb(deployOptions *DeployOptions, clientset *kubernetes.Clientset) error {
 	if deployOptions.Hostname == "" {
-		hostname, err := promptForHostname()
+		hostname, err := promptForHostname(prompt.OrTerminal(deployOptions.Prompter))
 		if err != nil {
 			return errors.Wrap(err, "failed to prompt for hostname")
 		}
@@ -68,7 +67,7 @@ func ensureWeb(deployOptions *DeployOptions, clientset *kubernetes.Clientset) er
 		return errors.Wrap(err, "failed to ensure web configmap")
 	}
 
-	if err := ensureWebDeployment(deployOptions.Namespace, clientset); err != nil {
+	if err := ensureWebDeployment(*deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure web deployment")
 	}
 
@@ -86,7 +85,11 @@ func ensureWebConfig(deployOptions *DeployOptions, clientset *kubernetes.Clients
 			return errors.Wrap(err, "failed to get existing config map")
 		}
 
-		_, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(webConfig(*deployOptions))
+		configMap := webConfig(*deployOptions)
+		if err := applyPatches(*deployOptions, configMap); err != nil {
+			return errors.Wrap(err, "failed to patch web config map")
+		}
+		_, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(configMap)
 		if err != nil {
 			return errors.Wrap(err, "failed to create configmap")
 		}
@@ -95,17 +98,30 @@ func ensureWebConfig(deployOptions *DeployOptions, clientset *kubernetes.Clients
 	return nil
 }
 
-func ensureWebDeployment(namespace string, clientset *kubernetes.Clientset) error {
-	_, err := clientset.AppsV1().Deployments(namespace).Get("kotsadm-web", metav1.GetOptions{})
+func ensureWebDeployment(deployOptions DeployOptions, clientset *kubernetes.Clientset) error {
+	existing, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Get("kotsadm-web", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing deployment")
 		}
 
-		_, err := clientset.AppsV1().Deployments(namespace).Create(webDeployment(namespace))
+		deployment := webDeployment(deployOptions)
+		if err := applyPatches(deployOptions, deployment); err != nil {
+			return errors.Wrap(err, "failed to patch web deployment")
+		}
+		_, err := clientset.AppsV1().Deployments(deployOptions.Namespace).Create(deployment)
 		if err != nil {
 			return errors.Wrap(err, "failed to create deployment")
 		}
+		return nil
+	}
+
+	deployment := webDeployment(deployOptions)
+	if err := applyPatches(deployOptions, deployment); err != nil {
+		return errors.Wrap(err, "failed to patch web deployment")
+	}
+	if err := updateDeployment(deployOptions, clientset, existing, deployment); err != nil {
+		return errors.Wrap(err, "failed to update deployment")
 	}
 
 	return nil
@@ -118,7 +134,11 @@ func ensureWebService(deployOptions *DeployOptions, clientset *kubernetes.Client
 			return errors.Wrap(err, "failed to get existing service")
 		}
 
-		_, err := clientset.CoreV1().Services(deployOptions.Namespace).Create(webService(*deployOptions))
+		service := webService(*deployOptions)
+		if err := applyPatches(*deployOptions, service); err != nil {
+			return errors.Wrap(err, "failed to patch web service")
+		}
+		_, err := clientset.CoreV1().Services(deployOptions.Namespace).Create(service)
 		if err != nil {
 			return errors.Wrap(err, "Failed to create service")
 		}
@@ -128,82 +148,49 @@ func ensureWebService(deployOptions *DeployOptions, clientset *kubernetes.Client
 }
 
 func promptForWebServiceType(deployOptions *DeployOptions) (string, error) {
-	prompt := promptui.Select{
-		Label: "Web/UI Service Type:",
-		Items: []string{"ClusterIP", "NodePort", "LoadBalancer"},
+	prompter := prompt.OrTerminal(deployOptions.Prompter)
+
+	result, err := prompter.Select("Web/UI Service Type:", []string{"ClusterIP", "NodePort", "LoadBalancer"})
+	if err != nil {
+		return "", err
 	}
 
-	for {
-		_, result, err := prompt.Run()
+	if result == "NodePort" {
+		nodePort, err := promptForWebNodePort(prompter)
 		if err != nil {
-			if err == promptui.ErrInterrupt {
-				os.Exit(-1)
-			}
-			continue
+			return "", errors.Wrap(err, "failed to prompt for node port")
 		}
 
-		if result == "NodePort" {
-			nodePort, err := promptForWebNodePort()
-			if err != nil {
-				return "", errors.Wrap(err, "failed to prompt for node port")
-			}
-
-			deployOptions.NodePort = int32(nodePort)
-		}
-		return result, nil
+		deployOptions.NodePort = int32(nodePort)
 	}
-
+	return result, nil
 }
 
-func promptForWebNodePort() (int, error) {
-	templates := &promptui.PromptTemplates{
-		Prompt:  "{{ . | bold }} ",
-		Valid:   "{{ . | green }} ",
-		Invalid: "{{ . | red }} ",
-		Success: "{{ . | bold }} ",
-	}
-
-	prompt := promptui.Prompt{
-		Label:     "Node Port:",
-		Templates: templates,
-		Default:   "30000",
+func promptForWebNodePort(prompter prompt.Prompter) (int, error) {
+	result, err := prompter.Input(prompt.Input{
+		Label:   "Node Port:",
+		Default: "30000",
 		Validate: func(input string) error {
 			_, err := strconv.Atoi(input)
 			return err
 		},
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	for {
-		result, err := prompt.Run()
-		if err != nil {
-			if err == promptui.ErrInterrupt {
-				os.Exit(-1)
-			}
-			continue
-		}
-
-		nodePort, err := strconv.Atoi(result)
-		if err != nil {
-			return 0, errors.Wrap(err, "failed to convert nodeport")
-		}
-
-		return nodePort, nil
+	nodePort, err := strconv.Atoi(result)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to convert nodeport")
 	}
 
+	return nodePort, nil
 }
 
-func promptForHostname() (string, error) {
-	templates := &promptui.PromptTemplates{
-		Prompt:  "{{ . | bold }} ",
-		Valid:   "{{ . | green }} ",
-		Invalid: "{{ . | red }} ",
-		Success: "{{ . | bold }} ",
-	}
-
-	prompt := promptui.Prompt{
-		Label:     "Hostname for the Admin Console:",
-		Templates: templates,
-		Default:   "localhost:8800",
+func promptForHostname(prompter prompt.Prompter) (string, error) {
+	return prompter.Input(prompt.Input{
+		Label:   "Hostname for the Admin Console:",
+		Default: "localhost:8800",
 		Validate: func(input string) error {
 			if !strings.Contains(input, ":") {
 				errs := validation.IsDNS1123Subdomain(input)
@@ -231,18 +218,5 @@ func promptForHostname() (string, error) {
 
 			return nil
 		},
-	}
-
-	for {
-		result, err := prompt.Run()
-		if err != nil {
-			if err == promptui.ErrInterrupt {
-				os.Exit(-1)
-			}
-			continue
-		}
-
-		return result, nil
-	}
-
+	})
 }
@@ -20,6 +20,7 @@ func webConfig(deployOptions DeployOptions) *corev1.ConfigMap {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-web-scripts",
 			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Data: map[string]string{
 			"start-kotsadm-web.sh": fmt.Sprintf(`#!/bin/bash
@@ -43,7 +44,9 @@ nginx -g "daemon off;"`, deployOptions.Hostname, deployOptions.Hostname,
 	return configMap
 }
 
-func webDeployment(namespace string) *appsv1.Deployment {
+func webDeployment(deployOptions DeployOptions) *appsv1.Deployment {
+	replicas := componentReplicas(deployOptions, "kotsadm-web")
+
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
@@ -51,9 +54,11 @@ func webDeployment(namespace string) *appsv1.Deployment {
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-web",
-			Namespace: namespace,
+			Namespace: deployOptions.Namespace,
+			Labels:    kotsadmLabels(),
 		},
 		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app": "kotsadm-web",
@@ -66,6 +71,9 @@ func webDeployment(namespace string) *appsv1.Deployment {
 					},
 				},
 				Spec: corev1.PodSpec{
+					NodeSelector: deployOptions.NodeSelector,
+					Tolerations:  deployOptions.Tolerations,
+					Affinity:     componentAffinity(deployOptions, "kotsadm-web"),
 					SecurityContext: &corev1.PodSecurityContext{
 						RunAsUser: util.IntPointer(101),
 						FSGroup:   util.IntPointer(101),
@@ -85,9 +93,10 @@ func webDeployment(namespace string) *appsv1.Deployment {
 					},
 					Containers: []corev1.Container{
 						{
-							Image:           fmt.Sprintf("%s/kotsadm-web:%s", kotsadmRegistry(), kotsadmTag()),
+							Image:           kotsadmImage("kotsadm-web"),
 							ImagePullPolicy: corev1.PullAlways,
 							Name:            "kotsadm-web",
+							Resources:       deployOptions.Resources.Web,
 							Args: []string{
 								"/scripts/start-kotsadm-web.sh",
 							},
@@ -97,7 +106,7 @@ func webDeployment(namespace string) *appsv1.Deployment {
 									ContainerPort: 3000,
 								},
 							},
-							ReadinessProbe: &corev1.Probe{
+							ReadinessProbe: deployOptions.TuningProfile.readinessProbe(&corev1.Probe{
 								FailureThreshold:    3,
 								InitialDelaySeconds: 2,
 								PeriodSeconds:       2,
@@ -110,7 +119,7 @@ func webDeployment(namespace string) *appsv1.Deployment {
 										Scheme: corev1.URISchemeHTTP,
 									},
 								},
-							},
+							}),
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "kotsadm-web-scripts",
@@ -125,6 +134,10 @@ func webDeployment(namespace string) *appsv1.Deployment {
 		},
 	}
 
+	addProxy(deployOptions, &deployment.Spec.Template.Spec)
+	addSidecarInjection(deployOptions, &deployment.Spec.Template.ObjectMeta, &deployment.Spec.Template.Spec)
+	addOpenShiftCompatibility(deployOptions, &deployment.Spec.Template.Spec)
+
 	return deployment
 }
 
@@ -135,13 +148,7 @@ func webService(deployOptions DeployOptions) *corev1.Service {
 		TargetPort: intstr.FromString("http"),
 	}
 
-	serviceType := corev1.ServiceTypeClusterIP
-	if deployOptions.ServiceType == "NodePort" {
-		serviceType = corev1.ServiceTypeNodePort
-		port.NodePort = int32(deployOptions.NodePort)
-	} else if deployOptions.ServiceType == "LoadBalancer" {
-		serviceType = corev1.ServiceTypeLoadBalancer
-	}
+	serviceType, ports := serviceSpec(deployOptions.ServiceType, deployOptions.NodePort, []corev1.ServicePort{port})
 
 	service := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -149,17 +156,17 @@ func webService(deployOptions DeployOptions) *corev1.Service {
 			Kind:       "Service",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "kotsadm-web",
-			Namespace: deployOptions.Namespace,
+			Name:        "kotsadm-web",
+			Namespace:   deployOptions.Namespace,
+			Annotations: deployOptions.ServiceAnnotations,
+			Labels:      kotsadmLabels(),
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{
 				"app": "kotsadm-web",
 			},
-			Type: serviceType,
-			Ports: []corev1.ServicePort{
-				port,
-			},
+			Type:  serviceType,
+			Ports: ports,
 		},
 	}
 	return service
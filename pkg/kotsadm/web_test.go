@@ -0,0 +1,64 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/prompt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// answers is a prompter with the answers to the questions, by label
+type answers map[string]string
+
+func (a answers) Input(input prompt.Input) (string, error) {
+	answer, ok := a[input.Label]
+	if !ok {
+		return "", prompt.ErrCanceled
+	}
+	if input.Validate != nil {
+		if err := input.Validate(answer); err != nil {
+			return "", err
+		}
+	}
+	return answer, nil
+}
+
+func (a answers) Select(label string, items []string) (string, error) {
+	answer, ok := a[label]
+	if !ok {
+		return "", prompt.ErrCanceled
+	}
+	return answer, nil
+}
+
+func Test_promptForWebServiceType(t *testing.T) {
+	deployOptions := DeployOptions{
+		Prompter: answers{
+			"Web/UI Service Type:": "NodePort",
+			"Node Port:":           "30080",
+		},
+	}
+
+	serviceType, err := promptForWebServiceType(&deployOptions)
+	require.NoError(t, err)
+	assert.Equal(t, "NodePort", serviceType)
+	assert.Equal(t, int32(30080), deployOptions.NodePort)
+
+	deployOptions.Prompter = answers{}
+	_, err = promptForWebServiceType(&deployOptions)
+	assert.Equal(t, prompt.ErrCanceled, errors.Cause(err))
+}
+
+func Test_promptForSharedPassword(t *testing.T) {
+	password, err := promptForSharedPassword(answers{"Enter a new password to be used for the Admin Console:": "password"})
+	require.NoError(t, err)
+	assert.Equal(t, "password", password)
+
+	_, err = promptForSharedPassword(answers{"Enter a new password to be used for the Admin Console:": "short"})
+	assert.EqualError(t, err, "please enter a longer password")
+
+	_, err = promptForSharedPassword(prompt.NonInteractive{})
+	assert.Equal(t, prompt.ErrMissingInput, errors.Cause(err))
+}
@@ -0,0 +1,164 @@
+package license
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	"github.com/replicatedhq/kots/pkg/util"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// ExpiresAtEntitlement is the entitlement with the time a license expires at, in RFC 3339 format.
+// Licenses that don't expire have an empty value.
+const ExpiresAtEntitlement = "expires_at"
+
+var (
+	ErrLicenseExpired    = errors.New("license is expired")
+	ErrAppSlugMismatch   = errors.New("license is for a different application")
+	ErrChannelMismatch   = errors.New("license is for a different channel")
+	ErrNotLicense        = errors.New("not an application license")
+	ErrEntitlementType   = errors.New("entitlement has a different type")
+	ErrEntitlementAbsent = errors.New("entitlement is not in the license")
+)
+
+func init() {
+	kotsscheme.AddToScheme(scheme.Scheme)
+}
+
+type ValidateOptions struct {
+	// AppSlug and ChannelName are checked when they are set
+	AppSlug     string
+	ChannelName string
+	// Now is the time that expiration is checked against, the current time when it's zero
+	Now time.Time
+}
+
+// ParseLicense decodes a License custom resource. The signature isn't verified.
+func ParseLicense(data []byte) (*kotsv1beta1.License, error) {
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	obj, gvk, err := decode(data, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode license")
+	}
+
+	if gvk.Group != "kots.io" || gvk.Version != "v1beta1" || gvk.Kind != "License" {
+		return nil, ErrNotLicense
+	}
+
+	return obj.(*kotsv1beta1.License), nil
+}
+
+// ParseAndValidate parses the license and returns it once its signature is verified and it passes
+// the checks in ValidateLicense. The returned license has the signed values of all fields.
+func ParseAndValidate(data []byte, options ValidateOptions) (*kotsv1beta1.License, error) {
+	license, err := ParseLicense(data)
+	if err != nil {
+		return nil, err
+	}
+
+	verifiedLicense, err := VerifySignature(license)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify signature")
+	}
+
+	if err := ValidateLicense(verifiedLicense, options); err != nil {
+		return nil, err
+	}
+
+	return verifiedLicense, nil
+}
+
+// ValidateLicense checks that a verified license hasn't expired, and is for the app and channel in
+// the options
+func ValidateLicense(license *kotsv1beta1.License, options ValidateOptions) error {
+	if options.AppSlug != "" && license.Spec.AppSlug != options.AppSlug {
+		return errors.Wrapf(ErrAppSlugMismatch, "license is for %q, not %q", license.Spec.AppSlug, options.AppSlug)
+	}
+
+	if options.ChannelName != "" && license.Spec.ChannelName != options.ChannelName {
+		return errors.Wrapf(ErrChannelMismatch, "license is for channel %q, not %q", license.Spec.ChannelName, options.ChannelName)
+	}
+
+	expiresAt, err := ExpiresAt(license)
+	if err != nil {
+		return errors.Wrap(err, "failed to get expiration")
+	}
+
+	now := options.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if !expiresAt.IsZero() && !now.Before(expiresAt) {
+		return util.NewError(util.ErrLicenseExpired, fmt.Sprintf("license expired at %s", expiresAt.Format(time.RFC3339)), ErrLicenseExpired)
+	}
+
+	return nil
+}
+
+// ExpiresAt returns the time the license expires at, or the zero time if it doesn't expire
+func ExpiresAt(license *kotsv1beta1.License) (time.Time, error) {
+	value, err := GetStringEntitlement(license, ExpiresAtEntitlement)
+	if errors.Cause(err) == ErrEntitlementAbsent {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to parse %s", ExpiresAtEntitlement)
+	}
+	return expiresAt, nil
+}
+
+// GetEntitlement returns the value of an entitlement, which is an int64, string or bool
+func GetEntitlement(license *kotsv1beta1.License, name string) (interface{}, error) {
+	entitlement, ok := license.Spec.Entitlements[name]
+	if !ok {
+		return nil, errors.Wrap(ErrEntitlementAbsent, name)
+	}
+	return entitlement.Value.Value(), nil
+}
+
+func GetStringEntitlement(license *kotsv1beta1.License, name string) (string, error) {
+	value, err := GetEntitlement(license, name)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.Wrapf(ErrEntitlementType, "%s is not a string", name)
+	}
+	return s, nil
+}
+
+func GetIntEntitlement(license *kotsv1beta1.License, name string) (int64, error) {
+	value, err := GetEntitlement(license, name)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := value.(int64)
+	if !ok {
+		return 0, errors.Wrapf(ErrEntitlementType, "%s is not an int", name)
+	}
+	return i, nil
+}
+
+func GetBoolEntitlement(license *kotsv1beta1.License, name string) (bool, error) {
+	value, err := GetEntitlement(license, name)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, errors.Wrapf(ErrEntitlementType, "%s is not a bool", name)
+	}
+	return b, nil
+}
@@ -0,0 +1,123 @@
+package license
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const unsignedLicense = `apiVersion: kots.io/v1beta1
+kind: License
+metadata:
+  name: local
+spec:
+  licenseID: abcdef
+  appSlug: my-app
+  channelName: Stable
+  entitlements:
+    expires_at:
+      title: Expiration
+      value: "2020-06-01T00:00:00Z"
+    is_vip:
+      title: Is VIP
+      value: true
+    num_seats:
+      title: Number Of Seats
+      value: 10
+  signature: IA==`
+
+func Test_ParseLicense(t *testing.T) {
+	license, err := ParseLicense([]byte(unsignedLicense))
+	require.NoError(t, err)
+	assert.Equal(t, "my-app", license.Spec.AppSlug)
+
+	_, err = ParseLicense([]byte("apiVersion: kots.io/v1beta1\nkind: Config\nmetadata:\n  name: config\n"))
+	assert.Equal(t, ErrNotLicense, errors.Cause(err))
+
+	// the signature of the license is a single space
+	_, err = ParseAndValidate([]byte(unsignedLicense), ValidateOptions{})
+	assert.Error(t, err)
+}
+
+func Test_ValidateLicense(t *testing.T) {
+	license, err := ParseLicense([]byte(unsignedLicense))
+	require.NoError(t, err)
+
+	beforeExpiration := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		license     *kotsv1beta1.License
+		options     ValidateOptions
+		expectedErr error
+	}{
+		{
+			name:    "valid",
+			license: license,
+			options: ValidateOptions{AppSlug: "my-app", ChannelName: "Stable", Now: beforeExpiration},
+		},
+		{
+			name:        "expired",
+			license:     license,
+			options:     ValidateOptions{Now: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)},
+			expectedErr: ErrLicenseExpired,
+		},
+		{
+			name:        "different app",
+			license:     license,
+			options:     ValidateOptions{AppSlug: "other-app", Now: beforeExpiration},
+			expectedErr: ErrAppSlugMismatch,
+		},
+		{
+			name:        "different channel",
+			license:     license,
+			options:     ValidateOptions{ChannelName: "Beta", Now: beforeExpiration},
+			expectedErr: ErrChannelMismatch,
+		},
+		{
+			name:    "no expiration",
+			license: &kotsv1beta1.License{},
+			options: ValidateOptions{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateLicense(test.license, test.options)
+			if test.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, test.expectedErr, errors.Cause(err))
+		})
+	}
+}
+
+func Test_Entitlements(t *testing.T) {
+	req := require.New(t)
+
+	license, err := ParseLicense([]byte(unsignedLicense))
+	req.NoError(err)
+
+	expiresAt, err := ExpiresAt(license)
+	req.NoError(err)
+	assert.Equal(t, time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), expiresAt.UTC())
+
+	isVIP, err := GetBoolEntitlement(license, "is_vip")
+	req.NoError(err)
+	assert.True(t, isVIP)
+
+	numSeats, err := GetIntEntitlement(license, "num_seats")
+	req.NoError(err)
+	assert.Equal(t, int64(10), numSeats)
+
+	_, err = GetStringEntitlement(license, "num_seats")
+	assert.Equal(t, ErrEntitlementType, errors.Cause(err))
+
+	_, err = GetEntitlement(license, "missing")
+	assert.Equal(t, ErrEntitlementAbsent, errors.Cause(err))
+}
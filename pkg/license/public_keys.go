@@ -1,4 +1,4 @@
-package pull
+package license
 
 var publicKeys = map[string][]byte{
 	"1d3f7f6b50714fe7b895554dd65773b0": []byte(`-----BEGIN PUBLIC KEY-----
@@ -1,4 +1,4 @@
-package pull
+package license
 
 import (
 	"crypto"
@@ -59,11 +59,11 @@ func VerifySignature(license *kotsv1beta1.License) (*kotsv1beta1.License, error)
 		return nil, errors.New("unknown global key")
 	}
 
-	if err := verify([]byte(innerSignature.PublicKey), keySignature.Signature, globalKeyPEM); err != nil {
+	if err := Verify([]byte(innerSignature.PublicKey), keySignature.Signature, globalKeyPEM); err != nil {
 		return nil, errors.Wrap(err, "failed to verify key signature")
 	}
 
-	if err := verify(outerSignature.LicenseData, innerSignature.LicenseSignature, []byte(innerSignature.PublicKey)); err != nil {
+	if err := Verify(outerSignature.LicenseData, innerSignature.LicenseSignature, []byte(innerSignature.PublicKey)); err != nil {
 		return nil, errors.Wrap(err, "failed to verify license signature")
 	}
 
@@ -82,8 +82,12 @@ func VerifySignature(license *kotsv1beta1.License) (*kotsv1beta1.License, error)
 	return verifiedLicense, nil
 }
 
-func verify(message, signature, publicKeyPEM []byte) error {
+// Verify checks that the message was signed with the private key of publicKeyPEM
+func Verify(message, signature, publicKeyPEM []byte) error {
 	pubBlock, _ := pem.Decode(publicKeyPEM)
+	if pubBlock == nil {
+		return errors.New("failed to decode public key PEM")
+	}
 	publicKey, err := x509.ParsePKIXPublicKey(pubBlock.Bytes)
 	if err != nil {
 		return errors.Wrap(err, "failed to load public key from PEM")
@@ -175,7 +179,7 @@ func VerifyOldSignature(license *kotsv1beta1.License) (*kotsv1beta1.License, err
 		return nil, errors.New("unknown global key")
 	}
 
-	if err := verify([]byte(signature.PublicKey), keySignature.Signature, globalKeyPEM); err != nil {
+	if err := Verify([]byte(signature.PublicKey), keySignature.Signature, globalKeyPEM); err != nil {
 		return nil, errors.Wrap(err, "failed to verify key signature")
 	}
 
@@ -184,7 +188,7 @@ func VerifyOldSignature(license *kotsv1beta1.License) (*kotsv1beta1.License, err
 		return nil, errors.Wrap(err, "failed to convert license to message")
 	}
 
-	if err := verify(licenseMessage, signature.LicenseSignature, []byte(signature.PublicKey)); err != nil {
+	if err := Verify(licenseMessage, signature.LicenseSignature, []byte(signature.PublicKey)); err != nil {
 		return nil, errors.Wrap(err, "failed to verify license signature")
 	}
 
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// LogFormatEnv selects the output of loggers that are created with NewLogger. When it's
+// LogFormatJSON, they write json events instead of text with spinners.
+const LogFormatEnv = "KOTS_LOG_FORMAT"
+
+const LogFormatJSON = "json"
+
+// log levels of json events
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelError = "error"
+)
+
+// statuses of steps, which are the actions that have spinners in text output
+const (
+	StatusStarted   = "started"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Event is a line of json output. Step is the action that the event is part of, and Status is
+// set when the step starts or finishes. ErrorChain has the message of each error that wraps the
+// cause, outermost first. ErrorCode and Remediation are set for the errors of pkg/util that kots
+// can suggest a fix for.
+type Event struct {
+	Time        time.Time `json:"time"`
+	Level       string    `json:"level"`
+	Msg         string    `json:"msg"`
+	Step        string    `json:"step,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	ErrorChain  []string  `json:"errorChain,omitempty"`
+	ErrorCode   string    `json:"errorCode,omitempty"`
+	Remediation string    `json:"remediation,omitempty"`
+}
+
+// JSON makes the logger write a json event on each line instead of text. Nothing is animated.
+func (l *Logger) JSON() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.isJSON = true
+}
+
+func isJSONFormatFromEnv() bool {
+	return os.Getenv(LogFormatEnv) == LogFormatJSON
+}
+
+// writeEvent writes the event when the logger writes json, and returns false when it doesn't so
+// that the caller writes text instead. Events without a message, for the blank lines of text
+// output, aren't written.
+func (l *Logger) writeEvent(event Event) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.isJSON {
+		return false
+	}
+	if l.isSilent || event.Msg == "" {
+		return true
+	}
+
+	l.writeEventLocked(event)
+	return true
+}
+
+// writeEventLocked writes the event, the lock must be held
+func (l *Logger) writeEventLocked(event Event) {
+	event.Time = time.Now().UTC()
+	if event.Step == "" && l.spinner != nil {
+		event.Step = l.spinner.msg
+	}
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	l.out.Write(append(b, '\n'))
+}
+
+// errorChain returns the messages of err and the errors it wraps, without the messages that are
+// repeated by wrappers that only add a stack
+func errorChain(err error) []string {
+	chain := []string{}
+	for err != nil {
+		msg := err.Error()
+		if len(chain) == 0 || chain[len(chain)-1] != msg {
+			chain = append(chain, msg)
+		}
+
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			err = nil
+		}
+	}
+	return chain
+}
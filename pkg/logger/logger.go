@@ -2,28 +2,82 @@ package logger
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/replicatedhq/kots/pkg/redact"
+	"github.com/replicatedhq/kots/pkg/util"
 	"github.com/tj/go-spin"
 )
 
+// Logger writes the progress of a command. It's safe to use from several goroutines: lines are
+// written one at a time, and on a terminal the spinner and the progress lines of running tasks are
+// redrawn below them. When the output isn't a terminal, nothing is animated or redrawn. Loggers
+// can write json events instead, see JSON.
 type Logger struct {
-	spinnerStopCh chan bool
-	spinnerMsg    string
-	spinnerArgs   []interface{}
-	isSilent      bool
-	isVerbose     bool
+	mu         sync.Mutex
+	out        io.Writer
+	isTerminal bool
+	isSilent   bool
+	isVerbose  bool
+	isJSON     bool
+
+	spinner *spinner
+	tasks   []*task
+	// renderedLines is the number of spinner and task lines that are on the terminal
+	renderedLines int
 }
 
+type spinner struct {
+	prefix string
+	msg    string
+	frames *spin.Spinner
+	frame  string
+	stopCh chan struct{}
+}
+
+type task struct {
+	name   string
+	status string
+}
+
+// NewLogger returns a logger that writes to stdout, with json events when LogFormatEnv is set
+// to LogFormatJSON
 func NewLogger() *Logger {
-	return &Logger{}
+	l := NewLoggerWithWriter(os.Stdout, isTerminal(os.Stdout))
+	if isJSONFormatFromEnv() {
+		l.JSON()
+	}
+	return l
+}
+
+// NewLoggerWithWriter returns a logger that writes to w. The spinner and progress lines are only
+// redrawn when isTerminal is set.
+func NewLoggerWithWriter(w io.Writer, isTerminal bool) *Logger {
+	return &Logger{
+		out:        w,
+		isTerminal: isTerminal,
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
 func (l *Logger) Silence() {
 	if l == nil {
 		return
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.isSilent = true
 }
 
@@ -31,185 +85,319 @@ func (l *Logger) Verbose() {
 	if l == nil {
 		return
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.isVerbose = true
 }
 
 func (l *Logger) Initialize() {
-	if l == nil || l.isSilent {
+	if l.writeEvent(Event{}) {
 		return
 	}
-
-	fmt.Println("")
+	l.println("")
 }
 
 func (l *Logger) Finish() {
-	if l == nil || l.isSilent {
+	if l.writeEvent(Event{}) {
 		return
 	}
-
-	fmt.Println("")
+	l.println("")
 }
 
+// Debug writes a message with the details of an error or a file. Secrets in it are redacted.
 func (l *Logger) Debug(msg string, args ...interface{}) {
-	if l == nil || l.isSilent {
+	if l == nil {
 		return
 	}
 
-	fmt.Printf("    ")
-	fmt.Println(fmt.Sprintf(msg, args...))
-	fmt.Println("")
+	// redacting is slow enough to matter when there are messages for every file
+	l.mu.Lock()
+	isSilent := l.isSilent
+	l.mu.Unlock()
+	if isSilent {
+		return
+	}
+
+	redacted := redact.RedactString(fmt.Sprintf(msg, args...))
+	if l.writeEvent(Event{Level: LevelDebug, Msg: redacted}) {
+		return
+	}
+	l.println("    "+redacted, "")
 }
 
 func (l *Logger) Info(msg string, args ...interface{}) {
-	if l == nil || l.isSilent || !l.isVerbose {
+	if l == nil {
 		return
 	}
 
-	fmt.Printf("    ")
-	fmt.Println(fmt.Sprintf(msg, args...))
-	fmt.Println("")
-}
+	l.mu.Lock()
+	isVerbose := l.isVerbose
+	l.mu.Unlock()
+	if !isVerbose {
+		return
+	}
 
-func (l *Logger) ActionWithoutSpinner(msg string, args ...interface{}) {
-	if l == nil || l.isSilent {
+	redacted := redact.RedactString(fmt.Sprintf(msg, args...))
+	if l.writeEvent(Event{Level: LevelInfo, Msg: redacted}) {
 		return
 	}
+	l.println("    "+redacted, "")
+}
 
+func (l *Logger) ActionWithoutSpinner(msg string, args ...interface{}) {
 	if msg == "" {
-		fmt.Println("")
+		if l.writeEvent(Event{}) {
+			return
+		}
+		l.println("")
 		return
 	}
 
-	fmt.Printf("  • ")
-	fmt.Println(fmt.Sprintf(msg, args...))
+	if l.writeEvent(Event{Level: LevelInfo, Msg: fmt.Sprintf(msg, args...)}) {
+		return
+	}
+	l.println("  • " + fmt.Sprintf(msg, args...))
 }
 
 func (l *Logger) ChildActionWithoutSpinner(msg string, args ...interface{}) {
-	if l == nil || l.isSilent {
+	if l.writeEvent(Event{Level: LevelInfo, Msg: fmt.Sprintf(msg, args...)}) {
 		return
 	}
-
-	fmt.Printf("    • ")
-	fmt.Println(fmt.Sprintf(msg, args...))
+	l.println("    • " + fmt.Sprintf(msg, args...))
 }
 
 func (l *Logger) ActionWithSpinner(msg string, args ...interface{}) {
-	if l == nil || l.isSilent {
+	l.startSpinner("  • ", fmt.Sprintf(msg, args...))
+}
+
+func (l *Logger) ChildActionWithSpinner(msg string, args ...interface{}) {
+	l.startSpinner("    • ", fmt.Sprintf(msg, args...))
+}
+
+func (l *Logger) FinishChildSpinner() {
+	l.finishSpinner("    • ", color.New(color.FgHiGreen).Sprint(" ✓"), StatusSucceeded)
+}
+
+func (l *Logger) FinishSpinner() {
+	l.finishSpinner("  • ", color.New(color.FgHiGreen).Sprint(" ✓"), StatusSucceeded)
+}
+
+func (l *Logger) FinishSpinnerWithError() {
+	l.finishSpinner("  • ", color.New(color.FgHiRed).Sprint(" ✗"), StatusFailed)
+}
+
+func (l *Logger) Error(err error) {
+	if err != nil && l.writeEvent(Event{Level: LevelError, Msg: err.Error(), Error: err.Error(), ErrorChain: errorChain(err), ErrorCode: string(util.ErrorCodeOf(err)), Remediation: util.Remediation(err)}) {
 		return
 	}
+	c := color.New(color.FgHiRed)
+	l.println(c.Sprint("  • ") + c.Sprint(fmt.Sprintf("%#v", err)))
+}
 
-	s := spin.New()
+// Progress sets the status of a task that runs concurrently with others, e.g. an image that is
+// being pushed. On a terminal, each running task has a line below the spinner that is updated in
+// place. Otherwise the status is written on its own line each time it changes.
+func (l *Logger) Progress(name string, msg string, args ...interface{}) {
+	if l == nil {
+		return
+	}
 
-	fmt.Printf("  • ")
-	fmt.Printf(msg, args...)
-	fmt.Printf(" %s", s.Next())
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.isSilent {
+		return
+	}
 
-	l.spinnerStopCh = make(chan bool)
-	l.spinnerMsg = msg
-	l.spinnerArgs = args
+	status := fmt.Sprintf(msg, args...)
 
-	go func() {
-		for {
-			select {
-			case <-l.spinnerStopCh:
-				return
-			case <-time.After(time.Millisecond * 100):
-				fmt.Printf("\r")
-				fmt.Printf("  • ")
-				fmt.Printf(msg, args...)
-				fmt.Printf(" %s", s.Next())
-			}
+	var t *task
+	for _, existing := range l.tasks {
+		if existing.name == name {
+			t = existing
+			break
 		}
-	}()
+	}
+	if t == nil {
+		t = &task{name: name}
+		l.tasks = append(l.tasks, t)
+	} else if t.status == status {
+		return
+	}
+	t.status = status
+
+	if l.isJSON {
+		l.writeEventLocked(Event{Level: LevelInfo, Msg: status, Step: name})
+		return
+	}
+	if !l.isTerminal {
+		l.writeLines(taskLine(t))
+		return
+	}
+	l.clearStatus()
+	l.renderStatus()
 }
 
-func (l *Logger) ChildActionWithSpinner(msg string, args ...interface{}) {
-	if l == nil || l.isSilent {
+// FinishProgress removes the status line of a task, the result is logged by the caller
+func (l *Logger) FinishProgress(name string) {
+	if l == nil {
 		return
 	}
 
-	s := spin.New()
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	fmt.Printf("    • ")
-	fmt.Printf(msg, args...)
-	fmt.Printf(" %s", s.Next())
+	for i, t := range l.tasks {
+		if t.name != name {
+			continue
+		}
 
-	l.spinnerStopCh = make(chan bool)
-	l.spinnerMsg = msg
-	l.spinnerArgs = args
+		l.clearStatus()
+		l.tasks = append(l.tasks[:i], l.tasks[i+1:]...)
+		l.renderStatus()
+		return
+	}
+}
+
+func (l *Logger) startSpinner(prefix string, msg string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.isSilent {
+		return
+	}
+
+	// a spinner that wasn't finished is replaced
+	if l.spinner != nil {
+		close(l.spinner.stopCh)
+	}
+
+	s := &spinner{
+		prefix: prefix,
+		msg:    msg,
+		frames: spin.New(),
+		stopCh: make(chan struct{}),
+	}
+	s.frame = s.frames.Next()
+	l.spinner = s
+
+	if l.isJSON {
+		l.writeEventLocked(Event{Level: LevelInfo, Msg: msg, Status: StatusStarted})
+		return
+	}
+	if !l.isTerminal {
+		l.writeLines(prefix + msg)
+		return
+	}
+
+	l.clearStatus()
+	l.renderStatus()
 
 	go func() {
 		for {
 			select {
-			case <-l.spinnerStopCh:
+			case <-s.stopCh:
 				return
 			case <-time.After(time.Millisecond * 100):
-				fmt.Printf("\r")
-				fmt.Printf("    • ")
-				fmt.Printf(msg, args...)
-				fmt.Printf(" %s", s.Next())
+				l.mu.Lock()
+				if l.spinner == s {
+					s.frame = s.frames.Next()
+					l.clearStatus()
+					l.renderStatus()
+				}
+				l.mu.Unlock()
 			}
 		}
 	}()
 }
 
-func (l *Logger) FinishChildSpinner() {
-	if l == nil || l.isSilent {
+func (l *Logger) finishSpinner(prefix string, mark string, status string) {
+	if l == nil {
 		return
 	}
 
-	green := color.New(color.FgHiGreen)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := l.spinner
+	if s == nil {
+		return
+	}
+	close(s.stopCh)
 
-	fmt.Printf("\r")
-	fmt.Printf("    • ")
-	fmt.Printf(l.spinnerMsg, l.spinnerArgs...)
-	green.Printf(" ✓")
-	fmt.Printf("  \n")
+	if l.isJSON {
+		level := LevelInfo
+		if status == StatusFailed {
+			level = LevelError
+		}
+		l.writeEventLocked(Event{Level: level, Msg: s.msg, Status: status})
+		l.spinner = nil
+		return
+	}
 
-	l.spinnerStopCh <- true
-	close(l.spinnerStopCh)
+	l.clearStatus()
+	l.spinner = nil
+	l.writeLines(prefix + s.msg + mark)
+	l.renderStatus()
 }
 
-func (l *Logger) FinishSpinner() {
-	if l == nil || l.isSilent {
+// println writes the lines above the spinner and progress lines
+func (l *Logger) println(lines ...string) {
+	if l == nil {
 		return
 	}
 
-	green := color.New(color.FgHiGreen)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.isSilent {
+		return
+	}
 
-	fmt.Printf("\r")
-	fmt.Printf("  • ")
-	fmt.Printf(l.spinnerMsg, l.spinnerArgs...)
-	green.Printf(" ✓")
-	fmt.Printf("  \n")
+	l.clearStatus()
+	l.writeLines(lines...)
+	l.renderStatus()
+}
 
-	l.spinnerStopCh <- true
-	close(l.spinnerStopCh)
+func (l *Logger) writeLines(lines ...string) {
+	for _, line := range lines {
+		fmt.Fprintln(l.out, line)
+	}
 }
 
-func (l *Logger) FinishSpinnerWithError() {
-	if l == nil || l.isSilent {
+// clearStatus erases the spinner and progress lines from the terminal, so that other lines can be
+// written in their place. The lock must be held.
+func (l *Logger) clearStatus() {
+	if !l.isTerminal || l.renderedLines == 0 {
 		return
 	}
 
-	red := color.New(color.FgHiRed)
-
-	fmt.Printf("\r")
-	fmt.Printf("  • ")
-	fmt.Printf(l.spinnerMsg, l.spinnerArgs...)
-	red.Printf(" ✗")
-	fmt.Printf("  \n")
-
-	l.spinnerStopCh <- true
-	close(l.spinnerStopCh)
+	// the cursor is at the end of the last line
+	fmt.Fprint(l.out, "\r\033[2K"+strings.Repeat("\033[1A\033[2K", l.renderedLines-1))
+	l.renderedLines = 0
 }
 
-func (l *Logger) Error(err error) {
-	if l == nil || l.isSilent {
+// renderStatus draws the spinner and progress lines on the terminal. The lock must be held.
+func (l *Logger) renderStatus() {
+	if !l.isTerminal || l.isSilent || l.isJSON {
 		return
 	}
 
-	c := color.New(color.FgHiRed)
-	c.Printf("  • ")
-	c.Println(fmt.Sprintf("%#v", err))
+	lines := []string{}
+	if l.spinner != nil {
+		lines = append(lines, fmt.Sprintf("%s%s %s", l.spinner.prefix, l.spinner.msg, l.spinner.frame))
+	}
+	for _, t := range l.tasks {
+		lines = append(lines, taskLine(t))
+	}
+
+	// the last line isn't ended, so that output that isn't written by the logger is written after
+	// it instead of being erased with the status
+	fmt.Fprint(l.out, strings.Join(lines, "\n"))
+	l.renderedLines = len(lines)
+}
+
+func taskLine(t *task) string {
+	return fmt.Sprintf("    • %s: %s", t.name, t.status)
 }
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NotTerminal(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLoggerWithWriter(&out, false)
+
+	log.ActionWithSpinner("Pulling %s", "upstream")
+	log.ChildActionWithoutSpinner("Found %d files", 3)
+	log.Progress("nginx", "transferring")
+	log.Progress("nginx", "transferring")
+	log.FinishProgress("nginx")
+	log.FinishSpinner()
+	log.FinishSpinner()
+
+	assert.Equal(t, strings.Join([]string{
+		"  • Pulling upstream",
+		"    • Found 3 files",
+		"    • nginx: transferring",
+		"  • Pulling upstream ✓",
+		"",
+	}, "\n"), out.String())
+}
+
+func Test_TerminalRedrawsStatus(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLoggerWithWriter(&out, true)
+
+	log.Progress("nginx", "transferring")
+	log.ChildActionWithoutSpinner("done")
+	log.FinishProgress("nginx")
+
+	assert.Equal(t, "    • nginx: transferring"+
+		"\r\033[2K"+"    • done\n"+"    • nginx: transferring"+
+		"\r\033[2K", out.String())
+}
+
+func Test_Silent(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLoggerWithWriter(&out, true)
+	log.Silence()
+
+	log.ActionWithSpinner("Pulling upstream")
+	log.Progress("nginx", "transferring")
+	log.Error(errors.New("failed"))
+	log.FinishSpinner()
+
+	assert.Empty(t, out.String())
+}
+
+func Test_ConcurrentLines(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLoggerWithWriter(&out, false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				log.ChildActionWithoutSpinner("task %d line %d", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	assert.Len(t, lines, 100)
+	for _, line := range lines {
+		var i, j int
+		_, err := fmt.Sscanf(line, "    • task %d line %d", &i, &j)
+		assert.NoError(t, err, line)
+	}
+}
+
+func Test_JSON(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLoggerWithWriter(&out, true)
+	log.JSON()
+
+	log.Initialize()
+	log.ActionWithSpinner("Pulling %s", "upstream")
+	log.ChildActionWithoutSpinner("Found %d files", 3)
+	log.Progress("nginx", "transferring")
+	log.FinishProgress("nginx")
+	log.Debug("password=%s", "hunter2")
+	log.FinishSpinner()
+	log.ActionWithSpinner("Creating base")
+	log.FinishSpinnerWithError()
+	log.Error(pkgerrors.Wrap(pkgerrors.Wrap(errors.New("connection refused"), "failed to dial"), "failed to upload"))
+	log.Finish()
+
+	events := []Event{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		event := Event{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		assert.False(t, event.Time.IsZero())
+		event.Time = time.Time{}
+		events = append(events, event)
+	}
+
+	assert.Equal(t, []Event{
+		{Level: LevelInfo, Msg: "Pulling upstream", Step: "Pulling upstream", Status: StatusStarted},
+		{Level: LevelInfo, Msg: "Found 3 files", Step: "Pulling upstream"},
+		{Level: LevelInfo, Msg: "transferring", Step: "nginx"},
+		{Level: LevelDebug, Msg: "password=" + redact.Mask("hunter2"), Step: "Pulling upstream"},
+		{Level: LevelInfo, Msg: "Pulling upstream", Step: "Pulling upstream", Status: StatusSucceeded},
+		{Level: LevelInfo, Msg: "Creating base", Step: "Creating base", Status: StatusStarted},
+		{Level: LevelError, Msg: "Creating base", Step: "Creating base", Status: StatusFailed},
+		{
+			Level:      LevelError,
+			Msg:        "failed to upload: failed to dial: connection refused",
+			Error:      "failed to upload: failed to dial: connection refused",
+			ErrorChain: []string{"failed to upload: failed to dial: connection refused", "failed to dial: connection refused", "connection refused"},
+		},
+	}, events)
+}
+
+func Test_JSONFromEnv(t *testing.T) {
+	os.Setenv(LogFormatEnv, LogFormatJSON)
+	defer os.Unsetenv(LogFormatEnv)
+
+	log := NewLogger()
+	assert.True(t, log.isJSON)
+}
@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	units "github.com/docker/go-units"
+)
+
+// units of progress
+const (
+	ProgressUnitBytes = "bytes"
+	ProgressUnitItems = "items"
+)
+
+// progressInterval is how often progress of bytes is reported, the last update is always reported
+const progressInterval = 250 * time.Millisecond
+
+// ProgressUpdate is the progress of a step of a long running operation. Total is 0 when it isn't
+// known, and ETA is 0 when it can't be estimated.
+type ProgressUpdate struct {
+	Step    string        `json:"step"`
+	Current int64         `json:"current"`
+	Total   int64         `json:"total,omitempty"`
+	Unit    string        `json:"unit"`
+	ETA     time.Duration `json:"eta,omitempty"`
+	Done    bool          `json:"done"`
+}
+
+// ProgressReporter receives the progress of operations such as uploads and image copies, so that
+// it can be shown by wrappers of the APIs. Report can be called from several goroutines.
+type ProgressReporter interface {
+	Report(update ProgressUpdate)
+}
+
+// NewTerminalProgressReporter returns a reporter that shows each step as a progress line of the
+// logger, until the step is done
+func NewTerminalProgressReporter(log *Logger) ProgressReporter {
+	return &terminalProgressReporter{log: log}
+}
+
+type terminalProgressReporter struct {
+	log *Logger
+}
+
+func (r *terminalProgressReporter) Report(update ProgressUpdate) {
+	if update.Done {
+		r.log.FinishProgress(update.Step)
+		return
+	}
+
+	msg := formatProgressAmount(update.Current, update.Unit)
+	if update.Total > 0 {
+		msg = fmt.Sprintf("%s of %s", msg, formatProgressAmount(update.Total, update.Unit))
+	}
+	if update.ETA > 0 {
+		msg = fmt.Sprintf("%s, %s left", msg, update.ETA.Round(time.Second))
+	}
+	r.log.Progress(update.Step, "%s", msg)
+}
+
+func formatProgressAmount(n int64, unit string) string {
+	if unit == ProgressUnitBytes {
+		return units.HumanSize(float64(n))
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// NewJSONProgressReporter returns a reporter that writes each update as a line of json, with the
+// eta in seconds
+func NewJSONProgressReporter(w io.Writer) ProgressReporter {
+	return &jsonProgressReporter{w: w}
+}
+
+type jsonProgressReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (r *jsonProgressReporter) Report(update ProgressUpdate) {
+	line := struct {
+		ProgressUpdate
+		ETA int64 `json:"eta,omitempty"`
+	}{
+		ProgressUpdate: update,
+		ETA:            int64(update.ETA.Seconds()),
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Write(append(b, '\n'))
+}
+
+// ProgressTracker reports the progress of a step, with an eta from the rate so far. A tracker
+// with a nil reporter does nothing.
+type ProgressTracker struct {
+	mu         sync.Mutex
+	reporter   ProgressReporter
+	step       string
+	unit       string
+	total      int64
+	current    int64
+	start      time.Time
+	lastReport time.Time
+	now        func() time.Time
+}
+
+func NewProgressTracker(reporter ProgressReporter, step string, unit string, total int64) *ProgressTracker {
+	return &ProgressTracker{
+		reporter: reporter,
+		step:     step,
+		unit:     unit,
+		total:    total,
+		start:    time.Now(),
+		now:      time.Now,
+	}
+}
+
+// Add adds n to the progress. Progress of bytes is reported at most every progressInterval.
+func (t *ProgressTracker) Add(n int64) {
+	if t == nil || t.reporter == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.current += n
+	now := t.now()
+	if t.unit == ProgressUnitBytes && now.Sub(t.lastReport) < progressInterval && t.current != t.total {
+		t.mu.Unlock()
+		return
+	}
+	t.lastReport = now
+	update := t.update(now)
+	t.mu.Unlock()
+
+	t.reporter.Report(update)
+}
+
+// Done reports that the step is done, whether it succeeded or not
+func (t *ProgressTracker) Done() {
+	if t == nil || t.reporter == nil {
+		return
+	}
+
+	t.mu.Lock()
+	update := t.update(t.now())
+	t.mu.Unlock()
+
+	update.ETA = 0
+	update.Done = true
+	t.reporter.Report(update)
+}
+
+func (t *ProgressTracker) update(now time.Time) ProgressUpdate {
+	update := ProgressUpdate{
+		Step:    t.step,
+		Current: t.current,
+		Total:   t.total,
+		Unit:    t.unit,
+	}
+	if t.total > 0 && t.current > 0 && t.current < t.total {
+		elapsed := now.Sub(t.start)
+		update.ETA = time.Duration(float64(elapsed) * float64(t.total-t.current) / float64(t.current))
+	}
+	return update
+}
+
+// Reader returns a reader that adds the bytes that are read from r to the progress
+func (t *ProgressTracker) Reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, tracker: t}
+}
+
+type progressReader struct {
+	r       io.Reader
+	tracker *ProgressTracker
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.tracker.Add(int64(n))
+	}
+	return n, err
+}
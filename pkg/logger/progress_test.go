@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingReporter struct {
+	mu      sync.Mutex
+	updates []ProgressUpdate
+}
+
+func (r *recordingReporter) Report(update ProgressUpdate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates = append(r.updates, update)
+}
+
+func Test_ProgressTracker(t *testing.T) {
+	reporter := &recordingReporter{}
+	tracker := NewProgressTracker(reporter, "Uploading archive", ProgressUnitBytes, 400)
+
+	now := tracker.start
+	tracker.now = func() time.Time { return now }
+
+	now = now.Add(time.Second)
+	tracker.Add(100)
+	// bytes aren't reported more often than the interval
+	now = now.Add(100 * time.Millisecond)
+	tracker.Add(100)
+	now = now.Add(time.Second)
+	tracker.Add(100)
+	// the last bytes are always reported
+	tracker.Add(100)
+	tracker.Done()
+
+	assert.Equal(t, []ProgressUpdate{
+		{Step: "Uploading archive", Current: 100, Total: 400, Unit: ProgressUnitBytes, ETA: 3 * time.Second},
+		{Step: "Uploading archive", Current: 300, Total: 400, Unit: ProgressUnitBytes, ETA: 700 * time.Millisecond},
+		{Step: "Uploading archive", Current: 400, Total: 400, Unit: ProgressUnitBytes},
+		{Step: "Uploading archive", Current: 400, Total: 400, Unit: ProgressUnitBytes, Done: true},
+	}, reporter.updates)
+
+	// without a reporter, nothing is done
+	var nilTracker *ProgressTracker
+	nilTracker.Add(1)
+	nilTracker.Done()
+	NewProgressTracker(nil, "Creating archive", ProgressUnitItems, 0).Done()
+}
+
+func Test_ProgressTrackerReader(t *testing.T) {
+	reporter := &recordingReporter{}
+	tracker := NewProgressTracker(reporter, "Uploading archive", ProgressUnitBytes, 5)
+
+	var out bytes.Buffer
+	_, err := out.ReadFrom(tracker.Reader(strings.NewReader("hello")))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", out.String())
+
+	require.NotEmpty(t, reporter.updates)
+	assert.Equal(t, int64(5), reporter.updates[len(reporter.updates)-1].Current)
+}
+
+func Test_JSONProgressReporter(t *testing.T) {
+	var out bytes.Buffer
+	reporter := NewJSONProgressReporter(&out)
+
+	reporter.Report(ProgressUpdate{Step: "Transferring images", Current: 1, Total: 4, Unit: ProgressUnitItems, ETA: 90 * time.Second})
+	reporter.Report(ProgressUpdate{Step: "Transferring images", Current: 4, Total: 4, Unit: ProgressUnitItems, Done: true})
+
+	assert.Equal(t, `{"step":"Transferring images","current":1,"total":4,"unit":"items","done":false,"eta":90}
+{"step":"Transferring images","current":4,"total":4,"unit":"items","done":true}
+`, out.String())
+}
+
+func Test_TerminalProgressReporter(t *testing.T) {
+	var out bytes.Buffer
+	reporter := NewTerminalProgressReporter(NewLoggerWithWriter(&out, false))
+
+	reporter.Report(ProgressUpdate{Step: "Uploading archive", Current: 2000000, Total: 8000000, Unit: ProgressUnitBytes, ETA: 3 * time.Second})
+	reporter.Report(ProgressUpdate{Step: "Transferring images", Current: 1, Unit: ProgressUnitItems})
+	reporter.Report(ProgressUpdate{Step: "Uploading archive", Done: true})
+
+	assert.Equal(t, strings.Join([]string{
+		"    • Uploading archive: 2MB of 8MB, 3s left",
+		"    • Transferring images: 1",
+		"",
+	}, "\n"), out.String())
+}
@@ -1,6 +1,8 @@
 package midstream
 
 import (
+	"fmt"
+
 	"sigs.k8s.io/kustomize/v3/pkg/image"
 	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
 )
@@ -58,3 +60,89 @@ func findNewStrings(new []string, existing []string) []string {
 
 	return newStrings
 }
+
+func findNewJson6902Patches(new []kustomizetypes.PatchJson6902, existing []kustomizetypes.PatchJson6902) []kustomizetypes.PatchJson6902 {
+	newPatches := make([]kustomizetypes.PatchJson6902, 0)
+	keys := make(map[string]bool)
+
+	for _, e := range existing {
+		keys[json6902Key(e)] = true
+	}
+
+	for _, n := range new {
+		if _, exists := keys[json6902Key(n)]; !exists {
+			keys[json6902Key(n)] = true
+			newPatches = append(newPatches, n)
+		}
+	}
+
+	return newPatches
+}
+
+// json6902Key identifies a json patch by its file and the object that it's applied to, the same file
+// can patch several objects
+func json6902Key(patch kustomizetypes.PatchJson6902) string {
+	if patch.Target == nil {
+		return patch.Path
+	}
+	t := patch.Target
+	return fmt.Sprintf("%s/%s/%s/%s/%s:%s", t.Group, t.Version, t.Kind, t.Namespace, t.Name, patch.Path)
+}
+
+func findNewConfigMapGenerators(new []kustomizetypes.ConfigMapArgs, existing []kustomizetypes.ConfigMapArgs) []kustomizetypes.ConfigMapArgs {
+	newGenerators := make([]kustomizetypes.ConfigMapArgs, 0)
+	names := make(map[string]bool)
+
+	for _, e := range existing {
+		names[generatorKey(e.GeneratorArgs)] = true
+	}
+
+	for _, n := range new {
+		if _, exists := names[generatorKey(n.GeneratorArgs)]; !exists {
+			names[generatorKey(n.GeneratorArgs)] = true
+			newGenerators = append(newGenerators, n)
+		}
+	}
+
+	return newGenerators
+}
+
+func findNewSecretGenerators(new []kustomizetypes.SecretArgs, existing []kustomizetypes.SecretArgs) []kustomizetypes.SecretArgs {
+	newGenerators := make([]kustomizetypes.SecretArgs, 0)
+	names := make(map[string]bool)
+
+	for _, e := range existing {
+		names[generatorKey(e.GeneratorArgs)] = true
+	}
+
+	for _, n := range new {
+		if _, exists := names[generatorKey(n.GeneratorArgs)]; !exists {
+			names[generatorKey(n.GeneratorArgs)] = true
+			newGenerators = append(newGenerators, n)
+		}
+	}
+
+	return newGenerators
+}
+
+func generatorKey(args kustomizetypes.GeneratorArgs) string {
+	return args.Namespace + "/" + args.Name
+}
+
+func findNewVars(new []kustomizetypes.Var, existing []kustomizetypes.Var) []kustomizetypes.Var {
+	newVars := make([]kustomizetypes.Var, 0)
+	names := make(map[string]bool)
+
+	for _, e := range existing {
+		names[e.Name] = true
+	}
+
+	for _, n := range new {
+		if _, exists := names[n.Name]; !exists {
+			names[n.Name] = true
+			newVars = append(newVars, n)
+		}
+	}
+
+	return newVars
+}
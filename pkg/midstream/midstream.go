@@ -13,9 +13,16 @@ type Midstream struct {
 	Base          *base.Base
 	DocForPatches []*k8sdoc.Doc
 	PullSecret    *corev1.Secret
+
+	// PullSecretNamespaces are the namespaces, other than the namespace of the
+	// PullSecret, that need a copy of the pull secret
+	PullSecretNamespaces []string
+
+	// ReconcileReport is set when writing the midstream updated an existing kustomization
+	ReconcileReport *ReconcileReport
 }
 
-func CreateMidstream(b *base.Base, images []image.Image, objects []*k8sdoc.Doc, pullSecret *corev1.Secret) (*Midstream, error) {
+func CreateMidstream(b *base.Base, images []image.Image, objects []*k8sdoc.Doc, pullSecret *corev1.Secret, additionalNamespaces []string) (*Midstream, error) {
 	kustomization := kustomizetypes.Kustomization{
 		TypeMeta: kustomizetypes.TypeMeta{
 			APIVersion: "kustomize.config.k8s.io/v1beta1",
@@ -35,5 +42,15 @@ func CreateMidstream(b *base.Base, images []image.Image, objects []*k8sdoc.Doc,
 		PullSecret:    pullSecret,
 	}
 
+	if pullSecret != nil {
+		namespaces := append(findNamespaces(b), additionalNamespaces...)
+		for _, namespace := range uniqueNamespaces(namespaces) {
+			if namespace == pullSecret.Namespace {
+				continue
+			}
+			m.PullSecretNamespaces = append(m.PullSecretNamespaces, namespace)
+		}
+	}
+
 	return &m, nil
 }
@@ -0,0 +1,276 @@
+package midstream
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// ServiceEnvAnnotation lists the environment variables of a workload whose values reference services
+// of the application by name, e.g. "DB_HOST,CACHE_URL". Kustomize renames references in the fields it
+// knows about when a name prefix or suffix is set, but not in environment variables.
+const ServiceEnvAnnotation = "kots.io/service-env"
+
+const serviceEnvFilename = "service-env.yaml"
+
+var (
+	serviceNameCandidate = regexp.MustCompile(`[a-z0-9]([-a-z0-9]*[a-z0-9])?`)
+	validNameAffix       = regexp.MustCompile(`^[-a-z0-9]*$`)
+)
+
+// ValidateNames checks that the name prefix and suffix only have characters that are allowed in the
+// names of all kinds
+func ValidateNames(prefix string, suffix string) error {
+	if !validNameAffix.MatchString(prefix) {
+		return errors.Errorf("invalid name prefix %q, it can only contain lowercase letters, numbers and dashes", prefix)
+	}
+	if !validNameAffix.MatchString(suffix) {
+		return errors.Errorf("invalid name suffix %q, it can only contain lowercase letters, numbers and dashes", suffix)
+	}
+	return nil
+}
+
+// SetInstanceName names the pull secret for an app instance and labels it with the instance name, so
+// that the pull secrets of apps that are installed in the same namespace don't replace each other
+func (m *Midstream) SetInstanceName(instanceName string) {
+	if m.PullSecret == nil || instanceName == "" {
+		return
+	}
+
+	m.PullSecret.Name = util.InstanceObjectName(instanceName, m.PullSecret.Name)
+	if m.PullSecret.Labels == nil {
+		m.PullSecret.Labels = map[string]string{}
+	}
+	m.PullSecret.Labels[util.InstanceLabelKey] = instanceName
+}
+
+// inheritNames keeps the name prefix and suffix of an existing kustomization when they aren't set,
+// so that writing the midstream again, e.g. when images are rewritten, doesn't rename the objects
+func (m *Midstream) inheritNames(existing *kustomizetypes.Kustomization) {
+	if existing == nil {
+		return
+	}
+	if m.Kustomization.NamePrefix == "" {
+		m.Kustomization.NamePrefix = existing.NamePrefix
+	}
+	if m.Kustomization.NameSuffix == "" {
+		m.Kustomization.NameSuffix = existing.NameSuffix
+	}
+}
+
+// writeServiceEnvPatches writes patches that rename the services referenced in the environment variables
+// listed in the ServiceEnvAnnotation of each workload, to match the name prefix and suffix
+func (m *Midstream) writeServiceEnvPatches(options WriteOptions) (string, error) {
+	prefix, suffix := m.Kustomization.NamePrefix, m.Kustomization.NameSuffix
+	if (prefix == "" && suffix == "") || m.Base == nil {
+		return "", nil
+	}
+
+	patches, err := serviceEnvPatches(m.Base.Files, prefix, suffix)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create patches")
+	}
+	if len(patches) == 0 {
+		return "", nil
+	}
+
+	docs := [][]byte{}
+	for _, patch := range patches {
+		b, err := yaml.Marshal(patch)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal patch")
+		}
+		docs = append(docs, b)
+	}
+
+	if err := options.FileModes.WriteFile(filepath.Join(options.MidstreamDir, serviceEnvFilename), bytes.Join(docs, []byte("---\n"))); err != nil {
+		return "", errors.Wrap(err, "failed to write patches")
+	}
+
+	return serviceEnvFilename, nil
+}
+
+type serviceEnvObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name        string            `json:"name"`
+		Namespace   string            `json:"namespace"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+func serviceEnvPatches(files []base.BaseFile, prefix string, suffix string) ([]map[string]interface{}, error) {
+	services := map[string]bool{}
+	objects := []serviceEnvObject{}
+	contents := [][]byte{}
+	for _, file := range files {
+		o := serviceEnvObject{}
+		if err := k8syaml.Unmarshal(file.Content, &o); err != nil {
+			continue
+		}
+		if o.Kind == "Service" {
+			services[o.Metadata.Name] = true
+		}
+		if o.Metadata.Annotations[ServiceEnvAnnotation] != "" {
+			objects = append(objects, o)
+			contents = append(contents, file.Content)
+		}
+	}
+
+	patches := []map[string]interface{}{}
+	for i, o := range objects {
+		envNames := map[string]bool{}
+		for _, name := range strings.Split(o.Metadata.Annotations[ServiceEnvAnnotation], ",") {
+			envNames[strings.TrimSpace(name)] = true
+		}
+
+		obj := map[string]interface{}{}
+		if err := k8syaml.Unmarshal(contents[i], &obj); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s %s", o.Kind, o.Metadata.Name)
+		}
+
+		podSpecPath := podSpecPathForKind(o.Kind)
+		podSpec, ok := fieldAtPath(obj, podSpecPath)
+		if !ok {
+			continue
+		}
+
+		podSpecPatch := map[string]interface{}{}
+		for _, containersField := range []string{"containers", "initContainers"} {
+			containersPatch := renamedContainerEnv(podSpec[containersField], envNames, services, prefix, suffix)
+			if len(containersPatch) > 0 {
+				podSpecPatch[containersField] = containersPatch
+			}
+		}
+		if len(podSpecPatch) == 0 {
+			continue
+		}
+
+		var current interface{} = podSpecPatch
+		fields := strings.Split(podSpecPath, ".")
+		for j := len(fields) - 1; j >= 0; j-- {
+			current = map[string]interface{}{
+				fields[j]: current,
+			}
+		}
+
+		metadata := map[string]interface{}{
+			"name": o.Metadata.Name,
+		}
+		if o.Metadata.Namespace != "" {
+			metadata["namespace"] = o.Metadata.Namespace
+		}
+
+		patch := current.(map[string]interface{})
+		patch["apiVersion"] = o.APIVersion
+		patch["kind"] = o.Kind
+		patch["metadata"] = metadata
+		patches = append(patches, patch)
+	}
+
+	return patches, nil
+}
+
+// renamedContainerEnv returns the containers with only the environment variables that were renamed
+func renamedContainerEnv(containers interface{}, envNames map[string]bool, services map[string]bool, prefix string, suffix string) []interface{} {
+	list, ok := containers.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	patched := []interface{}{}
+	for _, c := range list {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		env, _ := container["env"].([]interface{})
+
+		envPatch := []interface{}{}
+		for _, e := range env {
+			envVar, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := envVar["name"].(string)
+			value, _ := envVar["value"].(string)
+			if !envNames[name] || value == "" {
+				continue
+			}
+
+			renamed := renameServiceReferences(value, services, prefix, suffix)
+			if renamed == value {
+				continue
+			}
+			envPatch = append(envPatch, map[string]interface{}{
+				"name":  name,
+				"value": renamed,
+			})
+		}
+
+		if len(envPatch) > 0 {
+			patched = append(patched, map[string]interface{}{
+				"name": container["name"],
+				"env":  envPatch,
+			})
+		}
+	}
+
+	return patched
+}
+
+// renameServiceReferences renames the services in a host name or url, e.g. "db", "db:5432",
+// "db.namespace.svc" or "postgres://user@db:5432/app", but not in the scheme, path or domain
+func renameServiceReferences(value string, services map[string]bool, prefix string, suffix string) string {
+	result := ""
+	last := 0
+	for _, match := range serviceNameCandidate.FindAllStringIndex(value, -1) {
+		start, end := match[0], match[1]
+		name := value[start:end]
+		if !services[name] {
+			continue
+		}
+		if start > 0 && !strings.HasSuffix(value[:start], "@") && !strings.HasSuffix(value[:start], "//") {
+			continue
+		}
+		if end < len(value) && (!strings.ContainsAny(value[end:end+1], ".:/") || strings.HasPrefix(value[end:], "://")) {
+			continue
+		}
+
+		result += value[last:start] + prefix + name + suffix
+		last = end
+	}
+
+	return result + value[last:]
+}
+
+func podSpecPathForKind(kind string) string {
+	for _, location := range k8sdoc.DefaultImageLocations() {
+		if location.Kind == kind {
+			return location.PodSpecPath
+		}
+	}
+	return "spec.template.spec"
+}
+
+func fieldAtPath(obj map[string]interface{}, path string) (map[string]interface{}, bool) {
+	current := obj
+	for _, field := range strings.Split(path, ".") {
+		next, ok := current[field].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
@@ -0,0 +1,173 @@
+package midstream
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_renameServiceReferences(t *testing.T) {
+	services := map[string]bool{"db": true, "postgres": true, "cache": true}
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "db", expected: "team-a-db-prod"},
+		{value: "db:5432", expected: "team-a-db-prod:5432"},
+		{value: "db.default.svc.cluster.local", expected: "team-a-db-prod.default.svc.cluster.local"},
+		{value: "postgres://user@postgres:5432/db", expected: "postgres://user@team-a-postgres-prod:5432/db"},
+		{value: "http://cache/db", expected: "http://team-a-cache-prod/db"},
+		{value: "other.db", expected: "other.db"},
+		{value: "dbx", expected: "dbx"},
+		{value: "cache,db", expected: "cache,db"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			assert.Equal(t, test.expected, renameServiceReferences(test.value, services, "team-a-", "-prod"))
+		})
+	}
+}
+
+func Test_serviceEnvPatches(t *testing.T) {
+	files := []base.BaseFile{
+		{
+			Path:    "service.yaml",
+			Content: []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: db\n"),
+		},
+		{
+			Path: "deployment.yaml",
+			Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    kots.io/service-env: DB_HOST, DB_URL
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        env:
+        - name: DB_HOST
+          value: db
+        - name: DB_URL
+          value: postgres://db:5432/app
+        - name: OTHER_HOST
+          value: db
+      - name: sidecar
+        env:
+        - name: DB_HOST
+          value: external.example.com
+`),
+		},
+		{
+			Path: "cronjob.yaml",
+			Content: []byte(`apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: cleanup
+  namespace: jobs
+  annotations:
+    kots.io/service-env: DB_HOST
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          initContainers:
+          - name: wait
+            env:
+            - name: DB_HOST
+              value: db.default.svc
+`),
+		},
+		{
+			Path:    "unannotated.yaml",
+			Content: []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod\nspec:\n  containers:\n  - name: pod\n    env:\n    - name: DB_HOST\n      value: db\n"),
+		},
+	}
+
+	patches, err := serviceEnvPatches(files, "a-", "")
+	require.NoError(t, err)
+
+	expected := []map[string]interface{}{
+		{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{
+								"name": "web",
+								"env": []interface{}{
+									map[string]interface{}{"name": "DB_HOST", "value": "a-db"},
+									map[string]interface{}{"name": "DB_URL", "value": "postgres://a-db:5432/app"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"apiVersion": "batch/v1beta1",
+			"kind":       "CronJob",
+			"metadata":   map[string]interface{}{"name": "cleanup", "namespace": "jobs"},
+			"spec": map[string]interface{}{
+				"jobTemplate": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"initContainers": []interface{}{
+									map[string]interface{}{
+										"name": "wait",
+										"env": []interface{}{
+											map[string]interface{}{"name": "DB_HOST", "value": "a-db.default.svc"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, patches)
+}
+
+func Test_ValidateNames(t *testing.T) {
+	assert.NoError(t, ValidateNames("", ""))
+	assert.NoError(t, ValidateNames("team-a-", "-2"))
+	assert.Error(t, ValidateNames("Team_A", ""))
+	assert.Error(t, ValidateNames("", ".prod"))
+}
+
+func Test_SetInstanceName(t *testing.T) {
+	pullSecret, err := registry.PullSecretForRegistries([]string{"registry.example.com"}, "user", "pass", "default")
+	require.NoError(t, err)
+
+	m := &Midstream{PullSecret: pullSecret}
+	m.SetInstanceName("")
+	assert.Equal(t, "kotsadm-replicated-registry", m.PullSecret.Name)
+	assert.Empty(t, m.PullSecret.Labels)
+
+	m.SetInstanceName("my-app")
+	assert.Equal(t, "my-app-kotsadm-replicated-registry", m.PullSecret.Name)
+	assert.Equal(t, map[string]string{"kots.io/instance": "my-app"}, m.PullSecret.Labels)
+
+	// objects are patched to pull with the renamed secret
+	patch := obejctWithPullSecret(&k8sdoc.Doc{Kind: "Pod", PodSpecPath: "spec"}, m.PullSecret)
+	require.NotNil(t, patch)
+	assert.Equal(t, map[string]interface{}{
+		"imagePullSecrets": []map[string]string{{"name": "my-app-kotsadm-replicated-registry"}},
+	}, patch["spec"])
+}
@@ -0,0 +1,50 @@
+package midstream
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// findNamespaces returns all namespaces created or referenced by objects in the base
+func findNamespaces(b *base.Base) []string {
+	if b == nil {
+		return []string{}
+	}
+
+	namespaces := []string{}
+	for _, file := range b.Files {
+		yamlDocs := bytes.Split(file.Content, []byte("\n---\n"))
+		for _, yamlDoc := range yamlDocs {
+			doc := k8sdoc.Doc{}
+			if err := yaml.Unmarshal(yamlDoc, &doc); err != nil {
+				continue
+			}
+
+			if doc.APIVersion == "v1" && doc.Kind == "Namespace" {
+				namespaces = append(namespaces, doc.Metadata.Name)
+			}
+			namespaces = append(namespaces, doc.Metadata.Namespace)
+		}
+	}
+
+	return uniqueNamespaces(namespaces)
+}
+
+func uniqueNamespaces(namespaces []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, namespace := range namespaces {
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		unique = append(unique, namespace)
+	}
+
+	sort.Strings(unique)
+	return unique
+}
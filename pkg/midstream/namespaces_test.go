@@ -0,0 +1,72 @@
+package midstream
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_findNamespaces(t *testing.T) {
+	b := &base.Base{
+		Files: []base.BaseFile{
+			{
+				Path: "namespace.yaml",
+				Content: []byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: monitoring`),
+			},
+			{
+				Path: "deployment.yaml",
+				Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: backend
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app`),
+			},
+			{
+				Path: "other.yaml",
+				Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+  namespace: backend`),
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"backend", "monitoring"}, findNamespaces(b))
+}
+
+func Test_CreateMidstreamPullSecretNamespaces(t *testing.T) {
+	b := &base.Base{
+		Files: []base.BaseFile{
+			{
+				Path: "deployment.yaml",
+				Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: backend`),
+			},
+		},
+	}
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kotsadm-replicated-registry",
+			Namespace: "default",
+		},
+	}
+
+	m, err := CreateMidstream(b, nil, nil, pullSecret, []string{"jobs", "default", "backend"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backend", "jobs"}, m.PullSecretNamespaces)
+}
@@ -0,0 +1,191 @@
+package midstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/v3/pkg/image"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+// generatedMarkerFilename records which kustomization entries were written by kots,
+// so that they can be told apart from entries added by the user
+const generatedMarkerFilename = ".kots-generated.yaml"
+
+type generatedEntries struct {
+	Resources             []string `yaml:"resources,omitempty"`
+	PatchesStrategicMerge []string `yaml:"patchesStrategicMerge,omitempty"`
+	Images                []string `yaml:"images,omitempty"`
+}
+
+// ReconcileReport describes the changes made to an existing midstream kustomization
+type ReconcileReport struct {
+	// Pruned entries were generated by a previous version and are no longer needed
+	PrunedResources []string
+	PrunedPatches   []string
+	PrunedImages    []string
+
+	// Preserved entries were added by the user
+	PreservedResources []string
+	PreservedPatches   []string
+	PreservedImages    []string
+}
+
+func (r *ReconcileReport) HasPruned() bool {
+	return len(r.PrunedResources)+len(r.PrunedPatches)+len(r.PrunedImages) > 0
+}
+
+// Log writes the pruned and preserved entries, if there are any
+func (r *ReconcileReport) Log(log *logger.Logger) {
+	if r == nil {
+		return
+	}
+
+	for _, resource := range r.PrunedResources {
+		log.Info("Removed resource %q from midstream, it is no longer generated", resource)
+	}
+	for _, patch := range r.PrunedPatches {
+		log.Info("Removed patch %q from midstream, it is no longer generated", patch)
+	}
+	for _, i := range r.PrunedImages {
+		log.Info("Removed image %q from midstream, it is no longer generated", i)
+	}
+
+	numPreserved := len(r.PreservedResources) + len(r.PreservedPatches) + len(r.PreservedImages)
+	if numPreserved > 0 {
+		log.Info("Kept %d user defined entries in midstream", numPreserved)
+	}
+}
+
+func generatedEntriesFromKustomization(k *kustomizetypes.Kustomization) generatedEntries {
+	entries := generatedEntries{}
+	entries.Resources = append(entries.Resources, k.Resources...)
+	for _, patch := range k.PatchesStrategicMerge {
+		entries.PatchesStrategicMerge = append(entries.PatchesStrategicMerge, string(patch))
+	}
+	for _, i := range k.Images {
+		entries.Images = append(entries.Images, i.Name)
+	}
+	return entries
+}
+
+func readGeneratedMarker(midstreamDir string) (*generatedEntries, error) {
+	content, err := ioutil.ReadFile(filepath.Join(midstreamDir, generatedMarkerFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read marker file")
+	}
+
+	entries := generatedEntries{}
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal marker file")
+	}
+	return &entries, nil
+}
+
+func writeGeneratedMarker(midstreamDir string, entries generatedEntries, modes util.FileModes) error {
+	b, err := yaml.Marshal(entries)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal marker file")
+	}
+
+	if err := modes.WriteFile(filepath.Join(midstreamDir, generatedMarkerFilename), b); err != nil {
+		return errors.Wrap(err, "failed to write marker file")
+	}
+	return nil
+}
+
+// reconcileKustomization removes the entries from the existing kustomization that were
+// generated previously but are not generated anymore. Entries that were never generated
+// by kots are kept. Without a record of previously generated entries nothing is removed.
+func reconcileKustomization(existing *kustomizetypes.Kustomization, previous *generatedEntries, current generatedEntries) *ReconcileReport {
+	report := &ReconcileReport{}
+
+	previousResources, previousPatches, previousImages := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	if previous != nil {
+		previousResources = stringSet(previous.Resources)
+		previousPatches = stringSet(previous.PatchesStrategicMerge)
+		previousImages = stringSet(previous.Images)
+	}
+	currentResources := stringSet(current.Resources)
+	currentPatches := stringSet(current.PatchesStrategicMerge)
+	currentImages := stringSet(current.Images)
+
+	resources := make([]string, 0)
+	for _, resource := range existing.Resources {
+		switch {
+		case currentResources[resource]:
+			resources = append(resources, resource)
+		case previousResources[resource]:
+			report.PrunedResources = append(report.PrunedResources, resource)
+		default:
+			report.PreservedResources = append(report.PreservedResources, resource)
+			resources = append(resources, resource)
+		}
+	}
+	existing.Resources = resources
+
+	patches := make([]kustomizetypes.PatchStrategicMerge, 0)
+	for _, patch := range existing.PatchesStrategicMerge {
+		switch {
+		case currentPatches[string(patch)]:
+			patches = append(patches, patch)
+		case previousPatches[string(patch)]:
+			report.PrunedPatches = append(report.PrunedPatches, string(patch))
+		default:
+			report.PreservedPatches = append(report.PreservedPatches, string(patch))
+			patches = append(patches, patch)
+		}
+	}
+	existing.PatchesStrategicMerge = patches
+
+	images := make([]image.Image, 0)
+	for _, i := range existing.Images {
+		switch {
+		case currentImages[i.Name]:
+			images = append(images, i)
+		case previousImages[i.Name]:
+			report.PrunedImages = append(report.PrunedImages, i.Name)
+		default:
+			report.PreservedImages = append(report.PreservedImages, i.Name)
+			images = append(images, i)
+		}
+	}
+	existing.Images = images
+
+	return report
+}
+
+// removePrunedFiles deletes files that kots wrote for entries that have been pruned
+func removePrunedFiles(midstreamDir string, report *ReconcileReport) error {
+	pruned := []string{}
+	pruned = append(pruned, report.PrunedResources...)
+	pruned = append(pruned, report.PrunedPatches...)
+	for _, filename := range pruned {
+		if filename != secretFilename && filename != patchesFilename {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(midstreamDir, filename))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %s", filename)
+		}
+	}
+
+	return nil
+}
+
+func stringSet(list []string) map[string]bool {
+	set := map[string]bool{}
+	for _, s := range list {
+		set[s] = true
+	}
+	return set
+}
@@ -0,0 +1,82 @@
+package midstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/v3/pkg/image"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+func Test_reconcileKustomization(t *testing.T) {
+	tests := []struct {
+		name           string
+		existing       kustomizetypes.Kustomization
+		previous       *generatedEntries
+		current        generatedEntries
+		expected       kustomizetypes.Kustomization
+		expectedReport ReconcileReport
+	}{
+		{
+			name: "prunes entries that are no longer generated",
+			existing: kustomizetypes.Kustomization{
+				Resources:             []string{"secret.yaml", "my-configmap.yaml"},
+				PatchesStrategicMerge: []kustomizetypes.PatchStrategicMerge{"pullsecrets.yaml", "my-patch.yaml"},
+				Images: []image.Image{
+					{Name: "quay.io/replicatedcom/old", NewName: "proxy.replicated.com/proxy/app/quay.io/replicatedcom/old"},
+					{Name: "nginx", NewTag: "1.17"},
+				},
+			},
+			previous: &generatedEntries{
+				Resources:             []string{"secret.yaml"},
+				PatchesStrategicMerge: []string{"pullsecrets.yaml"},
+				Images:                []string{"quay.io/replicatedcom/old"},
+			},
+			current: generatedEntries{
+				Resources: []string{"secret.yaml"},
+			},
+			expected: kustomizetypes.Kustomization{
+				Resources:             []string{"secret.yaml", "my-configmap.yaml"},
+				PatchesStrategicMerge: []kustomizetypes.PatchStrategicMerge{"my-patch.yaml"},
+				Images: []image.Image{
+					{Name: "nginx", NewTag: "1.17"},
+				},
+			},
+			expectedReport: ReconcileReport{
+				PrunedPatches:      []string{"pullsecrets.yaml"},
+				PrunedImages:       []string{"quay.io/replicatedcom/old"},
+				PreservedResources: []string{"my-configmap.yaml"},
+				PreservedPatches:   []string{"my-patch.yaml"},
+				PreservedImages:    []string{"nginx"},
+			},
+		},
+		{
+			name: "keeps everything without a marker file",
+			existing: kustomizetypes.Kustomization{
+				Resources:             []string{"secret.yaml"},
+				PatchesStrategicMerge: []kustomizetypes.PatchStrategicMerge{"pullsecrets.yaml"},
+				Images:                []image.Image{},
+			},
+			previous: nil,
+			current:  generatedEntries{},
+			expected: kustomizetypes.Kustomization{
+				Resources:             []string{"secret.yaml"},
+				PatchesStrategicMerge: []kustomizetypes.PatchStrategicMerge{"pullsecrets.yaml"},
+				Images:                []image.Image{},
+			},
+			expectedReport: ReconcileReport{
+				PreservedResources: []string{"secret.yaml"},
+				PreservedPatches:   []string{"pullsecrets.yaml"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			existing := test.existing
+			report := reconcileKustomization(&existing, test.previous, test.current)
+			assert.Equal(t, test.expected, existing)
+			assert.Equal(t, test.expectedReport, *report)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package midstream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var unsafeTransformedFilename = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// Transformer mutates the rendered objects of an application. It receives every object
+// that is included in the base kustomization, one yaml document each, and returns the
+// objects to write instead. Objects can be changed, added or left out.
+type Transformer interface {
+	Transform(objects [][]byte) ([][]byte, error)
+}
+
+// ExecTransformer runs an executable with the objects as a multi document yaml stream on
+// stdin, and reads the transformed stream from stdout
+type ExecTransformer struct {
+	Command string
+	Args    []string
+}
+
+func (t ExecTransformer) Transform(objects [][]byte) ([][]byte, error) {
+	cmd := exec.Command(t.Command, t.Args...)
+	cmd.Env = os.Environ()
+
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd.Stdin = bytes.NewReader(bytes.Join(objects, []byte("\n---\n")))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "failed to run %s: %s", t.Command, strings.TrimSpace(stderr.String()))
+	}
+
+	// the output is decoded rather than split on separators, so that block scalars that
+	// contain "---" and documents that start with a separator are read correctly
+	transformed := [][]byte{}
+	decoder := yaml.NewDecoder(bytes.NewReader(stdout.Bytes()))
+	for {
+		doc := yaml.MapSlice{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode output of %s", t.Command)
+		}
+		if len(doc) == 0 {
+			continue
+		}
+
+		b, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal transformed object")
+		}
+		transformed = append(transformed, b)
+	}
+
+	return transformed, nil
+}
+
+type transformedObject struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+func (o transformedObject) id() string {
+	return fmt.Sprintf("%s/%s/%s/%s", o.APIVersion, o.Kind, o.Metadata.Namespace, o.Metadata.Name)
+}
+
+// transformedDoc is the position of an object in the files of the base
+type transformedDoc struct {
+	file int
+	doc  int
+}
+
+// TransformBase runs the transformers, in order, on the objects of the base before it's written.
+// Every object of a multi document file is transformed. Objects that were left out are removed
+// from their files, files without any objects left are removed from the base, and added objects
+// are written to the transformed directory of the base.
+func TransformBase(b *base.Base, transformers []Transformer, excludeKotsKinds bool) error {
+	if len(transformers) == 0 {
+		return nil
+	}
+
+	objects := [][]byte{}
+	docsByID := map[string]transformedDoc{}
+	fileDocs := map[int][][]byte{}
+	for i, file := range b.Files {
+		if !file.ShouldBeIncludedInBaseKustomization(excludeKotsKinds) {
+			continue
+		}
+
+		docs := bytes.Split(file.Content, []byte("\n---\n"))
+		hasObjects := false
+		for j, doc := range docs {
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			hasObjects = true
+
+			o := transformedObject{}
+			if err := yaml.Unmarshal(doc, &o); err != nil {
+				return errors.Wrapf(err, "failed to parse %s", file.Path)
+			}
+			docsByID[o.id()] = transformedDoc{file: i, doc: j}
+			objects = append(objects, doc)
+		}
+		if hasObjects {
+			fileDocs[i] = make([][]byte, len(docs))
+		}
+	}
+
+	for _, transformer := range transformers {
+		transformed, err := transformer.Transform(objects)
+		if err != nil {
+			return errors.Wrap(err, "failed to transform objects")
+		}
+		objects = transformed
+	}
+
+	transformedIDs := map[string]bool{}
+	usedPaths := map[string]bool{}
+	for _, file := range b.Files {
+		usedPaths[file.Path] = true
+	}
+
+	added := []base.BaseFile{}
+	for _, object := range objects {
+		o := transformedObject{}
+		if err := yaml.Unmarshal(object, &o); err != nil {
+			return errors.Wrap(err, "failed to parse transformed object")
+		}
+		if o.APIVersion == "" || o.Kind == "" || o.Metadata.Name == "" {
+			return errors.New("transformed objects must have an apiVersion, kind and metadata.name")
+		}
+		if transformedIDs[o.id()] {
+			return errors.Errorf("transformed objects include %s %s more than once", o.Kind, o.Metadata.Name)
+		}
+		transformedIDs[o.id()] = true
+
+		if d, ok := docsByID[o.id()]; ok {
+			fileDocs[d.file][d.doc] = object
+			continue
+		}
+
+		filePath := transformedFilePath(o, usedPaths)
+		usedPaths[filePath] = true
+		added = append(added, base.BaseFile{
+			Path:    filePath,
+			Content: object,
+		})
+	}
+
+	files := []base.BaseFile{}
+	for i, file := range b.Files {
+		docs, ok := fileDocs[i]
+		if !ok {
+			files = append(files, file)
+			continue
+		}
+
+		kept := [][]byte{}
+		for _, doc := range docs {
+			if doc != nil {
+				kept = append(kept, doc)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		file.Content = bytes.Join(kept, []byte("\n---\n"))
+		files = append(files, file)
+	}
+	b.Files = append(files, added...)
+
+	return nil
+}
+
+func transformedFilePath(o transformedObject, usedPaths map[string]bool) string {
+	name := strings.Trim(unsafeTransformedFilename.ReplaceAllString(strings.ToLower(fmt.Sprintf("%s-%s", o.Kind, o.Metadata.Name)), "-"), "-")
+
+	filePath := path.Join("transformed", name+".yaml")
+	for i := 2; usedPaths[filePath]; i++ {
+		filePath = path.Join("transformed", fmt.Sprintf("%s-%d.yaml", name, i))
+	}
+
+	return filePath
+}
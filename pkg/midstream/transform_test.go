@@ -0,0 +1,171 @@
+package midstream
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type funcTransformer func(objects [][]byte) ([][]byte, error)
+
+func (f funcTransformer) Transform(objects [][]byte) ([][]byte, error) {
+	return f(objects)
+}
+
+func Test_TransformBase(t *testing.T) {
+	b := &base.Base{
+		Files: []base.BaseFile{
+			{
+				Path:    "deployment-web.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"),
+			},
+			{
+				Path:    "service-web.yaml",
+				Content: []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"),
+			},
+			{
+				Path:    "notes.txt",
+				Content: []byte("not an object"),
+			},
+		},
+	}
+
+	transformers := []Transformer{
+		funcTransformer(func(objects [][]byte) ([][]byte, error) {
+			transformed := [][]byte{}
+			for _, object := range objects {
+				if bytes.Contains(object, []byte("kind: Service")) {
+					continue
+				}
+				transformed = append(transformed, append(object, []byte("  labels:\n    team: platform\n")...))
+			}
+			return transformed, nil
+		}),
+		funcTransformer(func(objects [][]byte) ([][]byte, error) {
+			return append(objects, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: Web Settings\n")), nil
+		}),
+	}
+
+	err := TransformBase(b, transformers, true)
+	require.NoError(t, err)
+
+	expected := []base.BaseFile{
+		{
+			Path:    "deployment-web.yaml",
+			Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  labels:\n    team: platform\n"),
+		},
+		{
+			Path:    "notes.txt",
+			Content: []byte("not an object"),
+		},
+		{
+			Path:    "transformed/configmap-web-settings.yaml",
+			Content: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: Web Settings\n"),
+		},
+	}
+	assert.Equal(t, expected, b.Files)
+}
+
+func Test_TransformBaseMultiDocumentFiles(t *testing.T) {
+	b := &base.Base{
+		Files: []base.BaseFile{
+			{
+				Path:    "web.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n"),
+			},
+			{
+				Path:    "secrets.yaml",
+				Content: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: b\n"),
+			},
+		},
+	}
+
+	// the service is changed, the configmap and all secrets are left out
+	transformer := funcTransformer(func(objects [][]byte) ([][]byte, error) {
+		transformed := [][]byte{}
+		for _, object := range objects {
+			if bytes.Contains(object, []byte("kind: ConfigMap")) || bytes.Contains(object, []byte("kind: Secret")) {
+				continue
+			}
+			if bytes.Contains(object, []byte("kind: Service")) {
+				object = []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: web\nspec:\n  type: NodePort")
+			}
+			transformed = append(transformed, object)
+		}
+		return transformed, nil
+	})
+
+	err := TransformBase(b, []Transformer{transformer}, true)
+	require.NoError(t, err)
+
+	expected := []base.BaseFile{
+		{
+			Path:    "web.yaml",
+			Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\nspec:\n  type: NodePort"),
+		},
+	}
+	assert.Equal(t, expected, b.Files)
+}
+
+func Test_TransformBaseDuplicateObjects(t *testing.T) {
+	b := &base.Base{
+		Files: []base.BaseFile{
+			{
+				Path:    "service-web.yaml",
+				Content: []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"),
+			},
+		},
+	}
+
+	duplicate := funcTransformer(func(objects [][]byte) ([][]byte, error) {
+		return append(objects, objects...), nil
+	})
+
+	err := TransformBase(b, []Transformer{duplicate}, true)
+	require.Error(t, err)
+}
+
+func Test_ExecTransformer(t *testing.T) {
+	transformer := ExecTransformer{
+		Command: "sh",
+		Args:    []string{"-c", "cat; printf -- '---\\napiVersion: v1\\nkind: ServiceAccount\\nmetadata:\\n  name: web\\n'"},
+	}
+
+	objects := [][]byte{
+		[]byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: web"),
+		[]byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"),
+	}
+
+	transformed, err := transformer.Transform(objects)
+	require.NoError(t, err)
+	require.Len(t, transformed, 3)
+	assert.Contains(t, string(transformed[0]), "kind: Service\n")
+	assert.Contains(t, string(transformed[1]), "kind: Deployment\n")
+	assert.Contains(t, string(transformed[2]), "kind: ServiceAccount\n")
+
+	// separators in block scalars are part of the value
+	blockScalar := ExecTransformer{
+		Command: "sh",
+		Args:    []string{"-c", "printf -- '--- # generated\\napiVersion: v1\\nkind: ConfigMap\\nmetadata:\\n  name: web\\ndata:\\n  notes: |\\n    intro\\n    ---\\n    outro\\n...\\n'"},
+	}
+	transformed, err = blockScalar.Transform(objects)
+	require.NoError(t, err)
+	require.Len(t, transformed, 1)
+	configMap := struct {
+		Data map[string]string `yaml:"data"`
+	}{}
+	require.NoError(t, yaml.Unmarshal(transformed[0], &configMap))
+	assert.Equal(t, "intro\n---\noutro\n", configMap.Data["notes"])
+
+	failing := ExecTransformer{
+		Command: "sh",
+		Args:    []string{"-c", "echo invalid object >&2; exit 1"},
+	}
+	_, err = failing.Transform(objects)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid object")
+}
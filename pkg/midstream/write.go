@@ -1,14 +1,14 @@
 package midstream
 
 import (
-	"io/ioutil"
+	"bytes"
 	"os"
-	"path"
 	"path/filepath"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/util"
 	yaml "gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
@@ -23,10 +23,12 @@ const (
 type WriteOptions struct {
 	MidstreamDir string
 	BaseDir      string
+	// FileModes are the modes that the midstream is written with, and whether files are written atomically
+	FileModes util.FileModes
 }
 
 func (m *Midstream) KustomizationFilename(options WriteOptions) string {
-	return path.Join(options.MidstreamDir, "kustomization.yaml")
+	return filepath.Join(options.MidstreamDir, "kustomization.yaml")
 }
 
 func (m *Midstream) WriteMidstream(options WriteOptions) error {
@@ -41,10 +43,12 @@ func (m *Midstream) WriteMidstream(options WriteOptions) error {
 		existingKustomization = k
 	}
 
-	if err := os.MkdirAll(options.MidstreamDir, 0744); err != nil {
+	if err := options.FileModes.MkdirAll(options.MidstreamDir); err != nil {
 		return errors.Wrap(err, "failed to mkdir")
 	}
 
+	m.inheritNames(existingKustomization)
+
 	secretFilename, err := m.writePullSecret(options)
 	if err != nil {
 		return errors.Wrap(err, "failed to write secret")
@@ -62,12 +66,37 @@ func (m *Midstream) WriteMidstream(options WriteOptions) error {
 		m.Kustomization.PatchesStrategicMerge = append(m.Kustomization.PatchesStrategicMerge, kustomizetypes.PatchStrategicMerge(patchFilename))
 	}
 
+	serviceEnvFilename, err := m.writeServiceEnvPatches(options)
+	if err != nil {
+		return errors.Wrap(err, "failed to write service env patches")
+	}
+	if serviceEnvFilename != "" {
+		m.Kustomization.PatchesStrategicMerge = append(m.Kustomization.PatchesStrategicMerge, kustomizetypes.PatchStrategicMerge(serviceEnvFilename))
+	}
+
+	generated := generatedEntriesFromKustomization(m.Kustomization)
+	if existingKustomization != nil {
+		previouslyGenerated, err := readGeneratedMarker(options.MidstreamDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to read previously generated entries")
+		}
+
+		m.ReconcileReport = reconcileKustomization(existingKustomization, previouslyGenerated, generated)
+		if err := removePrunedFiles(options.MidstreamDir, m.ReconcileReport); err != nil {
+			return errors.Wrap(err, "failed to remove pruned files")
+		}
+	}
+
 	m.mergeKustomization(existingKustomization)
 
 	if err := m.writeKustomization(options); err != nil {
 		return errors.Wrap(err, "failed to write kustomization")
 	}
 
+	if err := writeGeneratedMarker(options.MidstreamDir, generated, options.FileModes); err != nil {
+		return errors.Wrap(err, "failed to write generated entries")
+	}
+
 	return nil
 }
 
@@ -84,6 +113,14 @@ func (m *Midstream) mergeKustomization(existing *kustomizetypes.Kustomization) {
 
 	newResources := findNewStrings(m.Kustomization.Resources, existing.Resources)
 	m.Kustomization.Resources = append(existing.Resources, newResources...)
+
+	// the rest are never generated, they are kept from the existing kustomization without duplicates
+	m.Kustomization.PatchesJson6902 = findNewJson6902Patches(append(existing.PatchesJson6902, m.Kustomization.PatchesJson6902...), nil)
+	m.Kustomization.ConfigMapGenerator = findNewConfigMapGenerators(append(existing.ConfigMapGenerator, m.Kustomization.ConfigMapGenerator...), nil)
+	m.Kustomization.SecretGenerator = findNewSecretGenerators(append(existing.SecretGenerator, m.Kustomization.SecretGenerator...), nil)
+	m.Kustomization.Generators = findNewStrings(append(existing.Generators, m.Kustomization.Generators...), nil)
+	m.Kustomization.Configurations = findNewStrings(append(existing.Configurations, m.Kustomization.Configurations...), nil)
+	m.Kustomization.Vars = findNewVars(append(existing.Vars, m.Kustomization.Vars...), nil)
 }
 
 func (m *Midstream) writeKustomization(options WriteOptions) error {
@@ -94,11 +131,12 @@ func (m *Midstream) writeKustomization(options WriteOptions) error {
 
 	fileRenderPath := m.KustomizationFilename(options)
 
+	// kustomizations are the same on every platform, their paths are separated by slashes
 	m.Kustomization.Bases = []string{
-		relativeBaseDir,
+		filepath.ToSlash(relativeBaseDir),
 	}
 
-	if err := k8sutil.WriteKustomizationToFile(m.Kustomization, fileRenderPath); err != nil {
+	if err := k8sutil.WriteKustomizationToFileWithModes(m.Kustomization, fileRenderPath, options.FileModes); err != nil {
 		return errors.Wrap(err, "failed to write kustomization to file")
 	}
 
@@ -112,12 +150,23 @@ func (m *Midstream) writePullSecret(options WriteOptions) (string, error) {
 
 	absFilename := filepath.Join(options.MidstreamDir, secretFilename)
 
-	b, err := k8syaml.Marshal(m.PullSecret)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to marshal pull secret")
+	secrets := []*corev1.Secret{m.PullSecret}
+	for _, namespace := range m.PullSecretNamespaces {
+		secret := m.PullSecret.DeepCopy()
+		secret.Namespace = namespace
+		secrets = append(secrets, secret)
 	}
 
-	if err := ioutil.WriteFile(absFilename, b, 0644); err != nil {
+	multiDocs := [][]byte{}
+	for _, secret := range secrets {
+		b, err := k8syaml.Marshal(secret)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal pull secret")
+		}
+		multiDocs = append(multiDocs, b)
+	}
+
+	if err := options.FileModes.WriteFile(absFilename, bytes.Join(multiDocs, []byte("\n---\n"))); err != nil {
 		return "", errors.Wrap(err, "failed to write pull secret file")
 	}
 
@@ -125,52 +174,45 @@ func (m *Midstream) writePullSecret(options WriteOptions) (string, error) {
 }
 
 func (m *Midstream) writeObjectsWithPullSecret(options WriteOptions) (string, error) {
-	if len(m.DocForPatches) == 0 {
+	if len(m.DocForPatches) == 0 || m.PullSecret == nil {
 		return "", nil
 	}
 
 	filename := filepath.Join(options.MidstreamDir, patchesFilename)
 
-	f, err := os.Create(filename)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to create resources file")
-	}
-	defer f.Close()
-
+	var patches bytes.Buffer
+	numPatches := 0
 	for _, o := range m.DocForPatches {
 		withPullSecret := obejctWithPullSecret(o, m.PullSecret)
+		if withPullSecret == nil {
+			// images were found, but there is no pod spec to add the secret to
+			continue
+		}
 
 		b, err := yaml.Marshal(withPullSecret)
 		if err != nil {
 			return "", errors.Wrap(err, "failed to marshal object")
 		}
 
-		if _, err := f.Write([]byte("---\n")); err != nil {
-			return "", errors.Wrap(err, "failed to write object")
-		}
-		if _, err := f.Write(b); err != nil {
-			return "", errors.Wrap(err, "failed to write object")
+		patches.WriteString("---\n")
+		patches.Write(b)
+		numPatches++
+	}
+
+	if numPatches == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return "", errors.Wrap(err, "failed to remove empty patches file")
 		}
+		return "", nil
+	}
+
+	if err := options.FileModes.WriteFile(filename, patches.Bytes()); err != nil {
+		return "", errors.Wrap(err, "failed to write patches file")
 	}
 
 	return patchesFilename, nil
 }
 
-func obejctWithPullSecret(obj *k8sdoc.Doc, secret *corev1.Secret) *k8sdoc.Doc {
-	return &k8sdoc.Doc{
-		APIVersion: obj.APIVersion,
-		Kind:       obj.Kind,
-		Metadata: k8sdoc.Metadata{
-			Name: obj.Metadata.Name,
-		},
-		Spec: k8sdoc.Spec{
-			Template: k8sdoc.Template{
-				Spec: k8sdoc.PodSpec{
-					ImagePullSecrets: []k8sdoc.ImagePullSecret{
-						{"name": "kotsadm-replicated-registry"},
-					},
-				},
-			},
-		},
-	}
+func obejctWithPullSecret(obj *k8sdoc.Doc, secret *corev1.Secret) map[string]interface{} {
+	return obj.PullSecretPatch(secret.Name)
 }
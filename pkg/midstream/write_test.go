@@ -0,0 +1,124 @@
+package midstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/v3/pkg/gvk"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+func Test_mergeKustomization(t *testing.T) {
+	deploymentTarget := &kustomizetypes.PatchTarget{
+		Gvk:  gvk.Gvk{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Name: "web",
+	}
+	serviceTarget := &kustomizetypes.PatchTarget{
+		Gvk:  gvk.Gvk{Version: "v1", Kind: "Service"},
+		Name: "web",
+	}
+
+	tests := []struct {
+		name      string
+		generated kustomizetypes.Kustomization
+		existing  kustomizetypes.Kustomization
+		expected  kustomizetypes.Kustomization
+	}{
+		{
+			name: "keeps user defined json patches, generators, configurations and vars",
+			generated: kustomizetypes.Kustomization{
+				Resources:             []string{"secret.yaml"},
+				PatchesStrategicMerge: []kustomizetypes.PatchStrategicMerge{"pullsecrets.yaml"},
+			},
+			existing: kustomizetypes.Kustomization{
+				Resources:             []string{"secret.yaml"},
+				PatchesStrategicMerge: []kustomizetypes.PatchStrategicMerge{"pullsecrets.yaml"},
+				PatchesJson6902: []kustomizetypes.PatchJson6902{
+					{Target: deploymentTarget, Path: "replicas.yaml"},
+					{Target: serviceTarget, Path: "replicas.yaml"},
+					{Target: deploymentTarget, Path: "replicas.yaml"},
+				},
+				ConfigMapGenerator: []kustomizetypes.ConfigMapArgs{
+					{GeneratorArgs: kustomizetypes.GeneratorArgs{Name: "settings"}},
+					{GeneratorArgs: kustomizetypes.GeneratorArgs{Name: "settings", Namespace: "other"}},
+					{GeneratorArgs: kustomizetypes.GeneratorArgs{Name: "settings"}},
+				},
+				SecretGenerator: []kustomizetypes.SecretArgs{
+					{GeneratorArgs: kustomizetypes.GeneratorArgs{Name: "credentials"}, Type: "Opaque"},
+				},
+				Generators:     []string{"generator.yaml", "generator.yaml"},
+				Configurations: []string{"name-reference.yaml"},
+				Vars: []kustomizetypes.Var{
+					{Name: "WEB_SERVICE"},
+					{Name: "WEB_SERVICE"},
+				},
+			},
+			expected: kustomizetypes.Kustomization{
+				Resources:             []string{"secret.yaml"},
+				PatchesStrategicMerge: []kustomizetypes.PatchStrategicMerge{"pullsecrets.yaml"},
+				PatchesJson6902: []kustomizetypes.PatchJson6902{
+					{Target: deploymentTarget, Path: "replicas.yaml"},
+					{Target: serviceTarget, Path: "replicas.yaml"},
+				},
+				ConfigMapGenerator: []kustomizetypes.ConfigMapArgs{
+					{GeneratorArgs: kustomizetypes.GeneratorArgs{Name: "settings"}},
+					{GeneratorArgs: kustomizetypes.GeneratorArgs{Name: "settings", Namespace: "other"}},
+				},
+				SecretGenerator: []kustomizetypes.SecretArgs{
+					{GeneratorArgs: kustomizetypes.GeneratorArgs{Name: "credentials"}, Type: "Opaque"},
+				},
+				Generators:     []string{"generator.yaml"},
+				Configurations: []string{"name-reference.yaml"},
+				Vars: []kustomizetypes.Var{
+					{Name: "WEB_SERVICE"},
+				},
+			},
+		},
+		{
+			name: "nothing to keep",
+			generated: kustomizetypes.Kustomization{
+				Resources: []string{"secret.yaml"},
+			},
+			existing: kustomizetypes.Kustomization{},
+			expected: kustomizetypes.Kustomization{
+				Resources:          []string{"secret.yaml"},
+				PatchesJson6902:    []kustomizetypes.PatchJson6902{},
+				ConfigMapGenerator: []kustomizetypes.ConfigMapArgs{},
+				SecretGenerator:    []kustomizetypes.SecretArgs{},
+				Generators:         []string{},
+				Configurations:     []string{},
+				Vars:               []kustomizetypes.Var{},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := Midstream{Kustomization: &test.generated}
+			m.mergeKustomization(&test.existing)
+			assert.Equal(t, test.expected, *m.Kustomization)
+		})
+	}
+}
+
+func Test_WriteMidstreamBases(t *testing.T) {
+	appDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(appDir)
+
+	options := WriteOptions{
+		MidstreamDir: filepath.Join(appDir, "overlays", "midstream"),
+		BaseDir:      filepath.Join(appDir, "base"),
+	}
+	m := &Midstream{Kustomization: &kustomizetypes.Kustomization{}}
+	require.NoError(t, m.WriteMidstream(options))
+
+	kustomization, err := k8sutil.ReadKustomizationFromFile(m.KustomizationFilename(options))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"../../base"}, kustomization.Bases)
+}
@@ -0,0 +1,285 @@
+package preflight
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	analyzerunner "github.com/replicatedhq/troubleshoot/pkg/analyze"
+	troubleshootv1beta1 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta1"
+	troubleshootscheme "github.com/replicatedhq/troubleshoot/pkg/client/troubleshootclientset/scheme"
+	"github.com/replicatedhq/troubleshoot/pkg/collect"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func init() {
+	troubleshootscheme.AddToScheme(scheme.Scheme)
+}
+
+var yamlDocSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+const (
+	OutcomePass = "pass"
+	OutcomeWarn = "warn"
+	OutcomeFail = "fail"
+)
+
+type RunOptions struct {
+	Kubeconfig string
+}
+
+// Result is the outcome of one analyzer
+type Result struct {
+	Preflight string `json:"preflight"`
+	Title     string `json:"title"`
+	Outcome   string `json:"outcome"`
+	Message   string `json:"message"`
+	URI       string `json:"uri,omitempty"`
+}
+
+type Results struct {
+	Results []Result `json:"results"`
+	// Errors are the analyzers that could not be run, e.g. because the collector they need failed
+	Errors []string `json:"errors,omitempty"`
+}
+
+// IsFail is true when an analyzer failed or could not be run
+func (r *Results) IsFail() bool {
+	if len(r.Errors) > 0 {
+		return true
+	}
+	for _, result := range r.Results {
+		if result.Outcome == OutcomeFail {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Results) IsWarn() bool {
+	for _, result := range r.Results {
+		if result.Outcome == OutcomeWarn {
+			return true
+		}
+	}
+	return false
+}
+
+// FindPreflights returns the preflights of an app directory created by kots pull. The base has
+// the preflights after the templates have been rendered, the upstream is used when the base
+// doesn't have kots kinds.
+func FindPreflights(appDir string) ([]*troubleshootv1beta1.Preflight, error) {
+	for _, dir := range []string{"base", "upstream"} {
+		preflights, err := findPreflightsInDir(filepath.Join(appDir, dir))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find preflights in %s", dir)
+		}
+		if len(preflights) > 0 {
+			return preflights, nil
+		}
+	}
+	return []*troubleshootv1beta1.Preflight{}, nil
+}
+
+func findPreflightsInDir(dir string) ([]*troubleshootv1beta1.Preflight, error) {
+	preflights := []*troubleshootv1beta1.Preflight{}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return preflights, nil
+	}
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		for _, doc := range yamlDocSeparator.Split(string(content), -1) {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			obj, _, err := decode([]byte(doc), nil, nil)
+			if err != nil {
+				continue
+			}
+			if preflight, ok := obj.(*troubleshootv1beta1.Preflight); ok {
+				preflights = append(preflights, preflight)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return preflights, nil
+}
+
+// Run runs the collectors of the preflights against the cluster and analyzes the collected data.
+// Analyzers that can't be run are returned as errors in the results, so that all of the checks
+// are reported.
+func Run(preflights []*troubleshootv1beta1.Preflight, runOptions RunOptions) (*Results, error) {
+	clientConfig, err := clientcmd.BuildConfigFromFlags("", runOptions.Kubeconfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	results := Results{
+		Results: []Result{},
+	}
+	for _, preflight := range preflights {
+		collected, err := runCollectors(preflight, clientConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to run collectors of preflight %s", preflight.Name)
+		}
+
+		analyzed := analyze(preflight, collected)
+		results.Results = append(results.Results, analyzed.Results...)
+		results.Errors = append(results.Errors, analyzed.Errors...)
+	}
+
+	return &results, nil
+}
+
+func runCollectors(preflight *troubleshootv1beta1.Preflight, clientConfig *rest.Config) (map[string][]byte, error) {
+	collectors := []*troubleshootv1beta1.Collect{}
+	hasClusterInfo, hasClusterResources := false, false
+	for _, c := range preflight.Spec.Collectors {
+		hasClusterInfo = hasClusterInfo || c.ClusterInfo != nil
+		hasClusterResources = hasClusterResources || c.ClusterResources != nil
+		collectors = append(collectors, c)
+	}
+	// most analyzers use the cluster info and resources, they are always collected
+	if !hasClusterInfo {
+		collectors = append(collectors, &troubleshootv1beta1.Collect{ClusterInfo: &troubleshootv1beta1.ClusterInfo{}})
+	}
+	if !hasClusterResources {
+		collectors = append(collectors, &troubleshootv1beta1.Collect{ClusterResources: &troubleshootv1beta1.ClusterResources{}})
+	}
+
+	collected := map[string][]byte{}
+	for _, c := range collectors {
+		collector := collect.Collector{
+			Redact:       true,
+			Collect:      c,
+			ClientConfig: clientConfig,
+		}
+
+		output, err := collector.RunCollectorSync()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to run collector %s", collector.GetDisplayName())
+		}
+
+		files, err := parseCollectorOutput(output)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse output of collector %s", collector.GetDisplayName())
+		}
+		for name, contents := range files {
+			collected[name] = contents
+		}
+	}
+
+	return collected, nil
+}
+
+// parseCollectorOutput returns the files in the output of a collector. The output is a json object
+// of file names to base64 encoded contents, or to objects of more files in a directory.
+func parseCollectorOutput(output []byte) (map[string][]byte, error) {
+	input := map[string]interface{}{}
+	if err := json.Unmarshal(output, &input); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal output")
+	}
+
+	files := map[string][]byte{}
+	for name, contents := range input {
+		switch c := contents.(type) {
+		case string:
+			decoded, err := base64.StdEncoding.DecodeString(c)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode %s", name)
+			}
+			files[filepath.Clean(name)] = decoded
+		case map[string]interface{}:
+			for childName, childContents := range c {
+				s, _ := childContents.(string)
+				decoded, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to decode %s", filepath.Join(name, childName))
+				}
+				files[filepath.Join(name, childName)] = decoded
+			}
+		}
+	}
+
+	return files, nil
+}
+
+func analyze(preflight *troubleshootv1beta1.Preflight, collected map[string][]byte) Results {
+	getCollectedFileContents := func(name string) ([]byte, error) {
+		contents, ok := collected[name]
+		if !ok {
+			return nil, errors.Errorf("file %s was not collected", name)
+		}
+		return contents, nil
+	}
+	getChildCollectedFileContents := func(prefix string) (map[string][]byte, error) {
+		matching := map[string][]byte{}
+		for name, contents := range collected {
+			if strings.HasPrefix(name, prefix) {
+				matching[name] = contents
+			}
+		}
+		return matching, nil
+	}
+
+	results := Results{
+		Results: []Result{},
+	}
+	for _, analyzer := range preflight.Spec.Analyzers {
+		analyzeResult, err := analyzerunner.Analyze(analyzer, getCollectedFileContents, getChildCollectedFileContents)
+		if err != nil {
+			results.Errors = append(results.Errors, errors.Wrapf(err, "preflight %s", preflight.Name).Error())
+			continue
+		}
+
+		result := Result{
+			Preflight: preflight.Name,
+			Title:     analyzeResult.Title,
+			Message:   analyzeResult.Message,
+			URI:       analyzeResult.URI,
+		}
+		switch {
+		case analyzeResult.IsFail:
+			result.Outcome = OutcomeFail
+		case analyzeResult.IsWarn:
+			result.Outcome = OutcomeWarn
+		case analyzeResult.IsPass:
+			result.Outcome = OutcomePass
+		default:
+			// none of the outcomes matched
+			continue
+		}
+		results.Results = append(results.Results, result)
+	}
+
+	return results
+}
@@ -0,0 +1,158 @@
+package preflight
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPreflight = `apiVersion: troubleshoot.replicated.com/v1beta1
+kind: Preflight
+metadata:
+  name: my-app
+spec:
+  analyzers:
+  - clusterVersion:
+      outcomes:
+      - fail:
+          when: "< 1.13.0"
+          message: The application requires Kubernetes 1.13.0 or later
+          uri: https://kubernetes.io
+      - warn:
+          when: "< 1.15.0"
+          message: Kubernetes 1.15.0 or later is recommended
+      - pass:
+          message: Your cluster meets the recommended Kubernetes version
+  - storageClass:
+      checkName: Default storage class
+      storageClassName: default
+      outcomes:
+      - fail:
+          message: There is no default storage class
+      - pass:
+          message: There is a default storage class
+`
+
+func Test_FindPreflights(t *testing.T) {
+	req := require.New(t)
+
+	appDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(appDir)
+
+	req.NoError(os.MkdirAll(filepath.Join(appDir, "upstream"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "preflight.yaml"), []byte(testPreflight), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "deployment.yaml"), []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"), 0644))
+
+	preflights, err := FindPreflights(appDir)
+	req.NoError(err)
+	req.Len(preflights, 1)
+	assert.Equal(t, "my-app", preflights[0].Name)
+	assert.Len(t, preflights[0].Spec.Analyzers, 2)
+
+	// the rendered preflight in the base is used instead of the upstream
+	req.NoError(os.MkdirAll(filepath.Join(appDir, "base"), 0755))
+	rendered := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\n" + testPreflight
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "base", "preflight.yaml"), []byte(rendered), 0644))
+
+	preflights, err = FindPreflights(appDir)
+	req.NoError(err)
+	req.Len(preflights, 1)
+
+	empty, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(empty)
+
+	preflights, err = FindPreflights(empty)
+	req.NoError(err)
+	assert.Len(t, preflights, 0)
+}
+
+func Test_parseCollectorOutput(t *testing.T) {
+	output := []byte(`{"cluster-info/cluster_version.json":"eyJzdHJpbmciOiJ2MS4xNC4xIn0=","cluster-resources/pods":{"default.json":"W10="}}`)
+
+	files, err := parseCollectorOutput(output)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"cluster-info/cluster_version.json":   []byte(`{"string":"v1.14.1"}`),
+		"cluster-resources/pods/default.json": []byte(`[]`),
+	}, files)
+}
+
+func Test_analyze(t *testing.T) {
+	appDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(appDir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(appDir, "upstream"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(appDir, "upstream", "preflight.yaml"), []byte(testPreflight), 0644))
+	preflights, err := FindPreflights(appDir)
+	require.NoError(t, err)
+	require.Len(t, preflights, 1)
+
+	tests := []struct {
+		name      string
+		collected map[string][]byte
+		expected  Results
+		isFail    bool
+		isWarn    bool
+	}{
+		{
+			name: "warn",
+			collected: map[string][]byte{
+				"cluster-info/cluster_version.json": []byte(`{"string":"v1.14.1"}`),
+			},
+			expected: Results{
+				Results: []Result{
+					{
+						Preflight: "my-app",
+						Title:     "Required Kubernetes Version",
+						Outcome:   OutcomeWarn,
+						Message:   "Kubernetes 1.15.0 or later is recommended",
+					},
+				},
+				Errors: []string{"preflight my-app: file cluster-resources/storage-classes.json was not collected"},
+			},
+			isFail: true,
+			isWarn: true,
+		},
+		{
+			name: "fail",
+			collected: map[string][]byte{
+				"cluster-info/cluster_version.json":      []byte(`{"string":"v1.12.3"}`),
+				"cluster-resources/storage-classes.json": []byte(`[]`),
+			},
+			expected: Results{
+				Results: []Result{
+					{
+						Preflight: "my-app",
+						Title:     "Required Kubernetes Version",
+						Outcome:   OutcomeFail,
+						Message:   "The application requires Kubernetes 1.13.0 or later",
+						URI:       "https://kubernetes.io",
+					},
+					{
+						Preflight: "my-app",
+						Title:     "Default storage class",
+						Outcome:   OutcomeFail,
+						Message:   "There is no default storage class",
+					},
+				},
+			},
+			isFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results := analyze(preflights[0], test.collected)
+			assert.Equal(t, test.expected, results)
+			assert.Equal(t, test.isFail, results.IsFail())
+			assert.Equal(t, test.isWarn, results.IsWarn())
+		})
+	}
+}
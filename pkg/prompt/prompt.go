@@ -0,0 +1,112 @@
+package prompt
+
+import (
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrCanceled is returned when the user interrupts a prompt, e.g. with Ctrl+C
+	ErrCanceled = errors.New("canceled")
+	// ErrMissingInput is returned by prompters that can't ask for input that wasn't in the options
+	ErrMissingInput = errors.New("input is required")
+)
+
+// Input is a question that's answered with text
+type Input struct {
+	Label   string
+	Default string
+	// Mask hides the answer, e.g. for passwords
+	Mask bool
+	// Validate is called with each answer, the question is asked again when it returns an error
+	Validate func(string) error
+}
+
+// Prompter asks the user for the input that's missing from the options of kots. Programs that
+// embed kots can answer the questions themselves, or use NonInteractive to get ErrMissingInput.
+type Prompter interface {
+	// Input returns the answer to the question, which passed its validation
+	Input(input Input) (string, error)
+	// Select returns the item that was selected
+	Select(label string, items []string) (string, error)
+}
+
+// OrTerminal returns the prompter, or Terminal when it's nil, so that the zero value of the options
+// prompts like the kots cli does
+func OrTerminal(prompter Prompter) Prompter {
+	if prompter == nil {
+		return Terminal{}
+	}
+	return prompter
+}
+
+// Terminal prompts on the terminal that kots runs in. An interrupt returns ErrCanceled.
+type Terminal struct{}
+
+func (Terminal) Input(input Input) (string, error) {
+	templates := &promptui.PromptTemplates{
+		Prompt:  "{{ . | bold }} ",
+		Valid:   "{{ . | green }} ",
+		Invalid: "{{ . | red }} ",
+		Success: "{{ . | bold }} ",
+	}
+
+	prompt := promptui.Prompt{
+		Label:     input.Label,
+		Templates: templates,
+		Default:   input.Default,
+		Validate:  promptui.ValidateFunc(input.Validate),
+	}
+	if input.Mask {
+		prompt.Mask = rune('•')
+	}
+
+	for {
+		result, err := prompt.Run()
+		if err != nil {
+			if err == promptui.ErrInterrupt {
+				return "", ErrCanceled
+			}
+			continue
+		}
+
+		return result, nil
+	}
+}
+
+func (Terminal) Select(label string, items []string) (string, error) {
+	prompt := promptui.Select{
+		Label: label,
+		Items: items,
+	}
+
+	for {
+		_, result, err := prompt.Run()
+		if err != nil {
+			if err == promptui.ErrInterrupt {
+				return "", ErrCanceled
+			}
+			continue
+		}
+
+		return result, nil
+	}
+}
+
+// NonInteractive never prompts, every question returns ErrMissingInput with the label of the
+// question
+type NonInteractive struct{}
+
+func (NonInteractive) Input(input Input) (string, error) {
+	return "", missingInput(input.Label)
+}
+
+func (NonInteractive) Select(label string, items []string) (string, error) {
+	return "", missingInput(label)
+}
+
+func missingInput(label string) error {
+	return errors.Wrap(ErrMissingInput, strings.TrimSuffix(strings.TrimSpace(label), ":"))
+}
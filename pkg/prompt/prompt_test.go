@@ -0,0 +1,22 @@
+package prompt
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NonInteractive(t *testing.T) {
+	_, err := NonInteractive{}.Input(Input{Label: "Application name:"})
+	assert.Equal(t, ErrMissingInput, errors.Cause(err))
+	assert.EqualError(t, err, "Application name: input is required")
+
+	_, err = NonInteractive{}.Select("Web/UI Service Type:", []string{"ClusterIP", "NodePort"})
+	assert.Equal(t, ErrMissingInput, errors.Cause(err))
+}
+
+func Test_OrTerminal(t *testing.T) {
+	assert.Equal(t, Terminal{}, OrTerminal(nil))
+	assert.Equal(t, NonInteractive{}, OrTerminal(NonInteractive{}))
+}
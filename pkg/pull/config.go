@@ -0,0 +1,45 @@
+package pull
+
+import (
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// validateConfigValues checks the config values that were passed to a headless install against the
+// validation rules of the config items, which the admin console enforces on the config screen
+func validateConfigValues(u *upstream.Upstream, log *logger.Logger) error {
+	var configSpecData, configValuesData []byte
+	for _, file := range u.Files {
+		decode := scheme.Codecs.UniversalDeserializer().Decode
+		_, gvk, err := decode(file.Content, nil, nil)
+		if err != nil {
+			continue
+		}
+		if gvk.Group != "kots.io" || gvk.Version != "v1beta1" {
+			continue
+		}
+
+		if gvk.Kind == "Config" {
+			configSpecData = file.Content
+		} else if gvk.Kind == "ConfigValues" {
+			configValuesData = file.Content
+		}
+	}
+
+	if configSpecData == nil || configValuesData == nil {
+		return nil
+	}
+
+	itemErrors, err := config.ValidateConfig(log, string(configSpecData), string(configValuesData), config.TemplateConfigOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to validate config")
+	}
+	if len(itemErrors) > 0 {
+		return errors.Errorf("invalid config values:\n%s", config.ConfigItemErrorsToString(itemErrors))
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package pull
+
+import (
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	// ConfigValuesKeySecretName is the secret with the key that config values are encrypted with,
+	// in the namespace the app is pulled for. The key never leaves the cluster, so an app
+	// directory in a repo doesn't have what's needed to read the values.
+	ConfigValuesKeySecretName = "kots-config-values-key"
+
+	configValuesKeySecretKey = "encryptionKey"
+)
+
+// getConfigValuesCipher returns the cipher of the key in the cluster secret, the key is created
+// the first time
+func getConfigValuesCipher(namespace string) (*crypto.AESCipher, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	key, err := ensureConfigValuesKey(clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err := crypto.AESCipherFromString(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create cipher from secret %s", ConfigValuesKeySecretName)
+	}
+	return cipher, nil
+}
+
+func ensureConfigValuesKey(clientset kubernetes.Interface, namespace string) (string, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ConfigValuesKeySecretName, metav1.GetOptions{})
+	if err == nil {
+		key, ok := secret.Data[configValuesKeySecretKey]
+		if !ok {
+			return "", errors.Errorf("secret %s has no %s", ConfigValuesKeySecretName, configValuesKeySecretKey)
+		}
+		return string(key), nil
+	}
+	if !kuberneteserrors.IsNotFound(err) {
+		return "", errors.Wrap(err, "failed to get config values key secret")
+	}
+
+	cipher, err := crypto.NewAESCipher()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create new AES cipher")
+	}
+
+	secret = &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigValuesKeySecretName,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			configValuesKeySecretKey: []byte(cipher.ToString()),
+		},
+	}
+	if _, err := clientset.CoreV1().Secrets(namespace).Create(secret); err != nil {
+		return "", errors.Wrap(err, "failed to create config values key secret")
+	}
+
+	return cipher.ToString(), nil
+}
@@ -0,0 +1,29 @@
+package pull
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ensureConfigValuesKey(t *testing.T) {
+	req := require.New(t)
+	clientset := fake.NewSimpleClientset()
+
+	key, err := ensureConfigValuesKey(clientset, "my-app")
+	req.NoError(err)
+	_, err = crypto.AESCipherFromString(key)
+	req.NoError(err)
+
+	// the key in the secret is used after it's created
+	existing, err := ensureConfigValuesKey(clientset, "my-app")
+	req.NoError(err)
+	assert.Equal(t, key, existing)
+
+	other, err := ensureConfigValuesKey(clientset, "other")
+	req.NoError(err)
+	assert.NotEqual(t, key, other)
+}
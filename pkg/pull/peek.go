@@ -2,6 +2,7 @@ package pull
 
 import (
 	"github.com/pkg/errors"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/upstream"
 )
@@ -34,11 +35,11 @@ func GetUpdates(upstreamURI string, getUpdatesOptions GetUpdatesOptions) ([]upst
 	if getUpdatesOptions.LicenseFile != "" {
 		license, err := parseLicenseFromFile(getUpdatesOptions.LicenseFile)
 		if err != nil {
-			if errors.Cause(err) == ErrSignatureInvalid {
-				return nil, ErrSignatureInvalid
+			if errors.Cause(err) == kotslicense.ErrSignatureInvalid {
+				return nil, kotslicense.ErrSignatureInvalid
 			}
-			if errors.Cause(err) == ErrSignatureMissing {
-				return nil, ErrSignatureMissing
+			if errors.Cause(err) == kotslicense.ErrSignatureMissing {
+				return nil, kotslicense.ErrSignatureMissing
 			}
 			return nil, errors.Wrap(err, "failed to parse license from file")
 		}
@@ -1,6 +1,7 @@
 package pull
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
 	"net/url"
@@ -9,14 +10,22 @@ import (
 
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/application"
 	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/crypto"
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/downstream"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/midstream"
+	"github.com/replicatedhq/kots/pkg/prompt"
+	"github.com/replicatedhq/kots/pkg/rendermanifest"
 	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/util"
+	"github.com/replicatedhq/kots/pkg/version"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/kustomize/v3/pkg/image"
 )
@@ -34,13 +43,62 @@ type PullOptions struct {
 	UpdateCursor        string
 	ExcludeKotsKinds    bool
 	ExcludeAdminConsole bool
+	// SplitMultiDocYAML writes each object of a multi document upstream file to its own base file
+	SplitMultiDocYAML   bool
 	SharedPassword      string
 	CreateAppDir        bool
 	Silent              bool
 	RewriteImages       bool
 	RewriteImageOptions RewriteImageOptions
 	HelmOptions         []string
+	// HelmValuesFiles are the paths of values files of a helm chart upstream, merged in order
+	// before HelmOptions. The merged values are kept in the upstream, and the chart is rendered
+	// with them again when neither are set.
+	HelmValuesFiles []string
+	// HelmRenderer, Helm, KubeVersion and HelmAPIVersions are how helm charts are rendered, see
+	// base.RenderOptions. The renderer is kept in the render manifest.
+	HelmRenderer         string
+	Helm                 string
+	KubeVersion          string
+	HelmAPIVersions      []string
+	ImageLocations       []k8sdoc.ImageLocation
+	AdditionalNamespaces []string
+	SupportArchive       string
+	TemplateEnvPrefixes  []string
+	// DuplicateResources is how objects that are in more than one chart or file are resolved, see base.RenderOptions
+	DuplicateResources string
+	// ValidateBase checks the rendered base before it's written, see base.Validate. Problems that
+	// a cluster would accept, like unknown fields, are only logged.
+	ValidateBase        bool
+	ValidationDiscovery discovery.DiscoveryInterface
 	ReportWriter        io.Writer
+
+	// Transformers are run on the rendered objects before the base is written
+	Transformers []midstream.Transformer
+
+	// NamePrefix and NameSuffix are added to the names of all objects so that the app can be
+	// installed more than once in a cluster, see midstream.ServiceEnvAnnotation
+	NamePrefix string
+	NameSuffix string
+	// InstanceName scopes the names of the objects that kots creates for the app, e.g. the pull secret,
+	// so that more than one app can be installed in a namespace. It's usually the app slug, and it's
+	// kept in the render manifest.
+	InstanceName string
+
+	// FileModes are the modes that the base and midstream are written with, and whether their files
+	// are written atomically
+	FileModes util.FileModes
+
+	// ProgressReporter is optional, it's sent the progress of image copies
+	ProgressReporter logger.ProgressReporter
+
+	// EncryptConfigValues encrypts the config values in the upstream with the key in the
+	// ConfigValuesKeySecretName secret of Namespace, which is created if it doesn't exist
+	EncryptConfigValues bool
+
+	// Prompter asks for the shared password of the admin console when it's included and the
+	// password isn't set, on the terminal when it's nil
+	Prompter prompt.Prompter
 }
 
 type RewriteImageOptions struct {
@@ -49,6 +107,9 @@ type RewriteImageOptions struct {
 	Namespace  string
 	Username   string
 	Password   string
+	OCIOnly    bool
+	// Platforms of multi-architecture images to copy (e.g. linux/arm64), or all of them when empty
+	Platforms []string
 }
 
 // PullApplicationMetadata will return the application metadata yaml, if one is
@@ -92,6 +153,13 @@ func CanPullUpstream(upstreamURI string, pullOptions PullOptions) (bool, error)
 // Pull will download the application specified in upstreamURI using the options
 // specified in pullOptions. It returns the directory that the app was pulled to
 func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
+	return PullWithContext(context.Background(), upstreamURI, pullOptions)
+}
+
+// PullWithContext is Pull, stopping when ctx is canceled or its deadline passes. The download of
+// the upstream is canceled with ctx, which is also checked between the steps of the pull. An app
+// directory that was partly written is left as it is.
+func PullWithContext(ctx context.Context, upstreamURI string, pullOptions PullOptions) (string, error) {
 	log := logger.NewLogger()
 
 	if pullOptions.Silent {
@@ -100,69 +168,37 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 
 	log.Initialize()
 
+	if err := midstream.ValidateNames(pullOptions.NamePrefix, pullOptions.NameSuffix); err != nil {
+		return "", err
+	}
+	if err := util.ValidateInstanceName(pullOptions.InstanceName); err != nil {
+		return "", err
+	}
+
 	uri, err := url.ParseRequestURI(upstreamURI)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to parse uri")
 	}
 
-	fetchOptions := upstream.FetchOptions{}
-	fetchOptions.HelmRepoURI = pullOptions.HelmRepoURI
-	fetchOptions.RootDir = pullOptions.RootDir
-	fetchOptions.UseAppDir = pullOptions.CreateAppDir
-	fetchOptions.LocalPath = pullOptions.LocalPath
-	fetchOptions.CurrentCursor = pullOptions.UpdateCursor
-
-	if pullOptions.LicenseFile != "" {
-		license, err := parseLicenseFromFile(pullOptions.LicenseFile)
-		if err != nil {
-			if errors.Cause(err) == ErrSignatureInvalid {
-				return "", ErrSignatureInvalid
-			}
-			if errors.Cause(err) == ErrSignatureMissing {
-				return "", ErrSignatureMissing
-			}
-			return "", errors.Wrap(err, "failed to parse license from file")
-		}
-
-		fetchOptions.License = license
-	}
-	if pullOptions.ConfigFile != "" {
-		config, err := parseConfigValuesFromFile(pullOptions.ConfigFile)
-		if err != nil {
-			return "", errors.Wrap(err, "failed to parse license from file")
-		}
-		fetchOptions.ConfigValues = config
-	}
-	if pullOptions.InstallationFile != "" {
-		installation, err := parseInstallationFromFile(pullOptions.InstallationFile)
-		if err != nil {
-			return "", errors.Wrap(err, "failed to parse installation from file")
-		}
-		if installation != nil {
-			fetchOptions.EncryptionKey = installation.Spec.EncryptionKey
-		}
-	}
-
-	if pullOptions.AirgapRoot != "" {
-		airgap, err := findAirgapMetaInDir(pullOptions.AirgapRoot)
-		if err != nil {
-			return "", errors.Wrap(err, "failed to parse license from file")
-		}
-
-		if err := publicKeysMatch(fetchOptions.License, airgap); err != nil {
-			return "", errors.Wrap(err, "failed to validate app key")
-		}
-
-		fetchOptions.Airgap = airgap
+	fetchOptions, err := fetchOptionsFromPullOptions(pullOptions)
+	if err != nil {
+		return "", err
 	}
 
 	log.ActionWithSpinner("Pulling upstream")
-	u, err := upstream.FetchUpstream(upstreamURI, &fetchOptions)
+	u, err := upstream.FetchUpstreamWithContext(ctx, upstreamURI, fetchOptions)
 	if err != nil {
 		log.FinishSpinnerWithError()
 		return "", errors.Wrap(err, "failed to fetch upstream")
 	}
 
+	if pullOptions.ConfigFile != "" {
+		if err := validateConfigValues(u, log); err != nil {
+			log.FinishSpinnerWithError()
+			return "", err
+		}
+	}
+
 	includeAdminConsole := uri.Scheme == "replicated" && !pullOptions.ExcludeAdminConsole
 
 	writeUpstreamOptions := upstream.WriteOptions{
@@ -170,13 +206,30 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		CreateAppDir:        pullOptions.CreateAppDir,
 		IncludeAdminConsole: includeAdminConsole,
 		SharedPassword:      pullOptions.SharedPassword,
+		ConfigValuesCipher:  fetchOptions.ConfigValuesCipher,
+		Prompter:            pullOptions.Prompter,
+	}
+
+	helmValues, err := helmValuesFromOptions(pullOptions)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return "", err
+	}
+	if err := u.SetHelmValues(helmValues, u.GetUpstreamDir(writeUpstreamOptions)); err != nil {
+		log.FinishSpinnerWithError()
+		return "", errors.Wrap(err, "failed to set helm values")
 	}
+
 	if err := u.WriteUpstream(writeUpstreamOptions); err != nil {
 		log.FinishSpinnerWithError()
 		return "", errors.Wrap(err, "failed to write upstream")
 	}
 	log.FinishSpinner()
 
+	if err := checkCanceled(ctx); err != nil {
+		return "", err
+	}
+
 	replicatedRegistryInfo := registry.ProxyEndpointFromLicense(fetchOptions.License)
 
 	var pullSecret *corev1.Secret
@@ -187,9 +240,11 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		// Rewrite all images
 		if pullOptions.RewriteImageOptions.ImageFiles == "" {
 			writeUpstreamImageOptions := upstream.WriteUpstreamImageOptions{
-				RootDir:      pullOptions.RootDir,
-				CreateAppDir: pullOptions.CreateAppDir,
-				Log:          log,
+				RootDir:          pullOptions.RootDir,
+				CreateAppDir:     pullOptions.CreateAppDir,
+				ImageLocations:   pullOptions.ImageLocations,
+				Log:              log,
+				ProgressReporter: pullOptions.ProgressReporter,
 				SourceRegistry: registry.RegistryOptions{
 					Endpoint:      replicatedRegistryInfo.Registry,
 					ProxyEndpoint: replicatedRegistryInfo.Proxy,
@@ -207,9 +262,12 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 					Namespace: pullOptions.RewriteImageOptions.Namespace,
 					Username:  pullOptions.RewriteImageOptions.Username,
 					Password:  pullOptions.RewriteImageOptions.Password,
+					OCIOnly:   pullOptions.RewriteImageOptions.OCIOnly,
 				}
 			}
 
+			writeUpstreamImageOptions.Platforms = pullOptions.RewriteImageOptions.Platforms
+
 			newImages, err := u.CopyUpstreamImages(writeUpstreamImageOptions)
 			if err != nil {
 				return "", errors.Wrap(err, "failed to write upstream images")
@@ -229,12 +287,14 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 					Endpoint:      replicatedRegistryInfo.Registry,
 					ProxyEndpoint: replicatedRegistryInfo.Proxy,
 				},
-				ReportWriter: pullOptions.ReportWriter,
+				ReportWriter:     pullOptions.ReportWriter,
+				ProgressReporter: pullOptions.ProgressReporter,
 				DestinationRegistry: registry.RegistryOptions{
 					Endpoint:  pullOptions.RewriteImageOptions.Host,
 					Namespace: pullOptions.RewriteImageOptions.Namespace,
 					Username:  pullOptions.RewriteImageOptions.Username,
 					Password:  pullOptions.RewriteImageOptions.Password,
+					OCIOnly:   pullOptions.RewriteImageOptions.OCIOnly,
 				},
 			}
 			if fetchOptions.License != nil {
@@ -252,9 +312,10 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 			}
 
 			findObjectsOptions := upstream.FindObjectsWithImagesOptions{
-				RootDir:      pullOptions.RootDir,
-				CreateAppDir: pullOptions.CreateAppDir,
-				Log:          log,
+				RootDir:        pullOptions.RootDir,
+				CreateAppDir:   pullOptions.CreateAppDir,
+				ImageLocations: pullOptions.ImageLocations,
+				Log:            log,
 			}
 			affectedObjects, err := u.FindObjectsWithImages(findObjectsOptions)
 			if err != nil {
@@ -270,10 +331,20 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 				}
 			}
 
+			// cloud registries are logged in to with a token, instead of writing the cloud credentials to the pull secret
+			login, err := registry.PullSecretLogin(registry.RegistryOptions{
+				Endpoint: pullOptions.RewriteImageOptions.Host,
+				Username: registryUser,
+				Password: registryPass,
+			})
+			if err != nil {
+				return "", errors.Wrap(err, "failed to get registry login for pull secret")
+			}
+
 			pullSecret, err = registry.PullSecretForRegistries(
 				[]string{pullOptions.RewriteImageOptions.Host},
-				registryUser,
-				registryPass,
+				login.Username,
+				login.Password,
 				pullOptions.Namespace,
 			)
 			if err != nil {
@@ -296,7 +367,8 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 				Endpoint:      replicatedRegistryInfo.Registry,
 				ProxyEndpoint: replicatedRegistryInfo.Proxy,
 			},
-			Log: log,
+			ImageLocations: pullOptions.ImageLocations,
+			Log:            log,
 		}
 		rewrittenImages, affectedObjects, err := u.FindPrivateImages(findPrivateImagesOptions)
 		if err != nil {
@@ -320,43 +392,94 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		objects = affectedObjects
 	}
 
+	if err := checkCanceled(ctx); err != nil {
+		return "", err
+	}
+
 	renderOptions := base.RenderOptions{
-		SplitMultiDocYAML: true,
-		Namespace:         pullOptions.Namespace,
-		HelmOptions:       pullOptions.HelmOptions,
-		Log:               log,
+		SplitMultiDocYAML:  pullOptions.SplitMultiDocYAML,
+		EnvPrefixes:        pullOptions.TemplateEnvPrefixes,
+		Namespace:          pullOptions.Namespace,
+		HelmRenderer:       pullOptions.HelmRenderer,
+		Helm:               pullOptions.Helm,
+		KubeVersion:        pullOptions.KubeVersion,
+		HelmAPIVersions:    pullOptions.HelmAPIVersions,
+		DuplicateResources: pullOptions.DuplicateResources,
+		Log:                log,
 	}
 	log.ActionWithSpinner("Creating base")
 	b, err := base.RenderUpstream(u, &renderOptions)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to render upstream")
 	}
+	app := b.GetApplication()
+	if app != nil {
+		if err := application.CheckMinKotsVersion(app, version.Version()); err != nil {
+			log.FinishSpinnerWithError()
+			return "", err
+		}
+	}
 	log.FinishSpinner()
 
+	if app != nil && application.IsBehindTargetKotsVersion(app, version.Version()) {
+		log.Info("This release targets kots %s, this is kots %s", app.Spec.TargetKotsVersion, version.Version())
+	}
+
+	if err := midstream.TransformBase(b, pullOptions.Transformers, pullOptions.ExcludeKotsKinds); err != nil {
+		return "", errors.Wrap(err, "failed to transform base")
+	}
+
+	if pullOptions.ValidateBase {
+		validateOptions := base.ValidateOptions{
+			ExcludeKotsKinds: pullOptions.ExcludeKotsKinds,
+			Discovery:        pullOptions.ValidationDiscovery,
+		}
+		validationErrors, err := b.Validate(validateOptions)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to validate base")
+		}
+		for _, warning := range validationErrors.Warnings() {
+			log.Info("%s", warning.Error())
+		}
+		if errs := validationErrors.Errors(); len(errs) > 0 {
+			return "", errs
+		}
+	}
+
 	writeBaseOptions := base.WriteOptions{
 		BaseDir:          u.GetBaseDir(writeUpstreamOptions),
 		Overwrite:        true,
 		ExcludeKotsKinds: pullOptions.ExcludeKotsKinds,
+		FileModes:        pullOptions.FileModes,
 	}
 	if err := b.WriteBase(writeBaseOptions); err != nil {
 		return "", errors.Wrap(err, "failed to write base")
 	}
 
+	if err := checkCanceled(ctx); err != nil {
+		return "", err
+	}
+
 	log.ActionWithSpinner("Creating midstream")
 
-	m, err := midstream.CreateMidstream(b, images, objects, pullSecret)
+	m, err := midstream.CreateMidstream(b, images, objects, pullSecret, pullOptions.AdditionalNamespaces)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to create midstream")
 	}
+	m.Kustomization.NamePrefix = pullOptions.NamePrefix
+	m.Kustomization.NameSuffix = pullOptions.NameSuffix
+	m.SetInstanceName(pullOptions.InstanceName)
 	log.FinishSpinner()
 
 	writeMidstreamOptions := midstream.WriteOptions{
 		MidstreamDir: filepath.Join(b.GetOverlaysDir(writeBaseOptions), "midstream"),
 		BaseDir:      u.GetBaseDir(writeUpstreamOptions),
+		FileModes:    pullOptions.FileModes,
 	}
 	if err := m.WriteMidstream(writeMidstreamOptions); err != nil {
 		return "", errors.Wrap(err, "failed to write midstream")
 	}
+	m.ReconcileReport.Log(log)
 
 	for _, downstreamName := range pullOptions.Downstreams {
 		log.ActionWithSpinner("Creating downstream %q", downstreamName)
@@ -366,7 +489,7 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		}
 
 		writeDownstreamOptions := downstream.WriteOptions{
-			DownstreamDir: filepath.Join(b.GetOverlaysDir(writeBaseOptions), "downstreams", downstreamName),
+			DownstreamDir: downstream.Dir(b.GetOverlaysDir(writeBaseOptions), downstreamName),
 			MidstreamDir:  writeMidstreamOptions.MidstreamDir,
 		}
 
@@ -377,12 +500,40 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		log.FinishSpinner()
 	}
 
+	if pullOptions.SupportArchive != "" {
+		licenseID := ""
+		if fetchOptions.License != nil {
+			licenseID = fetchOptions.License.Spec.LicenseID
+		}
+
+		log.ActionWithSpinner("Creating support archive")
+		if err := writeSupportArchive(pullOptions, u, renderOptions, licenseID, log); err != nil {
+			log.FinishSpinnerWithError()
+			return "", errors.Wrap(err, "failed to write support archive")
+		}
+		log.FinishSpinner()
+	}
+
 	if includeAdminConsole {
 		if err := writeArchiveAsConfigMap(pullOptions, u, u.GetBaseDir(writeUpstreamOptions)); err != nil {
 			return "", errors.Wrap(err, "failed to write archive as config map")
 		}
 	}
 
+	appDir := filepath.Dir(u.GetBaseDir(writeUpstreamOptions))
+	renderManifest, err := rendermanifest.Create(appDir, u)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create render manifest")
+	}
+	if u.Type == "helm" {
+		renderManifest.HelmRenderer = pullOptions.HelmRenderer
+	}
+	renderManifest.InstanceName = pullOptions.InstanceName
+	renderManifest.SplitMultiDocYAML = pullOptions.SplitMultiDocYAML
+	if err := renderManifest.Write(appDir); err != nil {
+		return "", errors.Wrap(err, "failed to write render manifest")
+	}
+
 	return filepath.Join(pullOptions.RootDir, u.Name), nil
 }
 
@@ -392,18 +543,12 @@ func parseLicenseFromFile(filename string) (*kotsv1beta1.License, error) {
 		return nil, errors.Wrap(err, "failed to read license file")
 	}
 
-	decode := scheme.Codecs.UniversalDeserializer().Decode
-	decoded, gvk, err := decode(contents, nil, nil)
+	license, err := kotslicense.ParseLicense(contents)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to decode license file")
 	}
 
-	if gvk.Group != "kots.io" || gvk.Version != "v1beta1" || gvk.Kind != "License" {
-		return nil, errors.New("not an application license")
-	}
-
-	license := decoded.(*kotsv1beta1.License)
-	verifiedLicense, err := VerifySignature(license)
+	verifiedLicense, err := kotslicense.VerifySignature(license)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to verify signature")
 	}
@@ -411,7 +556,7 @@ func parseLicenseFromFile(filename string) (*kotsv1beta1.License, error) {
 	return verifiedLicense, nil
 }
 
-func parseConfigValuesFromFile(filename string) (*kotsv1beta1.ConfigValues, error) {
+func parseConfigValuesFromFile(filename string, cipher *crypto.AESCipher) (*kotsv1beta1.ConfigValues, error) {
 	contents, err := ioutil.ReadFile(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -420,6 +565,11 @@ func parseConfigValuesFromFile(filename string) (*kotsv1beta1.ConfigValues, erro
 		return nil, errors.Wrap(err, "failed to read config values file")
 	}
 
+	contents, err = upstream.DecryptConfigValues(contents, cipher)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt config values file")
+	}
+
 	decode := scheme.Codecs.UniversalDeserializer().Decode
 	decoded, gvk, err := decode(contents, nil, nil)
 	if err != nil {
@@ -515,14 +665,102 @@ func publicKeysMatch(license *kotsv1beta1.License, airgap *kotsv1beta1.Airgap) e
 		return nil
 	}
 
-	publicKey, err := GetAppPublicKey(license)
+	publicKey, err := kotslicense.GetAppPublicKey(license)
 	if err != nil {
 		return errors.Wrap(err, "failed to get public key from license")
 	}
 
-	if err := verify([]byte(license.Spec.AppSlug), []byte(airgap.Spec.Signature), publicKey); err != nil {
+	if err := kotslicense.Verify([]byte(license.Spec.AppSlug), []byte(airgap.Spec.Signature), publicKey); err != nil {
 		return errors.Wrap(err, "failed to verify bundle signature")
 	}
 
 	return nil
 }
+
+// helmValuesFromOptions reads the helm values files of the pull options
+func helmValuesFromOptions(pullOptions PullOptions) (upstream.HelmValues, error) {
+	helmValues := upstream.HelmValues{
+		Set: pullOptions.HelmOptions,
+	}
+	for _, filename := range pullOptions.HelmValuesFiles {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return upstream.HelmValues{}, errors.Wrapf(err, "failed to read helm values file %s", filename)
+		}
+		helmValues.Files = append(helmValues.Files, content)
+	}
+	return helmValues, nil
+}
+
+// fetchOptionsFromPullOptions reads the license, config values, installation and airgap bundle
+// that the upstream is fetched with
+func fetchOptionsFromPullOptions(pullOptions PullOptions) (*upstream.FetchOptions, error) {
+	fetchOptions := &upstream.FetchOptions{}
+	fetchOptions.HelmRepoURI = pullOptions.HelmRepoURI
+	fetchOptions.RootDir = pullOptions.RootDir
+	fetchOptions.UseAppDir = pullOptions.CreateAppDir
+	fetchOptions.LocalPath = pullOptions.LocalPath
+	fetchOptions.CurrentCursor = pullOptions.UpdateCursor
+
+	if pullOptions.EncryptConfigValues {
+		cipher, err := getConfigValuesCipher(pullOptions.Namespace)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get config values key")
+		}
+		fetchOptions.ConfigValuesCipher = cipher
+	}
+
+	if pullOptions.LicenseFile != "" {
+		license, err := parseLicenseFromFile(pullOptions.LicenseFile)
+		if err != nil {
+			if errors.Cause(err) == kotslicense.ErrSignatureInvalid {
+				return nil, kotslicense.ErrSignatureInvalid
+			}
+			if errors.Cause(err) == kotslicense.ErrSignatureMissing {
+				return nil, kotslicense.ErrSignatureMissing
+			}
+			return nil, errors.Wrap(err, "failed to parse license from file")
+		}
+
+		fetchOptions.License = license
+	}
+	if pullOptions.ConfigFile != "" {
+		config, err := parseConfigValuesFromFile(pullOptions.ConfigFile, fetchOptions.ConfigValuesCipher)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse license from file")
+		}
+		fetchOptions.ConfigValues = config
+	}
+	if pullOptions.InstallationFile != "" {
+		installation, err := parseInstallationFromFile(pullOptions.InstallationFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse installation from file")
+		}
+		if installation != nil {
+			fetchOptions.EncryptionKey = installation.Spec.EncryptionKey
+		}
+	}
+
+	if pullOptions.AirgapRoot != "" {
+		airgap, err := findAirgapMetaInDir(pullOptions.AirgapRoot)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse license from file")
+		}
+
+		if err := publicKeysMatch(fetchOptions.License, airgap); err != nil {
+			return nil, errors.Wrap(err, "failed to validate app key")
+		}
+
+		fetchOptions.Airgap = airgap
+	}
+
+	return fetchOptions, nil
+}
+
+// checkCanceled returns an error when the pull was canceled or its deadline passed
+func checkCanceled(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "pull stopped")
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package pull
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/midstream"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"gopkg.in/yaml.v2"
+)
+
+// supportArchiveExcludedDirs are never included in a support archive. userdata holds the
+// license, installation and config values, admin-console holds the admin console credentials.
+var supportArchiveExcludedDirs = []string{
+	path.Join("upstream", "userdata"),
+	path.Join("upstream", "admin-console"),
+	path.Join("base", "admin-console"),
+}
+
+type supportArchiveFile struct {
+	Path    string
+	Content []byte
+}
+
+// writeSupportArchive writes a tar.gz of the rendered application that can be shared with the
+// vendor. The base is rendered again in a temp dir with sensitive config values redacted, so that
+// the base in the app dir keeps the real values. The customer's license id and the data of all
+// secrets are also removed.
+func writeSupportArchive(pullOptions PullOptions, u *upstream.Upstream, renderOptions base.RenderOptions, licenseID string, log *logger.Logger) error {
+	appDir := filepath.Join(pullOptions.RootDir, u.Name)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tempDir)
+
+	renderOptions.RedactSensitiveConfig = true
+	b, err := base.RenderUpstream(u, &renderOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to render redacted upstream")
+	}
+	if err := midstream.TransformBase(b, pullOptions.Transformers, pullOptions.ExcludeKotsKinds); err != nil {
+		return errors.Wrap(err, "failed to transform redacted base")
+	}
+	writeBaseOptions := base.WriteOptions{
+		BaseDir:          filepath.Join(tempDir, "base"),
+		ExcludeKotsKinds: pullOptions.ExcludeKotsKinds,
+	}
+	if err := b.WriteBase(writeBaseOptions); err != nil {
+		return errors.Wrap(err, "failed to write redacted base")
+	}
+
+	// the base is read from the redacted render, everything else from the app dir
+	dirs := map[string]string{
+		"upstream": appDir,
+		"base":     tempDir,
+		"overlays": appDir,
+	}
+
+	files := []supportArchiveFile{}
+	for _, dir := range []string{"upstream", "base", "overlays"} {
+		rootDir := dirs[dir]
+		err := filepath.Walk(filepath.Join(rootDir, dir), func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(rootDir, filePath)
+			if err != nil {
+				return errors.Wrap(err, "failed to get relative path")
+			}
+			relPath = filepath.ToSlash(relPath)
+
+			if info.IsDir() {
+				if isExcludedFromSupportArchive(relPath) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			content, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s", relPath)
+			}
+
+			files = append(files, supportArchiveFile{
+				Path:    relPath,
+				Content: anonymizeContent(content, licenseID),
+			})
+			return nil
+		})
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return errors.Wrapf(err, "failed to read %s", dir)
+		}
+	}
+
+	configValues, err := base.RedactedConfigValues(u, log)
+	if err != nil {
+		return errors.Wrap(err, "failed to redact config values")
+	}
+	if configValues != nil {
+		files = append(files, supportArchiveFile{
+			Path:    path.Join("upstream", "userdata", "config.yaml"),
+			Content: configValues,
+		})
+	}
+
+	archive, err := createSupportArchive(u.Name, files)
+	if err != nil {
+		return errors.Wrap(err, "failed to create archive")
+	}
+
+	if err := ioutil.WriteFile(pullOptions.SupportArchive, archive, 0644); err != nil {
+		return errors.Wrap(err, "failed to write archive")
+	}
+
+	return nil
+}
+
+func isExcludedFromSupportArchive(relPath string) bool {
+	for _, excluded := range supportArchiveExcludedDirs {
+		if relPath == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// anonymizeContent removes the license id and replaces the data of any secrets in the content
+func anonymizeContent(content []byte, licenseID string) []byte {
+	if licenseID != "" {
+		content = bytes.Replace(content, []byte(licenseID), []byte(base.RedactedValue), -1)
+	}
+
+	docs := bytes.Split(content, []byte("\n---\n"))
+	for i, doc := range docs {
+		docs[i] = redactSecretData(doc)
+	}
+
+	return bytes.Join(docs, []byte("\n---\n"))
+}
+
+// redactSecretData replaces all values in the data and stringData of a secret.
+// Content that is not a secret is returned unchanged.
+func redactSecretData(doc []byte) []byte {
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal(doc, &obj); err != nil {
+		return doc
+	}
+	if obj["apiVersion"] != "v1" || obj["kind"] != "Secret" {
+		return doc
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(base.RedactedValue))
+	for field, value := range map[string]string{"data": encoded, "stringData": base.RedactedValue} {
+		data, ok := obj[field].(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		for k := range data {
+			data[k] = value
+		}
+	}
+
+	redacted, err := yaml.Marshal(obj)
+	if err != nil {
+		return doc
+	}
+	if !bytes.HasSuffix(doc, []byte("\n")) {
+		redacted = bytes.TrimSuffix(redacted, []byte("\n"))
+	}
+	return redacted
+}
+
+func createSupportArchive(topLevelDir string, files []supportArchiveFile) ([]byte, error) {
+	var b bytes.Buffer
+	gzipWriter := gzip.NewWriter(&b)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, file := range files {
+		header := &tar.Header{
+			Name: path.Join(topLevelDir, strings.TrimPrefix(file.Path, "/")),
+			Mode: 0644,
+			Size: int64(len(file.Content)),
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return nil, errors.Wrapf(err, "failed to write header for %s", file.Path)
+		}
+		if _, err := tarWriter.Write(file.Content); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", file.Path)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close tar writer")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close gzip writer")
+	}
+
+	return b.Bytes(), nil
+}
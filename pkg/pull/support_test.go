@@ -0,0 +1,65 @@
+package pull
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_anonymizeContent(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		licenseID string
+		expected  string
+	}{
+		{
+			name:      "replaces secret data",
+			licenseID: "abc123",
+			content: `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  password: aHVudGVyMg==
+stringData:
+  user: admin
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  license: abc123
+`,
+			expected: `apiVersion: v1
+data:
+  password: a290cy1yZWRhY3RlZA==
+kind: Secret
+metadata:
+  name: db
+stringData:
+  user: kots-redacted
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  license: kots-redacted
+`,
+		},
+		{
+			name:     "leaves other content unchanged",
+			content:  "not: [valid\n",
+			expected: "not: [valid\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := anonymizeContent([]byte(test.content), test.licenseID)
+			assert.Equal(t, test.expected, string(actual))
+		})
+	}
+}
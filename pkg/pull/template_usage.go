@@ -0,0 +1,42 @@
+package pull
+
+import (
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/replicatedhq/kots/pkg/upstream"
+)
+
+// TemplateUsage fetches the upstream, and reports the template functions, config items and
+// contexts that its files use, without rendering or writing anything
+func TemplateUsage(upstreamURI string, pullOptions PullOptions) (*template.Usage, error) {
+	log := logger.NewLogger()
+	if pullOptions.Silent {
+		log.Silence()
+	}
+
+	fetchOptions, err := fetchOptionsFromPullOptions(pullOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	log.ActionWithSpinner("Pulling upstream")
+	u, err := upstream.FetchUpstream(upstreamURI, fetchOptions)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return nil, errors.Wrap(err, "failed to fetch upstream")
+	}
+	log.FinishSpinner()
+
+	files := map[string][]byte{}
+	for _, file := range u.Files {
+		files[file.Path] = file.Content
+	}
+
+	usage, err := template.AnalyzeUsage(files)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to analyze template usage")
+	}
+
+	return usage, nil
+}
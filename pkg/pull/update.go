@@ -0,0 +1,214 @@
+package pull
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/rendermanifest"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/util"
+)
+
+// UpdateResult is what changed when an app directory was updated to the latest upstream release
+type UpdateResult struct {
+	FromCursor   string                  `json:"fromCursor"`
+	ToCursor     string                  `json:"toCursor"`
+	VersionLabel string                  `json:"versionLabel,omitempty"`
+	Updates      []upstream.Update       `json:"updates"`
+	Changes      *rendermanifest.Changes `json:"changes"`
+}
+
+// PullUpdate updates an app directory that was created by Pull. It only downloads the upstream
+// when there are releases after the update cursor in the directory, and then renders the base and
+// midstream again. The changes are the files that were rendered differently, so they can be
+// reviewed before they are committed. If upstreamURI is empty, the upstream that the directory was
+// pulled from is used.
+func PullUpdate(appDir string, upstreamURI string, pullOptions PullOptions) (*UpdateResult, error) {
+	return PullUpdateWithContext(context.Background(), appDir, upstreamURI, pullOptions)
+}
+
+// PullUpdateWithContext is PullUpdate, stopping when ctx is canceled or its deadline passes
+func PullUpdateWithContext(ctx context.Context, appDir string, upstreamURI string, pullOptions PullOptions) (*UpdateResult, error) {
+	previousManifest, err := rendermanifest.Load(appDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load render manifest")
+	}
+	if previousManifest == nil {
+		return nil, errors.Errorf("%s was not created by kots pull, it has no %s", appDir, rendermanifest.Filename)
+	}
+
+	if upstreamURI == "" {
+		upstreamURI = previousManifest.UpstreamURI
+	}
+	if !util.IsURL(upstreamURI) {
+		return nil, errors.Errorf("the upstream of %s is unknown, it has to be set to update", appDir)
+	}
+
+	pullOptions, installation, err := appDirPullOptions(appDir, previousManifest, pullOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchOptions, err := fetchOptionsFromPullOptions(pullOptions)
+	if err != nil {
+		return nil, err
+	}
+	fetchOptions.CurrentVersionLabel = installation.Spec.VersionLabel
+
+	updates, err := upstream.GetUpdatesUpstreamWithContext(ctx, upstreamURI, fetchOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updates")
+	}
+
+	result := UpdateResult{
+		FromCursor:   installation.Spec.UpdateCursor,
+		ToCursor:     installation.Spec.UpdateCursor,
+		VersionLabel: installation.Spec.VersionLabel,
+		Updates:      newerUpdates(updates, installation.Spec.UpdateCursor),
+		Changes:      &rendermanifest.Changes{},
+	}
+	if len(result.Updates) == 0 {
+		return &result, nil
+	}
+
+	renderDir, err := PullWithContext(ctx, upstreamURI, pullOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to pull update")
+	}
+
+	manifest, err := rendermanifest.Load(renderDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load updated render manifest")
+	}
+	if manifest == nil {
+		return nil, errors.Errorf("%s has no %s after the update", renderDir, rendermanifest.Filename)
+	}
+
+	result.ToCursor = manifest.UpdateCursor
+	result.VersionLabel = manifest.VersionLabel
+	result.Changes = previousManifest.Compare(manifest)
+
+	return &result, nil
+}
+
+// PullLocal renders an app directory that was created by Pull again, from the release manifests
+// in localPath, with the license, installation and config values in the directory. It's used while
+// the manifests of a release are being worked on.
+func PullLocal(appDir string, localPath string, pullOptions PullOptions) (string, error) {
+	manifest, err := rendermanifest.Load(appDir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to load render manifest")
+	}
+	if manifest == nil {
+		return "", errors.Errorf("%s was not created by kots pull, it has no %s", appDir, rendermanifest.Filename)
+	}
+
+	pullOptions, _, err = appDirPullOptions(appDir, manifest, pullOptions)
+	if err != nil {
+		return "", err
+	}
+	pullOptions.LocalPath = localPath
+
+	renderDir, err := Pull(manifest.UpstreamURI, pullOptions)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to pull")
+	}
+
+	return renderDir, nil
+}
+
+// PullReleaseWithContext renders an app directory that was created by Pull again, from the release
+// of its upstream at cursor, e.g. an update that was picked with the updatecheck package
+func PullReleaseWithContext(ctx context.Context, appDir string, cursor string, pullOptions PullOptions) (string, error) {
+	manifest, err := rendermanifest.Load(appDir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to load render manifest")
+	}
+	if manifest == nil {
+		return "", errors.Errorf("%s was not created by kots pull, it has no %s", appDir, rendermanifest.Filename)
+	}
+
+	pullOptions, _, err = appDirPullOptions(appDir, manifest, pullOptions)
+	if err != nil {
+		return "", err
+	}
+	pullOptions.UpdateCursor = cursor
+
+	renderDir, err := PullWithContext(ctx, manifest.UpstreamURI, pullOptions)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to pull release %s", cursor)
+	}
+
+	return renderDir, nil
+}
+
+// appDirPullOptions returns the options to pull an app directory again, with the user data that's
+// in it and how it was rendered
+func appDirPullOptions(appDir string, manifest *rendermanifest.RenderManifest, pullOptions PullOptions) (PullOptions, *kotsv1beta1.Installation, error) {
+	userdataDir := filepath.Join(appDir, "upstream", "userdata")
+	installationFile := filepath.Join(userdataDir, "installation.yaml")
+	installation, err := parseInstallationFromFile(installationFile)
+	if err != nil {
+		return pullOptions, nil, errors.Wrap(err, "failed to parse installation")
+	}
+	if installation == nil {
+		return pullOptions, nil, errors.Errorf("%s has no update cursor, %s is missing", appDir, installationFile)
+	}
+
+	if pullOptions.LicenseFile == "" {
+		licenseFile := filepath.Join(userdataDir, "license.yaml")
+		if _, err := os.Stat(licenseFile); err == nil {
+			pullOptions.LicenseFile = licenseFile
+		}
+	}
+	pullOptions.InstallationFile = installationFile
+
+	// the values are encrypted again with the key they were encrypted with
+	configValues, err := ioutil.ReadFile(filepath.Join(userdataDir, "config.yaml"))
+	if err != nil && !os.IsNotExist(err) {
+		return pullOptions, nil, errors.Wrap(err, "failed to read config values")
+	}
+	if upstream.IsEncryptedConfigValues(configValues) {
+		pullOptions.EncryptConfigValues = true
+	}
+	if pullOptions.HelmRenderer == "" {
+		pullOptions.HelmRenderer = manifest.HelmRenderer
+	}
+	if pullOptions.InstanceName == "" {
+		pullOptions.InstanceName = manifest.InstanceName
+	}
+	if manifest.SplitMultiDocYAML {
+		pullOptions.SplitMultiDocYAML = true
+	}
+	pullOptions.UpdateCursor = installation.Spec.UpdateCursor
+	pullOptions.RootDir = filepath.Dir(appDir)
+	pullOptions.CreateAppDir = true
+
+	return pullOptions, installation, nil
+}
+
+// newerUpdates returns the updates after the cursor. Replicated channel sequences and helm chart
+// versions are both compared as versions, other cursors are newer when they are different.
+func newerUpdates(updates []upstream.Update, cursor string) []upstream.Update {
+	current, _ := semver.NewVersion(cursor)
+
+	newer := []upstream.Update{}
+	for _, update := range updates {
+		if update.Cursor == cursor {
+			continue
+		}
+		if current != nil {
+			v, err := semver.NewVersion(update.Cursor)
+			if err == nil && !v.GreaterThan(current) {
+				continue
+			}
+		}
+		newer = append(newer, update)
+	}
+	return newer
+}
@@ -0,0 +1,60 @@
+package pull
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newerUpdates(t *testing.T) {
+	tests := []struct {
+		name     string
+		updates  []upstream.Update
+		cursor   string
+		expected []upstream.Update
+	}{
+		{
+			name:     "channel sequences",
+			updates:  []upstream.Update{{Cursor: "9"}, {Cursor: "10"}, {Cursor: "11", VersionLabel: "1.1.0"}},
+			cursor:   "10",
+			expected: []upstream.Update{{Cursor: "11", VersionLabel: "1.1.0"}},
+		},
+		{
+			name:     "chart versions",
+			updates:  []upstream.Update{{Cursor: "1.2.0"}, {Cursor: "1.10.0"}, {Cursor: "0.9.1"}},
+			cursor:   "1.2.0",
+			expected: []upstream.Update{{Cursor: "1.10.0"}},
+		},
+		{
+			name:     "no cursor",
+			updates:  []upstream.Update{{Cursor: "1"}},
+			cursor:   "",
+			expected: []upstream.Update{{Cursor: "1"}},
+		},
+		{
+			name:     "up to date",
+			updates:  []upstream.Update{{Cursor: "3"}},
+			cursor:   "3",
+			expected: []upstream.Update{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, newerUpdates(test.updates, test.cursor))
+		})
+	}
+}
+
+func Test_PullUpdateWithoutRenderManifest(t *testing.T) {
+	appDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(appDir)
+
+	_, err = PullUpdate(appDir, "replicated://my-app", PullOptions{})
+	assert.Error(t, err)
+}
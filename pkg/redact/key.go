@@ -0,0 +1,64 @@
+package redact
+
+import (
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// MaskKeySecretName is the secret with the mask key of the install, in the namespace of the
+	// admin console
+	MaskKeySecretName = "kotsadm-redact-key"
+
+	maskKeySecretKey = "maskKey"
+	maskKeyLength    = 32
+)
+
+// EnsureMaskKey returns the key that the values of the install in the namespace are masked with,
+// it's created the first time. Masks made with it can be compared between support bundles of the
+// install, but not with other installs.
+func EnsureMaskKey(clientset kubernetes.Interface, namespace string) ([]byte, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(MaskKeySecretName, metav1.GetOptions{})
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(string(secret.Data[maskKeySecretKey]))
+		if err != nil || len(key) != maskKeyLength {
+			return nil, errors.Errorf("secret %s has no valid %s", MaskKeySecretName, maskKeySecretKey)
+		}
+		return key, nil
+	}
+	if !kuberneteserrors.IsNotFound(err) {
+		return nil, errors.Wrap(err, "failed to get mask key secret")
+	}
+
+	key, err := newMaskKey()
+	if err != nil {
+		return nil, err
+	}
+
+	secret = &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      MaskKeySecretName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"kots.io/kotsadm": "true",
+			},
+		},
+		Data: map[string][]byte{
+			maskKeySecretKey: []byte(base64.StdEncoding.EncodeToString(key)),
+		},
+	}
+	if _, err := clientset.CoreV1().Secrets(namespace).Create(secret); err != nil {
+		return nil, errors.Wrap(err, "failed to create mask key secret")
+	}
+
+	return key, nil
+}
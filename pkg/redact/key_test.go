@@ -0,0 +1,27 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_EnsureMaskKey(t *testing.T) {
+	req := require.New(t)
+	clientset := fake.NewSimpleClientset()
+
+	key, err := EnsureMaskKey(clientset, "default")
+	req.NoError(err)
+	assert.Len(t, key, maskKeyLength)
+
+	// the key in the secret is used after it's created
+	existing, err := EnsureMaskKey(clientset, "default")
+	req.NoError(err)
+	assert.Equal(t, key, existing)
+
+	other, err := EnsureMaskKey(clientset, "other")
+	req.NoError(err)
+	assert.NotEqual(t, key, other)
+}
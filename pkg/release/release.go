@@ -0,0 +1,293 @@
+package release
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/archive"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/image"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/version"
+	"gopkg.in/yaml.v2"
+)
+
+// ManifestFilename is the first file in a release archive, it describes the release
+const ManifestFilename = "release.yaml"
+
+// manifestsDir is the directory in a release archive with the upstream manifests
+const manifestsDir = "manifests"
+
+// manifests have templates, so the kinds are read without parsing them
+var kindRegexp = regexp.MustCompile(`(?m)^kind:\s*["']?([A-Za-z0-9]+)`)
+
+type GenerateOptions struct {
+	// OutputPath is the file to write the release archive to, defaults to release.tar.gz in the
+	// current directory
+	OutputPath string
+	// UpdateCursor and VersionLabel are stamped on the release, they default to the ones of the
+	// installation in the app directory. The release must have an update cursor.
+	UpdateCursor string
+	VersionLabel string
+	Silent       bool
+}
+
+// Manifest describes a release archive
+type Manifest struct {
+	KotsVersion  string `yaml:"kotsVersion"`
+	AppSlug      string `yaml:"appSlug,omitempty"`
+	UpdateCursor string `yaml:"updateCursor"`
+	VersionLabel string `yaml:"versionLabel,omitempty"`
+
+	// Files are the paths of the manifests in the archive, in the order they are deployed
+	Files  []string `yaml:"files"`
+	Images []string `yaml:"images"`
+	// Lint are the problems found in the manifests after they were rendered
+	Lint []LintResult `yaml:"lint"`
+}
+
+// LintResult is a problem with an object in a manifest
+type LintResult struct {
+	Path    string `yaml:"path"`
+	Line    int    `yaml:"line"`
+	Message string `yaml:"message"`
+}
+
+// Release is a release archive that has been written
+type Release struct {
+	Path     string
+	Manifest Manifest
+}
+
+// Generate creates a release archive from the upstream of an app directory created by kots pull,
+// for promoting it to a channel. The manifests are rendered with the config values in the app
+// directory to lint them and to list the images, but the archive has the manifests with their
+// templates. User data, such as the license and config values, isn't included. The archive is
+// written when there are lint results, the caller decides if they should stop the release.
+func Generate(appDir string, generateOptions GenerateOptions) (*Release, error) {
+	log := logger.NewLogger()
+	if generateOptions.Silent {
+		log.Silence()
+	}
+
+	inspection, err := archive.Inspect(appDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to inspect app dir")
+	}
+
+	manifest := Manifest{
+		KotsVersion:  version.Version(),
+		AppSlug:      inspection.Name,
+		UpdateCursor: generateOptions.UpdateCursor,
+		VersionLabel: generateOptions.VersionLabel,
+		Lint:         []LintResult{},
+	}
+	if manifest.UpdateCursor == "" {
+		manifest.UpdateCursor = inspection.UpdateCursor
+	}
+	if manifest.VersionLabel == "" {
+		manifest.VersionLabel = inspection.VersionLabel
+	}
+	if manifest.UpdateCursor == "" {
+		return nil, errors.New("the app dir doesn't have an update cursor, one must be set")
+	}
+
+	u, err := readUpstreamDir(filepath.Join(appDir, "upstream"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read upstream")
+	}
+
+	log.ActionWithSpinner("Rendering manifests")
+	b, err := base.RenderUpstream(u, &base.RenderOptions{
+		Log: log,
+	})
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return nil, errors.Wrap(err, "failed to render upstream")
+	}
+	log.FinishSpinner()
+
+	validationErrors, err := b.Validate(base.ValidateOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to validate base")
+	}
+	for _, validationError := range validationErrors {
+		manifest.Lint = append(manifest.Lint, LintResult{
+			Path:    validationError.Path,
+			Line:    validationError.Line,
+			Message: validationError.Message,
+		})
+	}
+
+	images, err := listBaseImages(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list images")
+	}
+	manifest.Images = images
+
+	files := releaseFiles(u)
+	for _, file := range files {
+		manifest.Files = append(manifest.Files, filepath.ToSlash(filepath.Join(manifestsDir, file.Path)))
+	}
+
+	release := Release{
+		Path:     generateOptions.OutputPath,
+		Manifest: manifest,
+	}
+	if release.Path == "" {
+		release.Path = "release.tar.gz"
+	}
+
+	if err := writeArchive(release.Path, manifest, files); err != nil {
+		return nil, errors.Wrap(err, "failed to write archive")
+	}
+
+	return &release, nil
+}
+
+// readUpstreamDir reads the upstream of an app directory, it's a helm chart when it has a
+// Chart.yaml
+func readUpstreamDir(upstreamDir string) (*upstream.Upstream, error) {
+	u := upstream.Upstream{
+		Type: "replicated",
+	}
+	if _, err := os.Stat(filepath.Join(upstreamDir, "Chart.yaml")); err == nil {
+		u.Type = "helm"
+	}
+
+	err := filepath.Walk(upstreamDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		relPath, err := filepath.Rel(upstreamDir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get relative path of %s", path)
+		}
+
+		u.Files = append(u.Files, upstream.UpstreamFile{
+			Path:    relPath,
+			Content: content,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// releaseFiles returns the upstream files without the user data, in the order they are deployed
+// and then by path
+func releaseFiles(u *upstream.Upstream) []upstream.UpstreamFile {
+	files := []upstream.UpstreamFile{}
+	for _, file := range u.Files {
+		if strings.HasPrefix(filepath.ToSlash(file.Path), "userdata/") {
+			continue
+		}
+		files = append(files, file)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		ri, rj := fileRank(files[i].Content), fileRank(files[j].Content)
+		if ri != rj {
+			return ri < rj
+		}
+		return files[i].Path < files[j].Path
+	})
+
+	return files
+}
+
+// fileRank is the deploy position of the first kind to be deployed in the file, see k8sdoc.KindRank
+func fileRank(content []byte) int {
+	rank := k8sdoc.KindRank("")
+	for _, match := range kindRegexp.FindAllSubmatch(content, -1) {
+		if r := k8sdoc.KindRank(string(match[1])); r < rank {
+			rank = r
+		}
+	}
+	return rank
+}
+
+func listBaseImages(b *base.Base) ([]string, error) {
+	tempDir, err := ioutil.TempDir("", "kots")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tempDir)
+
+	baseDir := filepath.Join(tempDir, "base")
+	if err := b.WriteBase(base.WriteOptions{BaseDir: baseDir}); err != nil {
+		return nil, errors.Wrap(err, "failed to write base")
+	}
+
+	return image.ListImages(baseDir, nil)
+}
+
+// writeArchive writes the manifest and then the files in order, so that the release can be
+// recognized without extracting it
+func writeArchive(archivePath string, manifest Manifest, files []upstream.UpstreamFile) error {
+	b, err := yaml.Marshal(manifest)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal manifest")
+	}
+
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+	defer f.Close()
+
+	gzipWriter := gzip.NewWriter(f)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	if err := writeArchiveFile(tarWriter, ManifestFilename, b); err != nil {
+		return errors.Wrap(err, "failed to write manifest")
+	}
+	for i, file := range files {
+		if err := writeArchiveFile(tarWriter, manifest.Files[i], file.Content); err != nil {
+			return errors.Wrapf(err, "failed to write %s", file.Path)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return errors.Wrap(err, "failed to close tar writer")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return errors.Wrap(err, "failed to close gzip writer")
+	}
+	return f.Close()
+}
+
+func writeArchiveFile(tarWriter *tar.Writer, name string, content []byte) error {
+	header := &tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(content)),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return errors.Wrap(err, "failed to write header")
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		return errors.Wrap(err, "failed to write content")
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package release
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+var testUpstream = map[string]string{
+	"deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: repl{{ ConfigOption "image" }}
+`,
+	"service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: "eighty"
+`,
+	"namespace.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+`,
+	"config.yaml": `apiVersion: kots.io/v1beta1
+kind: Config
+metadata:
+  name: config
+spec:
+  groups:
+  - name: images
+    title: Images
+    items:
+    - name: image
+      type: text
+      default: nginx:1.17
+`,
+	"userdata/installation.yaml": `apiVersion: kots.io/v1beta1
+kind: Installation
+metadata:
+  name: my-app
+spec:
+  updateCursor: "12"
+  versionLabel: 1.2.0
+  encryptionKey: %s
+`,
+	"userdata/config.yaml": `apiVersion: kots.io/v1beta1
+kind: ConfigValues
+metadata:
+  name: my-app
+spec:
+  values:
+    image:
+      value: registry.example.com/web:1.2.0
+`,
+}
+
+func Test_Generate(t *testing.T) {
+	req := require.New(t)
+
+	appDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(appDir)
+
+	cipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	for name, content := range testUpstream {
+		if name == "userdata/installation.yaml" {
+			content = fmt.Sprintf(content, cipher.ToString())
+		}
+		filename := filepath.Join(appDir, "upstream", name)
+		req.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		req.NoError(ioutil.WriteFile(filename, []byte(content), 0644))
+	}
+
+	archivePath := filepath.Join(appDir, "release.tar.gz")
+	r, err := Generate(appDir, GenerateOptions{
+		OutputPath: archivePath,
+		Silent:     true,
+	})
+	req.NoError(err)
+
+	assert.Equal(t, "my-app", r.Manifest.AppSlug)
+	assert.Equal(t, "12", r.Manifest.UpdateCursor)
+	assert.Equal(t, "1.2.0", r.Manifest.VersionLabel)
+	assert.Equal(t, []string{"registry.example.com/web:1.2.0"}, r.Manifest.Images)
+	assert.Equal(t, []string{
+		"manifests/namespace.yaml",
+		"manifests/service.yaml",
+		"manifests/deployment.yaml",
+		"manifests/config.yaml",
+	}, r.Manifest.Files)
+	req.Len(r.Manifest.Lint, 1)
+	assert.Equal(t, "service.yaml", r.Manifest.Lint[0].Path)
+
+	// the manifest is first and the manifests are in order, with their templates
+	f, err := os.Open(archivePath)
+	req.NoError(err)
+	defer f.Close()
+	gzipReader, err := gzip.NewReader(f)
+	req.NoError(err)
+	tarReader := tar.NewReader(gzipReader)
+
+	names := []string{}
+	contents := map[string]string{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		req.NoError(err)
+		b, err := ioutil.ReadAll(tarReader)
+		req.NoError(err)
+		names = append(names, header.Name)
+		contents[header.Name] = string(b)
+	}
+	assert.Equal(t, append([]string{ManifestFilename}, r.Manifest.Files...), names)
+	assert.Equal(t, testUpstream["deployment.yaml"], contents["manifests/deployment.yaml"])
+
+	manifest := Manifest{}
+	req.NoError(yaml.Unmarshal([]byte(contents[ManifestFilename]), &manifest))
+	assert.Equal(t, r.Manifest, manifest)
+
+	// the cursor can be set
+	r, err = Generate(appDir, GenerateOptions{
+		OutputPath:   archivePath,
+		UpdateCursor: "13",
+		Silent:       true,
+	})
+	req.NoError(err)
+	assert.Equal(t, "13", r.Manifest.UpdateCursor)
+
+	// a release must have a cursor
+	req.NoError(os.Remove(filepath.Join(appDir, "upstream", "userdata", "installation.yaml")))
+	_, err = Generate(appDir, GenerateOptions{
+		OutputPath: archivePath,
+		Silent:     true,
+	})
+	assert.Error(t, err)
+}
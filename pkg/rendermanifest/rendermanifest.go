@@ -0,0 +1,219 @@
+package rendermanifest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/version"
+	"gopkg.in/yaml.v2"
+)
+
+// Filename is the name of the render manifest in the app directory
+const Filename = "render-manifest.yaml"
+
+// renderedDirs are the directories in the app directory that have checksums in the manifest
+var renderedDirs = []string{"upstream", "base", "overlays"}
+
+// RenderManifest records how an application directory was rendered, so that later
+// commands can tell what produced it and whether it has been changed since.
+type RenderManifest struct {
+	KotsVersion      string `yaml:"kotsVersion"`
+	UpstreamURI      string `yaml:"upstreamURI"`
+	UpdateCursor     string `yaml:"updateCursor,omitempty"`
+	VersionLabel     string `yaml:"versionLabel,omitempty"`
+	ConfigValuesHash string `yaml:"configValuesHash,omitempty"`
+	// RenderInputsHash is the checksum of all of the upstream files, including the license, config
+	// values and helm values in userdata, so that renders of the same inputs can be told apart from
+	// renders of changed inputs
+	RenderInputsHash  string            `yaml:"renderInputsHash,omitempty"`
+	TemplateFunctions map[string]string `yaml:"templateFunctions,omitempty"`
+	// HelmRenderer is the renderer that a helm chart upstream was rendered with, it's used again
+	// when the app directory is pulled again
+	HelmRenderer string `yaml:"helmRenderer,omitempty"`
+	// InstanceName is the instance name that the app was pulled with, it's used again when the app
+	// directory is pulled again so that the objects keep their names
+	InstanceName string `yaml:"instanceName,omitempty"`
+	// SplitMultiDocYAML is set when the base was written with a file per object, so that the base
+	// keeps its layout when the app directory is pulled again
+	SplitMultiDocYAML bool `yaml:"splitMultiDocYAML,omitempty"`
+
+	// Files are the sha256 checksums of all rendered files, by path relative to the app directory
+	Files map[string]string `yaml:"files"`
+}
+
+// Create builds the manifest for an app directory that u was rendered to
+func Create(appDir string, u *upstream.Upstream) (*RenderManifest, error) {
+	files, err := fileChecksums(appDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file checksums")
+	}
+
+	m := RenderManifest{
+		KotsVersion:       version.Version(),
+		UpstreamURI:       u.URI,
+		UpdateCursor:      u.UpdateCursor,
+		VersionLabel:      u.VersionLabel,
+		RenderInputsHash:  renderInputsHash(u.Files),
+		TemplateFunctions: template.FunctionVersions(),
+		Files:             files,
+	}
+
+	for _, file := range u.Files {
+		if filepath.ToSlash(file.Path) == "userdata/config.yaml" {
+			m.ConfigValuesHash = checksum(file.Content)
+		}
+	}
+
+	return &m, nil
+}
+
+// Write writes the manifest to the app directory
+func (m *RenderManifest) Write(appDir string) error {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal render manifest")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(appDir, Filename), b, 0644); err != nil {
+		return errors.Wrap(err, "failed to write render manifest")
+	}
+	return nil
+}
+
+// Load reads the manifest from the app directory. It returns nil if there is none.
+func Load(appDir string) (*RenderManifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(appDir, Filename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read render manifest")
+	}
+
+	m := RenderManifest{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal render manifest")
+	}
+	return &m, nil
+}
+
+// Changes are the differences between the files in an app directory and its manifest
+type Changes struct {
+	Modified []string `json:"modified"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+}
+
+func (c Changes) HasChanges() bool {
+	return len(c.Modified)+len(c.Added)+len(c.Removed) > 0
+}
+
+// Verify compares the files in the app directory to the checksums in the manifest
+func (m *RenderManifest) Verify(appDir string) (*Changes, error) {
+	current, err := fileChecksums(appDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file checksums")
+	}
+
+	return compareChecksums(m.Files, current), nil
+}
+
+// VerifyAppDir compares the files in an app directory created by kots pull to the checksums in its
+// manifest, to find the files that were changed by hand since they were rendered
+func VerifyAppDir(appDir string) (*Changes, error) {
+	m, err := Load(appDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load render manifest")
+	}
+	if m == nil {
+		return nil, errors.Errorf("%s was not created by kots pull, it has no %s", appDir, Filename)
+	}
+
+	return m.Verify(appDir)
+}
+
+// Compare returns the files that changed between this manifest and the manifest of a later render
+// of the same app directory
+func (m *RenderManifest) Compare(later *RenderManifest) *Changes {
+	return compareChecksums(m.Files, later.Files)
+}
+
+func compareChecksums(recorded map[string]string, current map[string]string) *Changes {
+	changes := Changes{}
+	for filePath, sum := range current {
+		recordedSum, ok := recorded[filePath]
+		if !ok {
+			changes.Added = append(changes.Added, filePath)
+		} else if recordedSum != sum {
+			changes.Modified = append(changes.Modified, filePath)
+		}
+	}
+	for filePath := range recorded {
+		if _, ok := current[filePath]; !ok {
+			changes.Removed = append(changes.Removed, filePath)
+		}
+	}
+
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+
+	return &changes
+}
+
+func fileChecksums(appDir string) (map[string]string, error) {
+	files := map[string]string{}
+	for _, dir := range renderedDirs {
+		err := filepath.Walk(filepath.Join(appDir, dir), func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			content, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s", filePath)
+			}
+
+			relPath, err := filepath.Rel(appDir, filePath)
+			if err != nil {
+				return errors.Wrap(err, "failed to get relative path")
+			}
+			files[filepath.ToSlash(relPath)] = checksum(content)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return nil, errors.Wrapf(err, "failed to walk %s", dir)
+		}
+	}
+	return files, nil
+}
+
+// renderInputsHash is the checksum of the paths and contents of the files, in the order of their paths
+func renderInputsHash(files []upstream.UpstreamFile) string {
+	sorted := make([]upstream.UpstreamFile, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool {
+		return filepath.ToSlash(sorted[i].Path) < filepath.ToSlash(sorted[j].Path)
+	})
+
+	h := sha256.New()
+	for _, file := range sorted {
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(file.Path), len(file.Content))
+		h.Write(file.Content)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func checksum(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
@@ -0,0 +1,112 @@
+package rendermanifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CreateAndVerify(t *testing.T) {
+	appDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(appDir)
+
+	writeFile := func(name string, content string) {
+		p := filepath.Join(appDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, []byte(content), 0644))
+	}
+	writeFile("upstream/userdata/config.yaml", "config")
+	writeFile("base/deployment.yaml", "deployment")
+	writeFile("overlays/midstream/kustomization.yaml", "kustomization")
+
+	u := &upstream.Upstream{
+		URI:          "replicated://my-app",
+		UpdateCursor: "12",
+		Files: []upstream.UpstreamFile{
+			{Path: "userdata/config.yaml", Content: []byte("config")},
+		},
+	}
+
+	m, err := Create(appDir, u)
+	require.NoError(t, err)
+	assert.Equal(t, "replicated://my-app", m.UpstreamURI)
+	assert.Equal(t, "12", m.UpdateCursor)
+	assert.Equal(t, "b79606fb3afea5bd1609ed40b622142f1c98125abcfe89a76a661b0e8e343910", m.ConfigValuesHash)
+	assert.Equal(t, renderInputsHash(u.Files), m.RenderInputsHash)
+	assert.Len(t, m.Files, 3)
+
+	require.NoError(t, m.Write(appDir))
+	loaded, err := Load(appDir)
+	require.NoError(t, err)
+	assert.Equal(t, m, loaded)
+
+	changes, err := VerifyAppDir(appDir)
+	require.NoError(t, err)
+	assert.False(t, changes.HasChanges())
+
+	writeFile("base/deployment.yaml", "changed")
+	writeFile("base/service.yaml", "service")
+	require.NoError(t, os.Remove(filepath.Join(appDir, "overlays/midstream/kustomization.yaml")))
+
+	changes, err = loaded.Verify(appDir)
+	require.NoError(t, err)
+	assert.Equal(t, &Changes{
+		Modified: []string{"base/deployment.yaml"},
+		Added:    []string{"base/service.yaml"},
+		Removed:  []string{"overlays/midstream/kustomization.yaml"},
+	}, changes)
+}
+
+func Test_VerifyAppDirWithoutManifest(t *testing.T) {
+	appDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(appDir)
+
+	_, err = VerifyAppDir(appDir)
+	assert.Error(t, err)
+}
+
+func Test_renderInputsHash(t *testing.T) {
+	files := []upstream.UpstreamFile{
+		{Path: "deployment.yaml", Content: []byte("deployment")},
+		{Path: "userdata/config.yaml", Content: []byte("config")},
+	}
+	reordered := []upstream.UpstreamFile{files[1], files[0]}
+	changedConfig := []upstream.UpstreamFile{files[0], {Path: "userdata/config.yaml", Content: []byte("changed")}}
+	moved := []upstream.UpstreamFile{{Path: "deploymen", Content: []byte("t.yamldeployment")}, files[1]}
+
+	assert.Equal(t, renderInputsHash(files), renderInputsHash(reordered))
+	assert.NotEqual(t, renderInputsHash(files), renderInputsHash(changedConfig))
+	assert.NotEqual(t, renderInputsHash(files), renderInputsHash(moved))
+	assert.NotEqual(t, renderInputsHash(files), renderInputsHash(nil))
+}
+
+func Test_Compare(t *testing.T) {
+	previous := &RenderManifest{
+		Files: map[string]string{
+			"upstream/deployment.yaml": "a",
+			"base/deployment.yaml":     "b",
+			"base/configmap.yaml":      "c",
+		},
+	}
+	later := &RenderManifest{
+		Files: map[string]string{
+			"upstream/deployment.yaml": "a",
+			"base/deployment.yaml":     "d",
+			"base/secret.yaml":         "e",
+		},
+	}
+
+	assert.Equal(t, &Changes{
+		Modified: []string{"base/deployment.yaml"},
+		Added:    []string{"base/secret.yaml"},
+		Removed:  []string{"base/configmap.yaml"},
+	}, previous.Compare(later))
+	assert.False(t, later.Compare(later).HasChanges())
+}
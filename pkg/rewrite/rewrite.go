@@ -12,29 +12,40 @@ import (
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/midstream"
+	"github.com/replicatedhq/kots/pkg/rendermanifest"
 	"github.com/replicatedhq/kots/pkg/upstream"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/kustomize/v3/pkg/image"
 )
 
 type RewriteOptions struct {
-	RootDir           string
-	UpstreamURI       string
-	UpstreamPath      string
-	Downstreams       []string
-	K8sNamespace      string
-	Silent            bool
-	CreateAppDir      bool
-	ExcludeKotsKinds  bool
-	Installation      *kotsv1beta1.Installation
-	License           *kotsv1beta1.License
-	ConfigValues      *kotsv1beta1.ConfigValues
-	ReportWriter      io.Writer
-	CopyImages        bool
-	RegistryEndpoint  string
-	RegistryUsername  string
-	RegistryPassword  string
-	RegistryNamespace string
+	RootDir              string
+	UpstreamURI          string
+	UpstreamPath         string
+	Downstreams          []string
+	K8sNamespace         string
+	Silent               bool
+	CreateAppDir         bool
+	ExcludeKotsKinds     bool
+	SplitMultiDocYAML    bool
+	Installation         *kotsv1beta1.Installation
+	License              *kotsv1beta1.License
+	ConfigValues         *kotsv1beta1.ConfigValues
+	ReportWriter         io.Writer
+	CopyImages           bool
+	RegistryEndpoint     string
+	RegistryUsername     string
+	RegistryPassword     string
+	RegistryNamespace    string
+	RegistryOCIOnly      bool
+	ImagePlatforms       []string
+	ImageLocations       []k8sdoc.ImageLocation
+	AdditionalNamespaces []string
+	TemplateEnvPrefixes  []string
+	ValidateBase         bool
+
+	// Transformers are run on the rendered objects before the base is written
+	Transformers []midstream.Transformer
 }
 
 func Rewrite(rewriteOptions RewriteOptions) error {
@@ -83,10 +94,11 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 
 	if rewriteOptions.CopyImages {
 		writeUpstreamImageOptions := upstream.WriteUpstreamImageOptions{
-			RootDir:      rewriteOptions.RootDir,
-			CreateAppDir: rewriteOptions.CreateAppDir,
-			ReportWriter: rewriteOptions.ReportWriter,
-			Log:          log,
+			RootDir:        rewriteOptions.RootDir,
+			CreateAppDir:   rewriteOptions.CreateAppDir,
+			ImageLocations: rewriteOptions.ImageLocations,
+			ReportWriter:   rewriteOptions.ReportWriter,
+			Log:            log,
 			SourceRegistry: registry.RegistryOptions{
 				Endpoint:      replicatedRegistryInfo.Registry,
 				ProxyEndpoint: replicatedRegistryInfo.Proxy,
@@ -96,7 +108,9 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 				Namespace: rewriteOptions.RegistryNamespace,
 				Username:  rewriteOptions.RegistryUsername,
 				Password:  rewriteOptions.RegistryPassword,
+				OCIOnly:   rewriteOptions.RegistryOCIOnly,
 			},
+			Platforms: rewriteOptions.ImagePlatforms,
 		}
 		if fetchOptions.License != nil {
 			writeUpstreamImageOptions.AppSlug = fetchOptions.License.Spec.AppSlug
@@ -112,9 +126,10 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 	}
 
 	findObjectsOptions := upstream.FindObjectsWithImagesOptions{
-		RootDir:      rewriteOptions.RootDir,
-		CreateAppDir: rewriteOptions.CreateAppDir,
-		Log:          log,
+		RootDir:        rewriteOptions.RootDir,
+		CreateAppDir:   rewriteOptions.CreateAppDir,
+		ImageLocations: rewriteOptions.ImageLocations,
+		Log:            log,
 	}
 	affectedObjects, err := u.FindObjectsWithImages(findObjectsOptions)
 	if err != nil {
@@ -131,10 +146,21 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 				return errors.Wrapf(err, "failed to load registry auth for %q", rewriteOptions.RegistryEndpoint)
 			}
 		}
+
+		// cloud registries are logged in to with a token, instead of writing the cloud credentials to the pull secret
+		login, err := registry.PullSecretLogin(registry.RegistryOptions{
+			Endpoint: rewriteOptions.RegistryEndpoint,
+			Username: registryUser,
+			Password: registryPass,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to get registry login for pull secret")
+		}
+
 		pullSecret, err = registry.PullSecretForRegistries(
 			[]string{rewriteOptions.RegistryEndpoint},
-			registryUser,
-			registryPass,
+			login.Username,
+			login.Password,
 			rewriteOptions.K8sNamespace,
 		)
 		if err != nil {
@@ -156,8 +182,9 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 	objects = affectedObjects
 
 	renderOptions := base.RenderOptions{
-		SplitMultiDocYAML: true,
+		SplitMultiDocYAML: rewriteOptions.SplitMultiDocYAML,
 		Namespace:         rewriteOptions.K8sNamespace,
+		EnvPrefixes:       rewriteOptions.TemplateEnvPrefixes,
 		Log:               log,
 	}
 	log.ActionWithSpinner("Creating base")
@@ -167,6 +194,26 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 	}
 	log.FinishSpinner()
 
+	if err := midstream.TransformBase(b, rewriteOptions.Transformers, rewriteOptions.ExcludeKotsKinds); err != nil {
+		return errors.Wrap(err, "failed to transform base")
+	}
+
+	if rewriteOptions.ValidateBase {
+		validateOptions := base.ValidateOptions{
+			ExcludeKotsKinds: rewriteOptions.ExcludeKotsKinds,
+		}
+		validationErrors, err := b.Validate(validateOptions)
+		if err != nil {
+			return errors.Wrap(err, "failed to validate base")
+		}
+		for _, warning := range validationErrors.Warnings() {
+			log.Info("%s", warning.Error())
+		}
+		if errs := validationErrors.Errors(); len(errs) > 0 {
+			return errs
+		}
+	}
+
 	writeBaseOptions := base.WriteOptions{
 		BaseDir:          u.GetBaseDir(writeUpstreamOptions),
 		Overwrite:        true,
@@ -178,7 +225,7 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 
 	log.ActionWithSpinner("Creating midstream")
 
-	m, err := midstream.CreateMidstream(b, images, objects, pullSecret)
+	m, err := midstream.CreateMidstream(b, images, objects, pullSecret, rewriteOptions.AdditionalNamespaces)
 	if err != nil {
 		return errors.Wrap(err, "failed to create midstream")
 	}
@@ -191,6 +238,7 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 	if err := m.WriteMidstream(writeMidstreamOptions); err != nil {
 		return errors.Wrap(err, "failed to write midstream")
 	}
+	m.ReconcileReport.Log(log)
 
 	for _, downstreamName := range rewriteOptions.Downstreams {
 		log.ActionWithSpinner("Creating downstream %q", downstreamName)
@@ -200,7 +248,7 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 		}
 
 		writeDownstreamOptions := downstream.WriteOptions{
-			DownstreamDir: filepath.Join(b.GetOverlaysDir(writeBaseOptions), "downstreams", downstreamName),
+			DownstreamDir: downstream.Dir(b.GetOverlaysDir(writeBaseOptions), downstreamName),
 			MidstreamDir:  writeMidstreamOptions.MidstreamDir,
 		}
 		if err := d.WriteDownstream(writeDownstreamOptions); err != nil {
@@ -210,6 +258,15 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 		log.FinishSpinner()
 	}
 
+	appDir := filepath.Dir(u.GetBaseDir(writeUpstreamOptions))
+	renderManifest, err := rendermanifest.Create(appDir, u)
+	if err != nil {
+		return errors.Wrap(err, "failed to create render manifest")
+	}
+	if err := renderManifest.Write(appDir); err != nil {
+		return errors.Wrap(err, "failed to write render manifest")
+	}
+
 	return nil
 }
 
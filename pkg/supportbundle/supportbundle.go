@@ -0,0 +1,298 @@
+package supportbundle
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mholt/archiver"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/redact"
+	troubleshootv1beta1 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta1"
+	troubleshootscheme "github.com/replicatedhq/troubleshoot/pkg/client/troubleshootclientset/scheme"
+	"github.com/replicatedhq/troubleshoot/pkg/collect"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func init() {
+	troubleshootscheme.AddToScheme(scheme.Scheme)
+}
+
+// versionFilename is the first file in a support bundle, it's used to recognize the archive
+const versionFilename = "version.yaml"
+
+var yamlDocSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+type GenerateOptions struct {
+	Kubeconfig string
+	// Namespace is the namespace of the admin console, the values in the bundle are masked with
+	// the key of its install so that they can be compared between bundles, see redact.EnsureMaskKey.
+	// A random key is used when it's empty.
+	Namespace string
+	// OutputPath is the file to write the bundle to, defaults to support-bundle.tar.gz in the
+	// current directory
+	OutputPath string
+	// RedactPatterns are regular expressions of more values to redact, in addition to the
+	// defaults of the redact package
+	RedactPatterns []string
+	Silent         bool
+}
+
+// Bundle is a support bundle that has been written
+type Bundle struct {
+	Path string
+	// CollectorErrors are the collectors that failed, the bundle has the output of the others
+	CollectorErrors []string
+}
+
+// FindCollectors returns the support bundle specs of an app directory created by kots pull. The
+// base has the specs after the templates have been rendered, the upstream is used when the base
+// doesn't have kots kinds.
+func FindCollectors(appDir string) ([]*troubleshootv1beta1.Collector, error) {
+	for _, dir := range []string{"base", "upstream"} {
+		collectors, err := findCollectorsInDir(filepath.Join(appDir, dir))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find support bundle specs in %s", dir)
+		}
+		if len(collectors) > 0 {
+			return collectors, nil
+		}
+	}
+	return []*troubleshootv1beta1.Collector{}, nil
+}
+
+func findCollectorsInDir(dir string) ([]*troubleshootv1beta1.Collector, error) {
+	collectors := []*troubleshootv1beta1.Collector{}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return collectors, nil
+	}
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		for _, doc := range yamlDocSeparator.Split(string(content), -1) {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			obj, _, err := decode([]byte(doc), nil, nil)
+			if err != nil {
+				continue
+			}
+			if collector, ok := obj.(*troubleshootv1beta1.Collector); ok {
+				collectors = append(collectors, collector)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return collectors, nil
+}
+
+// Generate runs the collectors of the specs against the cluster and writes the output to a
+// tar.gz. Secrets in the output are redacted, by the collectors and then with the redact
+// package. A collector that fails doesn't stop the others.
+func Generate(collectors []*troubleshootv1beta1.Collector, generateOptions GenerateOptions) (*Bundle, error) {
+	log := logger.NewLogger()
+	if generateOptions.Silent {
+		log.Silence()
+	}
+
+	clientConfig, err := clientcmd.BuildConfigFromFlags("", generateOptions.Kubeconfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	var maskKey []byte
+	if generateOptions.Namespace != "" {
+		maskKey, err = installMaskKey(clientConfig, generateOptions.Namespace)
+		if err != nil {
+			log.Info("Unable to get the redaction key of the admin console, values are masked differently than in other bundles: %s", err.Error())
+		}
+	}
+
+	redactor, err := redact.NewRedactor(redact.Options{
+		Patterns: generateOptions.RedactPatterns,
+		MaskKey:  maskKey,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create redactor")
+	}
+
+	bundleDir, err := ioutil.TempDir("", "kots")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(bundleDir)
+
+	if err := writeVersionFile(bundleDir); err != nil {
+		return nil, errors.Wrap(err, "failed to write version file")
+	}
+
+	bundle := Bundle{
+		Path: generateOptions.OutputPath,
+	}
+	if bundle.Path == "" {
+		bundle.Path = "support-bundle.tar.gz"
+	}
+
+	for _, c := range collectList(collectors) {
+		collector := collect.Collector{
+			Redact:       true,
+			Collect:      c,
+			ClientConfig: clientConfig,
+		}
+
+		log.ChildActionWithSpinner("Collecting %s", collector.GetDisplayName())
+		output, err := collector.RunCollectorSync()
+		if err == nil {
+			err = writeCollectorOutput(output, bundleDir, redactor)
+		}
+		if err != nil {
+			log.FinishSpinnerWithError()
+			bundle.CollectorErrors = append(bundle.CollectorErrors, fmt.Sprintf("%s: %v", collector.GetDisplayName(), err))
+			continue
+		}
+		log.FinishChildSpinner()
+	}
+
+	if err := archiveBundleDir(bundleDir, bundle.Path); err != nil {
+		return nil, errors.Wrap(err, "failed to create archive")
+	}
+
+	return &bundle, nil
+}
+
+func installMaskKey(clientConfig *rest.Config, namespace string) ([]byte, error) {
+	clientset, err := kubernetes.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+	return redact.EnsureMaskKey(clientset, namespace)
+}
+
+// collectList returns the collectors of all of the specs. The cluster info and resources are
+// always collected, once.
+func collectList(collectors []*troubleshootv1beta1.Collector) []*troubleshootv1beta1.Collect {
+	list := []*troubleshootv1beta1.Collect{
+		{ClusterInfo: &troubleshootv1beta1.ClusterInfo{}},
+		{ClusterResources: &troubleshootv1beta1.ClusterResources{}},
+	}
+	for _, collector := range collectors {
+		for _, c := range collector.Spec.Collectors {
+			if c.ClusterInfo != nil || c.ClusterResources != nil {
+				continue
+			}
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+func writeVersionFile(bundleDir string) error {
+	version := troubleshootv1beta1.SupportBundleVersion{
+		ApiVersion: "troubleshoot.replicated.com/v1beta1",
+		Kind:       "SupportBundle",
+	}
+	b, err := yaml.Marshal(version)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal version")
+	}
+	return ioutil.WriteFile(filepath.Join(bundleDir, versionFilename), b, 0644)
+}
+
+// writeCollectorOutput writes the files in the output of a collector to the bundle directory. The
+// output is a json object of file names to base64 encoded contents, or to objects of more files in
+// a directory. The contents are redacted before they're written.
+func writeCollectorOutput(output []byte, bundleDir string, redactor *redact.Redactor) error {
+	input := map[string]interface{}{}
+	if err := json.Unmarshal(output, &input); err != nil {
+		return errors.Wrap(err, "failed to unmarshal output")
+	}
+
+	files := map[string]string{}
+	for name, contents := range input {
+		switch c := contents.(type) {
+		case string:
+			files[name] = c
+		case map[string]interface{}:
+			for childName, childContents := range c {
+				s, _ := childContents.(string)
+				files[filepath.Join(name, childName)] = s
+			}
+		}
+	}
+
+	for name, encoded := range files {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decode %s", name)
+		}
+
+		filename := filepath.Join(bundleDir, filepath.Clean("/"+name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return errors.Wrapf(err, "failed to create dir for %s", name)
+		}
+		if err := ioutil.WriteFile(filename, redactor.Redact(decoded), 0644); err != nil {
+			return errors.Wrapf(err, "failed to write %s", name)
+		}
+	}
+
+	return nil
+}
+
+func archiveBundleDir(bundleDir string, archivePath string) error {
+	tarGz := archiver.TarGz{
+		Tar: &archiver.Tar{
+			ImplicitTopLevelFolder: false,
+		},
+	}
+
+	// the version file is first so that the bundle can be recognized without extracting it
+	paths := []string{
+		filepath.Join(bundleDir, versionFilename),
+	}
+	entries, err := ioutil.ReadDir(bundleDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read bundle dir")
+	}
+	for _, entry := range entries {
+		if entry.Name() == versionFilename {
+			continue
+		}
+		paths = append(paths, filepath.Join(bundleDir, entry.Name()))
+	}
+
+	if err := tarGz.Archive(paths, archivePath); err != nil {
+		return errors.Wrap(err, "failed to archive")
+	}
+	return nil
+}
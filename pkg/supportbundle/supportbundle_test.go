@@ -0,0 +1,138 @@
+package supportbundle
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mholt/archiver"
+	"github.com/replicatedhq/kots/pkg/redact"
+	troubleshootv1beta1 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCollector = `apiVersion: troubleshoot.replicated.com/v1beta1
+kind: Collector
+metadata:
+  name: my-app
+spec:
+  collectors:
+  - clusterInfo: {}
+  - logs:
+      collectorName: web
+      selector:
+      - app=web
+  afterCollection:
+  - uploadResultsTo:
+      uri: https://vendor.example.com/bundles
+      method: POST
+`
+
+func Test_FindCollectors(t *testing.T) {
+	req := require.New(t)
+
+	appDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(appDir)
+
+	req.NoError(os.MkdirAll(filepath.Join(appDir, "upstream"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "support-bundle.yaml"), []byte(testCollector), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "deployment.yaml"), []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"), 0644))
+
+	collectors, err := FindCollectors(appDir)
+	req.NoError(err)
+	req.Len(collectors, 1)
+	assert.Equal(t, "my-app", collectors[0].Name)
+
+	// the cluster info and resources are collected once
+	list := collectList(collectors)
+	req.Len(list, 3)
+	assert.NotNil(t, list[0].ClusterInfo)
+	assert.NotNil(t, list[1].ClusterResources)
+	assert.Equal(t, "web", list[2].Logs.CollectorName)
+
+	assert.Equal(t, &UploadOptions{URI: "https://vendor.example.com/bundles", Method: "POST"}, UploadOptionsFromSpecs(collectors))
+	assert.Nil(t, UploadOptionsFromSpecs([]*troubleshootv1beta1.Collector{}))
+}
+
+func Test_writeBundle(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	bundleDir := filepath.Join(tempDir, "bundle")
+	req.NoError(os.MkdirAll(bundleDir, 0755))
+	req.NoError(writeVersionFile(bundleDir))
+
+	redactor, err := redact.NewRedactor(redact.Options{})
+	req.NoError(err)
+
+	// web-1.log is "hello", web-2.log is "password=hunter2"
+	output := []byte(`{"cluster-info/cluster_version.json":"eyJzdHJpbmciOiJ2MS4xNC4xIn0=","web/logs":{"web-1.log":"aGVsbG8=","web-2.log":"cGFzc3dvcmQ9aHVudGVyMg=="},"../escape.txt":"aGVsbG8="}`)
+	req.NoError(writeCollectorOutput(output, bundleDir, redactor))
+
+	archivePath := filepath.Join(tempDir, "support-bundle.tar.gz")
+	req.NoError(archiveBundleDir(bundleDir, archivePath))
+
+	extractDir := filepath.Join(tempDir, "extracted")
+	req.NoError(archiver.NewTarGz().Unarchive(archivePath, extractDir))
+
+	version, err := ioutil.ReadFile(filepath.Join(extractDir, versionFilename))
+	req.NoError(err)
+	assert.Contains(t, string(version), "kind: SupportBundle")
+
+	clusterVersion, err := ioutil.ReadFile(filepath.Join(extractDir, "cluster-info", "cluster_version.json"))
+	req.NoError(err)
+	assert.Equal(t, `{"string":"v1.14.1"}`, string(clusterVersion))
+
+	logs, err := ioutil.ReadFile(filepath.Join(extractDir, "web", "logs", "web-1.log"))
+	req.NoError(err)
+	assert.Equal(t, "hello", string(logs))
+
+	logs, err = ioutil.ReadFile(filepath.Join(extractDir, "web", "logs", "web-2.log"))
+	req.NoError(err)
+	assert.Equal(t, "password="+redactor.Mask("hunter2"), string(logs))
+
+	// file names can't be outside of the bundle
+	_, err = os.Stat(filepath.Join(tempDir, "escape.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_Upload(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	bundlePath := filepath.Join(tempDir, "support-bundle.tar.gz")
+	req.NoError(ioutil.WriteFile(bundlePath, []byte("bundle"), 0644))
+
+	var method, contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	req.NoError(Upload(bundlePath, UploadOptions{URI: server.URL + "/bundles"}))
+	assert.Equal(t, "PUT", method)
+	assert.Equal(t, "application/tar+gzip", contentType)
+	assert.Equal(t, "bundle", string(body))
+
+	req.NoError(Upload(bundlePath, UploadOptions{URI: server.URL + "/bundles", Method: "POST"}))
+	assert.Equal(t, "POST", method)
+
+	assert.Error(t, Upload(bundlePath, UploadOptions{URI: server.URL + "/fail"}))
+}
@@ -0,0 +1,72 @@
+package supportbundle
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	troubleshootv1beta1 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta1"
+)
+
+type UploadOptions struct {
+	URI string
+	// Method defaults to PUT
+	Method string
+}
+
+// UploadOptionsFromSpecs returns where the specs ask for the bundle to be uploaded to, or nil if
+// none of them do
+func UploadOptionsFromSpecs(collectors []*troubleshootv1beta1.Collector) *UploadOptions {
+	for _, collector := range collectors {
+		for _, afterCollection := range collector.Spec.AfterCollection {
+			if afterCollection.UploadResultsTo == nil || afterCollection.UploadResultsTo.URI == "" {
+				continue
+			}
+			return &UploadOptions{
+				URI:    afterCollection.UploadResultsTo.URI,
+				Method: afterCollection.UploadResultsTo.Method,
+			}
+		}
+	}
+	return nil
+}
+
+// Upload sends a support bundle to a vendor endpoint, e.g. a presigned url
+func Upload(bundlePath string, uploadOptions UploadOptions) error {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open bundle")
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat bundle")
+	}
+
+	method := uploadOptions.Method
+	if method == "" {
+		method = "PUT"
+	}
+
+	req, err := http.NewRequest(method, uploadOptions.URI, f)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.ContentLength = fi.Size()
+	req.Header.Set("Content-Type", "application/tar+gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to upload bundle")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("unexpected status code: %d: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package template
+
+import (
+	"text/template"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+)
+
+// AppCtx gives templates the metadata of the installed app. The installation has the version,
+// and the license has the app and the channel it was installed from. The functions return empty
+// values when either is missing.
+type AppCtx struct {
+	Installation *kotsv1beta1.Installation
+	License      *kotsv1beta1.License
+}
+
+// FuncMap represents the available functions in the AppCtx.
+func (ctx AppCtx) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"AppVersionLabel": ctx.appVersionLabel,
+		"AppChannel":      ctx.appChannel,
+		"AppSlug":         ctx.appSlug,
+	}
+}
+
+func (ctx AppCtx) appVersionLabel() string {
+	if ctx.Installation == nil {
+		return ""
+	}
+	return ctx.Installation.Spec.VersionLabel
+}
+
+func (ctx AppCtx) appChannel() string {
+	if ctx.License == nil {
+		return ""
+	}
+	return ctx.License.Spec.ChannelName
+}
+
+func (ctx AppCtx) appSlug() string {
+	if ctx.License == nil {
+		return ""
+	}
+	return ctx.License.Spec.AppSlug
+}
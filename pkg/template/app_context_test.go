@@ -0,0 +1,57 @@
+package template
+
+import (
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppContext(t *testing.T) {
+	installation := &kotsv1beta1.Installation{
+		Spec: kotsv1beta1.InstallationSpec{
+			UpdateCursor: "12",
+			VersionLabel: "1.2.0",
+		},
+	}
+	license := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			AppSlug:     "my-app",
+			ChannelName: "Stable",
+		},
+	}
+
+	tests := []struct {
+		name         string
+		installation *kotsv1beta1.Installation
+		license      *kotsv1beta1.License
+		expected     string
+	}{
+		{
+			name:         "installed",
+			installation: installation,
+			license:      license,
+			expected:     "my-app/1.2.0 (Stable)",
+		},
+		{
+			name:     "no installation",
+			license:  license,
+			expected: "my-app/ (Stable)",
+		},
+		{
+			name:     "nothing installed",
+			expected: "/ ()",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := Builder{}
+			builder.AddCtx(AppCtx{Installation: test.installation, License: test.license})
+
+			rendered, err := builder.RenderTemplate(test.name, `{{repl AppSlug }}/{{repl AppVersionLabel }} ({{repl AppChannel }})`)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, rendered)
+		})
+	}
+}
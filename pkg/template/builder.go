@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"regexp"
 	"strconv"
+	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -13,13 +14,55 @@ var (
 	templateNotDefinedRegexp = regexp.MustCompile(`template.*not defined$`)
 )
 
+// delimiters are the delimiters of a render pass, templates are rendered with each of them in order
+type delimiters struct {
+	rdelim string
+	ldelim string
+}
+
+var renderDelimiters = []delimiters{
+	{"{{repl", "}}"},
+	{"repl{{", "}}"},
+}
+
+type templateKey struct {
+	delimiters
+	name string
+	text string
+}
+
 type Builder struct {
 	Ctx    []Ctx
 	Functs template.FuncMap
+
+	// the func map is built once and added to an empty template for each of the delimiters, which
+	// the rendered templates are associated with. Parsed templates are cached by their name and
+	// text. AddCtx resets all of these. A builder isn't safe for concurrent use.
+	funcMap   template.FuncMap
+	baseTmpls map[delimiters]*template.Template
+	tmplCache map[templateKey]*template.Template
 }
 
+// AddCtx adds the functions of a context to the builder, replacing the functions with the same
+// names of the contexts added before it. Use RegisterCtx to add functions to every builder.
 func (b *Builder) AddCtx(ctx Ctx) {
 	b.Ctx = append(b.Ctx, ctx)
+	b.resetCache()
+}
+
+func (b *Builder) resetCache() {
+	b.funcMap = nil
+	b.baseTmpls = nil
+	b.tmplCache = nil
+}
+
+// Copy returns a builder with the same contexts and funcs, and caches of its own. Copies of a
+// builder can render on different goroutines.
+func (b *Builder) Copy() *Builder {
+	return &Builder{
+		Ctx:    append([]Ctx{}, b.Ctx...),
+		Functs: b.Functs,
+	}
 }
 
 func (b *Builder) String(text string) (string, error) {
@@ -129,17 +172,24 @@ func (b *Builder) Float64(text string, defaultVal float64) (float64, error) {
 	return result, nil
 }
 
+// BuildFuncMap returns the funcs of the builder, then of its contexts, then of the registered
+// contexts. Later funcs replace earlier ones with the same name, registered contexts can't have
+// the name of a kots or sprig function.
 func (b *Builder) BuildFuncMap() template.FuncMap {
-	if b.Functs == nil {
-		b.Functs = template.FuncMap{}
+	funcMap := template.FuncMap{}
+	for name, fn := range b.Functs {
+		funcMap[name] = fn
 	}
-
-	funcMap := b.Functs
 	for _, ctx := range b.Ctx {
 		for name, fn := range ctx.FuncMap() {
 			funcMap[name] = fn
 		}
 	}
+	for _, ctx := range registeredContexts() {
+		for name, fn := range ctx.FuncMap() {
+			funcMap[name] = fn
+		}
+	}
 	return funcMap
 }
 
@@ -153,17 +203,14 @@ func (b *Builder) GetTemplate(name, text string, rdelim, ldelim string) (*templa
 }
 
 func (b *Builder) RenderTemplate(name string, text string) (string, error) {
-	delims := []struct {
-		rdelim string
-		ldelim string
-	}{
-		{"{{repl", "}}"},
-		{"repl{{", "}}"},
-	}
-
 	curText := text
-	for _, d := range delims {
-		tmpl, err := b.GetTemplate(name, curText, d.rdelim, d.ldelim)
+	for _, d := range renderDelimiters {
+		// text without the delimiter renders to itself
+		if !strings.Contains(curText, d.rdelim) {
+			continue
+		}
+
+		tmpl, err := b.getCachedTemplate(name, curText, d)
 		if err != nil {
 			return "", errors.Wrap(err, "failed to get template")
 		}
@@ -177,3 +224,47 @@ func (b *Builder) RenderTemplate(name string, text string) (string, error) {
 
 	return curText, nil
 }
+
+// getCachedTemplate returns the parsed template of the text, parsing it when it isn't in the cache.
+// Templates are associated with the base template of the delimiters so that the funcs don't have
+// to be added again. Texts that may define templates are parsed into a clone of the base instead,
+// so that their definitions aren't seen when other texts are rendered.
+func (b *Builder) getCachedTemplate(name, text string, d delimiters) (*template.Template, error) {
+	key := templateKey{delimiters: d, name: name, text: text}
+	if tmpl, ok := b.tmplCache[key]; ok {
+		return tmpl, nil
+	}
+
+	if b.funcMap == nil {
+		b.funcMap = b.BuildFuncMap()
+	}
+	if b.baseTmpls == nil {
+		b.baseTmpls = map[delimiters]*template.Template{}
+	}
+	base, ok := b.baseTmpls[d]
+	if !ok {
+		base = template.New("").Delims(d.rdelim, d.ldelim).Funcs(b.funcMap)
+		b.baseTmpls[d] = base
+	}
+
+	// templates share the funcs of the base, unless they can define templates of their own
+	set := base
+	if strings.Contains(text, "define") || strings.Contains(text, "block") {
+		clone, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		set = clone
+	}
+	tmpl, err := set.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.tmplCache == nil {
+		b.tmplCache = map[templateKey]*template.Template{}
+	}
+	b.tmplCache[key] = tmpl
+
+	return tmpl, nil
+}
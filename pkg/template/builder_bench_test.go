@@ -0,0 +1,87 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchmarkManifest is a deployment like the ones in apps with a lot of templated manifests, it has
+// templates with both of the delimiters
+const benchmarkManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web-%d
+  labels:
+    app: repl{{ ConfigOption "option_1" | ToLower | replace " " "-" }}
+spec:
+  replicas: {{repl ParseInt "3" }}
+  template:
+    spec:
+      containers:
+      - name: web
+        image: registry.example.com/web:{{repl ConfigOption "option_2" | ToLower | replace " " "-" }}
+        env:
+        - name: OPTION
+          value: '{{repl ConfigOption "option_1" }}'
+`
+
+func benchmarkBuilder() Builder {
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+	builder.AddCtx(testContext{})
+	return builder
+}
+
+func benchmarkManifests(n int) []string {
+	manifests := make([]string, n)
+	for i := range manifests {
+		manifests[i] = fmt.Sprintf(benchmarkManifest, i)
+	}
+	return manifests
+}
+
+func benchmarkRender(b *testing.B, manifests []string) {
+	for i := 0; i < b.N; i++ {
+		builder := benchmarkBuilder()
+		for j, manifest := range manifests {
+			if _, err := builder.RenderTemplate(fmt.Sprintf("manifest-%d.yaml", j), manifest); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkRenderTemplate_1(b *testing.B) {
+	benchmarkRender(b, benchmarkManifests(1))
+}
+
+func BenchmarkRenderTemplate_100(b *testing.B) {
+	benchmarkRender(b, benchmarkManifests(100))
+}
+
+func BenchmarkRenderTemplate_500(b *testing.B) {
+	benchmarkRender(b, benchmarkManifests(500))
+}
+
+// most of the manifests of an app aren't templated
+func BenchmarkRenderTemplate_500_Untemplated(b *testing.B) {
+	manifests := benchmarkManifests(500)
+	for i := range manifests {
+		if i%5 != 0 {
+			manifests[i] = strings.NewReplacer("repl{{", "", "{{repl", "", "}}", "").Replace(manifests[i])
+		}
+	}
+	benchmarkRender(b, manifests)
+}
+
+func BenchmarkConfigItems(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		builder := benchmarkBuilder()
+		for j := 0; j < 200; j++ {
+			if _, err := builder.Bool(`{{repl eq (ConfigOption "option_1") "Option 1" }}`, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
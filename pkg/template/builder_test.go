@@ -227,3 +227,46 @@ func TestBuildStrings(t *testing.T) {
 		require.New(t).Equal("", built)
 	})
 }
+
+func TestRenderTemplateCache(t *testing.T) {
+	req := require.New(t)
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	// templates defined in one file aren't seen by the others
+	rendered, err := builder.RenderTemplate("a.yaml", `{{repl define "name"}}a{{repl end}}{{repl template "name"}}`)
+	req.NoError(err)
+	req.Equal("a", rendered)
+
+	rendered, err = builder.RenderTemplate("b.yaml", `{{repl define "name"}}b{{repl end}}{{repl template "name"}}`)
+	req.NoError(err)
+	req.Equal("b", rendered)
+
+	rendered, err = builder.RenderTemplate("a.yaml", `{{repl define "name"}}a{{repl end}}{{repl template "name"}}`)
+	req.NoError(err)
+	req.Equal("a", rendered)
+
+	// files with the same name and different text are parsed again
+	rendered, err = builder.RenderTemplate("c.yaml", `{{repl ToUpper "c"}}`)
+	req.NoError(err)
+	req.Equal("C", rendered)
+
+	rendered, err = builder.RenderTemplate("c.yaml", `repl{{ ToLower "C"}}`)
+	req.NoError(err)
+	req.Equal("c", rendered)
+
+	// functions of contexts that are added later are used
+	_, err = builder.RenderTemplate("d.yaml", `{{repl ConfigOption "option_1"}}`)
+	req.Error(err)
+
+	builder.AddCtx(testContext{})
+	rendered, err = builder.RenderTemplate("d.yaml", `{{repl ConfigOption "option_1"}}`)
+	req.NoError(err)
+	req.Equal("Option 1", rendered)
+
+	// text without templates is unchanged
+	rendered, err = builder.RenderTemplate("e.yaml", "a: '{{ .Values.a }}'")
+	req.NoError(err)
+	req.Equal("a: '{{ .Values.a }}'", rendered)
+}
@@ -3,6 +3,7 @@ package template
 import (
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -10,45 +11,133 @@ import (
 	"github.com/replicatedhq/kots/pkg/crypto"
 )
 
+// NewConfigContext resolves the value and default of each config item. Items whose templates read other
+// items with the config functions are rendered after them, so a default can be built from other items.
+// Items whose when template is false are treated as not set by the config functions.
 func (b *Builder) NewConfigContext(configGroups []kotsv1beta1.ConfigGroup, templateContext map[string]ItemValue, cipher *crypto.AESCipher) (*ConfigCtx, error) {
 	configCtx := &ConfigCtx{
 		ItemValues: templateContext,
+		excluded:   map[string]bool{},
 	}
 
+	items, err := configItemsInDependencyOrder(configGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	// the items render with the contexts of the builder and the items resolved before them
+	itemBuilder := Builder{
+		Ctx: append(append([]Ctx{}, b.Ctx...), *configCtx),
+	}
+
+	for _, configItem := range items {
+		// if the pending value is different from the built, then use the pending every time
+		// We have to ignore errors here because contexts that are added to the builder later,
+		// such as the license, aren't available when the config is resolved.
+
+		var itemValue ItemValue
+		if v, ok := templateContext[configItem.Name]; ok {
+			itemValue = ItemValue{
+				Value:   v.Value,
+				Default: v.Default,
+			}
+		} else {
+			builtDefault, _ := itemBuilder.String(configItem.Default)
+			builtValue, _ := itemBuilder.String(configItem.Value)
+			itemValue = ItemValue{
+				Value:   builtValue,
+				Default: builtDefault,
+			}
+		}
+
+		if configItem.Type == "password" && itemValue.HasValue() {
+			// FIXME: this temporarily ignores errors and falls back on old behavior
+			val, err := decrypt(itemValue.ValueStr(), cipher)
+			if err == nil {
+				itemValue.Value = val
+			}
+		}
+		configCtx.ItemValues[configItem.Name] = itemValue
+
+		if configItem.When != "" {
+			when, _ := itemBuilder.Bool(configItem.When, true)
+			if !when {
+				configCtx.excluded[configItem.Name] = true
+			}
+		}
+	}
+
+	return configCtx, nil
+}
+
+// configItemsInDependencyOrder returns the items so that each one comes after the items that its
+// default, value and when templates read, and otherwise in the order they are defined
+func configItemsInDependencyOrder(configGroups []kotsv1beta1.ConfigGroup) ([]kotsv1beta1.ConfigItem, error) {
+	items := []kotsv1beta1.ConfigItem{}
+	indexes := map[string]int{}
 	for _, configGroup := range configGroups {
 		for _, configItem := range configGroup.Items {
-			// if the pending value is different from the built, then use the pending every time
-			// We have to ignore errors here because we only have the static context loaded
-			// for rendering. some items have templates that need the config context,
-			// so we can ignore these.
-
-			var itemValue ItemValue
-			if v, ok := templateContext[configItem.Name]; ok {
-				itemValue = ItemValue{
-					Value:   v.Value,
-					Default: v.Default,
+			indexes[configItem.Name] = len(items)
+			items = append(items, configItem)
+		}
+	}
+
+	configFunctions := ConfigCtx{}.FuncMap()
+	dependencies := make([]map[int]bool, len(items))
+	for i, item := range items {
+		dependencies[i] = map[int]bool{}
+		for _, text := range []string{item.Default, item.Value, item.When} {
+			calls, err := findTemplateCalls(item.Name, text)
+			if err != nil {
+				// the item will fail to render, it doesn't need to be ordered
+				continue
+			}
+			for _, call := range calls {
+				if _, ok := configFunctions[call.function]; !ok {
+					continue
+				}
+				if j, ok := indexes[call.firstArg]; ok && j != i {
+					dependencies[i][j] = true
 				}
-			} else {
-				builtDefault, _ := b.String(configItem.Default)
-				builtValue, _ := b.String(configItem.Value)
-				itemValue = ItemValue{
-					Value:   builtValue,
-					Default: builtDefault,
+			}
+		}
+	}
+
+	ordered := []kotsv1beta1.ConfigItem{}
+	done := make([]bool, len(items))
+	for len(ordered) < len(items) {
+		progress := false
+		for i, item := range items {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for j := range dependencies[i] {
+				if !done[j] {
+					ready = false
+					break
 				}
 			}
+			if !ready {
+				continue
+			}
+			ordered = append(ordered, item)
+			done[i] = true
+			progress = true
+		}
 
-			if configItem.Type == "password" && itemValue.HasValue() {
-				// FIXME: this temporarily ignores errors and falls back on old behavior
-				val, err := decrypt(itemValue.ValueStr(), cipher)
-				if err == nil {
-					itemValue.Value = val
+		if !progress {
+			cycle := []string{}
+			for i, item := range items {
+				if !done[i] {
+					cycle = append(cycle, item.Name)
 				}
 			}
-			configCtx.ItemValues[configItem.Name] = itemValue
+			return nil, errors.Errorf("config items %s depend on each other", strings.Join(cycle, ", "))
 		}
 	}
 
-	return configCtx, nil
+	return ordered, nil
 }
 
 // ConfigCtx is the context for builder functions before the application has started.
@@ -87,6 +176,9 @@ func (i ItemValue) DefaultStr() string {
 
 type ConfigCtx struct {
 	ItemValues map[string]ItemValue
+
+	// excluded are the items whose when template is false
+	excluded map[string]bool
 }
 
 // FuncMap represents the available functions in the ConfigCtx.
@@ -150,6 +242,10 @@ func (ctx ConfigCtx) getConfigOptionValue(itemName string) (string, error) {
 		return "", errors.New("unable to find config item")
 	}
 
+	if ctx.excluded[itemName] {
+		return "", nil
+	}
+
 	if val.HasValue() {
 		return val.ValueStr(), nil
 	}
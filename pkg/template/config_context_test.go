@@ -0,0 +1,105 @@
+package template
+
+import (
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigContext_dependencies(t *testing.T) {
+	groups := []kotsv1beta1.ConfigGroup{
+		{
+			Name: "database",
+			Items: []kotsv1beta1.ConfigItem{
+				{
+					Name:    "db_url",
+					Type:    "text",
+					Default: `repl{{ printf "postgres://%s:%s" (ConfigOption "db_host") (ConfigOption "db_port") }}`,
+				},
+				{
+					Name:    "db_host",
+					Type:    "text",
+					Default: `{{repl if ConfigOptionEquals "db_type" "embedded" }}postgres{{repl else }}{{repl ConfigOption "external_host" }}{{repl end }}`,
+				},
+				{
+					Name:    "db_type",
+					Type:    "select_one",
+					Default: "external",
+				},
+				{
+					Name:    "db_port",
+					Type:    "text",
+					Default: "5432",
+				},
+				{
+					Name:    "external_host",
+					Type:    "text",
+					Default: "db.example.com",
+					When:    `repl{{ ConfigOptionNotEquals "db_type" "embedded" }}`,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]ItemValue
+		expected map[string]string
+	}{
+		{
+			name:   "defaults",
+			values: map[string]ItemValue{},
+			expected: map[string]string{
+				"db_url":        "postgres://db.example.com:5432",
+				"db_host":       "db.example.com",
+				"external_host": "db.example.com",
+			},
+		},
+		{
+			name: "items that aren't shown are empty",
+			values: map[string]ItemValue{
+				"db_type": {Value: "embedded"},
+			},
+			expected: map[string]string{
+				"db_url":        "postgres://postgres:5432",
+				"db_host":       "postgres",
+				"external_host": "",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			builder := Builder{}
+			builder.AddCtx(StaticCtx{})
+
+			configCtx, err := builder.NewConfigContext(groups, test.values, nil)
+			req.NoError(err)
+
+			for name, expected := range test.expected {
+				assert.Equal(t, expected, configCtx.configOption(name), name)
+			}
+		})
+	}
+}
+
+func TestConfigContext_cycle(t *testing.T) {
+	groups := []kotsv1beta1.ConfigGroup{
+		{
+			Name: "cycle",
+			Items: []kotsv1beta1.ConfigItem{
+				{Name: "a", Default: `repl{{ ConfigOption "b" }}`},
+				{Name: "b", Default: `repl{{ ConfigOption "a" }}`},
+				{Name: "c", Default: "c"},
+			},
+		},
+	}
+
+	builder := Builder{}
+	_, err := builder.NewConfigContext(groups, map[string]ItemValue{}, nil)
+	assert.EqualError(t, err, "config items a, b depend on each other")
+}
@@ -0,0 +1,76 @@
+package template
+
+import (
+	"context"
+	"net"
+	"text/template"
+	"time"
+)
+
+const defaultDNSTimeout = 5 * time.Second
+
+// DNSResolver is the subset of net.Resolver used by the DNSCtx
+type DNSResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DNSCtx provides functions that resolve names using the DNS of the host running kots.
+// These make network requests and are meant to be added only when validating
+// config values, the StaticCtx provides versions that never resolve anything.
+type DNSCtx struct {
+	Resolver DNSResolver
+	Timeout  time.Duration
+}
+
+// FuncMap represents the available functions in the DNSCtx.
+func (ctx DNSCtx) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"DnsLookup": ctx.dnsLookup,
+		"DnsTxt":    ctx.dnsTxt,
+	}
+}
+
+// dnsLookup returns the addresses that hostname resolves to, or an empty list if
+// it does not resolve. Errors are not returned so that templates can check for
+// names that don't exist yet.
+func (ctx DNSCtx) dnsLookup(hostname string) []string {
+	c, cancel := context.WithTimeout(context.Background(), ctx.timeout())
+	defer cancel()
+
+	addrs, err := ctx.resolver().LookupHost(c, hostname)
+	if err != nil {
+		return []string{}
+	}
+	return addrs
+}
+
+func (ctx DNSCtx) dnsTxt(name string) []string {
+	c, cancel := context.WithTimeout(context.Background(), ctx.timeout())
+	defer cancel()
+
+	records, err := ctx.resolver().LookupTXT(c, name)
+	if err != nil {
+		return []string{}
+	}
+	return records
+}
+
+func (ctx DNSCtx) resolver() DNSResolver {
+	if ctx.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return ctx.Resolver
+}
+
+func (ctx DNSCtx) timeout() time.Duration {
+	if ctx.Timeout == 0 {
+		return defaultDNSTimeout
+	}
+	return ctx.Timeout
+}
+
+// noDNSLookup is used when DNS lookups are not enabled
+func noDNSLookup(name string) []string {
+	return []string{}
+}
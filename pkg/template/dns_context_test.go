@@ -0,0 +1,90 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	hosts map[string][]string
+	txt   map[string][]string
+}
+
+func (r fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, errors.Errorf("no such host %s", host)
+	}
+	return addrs, nil
+}
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := r.txt[name]
+	if !ok {
+		return nil, errors.Errorf("no such host %s", name)
+	}
+	return records, nil
+}
+
+func TestDNSContext(t *testing.T) {
+	resolver := fakeResolver{
+		hosts: map[string][]string{
+			"app.example.com": {"10.0.0.1", "10.0.0.2"},
+		},
+		txt: map[string][]string{
+			"_verify.example.com": {"token=abc"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		template  string
+		enableDNS bool
+		expect    string
+	}{
+		{
+			name:      "lookup resolves",
+			template:  `{{repl has "10.0.0.2" (DnsLookup "app.example.com") }}`,
+			enableDNS: true,
+			expect:    "true",
+		},
+		{
+			name:      "lookup does not resolve",
+			template:  `{{repl DnsLookup "missing.example.com" | len }}`,
+			enableDNS: true,
+			expect:    "0",
+		},
+		{
+			name:      "txt record",
+			template:  `{{repl DnsTxt "_verify.example.com" | join "," }}`,
+			enableDNS: true,
+			expect:    "token=abc",
+		},
+		{
+			name:      "lookup is not enabled",
+			template:  `{{repl DnsLookup "app.example.com" | len }}`,
+			enableDNS: false,
+			expect:    "0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			builder := Builder{}
+			builder.AddCtx(StaticCtx{})
+			if test.enableDNS {
+				builder.AddCtx(DNSCtx{Resolver: resolver})
+			}
+
+			actual, err := builder.String(test.template)
+			req.NoError(err)
+			assert.Equal(t, test.expect, actual)
+		})
+	}
+}
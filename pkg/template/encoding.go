@@ -0,0 +1,100 @@
+package template
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// toYaml marshals a value to yaml without the trailing newline. With an indent, the result starts
+// on a new line and every line is indented, so that it can follow a key or a block scalar
+// indicator in a manifest, e.g. `config.yaml: |{{repl ToYaml $config 4 }}`.
+func (ctx StaticCtx) toYaml(value interface{}, indent ...int) (string, error) {
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return "", errors.Wrap(err, "ToYaml failed to marshal value")
+	}
+
+	return indentLines(strings.TrimSuffix(string(b), "\n"), indent...), nil
+}
+
+// fromYaml unmarshals a yaml document, mappings become maps with string keys and numbers become
+// floats like they do in json
+func (ctx StaticCtx) fromYaml(s string) (interface{}, error) {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(s), &value); err != nil {
+		return nil, errors.Wrap(err, "FromYaml failed to unmarshal")
+	}
+	return value, nil
+}
+
+// toJson marshals a value to json on a single line, or indented by two spaces per level with an
+// indent like ToYaml
+func (ctx StaticCtx) toJson(value interface{}, indent ...int) (string, error) {
+	if len(indent) == 0 {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", errors.Wrap(err, "ToJson failed to marshal value")
+		}
+		return string(b), nil
+	}
+
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "ToJson failed to marshal value")
+	}
+	return indentLines(string(b), indent...), nil
+}
+
+func (ctx StaticCtx) fromJson(s string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil, errors.Wrap(err, "FromJson failed to unmarshal")
+	}
+	return value, nil
+}
+
+// mergeYaml merges yaml documents into the first one and returns the result as yaml. Mappings
+// are merged recursively, other values of later documents replace the earlier ones, including
+// lists. Empty documents are skipped.
+func (ctx StaticCtx) mergeYaml(docs ...string) (string, error) {
+	merged := map[string]interface{}{}
+	for i, doc := range docs {
+		value := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(doc), &value); err != nil {
+			return "", errors.Wrapf(err, "MergeYaml failed to unmarshal document %d", i+1)
+		}
+		mergeMaps(merged, value)
+	}
+
+	b, err := yaml.Marshal(merged)
+	if err != nil {
+		return "", errors.Wrap(err, "MergeYaml failed to marshal result")
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+func mergeMaps(dst map[string]interface{}, src map[string]interface{}) {
+	for key, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = srcValue
+	}
+}
+
+// indentLines starts s on a new line and indents every line by indent spaces, s is returned as it
+// is without an indent
+func indentLines(s string, indent ...int) string {
+	if len(indent) == 0 || indent[0] <= 0 {
+		return s
+	}
+
+	pad := strings.Repeat(" ", indent[0])
+	return "\n" + pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
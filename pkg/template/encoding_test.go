@@ -0,0 +1,84 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticContext_encoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "to yaml",
+			template: `{{repl ToYaml (dict "port" 8080 "hosts" (list "a" "b")) }}`,
+			expected: "hosts:\n- a\n- b\nport: 8080",
+		},
+		{
+			name:     "to yaml indented in a block scalar",
+			template: "data:\n  config.yaml: |{{repl ToYaml (dict \"server\" (dict \"port\" 8080)) 4 }}",
+			expected: "data:\n  config.yaml: |\n    server:\n      port: 8080",
+		},
+		{
+			name:     "from yaml",
+			template: `{{repl $c := FromYaml "server:\n  port: 8080\n" }}{{repl $c.server.port }}`,
+			expected: "8080",
+		},
+		{
+			name:        "from invalid yaml",
+			template:    `{{repl FromYaml "a: [" }}`,
+			expectError: true,
+		},
+		{
+			name:     "to json",
+			template: `{{repl ToJson (dict "enabled" true "name" "db") }}`,
+			expected: `{"enabled":true,"name":"db"}`,
+		},
+		{
+			name:     "to json indented",
+			template: "config.json: |{{repl ToJson (dict \"name\" \"db\") 2 }}",
+			expected: "config.json: |\n  {\n    \"name\": \"db\"\n  }",
+		},
+		{
+			name:     "from json",
+			template: `{{repl (FromJson "{\"replicas\": 3}").replicas }}`,
+			expected: "3",
+		},
+		{
+			name:     "round trip",
+			template: `{{repl FromJson "{\"a\":{\"b\":[1,2]}}" | ToYaml }}`,
+			expected: "a:\n  b:\n  - 1\n  - 2",
+		},
+		{
+			name:     "merge yaml",
+			template: `{{repl MergeYaml "server:\n  port: 80\n  hosts: [a, b]\nlog: info\n" "server:\n  port: 8080\n  hosts: [c]\n" "" }}`,
+			expected: "log: info\nserver:\n  hosts:\n  - c\n  port: 8080",
+		},
+		{
+			name:        "merge invalid yaml",
+			template:    `{{repl MergeYaml "a: 1" "- b" }}`,
+			expectError: true,
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := builder.String(test.template)
+			if test.expectError {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			req.Equal(test.expected, actual)
+		})
+	}
+}
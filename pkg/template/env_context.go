@@ -0,0 +1,54 @@
+package template
+
+import (
+	"os"
+	"strings"
+	"text/template"
+)
+
+// EnvCtx provides access to environment variables of the process rendering the application.
+// Only variables that start with one of the allowed prefixes can be read, so that operators
+// choose what automation can inject. The StaticCtx provides a version that never reads anything.
+type EnvCtx struct {
+	AllowedPrefixes []string
+
+	// LookupEnv defaults to os.LookupEnv
+	LookupEnv func(key string) (string, bool)
+}
+
+// FuncMap represents the available functions in the EnvCtx.
+func (ctx EnvCtx) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"GetEnv": ctx.getEnv,
+	}
+}
+
+// getEnv returns the value of the environment variable, or an empty string
+// if it is not set or not allowed
+func (ctx EnvCtx) getEnv(name string) string {
+	if !ctx.isAllowed(name) {
+		return ""
+	}
+
+	lookupEnv := ctx.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	value, _ := lookupEnv(name)
+	return value
+}
+
+func (ctx EnvCtx) isAllowed(name string) bool {
+	for _, prefix := range ctx.AllowedPrefixes {
+		if prefix != "" && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// noGetEnv is used when no environment variables are allowed
+func noGetEnv(name string) string {
+	return ""
+}
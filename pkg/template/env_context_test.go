@@ -0,0 +1,68 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvCtx(t *testing.T) {
+	env := map[string]string{
+		"KOTS_APP_HOSTNAME": "app.example.com",
+		"AWS_SECRET_KEY":    "secret",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	tests := []struct {
+		name     string
+		prefixes []string
+		tpl      string
+		expected string
+	}{
+		{
+			name:     "allowed variable",
+			prefixes: []string{"KOTS_APP_"},
+			tpl:      `{{repl GetEnv "KOTS_APP_HOSTNAME"}}`,
+			expected: "app.example.com",
+		},
+		{
+			name:     "variable without an allowed prefix",
+			prefixes: []string{"KOTS_APP_"},
+			tpl:      `{{repl GetEnv "AWS_SECRET_KEY"}}`,
+			expected: "",
+		},
+		{
+			name:     "allowed variable that is not set",
+			prefixes: []string{"KOTS_APP_"},
+			tpl:      `{{repl GetEnv "KOTS_APP_PORT"}}`,
+			expected: "",
+		},
+		{
+			name:     "empty prefix allows nothing",
+			prefixes: []string{""},
+			tpl:      `{{repl GetEnv "AWS_SECRET_KEY"}}`,
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := Builder{}
+			builder.AddCtx(StaticCtx{})
+			builder.AddCtx(EnvCtx{AllowedPrefixes: test.prefixes, LookupEnv: lookupEnv})
+
+			actual, err := builder.String(test.tpl)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+
+	static := Builder{}
+	static.AddCtx(StaticCtx{})
+	actual, err := static.String(`{{repl GetEnv "KOTS_APP_HOSTNAME"}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "", actual)
+}
@@ -0,0 +1,19 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// stableHash returns the first length characters of the hex encoded sha256 of input, or all 64 if
+// length is out of range. The result is the same on every render and only contains lowercase letters
+// and digits, so it can be used in resource names where RandomString would change on each render.
+func (ctx StaticCtx) stableHash(input string, length int) string {
+	sum := sha256.Sum256([]byte(input))
+	hash := hex.EncodeToString(sum[:])
+
+	if length <= 0 || length > len(hash) {
+		return hash
+	}
+	return hash[:length]
+}
@@ -0,0 +1,49 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticContext_stableHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "short",
+			template: `{{repl StableHash "tenant-a" 8 }}`,
+			expected: "80a707af",
+		},
+		{
+			name:     "in a name",
+			template: `db-{{repl StableHash "tenant-a" 6 }}`,
+			expected: "db-80a707",
+		},
+		{
+			name:     "full length",
+			template: `{{repl StableHash "tenant-a" 0 }}`,
+			expected: "80a707af7dc77ee1228f9127180f3964835e5beb4c4ab0d812f0fe7593579b3a",
+		},
+		{
+			name:     "longer than the hash",
+			template: `{{repl StableHash "tenant-a" 100 }}`,
+			expected: "80a707af7dc77ee1228f9127180f3964835e5beb4c4ab0d812f0fe7593579b3a",
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := builder.String(test.template)
+			req.NoError(err)
+			req.Equal(test.expected, actual)
+		})
+	}
+}
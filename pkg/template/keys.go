@@ -0,0 +1,178 @@
+package template
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultRSAKeyBits = 4096
+
+// genPrivateKey returns a new PEM encoded "rsa" or "ed25519" private key. A new key is generated
+// on every render, so it should be the value or default of a config item: the first key of an item
+// is saved with its config values and used from then on, see GeneratesKey. A key generated in a
+// manifest is different on every pull.
+func (ctx StaticCtx) genPrivateKey(keyType string, bits ...int) (string, error) {
+	switch strings.ToLower(keyType) {
+	case "rsa":
+		size := defaultRSAKeyBits
+		if len(bits) > 0 {
+			size = bits[0]
+		}
+		key, err := rsa.GenerateKey(rand.Reader, size)
+		if err != nil {
+			return "", errors.Wrap(err, "GenPrivateKey failed to generate rsa key")
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})), nil
+
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", errors.Wrap(err, "GenPrivateKey failed to generate ed25519 key")
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return "", errors.Wrap(err, "GenPrivateKey failed to marshal ed25519 key")
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+	}
+
+	return "", errors.Errorf("GenPrivateKey failed: unsupported key type %q", keyType)
+}
+
+// GeneratesKey is true when the template calls GenPrivateKey. Such a template renders to a new key
+// every time, so the first render of a config item's value or default is kept instead.
+func GeneratesKey(name string, text string) bool {
+	calls, err := findTemplateCalls(name, text)
+	if err != nil {
+		return false
+	}
+	for _, call := range calls {
+		if call.function == "GenPrivateKey" {
+			return true
+		}
+	}
+	return false
+}
+
+// genPublicKey returns the PEM encoded public key of a private key from GenPrivateKey. It's the
+// same on every render, so it's stable as long as the private key is.
+func (ctx StaticCtx) genPublicKey(privateKey string) (string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", errors.Wrap(err, "GenPublicKey failed to parse private key")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey(key))
+	if err != nil {
+		return "", errors.Wrap(err, "GenPublicKey failed to marshal public key")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// sshKeyPair returns the private key from GenPrivateKey in a format that ssh reads as "PrivateKey",
+// and its public key in the authorized_keys format as "PublicKey"
+func (ctx StaticCtx) sshKeyPair(privateKey string) (map[string]string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "SSHKeyPair failed to parse private key")
+	}
+
+	sshPublicKey, err := ssh.NewPublicKey(publicKey(key))
+	if err != nil {
+		return nil, errors.Wrap(err, "SSHKeyPair failed to create ssh public key")
+	}
+
+	sshPrivateKey := privateKey
+	if edKey, ok := key.(ed25519.PrivateKey); ok {
+		// ssh only reads ed25519 keys in its own format
+		sshPrivateKey = string(pem.EncodeToMemory(marshalOpenSSHEd25519Key(edKey)))
+	}
+
+	return map[string]string{
+		"PrivateKey": sshPrivateKey,
+		"PublicKey":  strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey))),
+	}, nil
+}
+
+func parsePrivateKey(privateKey string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *rsa.PrivateKey, ed25519.PrivateKey:
+			return key, nil
+		}
+		return nil, errors.Errorf("unsupported key type %T", key)
+	}
+
+	return nil, errors.Errorf("unsupported pem block type %q", block.Type)
+}
+
+func publicKey(privateKey interface{}) interface{} {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey
+	case ed25519.PrivateKey:
+		return key.Public()
+	}
+	return nil
+}
+
+// marshalOpenSSHEd25519Key encodes an unencrypted key in the openssh-key-v1 format, see
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.key
+func marshalOpenSSHEd25519Key(key ed25519.PrivateKey) *pem.Block {
+	pub := key.Public().(ed25519.PublicKey)
+	pubKey := ssh.Marshal(struct {
+		KeyType string
+		Pub     []byte
+	}{ssh.KeyAlgoED25519, pub})
+
+	// the check ints only have to match each other, they're derived from the key so that the
+	// encoding of a key is the same on every render
+	check := binary.BigEndian.Uint32(pub[:4])
+	privKeys := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		KeyType string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+	}{check, check, ssh.KeyAlgoED25519, pub, key, ""})
+
+	// the private section is padded to the cipher block size, which is 8 without a cipher
+	for i := 1; len(privKeys)%8 != 0; i++ {
+		privKeys = append(privKeys, byte(i))
+	}
+
+	body := ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, pubKey, privKeys})
+
+	return &pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte("openssh-key-v1\x00"), body...),
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"text/template"
+	"time"
 
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
 )
 
+// LicenseCtx gives templates the entitlements of the license. The functions return empty values
+// when there is no license, so that apps can be rendered without one.
 type LicenseCtx struct {
 	License *kotsv1beta1.License
 }
@@ -18,10 +22,14 @@ func (ctx LicenseCtx) FuncMap() template.FuncMap {
 	return template.FuncMap{
 		"LicenseFieldValue": ctx.licenseFieldValue,
 		"LicenseDockerCfg":  ctx.licenseDockercfg,
+		"LicenseExpiration": ctx.licenseExpiration,
 	}
 }
 
 func (ctx LicenseCtx) licenseFieldValue(name string) string {
+	if ctx.License == nil {
+		return ""
+	}
 	for key, entitlement := range ctx.License.Spec.Entitlements {
 		if key == name {
 			return fmt.Sprintf("%v", entitlement.Value.Value())
@@ -30,7 +38,27 @@ func (ctx LicenseCtx) licenseFieldValue(name string) string {
 	return ""
 }
 
+// licenseExpiration returns the time the license expires at in RFC 3339 format, or an empty string
+// if it doesn't expire
+func (ctx LicenseCtx) licenseExpiration() (string, error) {
+	if ctx.License == nil {
+		return "", nil
+	}
+
+	expiresAt, err := kotslicense.ExpiresAt(ctx.License)
+	if err != nil {
+		return "", err
+	}
+	if expiresAt.IsZero() {
+		return "", nil
+	}
+	return expiresAt.Format(time.RFC3339), nil
+}
+
 func (ctx LicenseCtx) licenseDockercfg() string {
+	if ctx.License == nil {
+		return ""
+	}
 	auth := fmt.Sprintf("%s:%s", ctx.License.Spec.LicenseID, ctx.License.Spec.LicenseID)
 	encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
 
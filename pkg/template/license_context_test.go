@@ -20,3 +20,63 @@ func TestLicenseContext_dockercfg(t *testing.T) {
 	dockercfg := ctx.licenseDockercfg()
 	assert.Equal(t, dockercfg, expect)
 }
+
+func TestLicenseContext_entitlements(t *testing.T) {
+	license := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			Entitlements: map[string]kotsv1beta1.EntitlementField{
+				"seat_count": {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.Int, IntVal: 25}},
+				"expires_at": {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.String, StrVal: "2021-01-02T03:04:05Z"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		license  *kotsv1beta1.License
+		template string
+		expected string
+	}{
+		{
+			name:     "field value",
+			license:  license,
+			template: `{{repl LicenseFieldValue "seat_count" }}`,
+			expected: "25",
+		},
+		{
+			name:     "expiration",
+			license:  license,
+			template: `{{repl LicenseExpiration }}`,
+			expected: "2021-01-02T03:04:05Z",
+		},
+		{
+			name: "no expiration",
+			license: &kotsv1beta1.License{
+				Spec: kotsv1beta1.LicenseSpec{
+					Entitlements: map[string]kotsv1beta1.EntitlementField{
+						"expires_at": {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.String}},
+					},
+				},
+			},
+			template: `{{repl LicenseExpiration }}`,
+			expected: "",
+		},
+		{
+			name:     "no license",
+			license:  nil,
+			template: `{{repl LicenseFieldValue "seat_count" }}{{repl LicenseExpiration }}`,
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := Builder{}
+			builder.AddCtx(LicenseCtx{License: test.license})
+
+			rendered, err := builder.RenderTemplate(test.name, test.template)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, rendered)
+		})
+	}
+}
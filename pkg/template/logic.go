@@ -0,0 +1,69 @@
+package template
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// truthy converts a value to a bool the way config item values are read: "1", "t" and "true"
+// are true, "0", "f", "false" and anything that isn't a bool are false. The text/template
+// and, or and not functions treat every non empty string as true, so "0" would be true.
+func (ctx StaticCtx) truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(val))
+		return b
+	}
+
+	rv := reflect.ValueOf(v)
+	switch {
+	case ctx.isInt(rv):
+		return rv.Int() != 0
+	case ctx.isUint(rv):
+		return rv.Uint() != 0
+	case ctx.isFloat(rv):
+		return rv.Float() != 0
+	}
+	return false
+}
+
+// and is true when all of the values are true
+func (ctx StaticCtx) and(values ...interface{}) bool {
+	for _, v := range values {
+		if !ctx.truthy(v) {
+			return false
+		}
+	}
+	return len(values) > 0
+}
+
+// or is true when any of the values is true
+func (ctx StaticCtx) or(values ...interface{}) bool {
+	for _, v := range values {
+		if ctx.truthy(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctx StaticCtx) not(value interface{}) bool {
+	return !ctx.truthy(value)
+}
+
+// xor is true when exactly one of the values is true, e.g. for options that can't be used
+// together
+func (ctx StaticCtx) xor(values ...interface{}) bool {
+	count := 0
+	for _, v := range values {
+		if ctx.truthy(v) {
+			count++
+		}
+	}
+	return count == 1
+}
@@ -0,0 +1,84 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticContext_logic(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "And with config values",
+			template: `{{repl And "1" "true" }}`,
+			expected: "true",
+		},
+		{
+			name:     "And with a zero",
+			template: `{{repl And "1" "0" }}`,
+			expected: "false",
+		},
+		{
+			name:     "And without values",
+			template: `{{repl And }}`,
+			expected: "false",
+		},
+		{
+			name:     "Or",
+			template: `{{repl Or "0" "false" "1" }}`,
+			expected: "true",
+		},
+		{
+			name:     "Or with empty strings",
+			template: `{{repl Or "" "0" }}`,
+			expected: "false",
+		},
+		{
+			name:     "Not",
+			template: `{{repl Not "0" }}`,
+			expected: "true",
+		},
+		{
+			name:     "Not with a bool",
+			template: `{{repl Not true }}`,
+			expected: "false",
+		},
+		{
+			name:     "Xor with one true",
+			template: `{{repl Xor "0" "1" "false" }}`,
+			expected: "true",
+		},
+		{
+			name:     "Xor with two true",
+			template: `{{repl Xor "1" "t" "0" }}`,
+			expected: "false",
+		},
+		{
+			name:     "numbers",
+			template: `{{repl And 1 (Not 0) }}`,
+			expected: "true",
+		},
+		{
+			name:     "nested",
+			template: `{{repl Or (And "1" "0") (Not (Xor "1" "1")) }}`,
+			expected: "true",
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := builder.String(test.template)
+			req.NoError(err)
+			req.Equal(test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package template
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// sortKeys returns the keys of a map in sorted order. Maps are iterated in random order,
+// so templates that build lists from them should use this to render the same way every time.
+func (ctx StaticCtx) sortKeys(m interface{}) ([]string, error) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		return nil, errors.Errorf("SortKeys expects a map, got %T", m)
+	}
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, errors.Errorf("SortKeys expects a map with string keys, got %T", m)
+	}
+
+	keys := []string{}
+	for _, key := range v.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// keys replaces the sprig function, which returns the keys in random order
+func (ctx StaticCtx) keys(dicts ...map[string]interface{}) []string {
+	keys := []string{}
+	for _, dict := range dicts {
+		for key := range dict {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// values replaces the sprig function, which returns the values in random order.
+// Values are returned in the order of their keys.
+func (ctx StaticCtx) values(dict map[string]interface{}) []interface{} {
+	values := []interface{}{}
+	for _, key := range ctx.keys(dict) {
+		values = append(values, dict[key])
+	}
+
+	return values
+}
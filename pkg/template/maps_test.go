@@ -0,0 +1,67 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticContext_orderedMaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "SortKeys",
+			template: `{{repl range SortKeys (dict "c" 3 "a" 1 "b" 2) }}{{repl . }}{{repl end }}`,
+			expected: "abc",
+		},
+		{
+			name:     "keys",
+			template: `{{repl keys (dict "c" 3 "a" 1) (dict "b" 2) | join "," }}`,
+			expected: "a,b,c",
+		},
+		{
+			name:     "values",
+			template: `{{repl values (dict "c" 3 "a" 1 "b" 2) | join "," }}`,
+			expected: "1,2,3",
+		},
+		{
+			name:     "range over a map",
+			template: `{{repl range $k, $v := dict "c" 3 "a" 1 "b" 2 }}{{repl $k }}={{repl $v }};{{repl end }}`,
+			expected: "a=1;b=2;c=3;",
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			// render repeatedly since map iteration order is random
+			for i := 0; i < 20; i++ {
+				actual, err := builder.String(test.template)
+				req.NoError(err)
+				req.Equal(test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStaticContext_sortKeys(t *testing.T) {
+	req := require.New(t)
+	ctx := StaticCtx{}
+
+	keys, err := ctx.sortKeys(map[string]string{"b": "2", "a": "1"})
+	req.NoError(err)
+	req.Equal([]string{"a", "b"}, keys)
+
+	_, err = ctx.sortKeys(map[int]string{1: "1"})
+	req.Error(err)
+
+	_, err = ctx.sortKeys([]string{"a"})
+	req.Error(err)
+}
@@ -0,0 +1,84 @@
+package template
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxNameLength is the length of a DNS-1123 label and of a label value
+	maxNameLength = 63
+	// nameHashLength is the length of the hash that TruncateWithHash appends
+	nameHashLength = 8
+)
+
+var (
+	invalidDNS1123Chars    = regexp.MustCompile(`[^a-z0-9-]+`)
+	invalidLabelValueChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	repeatedDashes         = regexp.MustCompile(`-{2,}`)
+)
+
+// toDNS1123 returns input as a DNS-1123 label, which every kind of resource can be named with. It's
+// lowercased, other characters than letters, digits and dashes are replaced with a dash, and it's
+// shortened to 63 characters with TruncateWithHash. Input without any valid characters becomes a hash
+// of it, so the name is never empty unless input is.
+func (ctx StaticCtx) toDNS1123(input string) string {
+	if input == "" {
+		return ""
+	}
+
+	name := invalidDNS1123Chars.ReplaceAllString(strings.ToLower(input), "-")
+	name = repeatedDashes.ReplaceAllString(name, "-")
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return ctx.stableHash(input, nameHashLength)
+	}
+	if len(name) <= maxNameLength {
+		return name
+	}
+	return ctx.truncateWithHash(name, maxNameLength)
+}
+
+// toLabelValue returns input as a valid label value. Other characters than letters, digits, dashes,
+// underscores and dots are replaced with a dash, it starts and ends with a letter or digit, and it's
+// shortened to 63 characters with TruncateWithHash. Label values can be empty.
+func (ctx StaticCtx) toLabelValue(input string) string {
+	value := invalidLabelValueChars.ReplaceAllString(input, "-")
+	value = strings.Trim(value, "-_.")
+	if value == "" && input != "" {
+		return ctx.stableHash(input, nameHashLength)
+	}
+	if len(value) <= maxNameLength {
+		return value
+	}
+	return ctx.truncateWithHash(value, maxNameLength)
+}
+
+// truncateWithHash returns input if it's at most length bytes, otherwise the start of input and a
+// dash and hash of all of it, length bytes in total. Names that only differ after length stay
+// different, and the result is the same on every render. The start doesn't end with a dash,
+// underscore or dot, so a valid name stays valid.
+func (ctx StaticCtx) truncateWithHash(input string, length int) string {
+	if length < 0 {
+		length = 0
+	}
+	if len(input) <= length {
+		return input
+	}
+
+	hash := ctx.stableHash(input, nameHashLength)
+	if length <= len(hash) {
+		return hash[:length]
+	}
+
+	end := length - len(hash) - 1
+	for end > 0 && !utf8.RuneStart(input[end]) {
+		end--
+	}
+	prefix := strings.TrimRight(input[:end], "-_.")
+	if prefix == "" {
+		return hash
+	}
+	return prefix + "-" + hash
+}
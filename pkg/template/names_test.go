@@ -0,0 +1,111 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestStaticContext_names(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "dns-1123 of an app name",
+			template: `{{repl ToDNS1123 "My App_Name (Staging)" }}`,
+			expected: "my-app-name-staging",
+		},
+		{
+			name:     "dns-1123 of a hostname",
+			template: `{{repl ToDNS1123 "App.Example.com." }}`,
+			expected: "app-example-com",
+		},
+		{
+			name:     "dns-1123 that is too long",
+			template: `{{repl ToDNS1123 "My Company's Super Long Application Name For The Enterprise Edition v2" }}`,
+			expected: "my-company-s-super-long-application-name-for-the-enter-fac9d8f0",
+		},
+		{
+			name:     "dns-1123 without valid characters",
+			template: `{{repl ToDNS1123 "日本" }}`,
+			expected: "cf2abf0c",
+		},
+		{
+			name:     "dns-1123 of nothing",
+			template: `{{repl ToDNS1123 "" }}`,
+			expected: "",
+		},
+		{
+			name:     "label value",
+			template: `{{repl ToLabelValue "--Release 1.2/rc_1--" }}`,
+			expected: "Release-1.2-rc_1",
+		},
+		{
+			name:     "label value that is too long",
+			template: `{{repl ToLabelValue "My Company's Super Long Application Name For The Enterprise Edition v2 (prod)" }}`,
+			expected: "My-Company-s-Super-Long-Application-Name-For-The-Enter-ce9f0b1b",
+		},
+		{
+			name:     "label value of nothing",
+			template: `{{repl ToLabelValue "" }}`,
+			expected: "",
+		},
+		{
+			name:     "truncate short",
+			template: `{{repl TruncateWithHash "kotsadm" 16 }}`,
+			expected: "kotsadm",
+		},
+		{
+			name:     "truncate long",
+			template: `{{repl TruncateWithHash "kotsadm-postgres-backup" 16 }}`,
+			expected: "kotsadm-53908637",
+		},
+		{
+			name:     "truncate shorter than the hash",
+			template: `{{repl TruncateWithHash "abcdefghij" 5 }}`,
+			expected: "72399",
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := builder.String(test.template)
+			req.NoError(err)
+			req.Equal(test.expected, actual)
+		})
+	}
+}
+
+func TestStaticContext_namesAreValid(t *testing.T) {
+	inputs := []string{
+		"a",
+		"-",
+		"...",
+		"Tenant_A.example.com",
+		"über-app",
+		"0123456789012345678901234567890123456789012345678901234567890123456789",
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa----------------",
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa________________",
+	}
+
+	ctx := StaticCtx{}
+	for _, input := range inputs {
+		assert.Empty(t, validation.IsDNS1123Label(ctx.toDNS1123(input)), input)
+		assert.Empty(t, validation.IsValidLabelValue(ctx.toLabelValue(input)), input)
+	}
+
+	// names that only differ after the limit stay different
+	a := ctx.truncateWithHash("kotsadm-tenant-a-very-long-suffix", 20)
+	b := ctx.truncateWithHash("kotsadm-tenant-a-very-long-suffiy", 20)
+	assert.Len(t, a, 20)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,79 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrReservedFunction   = errors.New("template function name is reserved")
+	ErrDuplicatedFunction = errors.New("template function is already registered")
+
+	// registeredCtxs are added to every builder after its own contexts, see RegisterCtx
+	registeredCtxsMtx sync.RWMutex
+	registeredCtxs    []Ctx
+)
+
+// RegisterCtx adds the functions of a context to every template that's rendered, so that programs
+// that embed kots can provide template functions of their own. The names of the kots and sprig
+// functions are reserved, and a name can only be registered once. Contexts should be registered
+// before anything is rendered, e.g. in an init func.
+func RegisterCtx(ctx Ctx) error {
+	funcMap := ctx.FuncMap()
+	if err := validateFuncMap(funcMap); err != nil {
+		return err
+	}
+
+	registeredCtxsMtx.Lock()
+	defer registeredCtxsMtx.Unlock()
+
+	reserved := builtinFunctionContexts()
+	registered := map[string]bool{}
+	for _, registeredCtx := range registeredCtxs {
+		for name := range registeredCtx.FuncMap() {
+			registered[name] = true
+		}
+	}
+
+	names := []string{}
+	for name := range funcMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := reserved[name]; ok {
+			return errors.Wrap(ErrReservedFunction, name)
+		}
+		if registered[name] {
+			return errors.Wrap(ErrDuplicatedFunction, name)
+		}
+	}
+
+	registeredCtxs = append(registeredCtxs, ctx)
+	return nil
+}
+
+// registeredContexts returns the registered contexts in the order they were registered
+func registeredContexts() []Ctx {
+	registeredCtxsMtx.RLock()
+	defer registeredCtxsMtx.RUnlock()
+
+	return append([]Ctx{}, registeredCtxs...)
+}
+
+// validateFuncMap returns an error for the funcs that text/template would panic on when they are
+// added to a template, so that they are rejected when they are registered instead of rendered
+func validateFuncMap(funcMap template.FuncMap) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("invalid template function: %s", fmt.Sprint(r))
+		}
+	}()
+
+	template.New("").Funcs(funcMap)
+	return nil
+}
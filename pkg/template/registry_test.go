@@ -0,0 +1,100 @@
+package template
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type funcMapCtx template.FuncMap
+
+func (ctx funcMapCtx) FuncMap() template.FuncMap {
+	return template.FuncMap(ctx)
+}
+
+// clearRegisteredCtxs clears the registered contexts, and returns the func that restores them
+func clearRegisteredCtxs() func() {
+	registeredCtxsMtx.Lock()
+	saved := registeredCtxs
+	registeredCtxs = nil
+	registeredCtxsMtx.Unlock()
+
+	return func() {
+		registeredCtxsMtx.Lock()
+		registeredCtxs = saved
+		registeredCtxsMtx.Unlock()
+	}
+}
+
+func TestRegisterCtx(t *testing.T) {
+	defer clearRegisteredCtxs()()
+	req := require.New(t)
+
+	err := RegisterCtx(funcMapCtx{
+		"VendorRegion": func() string { return "us-east-1" },
+	})
+	req.NoError(err)
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+	builder.AddCtx(testContext{})
+
+	rendered, err := builder.RenderTemplate("test", `{{repl VendorRegion }}-{{repl ConfigOption "option_1" | ToLower }}`)
+	req.NoError(err)
+	assert.Equal(t, "us-east-1-option 1", rendered)
+
+	usage, err := AnalyzeUsage(map[string][]byte{"test.yaml": []byte(`{{repl VendorRegion }}`)})
+	req.NoError(err)
+	req.Len(usage.Functions, 1)
+	assert.Equal(t, UsageContextRegistered, usage.Functions[0].Context)
+}
+
+func TestRegisterCtxErrors(t *testing.T) {
+	defer clearRegisteredCtxs()()
+
+	require.NoError(t, RegisterCtx(funcMapCtx{"VendorRegion": func() string { return "" }}))
+
+	tests := []struct {
+		name    string
+		funcMap funcMapCtx
+		wantErr error
+	}{
+		{
+			name:    "kots function",
+			funcMap: funcMapCtx{"ConfigOption": func(string) string { return "" }},
+			wantErr: ErrReservedFunction,
+		},
+		{
+			name:    "sprig function",
+			funcMap: funcMapCtx{"upper": func(string) string { return "" }},
+			wantErr: ErrReservedFunction,
+		},
+		{
+			name:    "already registered",
+			funcMap: funcMapCtx{"VendorRegion": func() string { return "" }},
+			wantErr: ErrDuplicatedFunction,
+		},
+		{
+			name:    "not a function",
+			funcMap: funcMapCtx{"VendorZone": "us-east-1a"},
+		},
+		{
+			name:    "invalid name",
+			funcMap: funcMapCtx{"vendor-zone": func() string { return "" }},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterCtx(tt.funcMap)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, errors.Cause(err))
+			}
+		})
+	}
+
+	assert.Len(t, registeredContexts(), 1)
+}
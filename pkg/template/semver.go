@@ -0,0 +1,52 @@
+package template
+
+import (
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+)
+
+// semverCompare returns -1, 0 or 1 when version a is older than, the same as or newer than
+// version b. Versions can start with a v and leave out the minor and patch versions.
+func (ctx StaticCtx) semverCompare(a string, b string) (int, error) {
+	versionA, err := semver.NewVersion(a)
+	if err != nil {
+		return 0, errors.Wrapf(err, "SemverCompare failed to parse version %q", a)
+	}
+	versionB, err := semver.NewVersion(b)
+	if err != nil {
+		return 0, errors.Wrapf(err, "SemverCompare failed to parse version %q", b)
+	}
+
+	return versionA.Compare(versionB), nil
+}
+
+// semverSatisfies returns whether the version matches the constraint, e.g. ">= 1.19" or
+// "~1.2.3, != 1.2.5". Prerelease versions only match constraints that include a prerelease.
+func (ctx StaticCtx) semverSatisfies(version string, constraint string) (bool, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, errors.Wrapf(err, "SemverSatisfies failed to parse version %q", version)
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, errors.Wrapf(err, "SemverSatisfies failed to parse constraint %q", constraint)
+	}
+
+	return c.Check(v), nil
+}
+
+func (ctx StaticCtx) semverMajor(version string) (uint64, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return 0, errors.Wrapf(err, "SemverMajor failed to parse version %q", version)
+	}
+	return v.Major(), nil
+}
+
+func (ctx StaticCtx) semverMinor(version string) (uint64, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return 0, errors.Wrapf(err, "SemverMinor failed to parse version %q", version)
+	}
+	return v.Minor(), nil
+}
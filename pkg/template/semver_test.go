@@ -0,0 +1,79 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticContext_semver(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "compare older",
+			template: `{{repl SemverCompare "1.2.3" "v1.10.0" }}`,
+			expected: "-1",
+		},
+		{
+			name:     "compare same",
+			template: `{{repl SemverCompare "v1.2" "1.2.0" }}`,
+			expected: "0",
+		},
+		{
+			name:     "compare newer",
+			template: `{{repl SemverCompare "2.0.0" "1.99.99" }}`,
+			expected: "1",
+		},
+		{
+			name:        "compare invalid",
+			template:    `{{repl SemverCompare "latest" "1.0.0" }}`,
+			expectError: true,
+		},
+		{
+			name:     "satisfies",
+			template: `{{repl if SemverSatisfies "v1.19.4+k3s1" ">= 1.19" }}networking.k8s.io/v1{{repl else }}networking.k8s.io/v1beta1{{repl end }}`,
+			expected: "networking.k8s.io/v1",
+		},
+		{
+			name:     "doesn't satisfy",
+			template: `{{repl if SemverSatisfies "v1.18.9" ">= 1.19" }}networking.k8s.io/v1{{repl else }}networking.k8s.io/v1beta1{{repl end }}`,
+			expected: "networking.k8s.io/v1beta1",
+		},
+		{
+			name:     "range",
+			template: `{{repl SemverSatisfies "1.2.5" "~1.2.3, != 1.2.5" }}`,
+			expected: "false",
+		},
+		{
+			name:        "invalid constraint",
+			template:    `{{repl SemverSatisfies "1.2.5" "newer than 1.2" }}`,
+			expectError: true,
+		},
+		{
+			name:     "major and minor",
+			template: `{{repl SemverMajor "v1.18.9" }}.{{repl SemverMinor "v1.18.9" }}`,
+			expected: "1.18",
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := builder.String(test.template)
+			if test.expectError {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			req.Equal(test.expected, actual)
+		})
+	}
+}
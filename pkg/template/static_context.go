@@ -53,11 +53,43 @@ func (ctx StaticCtx) FuncMap() template.FuncMap {
 	sprigMap["Mult"] = ctx.mult
 	sprigMap["Div"] = ctx.div
 	sprigMap["ParseBool"] = ctx.parseBool
+	sprigMap["And"] = ctx.and
+	sprigMap["Or"] = ctx.or
+	sprigMap["Not"] = ctx.not
+	sprigMap["Xor"] = ctx.xor
 	sprigMap["ParseFloat"] = ctx.parseFloat
 	sprigMap["ParseInt"] = ctx.parseInt
 	sprigMap["ParseUint"] = ctx.parseUint
 	sprigMap["HumanSize"] = ctx.humanSize
 	sprigMap["KubeSeal"] = ctx.kubeSeal
+	sprigMap["SortKeys"] = ctx.sortKeys
+	sprigMap["StableHash"] = ctx.stableHash
+	sprigMap["ToDNS1123"] = ctx.toDNS1123
+	sprigMap["ToLabelValue"] = ctx.toLabelValue
+	sprigMap["TruncateWithHash"] = ctx.truncateWithHash
+	sprigMap["SemverCompare"] = ctx.semverCompare
+	sprigMap["SemverSatisfies"] = ctx.semverSatisfies
+	sprigMap["SemverMajor"] = ctx.semverMajor
+	sprigMap["SemverMinor"] = ctx.semverMinor
+	sprigMap["ToYaml"] = ctx.toYaml
+	sprigMap["FromYaml"] = ctx.fromYaml
+	sprigMap["ToJson"] = ctx.toJson
+	sprigMap["FromJson"] = ctx.fromJson
+	sprigMap["MergeYaml"] = ctx.mergeYaml
+	sprigMap["GenPrivateKey"] = ctx.genPrivateKey
+	sprigMap["GenPublicKey"] = ctx.genPublicKey
+	sprigMap["SSHKeyPair"] = ctx.sshKeyPair
+
+	// the sprig versions of these return items in random order, which makes renders differ
+	sprigMap["keys"] = ctx.keys
+	sprigMap["values"] = ctx.values
+
+	// these only resolve when a DNSCtx is added, which is done when validating config
+	sprigMap["DnsLookup"] = noDNSLookup
+	sprigMap["DnsTxt"] = noDNSLookup
+
+	// this only reads the environment when an EnvCtx is added with allowed prefixes
+	sprigMap["GetEnv"] = noGetEnv
 
 	return sprigMap
 }
@@ -0,0 +1,308 @@
+package template
+
+import (
+	"regexp"
+	"sort"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	UsageContextSprig   = "sprig"
+	UsageContextStatic  = "static"
+	UsageContextConfig  = "config"
+	UsageContextLicense = "license"
+	UsageContextApp     = "app"
+	UsageContextDNS     = "dns"
+	UsageContextEnv     = "env"
+	// UsageContextRegistered is the context of the functions added with RegisterCtx
+	UsageContextRegistered = "registered"
+	UsageContextUnknown    = "unknown"
+)
+
+var (
+	functionNotDefinedRegexp = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+	// deprecatedFunctions will change or be removed in a future kots release, with what to use instead
+	deprecatedFunctions = map[string]string{
+		"trimall": "use trimAll",
+	}
+)
+
+// FunctionUsage is a template function that an app calls
+type FunctionUsage struct {
+	Name       string   `json:"name"`
+	Context    string   `json:"context"`
+	Count      int      `json:"count"`
+	Files      []string `json:"files"`
+	Deprecated string   `json:"deprecated,omitempty"`
+}
+
+// ConfigItemUsage is a config item that an app reads with the config context functions
+type ConfigItemUsage struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Files []string `json:"files"`
+}
+
+// Usage is what an app uses from the template functions, sorted by name
+type Usage struct {
+	Functions   []FunctionUsage   `json:"functions"`
+	ConfigItems []ConfigItemUsage `json:"configItems"`
+	Contexts    []string          `json:"contexts"`
+}
+
+// AnalyzeUsage finds the template functions, config items and contexts that the files use, without
+// rendering them. Functions that kots doesn't know about are reported with the unknown context.
+func AnalyzeUsage(files map[string][]byte) (*Usage, error) {
+	contexts := functionContexts()
+
+	functions := map[string]*FunctionUsage{}
+	configItems := map[string]*ConfigItemUsage{}
+
+	filenames := []string{}
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		calls, err := findTemplateCalls(filename, string(files[filename]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse templates in %s", filename)
+		}
+
+		for _, call := range calls {
+			context, ok := contexts[call.function]
+			if !ok {
+				context = UsageContextUnknown
+			}
+
+			function, ok := functions[call.function]
+			if !ok {
+				function = &FunctionUsage{
+					Name:       call.function,
+					Context:    context,
+					Deprecated: deprecatedFunctions[call.function],
+				}
+				functions[call.function] = function
+			}
+			function.Count++
+			function.Files = appendFilename(function.Files, filename)
+
+			if context != UsageContextConfig || call.firstArg == "" {
+				continue
+			}
+			item, ok := configItems[call.firstArg]
+			if !ok {
+				item = &ConfigItemUsage{Name: call.firstArg}
+				configItems[call.firstArg] = item
+			}
+			item.Count++
+			item.Files = appendFilename(item.Files, filename)
+		}
+	}
+
+	usage := &Usage{
+		Functions:   []FunctionUsage{},
+		ConfigItems: []ConfigItemUsage{},
+		Contexts:    []string{},
+	}
+	usedContexts := map[string]bool{}
+	for _, function := range functions {
+		usage.Functions = append(usage.Functions, *function)
+		usedContexts[function.Context] = true
+	}
+	for _, item := range configItems {
+		usage.ConfigItems = append(usage.ConfigItems, *item)
+	}
+	for context := range usedContexts {
+		usage.Contexts = append(usage.Contexts, context)
+	}
+
+	sort.Slice(usage.Functions, func(i, j int) bool { return usage.Functions[i].Name < usage.Functions[j].Name })
+	sort.Slice(usage.ConfigItems, func(i, j int) bool { return usage.ConfigItems[i].Name < usage.ConfigItems[j].Name })
+	sort.Strings(usage.Contexts)
+
+	return usage, nil
+}
+
+// DeprecatedFunctions returns the functions in the usage that are deprecated
+func (u *Usage) DeprecatedFunctions() []FunctionUsage {
+	deprecated := []FunctionUsage{}
+	for _, function := range u.Functions {
+		if function.Deprecated != "" {
+			deprecated = append(deprecated, function)
+		}
+	}
+	return deprecated
+}
+
+// functionContexts maps each function to the context that provides it, including the functions of
+// the registered contexts
+func functionContexts() map[string]string {
+	contexts := builtinFunctionContexts()
+	for _, ctx := range registeredContexts() {
+		for name := range ctx.FuncMap() {
+			contexts[name] = UsageContextRegistered
+		}
+	}
+	return contexts
+}
+
+// builtinFunctionContexts maps each of the kots and sprig functions to the context that provides
+// it. The static context stubs functions of the dns and env contexts, so those are added last.
+func builtinFunctionContexts() map[string]string {
+	contexts := map[string]string{}
+	add := func(funcMap template.FuncMap, context string) {
+		for name := range funcMap {
+			contexts[name] = context
+		}
+	}
+
+	add(StaticCtx{}.FuncMap(), UsageContextStatic)
+	add(sprig.TxtFuncMap(), UsageContextSprig)
+	add(ConfigCtx{}.FuncMap(), UsageContextConfig)
+	add(LicenseCtx{}.FuncMap(), UsageContextLicense)
+	add(AppCtx{}.FuncMap(), UsageContextApp)
+	add(DNSCtx{}.FuncMap(), UsageContextDNS)
+	add(EnvCtx{}.FuncMap(), UsageContextEnv)
+
+	// kots overrides these sprig functions so that they're stable
+	contexts["keys"] = UsageContextStatic
+	contexts["values"] = UsageContextStatic
+
+	return contexts
+}
+
+type templateCall struct {
+	function string
+	// firstArg is the first argument when it's a string literal, e.g. the item of ConfigOption
+	firstArg string
+}
+
+// findTemplateCalls parses the text with both of the delimiters that RenderTemplate uses, and
+// returns the functions called in the order they appear
+func findTemplateCalls(name string, text string) ([]templateCall, error) {
+	calls := []templateCall{}
+	for _, d := range []struct {
+		ldelim string
+		rdelim string
+	}{
+		{"{{repl", "}}"},
+		{"repl{{", "}}"},
+	} {
+		tmpl, err := parseWithAnyFunctions(name, text, d.ldelim, d.rdelim)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range tmpl.Templates() {
+			if t.Tree == nil {
+				continue
+			}
+			calls = append(calls, callsInNode(t.Tree.Root)...)
+		}
+	}
+
+	return calls, nil
+}
+
+// parseWithAnyFunctions parses the template, defining each function that the parser doesn't know
+// about so that apps that call functions from newer kots releases can be analyzed
+func parseWithAnyFunctions(name string, text string, ldelim string, rdelim string) (*template.Template, error) {
+	funcMap := template.FuncMap{}
+	for name := range functionContexts() {
+		funcMap[name] = noop
+	}
+
+	for {
+		tmpl, err := template.New(name).Delims(ldelim, rdelim).Funcs(funcMap).Parse(text)
+		if err == nil {
+			return tmpl, nil
+		}
+
+		matches := functionNotDefinedRegexp.FindStringSubmatch(err.Error())
+		if len(matches) != 2 {
+			return nil, err
+		}
+		if _, ok := funcMap[matches[1]]; ok {
+			return nil, err
+		}
+		funcMap[matches[1]] = noop
+	}
+}
+
+func noop(args ...interface{}) string {
+	return ""
+}
+
+func callsInNode(node parse.Node) []templateCall {
+	calls := []templateCall{}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return calls
+		}
+		for _, child := range n.Nodes {
+			calls = append(calls, callsInNode(child)...)
+		}
+	case *parse.ActionNode:
+		calls = append(calls, callsInNode(n.Pipe)...)
+	case *parse.IfNode:
+		calls = append(calls, callsInBranch(&n.BranchNode)...)
+	case *parse.RangeNode:
+		calls = append(calls, callsInBranch(&n.BranchNode)...)
+	case *parse.WithNode:
+		calls = append(calls, callsInBranch(&n.BranchNode)...)
+	case *parse.TemplateNode:
+		calls = append(calls, callsInNode(n.Pipe)...)
+	case *parse.PipeNode:
+		if n == nil {
+			return calls
+		}
+		for _, cmd := range n.Cmds {
+			calls = append(calls, callsInNode(cmd)...)
+		}
+	case *parse.ChainNode:
+		calls = append(calls, callsInNode(n.Node)...)
+	case *parse.CommandNode:
+		for i, arg := range n.Args {
+			identifier, ok := arg.(*parse.IdentifierNode)
+			if !ok {
+				calls = append(calls, callsInNode(arg)...)
+				continue
+			}
+
+			call := templateCall{function: identifier.Ident}
+			if i == 0 && len(n.Args) > 1 {
+				if s, ok := n.Args[1].(*parse.StringNode); ok {
+					call.firstArg = s.Text
+				}
+			}
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func callsInBranch(n *parse.BranchNode) []templateCall {
+	calls := callsInNode(n.Pipe)
+	calls = append(calls, callsInNode(n.List)...)
+	calls = append(calls, callsInNode(n.ElseList)...)
+	return calls
+}
+
+func appendFilename(filenames []string, filename string) []string {
+	for _, f := range filenames {
+		if f == filename {
+			return filenames
+		}
+	}
+	return append(filenames, filename)
+}
@@ -0,0 +1,74 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AnalyzeUsage(t *testing.T) {
+	files := map[string][]byte{
+		"deployment.yaml": []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    hostname: repl{{ ConfigOption "hostname" | ToLower }}
+spec:
+  replicas: repl{{ if ConfigOptionEquals "ha" "1" }}3repl{{ else }}1repl{{ end }}
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          env:
+            - name: SEAT_COUNT
+              value: '{{repl LicenseFieldValue "seats" }}'
+            - name: TRIMMED
+              value: '{{repl trimall "-" (ConfigOption "hostname") }}'
+`),
+		"configmap.yaml": []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  region: repl{{ GetEnv "KOTS_APP_REGION" | default "us-east-1" }}
+  future: repl{{ FunctionFromTheFuture }}
+  helm: '{{ .Values.notATemplate }}'
+`),
+	}
+
+	usage, err := AnalyzeUsage(files)
+	require.NoError(t, err)
+
+	assert.Equal(t, []FunctionUsage{
+		{Name: "ConfigOption", Context: UsageContextConfig, Count: 2, Files: []string{"deployment.yaml"}},
+		{Name: "ConfigOptionEquals", Context: UsageContextConfig, Count: 1, Files: []string{"deployment.yaml"}},
+		{Name: "FunctionFromTheFuture", Context: UsageContextUnknown, Count: 1, Files: []string{"configmap.yaml"}},
+		{Name: "GetEnv", Context: UsageContextEnv, Count: 1, Files: []string{"configmap.yaml"}},
+		{Name: "LicenseFieldValue", Context: UsageContextLicense, Count: 1, Files: []string{"deployment.yaml"}},
+		{Name: "ToLower", Context: UsageContextStatic, Count: 1, Files: []string{"deployment.yaml"}},
+		{Name: "default", Context: UsageContextSprig, Count: 1, Files: []string{"configmap.yaml"}},
+		{Name: "trimall", Context: UsageContextSprig, Count: 1, Files: []string{"deployment.yaml"}, Deprecated: "use trimAll"},
+	}, usage.Functions)
+
+	assert.Equal(t, []ConfigItemUsage{
+		{Name: "ha", Count: 1, Files: []string{"deployment.yaml"}},
+		{Name: "hostname", Count: 2, Files: []string{"deployment.yaml"}},
+	}, usage.ConfigItems)
+
+	assert.Equal(t, []string{"config", "env", "license", "sprig", "static", "unknown"}, usage.Contexts)
+
+	deprecated := usage.DeprecatedFunctions()
+	require.Len(t, deprecated, 1)
+	assert.Equal(t, "trimall", deprecated[0].Name)
+}
+
+func Test_AnalyzeUsageInvalidTemplate(t *testing.T) {
+	_, err := AnalyzeUsage(map[string][]byte{
+		"broken.yaml": []byte(`value: repl{{ ConfigOption "hostname" `),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken.yaml")
+}
@@ -0,0 +1,35 @@
+package template
+
+import (
+	"runtime/debug"
+)
+
+// templateModules are the modules that provide template functions
+var templateModules = map[string]string{
+	"sprig": "github.com/Masterminds/sprig/v3",
+}
+
+// FunctionVersions returns the versions of the libraries that provide template functions.
+// Versions are only available in binaries built with module support.
+func FunctionVersions() map[string]string {
+	versions := map[string]string{}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return versions
+	}
+
+	for name, modulePath := range templateModules {
+		for _, dep := range buildInfo.Deps {
+			if dep.Path != modulePath {
+				continue
+			}
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			versions[name] = dep.Version
+		}
+	}
+
+	return versions
+}
@@ -0,0 +1,111 @@
+package updatecheck
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
+	"github.com/replicatedhq/kots/pkg/upstream"
+)
+
+type Options struct {
+	License       *kotsv1beta1.License
+	CurrentCursor string
+	// Channel is checked against the channel of the license when it's set, the releases of other
+	// channels can't be installed with the license
+	Channel string
+	// VersionConstraint limits the updates to the releases with a version label that matches the
+	// semver constraint, e.g. "~1.4" or ">= 1.4, < 2"
+	VersionConstraint string
+}
+
+// Update is an available release of the application
+type Update struct {
+	Cursor       string `json:"cursor"`
+	Version      string `json:"version"`
+	ReleaseNotes string `json:"releaseNotes,omitempty"`
+	// Required releases can't be skipped, they are installed before any later release
+	Required  bool   `json:"required"`
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+// CheckForUpdates returns the releases of the upstream that are newer than the current cursor and
+// match the options, oldest first
+func CheckForUpdates(options Options) ([]Update, error) {
+	return CheckForUpdatesWithContext(context.Background(), options)
+}
+
+// CheckForUpdatesWithContext is CheckForUpdates, the requests to the replicated app api are
+// canceled with ctx
+func CheckForUpdatesWithContext(ctx context.Context, options Options) ([]Update, error) {
+	if options.License == nil {
+		return nil, errors.New("a license is required to check for updates")
+	}
+	if options.Channel != "" && options.Channel != options.License.Spec.ChannelName {
+		return nil, errors.Wrapf(kotslicense.ErrChannelMismatch, "license is for channel %q, not %q", options.License.Spec.ChannelName, options.Channel)
+	}
+
+	var constraint *semver.Constraints
+	if options.VersionConstraint != "" {
+		c, err := semver.NewConstraint(options.VersionConstraint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse version constraint %q", options.VersionConstraint)
+		}
+		constraint = c
+	}
+
+	releases, err := upstream.ListPendingReleases(ctx, options.License, options.CurrentCursor)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pending releases")
+	}
+
+	return filterReleases(releases, constraint), nil
+}
+
+// filterReleases returns the releases that match the constraint. Releases after a required
+// release that doesn't match are left out, they can't be installed without it.
+func filterReleases(releases []upstream.ChannelRelease, constraint *semver.Constraints) []Update {
+	sorted := append([]upstream.ChannelRelease{}, releases...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ChannelSequence < sorted[j].ChannelSequence
+	})
+
+	updates := []Update{}
+	for _, release := range sorted {
+		if !matchesConstraint(release.VersionLabel, constraint) {
+			if release.IsRequired {
+				break
+			}
+			continue
+		}
+
+		updates = append(updates, Update{
+			Cursor:       strconv.Itoa(release.ChannelSequence),
+			Version:      release.VersionLabel,
+			ReleaseNotes: release.ReleaseNotes,
+			Required:     release.IsRequired,
+			CreatedAt:    release.CreatedAt,
+		})
+	}
+
+	return updates
+}
+
+// matchesConstraint returns true when there is no constraint, and false for version labels that
+// aren't semver when there is one
+func matchesConstraint(versionLabel string, constraint *semver.Constraints) bool {
+	if constraint == nil {
+		return true
+	}
+
+	version, err := semver.NewVersion(versionLabel)
+	if err != nil {
+		return false
+	}
+
+	return constraint.Check(version)
+}
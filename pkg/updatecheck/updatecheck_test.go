@@ -0,0 +1,115 @@
+package updatecheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_filterReleases(t *testing.T) {
+	releases := []upstream.ChannelRelease{
+		{ChannelSequence: 12, VersionLabel: "2.0.0", IsRequired: true},
+		{ChannelSequence: 10, VersionLabel: "1.4.1", ReleaseNotes: "fixes"},
+		{ChannelSequence: 11, VersionLabel: "nightly"},
+		{ChannelSequence: 13, VersionLabel: "2.0.1"},
+	}
+
+	tests := []struct {
+		name             string
+		constraint       string
+		expectedVersions []string
+	}{
+		{
+			name:             "no constraint",
+			expectedVersions: []string{"1.4.1", "nightly", "2.0.0", "2.0.1"},
+		},
+		{
+			name:             "matches all semver releases",
+			constraint:       ">= 1.4",
+			expectedVersions: []string{"1.4.1", "2.0.0", "2.0.1"},
+		},
+		{
+			name:             "stops at a required release that doesn't match",
+			constraint:       "~1.4",
+			expectedVersions: []string{"1.4.1"},
+		},
+		{
+			name:             "no matches",
+			constraint:       ">= 3",
+			expectedVersions: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var constraint *semver.Constraints
+			if test.constraint != "" {
+				c, err := semver.NewConstraint(test.constraint)
+				require.NoError(t, err)
+				constraint = c
+			}
+
+			versions := []string{}
+			for _, update := range filterReleases(releases, constraint) {
+				versions = append(versions, update.Version)
+			}
+			assert.Equal(t, test.expectedVersions, versions)
+		})
+	}
+}
+
+func Test_CheckForUpdates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/release/my-app":
+			assert.Equal(t, "HEAD", r.Method)
+		case "/release/my-app/pending":
+			assert.Equal(t, "10", r.URL.Query().Get("channelSequence"))
+			w.Write([]byte(`{"channelReleases":[{"channelSequence":11,"releaseSequence":40,"versionLabel":"1.4.1","createdAt":"2020-06-01T00:00:00Z","releaseNotes":"fixes","isRequired":true}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	license := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			AppSlug:     "my-app",
+			ChannelName: "Stable",
+			Endpoint:    server.URL,
+			LicenseID:   "abc",
+		},
+	}
+
+	updates, err := CheckForUpdatesWithContext(context.Background(), Options{
+		License:           license,
+		CurrentCursor:     "10",
+		Channel:           "Stable",
+		VersionConstraint: "~1.4",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Update{
+		{
+			Cursor:       "11",
+			Version:      "1.4.1",
+			ReleaseNotes: "fixes",
+			Required:     true,
+			CreatedAt:    "2020-06-01T00:00:00Z",
+		},
+	}, updates)
+
+	_, err = CheckForUpdates(Options{License: license, Channel: "Beta"})
+	assert.Equal(t, kotslicense.ErrChannelMismatch, errors.Cause(err))
+
+	_, err = CheckForUpdates(Options{License: license, VersionConstraint: "not a constraint"})
+	assert.Error(t, err)
+}
@@ -1,49 +1,77 @@
 package upload
 
 import (
+	"archive/tar"
 	"io/ioutil"
 	"os"
 	"path"
-	"strings"
+	"path/filepath"
 
-	"github.com/mholt/archiver"
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/upstream"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
-func createUploadableArchive(rootPath string) (string, error) {
-	if strings.HasSuffix(rootPath, string(os.PathSeparator)) {
-		rootPath = strings.TrimSuffix(rootPath, string(os.PathSeparator))
-	}
+// uploadableArchiveDir is the directory in an uploadable archive that has the application directories
+const uploadableArchiveDir = "kots-uploadable-archive"
 
-	tarGz := archiver.TarGz{
-		Tar: &archiver.Tar{
-			ImplicitTopLevelFolder: true,
-		},
+// createUploadableArchive writes a tar.gz of the upstream, base and overlays directories, without
+// the files that are ignored
+func createUploadableArchive(rootPath string, archiveOptions ArchiveOptions) (string, error) {
+	// the caller of this function is repsonsible for deleting this file
+	tempDir, err := ioutil.TempDir("", "kots")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp dir")
 	}
+	archiveFilename := filepath.Join(tempDir, "kots-uploadable-archive.tar.gz")
 
-	paths := []string{
-		path.Join(rootPath, "upstream"),
-		path.Join(rootPath, "base"),
-		path.Join(rootPath, "overlays"),
+	f, err := os.Create(archiveFilename)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create archive file")
 	}
+	defer f.Close()
 
-	// the caller of this function is repsonsible for deleting this file
-	tempDir, err := ioutil.TempDir("", "kots")
+	gzipWriter, err := newGzipWriter(f, archiveOptions)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to create temp dir")
+		return "", err
+	}
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = walkArchiveFiles(rootPath, archiveOptions, func(relPath string, info os.FileInfo) error {
+		name := path.Join(uploadableArchiveDir, relPath)
+		if info.IsDir() {
+			header := &tar.Header{
+				Name:     name + "/",
+				Mode:     0755,
+				Typeflag: tar.TypeDir,
+				ModTime:  info.ModTime(),
+			}
+			return errors.Wrap(tarWriter.WriteHeader(header), "failed to write header")
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(rootPath, filepath.FromSlash(relPath)))
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", relPath)
+		}
+		return errors.Wrapf(writeTarFile(tarWriter, name, content), "failed to write %s", relPath)
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write files")
 	}
 
-	if err := tarGz.Archive(paths, path.Join(tempDir, "kots-uploadable-archive.tar.gz")); err != nil {
-		return "", errors.Wrap(err, "failed to create tar gz")
+	if err := tarWriter.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to close tar writer")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to close gzip writer")
 	}
 
-	return path.Join(tempDir, "kots-uploadable-archive.tar.gz"), nil
+	return archiveFilename, nil
 }
 
 func findUpdateCursor(rootPath string) (string, error) {
-	installationFilePath := path.Join(rootPath, "upstream", "userdata", "installation.yaml")
+	installationFilePath := filepath.Join(rootPath, "upstream", "userdata", "installation.yaml")
 	_, err := os.Stat(installationFilePath)
 	if os.IsNotExist(err) {
 		return "", nil
@@ -68,8 +96,20 @@ func findUpdateCursor(rootPath string) (string, error) {
 	return installation.Spec.UpdateCursor, nil
 }
 
+func hasEncryptedConfigValues(rootPath string) (bool, error) {
+	b, err := ioutil.ReadFile(filepath.Join(rootPath, "upstream", "userdata", "config.yaml"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read config values file")
+	}
+
+	return upstream.IsEncryptedConfigValues(b), nil
+}
+
 func findLicense(rootPath string) (*string, error) {
-	licenseFilePath := path.Join(rootPath, "upstream", "userdata", "license.yaml")
+	licenseFilePath := filepath.Join(rootPath, "upstream", "userdata", "license.yaml")
 	_, err := os.Stat(licenseFilePath)
 	if os.IsNotExist(err) {
 		return nil, nil
@@ -0,0 +1,50 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// maxUploadAttempts is how many times an archive is sent when the admin console received it
+// with a different checksum, e.g. because a proxy truncated it
+const maxUploadAttempts = 3
+
+// checksumMismatchError is returned when the checksum of the archive that the admin console
+// received isn't the checksum of the archive that was sent
+type checksumMismatchError struct {
+	expected string
+	received string
+}
+
+func (e checksumMismatchError) Error() string {
+	return fmt.Sprintf("the admin console received an archive with sha256 %s, expected %s", e.received, e.expected)
+}
+
+// fileChecksum returns the hex encoded sha256 of the file
+func fileChecksum(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrap(err, "failed to read file")
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyChecksum compares the checksum that the admin console reported with the checksum of the
+// archive. Admin consoles that don't verify archives don't report a checksum.
+func verifyChecksum(expected string, received string) error {
+	if received == "" || received == expected {
+		return nil
+	}
+	return checksumMismatchError{expected: expected, received: received}
+}
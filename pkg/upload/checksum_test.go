@@ -0,0 +1,80 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sendArchiveChecksum(t *testing.T) {
+	archive, err := ioutil.TempFile("", "kots")
+	require.NoError(t, err)
+	defer os.Remove(archive.Name())
+	_, err = archive.Write([]byte("archive contents"))
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+
+	checksum, err := fileChecksum(archive.Name())
+	require.NoError(t, err)
+	// hex encoded sha256
+	assert.Len(t, checksum, 64)
+
+	tests := []struct {
+		name             string
+		reportedChecksum string
+		expectMismatch   bool
+	}{
+		{
+			name:             "same checksum",
+			reportedChecksum: checksum,
+		},
+		{
+			name: "older admin console",
+		},
+		{
+			name:             "truncated archive",
+			reportedChecksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			expectMismatch:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/kots" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				require.NoError(t, r.ParseMultipartForm(1<<20))
+				metadata := map[string]string{}
+				require.NoError(t, json.Unmarshal([]byte(r.FormValue("metadata")), &metadata))
+				assert.Equal(t, checksum, metadata["archiveSha256"])
+
+				fmt.Fprintf(w, `{"uri": "/app/my-app", "archiveSha256": %q}`, test.reportedChecksum)
+			}))
+			defer server.Close()
+
+			err := sendArchive(archive.Name(), UploadOptions{
+				Endpoint:        server.URL,
+				ExistingAppSlug: "my-app",
+				archiveChecksum: checksum,
+			})
+			if !test.expectMismatch {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			_, ok := errors.Cause(err).(checksumMismatchError)
+			assert.True(t, ok)
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package upload
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ContentAddressedArchiveFormat is sent in the upload metadata when the archive
+	// contains a manifest and blobs instead of the application directories
+	ContentAddressedArchiveFormat = "content-addressed"
+
+	archiveManifestFilename = "manifest.json"
+	archiveBlobsDir         = "blobs"
+)
+
+// contentHashRegexp matches the hex sha256 that blobs are named by
+var contentHashRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ArchiveManifest maps every file in an application version to the sha256 of its content.
+// Files with the same content, in this or previous versions, are only stored once.
+type ArchiveManifest struct {
+	Version int               `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// Hashes returns the unique content hashes in the manifest
+func (m *ArchiveManifest) Hashes() []string {
+	unique := map[string]bool{}
+	for _, hash := range m.Files {
+		unique[hash] = true
+	}
+
+	hashes := make([]string, 0, len(unique))
+	for hash := range unique {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	return hashes
+}
+
+// buildArchiveManifest hashes the files in the upstream, base and overlays directories that
+// aren't ignored
+func buildArchiveManifest(rootPath string, archiveOptions ArchiveOptions) (*ArchiveManifest, error) {
+	manifest := ArchiveManifest{
+		Version: 1,
+		Files:   map[string]string{},
+	}
+
+	err := walkArchiveFiles(rootPath, archiveOptions, func(relPath string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(rootPath, filepath.FromSlash(relPath)))
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", relPath)
+		}
+
+		manifest.Files[relPath] = contentHash(content)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// createContentAddressedArchive writes a tar.gz with the manifest and the content of every
+// file in it, skipping the content that the receiver already has
+func createContentAddressedArchive(rootPath string, manifest *ArchiveManifest, existingHashes map[string]bool, archiveOptions ArchiveOptions) (string, error) {
+	// the caller of this function is repsonsible for deleting this file
+	tempDir, err := ioutil.TempDir("", "kots")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp dir")
+	}
+	archiveFilename := filepath.Join(tempDir, "kots-uploadable-archive.tar.gz")
+
+	f, err := os.Create(archiveFilename)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create archive file")
+	}
+	defer f.Close()
+
+	gzipWriter, err := newGzipWriter(f, archiveOptions)
+	if err != nil {
+		return "", err
+	}
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal manifest")
+	}
+	if err := writeTarFile(tarWriter, archiveManifestFilename, manifestData); err != nil {
+		return "", errors.Wrap(err, "failed to write manifest")
+	}
+
+	written := map[string]bool{}
+	filePaths := make([]string, 0, len(manifest.Files))
+	for filePath := range manifest.Files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	for _, filePath := range filePaths {
+		hash := manifest.Files[filePath]
+		if existingHashes[hash] || written[hash] {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(rootPath, filepath.FromSlash(filePath)))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read %s", filePath)
+		}
+		if contentHash(content) != hash {
+			return "", errors.Errorf("%s changed while creating archive", filePath)
+		}
+
+		if err := writeTarFile(tarWriter, path.Join(archiveBlobsDir, hash), content); err != nil {
+			return "", errors.Wrapf(err, "failed to write content of %s", filePath)
+		}
+		written[hash] = true
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to close tar writer")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to close gzip writer")
+	}
+
+	return archiveFilename, nil
+}
+
+// ExtractContentAddressedArchive restores the application files from a content addressed
+// archive into destDir. New content is added to blobsDir, and content missing from the archive
+// is read from blobsDir, so blobsDir should be kept between versions of the same app.
+func ExtractContentAddressedArchive(archivePath string, destDir string, blobsDir string) (*ArchiveManifest, error) {
+	if err := os.MkdirAll(blobsDir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create blobs dir")
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open archive")
+	}
+	defer f.Close()
+
+	gzipReader, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gzip reader")
+	}
+	defer gzipReader.Close()
+
+	var manifest *ArchiveManifest
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read archive")
+		}
+
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", header.Name)
+		}
+
+		if header.Name == archiveManifestFilename {
+			manifest = &ArchiveManifest{}
+			if err := json.Unmarshal(content, manifest); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal manifest")
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(header.Name, archiveBlobsDir+"/") {
+			continue
+		}
+		hash := strings.TrimPrefix(header.Name, archiveBlobsDir+"/")
+		if contentHash(content) != hash {
+			return nil, errors.Errorf("content of blob %s does not match its hash", hash)
+		}
+		if err := ioutil.WriteFile(filepath.Join(blobsDir, hash), content, 0644); err != nil {
+			return nil, errors.Wrapf(err, "failed to write blob %s", hash)
+		}
+	}
+
+	if manifest == nil {
+		return nil, errors.New("archive does not contain a manifest")
+	}
+
+	for filePath, hash := range manifest.Files {
+		// hashes are used in blob paths, so anything but a sha256 could escape the blobs dir
+		if !contentHashRegexp.MatchString(hash) {
+			return nil, errors.Errorf("invalid hash %q for %s", hash, filePath)
+		}
+
+		destPath := filepath.Join(destDir, filepath.FromSlash(filePath))
+		if !strings.HasPrefix(destPath, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return nil, errors.Errorf("invalid file path %s", filePath)
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(blobsDir, hash))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read blob for %s", filePath)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+			return nil, errors.Wrapf(err, "failed to create dir for %s", filePath)
+		}
+		if err := ioutil.WriteFile(destPath, content, 0644); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", filePath)
+		}
+	}
+
+	return manifest, nil
+}
+
+func writeTarFile(tarWriter *tar.Writer, name string, content []byte) error {
+	header := &tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(content)),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return errors.Wrap(err, "failed to write header")
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		return errors.Wrap(err, "failed to write content")
+	}
+	return nil
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package upload
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ContentAddressedArchiveRoundTrip(t *testing.T) {
+	req := require.New(t)
+
+	files := map[string]string{
+		"upstream/deployment.yaml":                    "kind: Deployment",
+		"base/deployment.yaml":                        "kind: Deployment",
+		"base/service.yaml":                           "kind: Service",
+		"overlays/midstream/kustomization.yaml":       "kind: Kustomization",
+		"overlays/downstreams/this-cluster/kust.yaml": "kind: Kustomization",
+	}
+
+	srcDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(srcDir)
+	for name, content := range files {
+		req.NoError(os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0755))
+		req.NoError(ioutil.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+	}
+
+	manifest, err := buildArchiveManifest(srcDir, ArchiveOptions{})
+	req.NoError(err)
+	assert.Len(t, manifest.Files, 5)
+	assert.Len(t, manifest.Hashes(), 3)
+
+	blobsDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(blobsDir)
+
+	// first version uploads all content
+	archive, err := createContentAddressedArchive(srcDir, manifest, nil, ArchiveOptions{})
+	req.NoError(err)
+	defer os.RemoveAll(filepath.Dir(archive))
+
+	firstDestDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(firstDestDir)
+	_, err = ExtractContentAddressedArchive(archive, firstDestDir, blobsDir)
+	req.NoError(err)
+
+	// second version only uploads what changed
+	req.NoError(ioutil.WriteFile(filepath.Join(srcDir, "base/service.yaml"), []byte("kind: Service\nchanged: true"), 0644))
+	manifest, err = buildArchiveManifest(srcDir, ArchiveOptions{})
+	req.NoError(err)
+
+	existingHashes := map[string]bool{}
+	for _, name := range []string{"base/deployment.yaml", "overlays/midstream/kustomization.yaml"} {
+		existingHashes[contentHash([]byte(files[name]))] = true
+	}
+	archive, err = createContentAddressedArchive(srcDir, manifest, existingHashes, ArchiveOptions{})
+	req.NoError(err)
+	defer os.RemoveAll(filepath.Dir(archive))
+
+	secondDestDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(secondDestDir)
+	_, err = ExtractContentAddressedArchive(archive, secondDestDir, blobsDir)
+	req.NoError(err)
+
+	for name, content := range files {
+		if name == "base/service.yaml" {
+			content = "kind: Service\nchanged: true"
+		}
+		actual, err := ioutil.ReadFile(filepath.Join(secondDestDir, name))
+		req.NoError(err)
+		assert.Equal(t, content, string(actual))
+	}
+}
+
+func Test_ExtractContentAddressedArchiveInvalidHash(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	b, err := json.Marshal(ArchiveManifest{Files: map[string]string{"base/deployment.yaml": "../../etc/passwd"}})
+	req.NoError(err)
+
+	archivePath := filepath.Join(tempDir, "archive.tar.gz")
+	f, err := os.Create(archivePath)
+	req.NoError(err)
+	gzipWriter := gzip.NewWriter(f)
+	tarWriter := tar.NewWriter(gzipWriter)
+	req.NoError(writeTarFile(tarWriter, archiveManifestFilename, b))
+	req.NoError(tarWriter.Close())
+	req.NoError(gzipWriter.Close())
+	req.NoError(f.Close())
+
+	_, err = ExtractContentAddressedArchive(archivePath, filepath.Join(tempDir, "dest"), filepath.Join(tempDir, "blobs"))
+	req.EqualError(err, `invalid hash "../../etc/passwd" for base/deployment.yaml`)
+}
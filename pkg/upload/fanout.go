@@ -0,0 +1,285 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/deploy"
+	"github.com/replicatedhq/kots/pkg/downstream"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultFanOutParallelism is the number of clusters that are uploaded to at the same time when the
+// options don't set it
+const DefaultFanOutParallelism = 4
+
+type ClusterPhase string
+
+const (
+	ClusterPhasePending   ClusterPhase = "pending"
+	ClusterPhaseUploading ClusterPhase = "uploading"
+	ClusterPhaseDeploying ClusterPhase = "deploying"
+	ClusterPhaseSucceeded ClusterPhase = "succeeded"
+	ClusterPhaseFailed    ClusterPhase = "failed"
+)
+
+// ClusterTarget is an admin console that the app is uploaded to, in a namespace of the cluster of
+// a kubeconfig context
+type ClusterTarget struct {
+	Kubeconfig string
+	// Context is the context of the kubeconfig, the current context when it's empty
+	Context   string
+	Namespace string
+}
+
+func (t ClusterTarget) String() string {
+	context := t.Context
+	if context == "" {
+		context = "(current context)"
+	}
+	return fmt.Sprintf("%s/%s", context, t.Namespace)
+}
+
+// ClusterStatus is the phase of the upload to one cluster. Err is set when it failed, and Deploy
+// when the downstream was deployed, or failed to become ready.
+type ClusterStatus struct {
+	Target ClusterTarget
+	Phase  ClusterPhase
+	Err    error
+	Deploy *deploy.Result
+}
+
+type FanOutOptions struct {
+	Targets []ClusterTarget
+	// UploadOptions are the options of the upload to every cluster. The namespace, kubeconfig, context
+	// and endpoint are the ones of each cluster, and the uploads are silent.
+	UploadOptions UploadOptions
+	// Parallelism is the number of clusters that are uploaded to at the same time,
+	// DefaultFanOutParallelism when it's not set
+	Parallelism int
+	// DeployDownstream is the downstream whose overlay is deployed to each cluster after the upload to
+	// it succeeds, nothing is deployed when it's empty
+	DeployDownstream string
+	DeployOptions    deploy.DeployOptions
+	// OnStatus is optional, it's called when the phase of a cluster changes. It's called from more
+	// than one goroutine, but not at the same time.
+	OnStatus func(ClusterStatus)
+}
+
+// FanOutResult is the status of each cluster, in the order of the targets
+type FanOutResult struct {
+	Clusters []ClusterStatus
+}
+
+// Failed returns the clusters that the upload or deploy failed for
+func (r FanOutResult) Failed() []ClusterStatus {
+	failed := []ClusterStatus{}
+	for _, cluster := range r.Clusters {
+		if cluster.Phase == ClusterPhaseFailed {
+			failed = append(failed, cluster)
+		}
+	}
+	return failed
+}
+
+// clusterFunc uploads to and deploys to one cluster, and reports the phases in between
+type clusterFunc func(ctx context.Context, target ClusterTarget, setPhase func(ClusterPhase)) (*deploy.Result, error)
+
+// UploadToClusters uploads the application version at path to the admin console of every target, and
+// deploys it when options.DeployDownstream is set. A cluster that fails doesn't stop the others, the
+// error is only returned for invalid options. The clusters that haven't started when ctx is canceled
+// fail with its error.
+func UploadToClusters(ctx context.Context, path string, options FanOutOptions) (*FanOutResult, error) {
+	if err := validateFanOutOptions(path, options); err != nil {
+		return nil, err
+	}
+
+	upload := func(ctx context.Context, target ClusterTarget, setPhase func(ClusterPhase)) (*deploy.Result, error) {
+		return uploadToCluster(ctx, path, target, options, setPhase)
+	}
+	return fanOut(ctx, options, upload), nil
+}
+
+func validateFanOutOptions(path string, options FanOutOptions) error {
+	if len(options.Targets) == 0 {
+		return errors.New("no clusters to upload to")
+	}
+
+	seen := map[ClusterTarget]bool{}
+	for _, target := range options.Targets {
+		if target.Namespace == "" {
+			return errors.Errorf("cluster %s does not have a namespace", target)
+		}
+		if seen[target] {
+			return errors.Errorf("cluster %s is listed more than once", target)
+		}
+		seen[target] = true
+	}
+
+	// the uploads can't prompt for the app, they run at the same time
+	uploadOptions := options.UploadOptions
+	if uploadOptions.ExistingAppSlug == "" && (uploadOptions.NewAppName == "" || uploadOptions.UpstreamURI == "") {
+		return errors.New("the slug of the app, or the name and upstream uri of a new app, are required to upload to more than one cluster")
+	}
+	if uploadOptions.UpgradeAdminConsole {
+		return errors.New("the admin console can't be upgraded when uploading to more than one cluster")
+	}
+
+	if options.DeployDownstream != "" {
+		downstreamDir := downstream.Dir(filepath.Join(path, "overlays"), options.DeployDownstream)
+		if _, err := os.Stat(filepath.Join(downstreamDir, "kustomization.yaml")); err != nil {
+			return errors.Errorf("downstream %s does not exist", options.DeployDownstream)
+		}
+	}
+
+	return nil
+}
+
+// fanOut runs fn for each target on options.Parallelism workers
+func fanOut(ctx context.Context, options FanOutOptions, fn clusterFunc) *FanOutResult {
+	parallelism := options.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultFanOutParallelism
+	}
+	if parallelism > len(options.Targets) {
+		parallelism = len(options.Targets)
+	}
+
+	result := &FanOutResult{
+		Clusters: make([]ClusterStatus, len(options.Targets)),
+	}
+
+	var mtx sync.Mutex
+	setStatus := func(index int, status ClusterStatus) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		result.Clusters[index] = status
+		if options.OnStatus != nil {
+			options.OnStatus(status)
+		}
+	}
+
+	for index, target := range options.Targets {
+		setStatus(index, ClusterStatus{Target: target, Phase: ClusterPhasePending})
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				target := options.Targets[index]
+				if err := ctx.Err(); err != nil {
+					setStatus(index, ClusterStatus{Target: target, Phase: ClusterPhaseFailed, Err: errors.Wrap(err, "upload stopped")})
+					continue
+				}
+
+				setPhase := func(phase ClusterPhase) {
+					setStatus(index, ClusterStatus{Target: target, Phase: phase})
+				}
+				setPhase(ClusterPhaseUploading)
+
+				deployResult, err := fn(ctx, target, setPhase)
+				if err != nil {
+					setStatus(index, ClusterStatus{Target: target, Phase: ClusterPhaseFailed, Err: err, Deploy: deployResult})
+					continue
+				}
+				setStatus(index, ClusterStatus{Target: target, Phase: ClusterPhaseSucceeded, Deploy: deployResult})
+			}
+		}()
+	}
+
+	for index := range options.Targets {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	return result
+}
+
+// clusterUploadOptions are the upload options for one target. The context is set as well as the
+// kubeconfig, so that the object store is port forwarded in the cluster of the target too.
+func clusterUploadOptions(uploadOptions UploadOptions, target ClusterTarget) UploadOptions {
+	uploadOptions.Namespace = target.Namespace
+	uploadOptions.Kubeconfig = target.Kubeconfig
+	uploadOptions.KubeContext = target.Context
+	uploadOptions.Silent = true
+	uploadOptions.ProgressReporter = nil
+	return uploadOptions
+}
+
+// uploadToCluster port forwards to the admin console of the target to upload, and deploys the
+// downstream to the cluster of the target after the upload
+func uploadToCluster(ctx context.Context, path string, target ClusterTarget, options FanOutOptions, setPhase func(ClusterPhase)) (*deploy.Result, error) {
+	cfg, err := k8sutil.GetContextConfig(target.Kubeconfig, target.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	uploadOptions := clusterUploadOptions(options.UploadOptions, target)
+
+	skew, err := kotsadm.GetVersionSkew(target.Namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get version skew")
+	}
+	// upgrades are rejected with the options, the admin console of the current context would be upgraded
+	noUpgrade := func(context.Context, kotsadm.UpgradeOptions) error {
+		return errors.New("the admin console can't be upgraded when uploading to more than one cluster")
+	}
+	if err := handleVersionSkew(ctx, skew, uploadOptions, noUpgrade); err != nil {
+		return nil, err
+	}
+
+	podName, err := findKotsadmPod(clientset, target.Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find kotsadm pod")
+	}
+	localPort, err := k8sutil.AvailablePort()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find a local port")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if _, err := k8sutil.PortForwardWithConfig(cfg, localPort, 3000, target.Namespace, podName, stopCh); err != nil {
+		return nil, errors.Wrap(err, "failed to port forward")
+	}
+	uploadOptions.Endpoint = fmt.Sprintf("http://localhost:%d", localPort)
+
+	if err := UploadWithContext(ctx, path, uploadOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to upload")
+	}
+
+	if options.DeployDownstream == "" {
+		return nil, nil
+	}
+	setPhase(ClusterPhaseDeploying)
+
+	kubeconfig, err := k8sutil.GetContextKubeconfig(target.Kubeconfig, target.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get kubeconfig")
+	}
+	downstreamDir := downstream.Dir(filepath.Join(path, "overlays"), options.DeployDownstream)
+	deployResult, err := deploy.Deploy(downstreamDir, kubeconfig, options.DeployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to deploy")
+	}
+	if !deployResult.Ready() {
+		return deployResult, errors.Errorf("%d objects of downstream %s are not ready", len(deployResult.NotReady), options.DeployDownstream)
+	}
+
+	return deployResult, nil
+}
@@ -0,0 +1,248 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/deploy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fanOut(t *testing.T) {
+	targets := []ClusterTarget{}
+	for i := 0; i < 7; i++ {
+		targets = append(targets, ClusterTarget{Context: fmt.Sprintf("cluster-%d", i), Namespace: "default"})
+	}
+
+	var mtx sync.Mutex
+	running, maxRunning := 0, 0
+	phases := map[string][]ClusterPhase{}
+
+	options := FanOutOptions{
+		Targets:     targets,
+		Parallelism: 3,
+		OnStatus: func(status ClusterStatus) {
+			phases[status.Target.Context] = append(phases[status.Target.Context], status.Phase)
+		},
+	}
+	result := fanOut(context.Background(), options, func(ctx context.Context, target ClusterTarget, setPhase func(ClusterPhase)) (*deploy.Result, error) {
+		mtx.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mtx.Unlock()
+		defer func() {
+			mtx.Lock()
+			running--
+			mtx.Unlock()
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		if target.Context == "cluster-2" {
+			return nil, errors.New("unable to find kotsadm pod")
+		}
+		setPhase(ClusterPhaseDeploying)
+		return &deploy.Result{}, nil
+	})
+
+	assert.Equal(t, 3, maxRunning)
+	require.Len(t, result.Clusters, len(targets))
+	for i, cluster := range result.Clusters {
+		assert.Equal(t, targets[i], cluster.Target)
+		if cluster.Target.Context == "cluster-2" {
+			assert.Equal(t, ClusterPhaseFailed, cluster.Phase)
+			assert.EqualError(t, cluster.Err, "unable to find kotsadm pod")
+			continue
+		}
+		assert.Equal(t, ClusterPhaseSucceeded, cluster.Phase)
+		assert.NoError(t, cluster.Err)
+		assert.NotNil(t, cluster.Deploy)
+	}
+
+	failed := result.Failed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "cluster-2", failed[0].Target.Context)
+
+	assert.Equal(t, []ClusterPhase{ClusterPhasePending, ClusterPhaseUploading, ClusterPhaseFailed}, phases["cluster-2"])
+	assert.Equal(t, []ClusterPhase{ClusterPhasePending, ClusterPhaseUploading, ClusterPhaseDeploying, ClusterPhaseSucceeded}, phases["cluster-0"])
+}
+
+func Test_fanOutCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	options := FanOutOptions{
+		Targets: []ClusterTarget{
+			{Context: "first", Namespace: "default"},
+			{Context: "second", Namespace: "default"},
+		},
+		Parallelism: 1,
+	}
+	result := fanOut(ctx, options, func(ctx context.Context, target ClusterTarget, setPhase func(ClusterPhase)) (*deploy.Result, error) {
+		cancel()
+		return nil, nil
+	})
+
+	assert.Equal(t, ClusterPhaseSucceeded, result.Clusters[0].Phase)
+	assert.Equal(t, ClusterPhaseFailed, result.Clusters[1].Phase)
+	assert.Equal(t, context.Canceled, errors.Cause(result.Clusters[1].Err))
+}
+
+func Test_validateFanOutOptions(t *testing.T) {
+	appDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(appDir)
+
+	downstreamDir := filepath.Join(appDir, "overlays", "downstreams", "production")
+	require.NoError(t, os.MkdirAll(downstreamDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(downstreamDir, "kustomization.yaml"), []byte("bases:\n- ../../midstream\n"), 0644))
+
+	targets := []ClusterTarget{
+		{Context: "us-east", Namespace: "default"},
+		{Context: "eu-west", Namespace: "default"},
+	}
+
+	tests := []struct {
+		name        string
+		options     FanOutOptions
+		expectError bool
+	}{
+		{
+			name: "existing app",
+			options: FanOutOptions{
+				Targets:          targets,
+				UploadOptions:    UploadOptions{ExistingAppSlug: "my-app"},
+				DeployDownstream: "production",
+			},
+		},
+		{
+			name: "new app",
+			options: FanOutOptions{
+				Targets:       targets,
+				UploadOptions: UploadOptions{NewAppName: "My App", UpstreamURI: "replicated://my-app"},
+			},
+		},
+		{
+			name:        "no targets",
+			options:     FanOutOptions{UploadOptions: UploadOptions{ExistingAppSlug: "my-app"}},
+			expectError: true,
+		},
+		{
+			name: "duplicate target",
+			options: FanOutOptions{
+				Targets:       append(targets, targets[0]),
+				UploadOptions: UploadOptions{ExistingAppSlug: "my-app"},
+			},
+			expectError: true,
+		},
+		{
+			name: "no namespace",
+			options: FanOutOptions{
+				Targets:       []ClusterTarget{{Context: "us-east"}},
+				UploadOptions: UploadOptions{ExistingAppSlug: "my-app"},
+			},
+			expectError: true,
+		},
+		{
+			name: "new app without upstream uri",
+			options: FanOutOptions{
+				Targets:       targets,
+				UploadOptions: UploadOptions{NewAppName: "My App"},
+			},
+			expectError: true,
+		},
+		{
+			name: "upgrade admin console",
+			options: FanOutOptions{
+				Targets:       targets,
+				UploadOptions: UploadOptions{ExistingAppSlug: "my-app", UpgradeAdminConsole: true},
+			},
+			expectError: true,
+		},
+		{
+			name: "missing downstream",
+			options: FanOutOptions{
+				Targets:          targets,
+				UploadOptions:    UploadOptions{ExistingAppSlug: "my-app"},
+				DeployDownstream: "staging",
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateFanOutOptions(appDir, test.options)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_clusterUploadOptionsContexts(t *testing.T) {
+	req := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	kubeconfig := filepath.Join(tempDir, "kubeconfig")
+	req.NoError(ioutil.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: us-east
+contexts:
+- name: us-east
+  context:
+    cluster: us-east
+    user: admin
+- name: eu-west
+  context:
+    cluster: eu-west
+    user: admin
+clusters:
+- name: us-east
+  cluster:
+    server: https://us-east.example.com:6443
+- name: eu-west
+  cluster:
+    server: https://eu-west.example.com:6443
+users:
+- name: admin
+  user:
+    token: abc123
+`), 0600))
+
+	uploadOptions := UploadOptions{ExistingAppSlug: "my-app", Namespace: "kots"}
+	targets := []ClusterTarget{
+		{Kubeconfig: kubeconfig, Context: "us-east", Namespace: "default"},
+		{Kubeconfig: kubeconfig, Context: "eu-west", Namespace: "kotsadm"},
+	}
+
+	// the object store of each target is looked up and port forwarded in the cluster of its context,
+	// not in the one of the current context
+	hosts := []string{}
+	for _, target := range targets {
+		clusterOptions := clusterUploadOptions(uploadOptions, target)
+		assert.Equal(t, target.Namespace, clusterOptions.Namespace)
+		assert.Equal(t, target.Context, clusterOptions.KubeContext)
+		assert.True(t, clusterOptions.Silent)
+
+		cfg, err := clusterOptions.clusterConfig()
+		req.NoError(err)
+		hosts = append(hosts, cfg.Host)
+	}
+	assert.Equal(t, []string{"https://us-east.example.com:6443", "https://eu-west.example.com:6443"}, hosts)
+	assert.Equal(t, "kots", uploadOptions.Namespace)
+}
@@ -0,0 +1,228 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/pgzip"
+	"github.com/pkg/errors"
+)
+
+// IgnoreFilename is the file in an application directory with patterns of the files that aren't
+// uploaded. The patterns are a subset of the .gitignore syntax: a pattern without a slash matches
+// a name at any depth, a leading slash or one in the middle anchors it to the application
+// directory, a trailing slash only matches directories, "**" matches any number of directories,
+// and "!" includes files that an earlier pattern excluded. Files in a directory that's excluded
+// can't be included again.
+const IgnoreFilename = ".kotsignore"
+
+// defaultIgnorePatterns are excluded before the patterns of IgnoreFilename, so they can be included again
+var defaultIgnorePatterns = []string{
+	".git/",
+	".svn/",
+	".hg/",
+	".DS_Store",
+	"._*",
+	"Thumbs.db",
+	"desktop.ini",
+	"*.swp",
+	"*~",
+}
+
+// archiveDirs are the directories of an application directory that are uploaded
+var archiveDirs = []string{"upstream", "base", "overlays"}
+
+type ArchiveOptions struct {
+	// CompressionLevel is the gzip level of the archive, from gzip.BestSpeed to
+	// gzip.BestCompression. The default level is used when it's 0.
+	CompressionLevel int
+	// Excludes are patterns of more files not to upload, Includes are patterns of files to upload
+	// even when they're excluded. Both have the syntax of IgnoreFilename, and come after its patterns.
+	Excludes []string
+	Includes []string
+	// Downstreams are the downstreams that the rendered overlays are uploaded of, all of them are
+	// uploaded when it's empty
+	Downstreams []string
+}
+
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+type ignoreRules []ignoreRule
+
+// loadIgnoreRules returns the default rules, then the rules in the IgnoreFilename of the application
+// directory, then the rules of the options
+func loadIgnoreRules(rootPath string, archiveOptions ArchiveOptions) (ignoreRules, error) {
+	patterns := append([]string{}, defaultIgnorePatterns...)
+
+	b, err := ioutil.ReadFile(filepath.Join(rootPath, IgnoreFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to read %s", IgnoreFilename)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", IgnoreFilename)
+	}
+
+	patterns = append(patterns, archiveOptions.Excludes...)
+	for _, include := range archiveOptions.Includes {
+		patterns = append(patterns, "!"+strings.TrimPrefix(include, "!"))
+	}
+
+	rules := ignoreRules{}
+	for _, pattern := range patterns {
+		rule, ok := parseIgnorePattern(pattern)
+		if !ok {
+			continue
+		}
+		if _, err := path.Match(strings.Join(rule.segments, "/"), ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %q", pattern)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// parseIgnorePattern returns false for blank lines and comments
+func parseIgnorePattern(pattern string) (ignoreRule, bool) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.segments = strings.Split(pattern, "/")
+	if !anchored {
+		rule.segments = append([]string{"**"}, rule.segments...)
+	}
+	return rule, true
+}
+
+// ignored returns true when the file or directory isn't uploaded. relPath is relative to the
+// application directory and uses slashes. The last rule that matches wins.
+func (r ignoreRules) ignored(relPath string, isDir bool) bool {
+	segments := strings.Split(relPath, "/")
+
+	ignored := false
+	for _, rule := range r {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, segments) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	matched, err := path.Match(pattern[0], segments[0])
+	if err != nil || !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// walkArchiveFiles calls fn for the directories and files of the application directory that are
+// uploaded, with their paths relative to rootPath
+func walkArchiveFiles(rootPath string, archiveOptions ArchiveOptions, fn func(relPath string, info os.FileInfo) error) error {
+	rules, err := loadIgnoreRules(rootPath, archiveOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to load ignore rules")
+	}
+
+	downstreams := map[string]bool{}
+	for _, downstream := range archiveOptions.Downstreams {
+		downstreams[downstream] = true
+	}
+
+	for _, dir := range archiveDirs {
+		err := filepath.Walk(filepath.Join(rootPath, dir), func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(rootPath, filePath)
+			if err != nil {
+				return errors.Wrap(err, "failed to get relative path")
+			}
+			relPath = filepath.ToSlash(relPath)
+
+			skip := rules.ignored(relPath, info.IsDir())
+			if info.IsDir() && len(downstreams) > 0 && path.Dir(relPath) == "overlays/downstreams" && !downstreams[path.Base(relPath)] {
+				skip = true
+			}
+			if skip {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			return fn(relPath, info)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to walk %s", dir)
+		}
+	}
+
+	return nil
+}
+
+// newGzipWriter compresses blocks of the archive in parallel
+func newGzipWriter(w io.Writer, archiveOptions ArchiveOptions) (*pgzip.Writer, error) {
+	level := archiveOptions.CompressionLevel
+	if level == 0 {
+		level = pgzip.DefaultCompression
+	}
+
+	gzipWriter, err := pgzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gzip writer")
+	}
+	return gzipWriter, nil
+}
@@ -0,0 +1,158 @@
+package upload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ignoreRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		relPath  string
+		isDir    bool
+		expect   bool
+	}{
+		{
+			name:    "git dir is ignored by default",
+			relPath: "upstream/.git",
+			isDir:   true,
+			expect:  true,
+		},
+		{
+			name:    "os junk is ignored by default",
+			relPath: "base/.DS_Store",
+			expect:  true,
+		},
+		{
+			name:    "manifests are not ignored",
+			relPath: "base/deployment.yaml",
+			expect:  false,
+		},
+		{
+			name:     "name matches at any depth",
+			patterns: []string{"*.bak"},
+			relPath:  "overlays/midstream/kustomization.yaml.bak",
+			expect:   true,
+		},
+		{
+			name:     "anchored pattern",
+			patterns: []string{"/upstream/tests"},
+			relPath:  "upstream/tests",
+			isDir:    true,
+			expect:   true,
+		},
+		{
+			name:     "anchored pattern doesn't match deeper",
+			patterns: []string{"/tests"},
+			relPath:  "upstream/tests",
+			isDir:    true,
+			expect:   false,
+		},
+		{
+			name:     "dir only pattern doesn't match files",
+			patterns: []string{"tests/"},
+			relPath:  "upstream/tests",
+			expect:   false,
+		},
+		{
+			name:     "double star",
+			patterns: []string{"upstream/**/*.md"},
+			relPath:  "upstream/docs/a/README.md",
+			expect:   true,
+		},
+		{
+			name:     "negation includes again",
+			patterns: []string{"*.md", "!upstream/README.md"},
+			relPath:  "upstream/README.md",
+			expect:   false,
+		},
+		{
+			name:     "default can be included again",
+			patterns: []string{"!*~"},
+			relPath:  "upstream/config~",
+			expect:   false,
+		},
+		{
+			name:     "comments and blank lines",
+			patterns: []string{"# *.yaml", ""},
+			relPath:  "base/service.yaml",
+			expect:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rootPath, err := ioutil.TempDir("", "kots")
+			require.NoError(t, err)
+			defer os.RemoveAll(rootPath)
+
+			rules, err := loadIgnoreRules(rootPath, ArchiveOptions{Excludes: test.patterns})
+			require.NoError(t, err)
+			assert.Equal(t, test.expect, rules.ignored(test.relPath, test.isDir))
+		})
+	}
+}
+
+func Test_walkArchiveFiles(t *testing.T) {
+	req := require.New(t)
+
+	rootPath, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(rootPath)
+
+	files := []string{
+		".kotsignore",
+		"upstream/deployment.yaml",
+		"upstream/.git/HEAD",
+		"upstream/notes.txt",
+		"base/deployment.yaml",
+		"base/.DS_Store",
+		"overlays/midstream/kustomization.yaml",
+		"overlays/downstreams/this-cluster/kustomization.yaml",
+		"overlays/downstreams/other-cluster/kustomization.yaml",
+	}
+	for _, name := range files {
+		req.NoError(os.MkdirAll(filepath.Dir(filepath.Join(rootPath, name)), 0755))
+		req.NoError(ioutil.WriteFile(filepath.Join(rootPath, name), []byte("*.txt\n"), 0644))
+	}
+
+	archiveFiles := []string{}
+	err = walkArchiveFiles(rootPath, ArchiveOptions{Downstreams: []string{"this-cluster"}}, func(relPath string, info os.FileInfo) error {
+		if !info.IsDir() {
+			archiveFiles = append(archiveFiles, relPath)
+		}
+		return nil
+	})
+	req.NoError(err)
+
+	sort.Strings(archiveFiles)
+	assert.Equal(t, []string{
+		"base/deployment.yaml",
+		"overlays/downstreams/this-cluster/kustomization.yaml",
+		"overlays/midstream/kustomization.yaml",
+		"upstream/deployment.yaml",
+	}, archiveFiles)
+}
+
+func Test_createUploadableArchiveCompressionLevel(t *testing.T) {
+	rootPath, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootPath)
+	for _, dir := range archiveDirs {
+		require.NoError(t, os.MkdirAll(filepath.Join(rootPath, dir), 0755))
+	}
+
+	_, err = createUploadableArchive(rootPath, ArchiveOptions{CompressionLevel: 42})
+	require.Error(t, err)
+
+	archive, err := createUploadableArchive(rootPath, ArchiveOptions{CompressionLevel: 9})
+	require.NoError(t, err)
+	os.RemoveAll(filepath.Dir(archive))
+}
@@ -35,6 +35,11 @@ func findKotsadm(namespace string) (string, error) {
 		return "", errors.Wrap(err, "failed to create kubernetes clientset")
 	}
 
+	return findKotsadmPod(clientset, namespace)
+}
+
+// findKotsadmPod returns the name of a running kotsadm api pod in the namespace
+func findKotsadmPod(clientset kubernetes.Interface, namespace string) (string, error) {
 	pods, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: "app=kotsadm-api"})
 	if err != nil {
 		return "", errors.Wrap(err, "failed to list pods")
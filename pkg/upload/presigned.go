@@ -0,0 +1,198 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type presignedUpload struct {
+	URL string `json:"uploadURL"`
+	Key string `json:"key"`
+}
+
+// requestPresignedUpload asks the admin console for a url to upload the archive to object storage with.
+// The second return value is false if the admin console does not support presigned uploads.
+func requestPresignedUpload(uploadOptions UploadOptions) (*presignedUpload, bool, error) {
+	reqBody := map[string]interface{}{
+		"slug":          uploadOptions.ExistingAppSlug,
+		"archiveFormat": uploadOptions.archiveFormat,
+	}
+	b, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to marshal request")
+	}
+
+	resp, err := postJSON(uploadOptions, fmt.Sprintf("%s/api/v1/kots/upload-url", uploadOptions.Endpoint), b)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, false, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, false, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to read response body")
+	}
+	presigned := presignedUpload{}
+	if err := json.Unmarshal(respBody, &presigned); err != nil {
+		return nil, false, errors.Wrap(err, "failed to unmarshal response")
+	}
+	if presigned.URL == "" || presigned.Key == "" {
+		return nil, false, errors.New("response did not include an upload url and key")
+	}
+
+	return &presigned, true, nil
+}
+
+// uploadToPresignedURL streams the archive to object storage. The in-cluster object store
+// is reached through a port forward, with the host that the url was signed for.
+func uploadToPresignedURL(archiveFilename string, presignedURL string, uploadOptions UploadOptions) error {
+	u, err := url.Parse(presignedURL)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse upload url")
+	}
+
+	requestURL := *u
+	if isInClusterHost(u.Hostname()) {
+		localPort, err := freeLocalPort()
+		if err != nil {
+			return errors.Wrap(err, "failed to find a local port")
+		}
+
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+
+		if err := startObjectStorePortForward(uploadOptions, localPort, u, stopCh); err != nil {
+			return errors.Wrap(err, "failed to port forward to object store")
+		}
+
+		requestURL.Host = fmt.Sprintf("localhost:%d", localPort)
+	}
+
+	file, err := os.Open(archiveFilename)
+	if err != nil {
+		return errors.Wrap(err, "failed to open archive")
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat archive")
+	}
+
+	tracker := logger.NewProgressTracker(uploadOptions.ProgressReporter, "Uploading archive", logger.ProgressUnitBytes, fi.Size())
+	defer tracker.Done()
+
+	req, err := http.NewRequest("PUT", requestURL.String(), tracker.Reader(file))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Host = u.Host
+	req.ContentLength = fi.Size()
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := http.DefaultClient.Do(req.WithContext(uploadOptions.context()))
+	if err != nil {
+		return errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		// s3 rejects uploads that are too large for a single put with EntityTooLarge
+		if resp.StatusCode == http.StatusRequestEntityTooLarge || bytes.Contains(body, []byte("EntityTooLarge")) {
+			return util.NewError(util.ErrArchiveTooLarge, "the object store rejected the archive for its size", nil)
+		}
+		return errors.Errorf("unexpected status code: %d: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// isInClusterHost returns true for kubernetes service names, which can't be resolved outside the cluster
+func isInClusterHost(hostname string) bool {
+	if hostname == "localhost" || net.ParseIP(hostname) != nil {
+		return false
+	}
+	return !strings.Contains(hostname, ".") || strings.HasSuffix(hostname, ".svc") || strings.Contains(hostname, ".svc.")
+}
+
+func startObjectStorePortForward(uploadOptions UploadOptions, localPort int, u *url.URL, stopCh <-chan struct{}) error {
+	// the object store is in the cluster of the upload, which isn't the current context when
+	// uploading to more than one cluster
+	cfg, err := uploadOptions.clusterConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	// the object store service has the same name as the statefulset's pods' app label
+	service := strings.SplitN(u.Hostname(), ".", 2)[0]
+	pods, err := clientset.CoreV1().Pods(uploadOptions.Namespace).List(metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", service)})
+	if err != nil {
+		return errors.Wrap(err, "failed to list pods")
+	}
+
+	podName := ""
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			podName = pod.Name
+			break
+		}
+	}
+	if podName == "" {
+		return errors.Errorf("unable to find %s pod", service)
+	}
+
+	remotePort := 80
+	if u.Port() != "" {
+		remotePort, err = strconv.Atoi(u.Port())
+		if err != nil {
+			return errors.Wrap(err, "failed to parse port")
+		}
+	}
+
+	// errors after the forward started show up as a failed upload
+	_, err = k8sutil.PortForwardWithConfigAndHealthCheck(cfg, localPort, remotePort, uploadOptions.Namespace, podName, "/minio/health/live", stopCh)
+	if err != nil {
+		return errors.Wrap(err, "failed to start port forwarding")
+	}
+
+	return nil
+}
+
+func freeLocalPort() (int, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to listen")
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,175 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/kots/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_requestPresignedUpload(t *testing.T) {
+	tests := []struct {
+		name              string
+		status            int
+		response          string
+		expectSupported   bool
+		expectError       bool
+		expectedPresigned *presignedUpload
+	}{
+		{
+			name:            "supported",
+			status:          http.StatusOK,
+			response:        `{"uploadURL": "http://kotsadm-minio:9000/kotsadm/uploads/abc?X-Amz-Signature=def", "key": "uploads/abc"}`,
+			expectSupported: true,
+			expectedPresigned: &presignedUpload{
+				URL: "http://kotsadm-minio:9000/kotsadm/uploads/abc?X-Amz-Signature=def",
+				Key: "uploads/abc",
+			},
+		},
+		{
+			name:   "older admin console",
+			status: http.StatusNotFound,
+		},
+		{
+			name:        "server error",
+			status:      http.StatusInternalServerError,
+			expectError: true,
+		},
+		{
+			name:        "no key",
+			status:      http.StatusOK,
+			response:    `{"uploadURL": "http://kotsadm-minio:9000/kotsadm/uploads/abc"}`,
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v1/kots/upload-url", r.URL.Path)
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.response))
+			}))
+			defer server.Close()
+
+			presigned, supported, err := requestPresignedUpload(UploadOptions{Endpoint: server.URL})
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectSupported, supported)
+			assert.Equal(t, test.expectedPresigned, presigned)
+		})
+	}
+}
+
+func Test_uploadToPresignedURL(t *testing.T) {
+	archive, err := ioutil.TempFile("", "kots")
+	require.NoError(t, err)
+	defer os.Remove(archive.Name())
+	_, err = archive.Write([]byte("archive contents"))
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		assert.Equal(t, "def", r.URL.Query().Get("X-Amz-Signature"))
+		assert.Equal(t, int64(len("archive contents")), r.ContentLength)
+		received, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	err = uploadToPresignedURL(archive.Name(), server.URL+"/kotsadm/uploads/abc?X-Amz-Signature=def", UploadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "archive contents", string(received))
+}
+
+func Test_uploadToPresignedURLDeadline(t *testing.T) {
+	archive, err := ioutil.TempFile("", "kots")
+	require.NoError(t, err)
+	defer os.Remove(archive.Name())
+	require.NoError(t, archive.Close())
+
+	// the object store doesn't respond until the test is done
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = uploadToPresignedURL(archive.Name(), server.URL+"/kotsadm/uploads/abc", UploadOptions{ctx: ctx})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+}
+
+func Test_uploadToPresignedURLTooLarge(t *testing.T) {
+	archive, err := ioutil.TempFile("", "kots")
+	require.NoError(t, err)
+	defer os.Remove(archive.Name())
+	require.NoError(t, archive.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`<Error><Code>EntityTooLarge</Code></Error>`))
+	}))
+	defer server.Close()
+
+	err = uploadToPresignedURL(archive.Name(), server.URL+"/kotsadm/uploads/abc", UploadOptions{})
+	require.Error(t, err)
+	assert.Equal(t, util.ErrArchiveTooLarge, util.ErrorCodeOf(err))
+	assert.NotEmpty(t, util.Remediation(err))
+}
+
+func Test_createUploadRequestWithArchiveKey(t *testing.T) {
+	req, err := createUploadRequest("", UploadOptions{ExistingAppSlug: "my-app", archiveKey: "uploads/abc"}, "http://localhost:3000/api/v1/kots")
+	require.NoError(t, err)
+	assert.Equal(t, "PUT", req.Method)
+
+	require.NoError(t, req.ParseMultipartForm(1<<20))
+	assert.Empty(t, req.MultipartForm.File)
+
+	metadata := map[string]string{}
+	require.NoError(t, json.Unmarshal([]byte(req.FormValue("metadata")), &metadata))
+	assert.Equal(t, "uploads/abc", metadata["archiveKey"])
+}
+
+func Test_uploadRequestsAPIToken(t *testing.T) {
+	authorizations := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, _, err := requestPresignedUpload(UploadOptions{Endpoint: server.URL, APIToken: "kotsadm-api-token-abc.def"})
+	require.NoError(t, err)
+	_, _, err = requestPresignedUpload(UploadOptions{Endpoint: server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer kotsadm-api-token-abc.def", ""}, authorizations)
+
+	req, err := createUploadRequest("", UploadOptions{ExistingAppSlug: "my-app", archiveKey: "uploads/abc", APIToken: "kotsadm-api-token-abc.def"}, "http://localhost:3000/api/v1/kots")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer kotsadm-api-token-abc.def", req.Header.Get("Authorization"))
+}
+
+func Test_isInClusterHost(t *testing.T) {
+	assert.True(t, isInClusterHost("kotsadm-minio"))
+	assert.True(t, isInClusterHost("kotsadm-minio.default.svc.cluster.local"))
+	assert.False(t, isInClusterHost("s3.amazonaws.com"))
+	assert.False(t, isInClusterHost("127.0.0.1"))
+	assert.False(t, isInClusterHost("localhost"))
+}
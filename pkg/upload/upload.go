@@ -2,6 +2,7 @@ package upload
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,15 +12,17 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
 
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
 	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
 	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/prompt"
 	"github.com/replicatedhq/kots/pkg/util"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
 
 type UploadOptions struct {
@@ -31,9 +34,34 @@ type UploadOptions struct {
 	RegistryOptions registry.RegistryOptions
 	Endpoint        string
 	Silent          bool
-	updateCursor    string
-	license         *string
-	versionLabel    string
+	// KubeContext is the context of Kubeconfig of the cluster that the admin console is in, the
+	// current context when it's empty
+	KubeContext string
+	// APIToken is sent to the admin console to authenticate the upload when it's set, see
+	// auth.CreateAPIToken. It's read from KOTS_API_TOKEN by the cli.
+	APIToken string
+	// LicenseChannel is the channel that the license must be for, it isn't checked when empty
+	LicenseChannel string
+	// UpgradeAdminConsole upgrades an admin console that is too old for this version of kots,
+	// see CheckVersionSkew
+	UpgradeAdminConsole bool
+	// ProgressReporter is optional, it's sent the progress of creating and uploading the archive
+	ProgressReporter logger.ProgressReporter
+	// ArchiveOptions select the files that are uploaded, in addition to the IgnoreFilename of the
+	// application directory, and how the archive is compressed
+	ArchiveOptions ArchiveOptions
+	// Prompter asks for the app name and upstream uri of a new app when they aren't set, on the
+	// terminal when it's nil
+	Prompter      prompt.Prompter
+	updateCursor  string
+	license       *string
+	versionLabel  string
+	archiveFormat string
+	archiveKey    string
+	// archiveChecksum is the sha256 of the archive that's uploaded
+	archiveChecksum string
+	// ctx is the context of UploadWithContext
+	ctx context.Context
 }
 
 func init() {
@@ -43,10 +71,23 @@ func init() {
 // Upload will upload the application version at path
 // using the options in uploadOptions
 func Upload(path string, uploadOptions UploadOptions) error {
+	return UploadWithContext(context.Background(), path, uploadOptions)
+}
+
+// UploadWithContext is Upload, stopping when ctx is canceled or its deadline passes. The requests
+// to the admin console and to object storage are canceled with it.
+func UploadWithContext(ctx context.Context, path string, uploadOptions UploadOptions) error {
+	uploadOptions.ctx = ctx
+
 	license, err := findLicense(path)
 	if err != nil {
 		return errors.Wrap(err, "failed to find license")
 	}
+	if license != nil {
+		if err := validateLicense(*license, uploadOptions); err != nil {
+			return errors.Wrap(err, "invalid license")
+		}
+	}
 	uploadOptions.license = license
 
 	updateCursor, err := findUpdateCursor(path)
@@ -58,29 +99,38 @@ func Upload(path string, uploadOptions UploadOptions) error {
 	}
 	uploadOptions.updateCursor = updateCursor
 
-	archiveFilename, err := createUploadableArchive(path)
+	// the admin console reads the values from the archive, it doesn't have the key
+	encrypted, err := hasEncryptedConfigValues(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to check config values")
+	}
+	if encrypted {
+		return errors.New("the config values are encrypted and can't be uploaded, pull the app without --encrypt-config-values to upload it")
+	}
+
+	archiveTracker := logger.NewProgressTracker(uploadOptions.ProgressReporter, "Creating archive", logger.ProgressUnitItems, 0)
+	archiveTracker.Add(0)
+	archiveFilename, err := createArchiveForEndpoint(path, &uploadOptions)
+	archiveTracker.Done()
 	if err != nil {
 		return errors.Wrap(err, "failed to create uploadable archive")
 	}
 
 	defer os.Remove(archiveFilename)
 
-	// Make sure we have a name or slug
-	if uploadOptions.ExistingAppSlug == "" && uploadOptions.NewAppName == "" {
-		split := strings.Split(path, string(os.PathSeparator))
-		lastPathPart := ""
-		idx := 1
-		for lastPathPart == "" {
-			lastPathPart = split[len(split)-idx]
-			if lastPathPart == "" && len(split) > idx {
-				idx++
-				continue
-			}
+	archiveChecksum, err := fileChecksum(archiveFilename)
+	if err != nil {
+		return errors.Wrap(err, "failed to compute archive checksum")
+	}
+	uploadOptions.archiveChecksum = archiveChecksum
 
-			break
-		}
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "upload stopped")
+	}
 
-		appName, err := relentlesslyPromptForAppName(lastPathPart)
+	// Make sure we have a name or slug
+	if uploadOptions.ExistingAppSlug == "" && uploadOptions.NewAppName == "" {
+		appName, err := relentlesslyPromptForAppName(prompt.OrTerminal(uploadOptions.Prompter), appNameFromPath(path))
 		if err != nil {
 			return errors.Wrap(err, "failed to prompt for app name")
 		}
@@ -90,7 +140,7 @@ func Upload(path string, uploadOptions UploadOptions) error {
 
 	// Make sure we have an upstream URI
 	if uploadOptions.ExistingAppSlug == "" && uploadOptions.UpstreamURI == "" {
-		upstreamURI, err := promptForUpstreamURI()
+		upstreamURI, err := promptForUpstreamURI(prompt.OrTerminal(uploadOptions.Prompter))
 		if err != nil {
 			return errors.Wrap(err, "failed to prompt for upstream uri")
 		}
@@ -106,68 +156,224 @@ func Upload(path string, uploadOptions UploadOptions) error {
 
 	log.ActionWithSpinner("Uploading local application to Admin Console")
 
+	// the archive is sent again when it was corrupted on the way
+	for attempt := 1; ; attempt++ {
+		err := sendArchive(archiveFilename, uploadOptions)
+		if err == nil {
+			break
+		}
+		if _, ok := errors.Cause(err).(checksumMismatchError); ok && attempt < maxUploadAttempts {
+			log.Debug("Retrying upload: %s", err.Error())
+			continue
+		}
+		log.FinishSpinnerWithError()
+		return err
+	}
+
+	log.FinishSpinner()
+
+	return nil
+}
+
+// sendArchive sends the archive and its metadata to the admin console, and checks that the
+// archive that the admin console received has the same checksum
+func sendArchive(archiveFilename string, uploadOptions UploadOptions) error {
+	// send the archive straight to object storage when the admin console supports it,
+	// so that it isn't proxied through the api, and only post the metadata to the api
+	presigned, supported, err := requestPresignedUpload(uploadOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to request upload url")
+	}
+	uploadFilename := archiveFilename
+	if supported {
+		if err := uploadToPresignedURL(archiveFilename, presigned.URL, uploadOptions); err != nil {
+			return errors.Wrap(err, "failed to upload archive")
+		}
+		uploadOptions.archiveKey = presigned.Key
+		uploadFilename = ""
+	}
+
 	// upload using http to the pod directly
-	req, err := createUploadRequest(archiveFilename, uploadOptions, fmt.Sprintf("%s/api/v1/kots", uploadOptions.Endpoint))
+	req, err := createUploadRequest(uploadFilename, uploadOptions, fmt.Sprintf("%s/api/v1/kots", uploadOptions.Endpoint))
 	if err != nil {
-		log.FinishSpinnerWithError()
 		return errors.Wrap(err, "failed to create upload request")
 	}
-	resp, err := http.DefaultClient.Do(req)
+	if uploadFilename != "" {
+		tracker := logger.NewProgressTracker(uploadOptions.ProgressReporter, "Uploading archive", logger.ProgressUnitBytes, req.ContentLength)
+		defer tracker.Done()
+		req.Body = ioutil.NopCloser(tracker.Reader(req.Body))
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(uploadOptions.context()))
 	if err != nil {
-		log.FinishSpinnerWithError()
 		return errors.Wrap(err, "failed to execute request")
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return util.NewError(util.ErrArchiveTooLarge, "the admin console rejected the archive for its size", nil)
+	}
 	if resp.StatusCode != 200 {
-		log.FinishSpinnerWithError()
 		return errors.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.FinishSpinnerWithError()
 		return errors.Wrap(err, "failed to read response body")
 	}
 	type UploadResponse struct {
 		URI string `json:"uri"`
+		// ArchiveSha256 is the checksum of the archive that the admin console received
+		ArchiveSha256 string `json:"archiveSha256"`
 	}
 	var uploadResponse UploadResponse
 	if err := json.Unmarshal(b, &uploadResponse); err != nil {
-		log.FinishSpinnerWithError()
 		return errors.Wrap(err, "failed to unmarshal response")
 	}
 
-	log.FinishSpinner()
+	if err := verifyChecksum(uploadOptions.archiveChecksum, uploadResponse.ArchiveSha256); err != nil {
+		return errors.Wrap(err, "failed to verify archive")
+	}
 
 	return nil
 }
 
-func createUploadRequest(path string, uploadOptions UploadOptions, uri string) (*http.Request, error) {
-	file, err := os.Open(path)
+// createArchiveForEndpoint creates a content addressed archive without the content that
+// the admin console already has. Admin consoles that don't support content addressed
+// archives get an archive of the application directories.
+func createArchiveForEndpoint(path string, uploadOptions *UploadOptions) (string, error) {
+	manifest, err := buildArchiveManifest(path, uploadOptions.ArchiveOptions)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to open file")
+		return "", errors.Wrap(err, "failed to build archive manifest")
 	}
-	defer file.Close()
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	archivePart, err := writer.CreateFormFile("file", filepath.Base(path))
+	existingHashes, supported, err := findExistingHashes(manifest.Hashes(), *uploadOptions)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create form file")
+		return "", errors.Wrap(err, "failed to find existing content")
+	}
+
+	if !supported {
+		return createUploadableArchive(path, uploadOptions.ArchiveOptions)
 	}
-	_, err = io.Copy(archivePart, file)
+
+	archiveFilename, err := createContentAddressedArchive(path, manifest, existingHashes, uploadOptions.ArchiveOptions)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to copy file to upload")
+		return "", errors.Wrap(err, "failed to create content addressed archive")
+	}
+	uploadOptions.archiveFormat = ContentAddressedArchiveFormat
+
+	return archiveFilename, nil
+}
+
+// findExistingHashes asks the admin console which content it already has.
+// The second return value is false if the admin console does not support content addressed archives.
+func findExistingHashes(hashes []string, uploadOptions UploadOptions) (map[string]bool, bool, error) {
+	reqBody := map[string]interface{}{
+		"slug":   uploadOptions.ExistingAppSlug,
+		"hashes": hashes,
+	}
+	b, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to marshal request")
+	}
+
+	resp, err := postJSON(uploadOptions, fmt.Sprintf("%s/api/v1/kots/blobs", uploadOptions.Endpoint), b)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, false, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, false, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to read response body")
+	}
+	existingResponse := struct {
+		Existing []string `json:"existing"`
+	}{}
+	if err := json.Unmarshal(respBody, &existingResponse); err != nil {
+		return nil, false, errors.Wrap(err, "failed to unmarshal response")
+	}
+
+	existingHashes := map[string]bool{}
+	for _, hash := range existingResponse.Existing {
+		existingHashes[hash] = true
+	}
+
+	return existingHashes, true, nil
+}
+
+func (o UploadOptions) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// clusterConfig is the config of the cluster of the kubeconfig context that is uploaded to
+func (o UploadOptions) clusterConfig() (*rest.Config, error) {
+	if o.Kubeconfig == "" && o.KubeContext == "" {
+		return config.GetConfig()
+	}
+	return k8sutil.GetContextConfig(o.Kubeconfig, o.KubeContext)
+}
+
+// setAuthorization authenticates the request to the admin console with the api token, when there is one
+func (o UploadOptions) setAuthorization(req *http.Request) {
+	if o.APIToken != "" {
+		req.Header.Set("Authorization", "Bearer "+o.APIToken)
+	}
+}
+
+func postJSON(uploadOptions UploadOptions, uri string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("POST", uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	uploadOptions.setAuthorization(req)
+
+	return http.DefaultClient.Do(req.WithContext(uploadOptions.context()))
+}
+
+// createUploadRequest creates the request with the archive at path and its metadata, or with only the
+// metadata when path is empty and the archive was uploaded to uploadOptions.archiveKey
+func createUploadRequest(path string, uploadOptions UploadOptions, uri string) (*http.Request, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open file")
+		}
+		defer file.Close()
+
+		archivePart, err := writer.CreateFormFile("file", filepath.Base(path))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create form file")
+		}
+		_, err = io.Copy(archivePart, file)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to copy file to upload")
+		}
 	}
 
 	method := ""
 	if uploadOptions.ExistingAppSlug != "" {
 		method = "PUT"
 		metadata := map[string]string{
-			"slug":         uploadOptions.ExistingAppSlug,
-			"versionLabel": uploadOptions.versionLabel,
-			"updateCursor": uploadOptions.updateCursor,
+			"slug":          uploadOptions.ExistingAppSlug,
+			"versionLabel":  uploadOptions.versionLabel,
+			"updateCursor":  uploadOptions.updateCursor,
+			"archiveFormat": uploadOptions.archiveFormat,
+			"archiveKey":    uploadOptions.archiveKey,
+			"archiveSha256": uploadOptions.archiveChecksum,
 			// Intnetionally not including registry info here.  Updating settings should be its own thing.
 		}
 		b, err := json.Marshal(metadata)
@@ -193,6 +399,9 @@ func createUploadRequest(path string, uploadOptions UploadOptions, uri string) (
 			"registryUsername":  uploadOptions.RegistryOptions.Username,
 			"registryPassword":  uploadOptions.RegistryOptions.Password,
 			"registryNamespace": uploadOptions.RegistryOptions.Namespace,
+			"archiveFormat":     uploadOptions.archiveFormat,
+			"archiveKey":        uploadOptions.archiveKey,
+			"archiveSha256":     uploadOptions.archiveChecksum,
 		}
 
 		if uploadOptions.license != nil {
@@ -212,7 +421,7 @@ func createUploadRequest(path string, uploadOptions UploadOptions, uri string) (
 		}
 	}
 
-	err = writer.Close()
+	err := writer.Close()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to close writer")
 	}
@@ -223,58 +432,40 @@ func createUploadRequest(path string, uploadOptions UploadOptions, uri string) (
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
+	uploadOptions.setAuthorization(req)
 	return req, nil
 }
 
-func relentlesslyPromptForAppName(defaultAppName string) (string, error) {
-	templates := &promptui.PromptTemplates{
-		Prompt:  "{{ . | bold }} ",
-		Valid:   "{{ . | green }} ",
-		Invalid: "{{ . | red }} ",
-		Success: "{{ . | bold }} ",
+// appNameFromPath is the name of the directory that is uploaded, it's the default app name
+func appNameFromPath(p string) string {
+	name := filepath.Base(filepath.Clean(p))
+	if name == "." || name == string(filepath.Separator) || name == filepath.VolumeName(p) {
+		return ""
 	}
+	return name
+}
 
-	prompt := promptui.Prompt{
-		Label:     "Application name:",
-		Templates: templates,
-		Default:   defaultAppName,
+func relentlesslyPromptForAppName(prompter prompt.Prompter, defaultAppName string) (string, error) {
+	return prompter.Input(prompt.Input{
+		Label:   "Application name:",
+		Default: defaultAppName,
 		Validate: func(input string) error {
 			if len(input) < 3 {
 				return errors.New("invalid app name")
 			}
 			return nil
 		},
-	}
-
-	for {
-		result, err := prompt.Run()
-		if err != nil {
-			if err == promptui.ErrInterrupt {
-				os.Exit(-1)
-			}
-			continue
-		}
-
-		return result, nil
-	}
+	})
 }
 
-func promptForUpstreamURI() (string, error) {
-	templates := &promptui.PromptTemplates{
-		Prompt:  "{{ . | bold }} ",
-		Valid:   "{{ . | green }} ",
-		Invalid: "{{ . | red }} ",
-		Success: "{{ . | bold }} ",
-	}
-
+func promptForUpstreamURI(prompter prompt.Prompter) (string, error) {
 	supportedSchemes := map[string]interface{}{
 		"helm":       nil,
 		"replicated": nil,
 	}
 
-	prompt := promptui.Prompt{
-		Label:     "Upstream URI:",
-		Templates: templates,
+	return prompter.Input(prompt.Input{
+		Label: "Upstream URI:",
 		Validate: func(input string) error {
 			if !util.IsURL(input) {
 				return errors.New("Please enter a URL")
@@ -292,17 +483,5 @@ func promptForUpstreamURI() (string, error) {
 
 			return nil
 		},
-	}
-
-	for {
-		result, err := prompt.Run()
-		if err != nil {
-			if err == promptui.ErrInterrupt {
-				os.Exit(-1)
-			}
-			continue
-		}
-
-		return result, nil
-	}
+	})
 }
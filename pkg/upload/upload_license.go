@@ -2,31 +2,46 @@ package upload
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
 	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/prompt"
 )
 
 type UploadLicenseOptions struct {
 	Namespace  string
 	Kubeconfig string
 	NewAppName string
+	Silent     bool
+	// Prompter asks for the app name when NewAppName isn't set, on the terminal when it's nil
+	Prompter prompt.Prompter
 }
 
 func UploadLicense(path string, uploadLicenseOptions UploadLicenseOptions) error {
+	return UploadLicenseWithContext(context.Background(), path, uploadLicenseOptions)
+}
+
+// UploadLicenseWithContext is UploadLicense, the request to the admin console is canceled with ctx
+func UploadLicenseWithContext(ctx context.Context, path string, uploadLicenseOptions UploadLicenseOptions) error {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return errors.Wrap(err, "failed to read license file")
 	}
+	if _, err := kotslicense.ParseAndValidate(b, kotslicense.ValidateOptions{}); err != nil {
+		return errors.Wrap(err, "invalid license")
+	}
 	license := string(b)
 
 	// Make sure we have a name or slug
 	if uploadLicenseOptions.NewAppName == "" {
-		appName, err := relentlesslyPromptForAppName("")
+		appName, err := relentlesslyPromptForAppName(prompt.OrTerminal(uploadLicenseOptions.Prompter), "")
 		if err != nil {
 			return errors.Wrap(err, "failed to prompt for app name")
 		}
@@ -36,6 +51,9 @@ func UploadLicense(path string, uploadLicenseOptions UploadLicenseOptions) error
 
 	// Find the kotadm-api pod
 	log := logger.NewLogger()
+	if uploadLicenseOptions.Silent {
+		log.Silence()
+	}
 	log.ActionWithSpinner("Uploading license to Admin Console")
 
 	podName, err := findKotsadm(uploadLicenseOptions.Namespace)
@@ -69,7 +87,7 @@ func UploadLicense(path string, uploadLicenseOptions UploadLicenseOptions) error
 		log.FinishSpinnerWithError()
 		return errors.Wrap(err, "failed to create upload request")
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		log.FinishSpinnerWithError()
 		return errors.Wrap(err, "failed to execute request")
@@ -100,6 +118,25 @@ func UploadLicense(path string, uploadLicenseOptions UploadLicenseOptions) error
 	return nil
 }
 
+// validateLicense verifies the license of an application before it's uploaded, so that an expired
+// license or one for another app or channel fails before anything is sent to the admin console
+func validateLicense(license string, uploadOptions UploadOptions) error {
+	options := kotslicense.ValidateOptions{
+		ChannelName: uploadOptions.LicenseChannel,
+	}
+
+	// the app slug is only known when it's a replicated upstream, e.g. replicated://my-app/stable
+	if u, err := url.Parse(uploadOptions.UpstreamURI); err == nil && u.Scheme == "replicated" && u.User == nil {
+		options.AppSlug = u.Hostname()
+	}
+
+	if _, err := kotslicense.ParseAndValidate([]byte(license), options); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func createUploadLicenseRequest(license string, uploadLicenseOptions UploadLicenseOptions, uri string) (*http.Request, error) {
 	body := map[string]string{
 		"name":    uploadLicenseOptions.NewAppName,
@@ -0,0 +1,28 @@
+package upload
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_appNameFromPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "my-app", expected: "my-app"},
+		{path: "/home/user/my-app", expected: "my-app"},
+		{path: "/home/user/my-app/", expected: "my-app"},
+		{path: "./apps//my-app/", expected: "my-app"},
+		{path: "/", expected: ""},
+		{path: ".", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			assert.Equal(t, test.expected, appNameFromPath(filepath.FromSlash(test.path)))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package upload
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// CheckVersionSkew compares the version of kots with the admin console in the namespace of the
+// upload options before uploading. A skew that's compatible is a warning. An admin console that
+// is too old is upgraded with UpgradeAdminConsole, otherwise it's an error, as is one that is too
+// new. Upgrading replaces the api pods, it has to be checked before port forwarding to them.
+func CheckVersionSkew(ctx context.Context, uploadOptions UploadOptions) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	skew, err := kotsadm.GetVersionSkew(uploadOptions.Namespace, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to get version skew")
+	}
+
+	return handleVersionSkew(ctx, skew, uploadOptions, kotsadm.UpgradeWithContext)
+}
+
+func handleVersionSkew(ctx context.Context, skew *kotsadm.VersionSkew, uploadOptions UploadOptions, upgrade func(context.Context, kotsadm.UpgradeOptions) error) error {
+	if skew == nil {
+		return nil
+	}
+
+	log := logger.NewLogger()
+	if uploadOptions.Silent {
+		log.Silence()
+	}
+
+	if !skew.Incompatible {
+		log.Info("Warning: %s", skew)
+		return nil
+	}
+
+	if !skew.CLINewer() {
+		return errors.Errorf("%s, update kots to %s to upload", skew, skew.KotsadmVersion)
+	}
+	if !uploadOptions.UpgradeAdminConsole {
+		return errors.Errorf("%s, upgrade the admin console with kots admin-console upgrade to upload", skew)
+	}
+
+	log.ActionWithSpinner("Upgrading the Admin Console to %s", skew.CLIVersion)
+	err := upgrade(ctx, kotsadm.UpgradeOptions{
+		Namespace:  uploadOptions.Namespace,
+		Kubeconfig: uploadOptions.Kubeconfig,
+	})
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to upgrade admin console")
+	}
+	log.FinishSpinner()
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package upload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_handleVersionSkew(t *testing.T) {
+	tests := []struct {
+		name                string
+		skew                *kotsadm.VersionSkew
+		upgradeAdminConsole bool
+		wantErr             bool
+		wantUpgrade         bool
+	}{
+		{
+			name: "no skew",
+		},
+		{
+			name: "compatible",
+			skew: &kotsadm.VersionSkew{CLIVersion: "v1.17.0", KotsadmVersion: "v1.16.0"},
+		},
+		{
+			name:    "admin console too old",
+			skew:    &kotsadm.VersionSkew{CLIVersion: "v1.18.0", KotsadmVersion: "v1.16.0", Incompatible: true},
+			wantErr: true,
+		},
+		{
+			name:                "upgrade admin console",
+			skew:                &kotsadm.VersionSkew{CLIVersion: "v1.18.0", KotsadmVersion: "v1.16.0", Incompatible: true},
+			upgradeAdminConsole: true,
+			wantUpgrade:         true,
+		},
+		{
+			name:                "cli too old",
+			skew:                &kotsadm.VersionSkew{CLIVersion: "v1.14.0", KotsadmVersion: "v1.16.0", Incompatible: true},
+			upgradeAdminConsole: true,
+			wantErr:             true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			upgraded := false
+			upgrade := func(ctx context.Context, upgradeOptions kotsadm.UpgradeOptions) error {
+				upgraded = true
+				assert.Equal(t, "app", upgradeOptions.Namespace)
+				return nil
+			}
+
+			uploadOptions := UploadOptions{
+				Namespace:           "app",
+				Silent:              true,
+				UpgradeAdminConsole: test.upgradeAdminConsole,
+			}
+			err := handleVersionSkew(context.Background(), test.skew, uploadOptions, upgrade)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.wantUpgrade, upgraded)
+		})
+	}
+}
@@ -6,9 +6,9 @@ import (
 	"path"
 
 	"github.com/google/uuid"
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"github.com/replicatedhq/kots/pkg/prompt"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -26,13 +26,13 @@ type UpstreamSettings struct {
 	AutoCreateClusterToken string
 }
 
-func generateAdminConsoleFiles(renderDir string, sharedPassword string) ([]UpstreamFile, error) {
+func generateAdminConsoleFiles(renderDir string, sharedPassword string, prompter prompt.Prompter) ([]UpstreamFile, error) {
 	if _, err := os.Stat(path.Join(renderDir, "admin-console")); os.IsNotExist(err) {
 		settings := &UpstreamSettings{
 			SharedPassword:         sharedPassword,
 			AutoCreateClusterToken: uuid.New().String(),
 		}
-		return generateNewAdminConsoleFiles(settings)
+		return generateNewAdminConsoleFiles(settings, prompter)
 	}
 
 	existingFiles, err := ioutil.ReadDir(path.Join(renderDir, "admin-console"))
@@ -47,7 +47,7 @@ func generateAdminConsoleFiles(renderDir string, sharedPassword string) ([]Upstr
 		return nil, errors.Wrap(err, "failed to find existing settings")
 	}
 
-	return generateNewAdminConsoleFiles(settings)
+	return generateNewAdminConsoleFiles(settings, prompter)
 }
 
 func loadUpstreamSettingsFromFiles(settings *UpstreamSettings, renderDir string, files []os.FileInfo) error {
@@ -100,7 +100,7 @@ func loadUpstreamSettingsFromDeployment(settings *UpstreamSettings, deployment *
 	}
 }
 
-func generateNewAdminConsoleFiles(settings *UpstreamSettings) ([]UpstreamFile, error) {
+func generateNewAdminConsoleFiles(settings *UpstreamSettings, prompter prompt.Prompter) ([]UpstreamFile, error) {
 	upstreamFiles := []UpstreamFile{}
 
 	deployOptions := kotsadm.DeployOptions{
@@ -117,7 +117,7 @@ func generateNewAdminConsoleFiles(settings *UpstreamSettings) ([]UpstreamFile, e
 	}
 
 	if deployOptions.SharedPasswordBcrypt == "" && deployOptions.SharedPassword == "" {
-		p, err := promptForSharedPassword()
+		p, err := promptForSharedPassword(prompter)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to prompt for shared password")
 		}
@@ -140,18 +140,10 @@ func generateNewAdminConsoleFiles(settings *UpstreamSettings) ([]UpstreamFile, e
 	return upstreamFiles, nil
 }
 
-func promptForSharedPassword() (string, error) {
-	templates := &promptui.PromptTemplates{
-		Prompt:  "{{ . | bold }} ",
-		Valid:   "{{ . | green }} ",
-		Invalid: "{{ . | red }} ",
-		Success: "{{ . | bold }} ",
-	}
-
-	prompt := promptui.Prompt{
-		Label:     "Enter a new password to be used for the Admin Console:",
-		Templates: templates,
-		Mask:      rune('•'),
+func promptForSharedPassword(prompter prompt.Prompter) (string, error) {
+	return prompter.Input(prompt.Input{
+		Label: "Enter a new password to be used for the Admin Console:",
+		Mask:  true,
 		Validate: func(input string) error {
 			if len(input) < 6 {
 				return errors.New("please enter a longer password")
@@ -159,18 +151,5 @@ func promptForSharedPassword() (string, error) {
 
 			return nil
 		},
-	}
-
-	for {
-		result, err := prompt.Run()
-		if err != nil {
-			if err == promptui.ErrInterrupt {
-				os.Exit(-1)
-			}
-			continue
-		}
-
-		return result, nil
-	}
-
+	})
 }
@@ -0,0 +1,67 @@
+package upstream
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/crypto"
+)
+
+// encryptedConfigValuesHeader is the first line of a config values file that is encrypted. The
+// rest of the file is the base64 encoded values, sealed with the key of the app.
+const encryptedConfigValuesHeader = "# kots.io/encrypted-config-values\n"
+
+// IsEncryptedConfigValues is true when the content of a config values file is encrypted
+func IsEncryptedConfigValues(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(encryptedConfigValuesHeader))
+}
+
+// EncryptConfigValues encrypts the content of a config values file, so that the values aren't
+// readable when the app directory is committed to a repo
+func EncryptConfigValues(content []byte, cipher *crypto.AESCipher) ([]byte, error) {
+	sealed, err := cipher.Seal(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt config values")
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(sealed)
+	return []byte(encryptedConfigValuesHeader + encoded + "\n"), nil
+}
+
+// DecryptConfigValues returns the content of a config values file. Content that isn't encrypted
+// is returned as is, so that files from before encryption was enabled can still be read.
+func DecryptConfigValues(content []byte, cipher *crypto.AESCipher) ([]byte, error) {
+	if !IsEncryptedConfigValues(content) {
+		return content, nil
+	}
+	if cipher == nil {
+		return nil, errors.New("config values are encrypted and there is no key to decrypt them")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(content[len(encryptedConfigValuesHeader):])))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode encrypted config values")
+	}
+
+	decrypted, err := cipher.Open(decoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt config values")
+	}
+
+	return decrypted, nil
+}
+
+// encryptConfigValuesFile encrypts the content of the config values file that is written to the
+// upstream. When the previous file has the same values, it's kept as is so that the file only
+// changes in a repo when the values do.
+func encryptConfigValuesFile(content []byte, previousContent []byte, cipher *crypto.AESCipher) ([]byte, error) {
+	if IsEncryptedConfigValues(previousContent) {
+		previous, err := DecryptConfigValues(previousContent, cipher)
+		if err == nil && bytes.Equal(previous, content) {
+			return previousContent, nil
+		}
+	}
+
+	return EncryptConfigValues(content, cipher)
+}
@@ -0,0 +1,77 @@
+package upstream
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfigValues = `apiVersion: kots.io/v1beta1
+kind: ConfigValues
+metadata:
+  name: my-app
+spec:
+  values:
+    db_password:
+      value: 6Yk3hn1Y
+`
+
+func Test_EncryptConfigValues(t *testing.T) {
+	req := require.New(t)
+
+	cipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	encrypted, err := EncryptConfigValues([]byte(testConfigValues), cipher)
+	req.NoError(err)
+	assert.True(t, IsEncryptedConfigValues(encrypted))
+	assert.NotContains(t, string(encrypted), "6Yk3hn1Y")
+
+	decrypted, err := DecryptConfigValues(encrypted, cipher)
+	req.NoError(err)
+	assert.Equal(t, testConfigValues, string(decrypted))
+
+	// values that aren't encrypted are read as is
+	decrypted, err = DecryptConfigValues([]byte(testConfigValues), nil)
+	req.NoError(err)
+	assert.Equal(t, testConfigValues, string(decrypted))
+
+	_, err = DecryptConfigValues(encrypted, nil)
+	assert.Error(t, err)
+
+	otherCipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+	_, err = DecryptConfigValues(encrypted, otherCipher)
+	assert.Error(t, err)
+}
+
+func Test_encryptConfigValuesFile(t *testing.T) {
+	req := require.New(t)
+
+	cipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	previous, err := EncryptConfigValues([]byte(testConfigValues), cipher)
+	req.NoError(err)
+
+	// the file doesn't change when the values are the same
+	encrypted, err := encryptConfigValuesFile([]byte(testConfigValues), previous, cipher)
+	req.NoError(err)
+	assert.Equal(t, string(previous), string(encrypted))
+
+	changed := testConfigValues + "    db_user:\n      value: admin\n"
+	encrypted, err = encryptConfigValuesFile([]byte(changed), previous, cipher)
+	req.NoError(err)
+	assert.NotEqual(t, string(previous), string(encrypted))
+
+	decrypted, err := DecryptConfigValues(encrypted, cipher)
+	req.NoError(err)
+	assert.Equal(t, changed, string(decrypted))
+
+	// the values were written without encryption before
+	encrypted, err = encryptConfigValuesFile([]byte(testConfigValues), []byte(testConfigValues), cipher)
+	req.NoError(err)
+	assert.True(t, IsEncryptedConfigValues(encrypted))
+}
@@ -0,0 +1,112 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+)
+
+var licenseFieldValueRegex = regexp.MustCompile("LicenseFieldValue\\s+[\"`]([^\"`]+)[\"`]")
+
+// EntitlementChange is an entitlement that was added, removed or changed in a newer license
+type EntitlementChange struct {
+	Name          string      `json:"name"`
+	PreviousValue interface{} `json:"previousValue"`
+	CurrentValue  interface{} `json:"currentValue"`
+
+	// IsTemplated is true when the application reads the entitlement in a template,
+	// and needs to be rendered again for the change to take effect
+	IsTemplated bool `json:"isTemplated"`
+}
+
+// DiffEntitlements returns the entitlements that differ between the installed and the latest license,
+// sorted by name
+func DiffEntitlements(installed *kotsv1beta1.License, latest *kotsv1beta1.License) []EntitlementChange {
+	changes := []EntitlementChange{}
+
+	for name, previous := range installed.Spec.Entitlements {
+		current, ok := latest.Spec.Entitlements[name]
+		if !ok {
+			changes = append(changes, EntitlementChange{
+				Name:          name,
+				PreviousValue: previous.Value.Value(),
+			})
+			continue
+		}
+
+		if !reflect.DeepEqual(previous.Value.Value(), current.Value.Value()) {
+			changes = append(changes, EntitlementChange{
+				Name:          name,
+				PreviousValue: previous.Value.Value(),
+				CurrentValue:  current.Value.Value(),
+			})
+		}
+	}
+
+	for name, current := range latest.Spec.Entitlements {
+		if _, ok := installed.Spec.Entitlements[name]; !ok {
+			changes = append(changes, EntitlementChange{
+				Name:         name,
+				CurrentValue: current.Value.Value(),
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
+
+// FindTemplatedEntitlements returns the names of the entitlements that are read with LicenseFieldValue
+// in the files of the upstream directory
+func FindTemplatedEntitlements(upstreamDir string) (map[string]bool, error) {
+	names := map[string]bool{}
+
+	err := filepath.Walk(upstreamDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != upstreamDir && info.Name() == "userdata" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		for _, match := range licenseFieldValueRegex.FindAllSubmatch(content, -1) {
+			names[string(match[1])] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk upstream dir")
+	}
+
+	return names, nil
+}
+
+// MarkTemplatedEntitlements sets IsTemplated on the changes and returns true if any of the changed
+// entitlements are templated
+func MarkTemplatedEntitlements(changes []EntitlementChange, templated map[string]bool) bool {
+	requiresRender := false
+	for i := range changes {
+		changes[i].IsTemplated = templated[changes[i].Name]
+		requiresRender = requiresRender || changes[i].IsTemplated
+	}
+	return requiresRender
+}
@@ -0,0 +1,71 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiffEntitlements(t *testing.T) {
+	installed := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			Entitlements: map[string]kotsv1beta1.EntitlementField{
+				"seats":   {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.Int, IntVal: 10}},
+				"sso":     {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.Bool, BoolVal: false}},
+				"edition": {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.String, StrVal: "team"}},
+				"legacy":  {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.String, StrVal: "yes"}},
+			},
+		},
+	}
+	latest := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			Entitlements: map[string]kotsv1beta1.EntitlementField{
+				"seats":   {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.Int, IntVal: 25}},
+				"sso":     {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.Bool, BoolVal: true}},
+				"edition": {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.String, StrVal: "team"}, Title: "Edition"},
+				"audit":   {Value: kotsv1beta1.EntitlementValue{Type: kotsv1beta1.Bool, BoolVal: true}},
+			},
+		},
+	}
+
+	expected := []EntitlementChange{
+		{Name: "audit", CurrentValue: true},
+		{Name: "legacy", PreviousValue: "yes"},
+		{Name: "seats", PreviousValue: int64(10), CurrentValue: int64(25)},
+		{Name: "sso", PreviousValue: false, CurrentValue: true},
+	}
+	assert.Equal(t, expected, DiffEntitlements(installed, latest))
+	assert.Empty(t, DiffEntitlements(latest, latest))
+}
+
+func Test_FindTemplatedEntitlements(t *testing.T) {
+	upstreamDir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(upstreamDir)
+
+	files := map[string]string{
+		"deployment.yaml":      "replicas: repl{{ LicenseFieldValue \"seats\" }}\n",
+		"config/settings.yaml": "sso: '{{repl LicenseFieldValue `sso` }}'\nname: '{{repl ConfigOption \"name\" }}'\n",
+		"userdata/config.yaml": "value: '{{repl LicenseFieldValue \"ignored\" }}'\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(upstreamDir, name)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(upstreamDir, name), []byte(content), 0644))
+	}
+
+	templated, err := FindTemplatedEntitlements(upstreamDir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"seats": true, "sso": true}, templated)
+
+	changes := []EntitlementChange{{Name: "audit"}, {Name: "seats"}}
+	assert.True(t, MarkTemplatedEntitlements(changes, templated))
+	assert.False(t, changes[0].IsTemplated)
+	assert.True(t, changes[1].IsTemplated)
+
+	assert.False(t, MarkTemplatedEntitlements([]EntitlementChange{{Name: "audit"}}, templated))
+}
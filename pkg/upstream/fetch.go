@@ -1,6 +1,7 @@
 package upstream
 
 import (
+	"context"
 	"net/url"
 
 	"github.com/pkg/errors"
@@ -10,22 +11,30 @@ import (
 )
 
 type FetchOptions struct {
-	RootDir             string
-	UseAppDir           bool
-	HelmRepoName        string
-	HelmRepoURI         string
-	HelmOptions         []string
-	LocalPath           string
-	License             *kotsv1beta1.License
-	ConfigValues        *kotsv1beta1.ConfigValues
-	Airgap              *kotsv1beta1.Airgap
-	EncryptionKey       string
+	RootDir       string
+	UseAppDir     bool
+	HelmRepoName  string
+	HelmRepoURI   string
+	HelmOptions   []string
+	LocalPath     string
+	License       *kotsv1beta1.License
+	ConfigValues  *kotsv1beta1.ConfigValues
+	Airgap        *kotsv1beta1.Airgap
+	EncryptionKey string
+	// ConfigValuesCipher decrypts the config values of the previous pull, when they're encrypted
+	ConfigValuesCipher  *crypto.AESCipher
 	CurrentCursor       string
 	CurrentVersionLabel string
 }
 
 func FetchUpstream(upstreamURI string, fetchOptions *FetchOptions) (*Upstream, error) {
-	upstream, err := downloadUpstream(upstreamURI, fetchOptions)
+	return FetchUpstreamWithContext(context.Background(), upstreamURI, fetchOptions)
+}
+
+// FetchUpstreamWithContext is FetchUpstream, the requests to the replicated app api are canceled
+// with ctx
+func FetchUpstreamWithContext(ctx context.Context, upstreamURI string, fetchOptions *FetchOptions) (*Upstream, error) {
+	upstream, err := downloadUpstream(ctx, upstreamURI, fetchOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "download upstream failed")
 	}
@@ -33,7 +42,7 @@ func FetchUpstream(upstreamURI string, fetchOptions *FetchOptions) (*Upstream, e
 	return upstream, nil
 }
 
-func downloadUpstream(upstreamURI string, fetchOptions *FetchOptions) (*Upstream, error) {
+func downloadUpstream(ctx context.Context, upstreamURI string, fetchOptions *FetchOptions) (*Upstream, error) {
 	if !util.IsURL(upstreamURI) {
 		return readFilesFromPath(upstreamURI)
 	}
@@ -55,7 +64,7 @@ func downloadUpstream(upstreamURI string, fetchOptions *FetchOptions) (*Upstream
 		return downloadHelm(u, fetchOptions.HelmRepoURI)
 	}
 	if u.Scheme == "replicated" {
-		return downloadReplicated(u, fetchOptions.LocalPath, fetchOptions.RootDir, fetchOptions.UseAppDir, fetchOptions.License, fetchOptions.ConfigValues, fetchOptions.CurrentCursor, pickVersionLabel(fetchOptions), cipher)
+		return downloadReplicated(ctx, u, fetchOptions.LocalPath, fetchOptions.RootDir, fetchOptions.UseAppDir, fetchOptions.License, fetchOptions.ConfigValues, fetchOptions.CurrentCursor, pickVersionLabel(fetchOptions), cipher, fetchOptions.ConfigValuesCipher)
 	}
 	if u.Scheme == "git" {
 		return downloadGit(upstreamURI)
@@ -126,7 +126,7 @@ func downloadHelm(u *url.URL, repoURI string) (*Upstream, error) {
 		}
 
 		upstream := &Upstream{
-			URI:          u.RequestURI(),
+			URI:          u.String(),
 			Name:         chartName,
 			Type:         "helm",
 			Files:        files,
@@ -277,11 +277,11 @@ func readTarGz(source string) ([]UpstreamFile, error) {
 	// remove any common prefix from all files
 	if len(upstreamFiles) > 0 {
 		firstFileDir, _ := path.Split(upstreamFiles[0].Path)
-		commonPrefix := strings.Split(firstFileDir, string(os.PathSeparator))
+		commonPrefix := strings.Split(firstFileDir, "/")
 
 		for _, file := range upstreamFiles {
 			d, _ := path.Split(file.Path)
-			dirs := strings.Split(d, string(os.PathSeparator))
+			dirs := strings.Split(d, "/")
 
 			commonPrefix = util.CommonSlicePrefix(commonPrefix, dirs)
 
@@ -290,7 +290,7 @@ func readTarGz(source string) ([]UpstreamFile, error) {
 		cleanedUpstreamFiles := []UpstreamFile{}
 		for _, file := range upstreamFiles {
 			d, f := path.Split(file.Path)
-			d2 := strings.Split(d, string(os.PathSeparator))
+			d2 := strings.Split(d, "/")
 
 			cleanedUpstreamFile := file
 			d2 = d2[len(commonPrefix):]
@@ -0,0 +1,109 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/strvals"
+)
+
+// HelmValuesPath is the upstream file with the values that a helm chart is rendered with. It's
+// kept when the upstream is written again, so that the chart is rendered with the same values.
+var HelmValuesPath = path.Join("userdata", "helm-values.yaml")
+
+// HelmValues are the values that a helm chart is rendered with, merged like helm template merges
+// them: each of the files over the previous ones, and then the set overrides, e.g. "a.b=c", in order
+type HelmValues struct {
+	Files [][]byte
+	Set   []string
+}
+
+// IsEmpty returns true if there are no files or overrides
+func (v HelmValues) IsEmpty() bool {
+	return len(v.Files) == 0 && len(v.Set) == 0
+}
+
+// Merge returns the merged values as yaml
+func (v HelmValues) Merge() ([]byte, error) {
+	merged := map[string]interface{}{}
+	for i, file := range v.Files {
+		values := map[string]interface{}{}
+		if err := yaml.Unmarshal(file, &values); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal values file %d", i+1)
+		}
+		merged = mergeHelmValues(merged, values)
+	}
+
+	for _, value := range v.Set {
+		if err := strvals.ParseInto(value, merged); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse helm value %q", value)
+		}
+	}
+
+	b, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal helm values")
+	}
+	return b, nil
+}
+
+// mergeHelmValues merges src into dest. Maps are merged, all other values in src replace the
+// values in dest.
+func mergeHelmValues(dest map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	for key, value := range src {
+		srcMap, ok := value.(map[string]interface{})
+		if !ok {
+			dest[key] = value
+			continue
+		}
+		destMap, ok := dest[key].(map[string]interface{})
+		if !ok {
+			dest[key] = srcMap
+			continue
+		}
+		dest[key] = mergeHelmValues(destMap, srcMap)
+	}
+	return dest
+}
+
+// SetHelmValues sets the values of a helm upstream. The values are the merged helmValues when they
+// aren't empty, and the values that the upstream in the previous upstreamDir was rendered with
+// otherwise, if there are any.
+func (u *Upstream) SetHelmValues(helmValues HelmValues, upstreamDir string) error {
+	if u.Type != "helm" {
+		if !helmValues.IsEmpty() {
+			return errors.New("helm values can only be set on a helm chart upstream")
+		}
+		return nil
+	}
+
+	var content []byte
+	if !helmValues.IsEmpty() {
+		merged, err := helmValues.Merge()
+		if err != nil {
+			return err
+		}
+		content = merged
+	} else {
+		previous, err := ioutil.ReadFile(path.Join(upstreamDir, HelmValuesPath))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read previous helm values")
+		}
+		content = previous
+	}
+
+	for i, file := range u.Files {
+		if file.Path == HelmValuesPath {
+			u.Files[i].Content = content
+			return nil
+		}
+	}
+	u.Files = append(u.Files, UpstreamFile{Path: HelmValuesPath, Content: content})
+	return nil
+}
@@ -0,0 +1,110 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HelmValuesMerge(t *testing.T) {
+	tests := []struct {
+		name       string
+		helmValues HelmValues
+		expected   string
+	}{
+		{
+			name:       "empty",
+			helmValues: HelmValues{},
+			expected:   "{}\n",
+		},
+		{
+			name: "files in order",
+			helmValues: HelmValues{
+				Files: [][]byte{
+					[]byte("image:\n  repository: nginx\n  tag: \"1.17\"\nreplicas: 1\n"),
+					[]byte("image:\n  tag: \"1.19\"\nreplicas: 3\n"),
+				},
+			},
+			expected: "image:\n  repository: nginx\n  tag: \"1.19\"\nreplicas: 3\n",
+		},
+		{
+			name: "set over files",
+			helmValues: HelmValues{
+				Files: [][]byte{
+					[]byte("image:\n  repository: nginx\n  tag: \"1.17\"\n"),
+				},
+				Set: []string{"image.tag=1.18", "ingress.enabled=true"},
+			},
+			expected: "image:\n  repository: nginx\n  tag: \"1.18\"\ningress:\n  enabled: true\n",
+		},
+		{
+			name: "a file replaces a value with a map",
+			helmValues: HelmValues{
+				Files: [][]byte{
+					[]byte("resources: small\n"),
+					[]byte("resources:\n  limits:\n    cpu: 100m\n"),
+				},
+			},
+			expected: "resources:\n  limits:\n    cpu: 100m\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, err := test.helmValues.Merge()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(merged))
+		})
+	}
+}
+
+func Test_HelmValuesMergeInvalidFile(t *testing.T) {
+	_, err := HelmValues{Files: [][]byte{[]byte("- not\n- a map\n")}}.Merge()
+	assert.Error(t, err)
+}
+
+func Test_SetHelmValues(t *testing.T) {
+	upstreamDir, err := ioutil.TempDir("", "kots-helm-values")
+	require.NoError(t, err)
+	defer os.RemoveAll(upstreamDir)
+
+	newUpstream := func() *Upstream {
+		return &Upstream{
+			Type:  "helm",
+			Files: []UpstreamFile{{Path: "Chart.yaml", Content: []byte("name: nginx\n")}},
+		}
+	}
+
+	// no values, and none from a previous pull
+	u := newUpstream()
+	require.NoError(t, u.SetHelmValues(HelmValues{}, upstreamDir))
+	assert.Len(t, u.Files, 1)
+
+	u = newUpstream()
+	require.NoError(t, u.SetHelmValues(HelmValues{Set: []string{"replicas=2"}}, upstreamDir))
+	require.Len(t, u.Files, 2)
+	assert.Equal(t, HelmValuesPath, u.Files[1].Path)
+	assert.Equal(t, "replicas: 2\n", string(u.Files[1].Content))
+
+	// the values of the previous pull are used when none are set
+	require.NoError(t, os.MkdirAll(path.Join(upstreamDir, "userdata"), 0755))
+	require.NoError(t, ioutil.WriteFile(path.Join(upstreamDir, HelmValuesPath), u.Files[1].Content, 0644))
+	u = newUpstream()
+	require.NoError(t, u.SetHelmValues(HelmValues{}, upstreamDir))
+	require.Len(t, u.Files, 2)
+	assert.Equal(t, "replicas: 2\n", string(u.Files[1].Content))
+
+	// and replaced when they are
+	require.NoError(t, u.SetHelmValues(HelmValues{Set: []string{"replicas=4"}}, upstreamDir))
+	require.Len(t, u.Files, 2)
+	assert.Equal(t, "replicas: 4\n", string(u.Files[1].Content))
+
+	// other upstreams can't have values
+	u = &Upstream{Type: "replicated"}
+	require.NoError(t, u.SetHelmValues(HelmValues{}, upstreamDir))
+	assert.Error(t, u.SetHelmValues(HelmValues{Set: []string{"replicas=4"}}, upstreamDir))
+}
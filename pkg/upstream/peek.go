@@ -1,6 +1,7 @@
 package upstream
 
 import (
+	"context"
 	"net/url"
 
 	"github.com/pkg/errors"
@@ -13,7 +14,13 @@ type Update struct {
 }
 
 func GetUpdatesUpstream(upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
-	versions, err := getUpdatesUpstream(upstreamURI, fetchOptions)
+	return GetUpdatesUpstreamWithContext(context.Background(), upstreamURI, fetchOptions)
+}
+
+// GetUpdatesUpstreamWithContext is GetUpdatesUpstream, the requests to the replicated app api are
+// canceled with ctx
+func GetUpdatesUpstreamWithContext(ctx context.Context, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+	versions, err := getUpdatesUpstream(ctx, upstreamURI, fetchOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "download upstream failed")
 	}
@@ -21,7 +28,7 @@ func GetUpdatesUpstream(upstreamURI string, fetchOptions *FetchOptions) ([]Updat
 	return versions, nil
 }
 
-func getUpdatesUpstream(upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+func getUpdatesUpstream(ctx context.Context, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
 	if !util.IsURL(upstreamURI) {
 		return nil, errors.New("not implemented")
 	}
@@ -34,7 +41,7 @@ func getUpdatesUpstream(upstreamURI string, fetchOptions *FetchOptions) ([]Updat
 		return getUpdatesHelm(u, fetchOptions.HelmRepoURI)
 	}
 	if u.Scheme == "replicated" {
-		return getUpdatesReplicated(u, fetchOptions.LocalPath, fetchOptions.CurrentCursor, fetchOptions.CurrentVersionLabel, fetchOptions.License, fetchOptions.CurrentCursor)
+		return getUpdatesReplicated(ctx, u, fetchOptions.LocalPath, fetchOptions.CurrentCursor, fetchOptions.CurrentVersionLabel, fetchOptions.License, fetchOptions.CurrentCursor)
 	}
 	if u.Scheme == "git" {
 		// return getUpdatesGit(upstreamURI)
@@ -1,10 +1,10 @@
 package upstream
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 
@@ -23,6 +23,11 @@ type PushUpstreamImageOptions struct {
 	ReplicatedRegistry  registry.RegistryOptions
 	ReportWriter        io.Writer
 	DestinationRegistry registry.RegistryOptions
+	// Parallelism is the number of images that are pushed at the same time, see image.DefaultCopyParallelism
+	Parallelism int
+	OnProgress  func(image.CopyProgress)
+	// ProgressReporter is optional, it's sent the number of images that have been copied
+	ProgressReporter logger.ProgressReporter
 }
 
 func (u *Upstream) TagAndPushUpstreamImages(options PushUpstreamImageOptions) ([]kustomizeimage.Image, error) {
@@ -31,13 +36,13 @@ func (u *Upstream) TagAndPushUpstreamImages(options PushUpstreamImageOptions) ([
 		return nil, errors.Wrap(err, "failed to read images dir")
 	}
 
-	images := []kustomizeimage.Image{}
+	tasks := []image.CopyTask{}
 	for _, f := range formatDirs {
 		if !f.IsDir() {
 			continue
 		}
 
-		formatRoot := path.Join(options.ImagesDir, f.Name())
+		formatRoot := filepath.Join(options.ImagesDir, f.Name())
 		err := filepath.Walk(formatRoot,
 			func(path string, info os.FileInfo, err error) error {
 				if err != nil {
@@ -55,48 +60,12 @@ func (u *Upstream) TagAndPushUpstreamImages(options PushUpstreamImageOptions) ([
 					return errors.Wrap(err, "failed to decode image from path")
 				}
 
-				// copy to the registry
-				options.Log.ChildActionWithSpinner("Pushing image %s:%s", rewrittenImage.NewName, rewrittenImage.NewTag)
-
-				registryAuth := image.RegistryAuth{
-					Username: options.DestinationRegistry.Username,
-					Password: options.DestinationRegistry.Password,
-				}
-				err = image.CopyFromFileToRegistry(path, rewrittenImage.NewName, rewrittenImage.NewTag, rewrittenImage.Digest, registryAuth, options.ReportWriter)
-				if err != nil {
-					options.Log.FinishChildSpinner()
-					return errors.Wrap(err, "failed to push image")
-				}
-				options.Log.FinishChildSpinner()
-
-				images = append(images, rewrittenImage)
-
-				// kustomize does string based comparison, so all of these are treated as different images:
-				// docker.io/library/redis:latest
-				// redis:latest
-				// redis
-				// As a workaround we add all 3 to the list
-
-				rewrittenName := rewrittenImage.Name
-				if strings.HasPrefix(rewrittenName, "docker.io/library/") {
-					rewrittenName = strings.TrimPrefix(rewrittenName, "docker.io/library/")
-					images = append(images, kustomizeimage.Image{
-						Name:    rewrittenName,
-						NewName: rewrittenImage.NewName,
-						NewTag:  rewrittenImage.NewTag,
-						Digest:  rewrittenImage.Digest,
-					})
-				}
-
-				if strings.HasSuffix(rewrittenName, ":latest") {
-					rewrittenName = strings.TrimSuffix(rewrittenName, ":latest")
-					images = append(images, kustomizeimage.Image{
-						Name:    rewrittenName,
-						NewName: rewrittenImage.NewName,
-						NewTag:  rewrittenImage.NewTag,
-						Digest:  rewrittenImage.Digest,
-					})
-				}
+				tasks = append(tasks, image.CopyTask{
+					Image: fmt.Sprintf("%s:%s", rewrittenImage.NewName, rewrittenImage.NewTag),
+					Copy: func(reportWriter io.Writer) ([]kustomizeimage.Image, bool, error) {
+						return pushImageFromFile(path, rewrittenImage, options.DestinationRegistry, reportWriter)
+					},
+				})
 
 				return nil
 			})
@@ -106,5 +75,64 @@ func (u *Upstream) TagAndPushUpstreamImages(options PushUpstreamImageOptions) ([
 		}
 	}
 
+	images, err := image.RunCopyPipeline(tasks, image.CopyPipelineOptions{
+		Parallelism:      options.Parallelism,
+		Log:              options.Log,
+		ReportWriter:     options.ReportWriter,
+		OnProgress:       options.OnProgress,
+		ProgressReporter: options.ProgressReporter,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to push images")
+	}
+
 	return images, nil
 }
+
+// pushImageFromFile pushes an image archive to the registry, and returns the images to rewrite
+// it to and whether the registry already had it
+func pushImageFromFile(path string, rewrittenImage kustomizeimage.Image, destinationRegistry registry.RegistryOptions, reportWriter io.Writer) ([]kustomizeimage.Image, bool, error) {
+	registryAuth := image.RegistryAuth{
+		Username: destinationRegistry.Username,
+		Password: destinationRegistry.Password,
+	}
+	convertedDigest, skipped, err := image.CopyFromFileToRegistry(path, rewrittenImage.NewName, rewrittenImage.NewTag, rewrittenImage.Digest, registryAuth, destinationRegistry.OCIOnly, reportWriter)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if convertedDigest != "" && rewrittenImage.Digest != "" {
+		rewrittenImage.Digest = convertedDigest
+	}
+
+	images := []kustomizeimage.Image{rewrittenImage}
+
+	// kustomize does string based comparison, so all of these are treated as different images:
+	// docker.io/library/redis:latest
+	// redis:latest
+	// redis
+	// As a workaround we add all 3 to the list
+
+	rewrittenName := rewrittenImage.Name
+	if strings.HasPrefix(rewrittenName, "docker.io/library/") {
+		rewrittenName = strings.TrimPrefix(rewrittenName, "docker.io/library/")
+		images = append(images, kustomizeimage.Image{
+			Name:    rewrittenName,
+			NewName: rewrittenImage.NewName,
+			NewTag:  rewrittenImage.NewTag,
+			Digest:  rewrittenImage.Digest,
+		})
+	}
+
+	if strings.HasSuffix(rewrittenName, ":latest") {
+		rewrittenName = strings.TrimSuffix(rewrittenName, ":latest")
+		images = append(images, kustomizeimage.Image{
+			Name:    rewrittenName,
+			NewName: rewrittenImage.NewName,
+			NewTag:  rewrittenImage.NewTag,
+			Digest:  rewrittenImage.Digest,
+		})
+	}
+
+	return images, skipped, nil
+}
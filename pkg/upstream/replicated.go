@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -25,6 +26,7 @@ import (
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/image"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	kotslicense "github.com/replicatedhq/kots/pkg/license"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/template"
 	"github.com/replicatedhq/kots/pkg/util"
@@ -67,9 +69,11 @@ type ChannelRelease struct {
 	ReleaseSequence int    `json:"releaseSequence"`
 	VersionLabel    string `json:"versionLabel"`
 	CreatedAt       string `json:"createdAt"`
+	ReleaseNotes    string `json:"releaseNotes"`
+	IsRequired      bool   `json:"isRequired"`
 }
 
-func getUpdatesReplicated(u *url.URL, localPath string, currentCursor, versionLabel string, license *kotsv1beta1.License, channelSequence string) ([]Update, error) {
+func getUpdatesReplicated(ctx context.Context, u *url.URL, localPath string, currentCursor, versionLabel string, license *kotsv1beta1.License, channelSequence string) ([]Update, error) {
 	if localPath != "" {
 		parsedLocalRelease, err := readReplicatedAppFromLocalPath(localPath, currentCursor, versionLabel)
 		if err != nil {
@@ -89,12 +93,12 @@ func getUpdatesReplicated(u *url.URL, localPath string, currentCursor, versionLa
 		return nil, errors.Wrap(err, "failed to parse replicated upstream")
 	}
 
-	remoteLicense, err := getSuccessfulHeadResponse(replicatedUpstream, license)
+	remoteLicense, err := getSuccessfulHeadResponse(ctx, replicatedUpstream, license)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get successful head response")
 	}
 
-	pendingReleases, err := listPendingChannelReleases(replicatedUpstream, remoteLicense, channelSequence)
+	pendingReleases, err := listPendingChannelReleases(ctx, replicatedUpstream, remoteLicense, channelSequence)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list replicated app releases")
 	}
@@ -109,7 +113,7 @@ func getUpdatesReplicated(u *url.URL, localPath string, currentCursor, versionLa
 	return updates, nil
 }
 
-func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir bool, license *kotsv1beta1.License, existingConfigValues *kotsv1beta1.ConfigValues, updateCursor, versionLabel string, cipher *crypto.AESCipher) (*Upstream, error) {
+func downloadReplicated(ctx context.Context, u *url.URL, localPath string, rootDir string, useAppDir bool, license *kotsv1beta1.License, existingConfigValues *kotsv1beta1.ConfigValues, updateCursor, versionLabel string, cipher *crypto.AESCipher, configValuesCipher *crypto.AESCipher) (*Upstream, error) {
 	var release *Release
 
 	if localPath != "" {
@@ -133,12 +137,12 @@ func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir
 			return nil, errors.Wrap(err, "failed to parse replicated upstream")
 		}
 
-		remoteLicense, err := getSuccessfulHeadResponse(replicatedUpstream, license)
+		remoteLicense, err := getSuccessfulHeadResponse(ctx, replicatedUpstream, license)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get successful head response")
 		}
 
-		downloadedRelease, err := downloadReplicatedApp(replicatedUpstream, remoteLicense, updateCursor)
+		downloadedRelease, err := downloadReplicatedApp(ctx, replicatedUpstream, remoteLicense, updateCursor)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to download replicated app")
 		}
@@ -163,7 +167,7 @@ func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir
 			prevConfigFile = filepath.Join(rootDir, "upstream", "userdata", "config.yaml")
 		}
 		var err error
-		existingConfigValues, err = findConfigValuesInFile(prevConfigFile)
+		existingConfigValues, err = findConfigValuesInFile(prevConfigFile, configValuesCipher)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to load existing config values")
 		}
@@ -192,7 +196,7 @@ func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir
 	}
 
 	upstream := &Upstream{
-		URI:          u.RequestURI(),
+		URI:          u.String(),
 		Name:         application.Name,
 		Files:        files,
 		Type:         "replicated",
@@ -253,19 +257,22 @@ func parseReplicatedURL(u *url.URL) (*ReplicatedUpstream, error) {
 	return &replicatedUpstream, nil
 }
 
-func getSuccessfulHeadResponse(replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License) (*kotsv1beta1.License, error) {
+func getSuccessfulHeadResponse(ctx context.Context, replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License) (*kotsv1beta1.License, error) {
 	headReq, err := replicatedUpstream.getRequest("HEAD", license, "")
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create http request")
 	}
-	headResp, err := http.DefaultClient.Do(headReq)
+	headResp, err := http.DefaultClient.Do(headReq.WithContext(ctx))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to execute head request")
 	}
 	defer headResp.Body.Close()
 
-	if headResp.StatusCode == 401 {
-		return nil, errors.New("license was not accepted")
+	if headResp.StatusCode == 401 || headResp.StatusCode == 403 {
+		if err := kotslicense.ValidateLicense(license, kotslicense.ValidateOptions{}); util.ErrorCodeOf(err) == util.ErrLicenseExpired {
+			return nil, err
+		}
+		return nil, util.NewError(util.ErrUnauthorized, "license was not accepted", nil)
 	}
 
 	if headResp.StatusCode >= 400 {
@@ -300,6 +307,8 @@ func readReplicatedAppFromLocalPath(localPath, localCursor, versionLabel string)
 			// remove localpath prefix
 			appPath := strings.TrimPrefix(path, localPath)
 			appPath = strings.TrimLeft(appPath, string(os.PathSeparator))
+			// release manifests are keyed by slash separated paths, as in a release archive
+			appPath = filepath.ToSlash(appPath)
 
 			release.Manifests[appPath] = contents
 
@@ -312,12 +321,12 @@ func readReplicatedAppFromLocalPath(localPath, localCursor, versionLabel string)
 	return &release, nil
 }
 
-func downloadReplicatedApp(replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, channelSequence string) (*Release, error) {
+func downloadReplicatedApp(ctx context.Context, replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, channelSequence string) (*Release, error) {
 	getReq, err := replicatedUpstream.getRequest("GET", license, channelSequence)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create http request")
 	}
-	getResp, err := http.DefaultClient.Do(getReq)
+	getResp, err := http.DefaultClient.Do(getReq.WithContext(ctx))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to execute get request")
 	}
@@ -373,7 +382,25 @@ func downloadReplicatedApp(replicatedUpstream *ReplicatedUpstream, license *kots
 	return &release, nil
 }
 
-func listPendingChannelReleases(replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, channelSequence string) ([]ChannelRelease, error) {
+// ListPendingReleases returns the releases of the channel of the license that are newer than
+// currentCursor
+func ListPendingReleases(ctx context.Context, license *kotsv1beta1.License, currentCursor string) ([]ChannelRelease, error) {
+	replicatedUpstream := &ReplicatedUpstream{AppSlug: license.Spec.AppSlug}
+
+	remoteLicense, err := getSuccessfulHeadResponse(ctx, replicatedUpstream, license)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get successful head response")
+	}
+
+	pendingReleases, err := listPendingChannelReleases(ctx, replicatedUpstream, remoteLicense, currentCursor)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list replicated app releases")
+	}
+
+	return pendingReleases, nil
+}
+
+func listPendingChannelReleases(ctx context.Context, replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, channelSequence string) ([]ChannelRelease, error) {
 	u, err := url.Parse(license.Spec.Endpoint)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse endpoint from license")
@@ -393,7 +420,7 @@ func listPendingChannelReleases(replicatedUpstream *ReplicatedUpstream, license
 
 	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", license.Spec.LicenseID, license.Spec.LicenseID)))))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to execute get request")
 	}
@@ -490,14 +517,30 @@ func createConfigValues(applicationName string, config *kotsv1beta1.Config, exis
 				foundValue = prevValue.Value
 			}
 
-			renderedValue, err := builder.RenderTemplate(item.Name, item.Value)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to render config item value")
+			// a new value is saved as the config context resolved it, so that a value that's
+			// different on each render, like a generated key, matches the items that read it
+			var renderedValue string
+			if itemValue := configCtx.ItemValues[item.Name]; !ok && itemValue.HasValue() {
+				renderedValue = itemValue.ValueStr()
+			} else {
+				renderedValue, err = builder.RenderTemplate(item.Name, item.Value)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to render config item value")
+				}
 			}
 
-			renderedDefault, err := builder.RenderTemplate(item.Name, item.Default)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to render config item default")
+			// a default is rendered again so that it's updated with the release, unless it's a
+			// generated key, which is kept like a value so that it doesn't change on every pull
+			var renderedDefault string
+			if ok && prevValue.Default != "" && template.GeneratesKey(item.Name, item.Default) {
+				renderedDefault = prevValue.Default
+			} else if itemValue := configCtx.ItemValues[item.Name]; !ok && itemValue.HasDefault() {
+				renderedDefault = itemValue.DefaultStr()
+			} else {
+				renderedDefault, err = builder.RenderTemplate(item.Name, item.Default)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to render config item default")
+				}
 			}
 
 			if renderedValue == "" && renderedDefault == "" && foundValue == "" {
@@ -532,7 +575,7 @@ func createConfigValues(applicationName string, config *kotsv1beta1.Config, exis
 	return &configValues, nil
 }
 
-func findConfigValuesInFile(filename string) (*kotsv1beta1.ConfigValues, error) {
+func findConfigValuesInFile(filename string, configValuesCipher *crypto.AESCipher) (*kotsv1beta1.ConfigValues, error) {
 	content, err := ioutil.ReadFile(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -541,6 +584,11 @@ func findConfigValuesInFile(filename string) (*kotsv1beta1.ConfigValues, error)
 		return nil, errors.Wrap(err, "failed to open file")
 	}
 
+	content, err = DecryptConfigValues(content, configValuesCipher)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", filename)
+	}
+
 	decode := scheme.Codecs.UniversalDeserializer().Decode
 	obj, gvk, err := decode(content, nil, nil)
 	if err != nil {
@@ -625,7 +673,7 @@ func releaseToFiles(release *Release) ([]UpstreamFile, error) {
 	withoutUserdataFiles := []UpstreamFile{}
 	for _, file := range upstreamFiles {
 		d, _ := path.Split(file.Path)
-		dirs := strings.Split(d, string(os.PathSeparator))
+		dirs := strings.Split(d, "/")
 
 		if dirs[0] == "userdata" {
 			userdataFiles = append(userdataFiles, file)
@@ -637,11 +685,11 @@ func releaseToFiles(release *Release) ([]UpstreamFile, error) {
 	// remove any common prefix from all files
 	if len(withoutUserdataFiles) > 0 {
 		firstFileDir, _ := path.Split(withoutUserdataFiles[0].Path)
-		commonPrefix := strings.Split(firstFileDir, string(os.PathSeparator))
+		commonPrefix := strings.Split(firstFileDir, "/")
 
 		for _, file := range withoutUserdataFiles {
 			d, _ := path.Split(file.Path)
-			dirs := strings.Split(d, string(os.PathSeparator))
+			dirs := strings.Split(d, "/")
 
 			commonPrefix = util.CommonSlicePrefix(commonPrefix, dirs)
 
@@ -650,7 +698,7 @@ func releaseToFiles(release *Release) ([]UpstreamFile, error) {
 		cleanedUpstreamFiles := []UpstreamFile{}
 		for _, file := range withoutUserdataFiles {
 			d, f := path.Split(file.Path)
-			d2 := strings.Split(d, string(os.PathSeparator))
+			d2 := strings.Split(d, "/")
 
 			cleanedUpstreamFile := file
 			d2 = d2[len(commonPrefix):]
@@ -737,6 +785,7 @@ type FindPrivateImagesOptions struct {
 	CreateAppDir       bool
 	AppSlug            string
 	ReplicatedRegistry registry.RegistryOptions
+	ImageLocations     []k8sdoc.ImageLocation
 	Log                *logger.Logger
 }
 
@@ -747,7 +796,7 @@ func (u *Upstream) FindPrivateImages(options FindPrivateImagesOptions) ([]kustom
 	}
 	upstreamDir := path.Join(rootDir, "upstream")
 
-	upstreamImages, objects, err := image.GetPrivateImages(upstreamDir)
+	upstreamImages, objects, err := image.GetPrivateImages(upstreamDir, options.ImageLocations)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed to list upstream images")
 	}
@@ -777,9 +826,10 @@ func (u *Upstream) FindPrivateImages(options FindPrivateImagesOptions) ([]kustom
 }
 
 type FindObjectsWithImagesOptions struct {
-	RootDir      string
-	CreateAppDir bool
-	Log          *logger.Logger
+	RootDir        string
+	CreateAppDir   bool
+	ImageLocations []k8sdoc.ImageLocation
+	Log            *logger.Logger
 }
 
 func (u *Upstream) FindObjectsWithImages(options FindObjectsWithImagesOptions) ([]*k8sdoc.Doc, error) {
@@ -789,7 +839,7 @@ func (u *Upstream) FindObjectsWithImages(options FindObjectsWithImagesOptions) (
 	}
 	upstreamDir := path.Join(rootDir, "upstream")
 
-	objects, err := image.GetObjectsWithImages(upstreamDir)
+	objects, err := image.GetObjectsWithImages(upstreamDir, options.ImageLocations)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list upstream images")
 	}
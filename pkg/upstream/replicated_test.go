@@ -1,10 +1,14 @@
 package upstream
 
 import (
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/template"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -264,6 +268,56 @@ func Test_createConfigValues(t *testing.T) {
 	assert.Equal(t, expected3, values3.Spec.Values)
 }
 
+func Test_createConfigValuesGeneratedKey(t *testing.T) {
+	req := require.New(t)
+
+	config := &kotsv1beta1.Config{
+		Spec: kotsv1beta1.ConfigSpec{
+			Groups: []kotsv1beta1.ConfigGroup{
+				{
+					Name: "keys",
+					Items: []kotsv1beta1.ConfigItem{
+						{
+							Name:  "public_key",
+							Type:  "textarea",
+							Value: `repl{{ GenPublicKey (ConfigOption "private_key") }}`,
+						},
+						{
+							Name:  "private_key",
+							Type:  "textarea",
+							Value: `repl{{ GenPrivateKey "ed25519" }}`,
+						},
+						{
+							Name:    "jwt_key",
+							Type:    "textarea",
+							Default: `repl{{ GenPrivateKey "rsa" 1024 }}`,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// the saved public key is the one of the saved private key
+	values, err := createConfigValues("app", config, nil, nil)
+	req.NoError(err)
+
+	builder := template.Builder{}
+	builder.AddCtx(template.StaticCtx{})
+	publicKey, err := builder.String(`{{repl GenPublicKey ` + "`" + values.Spec.Values["private_key"].Value + "`" + ` }}`)
+	req.NoError(err)
+	assert.Equal(t, publicKey, values.Spec.Values["public_key"].Value)
+
+	// and the keys are kept by the next renders, including a key that is a default
+	req.NotEmpty(values.Spec.Values["jwt_key"].Default)
+	updated, err := createConfigValues("app", config, values.DeepCopy(), nil)
+	req.NoError(err)
+	assert.Equal(t, values.Spec.Values, updated.Spec.Values)
+	updated, err = createConfigValues("app", config, updated.DeepCopy(), nil)
+	req.NoError(err)
+	assert.Equal(t, values.Spec.Values, updated.Spec.Values)
+}
+
 func Test_getRequest(t *testing.T) {
 	beta := "beta"
 	unstable := "unstable"
@@ -313,3 +367,20 @@ func Test_getRequest(t *testing.T) {
 		assert.Equal(t, test.expectedURL, request.URL.String())
 	}
 }
+
+func Test_readReplicatedAppFromLocalPath(t *testing.T) {
+	localPath, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(localPath)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(localPath, "manifests", "web"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(localPath, "manifests", "web", "deployment.yaml"), []byte("deployment"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(localPath, "kots-app.yaml"), []byte("app"), 0644))
+
+	release, err := readReplicatedAppFromLocalPath(localPath, "1", "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"manifests/web/deployment.yaml": []byte("deployment"),
+		"kots-app.yaml":                 []byte("app"),
+	}, release.Manifests)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/prompt"
+	"github.com/replicatedhq/kots/pkg/util"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -19,18 +21,19 @@ type WriteOptions struct {
 	CreateAppDir        bool
 	IncludeAdminConsole bool
 	SharedPassword      string
+	// ConfigValuesCipher encrypts the config values file, see EncryptConfigValues. A directory with
+	// encrypted config values can't be written without it.
+	ConfigValuesCipher *crypto.AESCipher
+	// Prompter asks for the shared password of a new admin console when it isn't set, on the
+	// terminal when it's nil
+	Prompter prompt.Prompter
 }
 
 func (u *Upstream) WriteUpstream(options WriteOptions) error {
-	renderDir := options.RootDir
-	if options.CreateAppDir {
-		renderDir = path.Join(renderDir, u.Name)
-	}
-
-	renderDir = path.Join(renderDir, "upstream")
+	renderDir := u.GetUpstreamDir(options)
 
 	if options.IncludeAdminConsole {
-		adminConsoleFiles, err := generateAdminConsoleFiles(renderDir, options.SharedPassword)
+		adminConsoleFiles, err := generateAdminConsoleFiles(renderDir, options.SharedPassword, prompt.OrTerminal(options.Prompter))
 		if err != nil {
 			return errors.Wrap(err, "failed to generate admin console")
 		}
@@ -40,6 +43,7 @@ func (u *Upstream) WriteUpstream(options WriteOptions) error {
 
 	var previousValuesContent []byte
 	var previousInstallationContent []byte
+	var previousConfigValuesContent []byte
 	_, err := os.Stat(renderDir)
 	if err == nil {
 		// if there's already a values yaml, we need to save
@@ -63,21 +67,36 @@ func (u *Upstream) WriteUpstream(options WriteOptions) error {
 			previousInstallationContent = c
 		}
 
+		_, err = os.Stat(path.Join(renderDir, "userdata", "config.yaml"))
+		if err == nil {
+			c, err := ioutil.ReadFile(path.Join(renderDir, "userdata", "config.yaml"))
+			if err != nil {
+				return errors.Wrap(err, "failed to read existing config values")
+			}
+
+			previousConfigValuesContent = c
+		}
+
 		if err := os.RemoveAll(renderDir); err != nil {
 			return errors.Wrap(err, "failed to remove previous content in upstream")
 		}
 	}
 
+	if IsEncryptedConfigValues(previousConfigValuesContent) && options.ConfigValuesCipher == nil {
+		return errors.New("the existing config values are encrypted, they can only be written again with the key")
+	}
+
 	for _, file := range u.Files {
-		fileRenderPath := path.Join(renderDir, file.Path)
-		d, _ := path.Split(fileRenderPath)
-		if _, err := os.Stat(d); os.IsNotExist(err) {
-			if err := os.MkdirAll(d, 0744); err != nil {
-				return errors.Wrap(err, "failed to mkdir")
+		content := file.Content
+		if file.Path == path.Join("userdata", "config.yaml") && options.ConfigValuesCipher != nil {
+			encrypted, err := encryptConfigValuesFile(content, previousConfigValuesContent, options.ConfigValuesCipher)
+			if err != nil {
+				return errors.Wrap(err, "failed to encrypt config values")
 			}
+			content = encrypted
 		}
 
-		if err := ioutil.WriteFile(fileRenderPath, file.Content, 0644); err != nil {
+		if _, err := util.WriteFile(renderDir, file.Path, content); err != nil {
 			return errors.Wrap(err, "failed to write upstream file")
 		}
 	}
@@ -139,6 +158,15 @@ func (u *Upstream) WriteUpstream(options WriteOptions) error {
 	return nil
 }
 
+func (u *Upstream) GetUpstreamDir(options WriteOptions) string {
+	renderDir := options.RootDir
+	if options.CreateAppDir {
+		renderDir = path.Join(renderDir, u.Name)
+	}
+
+	return path.Join(renderDir, "upstream")
+}
+
 func (u *Upstream) GetBaseDir(options WriteOptions) string {
 	renderDir := options.RootDir
 	if options.CreateAppDir {
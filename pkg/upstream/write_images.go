@@ -7,6 +7,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/image"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	"github.com/replicatedhq/kots/pkg/logger"
 	kustomizeimage "sigs.k8s.io/kustomize/v3/pkg/image"
 )
@@ -17,8 +18,16 @@ type WriteUpstreamImageOptions struct {
 	AppSlug        string
 	SourceRegistry registry.RegistryOptions
 	DestRegistry   registry.RegistryOptions
+	ImageLocations []k8sdoc.ImageLocation
 	Log            *logger.Logger
 	ReportWriter   io.Writer
+	// Parallelism is the number of images that are copied at the same time, see image.DefaultCopyParallelism
+	Parallelism int
+	OnProgress  func(image.CopyProgress)
+	// ProgressReporter is optional, it's sent the number of images that have been copied
+	ProgressReporter logger.ProgressReporter
+	// Platforms of multi-architecture images to copy (e.g. linux/arm64), or all of them when empty
+	Platforms []string
 }
 
 func (u *Upstream) CopyUpstreamImages(options WriteUpstreamImageOptions) ([]kustomizeimage.Image, error) {
@@ -28,7 +37,13 @@ func (u *Upstream) CopyUpstreamImages(options WriteUpstreamImageOptions) ([]kust
 	}
 	upstreamDir := path.Join(rootDir, "upstream")
 
-	newImages, err := image.CopyImages(options.SourceRegistry, options.DestRegistry, options.AppSlug, options.Log, options.ReportWriter, upstreamDir)
+	newImages, err := image.CopyImages(options.SourceRegistry, options.DestRegistry, options.AppSlug, upstreamDir, options.ImageLocations, options.Platforms, image.CopyPipelineOptions{
+		Parallelism:      options.Parallelism,
+		Log:              options.Log,
+		ReportWriter:     options.ReportWriter,
+		OnProgress:       options.OnProgress,
+		ProgressReporter: options.ProgressReporter,
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to save images")
 	}
@@ -0,0 +1,88 @@
+package util
+
+// ErrorCode identifies a class of failure that callers can handle, e.g. to ask for new credentials
+type ErrorCode string
+
+const (
+	// ErrUnauthorized is returned when the license or credentials were rejected
+	ErrUnauthorized ErrorCode = "unauthorized"
+	// ErrLicenseExpired is returned when the license is past its expiration date
+	ErrLicenseExpired ErrorCode = "license_expired"
+	// ErrRegistryAuth is returned when the registry credentials can't log in or push
+	ErrRegistryAuth ErrorCode = "registry_auth"
+	// ErrArchiveTooLarge is returned when the admin console, or a proxy in front of it, rejects an upload for its size
+	ErrArchiveTooLarge ErrorCode = "archive_too_large"
+	// ErrNameCollision is returned when objects of the same name belong to another app instance
+	ErrNameCollision ErrorCode = "name_collision"
+)
+
+var remediations = map[ErrorCode]string{
+	ErrUnauthorized:    "Check that the license or credentials are correct and have not been revoked.",
+	ErrLicenseExpired:  "Ask the application vendor for a renewed license, and install it with the new license file.",
+	ErrRegistryAuth:    "Check the registry username and password, and that the account can push to the namespace.",
+	ErrArchiveTooLarge: "Exclude files from the upload in a .kotsignore file, or raise the request size limit of the proxy in front of the admin console.",
+	ErrNameCollision:   "Install the app with an instance name that isn't used in the namespace, or in another namespace.",
+}
+
+// Error is an error with a code and a remediation that's printed with it by the kots cli. The
+// wrapped error is the cause, so errors.Cause still returns sentinel errors that were wrapped.
+type Error struct {
+	Code        ErrorCode
+	Message     string
+	Remediation string
+	Err         error
+}
+
+// NewError returns an Error with the default remediation of the code
+func NewError(code ErrorCode, message string, err error) error {
+	return &Error{
+		Code:        code,
+		Message:     message,
+		Remediation: remediations[code],
+		Err:         err,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// ErrorCodeOf returns the code of the outermost Error in the chain of err, or "" if there isn't one
+func ErrorCodeOf(err error) ErrorCode {
+	if e := findError(err); e != nil {
+		return e.Code
+	}
+	return ""
+}
+
+// Remediation returns what the user can do about err, or "" if there is nothing to suggest
+func Remediation(err error) string {
+	if e := findError(err); e != nil {
+		return e.Remediation
+	}
+	return ""
+}
+
+func findError(err error) *Error {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return nil
+		}
+		err = cause.Cause()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Error(t *testing.T) {
+	sentinel := errors.New("license is expired")
+
+	tests := []struct {
+		name                string
+		err                 error
+		expectedMessage     string
+		expectedCode        ErrorCode
+		expectedRemediation string
+		expectedCause       error
+	}{
+		{
+			name:                "message and cause",
+			err:                 NewError(ErrLicenseExpired, "license expired at 2020-06-01T00:00:00Z", sentinel),
+			expectedMessage:     "license expired at 2020-06-01T00:00:00Z: license is expired",
+			expectedCode:        ErrLicenseExpired,
+			expectedRemediation: remediations[ErrLicenseExpired],
+			expectedCause:       sentinel,
+		},
+		{
+			name:                "wrapped",
+			err:                 errors.Wrap(NewError(ErrRegistryAuth, "invalid username or password", nil), "failed to validate registry"),
+			expectedMessage:     "failed to validate registry: invalid username or password",
+			expectedCode:        ErrRegistryAuth,
+			expectedRemediation: remediations[ErrRegistryAuth],
+		},
+		{
+			name: "custom remediation",
+			err: &Error{
+				Code:        ErrUnauthorized,
+				Message:     "failed to verify access to namespace",
+				Remediation: "Ask for access.",
+				Err:         sentinel,
+			},
+			expectedMessage:     "failed to verify access to namespace: license is expired",
+			expectedCode:        ErrUnauthorized,
+			expectedRemediation: "Ask for access.",
+			expectedCause:       sentinel,
+		},
+		{
+			name:            "plain error",
+			err:             errors.Wrap(sentinel, "failed to pull"),
+			expectedMessage: "failed to pull: license is expired",
+			expectedCause:   sentinel,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expectedMessage, test.err.Error())
+			assert.Equal(t, test.expectedCode, ErrorCodeOf(test.err))
+			assert.Equal(t, test.expectedRemediation, Remediation(test.err))
+			if test.expectedCause != nil {
+				assert.Equal(t, test.expectedCause, errors.Cause(test.err))
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultDirMode  os.FileMode = 0755
+	DefaultFileMode os.FileMode = 0644
+)
+
+// FileModes are the permissions that rendered output is written with, and how it's written.
+// Modes that are set are applied as they are, regardless of the umask. Modes that aren't set are the
+// defaults, which the umask applies to, except for files that are written atomically.
+type FileModes struct {
+	// DirMode is the mode of the directories that are created
+	DirMode os.FileMode
+	// FileMode is the mode of the files that are written
+	FileMode os.FileMode
+	// Atomic writes each file to a temp file in the same directory, syncs it and renames it over
+	// the file, so that a crash never leaves a partially written file
+	Atomic bool
+}
+
+// MkdirAll creates the directory and any missing parents
+func (m FileModes) MkdirAll(dir string) error {
+	info, err := os.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return errors.Errorf("%s is not a directory", dir)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to stat directory")
+	}
+
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := m.MkdirAll(parent); err != nil {
+			return err
+		}
+	}
+
+	mode := m.DirMode
+	if mode == 0 {
+		mode = DefaultDirMode
+	}
+	if err := os.Mkdir(dir, mode); err != nil && !os.IsExist(err) {
+		return errors.Wrap(err, "failed to create directory")
+	}
+	if m.DirMode != 0 {
+		if err := os.Chmod(dir, m.DirMode); err != nil {
+			return errors.Wrap(err, "failed to set directory mode")
+		}
+	}
+	return nil
+}
+
+// WriteFile writes the file, creating the directory that it is in
+func (m FileModes) WriteFile(filename string, content []byte) error {
+	if err := m.MkdirAll(filepath.Dir(filename)); err != nil {
+		return err
+	}
+
+	if m.Atomic {
+		return m.writeFileAtomic(filename, content)
+	}
+
+	mode := m.FileMode
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+	if err := ioutil.WriteFile(filename, content, mode); err != nil {
+		return errors.Wrap(err, "failed to write file")
+	}
+	if m.FileMode != 0 {
+		// an existing file keeps its mode when it's written
+		if err := os.Chmod(filename, m.FileMode); err != nil {
+			return errors.Wrap(err, "failed to set file mode")
+		}
+	}
+	return nil
+}
+
+func (m FileModes) writeFileAtomic(filename string, content []byte) error {
+	dir := filepath.Dir(filename)
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp file")
+	}
+	tempFilename := f.Name()
+	defer os.Remove(tempFilename)
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to write temp file")
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to sync temp file")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed to close temp file")
+	}
+
+	// temp files are created with 0600
+	mode := m.FileMode
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+	if err := os.Chmod(tempFilename, mode); err != nil {
+		return errors.Wrap(err, "failed to set file mode")
+	}
+
+	if err := os.Rename(tempFilename, filename); err != nil {
+		return errors.Wrap(err, "failed to rename temp file")
+	}
+
+	// the rename is only durable once the directory is synced, directories can't be synced on windows
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FileModesWriteFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		modes FileModes
+	}{
+		{
+			name:  "modes",
+			modes: FileModes{DirMode: 0750, FileMode: 0640},
+		},
+		{
+			name:  "atomic",
+			modes: FileModes{DirMode: 0750, FileMode: 0640, Atomic: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			dir, err := ioutil.TempDir("", "kots")
+			req.NoError(err)
+			defer os.RemoveAll(dir)
+
+			// an existing file gets the mode when it's written again
+			filename := filepath.Join(dir, "base", "templates", "deployment.yaml")
+			req.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+			req.NoError(ioutil.WriteFile(filename, []byte("previous"), 0666))
+			req.NoError(test.modes.WriteFile(filename, []byte("kind: Deployment\n")))
+
+			newFilename := filepath.Join(dir, "overlays", "midstream", "kustomization.yaml")
+			req.NoError(test.modes.WriteFile(newFilename, []byte("kind: Kustomization\n")))
+
+			content, err := ioutil.ReadFile(filename)
+			req.NoError(err)
+			assert.Equal(t, "kind: Deployment\n", string(content))
+			content, err = ioutil.ReadFile(newFilename)
+			req.NoError(err)
+			assert.Equal(t, "kind: Kustomization\n", string(content))
+
+			files, err := ioutil.ReadDir(filepath.Dir(filename))
+			req.NoError(err)
+			assert.Len(t, files, 1, "temp files are removed")
+
+			if runtime.GOOS == "windows" {
+				return
+			}
+			for _, f := range []string{filename, newFilename} {
+				info, err := os.Stat(f)
+				req.NoError(err)
+				assert.Equal(t, os.FileMode(0640), info.Mode().Perm(), f)
+			}
+			for _, d := range []string{filepath.Join(dir, "overlays"), filepath.Join(dir, "overlays", "midstream")} {
+				info, err := os.Stat(d)
+				req.NoError(err)
+				assert.Equal(t, os.FileMode(0750), info.Mode().Perm(), d)
+			}
+		})
+	}
+}
+
+func Test_FileModesDefaults(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "base", "deployment.yaml")
+	req.NoError(FileModes{Atomic: true}.WriteFile(filename, []byte("kind: Deployment\n")))
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	info, err := os.Stat(filename)
+	req.NoError(err)
+	assert.Equal(t, DefaultFileMode, info.Mode().Perm())
+}
+
+func Test_FileModesMkdirAllNotADirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "base")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(""), 0644))
+	assert.Error(t, FileModes{}.MkdirAll(filepath.Join(filename, "templates")))
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	invalidFilenameChars = regexp.MustCompile(`[<>:"|?*\\\x00-\x1f]`)
+	reservedFilenames    = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[1-9]|lpt[1-9])$`)
+)
+
+// SanitizeFilePath returns the slash separated path with each element made valid on Windows, as well as
+// on linux and macos. Characters that Windows doesn't allow (such as ":" in names made from kinds) are
+// replaced with "-", trailing dots and spaces are removed, and reserved device names get a "_" suffix.
+// The same path is returned on every platform so that rendered output doesn't depend on where it was rendered.
+func SanitizeFilePath(p string) string {
+	elements := strings.Split(p, "/")
+	for i, element := range elements {
+		if element == "" || element == "." || element == ".." {
+			continue
+		}
+
+		element = invalidFilenameChars.ReplaceAllString(element, "-")
+		element = strings.TrimRight(element, ". ")
+
+		stem := element
+		ext := ""
+		if idx := strings.Index(element, "."); idx != -1 {
+			stem, ext = element[:idx], element[idx:]
+		}
+		if reservedFilenames.MatchString(stem) {
+			element = stem + "_" + ext
+		}
+
+		if element == "" {
+			element = "_"
+		}
+		elements[i] = element
+	}
+	return strings.Join(elements, "/")
+}
+
+// WriteFile writes content to the slash separated relPath in dir, creating any missing directories.
+// relPath is sanitized first, and the sanitized path is returned so that it can be referenced, for
+// example from a kustomization.
+func WriteFile(dir string, relPath string, content []byte) (string, error) {
+	return WriteFileWithModes(dir, relPath, content, FileModes{})
+}
+
+// WriteFileWithModes is WriteFile with the modes that the file and directories are written with
+func WriteFileWithModes(dir string, relPath string, content []byte, modes FileModes) (string, error) {
+	relPath = SanitizeFilePath(path.Clean(relPath))
+	filename := filepath.Join(dir, filepath.FromSlash(relPath))
+
+	if err := modes.WriteFile(filename, content); err != nil {
+		return "", errors.Wrapf(err, "failed to write %s", relPath)
+	}
+
+	return relPath, nil
+}
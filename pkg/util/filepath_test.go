@@ -0,0 +1,77 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SanitizeFilePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "valid path",
+			path:     "charts/templates/deployment.yaml",
+			expected: "charts/templates/deployment.yaml",
+		},
+		{
+			name:     "colon from a kind",
+			path:     "manifests/rbac.authorization.k8s.io:ClusterRole.yaml",
+			expected: "manifests/rbac.authorization.k8s.io-ClusterRole.yaml",
+		},
+		{
+			name:     "invalid characters",
+			path:     `a<b>/c"d|e?f*g\h.yaml`,
+			expected: "a-b-/c-d-e-f-g-h.yaml",
+		},
+		{
+			name:     "trailing dots and spaces",
+			path:     "dir. /file.yaml.",
+			expected: "dir/file.yaml",
+		},
+		{
+			name:     "reserved names",
+			path:     "con/NUL.yaml",
+			expected: "con_/NUL_.yaml",
+		},
+		{
+			name:     "reserved prefix is valid",
+			path:     "console/com10.yaml",
+			expected: "console/com10.yaml",
+		},
+		{
+			name:     "relative elements",
+			path:     "../upstream/./a.yaml",
+			expected: "../upstream/./a.yaml",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, SanitizeFilePath(test.path))
+		})
+	}
+}
+
+func Test_WriteFile(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	written, err := WriteFile(dir, "templates/apps:Deployment.yaml", []byte("kind: Deployment\n"))
+	req.NoError(err)
+	assert.Equal(t, "templates/apps-Deployment.yaml", written)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "templates", "apps-Deployment.yaml"))
+	req.NoError(err)
+	assert.Equal(t, "kind: Deployment\n", string(content))
+}
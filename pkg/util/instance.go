@@ -0,0 +1,48 @@
+package util
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// InstanceLabelKey is set to the instance name on the objects that kots creates for one instance of
+// an app, so that an object of the same name that belongs to another instance is detected
+const InstanceLabelKey = "kots.io/instance"
+
+// maxInstanceNameLength keeps the longest instance scoped name, of the registry refresh cronjob, within
+// the 52 characters that cronjob names are limited to
+const maxInstanceNameLength = 24
+
+var validInstanceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ValidateInstanceName checks that an instance name can be the prefix of object names and a label value.
+// An empty name is valid, it's the instance of single app installs.
+func ValidateInstanceName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(name) > maxInstanceNameLength {
+		return errors.Errorf("invalid instance name %q, it can be at most %d characters", name, maxInstanceNameLength)
+	}
+	if !validInstanceName.MatchString(name) {
+		return errors.Errorf("invalid instance name %q, it can only contain lowercase letters, numbers and dashes, and must start and end with a letter or number", name)
+	}
+	return nil
+}
+
+// InstanceObjectName returns the name of an object that kots creates for an app, prefixed with the instance
+// name so that apps that are installed in the same namespace don't share the object. The name isn't changed
+// when there is no instance name.
+func InstanceObjectName(instanceName string, name string) string {
+	if instanceName == "" {
+		return name
+	}
+	return instanceName + "-" + name
+}
+
+// IsInstanceObject returns true when an object with the labels belongs to the instance, objects that were
+// created without an instance name don't have the label
+func IsInstanceObject(labels map[string]string, instanceName string) bool {
+	return labels[InstanceLabelKey] == instanceName
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidateInstanceName(t *testing.T) {
+	tests := []struct {
+		name      string
+		expectErr bool
+	}{
+		{name: ""},
+		{name: "my-app"},
+		{name: "app2"},
+		{name: "My-App", expectErr: true},
+		{name: "-my-app", expectErr: true},
+		{name: "my-app-", expectErr: true},
+		{name: "my_app", expectErr: true},
+		{name: "an-instance-name-that-is-too-long", expectErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateInstanceName(test.name)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_InstanceObjectName(t *testing.T) {
+	assert.Equal(t, "kotsadm-replicated-registry", InstanceObjectName("", "kotsadm-replicated-registry"))
+	assert.Equal(t, "my-app-kotsadm-replicated-registry", InstanceObjectName("my-app", "kotsadm-replicated-registry"))
+}
+
+func Test_IsInstanceObject(t *testing.T) {
+	assert.True(t, IsInstanceObject(nil, ""))
+	assert.True(t, IsInstanceObject(map[string]string{InstanceLabelKey: "my-app"}, "my-app"))
+	assert.False(t, IsInstanceObject(map[string]string{InstanceLabelKey: "other-app"}, "my-app"))
+	assert.False(t, IsInstanceObject(nil, "my-app"))
+	assert.False(t, IsInstanceObject(map[string]string{InstanceLabelKey: "my-app"}, ""))
+}
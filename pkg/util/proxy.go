@@ -0,0 +1,84 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyOptions are the proxy and the additional CA certificates to make outbound requests with.
+// Options that are not set fall back to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+type ProxyOptions struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+
+	// AdditionalCACert is a PEM encoded bundle of certificates that are trusted in addition to the
+	// system roots, e.g. the CA of a proxy that intercepts tls
+	AdditionalCACert []byte
+}
+
+// ProxyFunc returns the proxy to use for a request, in the form of http.Transport.Proxy
+func (o ProxyOptions) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if o.HTTPProxy != "" {
+		proxyConfig.HTTPProxy = o.HTTPProxy
+	}
+	if o.HTTPSProxy != "" {
+		proxyConfig.HTTPSProxy = o.HTTPSProxy
+	}
+	if o.NoProxy != "" {
+		proxyConfig.NoProxy = o.NoProxy
+	}
+
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// RootCAs returns the system roots with the additional CA certificates, or nil to use the system
+// roots when there are none
+func (o ProxyOptions) RootCAs() (*x509.CertPool, error) {
+	if len(o.AdditionalCACert) == 0 {
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(o.AdditionalCACert) {
+		return nil, errors.New("no certificates found in additional CA bundle")
+	}
+
+	return pool, nil
+}
+
+// ConfigureDefaultTransport sets the proxy and trusted CAs of http.DefaultTransport, which is
+// used by http.DefaultClient and by the clients that don't set a transport of their own
+func ConfigureDefaultTransport(options ProxyOptions) error {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return errors.New("default transport is not an http.Transport")
+	}
+
+	rootCAs, err := options.RootCAs()
+	if err != nil {
+		return errors.Wrap(err, "failed to load additional CA certificates")
+	}
+
+	transport.Proxy = options.ProxyFunc()
+	if rootCAs != nil {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.RootCAs = rootCAs
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package util
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ProxyFunc(t *testing.T) {
+	proxyOptions := ProxyOptions{
+		HTTPSProxy: "http://proxy.example.com:3128",
+		NoProxy:    "internal.example.com",
+	}
+	proxyFunc := proxyOptions.ProxyFunc()
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{
+			url:      "https://replicated.app/release/app",
+			expected: "http://proxy.example.com:3128",
+		},
+		{
+			url:      "https://registry.internal.example.com/v2/",
+			expected: "",
+		},
+		{
+			url:      "http://localhost:8800/api/v1/kots",
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", test.url, nil)
+		require.NoError(t, err)
+
+		proxyURL, err := proxyFunc(req)
+		require.NoError(t, err)
+		if test.expected == "" {
+			assert.Nil(t, proxyURL, test.url)
+		} else {
+			assert.Equal(t, test.expected, proxyURL.String(), test.url)
+		}
+	}
+}
+
+func Test_RootCAs(t *testing.T) {
+	pool, err := ProxyOptions{}.RootCAs()
+	require.NoError(t, err)
+	assert.Nil(t, pool)
+
+	_, err = ProxyOptions{AdditionalCACert: []byte("not a certificate")}.RootCAs()
+	require.Error(t, err)
+}